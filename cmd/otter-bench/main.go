@@ -0,0 +1,372 @@
+// Command otter-bench load-tests an Otter config center. Simulated clients
+// get and put configs of a group while others long-poll watch it, and the
+// latency percentiles of each operation are reported along with how long a
+// change takes to reach the watchers.
+//
+// Every simulated client has its own connection pool and shares one access
+// token, so the server sees as many clients as requested without a login
+// each. Run the server with a -settings file setting token_rate_limit: 0,
+// or the shared token is soon rate limited.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// options holds the command line flags
+type options struct {
+	server   string
+	token    string
+	tenant   string
+	username string
+	password string
+
+	namespace string
+	group     string
+	keys      int
+
+	getters     int
+	writers     int
+	watchers    int
+	putInterval time.Duration
+	duration    time.Duration
+	websocket   bool
+}
+
+func main() {
+	o := &options{}
+	fs := flag.NewFlagSet("otter-bench", flag.ExitOnError)
+	fs.StringVar(&o.server, "server", envOr("OTTER_SERVER", "http://localhost:8086"), "Server URL, or a comma-separated list (env OTTER_SERVER)")
+	fs.StringVar(&o.token, "token", os.Getenv("OTTER_TOKEN"), "Access token (env OTTER_TOKEN)")
+	fs.StringVar(&o.tenant, "tenant", os.Getenv("OTTER_TENANT"), "Tenant to log in to, empty for users outside any tenant (env OTTER_TENANT)")
+	fs.StringVar(&o.username, "username", envOr("OTTER_USERNAME", "admin"), "Username to log in with (env OTTER_USERNAME)")
+	fs.StringVar(&o.password, "password", envOr("OTTER_PASSWORD", "admin"), "Password to log in with (env OTTER_PASSWORD)")
+	fs.StringVar(&o.namespace, "namespace", "public", "Namespace of the benchmark configs")
+	fs.StringVar(&o.group, "group", "otter-bench", "Group of the benchmark configs, overwritten by the run")
+	fs.IntVar(&o.keys, "keys", 100, "Number of configs in the group")
+	fs.IntVar(&o.getters, "getters", 50, "Clients getting configs in a loop")
+	fs.IntVar(&o.writers, "writers", 2, "Clients putting configs")
+	fs.IntVar(&o.watchers, "watchers", 100, "Clients long-poll watching the group")
+	fs.DurationVar(&o.putInterval, "put-interval", 100*time.Millisecond, "Pause between the puts of each writer")
+	fs.DurationVar(&o.duration, "duration", 30*time.Second, "How long to run the load")
+	fs.BoolVar(&o.websocket, "websocket", false, "Watch over WebSocket instead of long polls")
+	fs.Parse(os.Args[1:])
+
+	if o.keys < 1 || o.getters < 0 || o.writers < 0 || o.watchers < 0 {
+		fmt.Fprintln(os.Stderr, "otter-bench: -keys must be positive and client counts not negative")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, o); err != nil {
+		fmt.Fprintf(os.Stderr, "otter-bench: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// run seeds the group, starts the watchers and then the getters and
+// writers, and prints the report once the load has run for o.duration
+func run(ctx context.Context, o *options) error {
+	setup := o.newClient()
+	defer setup.Close()
+	if o.token == "" {
+		if err := setup.Login(ctx, o.username, o.password); err != nil {
+			return fmt.Errorf("login: %w", err)
+		}
+		o.token = setup.Token()
+	}
+
+	keys := make([]string, o.keys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%04d", i)
+		if _, err := setup.PutConfig(ctx, o.namespace, o.group, keys[i], "seed", "text"); err != nil {
+			return fmt.Errorf("seed %s/%s/%s: %w", o.namespace, o.group, keys[i], err)
+		}
+	}
+
+	b := &bench{
+		runID:   fmt.Sprintf("%x", rand.Uint32()),
+		puts:    make(map[string]*pendingPut),
+		watches: o.watchers,
+	}
+
+	watchCtx, stopWatches := context.WithCancel(ctx)
+	defer stopWatches()
+	for i := 0; i < o.watchers; i++ {
+		c := o.newClient()
+		defer c.Close()
+		c.WatchGroup(watchCtx, o.namespace, o.group, b.received)
+	}
+	if o.watchers > 0 {
+		// Give the watches time to reach the server before changes start
+		fmt.Fprintf(os.Stderr, "Started %d watchers\n", o.watchers)
+		if !sleepContext(ctx, time.Second) {
+			return ctx.Err()
+		}
+	}
+
+	loadCtx, stopLoad := context.WithTimeout(ctx, o.duration)
+	defer stopLoad()
+	fmt.Fprintf(os.Stderr, "Running %d getters and %d writers for %v\n", o.getters, o.writers, o.duration)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < o.getters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := o.newClient()
+			defer c.Close()
+			for loadCtx.Err() == nil {
+				key := keys[rand.IntN(len(keys))]
+				t := time.Now()
+				_, err := c.GetConfig(loadCtx, o.namespace, o.group, key)
+				b.gets.observe(loadCtx, time.Since(t), err)
+			}
+		}()
+	}
+	for i := 0; i < o.writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := o.newClient()
+			defer c.Close()
+			for loadCtx.Err() == nil {
+				key := keys[rand.IntN(len(keys))]
+				value := b.nextValue()
+				t := b.sent(value)
+				_, err := c.PutConfig(loadCtx, o.namespace, o.group, key, value, "text")
+				b.putLatency.observe(loadCtx, time.Since(t), err)
+				if err != nil {
+					b.forget(value)
+				}
+				if !sleepContext(loadCtx, o.putInterval) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Let the last changes reach the watchers
+	if o.watchers > 0 {
+		sleepContext(ctx, time.Second)
+	}
+	stopWatches()
+
+	b.report(os.Stdout, elapsed)
+	return nil
+}
+
+// newClient creates a simulated client with a connection pool of its own.
+// Retries are disabled so the latencies are those of single requests.
+func (o *options) newClient() *client.Client {
+	transport := client.WatchTransportLongPoll
+	if o.websocket {
+		transport = client.WatchTransportWebSocket
+	}
+	return client.NewClientWithConfig(client.ClientConfig{
+		Endpoint:       o.server,
+		Token:          o.token,
+		Tenant:         o.tenant,
+		Logger:         client.NopLogger{},
+		Retry:          client.RetryPolicy{MaxRetries: -1},
+		WatchTransport: transport,
+	})
+}
+
+// sleepContext waits for d, returning false if ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// pendingPut tracks a put value until every watcher has received it
+type pendingPut struct {
+	sentAt   time.Time
+	received int
+}
+
+// bench collects the measurements of a run
+type bench struct {
+	runID string
+	seq   atomic.Int64
+
+	gets       recorder
+	putLatency recorder
+	// delivery records the time from sending a put to each watcher
+	// receiving it, and fanOut to the last of them
+	delivery recorder
+	fanOut   recorder
+
+	mu      sync.Mutex
+	puts    map[string]*pendingPut // by value
+	watches int
+}
+
+// nextValue returns a config value unique to this put of the run
+func (b *bench) nextValue() string {
+	return fmt.Sprintf("bench-%s-%d", b.runID, b.seq.Add(1))
+}
+
+// sent registers a value about to be put and returns the time it was sent
+func (b *bench) sent(value string) time.Time {
+	now := time.Now()
+	if b.watches == 0 {
+		return now
+	}
+	b.mu.Lock()
+	b.puts[value] = &pendingPut{sentAt: now}
+	b.mu.Unlock()
+	return now
+}
+
+// forget stops tracking a value whose put failed
+func (b *bench) forget(value string) {
+	b.mu.Lock()
+	delete(b.puts, value)
+	b.mu.Unlock()
+}
+
+// received is the callback of every watcher
+func (b *bench) received(cfg *model.Config) {
+	now := time.Now()
+	b.mu.Lock()
+	put, ok := b.puts[cfg.Value]
+	if !ok {
+		// A seed value, or one received after the fan-out was recorded
+		b.mu.Unlock()
+		return
+	}
+	put.received++
+	complete := put.received == b.watches
+	if complete {
+		delete(b.puts, cfg.Value)
+	}
+	b.mu.Unlock()
+
+	b.delivery.add(now.Sub(put.sentAt))
+	if complete {
+		b.fanOut.add(now.Sub(put.sentAt))
+	}
+}
+
+// report prints a table of the operations measured over elapsed
+func (b *bench) report(out io.Writer, elapsed time.Duration) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\tcount\terrors\trate/s\tp50\tp90\tp99\tmax\t")
+	b.gets.print(tw, "get", elapsed)
+	b.putLatency.print(tw, "put", elapsed)
+	if b.watches > 0 {
+		b.delivery.print(tw, "watch delivery", elapsed)
+		b.fanOut.print(tw, "full fan-out", elapsed)
+	}
+	tw.Flush()
+
+	if b.gets.rateLimited+b.putLatency.rateLimited > 0 {
+		fmt.Fprintf(out, "\n%d requests were rate limited; set token_rate_limit: 0 in the server's -settings file\n",
+			b.gets.rateLimited+b.putLatency.rateLimited)
+	}
+	if b.watches > 0 {
+		b.mu.Lock()
+		incomplete := len(b.puts)
+		b.mu.Unlock()
+		// Group watches only return the changes made while a poll is held,
+		// and watch queues keep only the latest change of a key, so not
+		// every put need reach every watcher
+		fmt.Fprintf(out, "\n%d puts did not reach every watcher (made between two polls, replaced by a later put of the key, or still in flight)\n", incomplete)
+	}
+}
+
+// recorder accumulates the latencies and errors of one operation
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int64
+	// rateLimited counts the errors that were 429 responses
+	rateLimited int64
+}
+
+// observe records an operation that took d and failed with err, if not
+// nil. Operations cut short because ctx ended are not counted.
+func (r *recorder) observe(ctx context.Context, d time.Duration, err error) {
+	if err == nil {
+		r.add(d)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	var apiErr *client.APIError
+	r.mu.Lock()
+	r.errors++
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		r.rateLimited++
+	}
+	r.mu.Unlock()
+}
+
+// add records a successful operation that took d
+func (r *recorder) add(d time.Duration) {
+	r.mu.Lock()
+	r.latencies = append(r.latencies, d)
+	r.mu.Unlock()
+}
+
+// print writes a table row of the operation's count, error count, rate and
+// latency percentiles
+func (r *recorder) print(tw *tabwriter.Writer, name string, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	count := int64(len(r.latencies))
+	rate := float64(count) / elapsed.Seconds()
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%v\t%v\t%v\t%v\t\n", name, count, r.errors, rate,
+		percentile(r.latencies, 0.50), percentile(r.latencies, 0.90), percentile(r.latencies, 0.99), percentile(r.latencies, 1))
+}
+
+// percentile returns the q-th quantile (0 < q <= 1) of sorted latencies
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q*float64(len(sorted)) + 0.5)
+	if i < 1 {
+		i = 1
+	}
+	if i > len(sorted) {
+		i = len(sorted)
+	}
+	return sorted[i-1].Round(time.Microsecond)
+}
@@ -0,0 +1,992 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// runUser administers users: list, create, update and delete
+func runUser(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, create, update or delete")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("user "+action, flag.ContinueOnError)
+	password := fs.String("password", "", "Password of the user")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the password from the first line of stdin")
+	role := fs.String("role", "", "Role: admin, user or viewer (default user on create)")
+	status := fs.String("status", "", "Status: active or inactive (default active on create)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *passwordStdin {
+		if *password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if action == "list" {
+		users, err := c.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "USERNAME\tROLE\tSTATUS\tCREATED")
+		for _, u := range users {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.Username, u.Role, u.Status, u.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("expected user %s <username>", action)
+	}
+	username := positional[0]
+
+	switch action {
+	case "create":
+		if *password == "" {
+			return errors.New("--password or --password-stdin is required")
+		}
+		req := client.UserRequest{Username: username, Password: *password, Role: *role, Status: *status}
+		if req.Role == "" {
+			req.Role = "user"
+		}
+		if req.Status == "" {
+			req.Status = "active"
+		}
+		if _, err := c.CreateUser(ctx, req); err != nil {
+			return err
+		}
+		fmt.Printf("Created user %s\n", username)
+	case "update":
+		// The server replaces role and status, so keep what is not being changed
+		users, err := c.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		req := client.UserRequest{Password: *password, Role: *role, Status: *status}
+		found := false
+		for _, u := range users {
+			if u.Username == username {
+				found = true
+				if req.Role == "" {
+					req.Role = u.Role
+				}
+				if req.Status == "" {
+					req.Status = u.Status
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("user %s not found", username)
+		}
+		if _, err := c.UpdateUser(ctx, username, req); err != nil {
+			return err
+		}
+		fmt.Printf("Updated user %s\n", username)
+	case "delete":
+		if err := c.DeleteUser(ctx, username); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted user %s\n", username)
+	default:
+		return fmt.Errorf("unknown action %q, expected list, create, update or delete", action)
+	}
+	return nil
+}
+
+// runNamespace administers namespaces: list, create and delete
+func runNamespace(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, create or delete")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("ns "+action, flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "list":
+		namespaces, err := c.ListNamespaces(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ns := range namespaces {
+			fmt.Println(ns)
+		}
+	case "create", "delete":
+		if len(positional) == 0 {
+			return fmt.Errorf("expected ns %s <namespace>...", action)
+		}
+		for _, ns := range positional {
+			done := "Created"
+			if action == "create" {
+				err = c.CreateNamespace(ctx, ns)
+			} else {
+				done = "Deleted"
+				err = c.DeleteNamespace(ctx, ns)
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", action, ns, err)
+			}
+			fmt.Printf("%s namespace %s\n", done, ns)
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected list, create or delete", action)
+	}
+	return nil
+}
+
+// runTenant administers tenants: list, create and delete
+func runTenant(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, create or delete")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("tenant "+action, flag.ContinueOnError)
+	admin := fs.String("admin", "", "Username of the tenant's first admin")
+	password := fs.String("admin-password", "", "Password of the tenant's first admin")
+	passwordStdin := fs.Bool("admin-password-stdin", false, "Read the admin password from the first line of stdin")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *passwordStdin {
+		if *password, err = readPassword(); err != nil {
+			return err
+		}
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if action == "list" {
+		tenants, err := c.ListTenants(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCREATED BY\tCREATED")
+		for _, t := range tenants {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, t.CreatedBy, t.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("expected tenant %s <name>", action)
+	}
+	name := positional[0]
+
+	switch action {
+	case "create":
+		req := client.TenantRequest{Name: name}
+		if *admin != "" {
+			if *password == "" {
+				return errors.New("--admin-password or --admin-password-stdin is required with --admin")
+			}
+			req.Admin = &client.UserRequest{Username: *admin, Password: *password}
+		}
+		if _, err := c.CreateTenant(ctx, req); err != nil {
+			return err
+		}
+		fmt.Printf("Created tenant %s\n", name)
+	case "delete":
+		if err := c.DeleteTenant(ctx, name); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted tenant %s\n", name)
+	default:
+		return fmt.Errorf("unknown action %q, expected list, create or delete", action)
+	}
+	return nil
+}
+
+// readPassword reads a password from the first line of stdin
+func readPassword() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// runSession lists and revokes login sessions, the caller's own or, with
+// --user, another user's
+func runSession(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list or revoke")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("session "+action, flag.ContinueOnError)
+	user := fs.String("user", "", "Manage the sessions of another user (admin only)")
+	all := fs.Bool("all", false, "Revoke every session of --user")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "list":
+		var sessions []*model.Session
+		if *user != "" {
+			sessions, err = c.ListUserSessions(ctx, *user)
+		} else {
+			sessions, err = c.ListSessions(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tIP\tCREATED\tREFRESHED\tUSER AGENT")
+		for _, s := range sessions {
+			id := s.ID
+			if s.Current {
+				id += " *"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", id, s.IP, s.CreatedAt.Format(time.RFC3339), s.RefreshedAt.Format(time.RFC3339), s.UserAgent)
+		}
+		return w.Flush()
+	case "revoke":
+		if *all {
+			if *user == "" {
+				return errors.New("--all requires --user")
+			}
+			n, err := c.RevokeUserSessions(ctx, *user)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Revoked %d sessions of %s\n", n, *user)
+			return nil
+		}
+		if len(positional) == 0 {
+			return errors.New("expected session revoke <id>... or --all --user U")
+		}
+		for _, id := range positional {
+			if *user != "" {
+				err = c.RevokeUserSession(ctx, *user, id)
+			} else {
+				err = c.RevokeSession(ctx, id)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Revoked session %s\n", id)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q, expected list or revoke", action)
+	}
+}
+
+// runToken mints and revokes scoped read-only tokens for applications
+func runToken(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected create or revoke")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("token "+action, flag.ContinueOnError)
+	var scopeFlags stringList
+	fs.Var(&scopeFlags, "scope", "Namespace and group the token may read, as <namespace>/<group> or <namespace>/* (repeatable)")
+	ttl := fs.Duration("ttl", 0, "Token lifetime (default 90 days, at most 8760h)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "create":
+		if len(positional) != 1 || len(scopeFlags) == 0 {
+			return errors.New("expected token create <name> --scope <namespace>/<group>...")
+		}
+		var scopes []client.TokenScope
+		for _, s := range scopeFlags {
+			namespace, group, ok := strings.Cut(s, "/")
+			if !ok || namespace == "" || group == "" {
+				return fmt.Errorf("invalid scope %q, expected <namespace>/<group>", s)
+			}
+			scopes = append(scopes, client.TokenScope{Namespace: namespace, Group: group})
+		}
+		token, err := c.CreateScopedToken(ctx, positional[0], scopes, *ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Created token %s (id %s), expires %s\n", token.Name, token.ID, token.ExpiresAt.Format(time.RFC3339))
+		fmt.Println(token.Token)
+	case "revoke":
+		if len(positional) == 0 {
+			return errors.New("expected token revoke <id>...")
+		}
+		for _, id := range positional {
+			if err := c.RevokeScopedToken(ctx, id); err != nil {
+				return err
+			}
+			fmt.Printf("Revoked token %s\n", id)
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected create or revoke", action)
+	}
+	return nil
+}
+
+// runLock administers config locks and namespace freezes: list, set and
+// remove. A target is a <ns>/<group>/<key> or a whole <ns>.
+func runLock(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, set or remove")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("lock "+action, flag.ContinueOnError)
+	reason := fs.String("reason", "", "Why the configs are locked")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("expected lock %s <namespace>[/<group>/<key>]", action)
+	}
+	target := positional[0]
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if action == "list" {
+		locks, err := c.ListLocks(ctx, target)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TARGET\tOWNER\tSINCE\tREASON")
+		for _, l := range locks {
+			name := l.Namespace
+			if l.Group != "" || l.Key != "" {
+				name += "/" + l.Group + "/" + l.Key
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, l.Owner, l.CreatedAt.Format(time.RFC3339), l.Reason)
+		}
+		return w.Flush()
+	}
+
+	var namespace, group, key string
+	if strings.Contains(target, "/") {
+		if namespace, group, key, err = parseKey(target); err != nil {
+			return err
+		}
+	} else {
+		namespace = target
+	}
+
+	switch action {
+	case "set":
+		if *reason == "" {
+			return errors.New("--reason is required")
+		}
+		if key == "" {
+			_, err = c.FreezeNamespace(ctx, namespace, *reason)
+		} else {
+			_, err = c.LockConfig(ctx, namespace, group, key, *reason)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Locked %s\n", target)
+	case "remove":
+		if key == "" {
+			err = c.UnfreezeNamespace(ctx, namespace)
+		} else {
+			err = c.UnlockConfig(ctx, namespace, group, key)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Unlocked %s\n", target)
+	default:
+		return fmt.Errorf("unknown action %q, expected list, set or remove", action)
+	}
+	return nil
+}
+
+// runOwner administers config ownership: list, set and remove. A target is
+// a <ns>/<group>/<key> or a whole <ns>/<group>.
+func runOwner(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, set or remove")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("owner "+action, flag.ContinueOnError)
+	var owner client.Owner
+	fs.StringVar(&owner.User, "user", "", "User owning the configs")
+	fs.StringVar(&owner.Team, "team", "", "Team owning the configs")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("expected owner %s <namespace>/<group>[/<key>]", action)
+	}
+	target := positional[0]
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if action == "list" {
+		owners, err := c.ListOwners(ctx, target)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TARGET\tOWNER\tASSIGNED BY")
+		for _, o := range owners {
+			name := o.Namespace + "/" + o.Group
+			if o.Key != "" {
+				name += "/" + o.Key
+			}
+			who := "user " + o.User
+			if o.Team != "" {
+				who = "team " + o.Team
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, who, o.AssignedBy)
+		}
+		return w.Flush()
+	}
+
+	parts := strings.SplitN(target, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] == "") {
+		return fmt.Errorf("invalid target %q, expected <namespace>/<group>[/<key>]", target)
+	}
+	namespace, group, key := parts[0], parts[1], ""
+	if len(parts) == 3 {
+		key = parts[2]
+	}
+
+	switch action {
+	case "set":
+		if (owner.User == "") == (owner.Team == "") {
+			return errors.New("expected one of --user or --team")
+		}
+		if key == "" {
+			_, err = c.SetGroupOwner(ctx, namespace, group, owner)
+		} else {
+			_, err = c.SetConfigOwner(ctx, namespace, group, key, owner)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Assigned %s\n", target)
+	case "remove":
+		if key == "" {
+			err = c.DeleteGroupOwner(ctx, namespace, group)
+		} else {
+			err = c.DeleteConfigOwner(ctx, namespace, group, key)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed the owner of %s\n", target)
+	default:
+		return fmt.Errorf("unknown action %q, expected list, set or remove", action)
+	}
+	return nil
+}
+
+// runTeam administers teams: list, set and delete
+func runTeam(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, set or delete")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("team "+action, flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "list":
+		teams, err := c.ListTeams(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tMEMBERS\tUPDATED BY")
+		for _, t := range teams {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, strings.Join(t.Members, ","), t.UpdatedBy)
+		}
+		return w.Flush()
+	case "set":
+		if len(positional) < 1 {
+			return errors.New("expected team set <name> <member>...")
+		}
+		if _, err := c.SetTeam(ctx, positional[0], positional[1:]); err != nil {
+			return err
+		}
+		fmt.Printf("Saved team %s\n", positional[0])
+	case "delete":
+		if len(positional) != 1 {
+			return errors.New("expected team delete <name>")
+		}
+		if err := c.DeleteTeam(ctx, positional[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted team %s\n", positional[0])
+	default:
+		return fmt.Errorf("unknown action %q, expected list, set or delete", action)
+	}
+	return nil
+}
+
+// runSchedule administers scheduled config changes: list, set and cancel
+func runSchedule(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, set or cancel")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("schedule "+action, flag.ContinueOnError)
+	at := fs.String("at", "", "When to publish the value, as RFC 3339")
+	configType := fs.String("type", "", "Config type, text by default")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "list":
+		if len(positional) != 1 {
+			return errors.New("expected schedule list <namespace>")
+		}
+		changes, err := c.ListScheduledChanges(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tKEY\tPUBLISH AT\tSTATUS\tBY\tERROR")
+		for _, ch := range changes {
+			fmt.Fprintf(w, "%d\t%s/%s/%s\t%s\t%s\t%s\t%s\n", ch.ID, ch.Namespace, ch.Group, ch.Key,
+				ch.PublishAt.Format(time.RFC3339), ch.Status, ch.CreatedBy, ch.Error)
+		}
+		return w.Flush()
+	case "set":
+		if len(positional) != 2 {
+			return errors.New("expected schedule set <ns>/<group>/<key> <value> --at TIME")
+		}
+		namespace, group, key, err := parseKey(positional[0])
+		if err != nil {
+			return err
+		}
+		publishAt, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("invalid --at %q, expected a time such as 2006-01-02T03:00:00Z", *at)
+		}
+		change, err := c.ScheduleConfig(ctx, namespace, group, key, positional[1], *configType, publishAt)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Scheduled %s for %s as change %d\n", positional[0], change.PublishAt.Format(time.RFC3339), change.ID)
+	case "cancel":
+		if len(positional) < 2 {
+			return errors.New("expected schedule cancel <namespace> <id>...")
+		}
+		for _, arg := range positional[1:] {
+			var id int64
+			if _, err := fmt.Sscan(arg, &id); err != nil {
+				return fmt.Errorf("invalid scheduled change ID %q", arg)
+			}
+			if err := c.CancelScheduledChange(ctx, positional[0], id); err != nil {
+				return err
+			}
+			fmt.Printf("Cancelled scheduled change %d\n", id)
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected list, set or cancel", action)
+	}
+	return nil
+}
+
+// runVariant administers the label-selected variants of a config: list, set
+// and delete
+func runVariant(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, set or delete")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("variant "+action, flag.ContinueOnError)
+	selector := fs.String("selector", "", "Labels the clients served the variant have, e.g. region=eu,tier=canary")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("expected variant %s <ns>/<group>/<key>", action)
+	}
+	namespace, group, key, err := parseKey(positional[0])
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "list":
+		variants, err := c.ListVariants(ctx, namespace, group, key)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSELECTOR\tUPDATED BY\tVALUE")
+		for _, v := range variants {
+			labels := make([]string, 0, len(v.Selector))
+			for name, value := range v.Selector {
+				labels = append(labels, name+"="+value)
+			}
+			sort.Strings(labels)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Name, strings.Join(labels, ","), v.UpdatedBy, v.Value)
+		}
+		return w.Flush()
+	case "set":
+		if len(positional) != 3 {
+			return errors.New("expected variant set <ns>/<group>/<key> <name> <value> --selector LABELS")
+		}
+		labels := make(map[string]string)
+		for _, pair := range strings.Split(*selector, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || name == "" || value == "" {
+				return fmt.Errorf("invalid --selector %q, expected name=value pairs separated by commas", *selector)
+			}
+			labels[name] = value
+		}
+		if _, err := c.SetVariant(ctx, namespace, group, key, positional[1], labels, positional[2]); err != nil {
+			return err
+		}
+		fmt.Printf("Set variant %s of %s\n", positional[1], positional[0])
+	case "delete":
+		if len(positional) != 2 {
+			return errors.New("expected variant delete <ns>/<group>/<key> <name>")
+		}
+		if err := c.DeleteVariant(ctx, namespace, group, key, positional[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted variant %s of %s\n", positional[1], positional[0])
+	default:
+		return fmt.Errorf("unknown action %q, expected list, set or delete", action)
+	}
+	return nil
+}
+
+// runOverride administers the overrides of a config targeted at single
+// client instances: list, set and delete
+func runOverride(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, set or delete")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("override "+action, flag.ContinueOnError)
+	clientID := fs.String("client-id", "", "Client ID of the instance targeted")
+	ip := fs.String("ip", "", "IP of the instance targeted, if not by client ID")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("expected override %s <ns>/<group>/<key>", action)
+	}
+	namespace, group, key, err := parseKey(positional[0])
+	if err != nil {
+		return err
+	}
+	if action != "list" && (*clientID == "") == (*ip == "") {
+		return errors.New("expected either --client-id or --ip")
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	target := *clientID
+	if target == "" {
+		target = *ip
+	}
+	switch action {
+	case "list":
+		overrides, err := c.ListOverrides(ctx, namespace, group, key)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "CLIENT ID\tIP\tUPDATED BY\tVALUE")
+		for _, o := range overrides {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.Selector["otter.client_id"], o.Selector["otter.ip"], o.UpdatedBy, o.Value)
+		}
+		return w.Flush()
+	case "set":
+		if len(positional) != 2 {
+			return errors.New("expected override set <ns>/<group>/<key> <value> --client-id ID|--ip IP")
+		}
+		if _, err := c.SetOverride(ctx, namespace, group, key, *clientID, *ip, positional[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Overrode %s for %s\n", positional[0], target)
+	case "delete":
+		if err := c.DeleteOverride(ctx, namespace, group, key, *clientID, *ip); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted override of %s for %s\n", positional[0], target)
+	default:
+		return fmt.Errorf("unknown action %q, expected list, set or delete", action)
+	}
+	return nil
+}
+
+// runAccess shows how often the configs of a namespace or group have been
+// fetched or watched, and when and by which client they last were
+func runAccess(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("access", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("expected access <namespace>[/<group>]")
+	}
+	namespace, group, _ := strings.Cut(positional[0], "/")
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	records, err := c.ListConfigAccess(ctx, namespace, group)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tACCESSES\tLAST ACCESSED\tCLIENT\tIP")
+	for _, a := range records {
+		last, client := "never", a.LastClientID
+		if a.LastAccessed != nil {
+			last = a.LastAccessed.Format(time.RFC3339)
+		}
+		if client == "" {
+			client = a.LastUsername
+		}
+		fmt.Fprintf(w, "%s/%s/%s\t%d\t%s\t%s\t%s\n", a.Namespace, a.Group, a.Key, a.AccessCount, last, client, a.LastIP)
+	}
+	return w.Flush()
+}
+
+// runStale lists the configs not accessed or not modified in a number of
+// days, candidates for cleaning up
+func runStale(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("stale", flag.ContinueOnError)
+	days := fs.Int("days", 30, "Days without access or change")
+	namespace := fs.String("namespace", "", "Namespace to report on, all if empty")
+	by := fs.String("by", "", "Report only configs not accessed (access) or not modified (update)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("expected stale [--days N] [--namespace NS] [--by access|update]")
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	configs, err := c.StaleConfigs(ctx, *days, *namespace, *by)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tUPDATED\tUPDATED BY\tLAST ACCESSED\tACCESSES")
+	for _, s := range configs {
+		last := "never"
+		if s.LastAccessed != nil {
+			last = s.LastAccessed.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s/%s/%s\t%s\t%s\t%s\t%d\n", s.Namespace, s.Group, s.Key, s.UpdatedAt.Format(time.RFC3339), s.UpdatedBy, last, s.AccessCount)
+	}
+	return w.Flush()
+}
+
+// runWebhook administers the webhooks of a namespace and their deliveries:
+// list, add, delete, deliveries and redeliver
+func runWebhook(ctx context.Context, g *globals, args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, add, delete, deliveries or redeliver")
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("webhook "+action, flag.ContinueOnError)
+	secret := fs.String("secret", "", "Secret signing the requests, generated if empty")
+	status := fs.String("status", "", "Only list deliveries with this status: pending, delivered or dead")
+	limit := fs.Int("limit", 0, "Deliveries to list, 50 by default")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return fmt.Errorf("expected webhook %s <namespace>", action)
+	}
+	namespace := positional[0]
+	// Every other action takes webhook and delivery IDs after the namespace
+	var ids []int64
+	if action != "add" {
+		for _, arg := range positional[1:] {
+			var id int64
+			if _, err := fmt.Sscan(arg, &id); err != nil {
+				return fmt.Errorf("invalid ID %q", arg)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch action {
+	case "list":
+		hooks, err := c.ListWebhooks(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tURL\tBY\tCREATED")
+		for _, h := range hooks {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", h.ID, h.URL, h.CreatedBy, h.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	case "add":
+		if len(positional) != 2 {
+			return errors.New("expected webhook add <namespace> <url> [--secret S]")
+		}
+		hook, err := c.CreateWebhook(ctx, namespace, positional[1], *secret)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created webhook %d, signing requests with secret %s\n", hook.ID, hook.Secret)
+	case "delete":
+		if len(ids) == 0 {
+			return errors.New("expected webhook delete <namespace> <id>...")
+		}
+		for _, id := range ids {
+			if err := c.DeleteWebhook(ctx, namespace, id); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted webhook %d\n", id)
+		}
+	case "deliveries":
+		if len(ids) != 1 {
+			return errors.New("expected webhook deliveries <namespace> <id> [--status S] [--limit N]")
+		}
+		deliveries, err := c.ListWebhookDeliveries(ctx, namespace, ids[0], *status, *limit)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tEVENT\tSTATUS\tATTEMPTS\tNEXT ATTEMPT\tCREATED\tERROR")
+		for _, d := range deliveries {
+			next := "-"
+			if d.Status == "pending" {
+				next = d.NextAttemptAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\t%s\n", d.ID, d.Event, d.Status, d.Attempts, next, d.CreatedAt.Format(time.RFC3339), d.LastError)
+		}
+		return w.Flush()
+	case "redeliver":
+		if len(ids) < 2 {
+			return errors.New("expected webhook redeliver <namespace> <id> <delivery>...")
+		}
+		for _, deliveryID := range ids[1:] {
+			if _, err := c.RedeliverWebhook(ctx, namespace, ids[0], deliveryID); err != nil {
+				return err
+			}
+			fmt.Printf("Redelivering delivery %d\n", deliveryID)
+		}
+	default:
+		return fmt.Errorf("unknown action %q, expected list, add, delete, deliveries or redeliver", action)
+	}
+	return nil
+}
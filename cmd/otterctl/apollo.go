@@ -0,0 +1,108 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// apolloTypes maps the formats of Apollo namespaces to otter types
+var apolloTypes = map[string]string{
+	"properties": "properties",
+	"xml":        "xml",
+	"json":       "json",
+	"yml":        "yaml",
+	"yaml":       "yaml",
+	"txt":        "text",
+}
+
+// readApolloExport reads an Apollo config export zip, which holds every
+// namespace as <owner>/<appId>/<env>/<appId>+<cluster>+<namespace>.<format>
+// next to .metadata files. Each app becomes an otter namespace, or
+// opts.namespace if set, its clusters become groups and its Apollo
+// namespaces keys. A properties namespace is imported as one properties
+// config named after it; other formats keep their suffix, as in Apollo.
+// Exports covering several environments need opts.env to pick one.
+func readApolloExport(file string, opts importOptions) ([]*exportFile, error) {
+	namespace, env := opts.namespace, opts.env
+	if file == "-" {
+		return nil, fmt.Errorf("an Apollo export must be read from a file")
+	}
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	envs := make(map[string]bool)
+	byApp := make(map[string]*exportFile)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || strings.HasSuffix(f.Name, ".metadata") {
+			continue
+		}
+		dir, name := path.Split(f.Name)
+		fileEnv := path.Base(path.Clean(dir))
+		if dir == "" || fileEnv == "." {
+			return nil, fmt.Errorf("unexpected entry %s, expected <owner>/<appId>/<env>/<appId>+<cluster>+<namespace>", f.Name)
+		}
+		envs[fileEnv] = true
+		if env != "" && !strings.EqualFold(fileEnv, env) {
+			continue
+		}
+
+		parts := strings.SplitN(name, "+", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("unexpected entry %s, expected <appId>+<cluster>+<namespace> file", f.Name)
+		}
+		appID, cluster, key := parts[0], parts[1], parts[2]
+		format := strings.TrimPrefix(path.Ext(key), ".")
+		configType, ok := apolloTypes[format]
+		if !ok {
+			return nil, fmt.Errorf("unsupported format of %s", f.Name)
+		}
+		if configType == "properties" {
+			key = strings.TrimSuffix(key, ".properties")
+		}
+
+		value, err := readZipEntry(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		out := byApp[appID]
+		if out == nil {
+			out = &exportFile{Namespace: appID, Configs: []exportConfig{}}
+			byApp[appID] = out
+		}
+		out.Configs = append(out.Configs, exportConfig{Group: cluster, Key: key, Type: configType, Value: value})
+	}
+
+	if env == "" && len(envs) > 1 {
+		names := make([]string, 0, len(envs))
+		for name := range envs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("export covers environments %s, pick one with --env", strings.Join(names, ", "))
+	}
+	if namespace != "" && len(byApp) > 1 {
+		return nil, fmt.Errorf("export covers %d apps, which cannot share --namespace", len(byApp))
+	}
+
+	files := make([]*exportFile, 0, len(byApp))
+	for _, out := range byApp {
+		if namespace != "" {
+			out.Namespace = namespace
+		}
+		sort.Slice(out.Configs, func(i, j int) bool {
+			if out.Configs[i].Group != out.Configs[j].Group {
+				return out.Configs[i].Group < out.Configs[j].Group
+			}
+			return out.Configs[i].Key < out.Configs[j].Key
+		})
+		files = append(files, out)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Namespace < files[j].Namespace })
+	return files, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runBackup downloads a full dump of the server, or makes the server save
+// one to its backup target
+func runBackup(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := fs.String("o", "", "Output file (default: stdout)")
+	save := fs.Bool("save", false, "Save the backup to the server's backup target instead of downloading it")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if *save {
+		name, err := c.SaveBackup(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved backup %s\n", name)
+		return nil
+	}
+
+	data, err := c.Backup(ctx)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote backup to %s (%d bytes)\n", *output, len(data))
+	return nil
+}
+
+// runRestore uploads a backup to the server
+func runRestore(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	input := fs.String("f", "", "Backup file to restore, or - for stdin")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return errors.New("-f is required")
+	}
+
+	var data []byte
+	var err error
+	if *input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	result, err := c.Restore(ctx, data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d namespaces, %d configs, %d history entries and %d users\n", result.Namespaces, result.Configs, result.History, result.Users)
+	return nil
+}
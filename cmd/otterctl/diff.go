@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sotowang/otter/internal/util"
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// ANSI escapes used to colorize diffs
+const (
+	colorReset = "\x1b[0m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorBold  = "\x1b[1m"
+)
+
+// runDiff compares two versions of a key, or every key of two namespaces
+func runDiff(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	from := fs.Int64("from", 0, "Version to diff from (default: the version before --to)")
+	to := fs.Int64("to", 0, "Version to diff to (default: the current value)")
+	contextLines := fs.Int("context", 3, "Lines of context around each change")
+	var namespaces stringList
+	fs.Var(&namespaces, "namespace", "Namespace to compare (give exactly two)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var diff string
+	switch {
+	case len(namespaces) > 0:
+		if len(namespaces) != 2 || len(positional) > 0 {
+			return errors.New("expected exactly two --namespace flags and no key")
+		}
+		diff, err = diffNamespaces(ctx, c, namespaces[0], namespaces[1], *contextLines)
+	case len(positional) == 1:
+		namespace, group, key, perr := parseKey(positional[0])
+		if perr != nil {
+			return perr
+		}
+		diff, err = diffVersions(ctx, c, namespace, group, key, *from, *to, *contextLines)
+	default:
+		return errors.New("expected <ns>/<group>/<key> or two --namespace flags")
+	}
+	if err != nil {
+		return err
+	}
+
+	if g.colorize(os.Stdout) {
+		diff = colorizeDiff(diff)
+	}
+	fmt.Fprint(os.Stdout, diff)
+	return nil
+}
+
+// diffVersions diffs two versions of a key from its history. A zero to
+// means the current value and a zero from the version preceding to.
+func diffVersions(ctx context.Context, c *client.Client, namespace, group, key string, from, to int64, contextLines int) (string, error) {
+	histories, err := c.ListHistory(ctx, namespace, group, key)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(histories, func(i, j int) bool { return histories[i].ID < histories[j].ID })
+
+	toName, toValue := "/dev/null", ""
+	toIndex := len(histories)
+	if to == 0 {
+		current, err := c.GetConfig(ctx, namespace, group, key)
+		var apiErr *client.APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound) {
+			return "", err
+		}
+		if current != nil {
+			to = current.Version
+			toName = fmt.Sprintf("%s (current, version %d)", key, to)
+			toValue = current.Value
+		}
+	}
+	if i := findVersion(histories, to); i >= 0 {
+		toIndex = i
+		if toName == "/dev/null" {
+			toName = fmt.Sprintf("%s (version %d)", key, to)
+			toValue = histories[i].Value
+		}
+	} else if toName == "/dev/null" && to != 0 {
+		return "", fmt.Errorf("version %d not found in history", to)
+	}
+
+	fromName, fromValue := "/dev/null", ""
+	if from != 0 {
+		i := findVersion(histories, from)
+		if i < 0 {
+			return "", fmt.Errorf("version %d not found in history", from)
+		}
+		fromName = fmt.Sprintf("%s (version %d)", key, from)
+		fromValue = histories[i].Value
+	} else if toIndex > 0 {
+		prev := histories[toIndex-1]
+		fromName = fmt.Sprintf("%s (version %d)", key, prev.Version)
+		fromValue = prev.Value
+	}
+
+	return util.UnifiedDiff(fromName, toName, fromValue, toValue, contextLines), nil
+}
+
+// findVersion returns the index of the latest history entry recording
+// version, or -1
+func findVersion(histories []*model.ConfigHistory, version int64) int {
+	for i := len(histories) - 1; i >= 0; i-- {
+		if histories[i].Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// diffNamespaces diffs every config of two namespaces, matched by group and key
+func diffNamespaces(ctx context.Context, c *client.Client, a, b string, contextLines int) (string, error) {
+	left, err := namespaceValues(ctx, c, a)
+	if err != nil {
+		return "", err
+	}
+	right, err := namespaceValues(ctx, c, b)
+	if err != nil {
+		return "", err
+	}
+
+	paths := make([]string, 0, len(left)+len(right))
+	for path := range left {
+		paths = append(paths, path)
+	}
+	for path := range right {
+		if _, ok := left[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var out strings.Builder
+	for _, path := range paths {
+		fromName, toName := "/dev/null", "/dev/null"
+		fromValue, inLeft := left[path]
+		toValue, inRight := right[path]
+		if inLeft {
+			fromName = a + "/" + path
+		}
+		if inRight {
+			toName = b + "/" + path
+		}
+		out.WriteString(util.UnifiedDiff(fromName, toName, fromValue, toValue, contextLines))
+	}
+	return out.String(), nil
+}
+
+// namespaceValues maps the group/key of every config in a namespace to its value
+func namespaceValues(ctx context.Context, c *client.Client, namespace string) (map[string]string, error) {
+	configs, err := c.ListNamespaceConfigs(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("list namespace %s: %w", namespace, err)
+	}
+	values := make(map[string]string, len(configs))
+	for _, cfg := range configs {
+		values[cfg.Group+"/"+cfg.Key] = cfg.Value
+	}
+	return values, nil
+}
+
+// colorize reports whether output to w should be colorized
+func (g *globals) colorize(w io.Writer) bool {
+	switch g.color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorizeDiff adds ANSI colors to a unified diff
+func colorizeDiff(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			lines[i] = colorBold + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = colorCyan + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		case strings.HasPrefix(line, "-"):
+			lines[i] = colorRed + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		case strings.HasPrefix(line, "+"):
+			lines[i] = colorGreen + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// exportFile is the format written by export and read by import. Versions
+// record what was exported so import can detect later changes on the server.
+type exportFile struct {
+	Namespace  string         `json:"namespace" yaml:"namespace"`
+	ExportedAt time.Time      `json:"exported_at" yaml:"exported_at"`
+	Configs    []exportConfig `json:"configs" yaml:"configs"`
+}
+
+type exportConfig struct {
+	Group       string `json:"group" yaml:"group"`
+	Key         string `json:"key" yaml:"key"`
+	Type        string `json:"type" yaml:"type"`
+	ContentType string `json:"content_type,omitempty" yaml:"content_type,omitempty"`
+	Version     int64  `json:"version,omitempty" yaml:"version,omitempty"`
+	Value       string `json:"value" yaml:"value"`
+}
+
+// runExport writes every config of a namespace to a YAML or JSON file, or
+// with --group the configs of one group flattened by the server into a
+// dotenv, properties, YAML or JSON file
+func runExport(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace to export")
+	group := fs.String("group", "", "Group to flatten into a single file")
+	format := fs.String("format", "dotenv", "Format of a group export: dotenv, properties, yaml or json")
+	output := fs.String("o", "", "Output file, .json for JSON and YAML otherwise (default: YAML to stdout)")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *namespace == "" {
+		return errors.New("--namespace is required")
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if *group != "" {
+		data, err := c.ExportGroup(ctx, *namespace, *group, *format)
+		if err != nil {
+			return err
+		}
+		if *output == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(*output, data, 0o600); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported group %s of %s to %s\n", *group, *namespace, *output)
+		return nil
+	}
+
+	configs, err := c.ListNamespaceConfigs(ctx, *namespace)
+	if err != nil {
+		return err
+	}
+	file := exportFile{Namespace: *namespace, ExportedAt: time.Now().UTC(), Configs: []exportConfig{}}
+	for _, cfg := range configs {
+		file.Configs = append(file.Configs, exportConfig{Group: cfg.Group, Key: cfg.Key, Type: cfg.Type, ContentType: cfg.ContentType, Version: cfg.Version, Value: cfg.Value})
+	}
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(*output), ".json") {
+		data, err = json.MarshalIndent(file, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d configs from %s to %s\n", len(file.Configs), *namespace, *output)
+	return nil
+}
+
+// importAction is what import does with one config
+type importAction string
+
+const (
+	actionCreate    importAction = "create"
+	actionUpdate    importAction = "update"
+	actionUnchanged importAction = "unchanged"
+	// actionConflict marks configs changed on the server since the export
+	actionConflict importAction = "conflict"
+)
+
+// importOptions are the import flags that select what a source reads
+type importOptions struct {
+	namespace string // Target namespace, empty for the source's default
+	env       string // Environment to read from sources exporting several
+}
+
+// importSources read a file from otter or another config center into
+// export files, one per target namespace
+var importSources = map[string]func(path string, opts importOptions) ([]*exportFile, error){
+	"otter":  readOtterImport,
+	"nacos":  readNacosExport,
+	"apollo": readApolloExport,
+}
+
+// runImport applies a file written by export, or exported by another config
+// center, reporting configs changed on the server since the export as
+// conflicts
+func runImport(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := fs.String("f", "", "File to import, or - for stdin")
+	from := fs.String("from", "otter", "Format of the file: otter (written by export), nacos or apollo (export zips), dotenv or properties")
+	group := fs.String("group", "", "Group to import a dotenv or properties file into")
+	key := fs.String("key", "", "Import a dotenv or properties file as one properties config of this key instead of one config per entry")
+	var opts importOptions
+	fs.StringVar(&opts.namespace, "namespace", "", "Namespace to import into (default: the exported namespace, public for Nacos or the appId for Apollo)")
+	fs.StringVar(&opts.env, "env", "", "Apollo environment to import when the export covers several")
+	dryRun := fs.Bool("dry-run", false, "Report what would change without writing")
+	force := fs.Bool("force", false, "Overwrite configs changed on the server since the export")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return errors.New("-f is required")
+	}
+	if *from == "dotenv" || *from == "properties" {
+		return importEnvFile(ctx, g, *input, opts.namespace, *group, client.ImportFileOptions{Format: *from, SingleKey: *key, DryRun: *dryRun})
+	}
+	read, ok := importSources[*from]
+	if !ok {
+		return fmt.Errorf("unknown --from %q", *from)
+	}
+
+	files, err := read(*input, opts)
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	counts := make(map[importAction]int)
+	pending := make([][]exportConfig, len(files))
+	for i, file := range files {
+		existing, err := c.ListNamespaceConfigs(ctx, file.Namespace)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]*model.Config, len(existing))
+		for _, cfg := range existing {
+			current[cfg.Group+"/"+cfg.Key] = cfg
+		}
+
+		for _, cfg := range file.Configs {
+			action := planImport(current[cfg.Group+"/"+cfg.Key], cfg)
+			counts[action]++
+			if action != actionUnchanged {
+				fmt.Printf("%-9s %s/%s/%s\n", action, file.Namespace, cfg.Group, cfg.Key)
+			}
+			if action == actionCreate || action == actionUpdate || (action == actionConflict && *force) {
+				pending[i] = append(pending[i], cfg)
+			}
+		}
+	}
+	fmt.Printf("%d to create, %d to update, %d unchanged, %d conflicts\n",
+		counts[actionCreate], counts[actionUpdate], counts[actionUnchanged], counts[actionConflict])
+
+	if *dryRun {
+		return nil
+	}
+	if counts[actionConflict] > 0 && !*force {
+		return fmt.Errorf("%d configs changed on the server since the export, rerun with --force to overwrite them", counts[actionConflict])
+	}
+
+	for i, file := range files {
+		if err := ensureNamespace(ctx, c, file.Namespace); err != nil {
+			return err
+		}
+		for _, cfg := range pending[i] {
+			if err := importConfig(ctx, c, file.Namespace, cfg); err != nil {
+				return fmt.Errorf("put %s/%s/%s: %w", file.Namespace, cfg.Group, cfg.Key, err)
+			}
+		}
+		fmt.Printf("Imported %d configs into %s\n", len(pending[i]), file.Namespace)
+	}
+	return nil
+}
+
+// importEnvFile imports a dotenv or properties file through the server,
+// which parses it
+func importEnvFile(ctx context.Context, g *globals, input, namespace, group string, opts client.ImportFileOptions) error {
+	if namespace == "" || group == "" {
+		return fmt.Errorf("--namespace and --group are required to import a %s file", opts.Format)
+	}
+	var data []byte
+	var err error
+	if input == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if !opts.DryRun {
+		if err := ensureNamespace(ctx, c, namespace); err != nil {
+			return err
+		}
+	}
+	results, err := c.ImportFile(ctx, namespace, group, data, opts)
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Action]++
+		if r.Action != string(actionUnchanged) {
+			fmt.Printf("%-9s %s/%s/%s\n", r.Action, namespace, group, r.Key)
+		}
+	}
+	fmt.Printf("%d to create, %d to update, %d unchanged\n", counts[string(actionCreate)], counts[string(actionUpdate)], counts[string(actionUnchanged)])
+	return nil
+}
+
+// readOtterImport reads a file written by export. Versions are dropped
+// when importing into another namespace, where they are not comparable.
+func readOtterImport(path string, opts importOptions) ([]*exportFile, error) {
+	file, err := readExportFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.namespace != "" && opts.namespace != file.Namespace {
+		for i := range file.Configs {
+			file.Configs[i].Version = 0
+		}
+		file.Namespace = opts.namespace
+	}
+	if file.Namespace == "" {
+		return nil, errors.New("file names no namespace, use --namespace")
+	}
+	return []*exportFile{file}, nil
+}
+
+// importConfig writes an exported config, keeping the content type of
+// binary configs
+func importConfig(ctx context.Context, c *client.Client, namespace string, cfg exportConfig) error {
+	if cfg.Type != "binary" {
+		_, err := c.PutConfig(ctx, namespace, cfg.Group, cfg.Key, cfg.Value, cfg.Type)
+		return err
+	}
+	data, err := base64.StdEncoding.DecodeString(cfg.Value)
+	if err != nil {
+		return fmt.Errorf("invalid binary value: %w", err)
+	}
+	_, err = c.PutBinaryConfig(ctx, namespace, cfg.Group, cfg.Key, data, cfg.ContentType)
+	return err
+}
+
+// planImport decides what importing cfg does given the current config on
+// the server, or nil if there is none. Configs without a version, such as
+// those from other config centers, never conflict.
+func planImport(current *model.Config, cfg exportConfig) importAction {
+	switch {
+	case current == nil:
+		return actionCreate
+	case current.Value == cfg.Value && current.Type == cfg.Type && current.ContentType == cfg.ContentType:
+		return actionUnchanged
+	case cfg.Version != 0 && current.Version > cfg.Version:
+		return actionConflict
+	default:
+		return actionUpdate
+	}
+}
+
+// readExportFile reads an export file in either format
+func readExportFile(path string) (*exportFile, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file exportFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// ensureNamespace creates a namespace unless it already exists
+func ensureNamespace(ctx context.Context, c *client.Client, namespace string) error {
+	namespaces, err := c.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		if ns == namespace {
+			return nil
+		}
+	}
+	return c.CreateNamespace(ctx, namespace)
+}
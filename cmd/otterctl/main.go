@@ -0,0 +1,163 @@
+// Command otterctl manages an Otter config center from the command line.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/sotowang/otter/pkg/client"
+)
+
+// command is an otterctl subcommand. run receives the arguments after the
+// subcommand name.
+type command struct {
+	usage string
+	run   func(ctx context.Context, g *globals, args []string) error
+}
+
+var commands = map[string]command{
+	"access":   {"access <namespace>[/<group>]", runAccess},
+	"backup":   {"backup [-o FILE] | backup --save", runBackup},
+	"diff":     {"diff <ns>/<group>/<key> [--from V1] [--to V2] | diff --namespace A --namespace B", runDiff},
+	"export":   {"export --namespace NS [--group G [--format dotenv|properties|yaml|json]] [-o FILE]", runExport},
+	"import":   {"import -f FILE [--from otter|nacos|apollo|dotenv|properties] [--namespace NS] [--group G] [--key K] [--env ENV] [--dry-run] [--force]", runImport},
+	"lock":     {"lock list <namespace> | lock set <namespace>[/<group>/<key>] --reason R | lock remove <namespace>[/<group>/<key>]", runLock},
+	"ns":       {"ns list | ns create|delete <namespace>...", runNamespace},
+	"override": {"override list <ns>/<group>/<key> | override set <ns>/<group>/<key> <value> --client-id ID|--ip IP | override delete <ns>/<group>/<key> --client-id ID|--ip IP", runOverride},
+	"owner":    {"owner list <namespace> | owner set <ns>/<group>[/<key>] --user U|--team T | owner remove <ns>/<group>[/<key>]", runOwner},
+	"restore":  {"restore -f FILE", runRestore},
+	"schedule": {"schedule list <namespace> | schedule set <ns>/<group>/<key> <value> --at TIME [--type T] | schedule cancel <namespace> <id>...", runSchedule},
+	"session":  {"session list [--user U] | session revoke <id>... [--user U] | session revoke --all --user U", runSession},
+	"stale":    {"stale [--days 30] [--namespace NS] [--by access|update]", runStale},
+	"tail":     {"tail --namespace NS [--since 10m] [--values]", runTail},
+	"team":     {"team list | team set <name> <member>... | team delete <name>", runTeam},
+	"tenant":   {"tenant list | tenant create <name> [--admin U --admin-password P|--admin-password-stdin] | tenant delete <name>", runTenant},
+	"token":    {"token create <name> --scope <namespace>/<group>... [--ttl 720h] | token revoke <id>...", runToken},
+	"user":     {"user list | user create|update <username> [--password P|--password-stdin] [--role R] [--status S] | user delete <username>", runUser},
+	"variant":  {"variant list <ns>/<group>/<key> | variant set <ns>/<group>/<key> <name> <value> --selector region=eu[,tier=canary] | variant delete <ns>/<group>/<key> <name>", runVariant},
+	"webhook":  {"webhook list <namespace> | webhook add <namespace> <url> [--secret S] | webhook delete <namespace> <id>... | webhook deliveries <namespace> <id> [--status pending|delivered|dead] | webhook redeliver <namespace> <id> <delivery>...", runWebhook},
+	"watch":    {"watch [--values] <ns>/<group>/<key>|<ns>/<group>|<ns>/*/*...", runWatch},
+}
+
+// globals holds the connection flags shared by every subcommand
+type globals struct {
+	server   string
+	token    string
+	tenant   string
+	username string
+	password string
+	color    string
+}
+
+func main() {
+	g := &globals{}
+	fs := flag.NewFlagSet("otterctl", flag.ExitOnError)
+	fs.StringVar(&g.server, "server", envOr("OTTER_SERVER", "http://localhost:8086"), "Server URL, or a comma-separated list (env OTTER_SERVER)")
+	fs.StringVar(&g.token, "token", os.Getenv("OTTER_TOKEN"), "Access token (env OTTER_TOKEN)")
+	fs.StringVar(&g.tenant, "tenant", os.Getenv("OTTER_TENANT"), "Tenant to log in to, empty for users outside any tenant (env OTTER_TENANT)")
+	fs.StringVar(&g.username, "username", os.Getenv("OTTER_USERNAME"), "Username to log in with (env OTTER_USERNAME)")
+	fs.StringVar(&g.password, "password", os.Getenv("OTTER_PASSWORD"), "Password to log in with (env OTTER_PASSWORD)")
+	fs.StringVar(&g.color, "color", "auto", "Colorize output: auto, always or never")
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+
+	if fs.NArg() == 0 {
+		usage(fs)
+		os.Exit(2)
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "otterctl: unknown command %q\n", fs.Arg(0))
+		usage(fs)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := cmd.run(ctx, g, fs.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "otterctl %s: %v\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: otterctl [flags] <command> [args]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// connect creates a client for the configured server, logging in if
+// credentials were given instead of a token
+func (g *globals) connect(ctx context.Context) (*client.Client, error) {
+	c := client.NewClientWithConfig(client.ClientConfig{
+		Endpoint: g.server,
+		Token:    g.token,
+		Tenant:   g.tenant,
+		Logger:   client.NopLogger{},
+	})
+	if g.token == "" && g.username != "" {
+		if err := c.Login(ctx, g.username, g.password); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// parseFlags parses fs from args, allowing flags to follow positional
+// arguments, and returns the positional arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseKey splits a <ns>/<group>/<key> argument
+func parseKey(arg string) (namespace, group, key string, err error) {
+	parts := strings.SplitN(arg, "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid key %q, expected <namespace>/<group>/<key>", arg)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Nacos export zips hold each config as <group>/<dataId>, with the config
+// types listed in .metadata.yml by Nacos 1.4 and later. Older exports only
+// have .meta.yml with application names, so types are guessed from the
+// dataId extension.
+const (
+	nacosMetadataFile       = ".metadata.yml"
+	nacosLegacyMetadataFile = ".meta.yml"
+	nacosDefaultNamespace   = "public"
+)
+
+// nacosTypes maps Nacos config types to otter types
+var nacosTypes = map[string]string{
+	"text":       "text",
+	"html":       "text",
+	"json":       "json",
+	"xml":        "xml",
+	"yaml":       "yaml",
+	"properties": "properties",
+	"toml":       "toml",
+}
+
+// readNacosExport reads a Nacos config export zip. A zip holds a single
+// Nacos namespace (tenant), which is imported into opts.namespace, or into
+// public like Nacos's default namespace. Nacos groups and dataIds become
+// otter groups and keys.
+func readNacosExport(file string, opts importOptions) ([]*exportFile, error) {
+	if file == "-" {
+		return nil, fmt.Errorf("a Nacos export must be read from a file")
+	}
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	types := make(map[string]string)
+	var entries []*zip.File
+	for _, f := range zr.File {
+		switch {
+		case f.FileInfo().IsDir() || f.Name == nacosLegacyMetadataFile:
+		case f.Name == nacosMetadataFile:
+			if err := readNacosMetadata(f, types); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", f.Name, err)
+			}
+		default:
+			entries = append(entries, f)
+		}
+	}
+
+	namespace := opts.namespace
+	if namespace == "" {
+		namespace = nacosDefaultNamespace
+	}
+	out := &exportFile{Namespace: namespace, Configs: []exportConfig{}}
+	for _, f := range entries {
+		group, dataID, ok := strings.Cut(f.Name, "/")
+		if !ok || group == "" || dataID == "" || strings.Contains(dataID, "/") {
+			return nil, fmt.Errorf("unexpected entry %s, expected <group>/<dataId>", f.Name)
+		}
+		value, err := readZipEntry(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		configType, ok := nacosTypes[types[group+"/"+dataID]]
+		if !ok {
+			configType = typeFromExtension(dataID)
+		}
+		out.Configs = append(out.Configs, exportConfig{Group: group, Key: dataID, Type: configType, Value: value})
+	}
+	sort.Slice(out.Configs, func(i, j int) bool {
+		if out.Configs[i].Group != out.Configs[j].Group {
+			return out.Configs[i].Group < out.Configs[j].Group
+		}
+		return out.Configs[i].Key < out.Configs[j].Key
+	})
+	return []*exportFile{out}, nil
+}
+
+// readNacosMetadata records the type of every config listed in a
+// .metadata.yml entry, keyed by <group>/<dataId>
+func readNacosMetadata(f *zip.File, types map[string]string) error {
+	data, err := readZipEntry(f)
+	if err != nil {
+		return err
+	}
+	var meta struct {
+		Metadata []struct {
+			Group  string `yaml:"group"`
+			DataID string `yaml:"dataId"`
+			Type   string `yaml:"type"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &meta); err != nil {
+		return err
+	}
+	for _, m := range meta.Metadata {
+		types[m.Group+"/"+m.DataID] = strings.ToLower(m.Type)
+	}
+	return nil
+}
+
+func readZipEntry(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return string(data), err
+}
+
+// typeFromExtension guesses the otter type of a config from the extension
+// of its name, falling back to text
+func typeFromExtension(name string) string {
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")); ext {
+	case "yml":
+		return "yaml"
+	case "json", "xml", "yaml", "properties", "toml", "ini", "hcl":
+		return ext
+	}
+	return "text"
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// eventTimeFormat is the timestamp printed before each event
+const eventTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// runWatch streams changes to keys, whole groups or whole namespaces until
+// interrupted. A * key stands for the whole group and */* for the namespace.
+func runWatch(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	values := fs.Bool("values", false, "Print the new value of every change")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return errors.New("expected at least one <ns>/<group>/<key>, <ns>/<group> or <ns>/*/*")
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Callbacks of different watches run concurrently
+	var mu sync.Mutex
+	show := func(event *model.ConfigEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		printEvent(time.Now(), event, *values)
+	}
+
+	var watches []*client.Watch
+	for _, arg := range positional {
+		parts := strings.Split(arg, "/")
+		if len(parts) == 3 && parts[2] == "*" {
+			parts = parts[:2]
+		}
+		var w *client.Watch
+		switch {
+		case len(parts) == 2 && parts[0] != "" && parts[1] == "*":
+			w = c.WatchNamespaceEvents(ctx, parts[0], show)
+		case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+			w = c.WatchGroupEvents(ctx, parts[0], parts[1], show)
+		default:
+			namespace, group, key, err := parseKey(arg)
+			if err != nil {
+				return err
+			}
+			w = c.WatchConfigEvents(ctx, namespace, group, key, show)
+		}
+		watches = append(watches, w)
+		go reportErrors(w)
+	}
+	fmt.Fprintf(os.Stderr, "Watching %s, press Ctrl-C to stop\n", strings.Join(positional, ", "))
+
+	<-ctx.Done()
+	for _, w := range watches {
+		w.Stop()
+	}
+	return nil
+}
+
+// printEvent prints one change received by a watch
+func printEvent(at time.Time, event *model.ConfigEvent, value bool) {
+	cfg := event.Config
+	path := cfg.Namespace + "/" + cfg.Group + "/" + cfg.Key
+	if event.Type == model.EventDelete {
+		fmt.Printf("%s  %-8s  %s\n", at.Format(eventTimeFormat), event.Type, path)
+		return
+	}
+	fmt.Printf("%s  %-8s  %s  version %d\n", at.Format(eventTimeFormat), event.Type, path, cfg.Version)
+	if value {
+		printValue(cfg.Value)
+	}
+}
+
+// printValue prints a config value indented under its event
+func printValue(value string) {
+	for _, line := range strings.Split(strings.TrimRight(value, "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// reportErrors prints the errors of a watch until it stops
+func reportErrors(w *client.Watch) {
+	for {
+		select {
+		case err := <-w.Errors():
+			fmt.Fprintf(os.Stderr, "%s  error: %v\n", time.Now().Format(eventTimeFormat), err)
+		case <-w.Done():
+			return
+		}
+	}
+}
+
+// runTail follows the change feed of a namespace, including who made each
+// change, until interrupted
+func runTail(ctx context.Context, g *globals, args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	namespace := fs.String("namespace", "", "Namespace to follow")
+	since := fs.Duration("since", 0, "Also print changes made this long ago, e.g. 10m")
+	interval := fs.Duration("interval", 2*time.Second, "How often to poll for new changes")
+	values := fs.Bool("values", false, "Print the new value of every change")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *namespace == "" {
+		return errors.New("--namespace is required")
+	}
+
+	c, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	from := time.Now().Add(-*since)
+	// IDs already printed at the from timestamp, which is polled again
+	seen := make(map[int64]bool)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		changes, err := c.NamespaceChanges(ctx, *namespace, from, 0)
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "%s  error: %v\n", time.Now().Format(eventTimeFormat), err)
+		}
+		// The feed is newest first
+		for i := len(changes) - 1; i >= 0; i-- {
+			h := changes[i]
+			if seen[h.ID] {
+				continue
+			}
+			if h.CreatedAt.After(from) {
+				from = h.CreatedAt
+				seen = make(map[int64]bool)
+			}
+			seen[h.ID] = true
+			fmt.Printf("%s  %-8s  %s/%s/%s  version %d  by %s\n",
+				h.CreatedAt.Format(eventTimeFormat), h.OpType, h.Namespace, h.Group, h.Key, h.Version, h.CreatedBy)
+			if *values && h.OpType != "DELETE" {
+				printValue(h.Value)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/sotowang/otter/pkg/client"
@@ -8,10 +9,11 @@ import (
 )
 
 func main() {
+	ctx := context.Background()
 	c := client.NewClient("http://localhost:8086")
 
 	// Login
-	if err := c.Login("admin", "admin"); err != nil {
+	if err := c.Login(ctx, "admin", "admin"); err != nil {
 		panic(err)
 	}
 	fmt.Println("Login successful")
@@ -21,7 +23,7 @@ func main() {
 	key := "sdk_test_key"
 
 	// Watch for changes
-	c.WatchConfig(namespace, group, key, func(cfg *model.Config) {
+	c.WatchConfig(ctx, namespace, group, key, func(cfg *model.Config) {
 		fmt.Printf("Config Updated: %s = %s (Version: %d)\n", cfg.Key, cfg.Value, cfg.Version)
 	})
 
@@ -0,0 +1,285 @@
+// Package backup dumps the whole server state to a portable snapshot,
+// restores it, and keeps scheduled snapshots in a local directory or an S3
+// bucket.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// FormatVersion is the snapshot format written by Dump
+const FormatVersion = 1
+
+// historyPageSize is the number of history entries read per query while dumping
+const historyPageSize = 1000
+
+// Snapshot is a full dump of the server state. Sessions and blacklisted
+// tokens are left out, so users sign in again after a restore.
+type Snapshot struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Tenants own the namespaces and users named "<tenant>/<name>"
+	Tenants    []*model.Tenant `json:"tenants,omitempty"`
+	Namespaces []*Namespace    `json:"namespaces"`
+	Users      []*model.User   `json:"users"`
+	Teams      []*model.Team   `json:"teams,omitempty"`
+}
+
+// Namespace holds the configs, variants, history, quota, locks, owners and
+// scheduled changes of one namespace
+type Namespace struct {
+	Name      string                   `json:"name"`
+	Quota     *model.NamespaceQuota    `json:"quota,omitempty"`
+	Locks     []*model.ConfigLock      `json:"locks,omitempty"`
+	Owners    []*model.ConfigOwner     `json:"owners,omitempty"`
+	Scheduled []*model.ScheduledChange `json:"scheduled,omitempty"`
+	Variants  []*model.ConfigVariant   `json:"variants,omitempty"`
+	Configs   []*model.Config          `json:"configs"`
+	History   []*model.ConfigHistory   `json:"history"`
+}
+
+// RestoreResult counts what a restore wrote
+type RestoreResult struct {
+	Namespaces int `json:"namespaces"`
+	Configs    int `json:"configs"`
+	History    int `json:"history"`
+	Users      int `json:"users"`
+}
+
+// Dump reads the full state of st
+func Dump(ctx context.Context, st store.Store) (*Snapshot, error) {
+	names, err := st.ListNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	sort.Strings(names)
+
+	snap := &Snapshot{FormatVersion: FormatVersion, CreatedAt: time.Now().UTC(), Namespaces: []*Namespace{}}
+	if snap.Tenants, err = st.ListTenants(ctx); err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	for _, name := range names {
+		ns := &Namespace{Name: name}
+		quota, err := st.GetNamespaceQuota(ctx, name)
+		if err != nil && err != store.ErrNotFound {
+			return nil, fmt.Errorf("get quota of %s: %w", name, err)
+		}
+		ns.Quota = quota
+		if ns.Locks, err = st.ListLocks(ctx, name); err != nil {
+			return nil, fmt.Errorf("list locks of %s: %w", name, err)
+		}
+		if ns.Owners, err = st.ListOwners(ctx, name); err != nil {
+			return nil, fmt.Errorf("list owners of %s: %w", name, err)
+		}
+		if ns.Scheduled, err = st.ListScheduledChanges(ctx, name); err != nil {
+			return nil, fmt.Errorf("list scheduled changes of %s: %w", name, err)
+		}
+		if ns.Variants, err = st.ListVariants(ctx, name, "", ""); err != nil {
+			return nil, fmt.Errorf("list variants of %s: %w", name, err)
+		}
+
+		if ns.Configs, err = st.ListNamespaceConfigs(ctx, name); err != nil {
+			return nil, fmt.Errorf("list configs of %s: %w", name, err)
+		}
+		if ns.Configs == nil {
+			ns.Configs = []*model.Config{}
+		}
+		sort.Slice(ns.Configs, func(i, j int) bool {
+			if ns.Configs[i].Group != ns.Configs[j].Group {
+				return ns.Configs[i].Group < ns.Configs[j].Group
+			}
+			return ns.Configs[i].Key < ns.Configs[j].Key
+		})
+
+		ns.History = []*model.ConfigHistory{}
+		for afterID := int64(0); ; {
+			page, err := st.ListNamespaceEvents(ctx, name, afterID, historyPageSize)
+			if err != nil {
+				return nil, fmt.Errorf("list history of %s: %w", name, err)
+			}
+			ns.History = append(ns.History, page...)
+			if len(page) < historyPageSize {
+				break
+			}
+			afterID = page[len(page)-1].ID
+		}
+		snap.Namespaces = append(snap.Namespaces, ns)
+	}
+
+	if snap.Users, err = st.ListUsers(ctx); err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	if snap.Teams, err = st.ListTeams(ctx); err != nil {
+		return nil, fmt.Errorf("list teams: %w", err)
+	}
+	return snap, nil
+}
+
+// Restore writes snap into st. Tenants, namespaces, quotas, locks, owners,
+// variants, users and teams are created or replaced, configs are written as new
+// versions and history and scheduled changes are appended, so restoring into a server that already has data merges the
+// snapshot into it. Into an empty store, every config keeps the version it had when
+// dumped. Configs are updated in place with their new versions.
+func Restore(ctx context.Context, st store.Store, snap *Snapshot) (*RestoreResult, error) {
+	if snap.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format version %d", snap.FormatVersion)
+	}
+
+	existing, err := st.ListNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list namespaces: %w", err)
+	}
+	known := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		known[name] = true
+	}
+
+	result := &RestoreResult{}
+	for _, tenant := range snap.Tenants {
+		_, err := st.GetTenant(ctx, tenant.Name)
+		if err == store.ErrNotFound {
+			err = st.CreateTenant(ctx, tenant)
+		}
+		if err != nil {
+			return result, fmt.Errorf("restore tenant %s: %w", tenant.Name, err)
+		}
+	}
+	for _, ns := range snap.Namespaces {
+		if !known[ns.Name] {
+			if err := st.CreateNamespace(ctx, ns.Name); err != nil {
+				return result, fmt.Errorf("create namespace %s: %w", ns.Name, err)
+			}
+		}
+		if ns.Quota != nil {
+			if err := st.SetNamespaceQuota(ctx, ns.Quota); err != nil {
+				return result, fmt.Errorf("set quota of %s: %w", ns.Name, err)
+			}
+		}
+		for _, lock := range ns.Locks {
+			if err := st.SetLock(ctx, lock); err != nil {
+				return result, fmt.Errorf("set lock of %s: %w", ns.Name, err)
+			}
+		}
+		for _, owner := range ns.Owners {
+			if err := st.SetOwner(ctx, owner); err != nil {
+				return result, fmt.Errorf("set owner of %s: %w", ns.Name, err)
+			}
+		}
+		for _, change := range ns.Scheduled {
+			if err := st.CreateScheduledChange(ctx, change); err != nil {
+				return result, fmt.Errorf("schedule change of %s: %w", ns.Name, err)
+			}
+		}
+		for _, variant := range ns.Variants {
+			if err := st.SetVariant(ctx, variant); err != nil {
+				return result, fmt.Errorf("set variant of %s: %w", ns.Name, err)
+			}
+		}
+		result.Namespaces++
+
+		// Move every key's version counter up to where it was, so restored
+		// configs keep their versions and later writes do not reuse versions
+		// already in the history. This happens before history is written,
+		// since SQL stores start a new key's counter after its history.
+		latest := make(map[[3]string]int64)
+		for _, h := range ns.History {
+			k := [3]string{h.Namespace, h.Group, h.Key}
+			latest[k] = max(latest[k], h.Version)
+		}
+		for _, cfg := range ns.Configs {
+			k := [3]string{cfg.Namespace, cfg.Group, cfg.Key}
+			if err := advanceVersion(ctx, st, k, cfg.Version-1); err != nil {
+				return result, err
+			}
+			delete(latest, k)
+			if err := st.Put(ctx, cfg); err != nil {
+				return result, fmt.Errorf("put %s/%s/%s: %w", cfg.Namespace, cfg.Group, cfg.Key, err)
+			}
+			result.Configs++
+		}
+		for k, version := range latest {
+			if err := advanceVersion(ctx, st, k, version); err != nil {
+				return result, err
+			}
+		}
+		for _, h := range ns.History {
+			if err := st.CreateHistory(ctx, h); err != nil {
+				return result, fmt.Errorf("create history of %s/%s/%s: %w", h.Namespace, h.Group, h.Key, err)
+			}
+			result.History++
+		}
+	}
+
+	for _, user := range snap.Users {
+		_, err := st.GetUser(ctx, user.Username)
+		switch {
+		case err == store.ErrNotFound:
+			err = st.CreateUser(ctx, user)
+		case err == nil:
+			err = st.UpdateUser(ctx, user)
+		}
+		if err != nil {
+			return result, fmt.Errorf("restore user %s: %w", user.Username, err)
+		}
+		result.Users++
+	}
+	for _, team := range snap.Teams {
+		if err := st.SetTeam(ctx, team); err != nil {
+			return result, fmt.Errorf("restore team %s: %w", team.Name, err)
+		}
+	}
+	return result, nil
+}
+
+// advanceVersion allocates versions of a key until at least version has
+// been allocated
+func advanceVersion(ctx context.Context, st store.Store, k [3]string, version int64) error {
+	for allocated := int64(0); allocated < version; {
+		var err error
+		if allocated, err = st.NextVersion(ctx, k[0], k[1], k[2]); err != nil {
+			return fmt.Errorf("allocate version of %s/%s/%s: %w", k[0], k[1], k[2], err)
+		}
+	}
+	return nil
+}
+
+// Encode writes snap as gzipped JSON
+func Encode(snap *Snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(snap); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode reads a snapshot written by Encode, or as plain JSON
+func Decode(data []byte) (*Snapshot, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if len(data) > 1 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return &snap, nil
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestRestore tests that a dump restored into an empty store keeps config
+// versions, history, quotas and users, and that later writes continue the
+// version sequence of deleted keys
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	src := store.NewInMemoryStore()
+	src.CreateNamespace(ctx, "prod")
+	src.SetNamespaceQuota(ctx, &model.NamespaceQuota{Namespace: "prod", MaxConfigs: 10})
+	src.CreateUser(ctx, &model.User{Username: "alice", Password: "hash", Role: "user", Status: "active"})
+	for _, key := range []string{"rate", "rate", "rate", "gone"} {
+		cfg := &model.Config{Namespace: "prod", Group: "billing", Key: key, Value: "v", Type: "text"}
+		src.Put(ctx, cfg)
+		src.CreateHistory(ctx, &model.ConfigHistory{Namespace: "prod", Group: "billing", Key: key, Value: "v", Version: cfg.Version, OpType: "UPDATE"})
+	}
+	src.Delete(ctx, "prod", "billing", "gone")
+	version, _ := src.NextVersion(ctx, "prod", "billing", "gone")
+	src.CreateHistory(ctx, &model.ConfigHistory{Namespace: "prod", Group: "billing", Key: "gone", Version: version, OpType: "DELETE"})
+
+	snap, err := Dump(ctx, src)
+	if err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	data, err := Encode(snap)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if snap, err = Decode(data); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	dst, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "otter.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	result, err := Restore(ctx, dst, snap)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	// The in-memory store always has the public namespace
+	if want := (RestoreResult{Namespaces: 2, Configs: 1, History: 5, Users: 1}); *result != want {
+		t.Errorf("restored %+v, want %+v", *result, want)
+	}
+
+	if cfg, err := dst.Get(ctx, "prod", "billing", "rate"); err != nil || cfg.Version != 3 {
+		t.Errorf("restored config = %+v, %v, want version 3", cfg, err)
+	}
+	if v, err := dst.NextVersion(ctx, "prod", "billing", "gone"); err != nil || v != 3 {
+		t.Errorf("next version of deleted key = %d, %v, want 3", v, err)
+	}
+	if quota, err := dst.GetNamespaceQuota(ctx, "prod"); err != nil || quota.MaxConfigs != 10 {
+		t.Errorf("restored quota = %+v, %v", quota, err)
+	}
+	if user, err := dst.GetUser(ctx, "alice"); err != nil || user.Password != "hash" {
+		t.Errorf("restored user = %+v, %v", user, err)
+	}
+}
+
+// TestPrune tests that pruning keeps the newest backups and leaves other
+// files alone
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	dir := &Dir{Path: t.TempDir()}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		dir.Write(ctx, FileName(start.Add(time.Duration(i)*time.Hour)), []byte("{}"))
+	}
+	dir.Write(ctx, "notes.txt", nil)
+
+	if n, err := Prune(ctx, dir, 2); err != nil || n != 3 {
+		t.Fatalf("Prune = %d, %v, want 3", n, err)
+	}
+	names, _ := dir.List(ctx)
+	if want := fmt.Sprint([]string{"notes.txt", FileName(start.Add(3 * time.Hour)), FileName(start.Add(4 * time.Hour))}); fmt.Sprint(names) != want {
+		t.Errorf("left %v, want %s", names, want)
+	}
+}
@@ -0,0 +1,256 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sotowang/otter/internal/awsv4"
+)
+
+// filePrefix and fileSuffix frame the names of scheduled backups, whose
+// timestamps make them sort oldest first
+const (
+	filePrefix = "otter-backup-"
+	fileSuffix = ".json.gz"
+)
+
+// Target stores backup files
+type Target interface {
+	Write(ctx context.Context, name string, data []byte) error
+	// List returns the names of the stored backup files
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+	// String describes the target for logs
+	String() string
+}
+
+// FileName returns the name of a backup taken at t
+func FileName(t time.Time) string {
+	return filePrefix + t.UTC().Format("20060102T150405Z") + fileSuffix
+}
+
+// Open returns the target at location, either a local directory or an
+// S3 location as s3://bucket/prefix. S3 targets take the region from
+// ?region= or AWS_REGION, may point ?endpoint= at an S3-compatible store,
+// and sign with the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables.
+func Open(location string) (Target, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return &Dir{Path: location}, nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing bucket in %s", location)
+	}
+	region := u.Query().Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("no AWS region configured for %s", location)
+	}
+	return &S3{
+		Bucket:      u.Host,
+		Prefix:      strings.Trim(u.Path, "/"),
+		Region:      region,
+		Endpoint:    u.Query().Get("endpoint"),
+		Credentials: awsv4.EnvCredentials(),
+	}, nil
+}
+
+// Prune deletes all but the newest keep backup files of t, and returns the
+// number deleted. Files not named by FileName are left alone.
+func Prune(ctx context.Context, t Target, keep int) (int, error) {
+	names, err := t.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var backups []string
+	for _, name := range names {
+		if strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+
+	deleted := 0
+	for len(backups)-deleted > keep {
+		if err := t.Delete(ctx, backups[deleted]); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Dir stores backups in a local directory, created if missing
+type Dir struct {
+	Path string
+}
+
+func (d *Dir) String() string { return d.Path }
+
+// Write implements Target. The file is written under a temporary name and
+// renamed, so a crash never leaves a truncated backup behind.
+func (d *Dir) Write(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(d.Path, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.Path, ".tmp-"+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(d.Path, name))
+}
+
+// List implements Target
+func (d *Dir) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Delete implements Target
+func (d *Dir) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(d.Path, name))
+}
+
+// S3 stores backups in an S3 bucket, addressed path-style so S3-compatible
+// stores work too
+type S3 struct {
+	Bucket      string
+	Prefix      string // Key prefix without slashes at either end
+	Region      string
+	Endpoint    string // https://s3.<region>.amazonaws.com if empty
+	Credentials awsv4.Credentials
+	HTTPClient  *http.Client // http.DefaultClient if nil
+}
+
+func (s *S3) String() string { return "s3://" + s.Bucket + "/" + s.Prefix }
+
+// key returns the object key of a backup file
+func (s *S3) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+// Write implements Target
+func (s *S3) Write(ctx context.Context, name string, data []byte) error {
+	_, err := s.do(ctx, http.MethodPut, s.key(name), nil, data)
+	return err
+}
+
+// List implements Target
+func (s *S3) List(ctx context.Context) ([]string, error) {
+	prefix := s.key("")
+	var names []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		body, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var res struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, fmt.Errorf("decode S3 listing: %w", err)
+		}
+		for _, obj := range res.Contents {
+			if name := strings.TrimPrefix(obj.Key, prefix); !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+		if !res.IsTruncated {
+			return names, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// Delete implements Target
+func (s *S3) Delete(ctx context.Context, name string) error {
+	_, err := s.do(ctx, http.MethodDelete, s.key(name), nil, nil)
+	return err
+}
+
+// do sends a signed request for an object, or the bucket if key is empty,
+// and returns the response body
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u = u.JoinPath(s.Bucket, key)
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", awsv4.PayloadHash(body))
+	awsv4.Sign(req, body, s.Credentials, s.Region, "s3", time.Now())
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("S3 %s %s: %s: %s", method, u.Path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
@@ -0,0 +1,146 @@
+// Package demo seeds a store with example namespaces, configs of every type
+// with some history, and a read-only demo user, for evaluating otter and
+// developing the UI without entering everything by hand.
+package demo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+	"github.com/sotowang/otter/internal/util"
+)
+
+const (
+	// Username and Password log in as the read-only demo user
+	Username = "demo"
+	Password = "demo"
+
+	// operator is recorded as the author of seeded configs
+	operator = "system"
+)
+
+// config is one seeded config. Each of its versions is written in turn, so
+// configs with several versions come with a history.
+type config struct {
+	namespace   string
+	group       string
+	key         string
+	configType  string
+	contentType string
+	versions    []string
+}
+
+var configs = []config{
+	{"demo-shop", "web", "application.properties", "properties", "", []string{
+		"server.port=8080\nserver.context-path=/shop\n",
+		"server.port=8080\nserver.context-path=/shop\nsession.timeout=30m\n",
+		"# Storefront\nserver.port=8080\nserver.context-path=/shop\nsession.timeout=45m\n",
+	}},
+	{"demo-shop", "web", "feature-flags.json", "json", "", []string{
+		`{"newCheckout": false, "recommendations": true}`,
+		`{"newCheckout": true, "recommendations": true, "darkMode": false}`,
+	}},
+	{"demo-shop", "web", "banner.md", "markdown", "", []string{
+		"# Autumn sale\n\nEverything **20% off** until Sunday.\n",
+	}},
+	{"demo-shop", "web", "logo.png", "binary", "image/png", []string{
+		// A 1x1 transparent PNG
+		"iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=",
+	}},
+	{"demo-shop", "orders", "application.yaml", "yaml", "", []string{
+		"orders:\n  maxItems: 50\n  retention: 30d\n",
+		"orders:\n  maxItems: 100\n  retention: 90d\nqueue:\n  url: amqp://mq.internal:5672\n",
+	}},
+	{"demo-shop", "orders", "db.toml", "toml", "", []string{
+		"[database]\nhost = \"db.internal\"\nport = 5432\npool = 10\n",
+	}},
+	{"demo-shop", "orders", "DB_HOST", "text", "", []string{"db.internal"}},
+	{"demo-payments", "gateway", "settings.ini", "ini", "", []string{
+		"[gateway]\nprovider = stripe\ntimeout = 5s\n",
+		"[gateway]\nprovider = stripe\ntimeout = 10s\n\n[retry]\nattempts = 3\n",
+	}},
+	{"demo-payments", "gateway", "limits.hcl", "hcl", "", []string{
+		"limits {\n  daily   = 10000\n  per_txn = 2500\n}\n",
+	}},
+	{"demo-payments", "gateway", "currencies.xml", "xml", "", []string{
+		"<currencies>\n  <currency code=\"EUR\"/>\n  <currency code=\"USD\"/>\n</currencies>\n",
+	}},
+}
+
+// Seed writes the demo data into st, unless its first namespace already
+// exists, and reports whether it did. Version history is spread over the
+// days before now.
+func Seed(ctx context.Context, st store.Store) (bool, error) {
+	namespaces, err := st.ListNamespaces(ctx)
+	if err != nil {
+		return false, fmt.Errorf("list namespaces: %w", err)
+	}
+	known := make(map[string]bool, len(namespaces))
+	for _, name := range namespaces {
+		known[name] = true
+	}
+	if known[configs[0].namespace] {
+		return false, nil
+	}
+
+	now := time.Now()
+	for _, c := range configs {
+		if !known[c.namespace] {
+			if err := st.CreateNamespace(ctx, c.namespace); err != nil {
+				return false, fmt.Errorf("create namespace %s: %w", c.namespace, err)
+			}
+			known[c.namespace] = true
+		}
+		for i, value := range c.versions {
+			at := now.Add(-time.Duration(len(c.versions)-1-i) * 24 * time.Hour)
+			cfg := &model.Config{
+				Namespace:   c.namespace,
+				Group:       c.group,
+				Key:         c.key,
+				Value:       value,
+				Type:        c.configType,
+				ContentType: c.contentType,
+				CreatedBy:   operator,
+				UpdatedBy:   operator,
+				CreatedAt:   at,
+				UpdatedAt:   at,
+			}
+			if err := st.Put(ctx, cfg); err != nil {
+				return false, fmt.Errorf("put %s/%s/%s: %w", c.namespace, c.group, c.key, err)
+			}
+			history := &model.ConfigHistory{
+				Namespace: c.namespace,
+				Group:     c.group,
+				Key:       c.key,
+				Value:     value,
+				Type:      c.configType,
+				Version:   cfg.Version,
+				OpType:    "UPDATE",
+				CreatedBy: operator,
+				CreatedAt: at,
+			}
+			if err := st.CreateHistory(ctx, history); err != nil {
+				return false, fmt.Errorf("create history of %s/%s/%s: %w", c.namespace, c.group, c.key, err)
+			}
+		}
+	}
+
+	if _, err := st.GetUser(ctx, Username); err != store.ErrNotFound {
+		return true, err
+	}
+	user := &model.User{
+		Username:  Username,
+		Password:  util.MD5Encrypt(Password),
+		Role:      "viewer",
+		Status:    "active",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := st.CreateUser(ctx, user); err != nil {
+		return false, fmt.Errorf("create demo user: %w", err)
+	}
+	return true, nil
+}
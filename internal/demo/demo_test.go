@@ -0,0 +1,39 @@
+package demo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestSeed tests that seeding writes configs with their history and a
+// viewer, and that seeding again leaves the store alone
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewInMemoryStore()
+
+	seeded, err := Seed(ctx, st)
+	if err != nil || !seeded {
+		t.Fatalf("Seed = %v, %v, want true", seeded, err)
+	}
+	cfg, err := st.Get(ctx, "demo-shop", "web", "application.properties")
+	if err != nil || cfg.Version != 3 {
+		t.Fatalf("got %+v, %v, want version 3", cfg, err)
+	}
+	history, err := st.ListHistory(ctx, "demo-shop", "web", "application.properties")
+	if err != nil || len(history) != 3 {
+		t.Errorf("got %d history entries, %v, want 3", len(history), err)
+	}
+	user, err := st.GetUser(ctx, Username)
+	if err != nil || user.Role != "viewer" {
+		t.Errorf("got %+v, %v, want a viewer", user, err)
+	}
+
+	if seeded, err := Seed(ctx, st); err != nil || seeded {
+		t.Errorf("second Seed = %v, %v, want false", seeded, err)
+	}
+	if cfg, _ := st.Get(ctx, "demo-shop", "web", "application.properties"); cfg.Version != 3 {
+		t.Errorf("second Seed rewrote configs, version %d", cfg.Version)
+	}
+}
@@ -0,0 +1,168 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Client calls methods of a server. It is safe for concurrent use; calls
+// are multiplexed over one HTTP/2 connection.
+type Client struct {
+	addr      string
+	transport *http2.Transport
+	client    *http.Client
+}
+
+// NewClient returns a client of the server at addr, a host:port, connected
+// on the first call
+func NewClient(addr string) *Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http2.Transport{
+		// Cleartext HTTP/2 with prior knowledge, as gRPC speaks it without TLS
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		ReadIdleTimeout: 30 * time.Second,
+	}
+	return &Client{addr: addr, transport: transport, client: &http.Client{Transport: transport}}
+}
+
+// Invoke calls method, a full method name like /package.Service/Method,
+// with req and decodes the response into resp. A call failing with a
+// status other than OK returns an *Error.
+func (c *Client) Invoke(ctx context.Context, method string, req, resp any) error {
+	msg, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	var body bytes.Buffer
+	writeMessage(&body, msg)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+c.addr+method, &body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", ContentType)
+	httpReq.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("Grpc-Timeout", encodeTimeout(time.Until(deadline)))
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return contextError(ctxErr)
+		}
+		return Errorf(Unavailable, "%v", err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return Errorf(Unknown, "unexpected HTTP status %s", httpResp.Status)
+	}
+
+	// A failing call may send its status without a message, in the headers
+	if err := status(httpResp.Header); err != nil {
+		return err
+	}
+	respMsg, readErr := readMessage(httpResp.Body)
+	if readErr == nil {
+		// Read to the end for the trailers
+		_, readErr = io.Copy(io.Discard, httpResp.Body)
+	} else if readErr == io.EOF {
+		readErr = nil
+		respMsg = nil
+	}
+	if readErr != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return contextError(ctxErr)
+		}
+		return Errorf(Internal, "read response: %v", readErr)
+	}
+	if err := status(httpResp.Trailer); err != nil {
+		return err
+	}
+	if httpResp.Trailer.Get("Grpc-Status") == "" {
+		return Errorf(Internal, "response without a status")
+	}
+	if respMsg == nil {
+		return Errorf(Internal, "response without a message")
+	}
+	if err := json.Unmarshal(respMsg, resp); err != nil {
+		return Errorf(Internal, "decode response: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connection to the server
+func (c *Client) Close() error {
+	c.transport.CloseIdleConnections()
+	return nil
+}
+
+// status returns the error of a status other than OK in h
+func status(h http.Header) error {
+	value := h.Get("Grpc-Status")
+	if value == "" {
+		return nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return Errorf(Internal, "malformed status %q", value)
+	}
+	if Code(code) == OK {
+		return nil
+	}
+	return &Error{Code: Code(code), Message: decodeMessage(h.Get("Grpc-Message"))}
+}
+
+func contextError(err error) *Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Errorf(DeadlineExceeded, "%v", err)
+	}
+	return Errorf(Canceled, "%v", err)
+}
+
+// encodeTimeout formats a grpc-timeout value, which has at most eight digits
+func encodeTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "1n"
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"n", time.Nanosecond}, {"u", time.Microsecond}, {"m", time.Millisecond}, {"S", time.Second}, {"M", time.Minute}} {
+		if n := (d + unit.size - 1) / unit.size; n < 1e8 {
+			return strconv.FormatInt(int64(n), 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour)+1, 10) + "H"
+}
+
+// decodeTimeout parses a grpc-timeout value
+func decodeTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{'n': time.Nanosecond, 'u': time.Microsecond, 'm': time.Millisecond, 'S': time.Second, 'M': time.Minute, 'H': time.Hour}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,129 @@
+// Package grpc is a minimal gRPC implementation for unary calls, speaking
+// the gRPC wire protocol over HTTP/2 cleartext with the JSON codec
+// (content type application/grpc+json) instead of protocol buffers. Any
+// gRPC library with a JSON codec registered can talk to it. It supports
+// neither streaming, compression nor TLS.
+package grpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// ContentType is the content type of requests and responses
+const ContentType = "application/grpc+json"
+
+// maxMessage bounds the size of a message read from the peer
+const maxMessage = 64 << 20
+
+// Code is a gRPC status code
+type Code int
+
+// The status codes used here, with the values of the gRPC specification
+const (
+	OK                Code = 0
+	Canceled          Code = 1
+	Unknown           Code = 2
+	InvalidArgument   Code = 3
+	DeadlineExceeded  Code = 4
+	NotFound          Code = 5
+	ResourceExhausted Code = 8
+	Unimplemented     Code = 12
+	Internal          Code = 13
+	Unavailable       Code = 14
+)
+
+var codeNames = map[Code]string{
+	OK:                "OK",
+	Canceled:          "Canceled",
+	Unknown:           "Unknown",
+	InvalidArgument:   "InvalidArgument",
+	DeadlineExceeded:  "DeadlineExceeded",
+	NotFound:          "NotFound",
+	ResourceExhausted: "ResourceExhausted",
+	Unimplemented:     "Unimplemented",
+	Internal:          "Internal",
+	Unavailable:       "Unavailable",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// Error is a call failing with a status other than OK
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Errorf returns an error with code and a formatted message
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("grpc: %s: %s", e.Code, e.Message)
+}
+
+// writeMessage writes msg as an uncompressed length-prefixed message
+func writeMessage(w io.Writer, msg []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// readMessage reads a length-prefixed message, returning io.EOF if r ends
+// before one starts
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated message prefix")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > maxMessage {
+		return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d", n, maxMessage)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("truncated message: %w", err)
+	}
+	return msg, nil
+}
+
+// encodeMessage percent-encodes a grpc-message value as the specification
+// requires, leaving printable ASCII other than % as it is
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func decodeMessage(msg string) string {
+	if decoded, err := url.PathUnescape(msg); err == nil {
+		return decoded
+	}
+	return msg
+}
@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestInvoke tests calls of a method that succeeds, one that fails, an
+// unknown one and one outliving its deadline
+func TestInvoke(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(map[string]Method{
+		"/test.Echo/Upper": func(ctx context.Context, decode func(any) error) (any, error) {
+			var s string
+			if err := decode(&s); err != nil {
+				return nil, err
+			}
+			return strings.ToUpper(s), nil
+		},
+		"/test.Echo/Fail": func(ctx context.Context, decode func(any) error) (any, error) {
+			return nil, errors.New("disk 100% full\nretry later")
+		},
+		"/test.Echo/Sleep": func(ctx context.Context, decode func(any) error) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}))
+	defer srv.Close()
+	c := NewClient(strings.TrimPrefix(srv.URL, "http://"))
+	defer c.Close()
+	ctx := context.Background()
+
+	var out string
+	if err := c.Invoke(ctx, "/test.Echo/Upper", "otter", &out); err != nil || out != "OTTER" {
+		t.Errorf("Upper = %q, %v, want OTTER", out, err)
+	}
+
+	var callErr *Error
+	if err := c.Invoke(ctx, "/test.Echo/Fail", nil, &out); !errors.As(err, &callErr) || callErr.Code != Unknown || callErr.Message != "disk 100% full\nretry later" {
+		t.Errorf("Fail = %v, want Unknown with the message intact", err)
+	}
+	if err := c.Invoke(ctx, "/test.Echo/Missing", nil, &out); !errors.As(err, &callErr) || callErr.Code != Unimplemented {
+		t.Errorf("Missing = %v, want Unimplemented", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := c.Invoke(ctx, "/test.Echo/Sleep", nil, &out); !errors.As(err, &callErr) || callErr.Code != DeadlineExceeded {
+		t.Errorf("Sleep = %v, want DeadlineExceeded", err)
+	}
+}
+
+// TestTimeout tests that grpc-timeout values round trip, rounded up to
+// fit eight digits
+func TestTimeout(t *testing.T) {
+	for _, d := range []time.Duration{time.Millisecond, 1500 * time.Millisecond, 3 * time.Hour, 2000 * time.Hour} {
+		s := encodeTimeout(d)
+		got, ok := decodeTimeout(s)
+		if !ok || got < d || len(s) > 9 {
+			t.Errorf("encodeTimeout(%v) = %q, decoded to %v, %v", d, s, got, ok)
+		}
+	}
+	if _, ok := decodeTimeout("10x"); ok {
+		t.Error("decodeTimeout accepted an unknown unit")
+	}
+}
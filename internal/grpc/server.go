@@ -0,0 +1,96 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Method handles a call, decoding its request with decode. An error that
+// is not an *Error fails the call with Unknown and the error's text.
+type Method func(ctx context.Context, decode func(req any) error) (resp any, err error)
+
+// NewHandler returns a handler serving methods, keyed by full method name
+// like /package.Service/Method, over HTTP/2 cleartext. Serve it with an
+// http.Server; calls of other methods fail with Unimplemented.
+func NewHandler(methods map[string]Method) http.Handler {
+	return h2c.NewHandler(&server{methods: methods}, &http2.Server{})
+}
+
+type server struct {
+	methods map[string]Method
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires POST over HTTP/2", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Content-Type") != ContentType {
+		http.Error(w, "unsupported content type, expected "+ContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	ctx := r.Context()
+	if timeout, ok := decodeTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := s.call(ctx, r)
+	var msg []byte
+	if err == nil {
+		if msg, err = json.Marshal(resp); err != nil {
+			err = Errorf(Internal, "encode response: %v", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	if err == nil {
+		var body bytes.Buffer
+		writeMessage(&body, msg)
+		w.Write(body.Bytes())
+	}
+
+	code, message := OK, ""
+	if err != nil {
+		var callErr *Error
+		switch {
+		case errors.As(err, &callErr):
+			code, message = callErr.Code, callErr.Message
+		case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+			code, message = contextError(err).Code, err.Error()
+		default:
+			code, message = Unknown, err.Error()
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	if message != "" {
+		w.Header().Set("Grpc-Message", encodeMessage(message))
+	}
+}
+
+func (s *server) call(ctx context.Context, r *http.Request) (any, error) {
+	method, ok := s.methods[r.URL.Path]
+	if !ok {
+		return nil, Errorf(Unimplemented, "unknown method %s", r.URL.Path)
+	}
+	msg, err := readMessage(r.Body)
+	if err != nil {
+		return nil, Errorf(InvalidArgument, "read request: %v", err)
+	}
+	return method(ctx, func(req any) error {
+		if err := json.Unmarshal(msg, req); err != nil {
+			return Errorf(InvalidArgument, "decode request: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,473 @@
+// Package kafka is a minimal Kafka producer speaking the wire protocol
+// directly: it looks up partition leaders with Metadata v4 and writes v2
+// record batches with Produce v3, which every broker since Kafka 0.11 and
+// up to 4.x understands. It supports plaintext connections only.
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	apiProduce  = 0
+	apiMetadata = 3
+
+	// produceTimeout is how long the leader waits for the in-sync replicas
+	// to acknowledge a write
+	produceTimeout = 10 * time.Second
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Error is an error code returned by a broker
+type Error int16
+
+func (e Error) Error() string {
+	return "kafka error code " + strconv.Itoa(int(e))
+}
+
+// Message is a record to produce. Records with the same key go to the same
+// partition, so they are consumed in the order they were produced.
+type Message struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// Producer writes messages to a topic, to the partition chosen by hashing
+// their key. It is safe for concurrent use; writes are serialized.
+type Producer struct {
+	brokers  []string
+	topic    string
+	clientID string
+	dialer   net.Dialer
+
+	mu      sync.Mutex
+	leaders []string // address of the leader of each partition
+	conns   map[string]*conn
+	corrID  int32
+}
+
+// conn is a connection to a broker
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewProducer returns a producer writing to topic through the brokers given
+// as host:port, which bootstrap the discovery of the cluster
+func NewProducer(brokers []string, topic, clientID string) *Producer {
+	return &Producer{
+		brokers:  brokers,
+		topic:    topic,
+		clientID: clientID,
+		dialer:   net.Dialer{Timeout: 10 * time.Second},
+		conns:    make(map[string]*conn),
+	}
+}
+
+// Produce writes msgs, acknowledged by every in-sync replica. After a
+// failure the partition leaders are looked up again and the write retried
+// once.
+func (p *Producer) Produce(ctx context.Context, msgs ...Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.produce(ctx, msgs)
+	if err != nil && ctx.Err() == nil {
+		p.reset()
+		err = p.produce(ctx, msgs)
+	}
+	return err
+}
+
+// Close closes the connections to the brokers
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
+
+// reset forgets the partition leaders and closes every connection, so they
+// are looked up and opened afresh. Callers must hold p.mu.
+func (p *Producer) reset() {
+	for addr, c := range p.conns {
+		c.Close()
+		delete(p.conns, addr)
+	}
+	p.leaders = nil
+}
+
+// produce writes msgs grouped by partition leader. Callers must hold p.mu.
+func (p *Producer) produce(ctx context.Context, msgs []Message) error {
+	if p.leaders == nil {
+		if err := p.loadMetadata(ctx); err != nil {
+			return err
+		}
+	}
+	byPartition := make(map[int32][]Message)
+	for _, msg := range msgs {
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		partition := int32(h.Sum32() % uint32(len(p.leaders)))
+		byPartition[partition] = append(byPartition[partition], msg)
+	}
+	for partition, batch := range byPartition {
+		if err := p.produceBatch(ctx, partition, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadMetadata looks up the leader of every partition of the topic from the
+// first bootstrap broker that answers. Callers must hold p.mu.
+func (p *Producer) loadMetadata(ctx context.Context) error {
+	var req encoder
+	req.int32(1)
+	req.string(p.topic)
+	req.int8(0) // allow_auto_topic_creation
+
+	var lastErr error
+	for _, addr := range p.brokers {
+		resp, err := p.roundTrip(ctx, addr, apiMetadata, 4, req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		leaders, err := parseMetadata(resp, p.topic)
+		if err != nil {
+			return err
+		}
+		p.leaders = leaders
+		return nil
+	}
+	return fmt.Errorf("kafka metadata: %w", lastErr)
+}
+
+// parseMetadata reads the partition leaders of topic from a Metadata v4
+// response
+func parseMetadata(resp []byte, topic string) ([]string, error) {
+	d := decoder{buf: resp}
+	d.int32() // throttle_time_ms
+	brokers := make(map[int32]string)
+	// node_id, host, port and rack
+	for n := d.arrayLen(4 + 2 + 4 + 2); n > 0; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster_id
+	d.int32()  // controller_id
+
+	var leaders []string
+	// error_code, name, is_internal and the length of partitions
+	for n := d.arrayLen(2 + 2 + 1 + 4); n > 0; n-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		// error_code, partition_index, leader_id and the lengths of
+		// replica_nodes and isr_nodes
+		partitions := d.arrayLen(2 + 4 + 4 + 4 + 4)
+		if name == topic {
+			if code != 0 {
+				return nil, fmt.Errorf("kafka metadata of topic %s: %w", topic, Error(code))
+			}
+			leaders = make([]string, partitions)
+		}
+		for i := 0; i < partitions; i++ {
+			d.int16() // error_code
+			index := d.int32()
+			leader := d.int32()
+			d.skipInt32Array() // replica_nodes
+			d.skipInt32Array() // isr_nodes
+			if name == topic && index >= 0 && int(index) < partitions {
+				leaders[index] = brokers[leader]
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("kafka metadata: %w", d.err)
+	}
+	if len(leaders) == 0 {
+		return nil, fmt.Errorf("kafka topic %s not found or has no partitions", topic)
+	}
+	for i, leader := range leaders {
+		if leader == "" {
+			return nil, fmt.Errorf("kafka partition %d of topic %s has no leader", i, topic)
+		}
+	}
+	return leaders, nil
+}
+
+// produceBatch writes msgs to a partition. Callers must hold p.mu.
+func (p *Producer) produceBatch(ctx context.Context, partition int32, msgs []Message) error {
+	batch := encodeBatch(msgs)
+
+	var req encoder
+	req.string16(-1) // transactional_id
+	req.int16(-1)    // acks: all in-sync replicas
+	req.int32(int32(produceTimeout / time.Millisecond))
+	req.int32(1)
+	req.string(p.topic)
+	req.int32(1)
+	req.int32(partition)
+	req.bytes(batch)
+
+	resp, err := p.roundTrip(ctx, p.leaders[partition], apiProduce, 3, req)
+	if err != nil {
+		return err
+	}
+	d := decoder{buf: resp}
+	for n := d.int32(); n > 0; n-- {
+		d.string() // name
+		for m := d.int32(); m > 0; m-- {
+			d.int32() // index
+			if code := d.int16(); code != 0 && d.err == nil {
+				return fmt.Errorf("kafka produce to partition %d: %w", partition, Error(code))
+			}
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+		}
+	}
+	return d.err
+}
+
+// encodeBatch encodes msgs as a v2 record batch
+func encodeBatch(msgs []Message) []byte {
+	first := msgs[0].Time
+	if first.IsZero() {
+		first = time.Now()
+	}
+	var records encoder
+	maxTime := first
+	for i, msg := range msgs {
+		t := msg.Time
+		if t.IsZero() {
+			t = first
+		}
+		if t.After(maxTime) {
+			maxTime = t
+		}
+		var r encoder
+		r.int8(0) // attributes
+		r.varint(t.Sub(first).Milliseconds())
+		r.varint(int64(i))
+		r.varbytes(msg.Key)
+		r.varbytes(msg.Value)
+		r.varint(0) // headers
+		records.varint(int64(len(r.buf)))
+		records.raw(r.buf)
+	}
+
+	// The CRC covers everything from the attributes on
+	var body encoder
+	body.int16(0) // attributes: no compression
+	body.int32(int32(len(msgs) - 1))
+	body.int64(first.UnixMilli())
+	body.int64(maxTime.UnixMilli())
+	body.int64(-1) // producer_id
+	body.int16(-1) // producer_epoch
+	body.int32(-1) // base_sequence
+	body.int32(int32(len(msgs)))
+	body.raw(records.buf)
+
+	var batch encoder
+	batch.int64(0) // base_offset, assigned by the broker
+	batch.int32(int32(4 + 1 + 4 + len(body.buf)))
+	batch.int32(-1) // partition_leader_epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(body.buf, castagnoli)))
+	batch.raw(body.buf)
+	return batch.buf
+}
+
+// roundTrip sends a request to the broker at addr and returns the body of
+// its response. Callers must hold p.mu.
+func (p *Producer) roundTrip(ctx context.Context, addr string, apiKey, apiVersion int16, body encoder) ([]byte, error) {
+	c, err := p.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(produceTimeout + 5*time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	p.corrID++
+	var req encoder
+	req.int32(0) // size, filled in below
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(p.corrID)
+	req.string(p.clientID)
+	req.raw(body.buf)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+
+	resp, err := func() ([]byte, error) {
+		if _, err := c.Write(req.buf); err != nil {
+			return nil, err
+		}
+		var header [8]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return nil, err
+		}
+		size := int32(binary.BigEndian.Uint32(header[:4]))
+		if id := int32(binary.BigEndian.Uint32(header[4:])); id != p.corrID {
+			return nil, fmt.Errorf("kafka response to request %d, expected %d", id, p.corrID)
+		}
+		if size < 4 || size > 64<<20 {
+			return nil, fmt.Errorf("kafka response of %d bytes", size)
+		}
+		resp := make([]byte, size-4)
+		_, err := io.ReadFull(c.r, resp)
+		return resp, err
+	}()
+	if err != nil {
+		c.Close()
+		delete(p.conns, addr)
+		return nil, fmt.Errorf("kafka broker %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+// conn returns the connection to the broker at addr, dialing it if needed.
+// Callers must hold p.mu.
+func (p *Producer) conn(ctx context.Context, addr string) (*conn, error) {
+	if c, ok := p.conns[addr]; ok {
+		return c, nil
+	}
+	nc, err := p.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("kafka broker %s: %w", addr, err)
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	p.conns[addr] = c
+	return c, nil
+}
+
+// encoder appends values in the Kafka wire format
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) raw(b []byte)  { e.buf = append(e.buf, b...) }
+func (e *encoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *encoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *encoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *encoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+// varint appends a zigzag-encoded variable-length integer
+func (e *encoder) varint(v int64) { e.buf = binary.AppendVarint(e.buf, v) }
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// string16 appends a null string for a negative length
+func (e *encoder) string16(n int16) { e.int16(n) }
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// varbytes appends b prefixed with its length as a varint, -1 if nil
+func (e *encoder) varbytes(b []byte) {
+	if b == nil {
+		e.varint(-1)
+		return
+	}
+	e.varint(int64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// decoder reads values in the Kafka wire format, recording the first error
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.buf) {
+		d.err = errors.New("truncated response")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a possibly null string, returning "" for null
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// arrayLen reads the length of an array whose elements take at least
+// minSize bytes, recording an error for a length that is negative or more
+// than the rest of the response can hold
+func (d *decoder) arrayLen(minSize int) int {
+	n := d.int32()
+	if d.err == nil && (n < 0 || int(n) > len(d.buf)/minSize) {
+		d.err = fmt.Errorf("invalid array length %d", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) skipInt32Array() {
+	if n := d.int32(); n > 0 {
+		d.next(4 * int(n))
+	}
+}
@@ -0,0 +1,229 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBroker is a single-node cluster answering Metadata and Produce
+// requests, recording the records produced to each partition
+type fakeBroker struct {
+	t          *testing.T
+	ln         net.Listener
+	partitions int32
+
+	mu      sync.Mutex
+	records map[int32][][2]string // key and value per partition
+}
+
+func newFakeBroker(t *testing.T, partitions int32) *fakeBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, ln: ln, partitions: partitions, records: make(map[int32][][2]string)}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeBroker) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := decoder{buf: req}
+		apiKey, _, corrID := d.int16(), d.int16(), d.int32()
+		d.string() // client_id
+
+		var resp encoder
+		resp.int32(0)
+		resp.int32(corrID)
+		switch apiKey {
+		case apiMetadata:
+			b.metadata(&resp)
+		case apiProduce:
+			b.produce(&d, &resp)
+		default:
+			b.t.Errorf("unexpected API key %d", apiKey)
+			return
+		}
+		binary.BigEndian.PutUint32(resp.buf, uint32(len(resp.buf)-4))
+		if _, err := c.Write(resp.buf); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) metadata(resp *encoder) {
+	host, port, _ := net.SplitHostPort(b.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	resp.int32(0) // throttle_time_ms
+	resp.int32(1)
+	resp.int32(7) // node_id
+	resp.string(host)
+	resp.int32(int32(p))
+	resp.string16(-1) // rack
+	resp.string16(-1) // cluster_id
+	resp.int32(7)     // controller_id
+	resp.int32(1)
+	resp.int16(0)
+	resp.string("changes")
+	resp.int8(0)
+	resp.int32(b.partitions)
+	for i := int32(0); i < b.partitions; i++ {
+		resp.int16(0)
+		resp.int32(i)
+		resp.int32(7) // leader_id
+		resp.int32(0) // replica_nodes
+		resp.int32(0) // isr_nodes
+	}
+}
+
+func (b *fakeBroker) produce(d *decoder, resp *encoder) {
+	d.string() // transactional_id
+	if acks := d.int16(); acks != -1 {
+		b.t.Errorf("produced with acks %d, want -1", acks)
+	}
+	d.int32() // timeout_ms
+	d.int32() // topics
+	topic := d.string()
+	d.int32() // partitions
+	partition := d.int32()
+	batch := d.next(int(d.int32()))
+	if d.err != nil {
+		b.t.Errorf("malformed produce request: %v", d.err)
+		return
+	}
+
+	// base_offset, batch_length, partition_leader_epoch, magic and crc
+	// precede the part covered by the CRC
+	if length := int(binary.BigEndian.Uint32(batch[8:12])); length != len(batch)-12 {
+		b.t.Errorf("batch length %d, want %d", length, len(batch)-12)
+	}
+	if batch[16] != 2 {
+		b.t.Errorf("batch magic %d, want 2", batch[16])
+	}
+	if crc := binary.BigEndian.Uint32(batch[17:21]); crc != crc32.Checksum(batch[21:], castagnoli) {
+		b.t.Error("batch CRC mismatch")
+	}
+	body := decoder{buf: batch[21:]}
+	body.next(2 + 4 + 8 + 8 + 8 + 2 + 4)
+	count := body.int32()
+	for i := int32(0); i < count; i++ {
+		length, n := binary.Varint(body.buf)
+		body.next(n)
+		r := body.next(int(length))
+		r = r[1:]               // attributes
+		_, n = binary.Varint(r) // timestamp_delta
+		r = r[n:]
+		_, n = binary.Varint(r) // offset_delta
+		r = r[n:]
+		keyLen, n := binary.Varint(r)
+		key := string(r[n : n+int(keyLen)])
+		r = r[n+int(keyLen):]
+		valueLen, n := binary.Varint(r)
+		value := string(r[n : n+int(valueLen)])
+		b.mu.Lock()
+		b.records[partition] = append(b.records[partition], [2]string{key, value})
+		b.mu.Unlock()
+	}
+
+	resp.int32(1)
+	resp.string(topic)
+	resp.int32(1)
+	resp.int32(partition)
+	resp.int16(0) // error_code
+	resp.int64(0) // base_offset
+	resp.int64(-1)
+	resp.int32(0) // throttle_time_ms
+}
+
+// TestProduce tests that messages reach the broker as valid record batches,
+// those with the same key on the same partition in order
+func TestProduce(t *testing.T) {
+	broker := newFakeBroker(t, 3)
+	p := NewProducer([]string{broker.ln.Addr().String()}, "changes", "test")
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Produce(ctx, Message{Key: []byte("public/app/a"), Value: []byte("1")}, Message{Key: []byte("public/app/b"), Value: []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Produce(ctx, Message{Key: []byte("public/app/a"), Value: []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	total := 0
+	for partition, records := range broker.records {
+		total += len(records)
+		var values []string
+		for _, r := range records {
+			if r[0] == "public/app/a" {
+				values = append(values, r[1])
+			}
+		}
+		if len(values) > 0 && (len(values) != 2 || values[0] != "1" || values[1] != "3") {
+			t.Errorf("partition %d has records %v of one key, want [1 3]", partition, values)
+		}
+	}
+	if total != 3 {
+		t.Errorf("broker received %d records, want 3", total)
+	}
+}
+
+// TestParseMetadataCounts tests that broker, topic and partition counts the
+// response cannot hold are rejected rather than looped over or allocated
+func TestParseMetadataCounts(t *testing.T) {
+	metadata := func(brokers, topics, partitions int32) []byte {
+		var resp encoder
+		resp.int32(0) // throttle_time_ms
+		resp.int32(brokers)
+		resp.string16(-1) // cluster_id
+		resp.int32(7)     // controller_id
+		resp.int32(topics)
+		resp.int16(0)
+		resp.string("changes")
+		resp.int8(0)
+		resp.int32(partitions)
+		return resp.buf
+	}
+	for name, resp := range map[string][]byte{
+		"negative brokers":    metadata(-1, 1, 0),
+		"too many brokers":    metadata(1<<31-1, 1, 0),
+		"negative topics":     metadata(0, -1, 0),
+		"too many topics":     metadata(0, 1<<31-1, 0),
+		"negative partitions": metadata(0, 1, -1),
+		"too many partitions": metadata(0, 1, 1<<30),
+	} {
+		if _, err := parseMetadata(resp, "changes"); err == nil {
+			t.Errorf("parseMetadata with %s succeeded", name)
+		}
+	}
+}
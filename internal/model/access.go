@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ConfigAccess records how often a config has been fetched or watched, and
+// when and by which client it last was
+type ConfigAccess struct {
+	Namespace    string     `json:"namespace"`
+	Group        string     `json:"group"`
+	Key          string     `json:"key"`
+	AccessCount  int64      `json:"access_count"`
+	LastAccessed *time.Time `json:"last_accessed"`            // 从未访问时为null
+	LastClientID string     `json:"last_client_id,omitempty"` // 最后访问的客户端ID
+	LastUsername string     `json:"last_username,omitempty"`
+	LastIP       string     `json:"last_ip,omitempty"`
+}
@@ -4,14 +4,20 @@ import "time"
 
 // Config represents a configuration item.
 type Config struct {
-	Namespace  string    `json:"namespace"`
-	Group      string    `json:"group"`
-	Key        string    `json:"key"`
-	Value      string    `json:"value"`
-	Type       string    `json:"type"` // 配置类型：text, properties, json, yaml, yml, xml, markdown
-	Version    int64     `json:"version"`
-	CreatedBy  string    `json:"created_by"` // 创建人
-	UpdatedBy  string    `json:"updated_by"` // 修改人
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	Namespace   string    `json:"namespace"`
+	Group       string    `json:"group"`
+	Key         string    `json:"key"`
+	Value       string    `json:"value"`
+	Type        string    `json:"type"`                   // 配置类型：text, properties, json, yaml, yml, xml, markdown, toml, ini, hcl, binary
+	ContentType string    `json:"content_type,omitempty"` // binary配置的MIME类型
+	MD5         string    `json:"md5,omitempty"`          // 内容的MD5（binary配置为解码后的字节）
+	SHA256      string    `json:"sha256,omitempty"`       // 内容的SHA-256
+	Size        int64     `json:"size"`                   // 内容字节数
+	Version     int64     `json:"version"`
+	CreatedBy   string    `json:"created_by"` // 创建人
+	UpdatedBy   string    `json:"updated_by"` // 修改人
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Variant     string    `json:"variant,omitempty"`   // 按客户端标签选中的变体名称，不存储
+	Signature   string    `json:"signature,omitempty"` // HMAC签名，配置了签名密钥时在返回时附带，不存储
 }
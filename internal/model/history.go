@@ -4,13 +4,15 @@ import "time"
 
 // ConfigHistory represents a historical version of a configuration.
 type ConfigHistory struct {
-	ID        int64     `json:"id"`
-	Namespace string    `json:"namespace"`
-	Group     string    `json:"group"`
-	Key       string    `json:"key"`
-	Value     string    `json:"value"`
-	Type      string    `json:"type"` // 配置类型：text, properties, json, yaml, yml, xml, markdown
-	Version   int64     `json:"version"`
-	OpType    string    `json:"op_type"` // CREATE, UPDATE, DELETE
-	CreatedAt time.Time `json:"created_at"`
+	ID          int64     `json:"id"`
+	Namespace   string    `json:"namespace"`
+	Group       string    `json:"group"`
+	Key         string    `json:"key"`
+	Value       string    `json:"value"`
+	Type        string    `json:"type"`                   // 配置类型：text, properties, json, yaml, yml, xml, markdown, toml, ini, hcl, binary
+	ContentType string    `json:"content_type,omitempty"` // binary配置的MIME类型
+	Version     int64     `json:"version"`
+	OpType      string    `json:"op_type"`    // CREATE, UPDATE, DELETE
+	CreatedBy   string    `json:"created_by"` // 操作人
+	CreatedAt   time.Time `json:"created_at"`
 }
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// ConfigLock stops writes and deletions of a config key, or of every key in
+// a namespace when Group and Key are empty, until it is removed
+type ConfigLock struct {
+	Namespace string    `json:"namespace"`
+	Group     string    `json:"group,omitempty"` // 为空表示锁定整个命名空间
+	Key       string    `json:"key,omitempty"`
+	Owner     string    `json:"owner"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsNamespaceLock reports whether the lock freezes a whole namespace
+func (l *ConfigLock) IsNamespaceLock() bool {
+	return l.Group == "" && l.Key == ""
+}
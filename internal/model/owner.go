@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Team is a named set of users that can own configs together
+type Team struct {
+	Name      string    `json:"name"`
+	Members   []string  `json:"members"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConfigOwner assigns a config key, or every key of a group when Key is
+// empty, to a user or a team. Only its owners and admins may change an
+// owned config.
+type ConfigOwner struct {
+	Namespace  string    `json:"namespace"`
+	Group      string    `json:"group"`
+	Key        string    `json:"key,omitempty"`  // 为空表示整个分组
+	User       string    `json:"user,omitempty"` // User和Team二选一
+	Team       string    `json:"team,omitempty"`
+	AssignedBy string    `json:"assigned_by"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+// Notification tells a user about a change another user made to a config
+// they own
+type Notification struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Namespace string    `json:"namespace"`
+	Group     string    `json:"group"`
+	Key       string    `json:"key"`
+	Version   int64     `json:"version"`
+	OpType    string    `json:"op_type"` // UPDATE, DELETE or ROLLBACK
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+	Read      bool      `json:"read"`
+}
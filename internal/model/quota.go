@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// NamespaceQuota limits how much configuration a namespace may hold.
+// A zero limit means unlimited.
+type NamespaceQuota struct {
+	Namespace     string    `json:"namespace"`
+	MaxConfigs    int64     `json:"max_configs"`     // 最大配置数量
+	MaxTotalBytes int64     `json:"max_total_bytes"` // 配置值总字节数上限
+	MaxValueBytes int64     `json:"max_value_bytes"` // 单个配置值字节数上限
+	UpdatedBy     string    `json:"updated_by"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Session is one login of a user. The tokens issued at login and on every
+// refresh carry its ID, so revoking the session revokes all of them.
+type Session struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"` // 最近一次刷新令牌的时间
+	ExpiresAt   time.Time `json:"expires_at"`   // 刷新令牌过期时间
+	Current     bool      `json:"current"`      // 是否为发起请求的会话，不存储
+}
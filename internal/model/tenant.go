@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// Tenant is an organization owning its own namespaces and users, isolated
+// from those of every other tenant
+type Tenant struct {
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// ConfigVariant is an alternative value of a config served to clients whose
+// labels match its selector, such as region=eu or tier=canary
+type ConfigVariant struct {
+	Namespace string            `json:"namespace"`
+	Group     string            `json:"group"`
+	Key       string            `json:"key"`
+	Name      string            `json:"name"`
+	Selector  map[string]string `json:"selector"` // 客户端须具备的全部标签
+	Value     string            `json:"value"`
+	UpdatedBy string            `json:"updated_by"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Matches reports whether a client with labels has every label of the
+// variant's selector
+func (v *ConfigVariant) Matches(labels map[string]string) bool {
+	for name, value := range v.Selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,342 @@
+// Package nats is a minimal NATS publisher speaking the client protocol
+// directly. Messages can be published to core NATS, confirmed by a round
+// trip to the server, or to JetStream, confirmed by the stream storing
+// them. It supports plaintext connections with user and password or token
+// authentication only.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeout bounds every exchange with the server unless the context ends
+// sooner
+const timeout = 10 * time.Second
+
+// Options configure a connection
+type Options struct {
+	Servers  []string // host:port of the servers, tried in order
+	User     string
+	Password string
+	Token    string
+	Name     string // client name shown by the server
+}
+
+// Message is a message to publish
+type Message struct {
+	Subject string
+	Data    []byte
+}
+
+// Conn publishes messages, connecting to the first server that answers and
+// reconnecting after a failure. It is safe for concurrent use; publishes
+// are serialized.
+type Conn struct {
+	opts   Options
+	dialer net.Dialer
+
+	mu    sync.Mutex
+	conn  net.Conn
+	r     *bufio.Reader
+	inbox string // prefix of the subjects JetStream acks are sent to
+	seq   int
+}
+
+// NewConn returns a connection to the servers in opts, opened when the
+// first message is published
+func NewConn(opts Options) *Conn {
+	return &Conn{opts: opts, dialer: net.Dialer{Timeout: timeout}}
+}
+
+// Publish sends msgs to core NATS and waits for the server to have
+// processed them, retrying once on a new connection after a failure
+func (c *Conn) Publish(ctx context.Context, msgs ...Message) error {
+	return c.retry(ctx, func() error { return c.publish(msgs, false) })
+}
+
+// PublishJetStream sends msgs to JetStream and waits for the streams
+// capturing their subjects to acknowledge every one, retrying once on a new
+// connection after a failure. A message no stream captures is an error.
+func (c *Conn) PublishJetStream(ctx context.Context, msgs ...Message) error {
+	return c.retry(ctx, func() error { return c.publish(msgs, true) })
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	return nil
+}
+
+// retry runs publish on an open connection, reconnecting and running it
+// again once if it fails
+func (c *Conn) retry(ctx context.Context, publish func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.conn == nil {
+			if err = c.connect(ctx); err != nil {
+				continue
+			}
+		}
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		c.conn.SetDeadline(deadline)
+		if err = publish(); err == nil {
+			return nil
+		}
+		var serverErr *ServerError
+		if errors.As(err, &serverErr) || ctx.Err() != nil {
+			// The server refused the messages; sending them again won't help
+			c.reset()
+			return err
+		}
+		c.reset()
+	}
+	return err
+}
+
+// ServerError is an error reported by the server or a JetStream stream
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return "nats: " + e.Message
+}
+
+// reset closes the connection so the next publish opens a new one. Callers
+// must hold c.mu.
+func (c *Conn) reset() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// connect opens a connection to the first server that answers. Callers must
+// hold c.mu.
+func (c *Conn) connect(ctx context.Context) error {
+	var lastErr error
+	for _, addr := range c.opts.Servers {
+		if lastErr = c.handshake(ctx, addr); lastErr == nil {
+			return nil
+		}
+		c.reset()
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no servers")
+	}
+	return fmt.Errorf("nats connect: %w", lastErr)
+}
+
+// handshake connects to the server at addr, authenticates and subscribes
+// to the inbox of JetStream acks. Callers must hold c.mu.
+func (c *Conn) handshake(ctx context.Context, addr string) error {
+	conn, err := c.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("server %s did not send INFO", addr)
+	}
+
+	connect, err := json.Marshal(map[string]any{
+		"verbose":       false,
+		"pedantic":      false,
+		"lang":          "go",
+		"version":       "otter",
+		"protocol":      1,
+		"name":          c.opts.Name,
+		"user":          c.opts.User,
+		"pass":          c.opts.Password,
+		"auth_token":    c.opts.Token,
+		"headers":       true,
+		"no_responders": true,
+	})
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	c.inbox = "_INBOX." + hex.EncodeToString(b)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", connect, c.inbox); err != nil {
+		return err
+	}
+	return c.awaitPong(nil)
+}
+
+// publish writes msgs followed by a PING and reads the server's replies up
+// to the PONG, collecting JetStream acks if jetstream is set. Callers must
+// hold c.mu.
+func (c *Conn) publish(msgs []Message, jetstream bool) error {
+	var buf []byte
+	acks := make(map[string]bool)
+	for _, msg := range msgs {
+		if jetstream {
+			c.seq++
+			reply := c.inbox + "." + strconv.Itoa(c.seq)
+			acks[reply] = false
+			buf = fmt.Appendf(buf, "PUB %s %s %d\r\n", msg.Subject, reply, len(msg.Data))
+		} else {
+			buf = fmt.Appendf(buf, "PUB %s %d\r\n", msg.Subject, len(msg.Data))
+		}
+		buf = append(buf, msg.Data...)
+		buf = append(buf, "\r\n"...)
+	}
+	buf = append(buf, "PING\r\n"...)
+	if _, err := c.conn.Write(buf); err != nil {
+		return err
+	}
+	if err := c.awaitPong(acks); err != nil {
+		return err
+	}
+
+	// Acks may still arrive after the PONG, as JetStream replies once the
+	// stream has stored the message
+	for pending(acks) {
+		if err := c.readReply(acks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pending reports whether any ack is still awaited
+func pending(acks map[string]bool) bool {
+	for _, acked := range acks {
+		if !acked {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitPong reads replies until the server's PONG. Callers must hold c.mu.
+func (c *Conn) awaitPong(acks map[string]bool) error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "PONG" {
+			return nil
+		}
+		if err := c.handleLine(line, acks); err != nil {
+			return err
+		}
+	}
+}
+
+// readReply reads and handles one reply. Callers must hold c.mu.
+func (c *Conn) readReply(acks map[string]bool) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line == "PONG" {
+		return nil
+	}
+	return c.handleLine(line, acks)
+}
+
+// handleLine handles a line from the server other than PONG: errors,
+// pings and JetStream acks. Callers must hold c.mu.
+func (c *Conn) handleLine(line string, acks map[string]bool) error {
+	switch {
+	case line == "PING":
+		_, err := io.WriteString(c.conn, "PONG\r\n")
+		return err
+	case line == "+OK" || strings.HasPrefix(line, "INFO "):
+		return nil
+	case strings.HasPrefix(line, "-ERR"):
+		return &ServerError{Message: strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'")}
+	case strings.HasPrefix(line, "MSG ") || strings.HasPrefix(line, "HMSG "):
+		return c.handleAck(line, acks)
+	}
+	return fmt.Errorf("unexpected server line %q", line)
+}
+
+// handleAck reads the JetStream ack announced by a MSG or HMSG line
+func (c *Conn) handleAck(line string, acks map[string]bool) error {
+	// MSG <subject> <sid> <size> or HMSG <subject> <sid> <header size> <size>
+	fields := strings.Fields(line)
+	headers := fields[0] == "HMSG"
+	if len(fields) < 4 || (headers && len(fields) < 5) {
+		return fmt.Errorf("malformed server line %q", line)
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return fmt.Errorf("malformed server line %q", line)
+	}
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return err
+	}
+	payload = payload[:size]
+
+	reply := fields[1]
+	if _, ok := acks[reply]; !ok {
+		// An ack to a message of an earlier, failed publish
+		return nil
+	}
+	acks[reply] = true
+
+	if headers {
+		headerSize, err := strconv.Atoi(fields[len(fields)-2])
+		if err != nil || headerSize > size {
+			return fmt.Errorf("malformed server line %q", line)
+		}
+		// NATS/1.0 503 means no stream captures the subject
+		status := strings.Fields(strings.SplitN(string(payload[:headerSize]), "\r\n", 2)[0])
+		if len(status) > 1 && status[1] == "503" {
+			return &ServerError{Message: "no JetStream stream captures the subject"}
+		}
+		payload = payload[headerSize:]
+	}
+	var ack struct {
+		Error *struct {
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return fmt.Errorf("malformed JetStream ack: %w", err)
+	}
+	if ack.Error != nil {
+		return &ServerError{Message: ack.Error.Description}
+	}
+	return nil
+}
+
+// readLine reads a protocol line without its CRLF. Callers must hold c.mu.
+func (c *Conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,159 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a NATS server recording the messages published to it and
+// acknowledging those with a reply subject like JetStream does, except on
+// subjects starting with "unstored.", which no stream captures
+type fakeServer struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu       sync.Mutex
+	connect  string
+	messages []Message
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{t: t, ln: ln}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	io.WriteString(c, "INFO {\"server_id\":\"fake\",\"headers\":true}\r\n")
+	sid, seq := "", 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connect = strings.TrimSpace(strings.TrimPrefix(line, "CONNECT"))
+			s.mu.Unlock()
+		case "SUB":
+			sid = fields[len(fields)-1]
+		case "PING":
+			io.WriteString(c, "PONG\r\n")
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, Message{Subject: fields[1], Data: data[:size]})
+			s.mu.Unlock()
+			if len(fields) != 4 {
+				continue
+			}
+			reply := fields[2]
+			if strings.HasPrefix(fields[1], "unstored.") {
+				header := "NATS/1.0 503\r\n\r\n"
+				fmt.Fprintf(c, "HMSG %s %s %d %d\r\n%s\r\n", reply, sid, len(header), len(header), header)
+				continue
+			}
+			seq++
+			ack := fmt.Sprintf(`{"stream":"CHANGES","seq":%d}`, seq)
+			fmt.Fprintf(c, "MSG %s %s %d\r\n%s\r\n", reply, sid, len(ack), ack)
+		default:
+			s.t.Errorf("unexpected client line %q", line)
+			return
+		}
+	}
+}
+
+// TestPublish tests that messages reach the server on their subjects, with
+// the credentials given in the options
+func TestPublish(t *testing.T) {
+	server := newFakeServer(t)
+	c := NewConn(Options{Servers: []string{server.ln.Addr().String()}, Token: "secret"})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, Message{Subject: "changes.public", Data: []byte("1")}, Message{Subject: "changes.team", Data: []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish(ctx, Message{Subject: "changes.public", Data: []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !strings.Contains(server.connect, `"auth_token":"secret"`) {
+		t.Errorf("CONNECT %s lacks the token", server.connect)
+	}
+	want := []string{"changes.public 1", "changes.team 2", "changes.public 3"}
+	if len(server.messages) != len(want) {
+		t.Fatalf("server received %d messages, want %d", len(server.messages), len(want))
+	}
+	for i, msg := range server.messages {
+		if got := msg.Subject + " " + string(msg.Data); got != want[i] {
+			t.Errorf("message %d is %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+// TestPublishJetStream tests that publishing waits for JetStream acks and
+// fails when no stream captures a subject
+func TestPublishJetStream(t *testing.T) {
+	server := newFakeServer(t)
+	c := NewConn(Options{Servers: []string{"127.0.0.1:1", server.ln.Addr().String()}})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.PublishJetStream(ctx, Message{Subject: "changes.public", Data: []byte("1")}, Message{Subject: "changes.team", Data: []byte("2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.PublishJetStream(ctx, Message{Subject: "unstored.public", Data: []byte("3")})
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) {
+		t.Fatalf("publish to a subject without a stream returned %v, want a ServerError", err)
+	}
+
+	// The connection recovers for the next publish
+	if err := c.PublishJetStream(ctx, Message{Subject: "changes.public", Data: []byte("4")}); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 4 {
+		t.Errorf("server received %d messages, want 4", len(server.messages))
+	}
+}
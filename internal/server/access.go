@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// DefaultAccessFlushInterval is how often the accesses counted in memory
+// are added to the store
+const DefaultAccessFlushInterval = 10 * time.Second
+
+// accessKey identifies a config across tenants
+type accessKey struct {
+	tenant, namespace, group, key string
+}
+
+// AccessTracker counts the fetches and watches of each config in memory
+// until they are flushed to the store, so reading a config costs no write
+type AccessTracker struct {
+	mu      sync.Mutex
+	pending map[accessKey]*model.ConfigAccess
+}
+
+func NewAccessTracker() *AccessTracker {
+	return &AccessTracker{pending: make(map[accessKey]*model.ConfigAccess)}
+}
+
+// Record counts an access to a config by a client
+func (t *AccessTracker) Record(namespace, group, key string, info SubscriberInfo) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ak := accessKey{info.Tenant, namespace, group, key}
+	access, ok := t.pending[ak]
+	if !ok {
+		access = &model.ConfigAccess{Namespace: namespace, Group: group, Key: key}
+		t.pending[ak] = access
+	}
+	access.AccessCount++
+	access.LastAccessed = &now
+	access.LastClientID = info.ClientID
+	access.LastUsername = info.Username
+	access.LastIP = info.IP
+}
+
+// Merge adds the accesses to a config not yet flushed to its stored record
+func (t *AccessTracker) Merge(tenant string, access *model.ConfigAccess) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending, ok := t.pending[accessKey{tenant, access.Namespace, access.Group, access.Key}]
+	if !ok {
+		return
+	}
+	count := access.AccessCount + pending.AccessCount
+	*access = *pending
+	access.AccessCount = count
+}
+
+// take returns the accesses counted since the last flush and starts
+// counting afresh
+func (t *AccessTracker) take() map[accessKey]*model.ConfigAccess {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := t.pending
+	t.pending = make(map[accessKey]*model.ConfigAccess)
+	return pending
+}
+
+// restore puts back accesses that could not be flushed, to be tried again
+func (t *AccessTracker) restore(ak accessKey, access *model.ConfigAccess) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if newer, ok := t.pending[ak]; ok {
+		newer.AccessCount += access.AccessCount
+		return
+	}
+	t.pending[ak] = access
+}
+
+// StartAccessTracking flushes the accesses counted in memory to the store
+// every interval until ctx is cancelled. Accesses counted after the last
+// flush are lost unless FlushAccess is called on shutdown.
+func (s *Server) StartAccessTracking(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultAccessFlushInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.FlushAccess(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// FlushAccess adds the accesses counted in memory to the store. Those that
+// cannot be written are kept for the next flush.
+func (s *Server) FlushAccess(ctx context.Context) {
+	for ak, access := range s.access.take() {
+		if err := s.store.RecordAccess(store.WithTenant(ctx, ak.tenant), access); err != nil {
+			s.logger.Warn("Failed to record config access",
+				zap.String("tenant", ak.tenant),
+				zap.String("namespace", ak.namespace),
+				zap.String("group", ak.group),
+				zap.String("key", ak.key),
+				zap.Error(err))
+			s.access.restore(ak, access)
+		}
+	}
+}
+
+// configAccessHandler reports how often a config has been fetched or
+// watched, and when and by which client it last was
+func (s *Server) configAccessHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	namespace, group, key := c.Param("namespace"), c.Param("group"), c.Param("key")
+	if _, err := s.store.Get(ctx, namespace, group, key); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+			return
+		}
+		s.logger.Error("Failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := s.store.ListAccess(ctx, namespace, group, key)
+	if err != nil {
+		s.logger.Error("Failed to list config access", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	access := &model.ConfigAccess{Namespace: namespace, Group: group, Key: key}
+	if len(records) > 0 {
+		access = records[0]
+	}
+	s.access.Merge(store.TenantFrom(ctx), access)
+	c.JSON(http.StatusOK, access)
+}
+
+// namespaceAccessHandler reports the accesses of every config in a
+// namespace, or in the group given by the group query parameter, ordered by
+// group and key. Configs never accessed are included with a count of zero.
+func (s *Server) namespaceAccessHandler(c *gin.Context) {
+	records, err := s.configAccess(c.Request.Context(), c.Param("namespace"), c.Query("group"))
+	if err != nil {
+		s.logger.Error("Failed to list config access", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+// configAccess returns the access record of every config in a namespace,
+// or in a group if group is not empty, ordered by group and key
+func (s *Server) configAccess(ctx context.Context, namespace, group string) ([]*model.ConfigAccess, error) {
+	configs, err := s.sortedConfigs(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.storedAccess(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*model.ConfigAccess, len(configs))
+	for i, cfg := range configs {
+		records[i] = s.accessOf(ctx, stored, cfg)
+	}
+	return records, nil
+}
+
+// sortedConfigs returns the configs in a namespace, or in a group if group is
+// not empty, ordered by group and key
+func (s *Server) sortedConfigs(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	var configs []*model.Config
+	var err error
+	if group == "" {
+		configs, err = s.store.ListNamespaceConfigs(ctx, namespace)
+	} else {
+		configs, err = s.store.List(ctx, namespace, group)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Group != configs[j].Group {
+			return configs[i].Group < configs[j].Group
+		}
+		return configs[i].Key < configs[j].Key
+	})
+	return configs, nil
+}
+
+// storedAccess returns the stored access records of a namespace, or of a
+// group if group is not empty, by key
+func (s *Server) storedAccess(ctx context.Context, namespace, group string) (map[watchKey]*model.ConfigAccess, error) {
+	records, err := s.store.ListAccess(ctx, namespace, group, "")
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[watchKey]*model.ConfigAccess, len(records))
+	for _, access := range records {
+		stored[watchKey{access.Namespace, access.Group, access.Key}] = access
+	}
+	return stored, nil
+}
+
+// accessOf returns the access record of cfg, found in stored, with the
+// accesses not yet flushed added
+func (s *Server) accessOf(ctx context.Context, stored map[watchKey]*model.ConfigAccess, cfg *model.Config) *model.ConfigAccess {
+	access, ok := stored[watchKey{cfg.Namespace, cfg.Group, cfg.Key}]
+	if !ok {
+		access = &model.ConfigAccess{Namespace: cfg.Namespace, Group: cfg.Group, Key: cfg.Key}
+	}
+	s.access.Merge(store.TenantFrom(ctx), access)
+	return access
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestConfigAccess tests that accesses are counted per tenant, reported
+// before and after they are flushed to the store, and that configs never
+// accessed are reported with a count of zero
+func TestConfigAccess(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore()), access: NewAccessTracker()}
+	ctx := context.Background()
+	acme := store.WithTenant(ctx, "acme")
+	for _, key := range []string{"timeout", "retries"} {
+		if err := s.store.Put(ctx, &model.Config{Namespace: "public", Group: "app", Key: key, Value: "1", Type: "text"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.access.Record("public", "app", "timeout", SubscriberInfo{ClientID: "pod-1", IP: "10.0.0.1"})
+	s.access.Record("public", "app", "timeout", SubscriberInfo{Tenant: "acme", ClientID: "pod-9"})
+	s.FlushAccess(ctx)
+	s.access.Record("public", "app", "timeout", SubscriberInfo{ClientID: "pod-2", IP: "10.0.0.2"})
+
+	records, err := s.configAccess(ctx, "public", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d access records, want one per config", len(records))
+	}
+	if retries := records[0]; retries.Key != "retries" || retries.AccessCount != 0 || retries.LastAccessed != nil {
+		t.Errorf("config never accessed reported as %+v", retries)
+	}
+	if timeout := records[1]; timeout.AccessCount != 2 || timeout.LastClientID != "pod-2" || timeout.LastIP != "10.0.0.2" {
+		t.Errorf("flushed and pending accesses reported as %+v, want 2 with pod-2 last", timeout)
+	}
+
+	s.FlushAccess(ctx)
+	stored, err := s.store.ListAccess(acme, "public", "app", "timeout")
+	if err != nil || len(stored) != 1 || stored[0].AccessCount != 1 || stored[0].LastClientID != "pod-9" {
+		t.Errorf("tenant access %v, %v, want one by pod-9", stored, err)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// healthzTimeout bounds the store check of a health probe
+const healthzTimeout = 2 * time.Second
+
+// SetAdminListeners serves /healthz, /metrics, /debug/pprof, /api/v1/stats
+// and the /api/v1/admin API on listeners instead of the public API, once
+// Serve is called, so they can be firewalled independently. It must be
+// called before Serve.
+func (s *Server) SetAdminListeners(listeners ...net.Listener) {
+	s.adminListeners = listeners
+}
+
+// publicAdminMiddleware hides the monitoring and admin routes of the public
+// API while they are served on admin listeners
+func (s *Server) publicAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(s.adminListeners) > 0 {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not found"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// setupAdminEngine configures the routes of the admin listeners. Admin API
+// requests are authenticated like on the public API; monitoring and
+// profiling routes are left open to whoever can reach the listener.
+func (s *Server) setupAdminEngine() {
+	s.adminEngine = gin.New()
+	s.adminEngine.Use(gin.Recovery())
+	s.adminEngine.Use(s.ipFilterMiddleware())
+	s.adminEngine.Use(s.maintenanceMiddleware())
+	s.adminEngine.Use(s.bodyLogMiddleware())
+
+	s.adminEngine.GET("/healthz", s.healthzHandler)
+	s.adminEngine.GET("/metrics", s.metricsHandler)
+	s.adminEngine.GET("/debug/pprof/*profile", pprofHandler)
+
+	api := s.adminEngine.Group("/api/v1")
+	api.GET("/stats", s.getStatsHandler)
+	s.adminRoutes(api.Group("/admin", s.ginAuthMiddleware(), s.scopeMiddleware(), s.viewerMiddleware()))
+}
+
+// healthzHandler reports whether the server can reach its store and is not
+// draining, for load balancers and orchestrators
+func (s *Server) healthzHandler(c *gin.Context) {
+	if s.watcher.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), healthzTimeout)
+	defer cancel()
+	if _, err := s.allTenants.ListNamespaces(ctx); err != nil {
+		s.logger.Warn("Health check failed", zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// pprofHandler serves the runtime profiles of net/http/pprof
+func pprofHandler(c *gin.Context) {
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
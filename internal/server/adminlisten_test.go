@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestAdminEngineTrustedProxies tests that the IP rules of the admin
+// listeners ignore an X-Forwarded-For header sent by a client that is not a
+// trusted proxy, and honour one sent by a trusted proxy
+func TestAdminEngineTrustedProxies(t *testing.T) {
+	s := NewServer(store.NewInMemoryStore(), "secret", zap.NewNop())
+	rule, err := ParseIPRule("10.0.0.0/8", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetIPFilter(NewIPFilter([]IPRule{rule}))
+	if err := s.SetTrustedProxies([]string{"192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"203.0.113.7:4000", http.StatusForbidden},
+		{"192.168.1.1:4000", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set("X-Forwarded-For", "10.1.2.3")
+		w := httptest.NewRecorder()
+		s.adminEngine.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("admin request from %s forwarded for 10.1.2.3 = %d, want %d", tc.remoteAddr, w.Code, tc.want)
+		}
+	}
+}
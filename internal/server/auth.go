@@ -12,11 +12,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
 )
 
 type Claims struct {
 	Username  string `json:"username"`
-	TokenType string `json:"token_type"` // "access" or "refresh"
+	TokenType string `json:"token_type"` // "access", "refresh" or "scoped"
+	SessionID string `json:"sid,omitempty"`
+	// Tenant is the tenant of the user or scoped token, empty for platform users
+	Tenant string `json:"tenant,omitempty"`
+	// Scopes limit a "scoped" token to reading and watching these groups
+	Scopes TokenScopes `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -54,8 +60,14 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	session, err := s.startSession(r.Context(), user.Username, r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Generate access token and refresh token
-	accessToken, refreshToken, expiresIn, err := s.generateTokens(user.Username)
+	accessToken, refreshToken, expiresIn, err := s.generateTokens("", user.Username, session.ID)
 	if err != nil {
 		http.Error(w, "failed to generate tokens", http.StatusInternalServerError)
 		return
@@ -68,39 +80,46 @@ func (s *Server) login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// generateTokens generates both access token and refresh token
+// generateTokens generates both access token and refresh token for a session
+// of a user of tenant, empty for platform users
 
-func (s *Server) generateTokens(username string) (accessToken, refreshToken string, expiresIn int64, err error) {
+func (s *Server) generateTokens(tenant, username, sessionID string) (accessToken, refreshToken string, expiresIn int64, err error) {
 	// Access token: expires in 2 hours
 	accessExpiration := time.Now().Add(2 * time.Hour)
 	accessClaims := &Claims{
 		Username:  username,
 		TokenType: "access",
+		SessionID: sessionID,
+		Tenant:    tenant,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
 			ExpiresAt: jwt.NewNumericDate(accessExpiration),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   username,
 		},
 	}
 
-	accessToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).SignedString([]byte(s.jwtSecret))
+	accessToken, err = s.signToken(accessClaims)
 	if err != nil {
 		return "", "", 0, err
 	}
 
 	// Refresh token: expires in 7 days
-	refreshExpiration := time.Now().Add(7 * 24 * time.Hour)
+	refreshExpiration := time.Now().Add(refreshTokenTTL)
 	refreshClaims := &Claims{
 		Username:  username,
 		TokenType: "refresh",
+		SessionID: sessionID,
+		Tenant:    tenant,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
 			ExpiresAt: jwt.NewNumericDate(refreshExpiration),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   username,
 		},
 	}
 
-	refreshToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(s.jwtSecret))
+	refreshToken, err = s.signToken(refreshClaims)
 	if err != nil {
 		return "", "", 0, err
 	}
@@ -123,11 +142,7 @@ func (s *Server) refreshTokenHandler(c *gin.Context) {
 
 	// Parse and validate refresh token
 	refreshClaims := &Claims{}
-	token, err := jwt.ParseWithClaims(req.RefreshToken, refreshClaims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
-
-	if err != nil || !token.Valid {
+	if err := s.parseToken(req.RefreshToken, refreshClaims); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
 		return
 	}
@@ -138,8 +153,20 @@ func (s *Server) refreshTokenHandler(c *gin.Context) {
 		return
 	}
 
+	// Refreshing keeps the session alive unless it has been revoked
+	sessionID, err := s.refreshSession(c, refreshClaims)
+	if err != nil {
+		if err == errSessionRevoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+		s.logger.Error("Failed to refresh session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
+		return
+	}
+
 	// Generate new access token and refresh token
-	accessToken, newRefreshToken, expiresIn, err := s.generateTokens(refreshClaims.Username)
+	accessToken, newRefreshToken, expiresIn, err := s.generateTokens(refreshClaims.Tenant, refreshClaims.Username, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
 		return
@@ -179,16 +206,17 @@ func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// Check token rate limit
-		// Allow 100 requests per minute per token
-		allowed, err := s.store.CheckTokenRateLimit(r.Context(), tokenStr, 100, 1*time.Minute)
-		if err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
-		}
-		if !allowed {
-			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
-			return
+		// Check token rate limit, per minute as set by the settings file
+		if limit := s.tokenRateLimit(); limit > 0 {
+			allowed, err := s.store.CheckTokenRateLimit(r.Context(), tokenStr, limit, 1*time.Minute)
+			if err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
 		}
 
 		// Increment token usage
@@ -199,24 +227,64 @@ func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(s.jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
+		if err := s.parseToken(tokenStr, claims); err != nil {
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		// Check if it's an access token
-		if claims.TokenType != "access" {
+		// Check if it's an access token or a scoped token
+		if claims.TokenType != "access" && claims.TokenType != "scoped" {
 			http.Error(w, "invalid token type", http.StatusUnauthorized)
 			return
 		}
 
-		// Add username to context if needed
+		// Check if the scoped token has been revoked
+		if claims.TokenType == "scoped" {
+			revoked, err := s.store.IsTokenBlacklisted(r.Context(), scopedTokenBlacklistKey(claims.ID))
+			if err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+			if claims.Scopes == nil {
+				claims.Scopes = TokenScopes{}
+			}
+		}
+
+		// Check if the token's session has been revoked
+		if claims.SessionID != "" {
+			revoked, err := s.store.IsTokenBlacklisted(r.Context(), sessionBlacklistKey(claims.SessionID))
+			if err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		// Add username and session to context if needed
 		ctx := context.WithValue(r.Context(), "username", claims.Username)
+		ctx = context.WithValue(ctx, "session_id", claims.SessionID)
+		// Limit every store query of the request to the token's tenant
+		if claims.Tenant != "" {
+			if _, err := s.store.GetTenant(r.Context(), claims.Tenant); err != nil {
+				if err == store.ErrNotFound {
+					http.Error(w, "tenant not found", http.StatusUnauthorized)
+				} else {
+					http.Error(w, "internal server error", http.StatusInternalServerError)
+				}
+				return
+			}
+			ctx = store.WithTenant(ctx, claims.Tenant)
+		}
+		if claims.TokenType == "scoped" {
+			ctx = context.WithValue(ctx, "scopes", claims.Scopes)
+		}
 		next(w, r.WithContext(ctx))
 	}
 }
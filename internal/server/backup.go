@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/backup"
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// SetBackupSchedule writes a backup to target every interval until ctx is
+// cancelled, keeping the newest keep backups there. A zero interval only
+// configures target for POST /admin/backup?save=true.
+func (s *Server) SetBackupSchedule(ctx context.Context, target backup.Target, interval time.Duration, keep int) {
+	s.backupTarget = target
+	s.backupKeep = keep
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.saveBackup(ctx); err != nil {
+					s.logger.Error("Scheduled backup failed", zap.String("target", target.String()), zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// saveBackup writes a backup to the configured target, prunes old backups
+// and returns the name of the new one
+func (s *Server) saveBackup(ctx context.Context) (string, error) {
+	snap, err := backup.Dump(ctx, s.allTenants)
+	if err != nil {
+		return "", err
+	}
+	data, err := backup.Encode(snap)
+	if err != nil {
+		return "", err
+	}
+	name := backup.FileName(snap.CreatedAt)
+	if err := s.backupTarget.Write(ctx, name, data); err != nil {
+		return "", err
+	}
+
+	pruned := 0
+	if s.backupKeep > 0 {
+		if pruned, err = backup.Prune(ctx, s.backupTarget, s.backupKeep); err != nil {
+			s.logger.Error("Failed to prune old backups", zap.String("target", s.backupTarget.String()), zap.Error(err))
+		}
+	}
+	s.logger.Info("Saved backup",
+		zap.String("target", s.backupTarget.String()),
+		zap.String("name", name),
+		zap.Int("bytes", len(data)),
+		zap.Int("pruned", pruned))
+	return name, nil
+}
+
+// backupHandler returns a full dump of the server, or with ?save=true
+// writes it to the configured backup target instead
+func (s *Server) backupHandler(c *gin.Context) {
+	if save, _ := strconv.ParseBool(c.Query("save")); save {
+		if s.backupTarget == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No backup target configured"})
+			return
+		}
+		name, err := s.saveBackup(c.Request.Context())
+		if err != nil {
+			s.logger.Error("Failed to save backup", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"name": name, "target": s.backupTarget.String()})
+		return
+	}
+
+	snap, err := backup.Dump(c.Request.Context(), s.allTenants)
+	if err != nil {
+		s.logger.Error("Failed to dump server state", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := backup.Encode(snap)
+	if err != nil {
+		s.logger.Error("Failed to encode backup", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Backup downloaded", zap.String("operator", c.GetString("username")), zap.Int("bytes", len(data)))
+	c.Header("Content-Disposition", `attachment; filename="`+backup.FileName(snap.CreatedAt)+`"`)
+	c.Data(http.StatusOK, "application/gzip", data)
+}
+
+// restoreHandler restores a backup uploaded as the request body, gzipped
+// or as plain JSON, and notifies watchers of every restored config
+func (s *Server) restoreHandler(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	snap, err := backup.Decode(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := backup.Restore(c.Request.Context(), s.allTenants, snap)
+	if err != nil {
+		s.logger.Error("Failed to restore backup", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "restored": result})
+		return
+	}
+	for _, ns := range snap.Namespaces {
+		// Configs of tenants are restored under their stored namespaces
+		tenant, namespace := store.SplitTenant(ns.Name)
+		ctx := store.WithTenant(c.Request.Context(), tenant)
+		for _, cfg := range ns.Configs {
+			event := *cfg
+			event.Namespace = namespace
+			s.notify(ctx, model.EventPut, &event)
+			s.publishChange(ctx, namespace, event.Group, event.Key, event.Version, "RESTORE", c.GetString("username"))
+		}
+	}
+
+	summary, _ := json.Marshal(result)
+	s.logger.Info("Restored backup",
+		zap.String("operator", c.GetString("username")),
+		zap.Time("created_at", snap.CreatedAt),
+		zap.ByteString("restored", summary))
+	c.JSON(http.StatusOK, result)
+}
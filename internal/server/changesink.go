@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/kafka"
+	"github.com/sotowang/otter/internal/nats"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// changeSinkQueue is how many change events may wait for a sink before
+// further ones are dropped
+const changeSinkQueue = 1024
+
+// ChangeEvent describes a config change for consumers outside otter, such
+// as data pipelines and audit systems
+type ChangeEvent struct {
+	Tenant    string    `json:"tenant,omitempty"`
+	Namespace string    `json:"namespace"`
+	Group     string    `json:"group"`
+	Key       string    `json:"key"`
+	Version   int64     `json:"version"`
+	Op        string    `json:"op"` // UPDATE, DELETE, ROLLBACK, VARIANT or RESTORE
+	Operator  string    `json:"operator"`
+	Time      time.Time `json:"time"`
+}
+
+// ChangeSink publishes change events to an external system
+type ChangeSink interface {
+	// Publish writes events, in the order they happened
+	Publish(ctx context.Context, events []*ChangeEvent) error
+	Close() error
+}
+
+// NewChangeSink creates the sink for a -change-sink URL, either
+// kafka://host:port[,host:port...]/topic or
+// nats://[user:password@|token@]host:port[,host:port...]/subject-prefix[?jetstream=true]
+func NewChangeSink(sinkURL string) (ChangeSink, error) {
+	u, err := url.Parse(sinkURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "kafka":
+		topic := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || topic == "" {
+			return nil, fmt.Errorf("expected kafka://host:port[,host:port...]/topic, got %q", sinkURL)
+		}
+		return NewKafkaSink(strings.Split(u.Host, ","), topic), nil
+	case "nats":
+		prefix := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || prefix == "" {
+			return nil, fmt.Errorf("expected nats://host:port[,host:port...]/subject-prefix, got %q", sinkURL)
+		}
+		opts := nats.Options{Servers: strings.Split(u.Host, ","), Name: "otter"}
+		if password, ok := u.User.Password(); ok {
+			opts.User, opts.Password = u.User.Username(), password
+		} else if u.User != nil {
+			opts.Token = u.User.Username()
+		}
+		return NewNATSSink(opts, prefix, u.Query().Get("jetstream") == "true"), nil
+	}
+	return nil, fmt.Errorf("unsupported change sink %q, expected kafka:// or nats://", sinkURL)
+}
+
+// KafkaSink is a ChangeSink producing every event as a JSON message to a
+// Kafka topic. Messages are keyed by tenant, namespace, group and key, so
+// the changes of a config stay in order on one partition.
+type KafkaSink struct {
+	producer *kafka.Producer
+}
+
+// NewKafkaSink produces to topic through the bootstrap brokers given as
+// host:port
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{producer: kafka.NewProducer(brokers, topic, "otter")}
+}
+
+func (k *KafkaSink) Publish(ctx context.Context, events []*ChangeEvent) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		key := event.Namespace + "/" + event.Group + "/" + event.Key
+		if event.Tenant != "" {
+			key = event.Tenant + "/" + key
+		}
+		msgs[i] = kafka.Message{Key: []byte(key), Value: value, Time: event.Time}
+	}
+	return k.producer.Produce(ctx, msgs...)
+}
+
+func (k *KafkaSink) Close() error {
+	return k.producer.Close()
+}
+
+// NATSSink is a ChangeSink publishing every event as a JSON message to a
+// NATS subject per namespace: the prefix followed by the namespace, or by
+// the tenant and the namespace for configs of a tenant. With JetStream,
+// each message must be stored by a stream capturing its subject.
+type NATSSink struct {
+	conn      *nats.Conn
+	prefix    string
+	jetstream bool
+}
+
+// NewNATSSink publishes under the subject prefix through the servers in
+// opts, to JetStream if jetstream is set
+func NewNATSSink(opts nats.Options, prefix string, jetstream bool) *NATSSink {
+	return &NATSSink{conn: nats.NewConn(opts), prefix: prefix, jetstream: jetstream}
+}
+
+// Subject returns the subject an event is published to
+func (n *NATSSink) Subject(event *ChangeEvent) string {
+	if event.Tenant != "" {
+		return n.prefix + "." + event.Tenant + "." + event.Namespace
+	}
+	return n.prefix + "." + event.Namespace
+}
+
+func (n *NATSSink) Publish(ctx context.Context, events []*ChangeEvent) error {
+	msgs := make([]nats.Message, len(events))
+	for i, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		msgs[i] = nats.Message{Subject: n.Subject(event), Data: data}
+	}
+	if n.jetstream {
+		return n.conn.PublishJetStream(ctx, msgs...)
+	}
+	return n.conn.Publish(ctx, msgs...)
+}
+
+func (n *NATSSink) Close() error {
+	return n.conn.Close()
+}
+
+// AddChangeSink publishes every config change to sink from now on, until
+// ctx is cancelled. Events are queued so writes never wait for the sink;
+// if it falls too far behind, further events are dropped and logged.
+func (s *Server) AddChangeSink(ctx context.Context, sink ChangeSink) {
+	queue := make(chan *ChangeEvent, changeSinkQueue)
+	s.changeSinks = append(s.changeSinks, queue)
+	go func() {
+		for {
+			var events []*ChangeEvent
+			select {
+			case event := <-queue:
+				events = append(events, event)
+			case <-ctx.Done():
+				return
+			}
+			// Publish whatever else is waiting along with it
+			for more := true; more && len(events) < changeSinkQueue; {
+				select {
+				case event := <-queue:
+					events = append(events, event)
+				default:
+					more = false
+				}
+			}
+			if err := sink.Publish(ctx, events); err != nil {
+				s.logger.Error("Failed to publish changes to change sink", zap.Int("count", len(events)), zap.Error(err))
+			}
+		}
+	}()
+}
+
+// publishChange queues a config change for every change sink and webhook
+func (s *Server) publishChange(ctx context.Context, namespace, group, key string, version int64, op, operator string) {
+	event := &ChangeEvent{
+		Tenant:    store.TenantFrom(ctx),
+		Namespace: namespace,
+		Group:     group,
+		Key:       key,
+		Version:   version,
+		Op:        op,
+		Operator:  operator,
+		Time:      time.Now(),
+	}
+	s.queueWebhookDeliveries(ctx, event)
+	for _, queue := range s.changeSinks {
+		select {
+		case queue <- event:
+		default:
+			s.logger.Warn("Change sink queue full, dropping change event",
+				zap.String("namespace", namespace),
+				zap.String("group", group),
+				zap.String("key", key),
+				zap.Int64("version", version))
+		}
+	}
+}
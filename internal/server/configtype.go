@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+
+	"github.com/hashicorp/hcl"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/ini.v1"
+)
+
+// validConfigTypes are the accepted config types; an empty type means text
+var validConfigTypes = map[string]bool{
+	"": true, "text": true, "properties": true, "json": true, "yaml": true, "yml": true, "xml": true,
+	"toml": true, "ini": true, "hcl": true, "binary": true,
+}
+
+// maxBinaryValueBytes limits the decoded size of a binary config value
+const maxBinaryValueBytes = 1 << 20
+
+// defaultBinaryContentType is stored for binary configs saved without a content type
+const defaultBinaryContentType = "application/octet-stream"
+
+// validateConfigValue checks that value parses as configType. Only TOML,
+// INI, HCL and binary values are checked; other types are accepted as they
+// are. Binary values must be standard base64 of at most maxBinaryValueBytes.
+func validateConfigValue(configType, value string) error {
+	var err error
+	switch configType {
+	case "toml":
+		var v map[string]any
+		err = toml.Unmarshal([]byte(value), &v)
+	case "ini":
+		_, err = ini.Load([]byte(value))
+	case "hcl":
+		_, err = hcl.Parse(value)
+	case "binary":
+		var data []byte
+		if data, err = base64.StdEncoding.DecodeString(value); err == nil && len(data) > maxBinaryValueBytes {
+			return fmt.Errorf("Binary value of %d bytes exceeds the limit of %d bytes", len(data), maxBinaryValueBytes)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid %s value: %v", configType, err)
+	}
+	return nil
+}
+
+// configContentType validates the content type sent with a config. Only
+// binary configs carry one, defaulting to application/octet-stream.
+func configContentType(configType, contentType string) (string, error) {
+	if configType != "binary" {
+		if contentType != "" {
+			return "", fmt.Errorf("A content type is only allowed for binary configs")
+		}
+		return "", nil
+	}
+	if contentType == "" {
+		return defaultBinaryContentType, nil
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return "", fmt.Errorf("Invalid content type: %v", err)
+	}
+	return contentType, nil
+}
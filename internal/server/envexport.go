@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// exportFormats maps each group export format to its content type and file
+// extension
+var exportFormats = map[string]struct{ contentType, ext string }{
+	"dotenv":     {"text/plain; charset=utf-8", ".env"},
+	"properties": {"text/x-java-properties; charset=utf-8", ".properties"},
+	"yaml":       {"application/yaml; charset=utf-8", ".yaml"},
+	"json":       {"application/json; charset=utf-8", ".json"},
+}
+
+// exportGroupHandler flattens every config of a group into one file of
+// ?format= dotenv, properties, yaml or json, with one entry per key sorted
+// by key. Secret references are resolved unless ?resolve=false, and binary
+// values are written base64-encoded as stored. Dotenv keys have characters
+// not allowed in variable names replaced by underscores.
+func (s *Server) exportGroupHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+
+	format := c.Query("format")
+	spec, ok := exportFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be dotenv, properties, yaml or json"})
+		return
+	}
+
+	configs, err := s.store.List(c.Request.Context(), namespace, group)
+	if err != nil {
+		s.logger.Error("Failed to list configs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Key < configs[j].Key })
+
+	entries := make([]envEntry, 0, len(configs))
+	for _, config := range configs {
+		resolved, err := s.resolveSecret(c, config)
+		if err != nil {
+			s.respondSecretError(c, err)
+			return
+		}
+		entries = append(entries, envEntry{Key: config.Key, Value: resolved.Value})
+	}
+
+	var body []byte
+	switch format {
+	case "dotenv":
+		text, err := formatDotenv(entries)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		body = []byte(text)
+	case "properties":
+		body = []byte(formatProperties(entries))
+	case "yaml":
+		body, err = formatYAMLMap(entries)
+	case "json":
+		body, err = formatJSONMap(entries)
+	}
+	if err != nil {
+		s.logger.Error("Failed to encode export", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, group, spec.ext))
+	c.Data(http.StatusOK, spec.contentType, body)
+}
+
+// formatDotenv writes entries as a dotenv file that parseDotenv reads back
+// unchanged. It fails if two keys map to the same variable name.
+func formatDotenv(entries []envEntry) (string, error) {
+	var b strings.Builder
+	seen := make(map[string]string)
+	for _, e := range entries {
+		name := dotenvName(e.Key)
+		if other, ok := seen[name]; ok {
+			return "", fmt.Errorf("Keys %s and %s both export as %s", other, e.Key, name)
+		}
+		seen[name] = e.Key
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quoteDotenv(e.Value))
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// dotenvName turns a config key into a variable name by replacing
+// characters other than letters, digits and underscores, and prefixing an
+// underscore to a leading digit
+func dotenvName(key string) string {
+	name := []byte(key)
+	for i, ch := range name {
+		if !(ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		return "_" + string(name)
+	}
+	return string(name)
+}
+
+// quoteDotenv leaves plain values bare and double-quotes the rest,
+// escaping what unquoteDotenv decodes
+func quoteDotenv(value string) string {
+	plain := !strings.ContainsFunc(value, func(r rune) bool {
+		return !(r == '_' || r == '-' || r == '.' || r == '/' || r == ':' || r == '@' || r == ',' || r == '+' ||
+			r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	if plain {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// formatYAMLMap writes entries as a YAML mapping of keys to string values,
+// keeping their order
+func formatYAMLMap(entries []envEntry) ([]byte, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, e := range entries {
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.Key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.Value})
+	}
+	return yaml.Marshal(mapping)
+}
+
+// formatJSONMap writes entries as an indented JSON object of keys to string
+// values
+func formatJSONMap(entries []envEntry) ([]byte, error) {
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		values[e.Key] = e.Value
+	}
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,409 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// maxImportFileBytes limits the size of an uploaded dotenv or properties file
+const maxImportFileBytes = 1 << 20
+
+// envEntry is one key and value of a dotenv or properties file
+type envEntry struct {
+	Key   string
+	Value string
+}
+
+// ImportResult reports what importing one entry did
+type ImportResult struct {
+	Key    string `json:"key"`
+	Action string `json:"action"` // create, update or unchanged
+}
+
+// importFileHandler imports a dotenv or properties file uploaded as the
+// request body into a group, either as one text config per entry
+// (?mode=keys, the default) or as a single properties config named by
+// ?key= (?mode=single). ?format= is dotenv or properties. With
+// ?dryRun=true it only reports what would change.
+func (s *Server) importFileHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+
+	format := c.Query("format")
+	if format != "dotenv" && format != "properties" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be dotenv or properties"})
+		return
+	}
+	mode := c.DefaultQuery("mode", "keys")
+	if mode != "keys" && mode != "single" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be keys or single"})
+		return
+	}
+	if mode == "single" && c.Query("key") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Key is required in single mode"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportFileBytes+1))
+	if err != nil || len(body) > maxImportFileBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Request body must be a file of at most %d bytes", maxImportFileBytes)})
+		return
+	}
+	text := string(body)
+
+	var entries []envEntry
+	if format == "dotenv" {
+		entries, err = parseDotenv(text)
+	} else {
+		entries, err = parseProperties(text)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get username from context
+	username := "system"
+	if user, ok := c.Request.Context().Value("username").(string); ok {
+		username = user
+	}
+
+	var configs []*model.Config
+	newConfig := func(key, value, configType string) *model.Config {
+		return &model.Config{
+			Namespace: namespace,
+			Group:     group,
+			Key:       key,
+			Value:     value,
+			Type:      configType,
+			CreatedBy: username,
+			UpdatedBy: username,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+	if mode == "single" {
+		// Properties files are stored as uploaded, keeping their comments
+		if format == "dotenv" {
+			text = formatProperties(entries)
+		}
+		configs = append(configs, newConfig(c.Query("key"), text, "properties"))
+	} else {
+		for _, e := range entries {
+			configs = append(configs, newConfig(e.Key, e.Value, "text"))
+		}
+	}
+
+	results := make([]ImportResult, 0, len(configs))
+	var changed []*model.Config
+	for _, config := range configs {
+		action := "create"
+		existing, err := s.store.Get(c.Request.Context(), namespace, group, config.Key)
+		switch {
+		case err == nil && existing.Value == config.Value && existing.Type == config.Type:
+			action = "unchanged"
+		case err == nil:
+			action = "update"
+		case err != store.ErrNotFound:
+			s.logger.Error("Failed to get config", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		results = append(results, ImportResult{Key: config.Key, Action: action})
+		if action != "unchanged" {
+			changed = append(changed, config)
+		}
+	}
+
+	if dryRun, _ := strconv.ParseBool(c.Query("dryRun")); dryRun {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	// Refuse the whole import if any changed key is locked or owned by others
+	for _, config := range changed {
+		if err := s.checkLock(c.Request.Context(), namespace, group, config.Key); err != nil {
+			s.respondLockError(c, err)
+			return
+		}
+		if err := s.checkOwner(c.Request.Context(), namespace, group, config.Key, username); err != nil {
+			s.respondOwnerError(c, err)
+			return
+		}
+	}
+
+	for _, config := range changed {
+		if err := s.checkQuota(c.Request.Context(), config); err != nil {
+			s.respondQuotaError(c, err)
+			return
+		}
+		if err := s.store.Put(c.Request.Context(), config); err != nil {
+			s.logger.Error("Failed to put config", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		history := &model.ConfigHistory{
+			Namespace: namespace,
+			Group:     group,
+			Key:       config.Key,
+			Value:     config.Value,
+			Type:      config.Type,
+			Version:   config.Version,
+			OpType:    "UPDATE",
+			CreatedBy: username,
+			CreatedAt: time.Now(),
+		}
+		_ = s.store.CreateHistory(c.Request.Context(), history)
+
+		s.notify(c.Request.Context(), model.EventPut, config)
+		s.notifyOwners(c.Request.Context(), namespace, group, config.Key, config.Version, "UPDATE", username)
+		s.publishChange(c.Request.Context(), namespace, group, config.Key, config.Version, "UPDATE", username)
+	}
+
+	s.logger.Info("Imported file",
+		zap.String("namespace", namespace),
+		zap.String("group", group),
+		zap.String("format", format),
+		zap.String("mode", mode),
+		zap.Int("changed", len(changed)),
+		zap.String("operator", username))
+	c.JSON(http.StatusOK, results)
+}
+
+// parseDotenv parses a dotenv file. Lines may start with export, values
+// may be single-quoted (taken literally) or double-quoted (with \n, \t, \"
+// and \\ escapes), and unquoted values end at a " #" comment. A key
+// repeated later in the file overrides the earlier value.
+func parseDotenv(text string) ([]envEntry, error) {
+	var entries []envEntry
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportFileBytes)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsFunc(key, unicode.IsSpace) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quote", n)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := unquoteDotenv(value[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value = unquoted
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		if i, ok := index[key]; ok {
+			entries[i].Value = value
+			continue
+		}
+		index[key] = len(entries)
+		entries = append(entries, envEntry{Key: key, Value: value})
+	}
+	return entries, scanner.Err()
+}
+
+// unquoteDotenv reads a double-quoted dotenv value up to its closing quote
+func unquoteDotenv(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", fmt.Errorf("unterminated quote")
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return "", fmt.Errorf("unterminated quote")
+}
+
+// parseProperties parses a Java properties file: keys are separated from
+// values by =, : or whitespace, lines ending in a backslash continue on the
+// next line, and \t, \n, \r, \f and \uXXXX escapes are decoded. A key
+// repeated later in the file overrides the earlier value.
+func parseProperties(text string) ([]envEntry, error) {
+	var entries []envEntry
+	index := make(map[string]int)
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for n := 0; n < len(lines); n++ {
+		line := strings.TrimLeft(lines[n], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// Join continuation lines, dropping the leading whitespace of each
+		for endsWithEscape(line) && n+1 < len(lines) {
+			n++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[n], " \t\f")
+		}
+
+		// The key ends at the first unescaped separator, which is whitespace
+		// optionally followed by one = or :
+		sep := len(line)
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+				sep = i
+				break
+			}
+		}
+		rawValue := strings.TrimLeft(line[sep:], " \t\f")
+		if rawValue != "" && (rawValue[0] == '=' || rawValue[0] == ':') {
+			rawValue = strings.TrimLeft(rawValue[1:], " \t\f")
+		}
+
+		key, err := unescapeProperty(line[:sep])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		value, err := unescapeProperty(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+
+		if i, ok := index[key]; ok {
+			entries[i].Value = value
+			continue
+		}
+		index[key] = len(entries)
+		entries = append(entries, envEntry{Key: key, Value: value})
+	}
+	return entries, nil
+}
+
+// endsWithEscape reports whether line ends in an odd number of backslashes
+func endsWithEscape(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// unescapeProperty decodes the escapes of a properties key or value
+func unescapeProperty(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape")
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape")
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// formatProperties writes entries as a properties file, escaping what
+// parseProperties would otherwise read differently
+func formatProperties(entries []envEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(escapeProperty(e.Key, true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(e.Value, false))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// escapeProperty escapes a properties key or value. Keys also escape
+// separators and comment markers, and values their leading space.
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case (r == '=' || r == ':' || r == '#' || r == '!') && key:
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestParseDotenv tests quoting, comments, export prefixes and overrides
+// in dotenv files
+func TestParseDotenv(t *testing.T) {
+	entries, err := parseDotenv(`# database
+export DB_HOST=localhost # local only
+DB_PASSWORD='p#ss "word"'
+GREETING="hello\nworld"
+EMPTY=
+DB_HOST=db.internal
+`)
+	if err != nil {
+		t.Fatalf("parseDotenv failed: %v", err)
+	}
+	want := `[{DB_HOST db.internal} {DB_PASSWORD p#ss "word"} {GREETING hello
+world} {EMPTY }]`
+	if got := fmt.Sprint(entries); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err := parseDotenv(`KEY="unterminated`); err == nil {
+		t.Error("parseDotenv accepted an unterminated quote")
+	}
+}
+
+// TestParseProperties tests separators, continuations and escapes in
+// properties files, and that formatted entries parse back unchanged
+func TestParseProperties(t *testing.T) {
+	entries, err := parseProperties(`! comment
+server.port = 8080
+db.url:jdbc:mysql://db/app
+greeting hello \
+    world
+path\ with\ spaces=été
+`)
+	if err != nil {
+		t.Fatalf("parseProperties failed: %v", err)
+	}
+	want := `[{server.port 8080} {db.url jdbc:mysql://db/app} {greeting hello world} {path with spaces été}]`
+	if got := fmt.Sprint(entries); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	tricky := []envEntry{{"a=b: c", " leading space"}, {"#not-a-comment", "multi\nline\\"}}
+	parsed, err := parseProperties(formatProperties(tricky))
+	if err != nil || fmt.Sprint(parsed) != fmt.Sprint(tricky) {
+		t.Errorf("round trip gave %q, %v, want %q", parsed, err, tricky)
+	}
+}
+
+// TestFormatDotenv tests that exported dotenv files parse back unchanged
+// and that keys which collide as variable names are rejected
+func TestFormatDotenv(t *testing.T) {
+	entries := []envEntry{{"DB_HOST", "db.internal"}, {"greeting", "hello \"world\"\n# not a comment"}, {"EMPTY", ""}}
+	text, err := formatDotenv(entries)
+	if err != nil {
+		t.Fatalf("formatDotenv failed: %v", err)
+	}
+	parsed, err := parseDotenv(text)
+	if err != nil || fmt.Sprint(parsed) != fmt.Sprint(entries) {
+		t.Errorf("round trip gave %q, %v, want %q", parsed, err, entries)
+	}
+
+	if name := dotenvName("1st.db-url"); name != "_1st_db_url" {
+		t.Errorf("dotenvName = %s, want _1st_db_url", name)
+	}
+	if _, err := formatDotenv([]envEntry{{"db.url", "a"}, {"db_url", "b"}}); err == nil {
+		t.Error("formatDotenv accepted keys that collide")
+	}
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+	"github.com/sotowang/otter/internal/util"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+const diffContextLines = 3
+
+// historyDiffHandler renders a unified diff between a history entry and its predecessor
+func (s *Server) historyDiffHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+	key := c.Param("key")
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history id"})
+		return
+	}
+
+	histories, err := s.store.ListHistory(c.Request.Context(), namespace, group, key)
+	if err != nil {
+		s.logger.Error("Failed to list history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// History IDs increase with every write, so the predecessor is the closest lower ID
+	sort.Slice(histories, func(i, j int) bool { return histories[i].ID < histories[j].ID })
+
+	var target, previous *model.ConfigHistory
+	for _, h := range histories {
+		if h.ID == id {
+			target = h
+			break
+		}
+		previous = h
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History entry not found"})
+		return
+	}
+
+	fromName, fromValue := "/dev/null", ""
+	var previousVersion int64
+	if previous != nil {
+		fromName = fmt.Sprintf("%s (version %d)", key, previous.Version)
+		fromValue = previous.Value
+		previousVersion = previous.Version
+	}
+	toName := fmt.Sprintf("%s (version %d)", key, target.Version)
+	diff := util.UnifiedDiff(fromName, toName, fromValue, target.Value, diffContextLines)
+
+	if wantsRawValue(c) {
+		c.Data(http.StatusOK, "text/x-diff; charset=utf-8", []byte(diff))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":               target.ID,
+		"version":          target.Version,
+		"op_type":          target.OpType,
+		"previous_version": previousVersion,
+		"diff":             diff,
+	})
+}
+
+// rollbackPreview responds with the result a rollback to target would have
+func (s *Server) rollbackPreview(c *gin.Context, target *model.ConfigHistory) {
+	current, err := s.store.Get(c.Request.Context(), target.Namespace, target.Group, target.Key)
+	if err != nil && err != store.ErrNotFound {
+		s.logger.Error("Failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	fromName, fromValue := "/dev/null", ""
+	var currentVersion int64
+	var currentType string
+	if current != nil {
+		fromName = fmt.Sprintf("%s (current, version %d)", target.Key, current.Version)
+		fromValue = current.Value
+		currentVersion = current.Version
+		currentType = current.Type
+	}
+	toName := fmt.Sprintf("%s (version %d)", target.Key, target.Version)
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":         true,
+		"namespace":       target.Namespace,
+		"group":           target.Group,
+		"key":             target.Key,
+		"target_version":  target.Version,
+		"value":           target.Value,
+		"type":            target.Type,
+		"current_version": currentVersion,
+		"current_type":    currentType,
+		"diff":            util.UnifiedDiff(fromName, toName, fromValue, target.Value, diffContextLines),
+	})
+}
+
+// parseTimeRange reads the optional from/to RFC 3339 query parameters
+func parseTimeRange(c *gin.Context) (from, to time.Time, err error) {
+	if v := c.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid from time, expected RFC 3339")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return from, to, fmt.Errorf("invalid to time, expected RFC 3339")
+		}
+	}
+	return from, to, nil
+}
+
+// inTimeRange reports whether t falls within [from, to]; zero bounds are open
+func inTimeRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && t.After(to) {
+		return false
+	}
+	return true
+}
+
+// exportHistoryHandler streams the change history of a config as CSV or JSON
+func (s *Server) exportHistoryHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+	key := c.Param("key")
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv or json"})
+		return
+	}
+
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	histories, err := s.store.ListHistory(c.Request.Context(), namespace, group, key)
+	if err != nil {
+		s.logger.Error("Failed to list history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sort.Slice(histories, func(i, j int) bool { return histories[i].ID < histories[j].ID })
+
+	filename := fmt.Sprintf("%s_%s_%s_history.%s", namespace, group, key, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write([]string{"id", "namespace", "group", "key", "version", "op_type", "type", "created_by", "created_at", "value"})
+		for _, h := range histories {
+			if !inTimeRange(h.CreatedAt, from, to) {
+				continue
+			}
+			_ = w.Write([]string{
+				strconv.FormatInt(h.ID, 10),
+				h.Namespace,
+				h.Group,
+				h.Key,
+				strconv.FormatInt(h.Version, 10),
+				h.OpType,
+				h.Type,
+				h.CreatedBy,
+				h.CreatedAt.Format(time.RFC3339),
+				h.Value,
+			})
+		}
+		w.Flush()
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	c.Writer.WriteString("[")
+	first := true
+	for _, h := range histories {
+		if !inTimeRange(h.CreatedAt, from, to) {
+			continue
+		}
+		if !first {
+			c.Writer.WriteString(",")
+		}
+		first = false
+		_ = enc.Encode(h)
+	}
+	c.Writer.WriteString("]\n")
+}
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// parseSince accepts either an RFC 3339 time or a duration relative to now (e.g. 24h)
+func parseSince(value string, fallback time.Duration) (time.Time, error) {
+	if value == "" {
+		return time.Now().Add(-fallback), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since, expected an RFC 3339 time or a duration such as 24h")
+	}
+	return t, nil
+}
+
+// namespaceChangesHandler returns a time-ordered feed of config changes across all groups of a namespace
+func (s *Server) namespaceChangesHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	since, err := parseSince(c.Query("since"), 24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultChangesLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if limit > maxChangesLimit {
+			limit = maxChangesLimit
+		}
+	}
+
+	changes, err := s.store.ListNamespaceHistory(c.Request.Context(), namespace, since, limit)
+	if err != nil {
+		s.logger.Error("Failed to list namespace changes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// namespaceEventsHandler returns the changes in a namespace after a cursor,
+// oldest first. The cursor is the ID of the last event a consumer has seen,
+// so one that was offline can catch up without missing or repeating events.
+func (s *Server) namespaceEventsHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	var cursor int64
+	if v := c.Query("since"); v != "" {
+		var err error
+		cursor, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since cursor"})
+			return
+		}
+	}
+
+	limit := defaultChangesLimit
+	if v := c.Query("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if limit > maxChangesLimit {
+			limit = maxChangesLimit
+		}
+	}
+
+	// Fetch one extra event to tell whether more remain after this page
+	events, err := s.store.ListNamespaceEvents(c.Request.Context(), namespace, cursor, limit+1)
+	if err != nil {
+		s.logger.Error("Failed to list namespace events", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+	if len(events) > 0 {
+		cursor = events[len(events)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":   events,
+		"cursor":   strconv.FormatInt(cursor, 10),
+		"has_more": hasMore,
+	})
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// IPRule allows or denies client networks, optionally scoped to requests
+// whose path starts with PathPrefix and, if set, use Method.
+type IPRule struct {
+	Method     string
+	PathPrefix string
+	Allow      bool
+	Networks   []*net.IPNet
+}
+
+// ParseIPRule parses a rule of the form "[METHOD ][/path/prefix=]cidr[,cidr...]".
+// Bare IP addresses are accepted as single-host networks.
+func ParseIPRule(spec string, allow bool) (IPRule, error) {
+	rule := IPRule{Allow: allow}
+
+	cidrs := spec
+	if i := strings.LastIndex(spec, "="); i >= 0 {
+		scope := strings.TrimSpace(spec[:i])
+		cidrs = spec[i+1:]
+		if method, path, ok := strings.Cut(scope, " "); ok {
+			rule.Method = strings.ToUpper(strings.TrimSpace(method))
+			scope = strings.TrimSpace(path)
+		}
+		if !strings.HasPrefix(scope, "/") {
+			return IPRule{}, fmt.Errorf("invalid path prefix %q in IP rule", scope)
+		}
+		rule.PathPrefix = scope
+	}
+
+	for _, cidr := range strings.Split(cidrs, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return IPRule{}, fmt.Errorf("invalid IP address %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			rule.Networks = append(rule.Networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return IPRule{}, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		rule.Networks = append(rule.Networks, network)
+	}
+	if len(rule.Networks) == 0 {
+		return IPRule{}, fmt.Errorf("IP rule %q has no networks", spec)
+	}
+	return rule, nil
+}
+
+func (r IPRule) applies(method, path string) bool {
+	if r.Method != "" && r.Method != method {
+		return false
+	}
+	return strings.HasPrefix(path, r.PathPrefix)
+}
+
+func (r IPRule) contains(ip net.IP) bool {
+	for _, network := range r.Networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFilter decides whether a client address may access a path.
+// Deny rules take precedence; if any allow rule applies to a request,
+// the client must match at least one of them.
+type IPFilter struct {
+	rules []IPRule
+}
+
+func NewIPFilter(rules []IPRule) *IPFilter {
+	return &IPFilter{rules: rules}
+}
+
+// Allowed reports whether the client IP may make a request with the given method and path
+func (f *IPFilter) Allowed(clientIP, method, path string) bool {
+	if f == nil || len(f.rules) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	allowRules, allowed := 0, false
+	for _, rule := range f.rules {
+		if !rule.applies(method, path) {
+			continue
+		}
+		if !rule.Allow {
+			if rule.contains(ip) {
+				return false
+			}
+			continue
+		}
+		allowRules++
+		if rule.contains(ip) {
+			allowed = true
+		}
+	}
+	return allowRules == 0 || allowed
+}
+
+// SetIPFilter replaces the IP allow/deny rules enforced on every request
+func (s *Server) SetIPFilter(filter *IPFilter) {
+	s.ipFilter.Store(filter)
+}
+
+// SetTrustedProxies sets the proxies whose forwarding headers are trusted
+// when determining the client IP, on the public API and the admin
+// listeners alike. Passing nil trusts none.
+func (s *Server) SetTrustedProxies(proxies []string) error {
+	if err := s.engine.SetTrustedProxies(proxies); err != nil {
+		return err
+	}
+	return s.adminEngine.SetTrustedProxies(proxies)
+}
+
+// ipFilterMiddleware rejects clients excluded by the IP allow/deny rules before authentication
+func (s *Server) ipFilterMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := s.ipFilter.Load()
+		if !filter.Allowed(c.ClientIP(), c.Request.Method, c.Request.URL.Path) {
+			s.logger.Warn("Request rejected by IP filter",
+				zap.String("ip", c.ClientIP()),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied from this address"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+const (
+	// listCacheSize bounds the number of list responses kept in the cache
+	listCacheSize = 4096
+	// listCacheTTL bounds how long a response is served from the cache, for
+	// changes no notification reaches, such as writes by instances sharing
+	// the database without a bus or bus messages that were lost
+	listCacheTTL = 5 * time.Second
+)
+
+// ListCacheStats summarizes the use of the cache of list responses
+type ListCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	// Invalidations counts the changes that dropped cached responses
+	Invalidations int64 `json:"invalidations"`
+}
+
+// listCacheKey identifies a cached list response. An empty group stands for
+// the list of every config in the namespace.
+type listCacheKey struct {
+	tenant, namespace, group string
+	values                   bool
+}
+
+// listCacheEntry is a cached list response and when it expires
+type listCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// listCache keeps the serialized responses of config list requests until a
+// change to their namespace or group is notified to the watcher, or for at
+// most listCacheTTL, so repeated listings of a large group neither query
+// the store nor encode the configs again.
+type listCache struct {
+	mu      sync.Mutex
+	entries map[listCacheKey]listCacheEntry
+	now     func() time.Time
+	// generation counts invalidations, so a response built from a store read
+	// that raced with a change is not cached
+	generation uint64
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+func newListCache() *listCache {
+	return &listCache{entries: make(map[listCacheKey]listCacheEntry), now: time.Now}
+}
+
+// get returns the cached response for key, if any, and the generation to
+// pass to put once a missing response has been built
+func (lc *listCache) get(key listCacheKey) ([]byte, uint64) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	entry, ok := lc.entries[key]
+	if ok && !lc.now().Before(entry.expires) {
+		delete(lc.entries, key)
+		ok = false
+	}
+	if !ok {
+		lc.misses.Add(1)
+		return nil, lc.generation
+	}
+	lc.hits.Add(1)
+	return entry.body, lc.generation
+}
+
+// put caches the response for key unless the cache was invalidated since
+// the generation returned by get
+func (lc *listCache) put(key listCacheKey, generation uint64, body []byte) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if generation != lc.generation {
+		return
+	}
+	if len(lc.entries) >= listCacheSize {
+		// Evict an arbitrary response rather than track recency on every hit
+		for k := range lc.entries {
+			delete(lc.entries, k)
+			break
+		}
+	}
+	lc.entries[key] = listCacheEntry{body: body, expires: lc.now().Add(listCacheTTL)}
+}
+
+// invalidate drops the cached lists of a group and of its namespace
+func (lc *listCache) invalidate(tenant, namespace, group string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.generation++
+	for _, g := range [...]string{group, ""} {
+		for _, values := range [...]bool{true, false} {
+			delete(lc.entries, listCacheKey{tenant, namespace, g, values})
+		}
+	}
+	lc.invalidations.Add(1)
+}
+
+// invalidateTenant drops every cached list of a tenant
+func (lc *listCache) invalidateTenant(tenant string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.generation++
+	for k := range lc.entries {
+		if k.tenant == tenant {
+			delete(lc.entries, k)
+		}
+	}
+	lc.invalidations.Add(1)
+}
+
+// onNotify invalidates the lists holding the config of a change event. It
+// is registered with the watcher, which sees the changes made through this
+// server as well as those received from other instances over the bus.
+func (lc *listCache) onNotify(tenant string, event *model.ConfigEvent) {
+	lc.invalidate(tenant, event.Config.Namespace, event.Config.Group)
+}
+
+// Stats returns the current size and hit counters of the cache
+func (lc *listCache) Stats() ListCacheStats {
+	lc.mu.Lock()
+	entries := len(lc.entries)
+	lc.mu.Unlock()
+	return ListCacheStats{
+		Entries:       entries,
+		Hits:          lc.hits.Load(),
+		Misses:        lc.misses.Load(),
+		Invalidations: lc.invalidations.Load(),
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestCachedList tests that a group list is served from the cache until a
+// change to the group is notified, and that a list read while a change was
+// notified is not cached
+func TestCachedList(t *testing.T) {
+	s := &Server{
+		logger:    zap.NewNop(),
+		store:     store.NewTenantStore(store.NewInMemoryStore()),
+		watcher:   NewWatcher(),
+		listCache: newListCache(),
+	}
+	s.watcher.OnNotify(s.listCache.onNotify)
+	ctx := context.Background()
+	put := func(key, value string) {
+		config := &model.Config{Namespace: "public", Group: "app", Key: key, Value: value, Type: "text"}
+		if err := s.store.Put(ctx, config); err != nil {
+			t.Fatal(err)
+		}
+		s.notify(ctx, model.EventPut, config)
+	}
+	list := func() []*model.Config {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/namespaces/public/groups/app/configs", nil)
+		s.cachedList(c, "public", "app", func(ctx context.Context) ([]*model.Config, error) {
+			return s.store.List(ctx, "public", "app")
+		})
+		var configs []*model.Config
+		if err := json.Unmarshal(w.Body.Bytes(), &configs); err != nil {
+			t.Fatalf("decoding list response %q: %v", w.Body.String(), err)
+		}
+		return configs
+	}
+
+	put("timeout", "30s")
+	if configs := list(); len(configs) != 1 {
+		t.Fatalf("listed %d configs, want 1", len(configs))
+	}
+	list()
+	if stats := s.listCache.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("stats after repeated list = %+v, want 1 hit, 1 miss and 1 entry", stats)
+	}
+
+	put("retries", "3")
+	if configs := list(); len(configs) != 2 {
+		t.Errorf("listed %d configs after a put, want 2", len(configs))
+	}
+
+	s.notify(ctx, model.EventPut, &model.Config{Namespace: "public", Group: "other", Key: "k"})
+	list()
+	if stats := s.listCache.Stats(); stats.Hits != 2 {
+		t.Errorf("list after a change to another group missed the cache: %+v", stats)
+	}
+
+	key := listCacheKey{namespace: "public", group: "app", values: true}
+	_, generation := s.listCache.get(key)
+	s.listCache.invalidate("", "public", "app")
+	s.listCache.put(key, generation, []byte("[]"))
+	if body, _ := s.listCache.get(key); body != nil {
+		t.Errorf("response read before an invalidation was cached: %s", body)
+	}
+}
+
+// TestCachedListExpires tests that a cached list picks up a change no
+// notification reached, such as a write by another instance sharing the
+// database without a bus, once the cached response expires
+func TestCachedListExpires(t *testing.T) {
+	now := time.Now()
+	s := &Server{
+		logger:    zap.NewNop(),
+		store:     store.NewTenantStore(store.NewInMemoryStore()),
+		watcher:   NewWatcher(),
+		listCache: newListCache(),
+	}
+	s.listCache.now = func() time.Time { return now }
+	s.watcher.OnNotify(s.listCache.onNotify)
+	ctx := context.Background()
+	put := func(key string) {
+		if err := s.store.Put(ctx, &model.Config{Namespace: "public", Group: "app", Key: key, Value: "v", Type: "text"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func() int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/namespaces/public/groups/app/configs", nil)
+		s.cachedList(c, "public", "app", func(ctx context.Context) ([]*model.Config, error) {
+			return s.store.List(ctx, "public", "app")
+		})
+		var configs []*model.Config
+		if err := json.Unmarshal(w.Body.Bytes(), &configs); err != nil {
+			t.Fatalf("decoding list response %q: %v", w.Body.String(), err)
+		}
+		return len(configs)
+	}
+
+	put("timeout")
+	if n := list(); n != 1 {
+		t.Fatalf("listed %d configs, want 1", n)
+	}
+	put("retries")
+	if n := list(); n != 1 {
+		t.Errorf("listed %d configs before the cached list expired, want the cached 1", n)
+	}
+	now = now.Add(listCacheTTL)
+	if n := list(); n != 2 {
+		t.Errorf("listed %d configs after the cached list expired, want 2", n)
+	}
+}
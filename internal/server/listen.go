@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// unixPrefix marks a listen address as the path of a Unix domain socket
+const unixPrefix = "unix:"
+
+// Listen opens a listener on addr, either a TCP host:port or unix:/path for
+// a Unix domain socket, or takes over the one on addr handed down by
+// Upgrade. A socket file left behind by a previous run is replaced, but any
+// other file at the path is kept and fails the listen.
+func Listen(addr string) (net.Listener, error) {
+	if l, ok := inheritedListener(addr); ok {
+		return l, nil
+	}
+	path, ok := strings.CutPrefix(addr, unixPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, errors.New("unix socket path required")
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return unixListener{l}, nil
+}
+
+// unixListener reports its peers as the loopback address, since they run
+// on the same host. IP rules, login lockouts and sessions then see local
+// proxies as 127.0.0.1, and -trusted-proxies can trust their forwarding
+// headers.
+type unixListener struct {
+	net.Listener
+}
+
+func (l unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return unixConn{conn}, nil
+}
+
+type unixConn struct {
+	net.Conn
+}
+
+func (unixConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+// Serve serves the API on every listener, and the admin routes on the
+// listeners given to SetAdminListeners, until one of them fails, and returns
+// that listener's error. It returns nil once Drain stops it.
+func (s *Server) Serve(listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("no listeners")
+	}
+	type served struct {
+		srv   *http.Server
+		l     net.Listener
+		admin bool
+	}
+	all := make([]served, 0, len(listeners)+len(s.adminListeners))
+	for _, l := range listeners {
+		all = append(all, served{&http.Server{Handler: s.engine}, l, false})
+	}
+	for _, l := range s.adminListeners {
+		all = append(all, served{&http.Server{Handler: s.adminEngine}, l, true})
+	}
+
+	s.httpMu.Lock()
+	for _, sv := range all {
+		s.httpServers = append(s.httpServers, sv.srv)
+	}
+	s.httpMu.Unlock()
+
+	errs := make(chan error, len(all))
+	for _, sv := range all {
+		s.logger.Info("Listening", zap.String("addr", sv.l.Addr().String()), zap.String("network", sv.l.Addr().Network()),
+			zap.Bool("admin", sv.admin))
+		go func(sv served) {
+			errs <- sv.srv.Serve(sv.l)
+		}(sv)
+	}
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
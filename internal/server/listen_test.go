@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestListenUnix tests that a Unix socket left behind is replaced, while a
+// socket in use or a regular file at the path is not
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "otter.sock")
+
+	l, err := Listen(unixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(unixPrefix + path); err == nil {
+		t.Error("listened on a socket in use")
+	}
+
+	accepted := make(chan struct{})
+	go func(l net.Listener) {
+		defer close(accepted)
+		if conn, err := l.Accept(); err == nil {
+			if addr, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !addr.IP.IsLoopback() {
+				t.Errorf("peer address %v, want loopback", conn.RemoteAddr())
+			}
+			conn.Close()
+		}
+	}(l)
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	<-accepted
+
+	// Leave the socket file behind as a crashed server would
+	l.(unixListener).Listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	replaced, err := Listen(unixPrefix + path)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	replaced.Close()
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(unixPrefix + file); err == nil {
+		t.Error("replaced a regular file")
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// lockedError describes a write rejected by a config or namespace lock
+type lockedError struct {
+	lock *model.ConfigLock
+}
+
+func (e *lockedError) Error() string {
+	if e.lock.IsNamespaceLock() {
+		return fmt.Sprintf("namespace %s is frozen by %s: %s", e.lock.Namespace, e.lock.Owner, e.lock.Reason)
+	}
+	return fmt.Sprintf("config %s/%s/%s is locked by %s: %s", e.lock.Namespace, e.lock.Group, e.lock.Key, e.lock.Owner, e.lock.Reason)
+}
+
+// checkLock verifies that a config may be written or deleted, neither it
+// nor its namespace being locked. It returns a *lockedError when the change
+// must be rejected.
+func (s *Server) checkLock(ctx context.Context, namespace, group, key string) error {
+	lock, err := s.store.GetLock(ctx, namespace, "", "")
+	if err == store.ErrNotFound {
+		lock, err = s.store.GetLock(ctx, namespace, group, key)
+	}
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &lockedError{lock: lock}
+}
+
+// respondLockError writes the response for a failed lock check
+func (s *Server) respondLockError(c *gin.Context, err error) {
+	if le, ok := err.(*lockedError); ok {
+		c.JSON(http.StatusLocked, gin.H{"error": le.Error(), "lock": le.lock})
+		return
+	}
+	s.logger.Error("Failed to check config lock", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// listLocksHandler returns the locks in a namespace, its freeze first
+func (s *Server) listLocksHandler(c *gin.Context) {
+	locks, err := s.store.ListLocks(c.Request.Context(), c.Param("namespace"))
+	if err != nil {
+		s.logger.Error("Failed to list locks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, locks)
+}
+
+// lockConfigHandler locks a config key against writes and deletions
+func (s *Server) lockConfigHandler(c *gin.Context) {
+	s.setLock(c, c.Param("namespace"), c.Param("group"), c.Param("key"))
+}
+
+// unlockConfigHandler removes the lock of a config key
+func (s *Server) unlockConfigHandler(c *gin.Context) {
+	s.removeLock(c, c.Param("namespace"), c.Param("group"), c.Param("key"))
+}
+
+// freezeNamespaceHandler freezes every config of a namespace, for example
+// during a release
+func (s *Server) freezeNamespaceHandler(c *gin.Context) {
+	s.setLock(c, c.Param("namespace"), "", "")
+}
+
+// unfreezeNamespaceHandler lifts the freeze of a namespace
+func (s *Server) unfreezeNamespaceHandler(c *gin.Context) {
+	s.removeLock(c, c.Param("namespace"), "", "")
+}
+
+// setLock creates a lock, or updates the reason of a lock the user already
+// holds. A lock held by someone else is left alone and reported with 423.
+func (s *Server) setLock(c *gin.Context, namespace, group, key string) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, a reason is required"})
+		return
+	}
+	username := c.GetString("username")
+
+	existing, err := s.store.GetLock(c.Request.Context(), namespace, group, key)
+	switch {
+	case err == nil && existing.Owner != username:
+		s.respondLockError(c, &lockedError{lock: existing})
+		return
+	case err != nil && err != store.ErrNotFound:
+		s.logger.Error("Failed to get lock", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	lock := &model.ConfigLock{
+		Namespace: namespace,
+		Group:     group,
+		Key:       key,
+		Owner:     username,
+		Reason:    req.Reason,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.SetLock(c.Request.Context(), lock); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Namespace not found"})
+			return
+		}
+		s.logger.Error("Failed to set lock", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Locked configs",
+		zap.String("namespace", namespace),
+		zap.String("group", group),
+		zap.String("key", key),
+		zap.String("reason", req.Reason),
+		zap.String("operator", username))
+	c.JSON(http.StatusOK, lock)
+}
+
+// removeLock deletes a lock. Only its owner or an admin may remove it.
+func (s *Server) removeLock(c *gin.Context, namespace, group, key string) {
+	username := c.GetString("username")
+
+	lock, err := s.store.GetLock(c.Request.Context(), namespace, group, key)
+	if err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Lock not found"})
+			return
+		}
+		s.logger.Error("Failed to get lock", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if lock.Owner != username {
+		admin, err := s.isAdmin(c.Request.Context(), username)
+		if err != nil {
+			s.logger.Error("Failed to get user", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !admin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the lock owner or an admin can unlock"})
+			return
+		}
+	}
+
+	if err := s.store.DeleteLock(c.Request.Context(), namespace, group, key); err != nil && err != store.ErrNotFound {
+		s.logger.Error("Failed to delete lock", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Unlocked configs",
+		zap.String("namespace", namespace),
+		zap.String("group", group),
+		zap.String("key", key),
+		zap.String("owner", lock.Owner),
+		zap.String("operator", username))
+	c.Status(http.StatusNoContent)
+}
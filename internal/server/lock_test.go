@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestCheckLock tests that a key lock only blocks its own key while a
+// namespace freeze blocks every key, and that both lift when removed
+func TestCheckLock(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore())}
+	ctx := context.Background()
+	locked := func(group, key string) *model.ConfigLock {
+		err := s.checkLock(ctx, "public", group, key)
+		if err == nil {
+			return nil
+		}
+		le, ok := err.(*lockedError)
+		if !ok {
+			t.Fatalf("checkLock failed: %v", err)
+		}
+		return le.lock
+	}
+
+	if err := s.store.SetLock(ctx, &model.ConfigLock{Namespace: "public", Group: "app", Key: "timeout", Owner: "alice", Reason: "incident"}); err != nil {
+		t.Fatal(err)
+	}
+	if lock := locked("app", "timeout"); lock == nil || lock.Owner != "alice" || lock.Reason != "incident" {
+		t.Errorf("locked key got lock %+v, want alice's", lock)
+	}
+	if lock := locked("app", "retries"); lock != nil {
+		t.Errorf("unlocked key got lock %+v", lock)
+	}
+
+	if err := s.store.SetLock(ctx, &model.ConfigLock{Namespace: "public", Owner: "bob", Reason: "release"}); err != nil {
+		t.Fatal(err)
+	}
+	if lock := locked("app", "retries"); lock == nil || !lock.IsNamespaceLock() {
+		t.Errorf("key in frozen namespace got lock %+v, want the freeze", lock)
+	}
+	if locks, err := s.store.ListLocks(ctx, "public"); err != nil || len(locks) != 2 || !locks[0].IsNamespaceLock() {
+		t.Errorf("ListLocks = %+v, %v, want the freeze then the key lock", locks, err)
+	}
+
+	for _, target := range [][2]string{{"", ""}, {"app", "timeout"}} {
+		if err := s.store.DeleteLock(ctx, "public", target[0], target[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lock := locked("app", "timeout"); lock != nil {
+		t.Errorf("unlocked key got lock %+v", lock)
+	}
+	if err := s.store.DeleteLock(ctx, "public", "app", "timeout"); err != store.ErrNotFound {
+		t.Errorf("deleting a missing lock returned %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMaxLoginFailures is how many failed logins lock a username or IP
+	defaultMaxLoginFailures = 5
+	// defaultLoginLockout is the first lockout, doubled for every further failure
+	defaultLoginLockout = time.Minute
+	// maxLoginLockout caps the exponential lockout
+	maxLoginLockout = time.Hour
+	// loginFailureWindow is how long failures are remembered without a new one
+	loginFailureWindow = 15 * time.Minute
+)
+
+// LoginLock describes the failed logins of a username or client IP
+type LoginLock struct {
+	Kind        string    `json:"kind"` // username or ip
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until"` // Zero if not locked
+}
+
+// loginSubject identifies what failed logins are counted against
+type loginSubject struct {
+	kind, value string
+}
+
+// LoginGuard counts failed logins per username and per client IP. Once
+// either reaches the failure limit, further attempts are refused for a
+// lockout that doubles with every additional failure. Counters live in
+// memory, so each server instance keeps its own.
+type LoginGuard struct {
+	maxFailures int
+	lockout     time.Duration
+
+	mu       sync.Mutex
+	failures map[loginSubject]*LoginLock
+}
+
+// NewLoginGuard creates a LoginGuard that locks after maxFailures failed
+// logins for lockout, doubled for every failure beyond that
+func NewLoginGuard(maxFailures int, lockout time.Duration) *LoginGuard {
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxLoginFailures
+	}
+	if lockout <= 0 {
+		lockout = defaultLoginLockout
+	}
+	return &LoginGuard{maxFailures: maxFailures, lockout: lockout, failures: make(map[loginSubject]*LoginLock)}
+}
+
+// Locked returns how long logins for username from ip remain refused, zero
+// if they are allowed
+func (g *LoginGuard) Locked(username, ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, subject := range []loginSubject{{"username", username}, {"ip", ip}} {
+		if lock := g.current(subject, now); lock != nil && lock.LockedUntil.After(now) {
+			wait = max(wait, lock.LockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// Fail records a failed login for username from ip
+func (g *LoginGuard) Fail(username, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for _, subject := range []loginSubject{{"username", username}, {"ip", ip}} {
+		lock := g.current(subject, now)
+		if lock == nil {
+			lock = &LoginLock{Kind: subject.kind, Value: subject.value}
+			g.failures[subject] = lock
+		}
+		lock.Failures++
+		lock.LastFailure = now
+		if extra := lock.Failures - g.maxFailures; extra >= 0 {
+			lockout := maxLoginLockout
+			if extra < 16 {
+				lockout = min(g.lockout<<extra, maxLoginLockout)
+			}
+			lock.LockedUntil = now.Add(lockout)
+		}
+	}
+}
+
+// Succeed forgets the failed logins of username after a successful login.
+// The IP keeps its count, so one valid account cannot be used to reset the
+// counter while guessing others.
+func (g *LoginGuard) Succeed(username string) {
+	g.mu.Lock()
+	delete(g.failures, loginSubject{"username", username})
+	g.mu.Unlock()
+}
+
+// Unlock forgets the failed logins of a username or IP and reports whether
+// there were any
+func (g *LoginGuard) Unlock(kind, value string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	subject := loginSubject{kind, value}
+	_, ok := g.failures[subject]
+	delete(g.failures, subject)
+	return ok
+}
+
+// List returns the usernames and IPs with recent failed logins, locked ones first
+func (g *LoginGuard) List() []LoginLock {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	locks := make([]LoginLock, 0, len(g.failures))
+	for subject := range g.failures {
+		if lock := g.current(subject, now); lock != nil {
+			locks = append(locks, *lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if !locks[i].LockedUntil.Equal(locks[j].LockedUntil) {
+			return locks[i].LockedUntil.After(locks[j].LockedUntil)
+		}
+		return locks[i].LastFailure.After(locks[j].LastFailure)
+	})
+	return locks
+}
+
+// current returns the failures of subject, dropping them once both the
+// lockout and the failure window have passed. g.mu must be held.
+func (g *LoginGuard) current(subject loginSubject, now time.Time) *LoginLock {
+	lock, ok := g.failures[subject]
+	if !ok {
+		return nil
+	}
+	if now.After(lock.LockedUntil) && now.Sub(lock.LastFailure) > loginFailureWindow {
+		delete(g.failures, subject)
+		return nil
+	}
+	return lock
+}
+
+// SetLoginGuard replaces the failed login limits, e.g. to change the defaults
+// of 5 failures and a one minute lockout
+func (s *Server) SetLoginGuard(g *LoginGuard) {
+	s.loginGuard = g
+}
+
+// rejectLockedLogin answers 429 if logins for username from the client IP
+// are locked out, and reports whether it did
+func (s *Server) rejectLockedLogin(c *gin.Context, username string) bool {
+	wait := s.loginGuard.Locked(username, c.ClientIP())
+	if wait <= 0 {
+		return false
+	}
+	s.logger.Warn("Login refused: Locked out", zap.String("username", username), zap.String("ip", c.ClientIP()), zap.Duration("remaining", wait))
+	c.Header("Retry-After", strconv.Itoa(int(wait.Round(time.Second)/time.Second)+1))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+	return true
+}
+
+// listLoginLocksHandler lists usernames and IPs with recent failed logins
+func (s *Server) listLoginLocksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.loginGuard.List())
+}
+
+// unlockLoginHandler clears the failed logins of ?username= or ?ip=
+func (s *Server) unlockLoginHandler(c *gin.Context) {
+	username, ip := c.Query("username"), c.Query("ip")
+	if (username == "") == (ip == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of username or ip is required"})
+		return
+	}
+	kind, value := "username", username
+	if ip != "" {
+		kind, value = "ip", ip
+	}
+	if !s.loginGuard.Unlock(kind, value) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No failed logins recorded"})
+		return
+	}
+	s.logger.Info("Login lock cleared", zap.String(kind, value), zap.String("by", c.GetString("username")))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestLoginGuard tests that failed logins lock a username and IP with a
+// doubling lockout, and that success and unlocking clear the right counters
+func TestLoginGuard(t *testing.T) {
+	g := NewLoginGuard(3, time.Minute)
+	for i := 0; i < 2; i++ {
+		g.Fail("alice", "10.0.0.1")
+	}
+	if wait := g.Locked("alice", "10.0.0.1"); wait != 0 {
+		t.Fatalf("locked for %v before reaching the limit", wait)
+	}
+
+	g.Fail("alice", "10.0.0.1")
+	if wait := g.Locked("alice", "10.0.0.2"); wait <= 0 || wait > time.Minute {
+		t.Errorf("username locked for %v, want up to 1m", wait)
+	}
+	g.Fail("alice", "10.0.0.1")
+	if wait := g.Locked("bob", "10.0.0.1"); wait <= time.Minute || wait > 2*time.Minute {
+		t.Errorf("IP locked for %v after another failure, want up to 2m", wait)
+	}
+
+	g.Succeed("alice")
+	if wait := g.Locked("alice", "10.0.0.2"); wait != 0 {
+		t.Errorf("username still locked for %v after a successful login", wait)
+	}
+	if wait := g.Locked("alice", "10.0.0.1"); wait == 0 {
+		t.Error("a successful login cleared the IP lock")
+	}
+	if !g.Unlock("ip", "10.0.0.1") || g.Locked("alice", "10.0.0.1") != 0 {
+		t.Error("Unlock did not clear the IP lock")
+	}
+	if len(g.List()) != 0 {
+		t.Errorf("List = %+v after unlocking, want none", g.List())
+	}
+}
+
+// TestLoginGuardIgnoresForwardedFor tests that failed logins count against
+// the connection's address when it is not a trusted proxy, so a rotating
+// X-Forwarded-For neither gets a fresh counter nor locks out the IPs it names
+func TestLoginGuardIgnoresForwardedFor(t *testing.T) {
+	s := NewServer(store.NewInMemoryStore(), "secret", zap.NewNop())
+	for i := 0; i < defaultMaxLoginFailures; i++ {
+		body := fmt.Sprintf(`{"username": "user%d", "password": "wrong"}`, i)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		req.RemoteAddr = "203.0.113.7:4000"
+		w := httptest.NewRecorder()
+		s.engine.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("login %d = %d, want 401", i, w.Code)
+		}
+	}
+
+	if wait := s.loginGuard.Locked("someone", "203.0.113.7"); wait <= 0 {
+		t.Error("connection address not locked after failures with rotating X-Forwarded-For")
+	}
+	for i := 0; i < defaultMaxLoginFailures; i++ {
+		if wait := s.loginGuard.Locked("someone", fmt.Sprintf("198.51.100.%d", i)); wait != 0 {
+			t.Errorf("forwarded address 198.51.100.%d locked for %v", i, wait)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const defaultMaintenanceMessage = "Server is in maintenance mode; configuration changes are temporarily disabled"
+
+// MaintenanceStatus describes whether the server is in read-only mode
+type MaintenanceStatus struct {
+	Enabled   bool      `json:"enabled"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// maintenanceExemptRoutes may still be called with a mutating method in read-only mode
+var maintenanceExemptRoutes = map[string]bool{
+	"/api/v1/login":             true,
+	"/api/v1/refresh":           true,
+	"/api/v1/admin/maintenance": true,
+	"/api/v1/admin/backup":      true,
+	"/api/v1/admin/reload":      true,
+	"/api/v1/namespaces/:namespace/groups/:group/configs/:key/ack": true,
+}
+
+// maintenanceMiddleware rejects mutations with 503 while read-only mode is enabled
+func (s *Server) maintenanceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := s.maintenance.Load()
+		if status == nil || !status.Enabled {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if maintenanceExemptRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		// Dry runs never write
+		if dryRun, _ := strconv.ParseBool(c.Query("dryRun")); dryRun {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", "120")
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":       status.Message,
+			"maintenance": true,
+		})
+	}
+}
+
+// getMaintenanceHandler returns the current read-only mode status
+func (s *Server) getMaintenanceHandler(c *gin.Context) {
+	status := s.maintenance.Load()
+	if status == nil {
+		status = &MaintenanceStatus{}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// setMaintenanceHandler enables or disables read-only mode
+func (s *Server) setMaintenanceHandler(c *gin.Context) {
+	var req struct {
+		Enabled *bool  `json:"enabled" binding:"required"`
+		Message string `json:"message"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	status := &MaintenanceStatus{
+		Enabled:   *req.Enabled,
+		UpdatedBy: c.GetString("username"),
+		UpdatedAt: time.Now(),
+	}
+	if status.Enabled {
+		status.Message = req.Message
+		if status.Message == "" {
+			status.Message = defaultMaintenanceMessage
+		}
+	}
+	s.maintenance.Store(status)
+
+	s.logger.Info("Maintenance mode changed",
+		zap.Bool("enabled", status.Enabled),
+		zap.String("operator", status.UpdatedBy))
+
+	c.JSON(http.StatusOK, status)
+}
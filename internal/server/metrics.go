@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// metricsHandler exposes the connection, route and watch statistics in the
+// Prometheus text format
+func (s *Server) metricsHandler(c *gin.Context) {
+	stats := s.currentStats()
+
+	var b strings.Builder
+	metric := func(name, kind, help string, value any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("otter_requests_total", "counter", "Requests served.", stats.TotalRequests)
+	metric("otter_requests_failed_total", "counter", "Requests answered with a 5xx status.", stats.FailedRequests)
+	metric("otter_active_connections", "gauge", "Requests in progress, other than long polls.", stats.ActiveConnections)
+	metric("otter_long_poll_holders", "gauge", "Long polls waiting for a change.", stats.LongPollHolders)
+	metric("otter_watch_subscriptions", "gauge", "Watch subscriptions of long polls and WebSocket connections.", stats.Watch.Subscriptions)
+	metric("otter_watch_notifications_total", "counter", "Change events notified to the watcher.", stats.Watch.Notifications)
+	metric("otter_watch_deliveries_total", "counter", "Change events queued to subscriptions.", stats.Watch.Deliveries)
+	metric("otter_watch_coalesced_total", "counter", "Queued change events replaced by a newer change of the same key.", stats.Watch.Coalesced)
+	metric("otter_watch_dropped_total", "counter", "Change events lost to full subscription queues.", stats.Watch.Dropped)
+
+	type route struct {
+		routeKey
+		hist histogramSnapshot
+	}
+	var routes []route
+	s.routes.Range(func(key, value any) bool {
+		routes = append(routes, route{key.(routeKey), value.(*latencyHistogram).snapshot()})
+		return true
+	})
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].route != routes[j].route {
+			return routes[i].route < routes[j].route
+		}
+		return routes[i].method < routes[j].method
+	})
+
+	b.WriteString("# HELP otter_request_duration_seconds Request latency by route, excluding time long polls spent waiting.\n")
+	b.WriteString("# TYPE otter_request_duration_seconds histogram\n")
+	for _, r := range routes {
+		labels := fmt.Sprintf(`method=%q,route=%q`, r.method, r.route)
+		var cumulative int64
+		for i, bound := range latencyBuckets {
+			cumulative += r.hist.counts[i]
+			fmt.Fprintf(&b, "otter_request_duration_seconds_bucket{%s,le=%q} %d\n", labels,
+				strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "otter_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, r.hist.count)
+		fmt.Fprintf(&b, "otter_request_duration_seconds_sum{%s} %g\n", labels, r.hist.total.Seconds())
+		fmt.Fprintf(&b, "otter_request_duration_seconds_count{%s} %d\n", labels, r.hist.count)
+	}
+	b.WriteString("# HELP otter_request_errors_total Requests answered with a 5xx status by route.\n")
+	b.WriteString("# TYPE otter_request_errors_total counter\n")
+	for _, r := range routes {
+		fmt.Fprintf(&b, "otter_request_errors_total{method=%q,route=%q} %d\n", r.method, r.route, r.hist.errors)
+	}
+
+	// Store operations, to tell which ones slow requests down
+	ops := s.storeOps.Stats()
+	b.WriteString("# HELP otter_store_operation_duration_seconds Store operation latency by method.\n")
+	b.WriteString("# TYPE otter_store_operation_duration_seconds histogram\n")
+	for _, op := range ops {
+		var cumulative int64
+		for i, bound := range store.OperationBuckets {
+			cumulative += op.Buckets[i]
+			fmt.Fprintf(&b, "otter_store_operation_duration_seconds_bucket{method=%q,le=%q} %d\n", op.Method,
+				strconv.FormatFloat(bound.Seconds(), 'g', -1, 64), cumulative)
+		}
+		cumulative += op.Buckets[len(store.OperationBuckets)]
+		fmt.Fprintf(&b, "otter_store_operation_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", op.Method, cumulative)
+		fmt.Fprintf(&b, "otter_store_operation_duration_seconds_sum{method=%q} %g\n", op.Method, op.Total.Seconds())
+		fmt.Fprintf(&b, "otter_store_operation_duration_seconds_count{method=%q} %d\n", op.Method, cumulative)
+	}
+	b.WriteString("# HELP otter_store_operation_errors_total Store operations failed, other than for a missing record, by method.\n")
+	b.WriteString("# TYPE otter_store_operation_errors_total counter\n")
+	for _, op := range ops {
+		fmt.Fprintf(&b, "otter_store_operation_errors_total{method=%q} %d\n", op.Method, op.Errors)
+	}
+	b.WriteString("# HELP otter_store_operations_in_flight Store operations in progress by method.\n")
+	b.WriteString("# TYPE otter_store_operations_in_flight gauge\n")
+	for _, op := range ops {
+		fmt.Fprintf(&b, "otter_store_operations_in_flight{method=%q} %d\n", op.Method, op.InFlight)
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// watchTarget is one key of a batch watch request. Version is the version
+// the client already holds: 0 if unknown, -1 if it knows the key is deleted.
+// A wildcard key watches the whole group, and a wildcard group and key the
+// whole namespace.
+type watchTarget struct {
+	Namespace string `json:"namespace" binding:"required"`
+	Group     string `json:"group" binding:"required"`
+	Key       string `json:"key" binding:"required"`
+	Version   int64  `json:"version"`
+}
+
+// watchWildcard matches any group or key of a watch target
+const watchWildcard = "*"
+
+// watchKey returns the registry key of the target, reporting false for a
+// wildcard group with a concrete key, which cannot be watched
+func (t watchTarget) watchKey() (watchKey, bool) {
+	switch {
+	case t.Group == watchWildcard && t.Key == watchWildcard:
+		return watchKey{t.Namespace, "", ""}, true
+	case t.Group == watchWildcard:
+		return watchKey{}, false
+	case t.Key == watchWildcard:
+		return watchKey{t.Namespace, t.Group, ""}, true
+	}
+	return watchKey{t.Namespace, t.Group, t.Key}, true
+}
+
+// watchBatchHandler long-polls many keys at once so a client can multiplex
+// all its watches over one connection. Keys whose version differs from the
+// one the client holds are returned straight away; otherwise the first
+// change to any key ends the poll, which is held for 30s or the ?wait= asked
+// for.
+func (s *Server) watchBatchHandler(c *gin.Context) {
+	var req struct {
+		Keys []watchTarget `json:"keys" binding:"required,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	wait, err := s.watchWait(c.Query("wait"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wait, want a positive duration such as 55s"})
+		return
+	}
+	if len(req.Keys) > maxBatchKeys {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d keys per batch", maxBatchKeys)})
+		return
+	}
+
+	info := s.subscriberInfo(c)
+	scopes := tokenScopes(c)
+	keys := make([]watchKey, len(req.Keys))
+	for i, t := range req.Keys {
+		wk, ok := t.watchKey()
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A wildcard group requires a wildcard key"})
+			return
+		}
+		if !scopes.allowsTarget(t) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Token is not allowed to access this resource"})
+			return
+		}
+		keys[i] = wk
+		if wk.key != "" {
+			s.propagation.Seen(tenantNamespace(c, t.Namespace), t.Group, t.Key, info)
+			s.access.Record(t.Namespace, t.Group, t.Key, info)
+		}
+	}
+
+	// Subscribe before comparing versions so no change slips in between
+	sub := s.watcher.subscribe(keys, info)
+	defer s.watcher.Unsubscribe(sub)
+	defer s.holdLongPoll()()
+	holdStart := time.Now()
+
+	changes, err := s.staleTargets(c.Request.Context(), req.Keys, info.selectorLabels())
+	if err != nil {
+		s.logger.Error("Failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(changes) > 0 {
+		c.JSON(http.StatusOK, gin.H{"changes": changes})
+		return
+	}
+
+	select {
+	case <-sub.Ready():
+		// Return every change queued by now, not just the first
+		c.Set(holdDurationKey, time.Since(holdStart))
+		events, err := s.variantEvents(c.Request.Context(), info.selectorLabels(), sub.drain())
+		if err != nil {
+			s.logger.Error("Failed to select config variant", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"changes": events})
+	case <-sub.Done():
+		// Subscription was force-expired by an admin
+		c.Set(holdDurationKey, time.Since(holdStart))
+		c.Status(http.StatusNotModified)
+	case <-time.After(wait):
+		c.Set(holdDurationKey, time.Since(holdStart))
+		c.Status(http.StatusNotModified)
+	case <-c.Request.Context().Done():
+		c.Set(holdDurationKey, time.Since(holdStart))
+	}
+}
+
+// staleTargets returns a change event for every target whose known version
+// is out of date, with deleted keys reported as DELETE events of version -1.
+// Changes carry the variants selected by labels.
+func (s *Server) staleTargets(ctx context.Context, targets []watchTarget, labels map[string]string) ([]*model.ConfigEvent, error) {
+	var changes []*model.ConfigEvent
+	for _, t := range targets {
+		if t.Version == 0 || t.Key == watchWildcard {
+			continue
+		}
+		cfg, err := s.store.Get(ctx, t.Namespace, t.Group, t.Key)
+		if err == store.ErrNotFound {
+			if t.Version != -1 {
+				changes = append(changes, &model.ConfigEvent{
+					Type:   model.EventDelete,
+					Config: s.signed(&model.Config{Namespace: t.Namespace, Group: t.Group, Key: t.Key, Version: -1}),
+				})
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Version != t.Version {
+			if cfg, err = s.variantConfig(ctx, labels, cfg); err != nil {
+				return nil, err
+			}
+			changes = append(changes, &model.ConfigEvent{Type: model.EventPut, Config: s.signed(cfg)})
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// NotifyBus carries config change events between server instances, so a
+// watch held by one instance sees writes made through another behind a
+// load balancer
+type NotifyBus interface {
+	// Publish sends a change event to every instance
+	Publish(ctx context.Context, event []byte) error
+	// Subscribe calls handler with every event published by any instance
+	// until ctx is cancelled
+	Subscribe(ctx context.Context, handler func(event []byte)) error
+	Close() error
+}
+
+// busEvent is the message exchanged over a NotifyBus. Origin identifies the
+// publishing instance, which has already notified its own watchers, and
+// Tenant the tenant whose config changed.
+type busEvent struct {
+	Origin string             `json:"origin"`
+	Tenant string             `json:"tenant,omitempty"`
+	Event  *model.ConfigEvent `json:"event"`
+}
+
+// redisChannel is the Redis pub/sub channel change events are published on
+const redisChannel = "otter:config-changes"
+
+// RedisBus is a NotifyBus backed by Redis pub/sub
+type RedisBus struct {
+	client *redis.Client
+}
+
+// NewNotifyBus creates the bus for a --notify-bus URL. Only redis:// and
+// rediss:// URLs are supported.
+func NewNotifyBus(url string) (NotifyBus, error) {
+	if strings.HasPrefix(url, "redis://") || strings.HasPrefix(url, "rediss://") {
+		return NewRedisBus(url)
+	}
+	return nil, fmt.Errorf("unsupported notify bus %q, expected redis://", url)
+}
+
+// NewRedisBus connects to the Redis server at url, e.g.
+// redis://:password@localhost:6379/0
+func NewRedisBus(url string) (*RedisBus, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBus{client: redis.NewClient(opts)}, nil
+}
+
+func (b *RedisBus) Publish(ctx context.Context, event []byte) error {
+	return b.client.Publish(ctx, redisChannel, event).Err()
+}
+
+// Subscribe receives events until ctx is cancelled. The Redis client
+// reconnects and resubscribes by itself when the connection drops.
+func (b *RedisBus) Subscribe(ctx context.Context, handler func(event []byte)) error {
+	pubsub := b.client.Subscribe(ctx, redisChannel)
+	defer pubsub.Close()
+
+	// Wait for the subscription to be confirmed so connection errors surface
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			handler([]byte(msg.Payload))
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *RedisBus) Close() error {
+	return b.client.Close()
+}
+
+// SetNotifyBus makes the server share change events with other instances
+// through bus, delivering events from other instances to local watchers
+// until ctx is cancelled
+func (s *Server) SetNotifyBus(ctx context.Context, bus NotifyBus) {
+	s.bus = bus
+	go func() {
+		err := bus.Subscribe(ctx, func(data []byte) {
+			var event busEvent
+			if err := json.Unmarshal(data, &event); err != nil || event.Event == nil || event.Event.Config == nil {
+				s.logger.Warn("Ignoring malformed notify bus event", zap.Error(err))
+				return
+			}
+			if event.Origin != s.instanceID {
+				s.watcher.Notify(event.Tenant, s.signedEvent(event.Event))
+			}
+		})
+		if err != nil {
+			s.logger.Error("Notify bus subscription failed", zap.Error(err))
+		}
+	}()
+}
+
+// notify delivers a change of the given event type to local watchers and
+// publishes it to the other instances if a notify bus is configured
+func (s *Server) notify(ctx context.Context, eventType string, cfg *model.Config) {
+	event := &model.ConfigEvent{Type: eventType, Config: cfg}
+	tenant := store.TenantFrom(ctx)
+	s.watcher.Notify(tenant, s.signedEvent(event))
+	if s.bus == nil {
+		return
+	}
+	data, err := json.Marshal(busEvent{Origin: s.instanceID, Tenant: tenant, Event: event})
+	if err != nil {
+		s.logger.Error("Failed to encode notify bus event", zap.Error(err))
+		return
+	}
+	if err := s.bus.Publish(context.Background(), data); err != nil {
+		s.logger.Error("Failed to publish change to notify bus",
+			zap.String("namespace", cfg.Namespace),
+			zap.String("group", cfg.Group),
+			zap.String("key", cfg.Key),
+			zap.Error(err))
+	}
+}
+
+// newInstanceID returns a random identifier for this server process
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// overrideTarget returns the name and selector of the override variant
+// targeted at a client instance by client ID, or by IP if clientID is empty
+func overrideTarget(clientID, ip string) (string, map[string]string) {
+	if clientID != "" {
+		return reservedPrefix + "client." + clientID, map[string]string{clientIDLabel: clientID}
+	}
+	return reservedPrefix + "ip." + ip, map[string]string{clientIPLabel: ip}
+}
+
+// listOverridesHandler returns the overrides of a config ordered by name
+func (s *Server) listOverridesHandler(c *gin.Context) {
+	variants, err := s.store.ListVariants(c.Request.Context(), c.Param("namespace"), c.Param("group"), c.Param("key"))
+	if err != nil {
+		s.logger.Error("Failed to list variants", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	overrides := []*model.ConfigVariant{}
+	for _, v := range variants {
+		if isOverride(v) {
+			overrides = append(overrides, v)
+		}
+	}
+	c.JSON(http.StatusOK, overrides)
+}
+
+// setOverrideHandler overrides the value of a config for a single client
+// instance, identified by client ID or IP, leaving the rest of the fleet
+// alone. The instance must have been seen fetching or watching the config
+// recently. An override takes precedence over every other variant.
+func (s *Server) setOverrideHandler(c *gin.Context) {
+	namespace, group, key := c.Param("namespace"), c.Param("group"), c.Param("key")
+	var req struct {
+		ClientID string `json:"client_id"`
+		IP       string `json:"ip"`
+		Value    string `json:"value" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.ClientID == "") == (req.IP == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, a value and either client_id or ip are required"})
+		return
+	}
+	if _, ok := s.propagation.Registered(tenantNamespace(c, namespace), group, key, req.ClientID, req.IP); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No client instance registered for this config matches the target"})
+		return
+	}
+
+	name, selector := overrideTarget(req.ClientID, req.IP)
+	s.putVariant(c, &model.ConfigVariant{
+		Namespace: namespace,
+		Group:     group,
+		Key:       key,
+		Name:      name,
+		Selector:  selector,
+		Value:     req.Value,
+		UpdatedBy: c.GetString("username"),
+		UpdatedAt: time.Now(),
+	})
+}
+
+// deleteOverrideHandler removes the override targeted at the client
+// instance given by the client_id or ip query parameter
+func (s *Server) deleteOverrideHandler(c *gin.Context) {
+	clientID, ip := c.Query("client_id"), c.Query("ip")
+	if (clientID == "") == (ip == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either client_id or ip is required"})
+		return
+	}
+	name, _ := overrideTarget(clientID, ip)
+	s.removeVariant(c, name, "Override not found")
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestOverrideConfig tests that an override is served to the instance it
+// targets before any variant, and that clients cannot claim the labels
+// identifying another instance
+func TestOverrideConfig(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore()), propagation: NewPropagationTracker()}
+	ctx := context.Background()
+	cfg := &model.Config{Namespace: "public", Group: "app", Key: "log_level", Value: "info", Type: "text"}
+	if err := s.store.Put(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	name, selector := overrideTarget("pod-7", "")
+	for _, v := range []*model.ConfigVariant{
+		{Name: "eu-canary", Selector: map[string]string{"region": "eu", "tier": "canary"}, Value: "warn"},
+		{Name: name, Selector: selector, Value: "debug"},
+	} {
+		v.Namespace, v.Group, v.Key = "public", "app", "log_level"
+		if err := s.store.SetVariant(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		info  SubscriberInfo
+		value string
+	}{
+		{SubscriberInfo{ClientID: "pod-7", IP: "10.0.0.7", Labels: parseLabels("region=eu,tier=canary")}, "debug"},
+		{SubscriberInfo{ClientID: "pod-8", IP: "10.0.0.8", Labels: parseLabels("region=eu,tier=canary")}, "warn"},
+		{SubscriberInfo{ClientID: "pod-8", IP: "10.0.0.8", Labels: parseLabels("otter.client_id=pod-7")}, "info"},
+	}
+	for _, tt := range tests {
+		served, err := s.variantConfig(ctx, tt.info.selectorLabels(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if served.Value != tt.value {
+			t.Errorf("client %s with labels %v served %q, want %q", tt.info.ClientID, tt.info.Labels, served.Value, tt.value)
+		}
+	}
+
+	s.propagation.Seen("public", "app", "log_level", SubscriberInfo{ClientID: "pod-7", IP: "10.0.0.7"})
+	if _, ok := s.propagation.Registered("public", "app", "log_level", "", "10.0.0.7"); !ok {
+		t.Error("instance seen fetching the config not registered by IP")
+	}
+	if _, ok := s.propagation.Registered("public", "app", "log_level", "pod-8", ""); ok {
+		t.Error("instance never seen registered")
+	}
+}
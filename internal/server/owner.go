@@ -0,0 +1,353 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// defaultNotificationLimit is how many notifications are listed unless the
+// request sets limit
+const defaultNotificationLimit = 50
+
+// ownerError describes a change rejected because the user does not own the
+// config
+type ownerError struct {
+	owner *model.ConfigOwner
+}
+
+func (e *ownerError) Error() string {
+	if e.owner.Key == "" {
+		return fmt.Sprintf("group %s/%s is owned by %s", e.owner.Namespace, e.owner.Group, ownerName(e.owner))
+	}
+	return fmt.Sprintf("config %s/%s/%s is owned by %s", e.owner.Namespace, e.owner.Group, e.owner.Key, ownerName(e.owner))
+}
+
+// ownerName describes the user or team of an owner
+func ownerName(owner *model.ConfigOwner) string {
+	if owner.Team != "" {
+		return "team " + owner.Team
+	}
+	return "user " + owner.User
+}
+
+// isAdmin reports whether username has the admin role
+func (s *Server) isAdmin(ctx context.Context, username string) (bool, error) {
+	user, err := s.store.GetUser(ctx, username)
+	if err == store.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Role == "admin", nil
+}
+
+// configOwner returns the owner of a config: the owner of its key, or else
+// the owner of its group. It returns store.ErrNotFound if it has neither.
+func (s *Server) configOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	owner, err := s.store.GetOwner(ctx, namespace, group, key)
+	if err == store.ErrNotFound && key != "" {
+		owner, err = s.store.GetOwner(ctx, namespace, group, "")
+	}
+	return owner, err
+}
+
+// ownerMembers returns the users an owner stands for. A deleted team stands
+// for no one.
+func (s *Server) ownerMembers(ctx context.Context, owner *model.ConfigOwner) ([]string, error) {
+	if owner.Team == "" {
+		return []string{owner.User}, nil
+	}
+	team, err := s.store.GetTeam(ctx, owner.Team)
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return team.Members, nil
+}
+
+// checkOwner verifies that username may change a config: it has no owner,
+// username is its owner or a member of its owning team, or username is an
+// admin. It returns an *ownerError when the change must be rejected.
+func (s *Server) checkOwner(ctx context.Context, namespace, group, key, username string) error {
+	owner, err := s.configOwner(ctx, namespace, group, key)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	members, err := s.ownerMembers(ctx, owner)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(members, username) {
+		return nil
+	}
+	admin, err := s.isAdmin(ctx, username)
+	if err != nil {
+		return err
+	}
+	if admin {
+		return nil
+	}
+	return &ownerError{owner: owner}
+}
+
+// respondOwnerError writes the response for a failed ownership check
+func (s *Server) respondOwnerError(c *gin.Context, err error) {
+	if oe, ok := err.(*ownerError); ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": oe.Error(), "owner": oe.owner})
+		return
+	}
+	s.logger.Error("Failed to check config owner", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// notifyOwners leaves a notification for every owner of a config other
+// than actor, who just changed it. Failures are logged, the change having
+// been made already.
+func (s *Server) notifyOwners(ctx context.Context, namespace, group, key string, version int64, opType, actor string) {
+	owner, err := s.configOwner(ctx, namespace, group, key)
+	if err == store.ErrNotFound {
+		return
+	}
+	var members []string
+	if err == nil {
+		members, err = s.ownerMembers(ctx, owner)
+	}
+	if err != nil {
+		s.logger.Warn("Failed to find config owners to notify", zap.Error(err))
+		return
+	}
+	for _, member := range members {
+		if member == actor {
+			continue
+		}
+		notification := &model.Notification{
+			Username:  member,
+			Namespace: namespace,
+			Group:     group,
+			Key:       key,
+			Version:   version,
+			OpType:    opType,
+			Actor:     actor,
+			CreatedAt: time.Now(),
+		}
+		if err := s.store.CreateNotification(ctx, notification); err != nil {
+			s.logger.Warn("Failed to notify config owner", zap.String("owner", member), zap.Error(err))
+		}
+	}
+}
+
+// listOwnersHandler returns the owners of groups and keys in a namespace
+func (s *Server) listOwnersHandler(c *gin.Context) {
+	owners, err := s.store.ListOwners(c.Request.Context(), c.Param("namespace"))
+	if err != nil {
+		s.logger.Error("Failed to list owners", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, owners)
+}
+
+// setGroupOwnerHandler assigns a group to a user or team
+func (s *Server) setGroupOwnerHandler(c *gin.Context) {
+	s.setOwner(c, c.Param("namespace"), c.Param("group"), "")
+}
+
+// deleteGroupOwnerHandler removes the owner of a group
+func (s *Server) deleteGroupOwnerHandler(c *gin.Context) {
+	s.deleteOwner(c, c.Param("namespace"), c.Param("group"), "")
+}
+
+// setConfigOwnerHandler assigns a config key to a user or team
+func (s *Server) setConfigOwnerHandler(c *gin.Context) {
+	s.setOwner(c, c.Param("namespace"), c.Param("group"), c.Param("key"))
+}
+
+// deleteConfigOwnerHandler removes the owner of a config key
+func (s *Server) deleteConfigOwnerHandler(c *gin.Context) {
+	s.deleteOwner(c, c.Param("namespace"), c.Param("group"), c.Param("key"))
+}
+
+// setOwner creates or replaces the owner of a group or key
+func (s *Server) setOwner(c *gin.Context, namespace, group, key string) {
+	var req struct {
+		User string `json:"user"`
+		Team string `json:"team"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.User == "") == (req.Team == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, expected a user or a team"})
+		return
+	}
+
+	var err error
+	if req.User != "" {
+		_, err = s.store.GetUser(c.Request.Context(), req.User)
+	} else {
+		_, err = s.store.GetTeam(c.Request.Context(), req.Team)
+	}
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Owner not found"})
+		return
+	} else if err != nil {
+		s.logger.Error("Failed to get owner", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	owner := &model.ConfigOwner{
+		Namespace:  namespace,
+		Group:      group,
+		Key:        key,
+		User:       req.User,
+		Team:       req.Team,
+		AssignedBy: c.GetString("username"),
+		AssignedAt: time.Now(),
+	}
+	if err := s.store.SetOwner(c.Request.Context(), owner); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Namespace not found"})
+			return
+		}
+		s.logger.Error("Failed to set owner", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, owner)
+}
+
+// deleteOwner removes the owner of a group or key
+func (s *Server) deleteOwner(c *gin.Context, namespace, group, key string) {
+	if err := s.store.DeleteOwner(c.Request.Context(), namespace, group, key); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Owner not found"})
+			return
+		}
+		s.logger.Error("Failed to delete owner", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// listTeamsHandler returns every team
+func (s *Server) listTeamsHandler(c *gin.Context) {
+	teams, err := s.store.ListTeams(c.Request.Context())
+	if err != nil {
+		s.logger.Error("Failed to list teams", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, teams)
+}
+
+// setTeamHandler creates a team or replaces its members
+func (s *Server) setTeamHandler(c *gin.Context) {
+	name := c.Param("team")
+	var req struct {
+		Members []string `json:"members" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !validUsername(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	for _, member := range req.Members {
+		if _, err := s.store.GetUser(c.Request.Context(), member); err != nil {
+			if err == store.ErrNotFound {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "User " + member + " not found"})
+				return
+			}
+			s.logger.Error("Failed to get user", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	team := &model.Team{
+		Name:      name,
+		Members:   req.Members,
+		UpdatedBy: c.GetString("username"),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.store.SetTeam(c.Request.Context(), team); err != nil {
+		s.logger.Error("Failed to set team", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, team)
+}
+
+// deleteTeamHandler deletes a team. Configs it owned stay owned by it and
+// can then only be changed by admins.
+func (s *Server) deleteTeamHandler(c *gin.Context) {
+	if err := s.store.DeleteTeam(c.Request.Context(), c.Param("team")); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+			return
+		}
+		s.logger.Error("Failed to delete team", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// listNotificationsHandler returns the logged-in user's notifications,
+// newest first. ?unread=true leaves out those already read.
+func (s *Server) listNotificationsHandler(c *gin.Context) {
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+	limit := defaultNotificationLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+	notifications, err := s.store.ListNotifications(c.Request.Context(), c.GetString("username"), unreadOnly, limit)
+	if err != nil {
+		s.logger.Error("Failed to list notifications", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+// readNotificationsHandler marks the logged-in user's notifications up to
+// and including up_to as read, or all of them without up_to
+func (s *Server) readNotificationsHandler(c *gin.Context) {
+	var req struct {
+		UpTo int64 `json:"up_to"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	if req.UpTo <= 0 {
+		req.UpTo = math.MaxInt64
+	}
+	if err := s.store.MarkNotificationsRead(c.Request.Context(), c.GetString("username"), req.UpTo); err != nil {
+		s.logger.Error("Failed to mark notifications read", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
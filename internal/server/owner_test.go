@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestConfigOwners tests that only a config's owners and admins may change
+// it, that a key owner takes precedence over its group's owner, and that
+// owners are notified of changes by others but not of their own
+func TestConfigOwners(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore())}
+	ctx := context.Background()
+	for _, u := range []*model.User{{Username: "root", Role: "admin"}, {Username: "alice", Role: "user"}, {Username: "bob", Role: "user"}, {Username: "carol", Role: "user"}} {
+		if err := s.store.CreateUser(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.store.SetTeam(ctx, &model.Team{Name: "payments", Members: []string{"alice", "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.SetOwner(ctx, &model.ConfigOwner{Namespace: "public", Group: "billing", Team: "payments"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.SetOwner(ctx, &model.ConfigOwner{Namespace: "public", Group: "billing", Key: "rate", User: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		key, user string
+		allowed   bool
+	}{
+		{"currency", "bob", true},
+		{"currency", "carol", false},
+		{"currency", "root", true},
+		{"rate", "carol", true},
+		{"rate", "alice", false},
+	} {
+		err := s.checkOwner(ctx, "public", "billing", tc.key, tc.user)
+		if _, denied := err.(*ownerError); err != nil && !denied {
+			t.Fatalf("checkOwner failed: %v", err)
+		}
+		if (err == nil) != tc.allowed {
+			t.Errorf("%s changing %s: allowed %v, want %v", tc.user, tc.key, err == nil, tc.allowed)
+		}
+	}
+	if err := s.checkOwner(ctx, "public", "other", "key", "carol"); err != nil {
+		t.Errorf("unowned config denied: %v", err)
+	}
+
+	s.notifyOwners(ctx, "public", "billing", "currency", 3, "UPDATE", "bob")
+	if n, _ := s.store.ListNotifications(ctx, "bob", false, 0); len(n) != 0 {
+		t.Errorf("bob was notified of his own change: %+v", n)
+	}
+	n, err := s.store.ListNotifications(ctx, "alice", true, 0)
+	if err != nil || len(n) != 1 || n[0].Actor != "bob" || n[0].Version != 3 {
+		t.Fatalf("alice's notifications = %+v, %v, want bob's change", n, err)
+	}
+	if err := s.store.MarkNotificationsRead(ctx, "alice", n[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.store.ListNotifications(ctx, "alice", true, 0); len(n) != 0 {
+		t.Errorf("unread notifications after marking read: %+v", n)
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// clientStaleAfter is how long a client may go unseen before it no longer
+// counts towards a config's propagation status. Watching clients re-poll at
+// least every minute unless the maximum watch wait is raised, so this
+// leaves plenty of headroom.
+const clientStaleAfter = 5 * time.Minute
+
+const (
+	// propagationSweepInterval is how often Seen and Ack drop the clients
+	// that have gone stale on every config, not just the one they touch
+	propagationSweepInterval = time.Minute
+	// maxClientsPerConfig bounds the clients tracked for one config, so
+	// clients rotating their IDs cannot grow the tracker without limit
+	maxClientsPerConfig = 1000
+)
+
+// ClientAck records the latest version of a config a client has acknowledged
+type ClientAck struct {
+	ClientID   string    `json:"client_id"`
+	Username   string    `json:"username,omitempty"`
+	IP         string    `json:"ip"`
+	Version    int64     `json:"version"`
+	AckedAt    time.Time `json:"acked_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// PropagationStatus reports how many registered clients hold the current version of a config
+type PropagationStatus struct {
+	Namespace    string      `json:"namespace"`
+	Group        string      `json:"group"`
+	Key          string      `json:"key"`
+	Version      int64       `json:"version"`
+	TotalClients int         `json:"total_clients"`
+	Acknowledged int         `json:"acknowledged"`
+	Percent      float64     `json:"percent"`
+	Complete     bool        `json:"complete"`
+	AckedClients []ClientAck `json:"acked_clients"`
+	Pending      []ClientAck `json:"pending_clients"`
+}
+
+// PropagationTracker keeps track of which clients hold which version of each config
+type PropagationTracker struct {
+	mu      sync.Mutex
+	clients map[watchKey]map[string]*ClientAck // key: client ID
+	swept   time.Time
+}
+
+func NewPropagationTracker() *PropagationTracker {
+	return &PropagationTracker{clients: make(map[watchKey]map[string]*ClientAck)}
+}
+
+// Seen registers a client as a consumer of a config without changing its acknowledged version
+func (t *PropagationTracker) Seen(namespace, group, key string, info SubscriberInfo) {
+	if info.ClientID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ack := t.entry(watchKey{namespace, group, key}, info)
+	ack.LastSeenAt = time.Now()
+}
+
+// Ack records that a client has applied the given version of a config
+func (t *PropagationTracker) Ack(namespace, group, key string, info SubscriberInfo, version int64) {
+	if info.ClientID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ack := t.entry(watchKey{namespace, group, key}, info)
+	now := time.Now()
+	ack.LastSeenAt = now
+	if version >= ack.Version {
+		ack.Version = version
+		ack.AckedAt = now
+	}
+}
+
+// entry returns the ack record for a client, creating it if needed and
+// making room for it by forgetting the client of the config seen longest
+// ago. Callers must hold t.mu.
+func (t *PropagationTracker) entry(wk watchKey, info SubscriberInfo) *ClientAck {
+	if now := time.Now(); now.Sub(t.swept) >= propagationSweepInterval {
+		t.sweep(now)
+	}
+	clients, ok := t.clients[wk]
+	if !ok {
+		clients = make(map[string]*ClientAck)
+		t.clients[wk] = clients
+	}
+	ack, ok := clients[info.ClientID]
+	if !ok {
+		if len(clients) >= maxClientsPerConfig {
+			var oldest *ClientAck
+			for _, a := range clients {
+				if oldest == nil || a.LastSeenAt.Before(oldest.LastSeenAt) {
+					oldest = a
+				}
+			}
+			delete(clients, oldest.ClientID)
+		}
+		ack = &ClientAck{ClientID: info.ClientID}
+		clients[info.ClientID] = ack
+	}
+	ack.Username = info.Username
+	ack.IP = info.IP
+	return ack
+}
+
+// sweep forgets the clients not seen for clientStaleAfter on every config.
+// Callers must hold t.mu.
+func (t *PropagationTracker) sweep(now time.Time) {
+	t.swept = now
+	cutoff := now.Add(-clientStaleAfter)
+	for wk, clients := range t.clients {
+		for id, ack := range clients {
+			if ack.LastSeenAt.Before(cutoff) {
+				delete(clients, id)
+			}
+		}
+		if len(clients) == 0 {
+			delete(t.clients, wk)
+		}
+	}
+}
+
+// Status reports propagation of the given version across clients seen recently
+func (t *PropagationTracker) Status(namespace, group, key string, version int64) PropagationStatus {
+	status := PropagationStatus{
+		Namespace:    namespace,
+		Group:        group,
+		Key:          key,
+		Version:      version,
+		AckedClients: []ClientAck{},
+		Pending:      []ClientAck{},
+	}
+
+	wk := watchKey{namespace, group, key}
+	cutoff := time.Now().Add(-clientStaleAfter)
+
+	t.mu.Lock()
+	for id, ack := range t.clients[wk] {
+		if ack.LastSeenAt.Before(cutoff) {
+			// Forget clients that have gone away
+			delete(t.clients[wk], id)
+			continue
+		}
+		status.TotalClients++
+		if ack.Version >= version {
+			status.Acknowledged++
+			status.AckedClients = append(status.AckedClients, *ack)
+		} else {
+			status.Pending = append(status.Pending, *ack)
+		}
+	}
+	if len(t.clients[wk]) == 0 {
+		delete(t.clients, wk)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(status.AckedClients, func(i, j int) bool { return status.AckedClients[i].ClientID < status.AckedClients[j].ClientID })
+	sort.Slice(status.Pending, func(i, j int) bool { return status.Pending[i].ClientID < status.Pending[j].ClientID })
+
+	if status.TotalClients > 0 {
+		status.Percent = float64(status.Acknowledged) / float64(status.TotalClients) * 100
+	}
+	status.Complete = status.Acknowledged == status.TotalClients
+	return status
+}
+
+// Registered returns the client seen recently for a config with clientID,
+// or with ip if clientID is empty
+func (t *PropagationTracker) Registered(namespace, group, key, clientID, ip string) (ClientAck, bool) {
+	cutoff := time.Now().Add(-clientStaleAfter)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ack := range t.clients[watchKey{namespace, group, key}] {
+		if ack.LastSeenAt.Before(cutoff) {
+			continue
+		}
+		if (clientID != "" && ack.ClientID == clientID) || (clientID == "" && ack.IP == ip) {
+			return *ack, true
+		}
+	}
+	return ClientAck{}, false
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPropagationTrackerForgetsStaleClients tests that clients gone stale on
+// a config are forgotten while other configs are watched, without anyone
+// asking for the config's propagation status
+func TestPropagationTrackerForgetsStaleClients(t *testing.T) {
+	tracker := NewPropagationTracker()
+	tracker.Seen("public", "app", "timeout", SubscriberInfo{ClientID: "gone", IP: "10.0.0.1"})
+	tracker.clients[watchKey{"public", "app", "timeout"}]["gone"].LastSeenAt = time.Now().Add(-clientStaleAfter - time.Second)
+	tracker.swept = time.Now().Add(-propagationSweepInterval)
+
+	tracker.Seen("public", "app", "retries", SubscriberInfo{ClientID: "live", IP: "10.0.0.2"})
+	if _, ok := tracker.clients[watchKey{"public", "app", "timeout"}]; ok {
+		t.Error("stale client of an unqueried config is still tracked")
+	}
+	if _, ok := tracker.clients[watchKey{"public", "app", "retries"}]["live"]; !ok {
+		t.Error("client seen just now was forgotten")
+	}
+}
+
+// TestPropagationTrackerClientLimit tests that a config tracks at most
+// maxClientsPerConfig clients, forgetting the one seen longest ago
+func TestPropagationTrackerClientLimit(t *testing.T) {
+	tracker := NewPropagationTracker()
+	wk := watchKey{"public", "app", "timeout"}
+	for i := 0; i <= maxClientsPerConfig; i++ {
+		tracker.Seen("public", "app", "timeout", SubscriberInfo{ClientID: fmt.Sprint("client-", i)})
+		if i == 0 {
+			tracker.clients[wk]["client-0"].LastSeenAt = time.Now().Add(-time.Minute)
+		}
+	}
+	if n := len(tracker.clients[wk]); n != maxClientsPerConfig {
+		t.Errorf("tracking %d clients, want %d", n, maxClientsPerConfig)
+	}
+	if _, ok := tracker.clients[wk]["client-0"]; ok {
+		t.Error("client seen longest ago is still tracked")
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// quotaError describes a write rejected by a namespace quota
+type quotaError struct {
+	status  int
+	message string
+}
+
+func (e *quotaError) Error() string {
+	return e.message
+}
+
+// checkQuota verifies that writing config would keep its namespace within quota.
+// It returns a *quotaError when the write must be rejected.
+func (s *Server) checkQuota(ctx context.Context, config *model.Config) error {
+	quota, err := s.namespaceQuota(ctx, config.Namespace)
+	if err == store.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	valueBytes := int64(len(config.Value))
+	if quota.MaxValueBytes > 0 && valueBytes > quota.MaxValueBytes {
+		return &quotaError{
+			status:  http.StatusRequestEntityTooLarge,
+			message: fmt.Sprintf("value size %d bytes exceeds the namespace limit of %d bytes", valueBytes, quota.MaxValueBytes),
+		}
+	}
+
+	if quota.MaxConfigs <= 0 && quota.MaxTotalBytes <= 0 {
+		return nil
+	}
+
+	configs, totalBytes, err := s.store.NamespaceUsage(ctx, config.Namespace)
+	if err != nil {
+		return err
+	}
+
+	// An overwrite replaces the existing value instead of adding a config
+	existing, err := s.store.Get(ctx, config.Namespace, config.Group, config.Key)
+	switch {
+	case err == nil:
+		totalBytes -= int64(len(existing.Value))
+	case err == store.ErrNotFound:
+		configs++
+	default:
+		return err
+	}
+	totalBytes += valueBytes
+
+	if quota.MaxConfigs > 0 && configs > quota.MaxConfigs {
+		return &quotaError{
+			status:  http.StatusForbidden,
+			message: fmt.Sprintf("namespace %s has reached its limit of %d configs", config.Namespace, quota.MaxConfigs),
+		}
+	}
+	if quota.MaxTotalBytes > 0 && totalBytes > quota.MaxTotalBytes {
+		return &quotaError{
+			status:  http.StatusForbidden,
+			message: fmt.Sprintf("namespace %s would exceed its storage limit of %d bytes", config.Namespace, quota.MaxTotalBytes),
+		}
+	}
+	return nil
+}
+
+// namespaceQuota returns the quota of a namespace, or the default quota of
+// the settings file if it has none. It returns store.ErrNotFound if neither
+// is set.
+func (s *Server) namespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	quota, err := s.store.GetNamespaceQuota(ctx, namespace)
+	if err != store.ErrNotFound {
+		return quota, err
+	}
+	limits := s.currentSettings().DefaultQuota
+	if limits == nil {
+		return nil, store.ErrNotFound
+	}
+	return &model.NamespaceQuota{
+		Namespace:     namespace,
+		MaxConfigs:    limits.MaxConfigs,
+		MaxTotalBytes: limits.MaxTotalBytes,
+		MaxValueBytes: limits.MaxValueBytes,
+	}, nil
+}
+
+// respondQuotaError writes the response for a failed quota check
+func (s *Server) respondQuotaError(c *gin.Context, err error) {
+	if qe, ok := err.(*quotaError); ok {
+		c.JSON(qe.status, gin.H{"error": qe.message})
+		return
+	}
+	s.logger.Error("Failed to check namespace quota", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// getNamespaceQuotaHandler returns the quota and current usage of a
+// namespace, showing the default quota if it has none of its own
+func (s *Server) getNamespaceQuotaHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	quota, err := s.namespaceQuota(c.Request.Context(), namespace)
+	if err == store.ErrNotFound {
+		quota = &model.NamespaceQuota{Namespace: namespace}
+	} else if err != nil {
+		s.logger.Error("Failed to get namespace quota", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	configs, totalBytes, err := s.store.NamespaceUsage(c.Request.Context(), namespace)
+	if err != nil {
+		s.logger.Error("Failed to get namespace usage", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quota": quota,
+		"usage": gin.H{
+			"configs":     configs,
+			"total_bytes": totalBytes,
+		},
+	})
+}
+
+// setNamespaceQuotaHandler creates or replaces the quota of a namespace
+func (s *Server) setNamespaceQuotaHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+
+	var req struct {
+		MaxConfigs    int64 `json:"max_configs"`
+		MaxTotalBytes int64 `json:"max_total_bytes"`
+		MaxValueBytes int64 `json:"max_value_bytes"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if req.MaxConfigs < 0 || req.MaxTotalBytes < 0 || req.MaxValueBytes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quota limits cannot be negative"})
+		return
+	}
+
+	quota := &model.NamespaceQuota{
+		Namespace:     namespace,
+		MaxConfigs:    req.MaxConfigs,
+		MaxTotalBytes: req.MaxTotalBytes,
+		MaxValueBytes: req.MaxValueBytes,
+		UpdatedBy:     c.GetString("username"),
+		UpdatedAt:     time.Now(),
+	}
+
+	if err := s.store.SetNamespaceQuota(c.Request.Context(), quota); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Namespace not found"})
+			return
+		}
+		s.logger.Error("Failed to set namespace quota", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
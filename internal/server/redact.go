@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redacted replaces the value of a sensitive log field
+const redacted = "[REDACTED]"
+
+// requestBodyField is the only log field allowed to carry a request body
+const requestBodyField = "request_body"
+
+// maxLoggedBodyBytes caps how much of a request body is logged
+const maxLoggedBodyBytes = 64 << 10
+
+// sensitiveKeyParts mark log field and JSON keys whose values are never logged
+var sensitiveKeyParts = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "credential", "api_key", "apikey", "private_key"}
+
+// isSensitiveKey reports whether values under key must be redacted
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactingCore redacts sensitive fields before they reach the wrapped core,
+// so a careless log site cannot leak credentials. Request bodies are dropped
+// unless body logging is enabled.
+type redactingCore struct {
+	zapcore.Core
+	logBodies *atomic.Bool
+}
+
+// redactLogger wraps logger so every entry passes through a redactingCore
+func redactLogger(logger *zap.Logger, logBodies *atomic.Bool) *zap.Logger {
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core, logBodies: logBodies}
+	}))
+}
+
+func (r *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: r.Core.With(r.redact(fields)), logBodies: r.logBodies}
+}
+
+func (r *redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if r.Enabled(ent.Level) {
+		return ce.AddCore(ent, r)
+	}
+	return ce
+}
+
+func (r *redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return r.Core.Write(ent, r.redact(fields))
+}
+
+// redact returns fields with sensitive values replaced, copying only if
+// something had to change
+func (r *redactingCore) redact(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		replace := isSensitiveKey(f.Key) || (f.Key == requestBodyField && !r.logBodies.Load())
+		if !replace {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]zapcore.Field, 0, len(fields)), fields[:i]...)
+		}
+		out = append(out, zap.String(f.Key, redacted))
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// SetLogRequestBodies controls whether request bodies may be logged. It is
+// off by default; when on, each request body is logged with sensitive JSON
+// fields redacted, which is meant for debugging only.
+func (s *Server) SetLogRequestBodies(enabled bool) {
+	s.logBodies.Store(enabled)
+}
+
+// bodyLogMiddleware logs request bodies while body logging is enabled
+func (s *Server) bodyLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.logBodies.Load() || c.Request.Body == nil || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBodyBytes+1))
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+		if len(body) > maxLoggedBodyBytes {
+			body = body[:maxLoggedBodyBytes]
+		}
+		s.logger.Info("Request body", zap.String("method", c.Request.Method), zap.String("path", c.Request.URL.Path),
+			zap.String(requestBodyField, redactBody(body)))
+		c.Next()
+	}
+}
+
+// redactBody returns a JSON body with the values of sensitive keys replaced.
+// Bodies that are not JSON are withheld entirely, as their fields cannot be
+// told apart.
+func redactBody(body []byte) string {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return redacted
+	}
+	out, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return redacted
+	}
+	return string(out)
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactJSON(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestRedactLogger tests that sensitive fields are redacted, including those
+// attached with With, and that request bodies are dropped unless enabled
+func TestRedactLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	var logBodies atomic.Bool
+	logger := redactLogger(zap.New(core), &logBodies)
+
+	logger.With(zap.String("access_token", "t0k")).Info("login",
+		zap.String("username", "alice"), zap.String("password", "hunter2"), zap.String(requestBodyField, "{}"))
+	logBodies.Store(true)
+	logger.Info("body", zap.String(requestBodyField, redactBody([]byte(`{"user":{"Password":"hunter2"},"value":"x"}`))))
+
+	entries := logs.AllUntimed()
+	fields := entries[0].ContextMap()
+	for key, want := range map[string]string{"username": "alice", "password": redacted, "access_token": redacted, requestBodyField: redacted} {
+		if fields[key] != want {
+			t.Errorf("field %s = %v, want %s", key, fields[key], want)
+		}
+	}
+	if got, want := entries[1].ContextMap()[requestBodyField], `{"user":{"Password":"[REDACTED]"},"value":"x"}`; got != want {
+		t.Errorf("logged body %v, want %s", got, want)
+	}
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// DefaultScheduleInterval is how often the server looks for scheduled
+// changes that have fallen due
+const DefaultScheduleInterval = time.Second
+
+// StartScheduler publishes scheduled changes as they fall due, looking for
+// them every interval until ctx is cancelled. Several servers may share a
+// store: each change is claimed before it is published, so only one of them
+// publishes it.
+func (s *Server) StartScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.publishDueChanges(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// publishDueChanges publishes every pending change due now, in the tenant
+// that scheduled it. A change that cannot be published is kept as failed
+// with the reason, for its author to modify or cancel.
+func (s *Server) publishDueChanges(ctx context.Context) {
+	changes, err := s.store.ListDueScheduledChanges(ctx, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to list due scheduled changes", zap.Error(err))
+		return
+	}
+	for _, change := range changes {
+		tenant, namespace := store.SplitTenant(change.Namespace)
+		tctx := store.WithTenant(ctx, tenant)
+		change.Namespace = namespace
+
+		if err := s.store.ClaimScheduledChange(tctx, change.ID); err != nil {
+			if err != store.ErrNotFound {
+				s.logger.Error("Failed to claim scheduled change", zap.Int64("id", change.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		fields := []zap.Field{
+			zap.Int64("id", change.ID),
+			zap.String("tenant", tenant),
+			zap.String("namespace", change.Namespace),
+			zap.String("group", change.Group),
+			zap.String("key", change.Key),
+		}
+		if err := s.publishScheduledChange(tctx, change); err != nil {
+			s.logger.Warn("Failed to publish scheduled change", append(fields, zap.Error(err))...)
+			change.Status = model.ScheduleStatusFailed
+			change.Error = err.Error()
+			change.UpdatedAt = time.Now()
+			if err := s.store.UpdateScheduledChange(tctx, change); err != nil && err != store.ErrNotFound {
+				s.logger.Error("Failed to update scheduled change", append(fields, zap.Error(err))...)
+			}
+			continue
+		}
+		if err := s.store.DeleteScheduledChange(tctx, change.ID); err != nil && err != store.ErrNotFound {
+			s.logger.Error("Failed to delete scheduled change", append(fields, zap.Error(err))...)
+		}
+		s.logger.Info("Published scheduled change", fields...)
+	}
+}
+
+// publishScheduledChange writes a scheduled change as its author would
+// have at the time, subject to the locks, ownership and quota in force now
+func (s *Server) publishScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	if err := s.checkLock(ctx, change.Namespace, change.Group, change.Key); err != nil {
+		return err
+	}
+	if err := s.checkOwner(ctx, change.Namespace, change.Group, change.Key, change.CreatedBy); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	config := &model.Config{
+		Namespace:   change.Namespace,
+		Group:       change.Group,
+		Key:         change.Key,
+		Value:       change.Value,
+		Type:        change.Type,
+		ContentType: change.ContentType,
+		CreatedBy:   change.CreatedBy,
+		UpdatedBy:   change.CreatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.checkQuota(ctx, config); err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, config); err != nil {
+		return err
+	}
+
+	history := &model.ConfigHistory{
+		Namespace:   config.Namespace,
+		Group:       config.Group,
+		Key:         config.Key,
+		Value:       config.Value,
+		Type:        config.Type,
+		ContentType: config.ContentType,
+		Version:     config.Version,
+		OpType:      "UPDATE",
+		CreatedBy:   change.CreatedBy,
+		CreatedAt:   now,
+	}
+	_ = s.store.CreateHistory(ctx, history)
+
+	s.notify(ctx, model.EventPut, config)
+	s.notifyOwners(ctx, config.Namespace, config.Group, config.Key, config.Version, "UPDATE", change.CreatedBy)
+	s.publishChange(ctx, config.Namespace, config.Group, config.Key, config.Version, "UPDATE", change.CreatedBy)
+	return nil
+}
+
+// scheduleConfig stores config to be written at publishAt instead of now.
+// Only ownership is checked here; locks and quotas are checked when it is
+// published.
+func (s *Server) scheduleConfig(c *gin.Context, config *model.Config, publishAt time.Time) {
+	if !publishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+		return
+	}
+	if err := s.checkOwner(c.Request.Context(), config.Namespace, config.Group, config.Key, config.CreatedBy); err != nil {
+		s.respondOwnerError(c, err)
+		return
+	}
+
+	change := &model.ScheduledChange{
+		Namespace:   config.Namespace,
+		Group:       config.Group,
+		Key:         config.Key,
+		Value:       config.Value,
+		Type:        config.Type,
+		ContentType: config.ContentType,
+		PublishAt:   publishAt,
+		Status:      model.ScheduleStatusPending,
+		CreatedBy:   config.CreatedBy,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.store.CreateScheduledChange(c.Request.Context(), change); err != nil {
+		s.logger.Error("Failed to schedule config change", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Scheduled config change",
+		zap.Int64("id", change.ID),
+		zap.String("namespace", change.Namespace),
+		zap.String("group", change.Group),
+		zap.String("key", change.Key),
+		zap.Time("publish_at", change.PublishAt),
+		zap.String("operator", change.CreatedBy))
+	c.JSON(http.StatusAccepted, change)
+}
+
+// scheduledChange returns the scheduled change in the request's path,
+// having responded with an error if there is none in its namespace
+func (s *Server) scheduledChange(c *gin.Context) (*model.ScheduledChange, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled change ID"})
+		return nil, false
+	}
+	change, err := s.store.GetScheduledChange(c.Request.Context(), id)
+	if err == store.ErrNotFound || (err == nil && change.Namespace != c.Param("namespace")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled change not found"})
+		return nil, false
+	}
+	if err != nil {
+		s.logger.Error("Failed to get scheduled change", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return change, true
+}
+
+// listScheduledChangesHandler returns the scheduled changes in a namespace,
+// soonest first
+func (s *Server) listScheduledChangesHandler(c *gin.Context) {
+	changes, err := s.store.ListScheduledChanges(c.Request.Context(), c.Param("namespace"))
+	if err != nil {
+		s.logger.Error("Failed to list scheduled changes", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// getScheduledChangeHandler returns a scheduled change
+func (s *Server) getScheduledChangeHandler(c *gin.Context) {
+	if change, ok := s.scheduledChange(c); ok {
+		c.JSON(http.StatusOK, change)
+	}
+}
+
+// updateScheduledChangeHandler changes the value or publish time of a
+// pending or failed change. A failed change is scheduled again.
+func (s *Server) updateScheduledChangeHandler(c *gin.Context) {
+	var req struct {
+		Value       *string    `json:"value"`
+		Type        *string    `json:"type"`
+		ContentType *string    `json:"content_type"`
+		PublishAt   *time.Time `json:"publish_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	change, ok := s.scheduledChange(c)
+	if !ok {
+		return
+	}
+	if change.Status == model.ScheduleStatusPublishing {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scheduled change is being published"})
+		return
+	}
+	username := c.GetString("username")
+	if err := s.checkOwner(c.Request.Context(), change.Namespace, change.Group, change.Key, username); err != nil {
+		s.respondOwnerError(c, err)
+		return
+	}
+
+	if req.Value != nil {
+		change.Value = *req.Value
+	}
+	if req.Type != nil {
+		if !validConfigTypes[*req.Type] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid config type"})
+			return
+		}
+		change.Type = *req.Type
+		if change.Type == "" {
+			change.Type = "text"
+		}
+	}
+	if err := validateConfigValue(change.Type, change.Value); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	contentType := change.ContentType
+	if change.Type != "binary" {
+		contentType = ""
+	}
+	if req.ContentType != nil {
+		contentType = *req.ContentType
+	}
+	contentType, err := configContentType(change.Type, contentType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	change.ContentType = contentType
+	if req.PublishAt != nil {
+		change.PublishAt = *req.PublishAt
+	}
+	if !change.PublishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+		return
+	}
+
+	change.Status = model.ScheduleStatusPending
+	change.Error = ""
+	change.UpdatedAt = time.Now()
+	if err := s.store.UpdateScheduledChange(c.Request.Context(), change); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled change not found"})
+			return
+		}
+		s.logger.Error("Failed to update scheduled change", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Rescheduled config change",
+		zap.Int64("id", change.ID),
+		zap.Time("publish_at", change.PublishAt),
+		zap.String("operator", username))
+	c.JSON(http.StatusOK, change)
+}
+
+// cancelScheduledChangeHandler cancels a scheduled change. A change stuck
+// publishing, its server having stopped midway, can be cancelled too.
+func (s *Server) cancelScheduledChangeHandler(c *gin.Context) {
+	change, ok := s.scheduledChange(c)
+	if !ok {
+		return
+	}
+	username := c.GetString("username")
+	if err := s.checkOwner(c.Request.Context(), change.Namespace, change.Group, change.Key, username); err != nil {
+		s.respondOwnerError(c, err)
+		return
+	}
+	if err := s.store.DeleteScheduledChange(c.Request.Context(), change.ID); err != nil && err != store.ErrNotFound {
+		s.logger.Error("Failed to cancel scheduled change", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Cancelled scheduled config change",
+		zap.Int64("id", change.ID),
+		zap.String("namespace", change.Namespace),
+		zap.String("group", change.Group),
+		zap.String("key", change.Key),
+		zap.String("operator", username))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestPublishDueChanges tests that due changes are written in the tenant
+// that scheduled them and removed, that changes not yet due are left alone
+// and that a change rejected by a lock is kept as failed
+func TestPublishDueChanges(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore()), watcher: NewWatcher()}
+	ctx := context.Background()
+	acme := store.WithTenant(ctx, "acme")
+	schedule := func(ctx context.Context, key string, publishAt time.Time) *model.ScheduledChange {
+		change := &model.ScheduledChange{
+			Namespace: "public",
+			Group:     "app",
+			Key:       key,
+			Value:     "new",
+			Type:      "text",
+			PublishAt: publishAt,
+			Status:    model.ScheduleStatusPending,
+			CreatedBy: "alice",
+		}
+		if err := s.store.CreateScheduledChange(ctx, change); err != nil {
+			t.Fatal(err)
+		}
+		return change
+	}
+
+	due := schedule(acme, "timeout", time.Now().Add(-time.Second))
+	later := schedule(ctx, "timeout", time.Now().Add(time.Hour))
+	locked := schedule(ctx, "retries", time.Now().Add(-time.Second))
+	if err := s.store.SetLock(ctx, &model.ConfigLock{Namespace: "public", Group: "app", Key: "retries", Owner: "bob", Reason: "incident"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.publishDueChanges(ctx)
+
+	if cfg, err := s.store.Get(acme, "public", "app", "timeout"); err != nil || cfg.Value != "new" || cfg.UpdatedBy != "alice" {
+		t.Errorf("tenant config %+v, %v, want alice's new value", cfg, err)
+	}
+	if _, err := s.store.GetScheduledChange(acme, due.ID); err != store.ErrNotFound {
+		t.Errorf("published change still scheduled: %v", err)
+	}
+	if _, err := s.store.Get(ctx, "public", "app", "timeout"); err != store.ErrNotFound {
+		t.Errorf("change not yet due was published: %v", err)
+	}
+	if change, err := s.store.GetScheduledChange(ctx, later.ID); err != nil || change.Status != model.ScheduleStatusPending {
+		t.Errorf("change not yet due %+v, %v, want it pending", change, err)
+	}
+	if change, err := s.store.GetScheduledChange(ctx, locked.ID); err != nil || change.Status != model.ScheduleStatusFailed || change.Error == "" {
+		t.Errorf("locked change %+v, %v, want it failed with the reason", change, err)
+	}
+	if _, err := s.store.Get(ctx, "public", "app", "retries"); err != store.ErrNotFound {
+		t.Errorf("locked config was written: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+const (
+	// defaultScopedTokenTTL is how long a scoped token lives unless asked otherwise
+	defaultScopedTokenTTL = 90 * 24 * time.Hour
+	// maxScopedTokenTTL caps the lifetime of scoped tokens, and so how long a
+	// revoked one stays blacklisted
+	maxScopedTokenTTL = 365 * 24 * time.Hour
+)
+
+// TokenScope is a namespace and group a scoped token may read and watch. A
+// wildcard group covers every group of the namespace, including
+// namespace-wide watches and listings.
+type TokenScope struct {
+	Namespace string `json:"namespace" binding:"required"`
+	Group     string `json:"group" binding:"required"`
+}
+
+// TokenScopes restricts a scoped token to reading and watching configs in
+// the listed namespaces and groups. A nil TokenScopes is unrestricted.
+type TokenScopes []TokenScope
+
+// Allows reports whether the scopes cover a group, or the whole namespace
+// when group is empty
+func (ts TokenScopes) Allows(namespace, group string) bool {
+	if ts == nil {
+		return true
+	}
+	for _, scope := range ts {
+		if scope.Namespace != namespace {
+			continue
+		}
+		if scope.Group == watchWildcard || (group != "" && scope.Group == group) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsTarget reports whether the scopes cover a watch target
+func (ts TokenScopes) allowsTarget(t watchTarget) bool {
+	if t.Group == watchWildcard {
+		return ts.Allows(t.Namespace, "")
+	}
+	return ts.Allows(t.Namespace, t.Group)
+}
+
+// scopedRoutes are the read and watch routes open to scoped tokens, with
+// whether they address a group (true) or a whole namespace (false). The
+// batch watch and WebSocket routes check each key instead.
+var scopedRoutes = map[string]bool{
+	"GET /api/v1/namespaces/:namespace/groups/:group/configs":            true,
+	"GET /api/v1/namespaces/:namespace/groups/:group/configs/:key":       true,
+	"POST /api/v1/namespaces/:namespace/groups/:group/configs/batch":     true,
+	"GET /api/v1/namespaces/:namespace/groups/:group/configs/:key/watch": true,
+	"POST /api/v1/namespaces/:namespace/groups/:group/configs/:key/ack":  true,
+	"GET /api/v1/namespaces/:namespace/groups/:group/watch":              true,
+	"GET /api/v1/namespaces/:namespace/groups/:group/export":             true,
+	"GET /api/v1/namespaces/:namespace/watch":                            false,
+	"GET /api/v1/namespaces/:namespace/configs":                          false,
+	"GET /api/v1/namespaces/:namespace/changes":                          false,
+	"GET /api/v1/namespaces/:namespace/events":                           false,
+	"POST /api/v1/watch":   false,
+	"GET /api/v1/watch/ws": false,
+}
+
+// scopedTokenBlacklistKey is the blacklist entry that revokes a scoped token
+func scopedTokenBlacklistKey(id string) string {
+	return "token:" + id
+}
+
+// tokenScopes returns the scopes of the request's token, nil if unrestricted
+func tokenScopes(c *gin.Context) TokenScopes {
+	scopes, _ := c.Get("scopes")
+	ts, _ := scopes.(TokenScopes)
+	return ts
+}
+
+// scopeMiddleware limits scoped tokens to the read and watch routes of the
+// namespaces and groups they were minted for. It must run after
+// ginAuthMiddleware.
+func (s *Server) scopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes := tokenScopes(c)
+		if scopes == nil {
+			c.Next()
+			return
+		}
+		perGroup, ok := scopedRoutes[c.Request.Method+" "+c.FullPath()]
+		group := ""
+		if perGroup {
+			group = c.Param("group")
+		}
+		namespace := c.Param("namespace")
+		if !ok || (namespace != "" && !scopes.Allows(namespace, group)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token is not allowed to access this resource"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// createScopedTokenHandler mints a token that may only read and watch the
+// given namespaces and groups, for applications that must not write configs
+func (s *Server) createScopedTokenHandler(c *gin.Context) {
+	var req struct {
+		Name   string       `json:"name" binding:"required"`
+		Scopes []TokenScope `json:"scopes" binding:"required,min=1,dive"`
+		TTL    string       `json:"ttl"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	ttl := defaultScopedTokenTTL
+	if req.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > maxScopedTokenTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ttl, expected a duration of at most 8760h"})
+			return
+		}
+	}
+	for _, scope := range req.Scopes {
+		if scope.Namespace == watchWildcard || strings.ContainsAny(scope.Namespace+scope.Group, "/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope, expected a namespace and a group or *"})
+			return
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := &Claims{
+		Username:  c.GetString("username"),
+		TokenType: "scoped",
+		Tenant:    store.TenantFrom(c.Request.Context()),
+		Scopes:    req.Scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   req.Name,
+		},
+	}
+	token, err := s.signToken(claims)
+	if err != nil {
+		s.logger.Error("Failed to sign scoped token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	s.logger.Info("Created scoped token", zap.String("name", req.Name), zap.String("id", claims.ID),
+		zap.Any("scopes", req.Scopes), zap.String("by", claims.Username))
+	c.JSON(http.StatusCreated, gin.H{
+		"id":         claims.ID,
+		"name":       req.Name,
+		"scopes":     req.Scopes,
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// revokeScopedTokenHandler revokes a scoped token by ID
+func (s *Server) revokeScopedTokenHandler(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.store.AddTokenToBlacklist(c.Request.Context(), scopedTokenBlacklistKey(id), time.Now().Add(maxScopedTokenTTL)); err != nil {
+		s.logger.Error("Failed to revoke scoped token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.logger.Info("Revoked scoped token", zap.String("id", id), zap.String("by", c.GetString("username")))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/pkg/vault"
+)
+
+// SetSecretResolver makes config reads resolve values like
+// vault:secret/data/db#password to the referenced Vault secret. Requests
+// with ?resolve=false, such as the console's editor, get the reference.
+func (s *Server) SetSecretResolver(r *vault.Resolver) {
+	s.secrets = r
+}
+
+// resolveSecret returns config with a Vault reference in its value replaced
+// by the secret. The stored config is never modified.
+func (s *Server) resolveSecret(c *gin.Context, config *model.Config) (*model.Config, error) {
+	if s.secrets == nil {
+		return config, nil
+	}
+	if resolve, err := strconv.ParseBool(c.Query("resolve")); err == nil && !resolve {
+		return config, nil
+	}
+	if _, _, ok := vault.ParseRef(config.Value); !ok {
+		return config, nil
+	}
+	value, err := s.secrets.Resolve(c.Request.Context(), config.Value)
+	if err != nil {
+		return nil, err
+	}
+	resolved := *config
+	resolved.Value = value
+	return &resolved, nil
+}
+
+// respondSecretError reports a secret reference that could not be resolved
+func (s *Server) respondSecretError(c *gin.Context, err error) {
+	s.logger.Error("Failed to resolve secret reference", zap.Error(err))
+	c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to resolve secret reference"})
+}
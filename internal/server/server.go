@@ -2,58 +2,30 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
+	"github.com/sotowang/otter/internal/backup"
 	"github.com/sotowang/otter/internal/model"
 	"github.com/sotowang/otter/internal/store"
 	"github.com/sotowang/otter/internal/util"
+	"github.com/sotowang/otter/pkg/vault"
 )
 
-type Watcher struct {
-	subscribers sync.Map // map[string][]chan *model.Config (key: namespace/group/key)
-}
-
-func NewWatcher() *Watcher {
-	return &Watcher{}
-}
-
-func (w *Watcher) Subscribe(namespace, group, key string) chan *model.Config {
-	ch := make(chan *model.Config, 1)
-	fullKey := namespace + "/" + group + "/" + key
-
-	val, _ := w.subscribers.LoadOrStore(fullKey, []chan *model.Config{})
-	subs := val.([]chan *model.Config)
-	subs = append(subs, ch)
-	w.subscribers.Store(fullKey, subs)
-
-	return ch
-}
-
-func (w *Watcher) Notify(config *model.Config) {
-	fullKey := config.Namespace + "/" + config.Group + "/" + config.Key
-	val, ok := w.subscribers.Load(fullKey)
-	if !ok {
-		return
-	}
-
-	subs := val.([]chan *model.Config)
-	for _, ch := range subs {
-		select {
-		case ch <- config:
-		default:
-		}
-	}
-	// Clear subscribers after notification (one-time trigger for long polling)
-	w.subscribers.Delete(fullKey)
-}
-
 // ConnectionStats contains connection statistics for the server
 type ConnectionStats struct {
 	TotalRequests      int64         `json:"total_requests"`
@@ -64,34 +36,120 @@ type ConnectionStats struct {
 	AverageDuration    time.Duration `json:"average_duration"`
 	LastRequestTime    time.Time     `json:"last_request_time"`
 	ErrorRate          float64       `json:"error_rate"`
+	Routes             []RouteStats  `json:"routes"`
+	// LongPollHolders counts watch requests waiting for a change, which are
+	// left out of ActiveConnections
+	LongPollHolders int64 `json:"long_poll_holders"`
+	// Watch reports the watch subscriptions and the events fanned out to them
+	Watch WatcherStats `json:"watch"`
+	// ListCache reports the use of the cache of config list responses
+	ListCache ListCacheStats `json:"list_cache"`
 }
 
+// clientIDHeader identifies an SDK instance across requests
+const clientIDHeader = "X-Otter-Client-Id"
+
+// clientLabelsHeader carries the labels, such as region=eu,tier=canary,
+// that select the variants a client is served
+const clientLabelsHeader = "X-Otter-Client-Labels"
+
 type Server struct {
-	store     store.Store
-	watcher   *Watcher
-	jwtSecret string
-	engine    *gin.Engine
-	logger    *zap.Logger
-
-	// Connection statistics
-	mu    sync.Mutex
-	stats ConnectionStats
+	store       store.Store
+	watcher     *Watcher
+	propagation *PropagationTracker
+	access      *AccessTracker
+	// listCache holds serialized list responses until their group changes or
+	// they expire
+	listCache *listCache
+	// changeSinks queue change events for each sink added by AddChangeSink
+	changeSinks []chan *ChangeEvent
+	// webhookClient posts webhook deliveries; webhookWake tells the
+	// delivery loop that new ones were queued
+	webhookClient *http.Client
+	webhookWake   chan struct{}
+	loginGuard    *LoginGuard
+	jwtSecret     string
+	// tenantSecrets sign the tokens of tenants given their own secret
+	tenantSecrets map[string]string
+	engine        *gin.Engine
+	logger        *zap.Logger
+
+	// Connection statistics, updated with atomics only so requests do not
+	// contend on a lock. The totals are summed over the routes on read.
+	activeConnections atomic.Int64
+	lastRequestTime   atomic.Int64 // Unix nanoseconds
+	routes            sync.Map     // routeKey -> *latencyHistogram
+	// longPollHolders counts long polls currently waiting for a change
+	longPollHolders atomic.Int64
+	// maxWatchWait bounds the wait a long poll may ask for, zero for
+	// DefaultMaxWatchWait
+	maxWatchWait time.Duration
+
+	ipFilter    atomic.Pointer[IPFilter]
+	maintenance atomic.Pointer[MaintenanceStatus]
+
+	// bus shares change events with other instances, nil when running alone
+	bus        NotifyBus
+	instanceID string
+
+	// secrets resolves vault: references when configs are read, nil if disabled
+	secrets *vault.Resolver
+	// signingKey signs served configs, nil if signing is disabled
+	signingKey []byte
+
+	// logBodies allows request bodies to be logged, off by default
+	logBodies atomic.Bool
+
+	// backupTarget receives saved backups, nil if none is configured
+	backupTarget backup.Target
+	backupKeep   int
+
+	// allTenants is the store with every tenant's data under its stored
+	// names, for backups
+	allTenants store.Store
+	// storeOps records the operations of the store for the metrics
+	storeOps *store.InstrumentedStore
+
+	// httpServers are serving the listeners passed to Serve
+	httpMu      sync.Mutex
+	httpServers []*http.Server
+
+	// adminEngine serves the monitoring and admin routes on adminListeners,
+	// if any are set
+	adminEngine    *gin.Engine
+	adminListeners []net.Listener
+
+	// settings are reloaded from settingsFile while running, and may
+	// adjust logLevel, which started out at startLevel
+	settings     atomic.Pointer[Settings]
+	settingsFile string
+	logLevel     *zap.AtomicLevel
+	startLevel   zapcore.Level
 }
 
-func NewServer(store store.Store, jwtSecret string, logger *zap.Logger) *Server {
+func NewServer(st store.Store, jwtSecret string, logger *zap.Logger) *Server {
 	// Set Gin to release mode in production
 	gin.SetMode(gin.ReleaseMode)
 
+	storeOps := store.NewInstrumentedStore(st)
 	s := &Server{
-		store:     store,
-		watcher:   NewWatcher(),
-		jwtSecret: jwtSecret,
-		engine:    gin.New(),
-		logger:    logger,
-		stats: ConnectionStats{
-			LastRequestTime: time.Now(),
-		},
-	}
+		store:         store.NewTenantStore(storeOps),
+		allTenants:    storeOps,
+		storeOps:      storeOps,
+		watcher:       NewWatcher(),
+		propagation:   NewPropagationTracker(),
+		access:        NewAccessTracker(),
+		listCache:     newListCache(),
+		webhookClient: &http.Client{Timeout: webhookTimeout},
+		webhookWake:   make(chan struct{}, 1),
+		loginGuard:    NewLoginGuard(defaultMaxLoginFailures, defaultLoginLockout),
+		jwtSecret:     jwtSecret,
+		engine:        gin.New(),
+		instanceID:    newInstanceID(),
+	}
+	s.watcher.OnNotify(s.listCache.onNotify)
+	s.lastRequestTime.Store(time.Now().UnixNano())
+	s.logger = redactLogger(logger, &s.logBodies)
 
 	// Initialize default admin user
 	s.initAdminUser()
@@ -99,7 +157,16 @@ func NewServer(store store.Store, jwtSecret string, logger *zap.Logger) *Server
 	// Setup Gin middleware
 	s.engine.Use(gin.Recovery())
 	s.engine.Use(s.statsMiddleware())
+	s.engine.Use(s.ipFilterMiddleware())
+	s.engine.Use(s.maintenanceMiddleware())
+	s.engine.Use(s.bodyLogMiddleware())
 	s.setupRoutes()
+	s.setupAdminEngine()
+
+	// Client IPs are the connection's address until trusted proxies are set,
+	// so forwarding headers cannot dodge the IP rules or misdirect login
+	// lockouts
+	_ = s.SetTrustedProxies(nil)
 
 	return s
 }
@@ -107,57 +174,78 @@ func NewServer(store store.Store, jwtSecret string, logger *zap.Logger) *Server
 // statsMiddleware is a Gin middleware that collects connection statistics
 func (s *Server) statsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Increment active connections
-		s.mu.Lock()
-		s.stats.ActiveConnections++
-		s.mu.Unlock()
+		s.activeConnections.Add(1)
+		defer s.activeConnections.Add(-1)
 
 		startTime := time.Now()
 
 		// Process request
 		c.Next()
 
-		// Calculate duration
-		duration := time.Since(startTime)
+		// Calculate duration, excluding time a long poll spent waiting for changes
+		endTime := time.Now()
+		duration := endTime.Sub(startTime)
+		if hold, ok := c.Get(holdDurationKey); ok {
+			duration -= hold.(time.Duration)
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		rk := routeKey{method: c.Request.Method, route: route}
 
 		// Determine if request was successful (status < 500)
 		success := c.Writer.Status() < 500
 
 		// Update statistics
-		s.mu.Lock()
-		s.stats.TotalRequests++
-		s.stats.TotalDuration += duration
-		s.stats.LastRequestTime = time.Now()
-
-		if success {
-			s.stats.SuccessfulRequests++
-		} else {
-			s.stats.FailedRequests++
-		}
-
-		// Calculate average duration
-		if s.stats.TotalRequests > 0 {
-			s.stats.AverageDuration = s.stats.TotalDuration / time.Duration(s.stats.TotalRequests)
+		s.lastRequestTime.Store(endTime.UnixNano())
+		hist, ok := s.routes.Load(rk)
+		if !ok {
+			hist, _ = s.routes.LoadOrStore(rk, newLatencyHistogram())
 		}
+		hist.(*latencyHistogram).observe(duration, !success)
+	}
+}
 
-		// Calculate error rate
-		if s.stats.TotalRequests > 0 {
-			s.stats.ErrorRate = float64(s.stats.FailedRequests) / float64(s.stats.TotalRequests) * 100
+// currentStats returns the current connection statistics
+func (s *Server) currentStats() ConnectionStats {
+	stats := ConnectionStats{
+		ActiveConnections: s.activeConnections.Load(),
+		LastRequestTime:   time.Unix(0, s.lastRequestTime.Load()),
+		Routes:            []RouteStats{},
+	}
+	s.routes.Range(func(key, value any) bool {
+		rk, hist := key.(routeKey), value.(*latencyHistogram).snapshot()
+		stats.TotalRequests += hist.count
+		stats.FailedRequests += hist.errors
+		stats.TotalDuration += hist.total
+		stats.Routes = append(stats.Routes, hist.routeStats(rk.method, rk.route))
+		return true
+	})
+	stats.SuccessfulRequests = stats.TotalRequests - stats.FailedRequests
+	if stats.TotalRequests > 0 {
+		stats.AverageDuration = stats.TotalDuration / time.Duration(stats.TotalRequests)
+		stats.ErrorRate = float64(stats.FailedRequests) / float64(stats.TotalRequests) * 100
+	}
+	stats.LongPollHolders = s.longPollHolders.Load()
+	stats.ActiveConnections -= stats.LongPollHolders
+	stats.Watch = s.watcher.Stats()
+	stats.ListCache = s.listCache.Stats()
+
+	sort.Slice(stats.Routes, func(i, j int) bool {
+		if stats.Routes[i].Route != stats.Routes[j].Route {
+			return stats.Routes[i].Route < stats.Routes[j].Route
 		}
+		return stats.Routes[i].Method < stats.Routes[j].Method
+	})
 
-		// Decrement active connections
-		s.stats.ActiveConnections--
-		s.mu.Unlock()
-	}
+	return stats
 }
 
 // getStatsHandler returns the current connection statistics
 func (s *Server) getStatsHandler(c *gin.Context) {
-	s.mu.Lock()
-	stats := s.stats
-	s.mu.Unlock()
-
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, s.currentStats())
 }
 
 // Run starts the HTTP server
@@ -167,14 +255,14 @@ func (s *Server) Run(addr string) error {
 
 func (s *Server) initAdminUser() {
 	ctx := context.Background()
-	
+
 	// Check if any admin user exists
 	users, err := s.store.ListUsers(ctx)
 	if err != nil {
 		s.logger.Error("Failed to list users", zap.Error(err))
 		return
 	}
-	
+
 	// Check if there's any admin user
 	adminExists := false
 	for _, user := range users {
@@ -183,7 +271,7 @@ func (s *Server) initAdminUser() {
 			break
 		}
 	}
-	
+
 	if !adminExists {
 		// Create admin user if no admin exists
 		newUser := &model.User{
@@ -198,7 +286,7 @@ func (s *Server) initAdminUser() {
 			s.logger.Error("Failed to create default admin user", zap.Error(err))
 			return
 		}
-		s.logger.Info("Created default admin user", zap.String("username", "admin"), zap.String("password", "admin"))
+		s.logger.Warn("Created default admin user with the default password, change it after logging in", zap.String("username", "admin"))
 	} else {
 		s.logger.Info("Admin user already exists, skipping creation")
 	}
@@ -220,6 +308,10 @@ func (s *Server) setupRoutes() {
 		c.File("./web/index.html")
 	})
 
+	// Monitoring routes, unless they are served on the admin listener
+	s.engine.GET("/healthz", s.publicAdminMiddleware(), s.healthzHandler)
+	s.engine.GET("/metrics", s.publicAdminMiddleware(), s.metricsHandler)
+
 	// API Routes
 	api := s.engine.Group("/api/v1")
 	{
@@ -228,26 +320,58 @@ func (s *Server) setupRoutes() {
 		api.POST("/refresh", s.refreshTokenHandler)
 
 		// Connection stats route (public for monitoring)
-		api.GET("/stats", s.getStatsHandler)
+		api.GET("/stats", s.publicAdminMiddleware(), s.getStatsHandler)
 
 		// Protected routes
 		protected := api.Group("/")
-		protected.Use(s.ginAuthMiddleware())
+		protected.Use(s.ginAuthMiddleware(), s.scopeMiddleware(), s.viewerMiddleware())
 		{
 			// Namespace routes
 			protected.GET("/namespaces", s.listNamespacesHandler)
 			protected.POST("/namespaces", s.createNamespaceHandler)
 			protected.DELETE("/namespaces/:namespace", s.deleteNamespaceHandler)
+			protected.GET("/namespaces/:namespace/changes", s.namespaceChangesHandler)
+			protected.GET("/namespaces/:namespace/events", s.namespaceEventsHandler)
+			protected.GET("/namespaces/:namespace/watch", s.watchNamespaceHandler)
+			protected.GET("/namespaces/:namespace/configs", s.listNamespaceConfigsHandler)
+			protected.GET("/namespaces/:namespace/locks", s.listLocksHandler)
+			protected.GET("/namespaces/:namespace/owners", s.listOwnersHandler)
+			protected.PUT("/namespaces/:namespace/lock", s.freezeNamespaceHandler)
+			protected.DELETE("/namespaces/:namespace/lock", s.unfreezeNamespaceHandler)
+			protected.GET("/namespaces/:namespace/scheduled", s.listScheduledChangesHandler)
+			protected.GET("/namespaces/:namespace/scheduled/:id", s.getScheduledChangeHandler)
+			protected.PUT("/namespaces/:namespace/scheduled/:id", s.updateScheduledChangeHandler)
+			protected.DELETE("/namespaces/:namespace/scheduled/:id", s.cancelScheduledChangeHandler)
 
 			// Config routes
 			protected.GET("/namespaces/:namespace/groups/:group/configs", s.listConfigsHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/watch", s.watchGroupHandler)
+			protected.POST("/namespaces/:namespace/groups/:group/import", s.importFileHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/export", s.exportGroupHandler)
 			protected.GET("/namespaces/:namespace/groups/:group/configs/:key", s.getConfigHandler)
+			protected.POST("/namespaces/:namespace/groups/:group/configs/batch", s.batchGetConfigsHandler)
 			protected.PUT("/namespaces/:namespace/groups/:group/configs/:key", s.putConfigHandler)
 			protected.DELETE("/namespaces/:namespace/groups/:group/configs/:key", s.deleteConfigHandler)
 			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/watch", s.watchConfigHandler)
+			protected.PUT("/namespaces/:namespace/groups/:group/configs/:key/lock", s.lockConfigHandler)
+			protected.DELETE("/namespaces/:namespace/groups/:group/configs/:key/lock", s.unlockConfigHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/variants", s.listVariantsHandler)
+			protected.PUT("/namespaces/:namespace/groups/:group/configs/:key/variants/:variant", s.setVariantHandler)
+			protected.DELETE("/namespaces/:namespace/groups/:group/configs/:key/variants/:variant", s.deleteVariantHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/overrides", s.listOverridesHandler)
+			protected.PUT("/namespaces/:namespace/groups/:group/configs/:key/overrides", s.setOverrideHandler)
+			protected.DELETE("/namespaces/:namespace/groups/:group/configs/:key/overrides", s.deleteOverrideHandler)
+			protected.POST("/watch", s.watchBatchHandler)
+			protected.GET("/watch/ws", s.watchWebSocketHandler)
+			protected.POST("/namespaces/:namespace/groups/:group/configs/:key/ack", s.ackConfigHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/propagation", s.propagationStatusHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/access", s.configAccessHandler)
+			protected.GET("/namespaces/:namespace/access", s.namespaceAccessHandler)
 
 			// History routes
 			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/history", s.listHistoryHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/history/export", s.exportHistoryHandler)
+			protected.GET("/namespaces/:namespace/groups/:group/configs/:key/history/:id/diff", s.historyDiffHandler)
 			protected.POST("/namespaces/:namespace/groups/:group/configs/:key/rollback", s.rollbackConfigHandler)
 
 			// User routes
@@ -255,16 +379,119 @@ func (s *Server) setupRoutes() {
 			protected.POST("/users", s.createUserHandler)
 			protected.PUT("/users/:username", s.updateUserHandler)
 			protected.DELETE("/users/:username", s.deleteUserHandler)
+
+			// Session routes
+			protected.GET("/sessions", s.listSessionsHandler)
+			protected.DELETE("/sessions/:id", s.revokeSessionHandler)
+
+			// Notifications about owned configs
+			protected.GET("/notifications", s.listNotificationsHandler)
+			protected.POST("/notifications/read", s.readNotificationsHandler)
+
+			// Admin routes, unless they are served on the admin listener
+			s.adminRoutes(protected.Group("/admin", s.publicAdminMiddleware()))
 		}
 	}
 }
 
+// adminRoutes registers the admin API on admin, the /api/v1/admin group of
+// the public or the admin listener
+func (s *Server) adminRoutes(admin *gin.RouterGroup) {
+	admin.Use(s.ginAdminMiddleware())
+	admin.GET("/namespaces/:namespace/quota", s.getNamespaceQuotaHandler)
+	admin.PUT("/namespaces/:namespace/quota", s.setNamespaceQuotaHandler)
+	admin.PUT("/namespaces/:namespace/groups/:group/owner", s.setGroupOwnerHandler)
+	admin.DELETE("/namespaces/:namespace/groups/:group/owner", s.deleteGroupOwnerHandler)
+	admin.PUT("/namespaces/:namespace/groups/:group/configs/:key/owner", s.setConfigOwnerHandler)
+	admin.DELETE("/namespaces/:namespace/groups/:group/configs/:key/owner", s.deleteConfigOwnerHandler)
+	admin.GET("/namespaces/:namespace/webhooks", s.listWebhooksHandler)
+	admin.POST("/namespaces/:namespace/webhooks", s.createWebhookHandler)
+	admin.DELETE("/namespaces/:namespace/webhooks/:id", s.deleteWebhookHandler)
+	admin.GET("/namespaces/:namespace/webhooks/:id/deliveries", s.listWebhookDeliveriesHandler)
+	admin.POST("/namespaces/:namespace/webhooks/:id/deliveries/:delivery/redeliver", s.redeliverWebhookHandler)
+	admin.GET("/stale-configs", s.staleConfigsHandler)
+	admin.GET("/teams", s.listTeamsHandler)
+	admin.PUT("/teams/:team", s.setTeamHandler)
+	admin.DELETE("/teams/:team", s.deleteTeamHandler)
+	admin.POST("/tokens", s.createScopedTokenHandler)
+	admin.DELETE("/tokens/:id", s.revokeScopedTokenHandler)
+	admin.GET("/users/:username/sessions", s.listUserSessionsHandler)
+	admin.DELETE("/users/:username/sessions", s.revokeUserSessionsHandler)
+	admin.DELETE("/users/:username/sessions/:id", s.revokeUserSessionHandler)
+
+	// Routes acting on the whole deployment, closed to tenants' admins
+	platform := admin.Group("")
+	platform.Use(s.platformMiddleware())
+	platform.GET("/watchers", s.listWatchersHandler)
+	platform.DELETE("/watchers", s.expireWatchersHandler)
+	platform.GET("/maintenance", s.getMaintenanceHandler)
+	platform.PUT("/maintenance", s.setMaintenanceHandler)
+	platform.GET("/login-locks", s.listLoginLocksHandler)
+	platform.DELETE("/login-locks", s.unlockLoginHandler)
+	platform.POST("/backup", s.backupHandler)
+	platform.POST("/restore", s.restoreHandler)
+	platform.GET("/tenants", s.listTenantsHandler)
+	platform.POST("/tenants", s.createTenantHandler)
+	platform.DELETE("/tenants/:tenant", s.deleteTenantHandler)
+	platform.POST("/reload", s.reloadSettingsHandler)
+}
+
+// ginAdminMiddleware rejects requests from users without the admin role.
+// It must run after ginAuthMiddleware.
+func (s *Server) ginAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := s.store.GetUser(c.Request.Context(), c.GetString("username"))
+		if err != nil {
+			if err == store.ErrNotFound {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+				return
+			}
+			s.logger.Error("Failed to get user", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if user.Role != "admin" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// viewerMiddleware limits users with the viewer role to reading: GET
+// routes, the read and watch routes open to scoped tokens, revoking their
+// own sessions and marking their notifications read. It must run after
+// ginAuthMiddleware.
+func (s *Server) viewerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		if _, ok := scopedRoutes[route]; ok || c.Request.Method == http.MethodGet || route == "DELETE /api/v1/sessions/:id" || route == "POST /api/v1/notifications/read" {
+			c.Next()
+			return
+		}
+		user, err := s.store.GetUser(c.Request.Context(), c.GetString("username"))
+		if err != nil && err != store.ErrNotFound {
+			s.logger.Error("Failed to get user", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err == nil && user.Role == "viewer" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Viewers can only read configs"})
+			return
+		}
+		c.Next()
+	}
+}
+
 // corsMiddleware handles CORS headers
 func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := s.allowedOrigin(c.GetHeader("Origin")); origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		c.Writer.Header().Add("Vary", "Origin")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Otter-Client-Id, X-Otter-Client-Labels")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -280,14 +507,27 @@ func (s *Server) ginAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Convert Gin context to http.ResponseWriter and *http.Request
 		// and use the existing authMiddleware
+		authorized := false
 		s.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			authorized = true
 			// If we get here, the token is valid
 			// Set the username from context to Gin context
 			if username, ok := r.Context().Value("username").(string); ok {
 				c.Set("username", username)
 			}
+			if sessionID, ok := r.Context().Value("session_id").(string); ok {
+				c.Set("session_id", sessionID)
+			}
+			if scopes, ok := r.Context().Value("scopes").(TokenScopes); ok {
+				c.Set("scopes", scopes)
+			}
+			c.Request = r
 			c.Next()
 		})(c.Writer, c.Request)
+		// authMiddleware has already written the error response
+		if !authorized {
+			c.Abort()
+		}
 	}
 }
 
@@ -365,13 +605,26 @@ func (s *Server) handleConfigs(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) watchConfig(w http.ResponseWriter, r *http.Request, namespace, group, key string) {
+	wait, err := s.watchWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Long polling: wait for update or timeout
-	ch := s.watcher.Subscribe(namespace, group, key)
+	sub := s.watcher.Subscribe(namespace, group, key, SubscriberInfo{
+		ClientID: r.Header.Get(clientIDHeader),
+		IP:       r.RemoteAddr,
+	})
+	defer s.watcher.Unsubscribe(sub)
 
 	select {
-	case cfg := <-ch:
-		json.NewEncoder(w).Encode(cfg)
-	case <-time.After(30 * time.Second):
+	case <-sub.Ready():
+		json.NewEncoder(w).Encode(sub.Next())
+	case <-sub.Done():
+		// Subscription was force-expired by an admin
+		w.WriteHeader(http.StatusNotModified)
+	case <-time.After(wait):
 		w.WriteHeader(http.StatusNotModified)
 	case <-r.Context().Done():
 		return
@@ -419,7 +672,6 @@ func (s *Server) putConfig(w http.ResponseWriter, r *http.Request, namespace, gr
 		Key:       key,
 		Value:     req.Value,
 		Type:      configType,
-		Version:   time.Now().Unix(),
 		CreatedBy: username,
 		UpdatedBy: username,
 		CreatedAt: time.Now(),
@@ -440,12 +692,13 @@ func (s *Server) putConfig(w http.ResponseWriter, r *http.Request, namespace, gr
 		Type:      cfg.Type,
 		Version:   cfg.Version,
 		OpType:    "UPDATE",
+		CreatedBy: username,
 		CreatedAt: time.Now(),
 	}
 	_ = s.store.CreateHistory(r.Context(), history)
 
 	// Notify watchers
-	s.watcher.Notify(cfg)
+	s.notify(r.Context(), model.EventPut, cfg)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(cfg)
@@ -455,10 +708,21 @@ func (s *Server) deleteConfig(w http.ResponseWriter, r *http.Request, namespace,
 	// Get existing config to save history before delete (optional, but good for record)
 	// For now, just record the delete op
 
+	// Get username from context
+	username := "system"
+	if user, ok := r.Context().Value("username").(string); ok {
+		username = user
+	}
+
 	if err := s.store.Delete(r.Context(), namespace, group, key); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	version, err := s.store.NextVersion(r.Context(), namespace, group, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Create history
 	history := &model.ConfigHistory{
@@ -467,14 +731,15 @@ func (s *Server) deleteConfig(w http.ResponseWriter, r *http.Request, namespace,
 		Key:       key,
 		Value:     "",
 		Type:      "",
-		Version:   time.Now().Unix(),
+		Version:   version,
 		OpType:    "DELETE",
+		CreatedBy: username,
 		CreatedAt: time.Now(),
 	}
 	_ = s.store.CreateHistory(r.Context(), history)
 
 	// Notify watchers about deletion
-	s.watcher.Notify(&model.Config{Namespace: namespace, Group: group, Key: key, Value: "", Version: -1})
+	s.notify(r.Context(), model.EventDelete, &model.Config{Namespace: namespace, Group: group, Key: key, Value: "", Version: -1})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -541,16 +806,16 @@ func (s *Server) rollbackConfig(w http.ResponseWriter, r *http.Request, namespac
 
 	// Restore config
 	cfg := &model.Config{
-		Namespace: namespace,
-		Group:     group,
-		Key:       key,
-		Value:     target.Value,
-		Type:      target.Type,       // 从历史记录中获取类型
-		Version:   time.Now().Unix(), // New version for the rollback
-		CreatedBy: username,
-		UpdatedBy: username,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Namespace:   namespace,
+		Group:       group,
+		Key:         key,
+		Value:       target.Value,
+		Type:        target.Type, // 从历史记录中获取类型
+		ContentType: target.ContentType,
+		CreatedBy:   username,
+		UpdatedBy:   username,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
 	if err := s.store.Put(r.Context(), cfg); err != nil {
@@ -560,19 +825,21 @@ func (s *Server) rollbackConfig(w http.ResponseWriter, r *http.Request, namespac
 
 	// Create history for rollback
 	history := &model.ConfigHistory{
-		Namespace: namespace,
-		Group:     group,
-		Key:       key,
-		Value:     target.Value,
-		Type:      cfg.Type,
-		Version:   cfg.Version,
-		OpType:    "ROLLBACK",
-		CreatedAt: time.Now(),
+		Namespace:   namespace,
+		Group:       group,
+		Key:         key,
+		Value:       target.Value,
+		Type:        cfg.Type,
+		ContentType: cfg.ContentType,
+		Version:     cfg.Version,
+		OpType:      "ROLLBACK",
+		CreatedBy:   username,
+		CreatedAt:   time.Now(),
 	}
 	_ = s.store.CreateHistory(r.Context(), history)
 
 	// Notify watchers
-	s.watcher.Notify(cfg)
+	s.notify(r.Context(), model.EventRollback, cfg)
 
 	json.NewEncoder(w).Encode(cfg)
 }
@@ -671,6 +938,8 @@ func (s *Server) loginHandler(c *gin.Context) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
+		// Tenant is the tenant the user belongs to, empty for platform users
+		Tenant string `json:"tenant"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -679,20 +948,34 @@ func (s *Server) loginHandler(c *gin.Context) {
 		return
 	}
 
-	// Calculate password hash for logging
-	passwordHash := util.MD5Encrypt(req.Password)
+	s.logger.Info("Login attempt", zap.String("username", req.Username), zap.String("tenant", req.Tenant), zap.String("ip", c.ClientIP()))
 
-	s.logger.Info("Login attempt", zap.String("username", req.Username), zap.String("ip", c.ClientIP()))
+	// Lockouts count failures per tenant user
+	lockName := store.TenantName(req.Tenant, req.Username)
+	if s.rejectLockedLogin(c, lockName) {
+		return
+	}
+
+	if req.Tenant != "" {
+		if _, err := s.store.GetTenant(c.Request.Context(), req.Tenant); err != nil {
+			if err != store.ErrNotFound {
+				s.logger.Error("Login failed: Database error", zap.String("tenant", req.Tenant), zap.Error(err))
+			}
+			s.loginGuard.Fail(lockName, c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		c.Request = c.Request.WithContext(store.WithTenant(c.Request.Context(), req.Tenant))
+	}
 
 	// Get user from store
 	user, err := s.store.GetUser(c.Request.Context(), req.Username)
 	if err != nil {
 		if err == store.ErrNotFound {
-			s.logger.Warn("Login failed: User not found", zap.String("username", req.Username), zap.String("ip", c.ClientIP()),
-				zap.String("password", req.Password), zap.String("password_hash", passwordHash))
+			s.logger.Warn("Login failed: User not found", zap.String("username", req.Username), zap.String("ip", c.ClientIP()))
+			s.loginGuard.Fail(lockName, c.ClientIP())
 		} else {
-			s.logger.Error("Login failed: Database error", zap.String("username", req.Username), zap.Error(err),
-				zap.String("password", req.Password), zap.String("password_hash", passwordHash))
+			s.logger.Error("Login failed: Database error", zap.String("username", req.Username), zap.Error(err))
 		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
@@ -700,31 +983,36 @@ func (s *Server) loginHandler(c *gin.Context) {
 
 	// Check password using MD5 encryption
 	if !util.CheckPassword(req.Password, user.Password) {
-		s.logger.Warn("Login failed: Incorrect password", zap.String("username", req.Username), zap.String("ip", c.ClientIP()),
-			zap.String("password", req.Password), zap.String("password_hash", passwordHash),
-			zap.String("stored_hash", user.Password))
+		s.logger.Warn("Login failed: Incorrect password", zap.String("username", req.Username), zap.String("ip", c.ClientIP()))
+		s.loginGuard.Fail(lockName, c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	// Check user status
 	if user.Status != "active" {
-		s.logger.Warn("Login failed: User inactive", zap.String("username", req.Username), zap.String("status", user.Status),
-			zap.String("password", req.Password), zap.String("password_hash", passwordHash))
+		s.logger.Warn("Login failed: User inactive", zap.String("username", req.Username), zap.String("status", user.Status))
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User account is inactive"})
 		return
 	}
 
+	session, err := s.startSession(c.Request.Context(), req.Username, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		s.logger.Error("Login failed: Session error", zap.String("username", req.Username), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		return
+	}
+
 	// Generate JWT tokens
-	accessToken, refreshToken, expiresIn, err := s.generateTokens(req.Username)
+	accessToken, refreshToken, expiresIn, err := s.generateTokens(req.Tenant, req.Username, session.ID)
 	if err != nil {
-		s.logger.Error("Login failed: Token generation error", zap.String("username", req.Username), zap.Error(err),
-			zap.String("password", req.Password), zap.String("password_hash", passwordHash))
+		s.logger.Error("Login failed: Token generation error", zap.String("username", req.Username), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
 		return
 	}
 
 	s.logger.Info("Login successful", zap.String("username", req.Username), zap.String("ip", c.ClientIP()))
+	s.loginGuard.Succeed(lockName)
 
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":  accessToken,
@@ -759,6 +1047,10 @@ func (s *Server) createNamespaceHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Namespace name cannot be empty"})
 		return
 	}
+	if strings.Contains(req.Name, "/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Namespace name must not contain /"})
+		return
+	}
 
 	if err := s.store.CreateNamespace(c.Request.Context(), req.Name); err != nil {
 		s.logger.Error("Failed to create namespace", zap.Error(err))
@@ -772,6 +1064,10 @@ func (s *Server) createNamespaceHandler(c *gin.Context) {
 // deleteNamespaceHandler deletes a namespace
 func (s *Server) deleteNamespaceHandler(c *gin.Context) {
 	namespace := c.Param("namespace")
+	if err := s.checkLock(c.Request.Context(), namespace, "", ""); err != nil {
+		s.respondLockError(c, err)
+		return
+	}
 	if err := s.store.DeleteNamespace(c.Request.Context(), namespace); err != nil {
 		s.logger.Error("Failed to delete namespace", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -786,13 +1082,66 @@ func (s *Server) listConfigsHandler(c *gin.Context) {
 	namespace := c.Param("namespace")
 	group := c.Param("group")
 
-	configs, err := s.store.List(c.Request.Context(), namespace, group)
-	if err != nil {
-		s.logger.Error("Failed to list configs", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	s.cachedList(c, namespace, group, func(ctx context.Context) ([]*model.Config, error) {
+		return s.store.List(ctx, namespace, group)
+	})
+}
+
+// cachedList sends the listed configs of a group, or of the whole
+// namespace if group is empty, from the list cache, reading them with list
+// and caching the response on a miss
+func (s *Server) cachedList(c *gin.Context, namespace, group string, list func(ctx context.Context) ([]*model.Config, error)) {
+	ctx := c.Request.Context()
+	values, err := strconv.ParseBool(c.Query("values"))
+	key := listCacheKey{
+		tenant:    store.TenantFrom(ctx),
+		namespace: namespace,
+		group:     group,
+		values:    err != nil || values,
+	}
+	body, generation := s.listCache.get(key)
+	if body == nil {
+		configs, err := list(ctx)
+		if err != nil {
+			s.logger.Error("Failed to list configs", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if configs == nil {
+			configs = []*model.Config{}
+		}
+		body, err = json.Marshal(s.listedConfigs(c, configs))
+		if err != nil {
+			s.logger.Error("Failed to encode configs", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		s.listCache.put(key, generation, body)
 	}
-	c.JSON(http.StatusOK, configs)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// listedConfigs returns configs as a list endpoint sends them: without
+// their values when the request sets values=false, so clients can detect
+// changes from the checksums alone
+func (s *Server) listedConfigs(c *gin.Context, configs []*model.Config) []*model.Config {
+	if values, err := strconv.ParseBool(c.Query("values")); err != nil || values {
+		if len(s.signingKey) == 0 {
+			return configs
+		}
+		signed := make([]*model.Config, len(configs))
+		for i, config := range configs {
+			signed[i] = s.signed(config)
+		}
+		return signed
+	}
+	listed := make([]*model.Config, len(configs))
+	for i, config := range configs {
+		copied := *config
+		copied.Value = ""
+		listed[i] = &copied
+	}
+	return listed
 }
 
 // getConfigHandler returns a specific config
@@ -811,7 +1160,151 @@ func (s *Server) getConfigHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, config)
+
+	// A client that fetched the config holds its current version
+	info := s.subscriberInfo(c)
+	s.propagation.Ack(tenantNamespace(c, namespace), group, key, info, config.Version)
+	s.access.Record(namespace, group, key, info)
+
+	if config, err = s.variantConfig(c.Request.Context(), info.selectorLabels(), config); err != nil {
+		s.logger.Error("Failed to select config variant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if config, err = s.resolveSecret(c, config); err != nil {
+		s.respondSecretError(c, err)
+		return
+	}
+
+	// Return the bare value for shell scripts and init containers
+	if wantsRawValue(c) {
+		if config.Type == "binary" {
+			s.writeBinaryValue(c, config)
+			return
+		}
+		c.Data(http.StatusOK, contentTypeForConfig(config.Type), []byte(config.Value))
+		return
+	}
+	c.JSON(http.StatusOK, s.signed(config))
+}
+
+// writeBinaryValue sends the decoded bytes of a binary config as a download
+func (s *Server) writeBinaryValue(c *gin.Context, config *model.Config) {
+	data, err := base64.StdEncoding.DecodeString(config.Value)
+	if err != nil {
+		s.logger.Error("Failed to decode binary config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stored binary value is not valid base64"})
+		return
+	}
+	contentType := config.ContentType
+	if contentType == "" {
+		contentType = defaultBinaryContentType
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": config.Key}))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// maxBatchKeys limits how many configs a single batch request may fetch
+const maxBatchKeys = 500
+
+// batchGetConfigsHandler returns several configs of a group in one request
+func (s *Server) batchGetConfigsHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+
+	var req struct {
+		Keys []string `json:"keys" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if len(req.Keys) > maxBatchKeys {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d keys per batch", maxBatchKeys)})
+		return
+	}
+
+	configs, err := s.store.List(c.Request.Context(), namespace, group)
+	if err != nil {
+		s.logger.Error("Failed to list configs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	byKey := make(map[string]*model.Config, len(configs))
+	for _, config := range configs {
+		byKey[config.Key] = config
+	}
+
+	found := make([]*model.Config, 0, len(req.Keys))
+	missing := make([]string, 0)
+	info := s.subscriberInfo(c)
+	for _, key := range req.Keys {
+		config, ok := byKey[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		s.propagation.Ack(tenantNamespace(c, namespace), group, key, info, config.Version)
+		s.access.Record(namespace, group, key, info)
+		if config, err = s.variantConfig(c.Request.Context(), info.selectorLabels(), config); err != nil {
+			s.logger.Error("Failed to select config variant", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if config, err = s.resolveSecret(c, config); err != nil {
+			s.respondSecretError(c, err)
+			return
+		}
+		found = append(found, s.signed(config))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"configs": found, "missing": missing})
+}
+
+// wantsRawValue reports whether the client asked for the bare config value,
+// either via ?raw=true or an Accept header preferring text/plain.
+func wantsRawValue(c *gin.Context) bool {
+	if raw, err := strconv.ParseBool(c.Query("raw")); err == nil {
+		return raw
+	}
+	accept := c.GetHeader("Accept")
+	return accept != "" && c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain
+}
+
+// contentTypeForConfig maps a config type to the Content-Type of its raw value
+func contentTypeForConfig(configType string) string {
+	switch configType {
+	case "json":
+		return "application/json; charset=utf-8"
+	case "yaml", "yml":
+		return "application/yaml; charset=utf-8"
+	case "xml":
+		return "application/xml; charset=utf-8"
+	case "properties":
+		return "text/x-java-properties; charset=utf-8"
+	case "toml":
+		return "application/toml; charset=utf-8"
+	case "markdown":
+		return "text/markdown; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// listNamespaceConfigsHandler returns every config in a namespace, ordered by group and key
+func (s *Server) listNamespaceConfigsHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	s.cachedList(c, namespace, "", func(ctx context.Context) ([]*model.Config, error) {
+		configs, err := s.store.ListNamespaceConfigs(ctx, namespace)
+		sort.Slice(configs, func(i, j int) bool {
+			if configs[i].Group != configs[j].Group {
+				return configs[i].Group < configs[j].Group
+			}
+			return configs[i].Key < configs[j].Key
+		})
+		return configs, err
+	})
 }
 
 // putConfigHandler creates or updates a config
@@ -821,8 +1314,10 @@ func (s *Server) putConfigHandler(c *gin.Context) {
 	key := c.Param("key")
 
 	var req struct {
-		Value string `json:"value" binding:"required"`
-		Type  string `json:"type"`
+		Value       string     `json:"value" binding:"required"`
+		Type        string     `json:"type"`
+		ContentType string     `json:"content_type"`
+		PublishAt   *time.Time `json:"publish_at"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -831,13 +1326,19 @@ func (s *Server) putConfigHandler(c *gin.Context) {
 	}
 
 	// Validate config type
-	validTypes := map[string]bool{
-		"": true, "text": true, "properties": true, "json": true, "yaml": true, "yml": true, "xml": true,
-	}
-	if !validTypes[req.Type] {
+	if !validConfigTypes[req.Type] {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid config type"})
 		return
 	}
+	if err := validateConfigValue(req.Type, req.Value); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	contentType, err := configContentType(req.Type, req.ContentType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Set default type if not provided
 	configType := req.Type
@@ -857,16 +1358,36 @@ func (s *Server) putConfigHandler(c *gin.Context) {
 	}
 
 	config := &model.Config{
-		Namespace: namespace,
-		Group:     group,
-		Key:       key,
-		Value:     req.Value,
-		Type:      configType,
-		Version:   time.Now().Unix(),
-		CreatedBy: username,
-		UpdatedBy: username,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Namespace:   namespace,
+		Group:       group,
+		Key:         key,
+		Value:       req.Value,
+		Type:        configType,
+		ContentType: contentType,
+		CreatedBy:   username,
+		UpdatedBy:   username,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	// Changes with a publish time are written by the scheduler
+	if req.PublishAt != nil {
+		s.scheduleConfig(c, config, *req.PublishAt)
+		return
+	}
+
+	if err := s.checkLock(c.Request.Context(), namespace, group, key); err != nil {
+		s.respondLockError(c, err)
+		return
+	}
+	if err := s.checkOwner(c.Request.Context(), namespace, group, key, username); err != nil {
+		s.respondOwnerError(c, err)
+		return
+	}
+
+	if err := s.checkQuota(c.Request.Context(), config); err != nil {
+		s.respondQuotaError(c, err)
+		return
 	}
 
 	if err := s.store.Put(c.Request.Context(), config); err != nil {
@@ -877,19 +1398,23 @@ func (s *Server) putConfigHandler(c *gin.Context) {
 
 	// Create history
 	history := &model.ConfigHistory{
-		Namespace: namespace,
-		Group:     group,
-		Key:       key,
-		Value:     req.Value,
-		Type:      config.Type,
-		Version:   config.Version,
-		OpType:    "UPDATE",
-		CreatedAt: time.Now(),
+		Namespace:   namespace,
+		Group:       group,
+		Key:         key,
+		Value:       req.Value,
+		Type:        config.Type,
+		ContentType: config.ContentType,
+		Version:     config.Version,
+		OpType:      "UPDATE",
+		CreatedBy:   username,
+		CreatedAt:   time.Now(),
 	}
 	_ = s.store.CreateHistory(c.Request.Context(), history)
 
-	// Notify watchers
-	s.watcher.Notify(config)
+	// Notify watchers and the config's owners
+	s.notify(c.Request.Context(), model.EventPut, config)
+	s.notifyOwners(c.Request.Context(), namespace, group, key, config.Version, "UPDATE", username)
+	s.publishChange(c.Request.Context(), namespace, group, key, config.Version, "UPDATE", username)
 
 	c.JSON(http.StatusCreated, config)
 }
@@ -900,11 +1425,33 @@ func (s *Server) deleteConfigHandler(c *gin.Context) {
 	group := c.Param("group")
 	key := c.Param("key")
 
+	// Get username from context
+	username := "system"
+	if user, ok := c.Request.Context().Value("username").(string); ok {
+		username = user
+	}
+
+	if err := s.checkLock(c.Request.Context(), namespace, group, key); err != nil {
+		s.respondLockError(c, err)
+		return
+	}
+	if err := s.checkOwner(c.Request.Context(), namespace, group, key, username); err != nil {
+		s.respondOwnerError(c, err)
+		return
+	}
+
 	if err := s.store.Delete(c.Request.Context(), namespace, group, key); err != nil {
 		s.logger.Error("Failed to delete config", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.deleteVariants(c.Request.Context(), namespace, group, key)
+	version, err := s.store.NextVersion(c.Request.Context(), namespace, group, key)
+	if err != nil {
+		s.logger.Error("Failed to allocate version", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create history
 	history := &model.ConfigHistory{
@@ -913,14 +1460,17 @@ func (s *Server) deleteConfigHandler(c *gin.Context) {
 		Key:       key,
 		Value:     "",
 		Type:      "",
-		Version:   time.Now().Unix(),
+		Version:   version,
 		OpType:    "DELETE",
+		CreatedBy: username,
 		CreatedAt: time.Now(),
 	}
 	_ = s.store.CreateHistory(c.Request.Context(), history)
 
 	// Notify watchers about deletion
-	s.watcher.Notify(&model.Config{Namespace: namespace, Group: group, Key: key, Value: "", Version: -1})
+	s.notify(c.Request.Context(), model.EventDelete, &model.Config{Namespace: namespace, Group: group, Key: key, Value: "", Version: -1})
+	s.notifyOwners(c.Request.Context(), namespace, group, key, version, "DELETE", username)
+	s.publishChange(c.Request.Context(), namespace, group, key, version, "DELETE", username)
 
 	c.Status(http.StatusNoContent)
 }
@@ -931,17 +1481,170 @@ func (s *Server) watchConfigHandler(c *gin.Context) {
 	group := c.Param("group")
 	key := c.Param("key")
 
-	// Long polling: wait for update or timeout
-	ch := s.watcher.Subscribe(namespace, group, key)
+	info := s.subscriberInfo(c)
+	s.propagation.Seen(tenantNamespace(c, namespace), group, key, info)
+	s.access.Record(namespace, group, key, info)
+
+	s.longPoll(c, namespace, group, key, info)
+}
+
+// watchGroupHandler long-polls for a change to any config in a group
+func (s *Server) watchGroupHandler(c *gin.Context) {
+	s.longPoll(c, c.Param("namespace"), c.Param("group"), "", s.subscriberInfo(c))
+}
+
+// watchNamespaceHandler long-polls for a change to any config in a namespace
+func (s *Server) watchNamespaceHandler(c *gin.Context) {
+	s.longPoll(c, c.Param("namespace"), "", "", s.subscriberInfo(c))
+}
+
+// longPoll waits for a change to the watched key, or any key in the group
+// when key is empty or in the namespace when group is empty too, answering
+// 304 if nothing changes before the timeout, 30s or the ?wait= asked for.
+// A key watch may pass the version the client holds as ?version=, in which
+// case a newer stored version is returned at once instead of waiting.
+func (s *Server) longPoll(c *gin.Context, namespace, group, key string, info SubscriberInfo) {
+	wait, err := s.watchWait(c.Query("wait"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wait, want a positive duration such as 55s"})
+		return
+	}
+	var version int64
+	if v := c.Query("version"); v != "" && key != "" {
+		var err error
+		if version, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+	}
+
+	// Long polling: wait for update or timeout. Subscribe before comparing
+	// versions so no change slips in between.
+	sub := s.watcher.Subscribe(namespace, group, key, info)
+	defer s.watcher.Unsubscribe(sub)
+	defer s.holdLongPoll()()
+	holdStart := time.Now()
+
+	if version != 0 {
+		changes, err := s.staleTargets(c.Request.Context(), []watchTarget{{namespace, group, key, version}}, info.selectorLabels())
+		if err != nil {
+			s.logger.Error("Failed to get config", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(changes) > 0 {
+			c.JSON(http.StatusOK, changes[0])
+			return
+		}
+	}
 
 	select {
-	case cfg := <-ch:
-		c.JSON(http.StatusOK, cfg)
-	case <-time.After(30 * time.Second):
+	case <-sub.Ready():
+		c.Set(holdDurationKey, time.Since(holdStart))
+		events, err := s.variantEvents(c.Request.Context(), info.selectorLabels(), []*model.ConfigEvent{sub.Next()})
+		if err != nil {
+			s.logger.Error("Failed to select config variant", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, events[0])
+	case <-sub.Done():
+		// Subscription was force-expired by an admin
+		c.Set(holdDurationKey, time.Since(holdStart))
+		c.Status(http.StatusNotModified)
+	case <-time.After(wait):
+		c.Set(holdDurationKey, time.Since(holdStart))
 		c.Status(http.StatusNotModified)
 	case <-c.Request.Context().Done():
+		c.Set(holdDurationKey, time.Since(holdStart))
+		return
+	}
+}
+
+// expireWatchersHandler terminates pending long polls for a namespace, group or key
+func (s *Server) expireWatchersHandler(c *gin.Context) {
+	namespace := c.Query("namespace")
+	if namespace == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "namespace is required"})
+		return
+	}
+
+	expired := s.watcher.Expire(namespace, c.Query("group"), c.Query("key"))
+	s.logger.Info("Expired watchers",
+		zap.String("namespace", namespace),
+		zap.String("group", c.Query("group")),
+		zap.String("key", c.Query("key")),
+		zap.Int("count", expired),
+		zap.String("operator", c.GetString("username")))
+
+	c.JSON(http.StatusOK, gin.H{"expired": expired})
+}
+
+// subscriberInfo identifies the client making the request
+func (s *Server) subscriberInfo(c *gin.Context) SubscriberInfo {
+	return SubscriberInfo{
+		Tenant:   store.TenantFrom(c.Request.Context()),
+		ClientID: c.GetHeader(clientIDHeader),
+		Username: c.GetString("username"),
+		IP:       c.ClientIP(),
+		Labels:   parseLabels(c.GetHeader(clientLabelsHeader)),
+	}
+}
+
+// ackConfigHandler records that a client has applied a version of a config
+func (s *Server) ackConfigHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+	key := c.Param("key")
+
+	var req struct {
+		Version json.Number `json:"version" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	version, err := req.Version.Int64()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version format"})
+		return
+	}
+
+	info := s.subscriberInfo(c)
+	if info.ClientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": clientIDHeader + " header is required"})
+		return
+	}
+
+	s.propagation.Ack(tenantNamespace(c, namespace), group, key, info, version)
+	c.Status(http.StatusNoContent)
+}
+
+// propagationStatusHandler reports which clients have acknowledged the current version of a config
+func (s *Server) propagationStatusHandler(c *gin.Context) {
+	namespace := c.Param("namespace")
+	group := c.Param("group")
+	key := c.Param("key")
+
+	config, err := s.store.Get(c.Request.Context(), namespace, group, key)
+	if err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+			return
+		}
+		s.logger.Error("Failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+
+	c.JSON(http.StatusOK, s.propagation.Status(tenantNamespace(c, namespace), group, key, config.Version))
+}
+
+// listWatchersHandler returns the active watch subscriptions per key
+func (s *Server) listWatchersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, s.watcher.Snapshot(c.Query("namespace")))
 }
 
 // listHistoryHandler returns config history
@@ -1002,6 +1705,12 @@ func (s *Server) rollbackConfigHandler(c *gin.Context) {
 		return
 	}
 
+	// Preview the rollback without writing or notifying watchers
+	if dryRun, _ := strconv.ParseBool(c.Query("dryRun")); dryRun {
+		s.rollbackPreview(c, target)
+		return
+	}
+
 	// Get username from context
 	username := "system"
 	if user, ok := c.Request.Context().Value("username").(string); ok {
@@ -1010,16 +1719,30 @@ func (s *Server) rollbackConfigHandler(c *gin.Context) {
 
 	// Restore config
 	config := &model.Config{
-		Namespace: namespace,
-		Group:     group,
-		Key:       key,
-		Value:     target.Value,
-		Type:      target.Type,       // 从历史记录中获取类型
-		Version:   time.Now().Unix(), // New version for the rollback
-		CreatedBy: username,
-		UpdatedBy: username,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Namespace:   namespace,
+		Group:       group,
+		Key:         key,
+		Value:       target.Value,
+		Type:        target.Type, // 从历史记录中获取类型
+		ContentType: target.ContentType,
+		CreatedBy:   username,
+		UpdatedBy:   username,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if err := s.checkLock(c.Request.Context(), namespace, group, key); err != nil {
+		s.respondLockError(c, err)
+		return
+	}
+	if err := s.checkOwner(c.Request.Context(), namespace, group, key, username); err != nil {
+		s.respondOwnerError(c, err)
+		return
+	}
+
+	if err := s.checkQuota(c.Request.Context(), config); err != nil {
+		s.respondQuotaError(c, err)
+		return
 	}
 
 	if err := s.store.Put(c.Request.Context(), config); err != nil {
@@ -1030,19 +1753,23 @@ func (s *Server) rollbackConfigHandler(c *gin.Context) {
 
 	// Create history for rollback
 	history := &model.ConfigHistory{
-		Namespace: namespace,
-		Group:     group,
-		Key:       key,
-		Value:     target.Value,
-		Type:      config.Type,
-		Version:   config.Version,
-		OpType:    "ROLLBACK",
-		CreatedAt: time.Now(),
+		Namespace:   namespace,
+		Group:       group,
+		Key:         key,
+		Value:       target.Value,
+		Type:        config.Type,
+		ContentType: config.ContentType,
+		Version:     config.Version,
+		OpType:      "ROLLBACK",
+		CreatedBy:   username,
+		CreatedAt:   time.Now(),
 	}
 	_ = s.store.CreateHistory(c.Request.Context(), history)
 
-	// Notify watchers
-	s.watcher.Notify(config)
+	// Notify watchers and the config's owners
+	s.notify(c.Request.Context(), model.EventRollback, config)
+	s.notifyOwners(c.Request.Context(), namespace, group, key, config.Version, "ROLLBACK", username)
+	s.publishChange(c.Request.Context(), namespace, group, key, config.Version, "ROLLBACK", username)
 
 	c.JSON(http.StatusOK, config)
 }
@@ -1065,7 +1792,7 @@ func (s *Server) createUserHandler(c *gin.Context) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
-		Role     string `json:"role" binding:"required,oneof=admin user"`
+		Role     string `json:"role" binding:"required,oneof=admin user viewer"`
 		Status   string `json:"status" binding:"required,oneof=active inactive"`
 	}
 
@@ -1073,6 +1800,10 @@ func (s *Server) createUserHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	if !validUsername(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must not contain /"})
+		return
+	}
 
 	// Check if user already exists
 	_, err := s.store.GetUser(c.Request.Context(), req.Username)
@@ -1110,7 +1841,7 @@ func (s *Server) updateUserHandler(c *gin.Context) {
 
 	var req struct {
 		Password string `json:"password"`
-		Role     string `json:"role" binding:"required,oneof=admin user"`
+		Role     string `json:"role" binding:"required,oneof=admin user viewer"`
 		Status   string `json:"status" binding:"required,oneof=active inactive"`
 	}
 
@@ -1195,6 +1926,9 @@ func (s *Server) deleteUserHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := s.store.DeleteNotifications(c.Request.Context(), username); err != nil {
+		s.logger.Warn("Failed to delete notifications of deleted user", zap.String("username", username), zap.Error(err))
+	}
 
 	c.Status(http.StatusNoContent)
 }
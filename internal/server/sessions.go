@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// refreshTokenTTL is how long a refresh token, and so an idle session, lives
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+// errSessionRevoked is returned when refreshing a token of a revoked session
+var errSessionRevoked = errors.New("session revoked")
+
+// newTokenID returns a random ID for sessions and the jti claim of tokens
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// sessionBlacklistKey is the blacklist entry that revokes every token of a session
+func sessionBlacklistKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// startSession records a new login of username
+func (s *Server) startSession(ctx context.Context, username, ip, userAgent string) (*model.Session, error) {
+	now := time.Now()
+	session := &model.Session{
+		ID:          newTokenID(),
+		Username:    username,
+		IP:          ip,
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(refreshTokenTTL),
+	}
+	if err := s.store.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// refreshSession extends the session of a refresh token and returns its ID.
+// Refresh tokens issued before sessions were tracked start a new session.
+func (s *Server) refreshSession(c *gin.Context, claims *Claims) (string, error) {
+	ctx := store.WithTenant(c.Request.Context(), claims.Tenant)
+	if claims.SessionID == "" {
+		session, err := s.startSession(ctx, claims.Username, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			return "", err
+		}
+		return session.ID, nil
+	}
+
+	revoked, err := s.store.IsTokenBlacklisted(ctx, sessionBlacklistKey(claims.SessionID))
+	if err != nil {
+		return "", err
+	}
+	if revoked {
+		return "", errSessionRevoked
+	}
+	session, err := s.store.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return "", errSessionRevoked
+		}
+		return "", err
+	}
+
+	now := time.Now()
+	session.IP = c.ClientIP()
+	session.UserAgent = c.Request.UserAgent()
+	session.RefreshedAt = now
+	session.ExpiresAt = now.Add(refreshTokenTTL)
+	if err := s.store.UpdateSession(ctx, session); err != nil {
+		return "", err
+	}
+	return session.ID, nil
+}
+
+// revokeSession blacklists every token of a session until its refresh token
+// would have expired, then forgets the session
+func (s *Server) revokeSession(ctx context.Context, session *model.Session) error {
+	if err := s.store.AddTokenToBlacklist(ctx, sessionBlacklistKey(session.ID), session.ExpiresAt); err != nil {
+		return err
+	}
+	return s.store.DeleteSession(ctx, session.ID)
+}
+
+// listSessionsHandler lists the active sessions of the current user
+func (s *Server) listSessionsHandler(c *gin.Context) {
+	s.listSessions(c, c.GetString("username"))
+}
+
+// revokeSessionHandler revokes one of the current user's sessions
+func (s *Server) revokeSessionHandler(c *gin.Context) {
+	s.revokeUserSession(c, c.GetString("username"), c.Param("id"))
+}
+
+// listUserSessionsHandler lists the active sessions of any user
+func (s *Server) listUserSessionsHandler(c *gin.Context) {
+	s.listSessions(c, c.Param("username"))
+}
+
+// revokeUserSessionHandler revokes one session of any user
+func (s *Server) revokeUserSessionHandler(c *gin.Context) {
+	s.revokeUserSession(c, c.Param("username"), c.Param("id"))
+}
+
+// revokeUserSessionsHandler revokes every session of a user, e.g. after a
+// device was lost
+func (s *Server) revokeUserSessionsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	sessions, err := s.store.ListSessions(ctx, c.Param("username"))
+	if err != nil {
+		s.logger.Error("Failed to list sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, session := range sessions {
+		if err := s.revokeSession(ctx, session); err != nil {
+			s.logger.Error("Failed to revoke session", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	s.logger.Info("Revoked all sessions", zap.String("username", c.Param("username")),
+		zap.Int("sessions", len(sessions)), zap.String("by", c.GetString("username")))
+	c.JSON(http.StatusOK, gin.H{"revoked": len(sessions)})
+}
+
+func (s *Server) listSessions(c *gin.Context, username string) {
+	sessions, err := s.store.ListSessions(c.Request.Context(), username)
+	if err != nil {
+		s.logger.Error("Failed to list sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	current := c.GetString("session_id")
+	for _, session := range sessions {
+		session.Current = session.ID == current
+	}
+	if sessions == nil {
+		sessions = []*model.Session{}
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (s *Server) revokeUserSession(c *gin.Context, username, id string) {
+	ctx := c.Request.Context()
+	session, err := s.store.GetSession(ctx, id)
+	if err == store.ErrNotFound || (err == nil && session.Username != username) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.revokeSession(ctx, session); err != nil {
+		s.logger.Error("Failed to revoke session", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.logger.Info("Revoked session", zap.String("username", username), zap.String("session", id),
+		zap.String("by", c.GetString("username")))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTokenRateLimit is the requests per minute allowed per token unless
+// the settings file sets token_rate_limit
+const defaultTokenRateLimit = 100
+
+// Settings are the server settings that can change while it runs, read
+// from the settings file at startup and again on SIGHUP or
+// POST /api/v1/admin/reload. Settings left out of the file take their
+// defaults, so removing a line undoes it on the next reload.
+type Settings struct {
+	// LogLevel is debug, info, warn or error; empty keeps the level the
+	// server started with
+	LogLevel string `yaml:"log_level" json:"log_level,omitempty"`
+	// TokenRateLimit is the requests per minute allowed per token, 0 for no
+	// limit; unset keeps the default of 100
+	TokenRateLimit *int64 `yaml:"token_rate_limit" json:"token_rate_limit,omitempty"`
+	// CORSOrigins are the origins browsers may call the API from, "*" for
+	// any; unset allows any
+	CORSOrigins []string `yaml:"cors_origins" json:"cors_origins,omitempty"`
+	// DefaultQuota limits namespaces without a quota of their own
+	DefaultQuota *QuotaLimits `yaml:"default_quota" json:"default_quota,omitempty"`
+}
+
+// QuotaLimits are the limits of a namespace quota, zero meaning unlimited
+type QuotaLimits struct {
+	MaxConfigs    int64 `yaml:"max_configs" json:"max_configs"`
+	MaxTotalBytes int64 `yaml:"max_total_bytes" json:"max_total_bytes"`
+	MaxValueBytes int64 `yaml:"max_value_bytes" json:"max_value_bytes"`
+}
+
+// LoadSettings reads and validates a YAML settings file. Unknown settings
+// are rejected so a misspelt one is not silently ignored.
+func LoadSettings(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := &Settings{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(settings); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	if settings.LogLevel != "" {
+		if _, err := zapcore.ParseLevel(settings.LogLevel); err != nil {
+			return nil, fmt.Errorf("invalid log_level %q", settings.LogLevel)
+		}
+	}
+	if settings.TokenRateLimit != nil && *settings.TokenRateLimit < 0 {
+		return nil, errors.New("token_rate_limit cannot be negative")
+	}
+	if q := settings.DefaultQuota; q != nil && (q.MaxConfigs < 0 || q.MaxTotalBytes < 0 || q.MaxValueBytes < 0) {
+		return nil, errors.New("default_quota limits cannot be negative")
+	}
+	return settings, nil
+}
+
+// SetLogLevel lets the settings file adjust level, the level of the
+// server's logger
+func (s *Server) SetLogLevel(level zap.AtomicLevel) {
+	s.logLevel = &level
+	s.startLevel = level.Level()
+}
+
+// SetSettingsFile loads the settings in path and makes ReloadSettings read
+// them again from there
+func (s *Server) SetSettingsFile(path string) error {
+	s.settingsFile = path
+	_, err := s.ReloadSettings()
+	return err
+}
+
+// ReloadSettings reads the settings file again and applies it without
+// interrupting requests or watches. The current settings stay in effect
+// if the file cannot be read or is invalid.
+func (s *Server) ReloadSettings() (*Settings, error) {
+	if s.settingsFile == "" {
+		return nil, errors.New("no settings file configured")
+	}
+	settings, err := LoadSettings(s.settingsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.logLevel != nil {
+		level := s.startLevel
+		if settings.LogLevel != "" {
+			level, _ = zapcore.ParseLevel(settings.LogLevel)
+		}
+		s.logLevel.SetLevel(level)
+	}
+	s.settings.Store(settings)
+	s.logger.Info("Loaded settings", zap.String("file", s.settingsFile), zap.Any("settings", settings))
+	return settings, nil
+}
+
+// currentSettings returns the settings in effect, empty if there is no
+// settings file
+func (s *Server) currentSettings() *Settings {
+	if settings := s.settings.Load(); settings != nil {
+		return settings
+	}
+	return &Settings{}
+}
+
+// tokenRateLimit returns the requests per minute allowed per token, 0 for
+// no limit
+func (s *Server) tokenRateLimit() int64 {
+	if limit := s.currentSettings().TokenRateLimit; limit != nil {
+		return *limit
+	}
+	return defaultTokenRateLimit
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin header for a
+// request from origin, empty if browsers may not call from there
+func (s *Server) allowedOrigin(origin string) string {
+	origins := s.currentSettings().CORSOrigins
+	if origins == nil || slices.Contains(origins, "*") {
+		return "*"
+	}
+	if origin != "" && slices.Contains(origins, origin) {
+		return origin
+	}
+	return ""
+}
+
+// reloadSettingsHandler reloads the settings file and returns the settings
+// now in effect
+func (s *Server) reloadSettingsHandler(c *gin.Context) {
+	settings, err := s.ReloadSettings()
+	if err != nil {
+		s.logger.Error("Failed to reload settings", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
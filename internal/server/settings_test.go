@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestReloadSettings tests that reloading applies each setting, restores
+// defaults for settings removed from the file and keeps the current
+// settings when the file is invalid
+func TestReloadSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore())}
+	s.SetLogLevel(zap.NewAtomicLevelAt(zap.InfoLevel))
+	ctx := context.Background()
+
+	write("log_level: debug\ntoken_rate_limit: 0\ncors_origins: [https://console.example.com]\ndefault_quota:\n  max_configs: 10\n")
+	if err := s.SetSettingsFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if level := s.logLevel.Level(); level != zap.DebugLevel {
+		t.Errorf("log level %v, want debug", level)
+	}
+	if limit := s.tokenRateLimit(); limit != 0 {
+		t.Errorf("token rate limit %d, want 0", limit)
+	}
+	if origin := s.allowedOrigin("https://evil.example.com"); origin != "" {
+		t.Errorf("allowed origin %q for an unlisted origin", origin)
+	}
+	if origin := s.allowedOrigin("https://console.example.com"); origin != "https://console.example.com" {
+		t.Errorf("allowed origin %q, want the listed origin", origin)
+	}
+	if quota, err := s.namespaceQuota(ctx, "prod"); err != nil || quota.MaxConfigs != 10 {
+		t.Errorf("namespace quota %+v, %v, want the default of 10 configs", quota, err)
+	}
+
+	write("log_levl: warn\n")
+	if _, err := s.ReloadSettings(); err == nil {
+		t.Error("reloaded a file with an unknown setting")
+	}
+	if limit := s.tokenRateLimit(); limit != 0 {
+		t.Errorf("token rate limit %d after a failed reload, want 0 kept", limit)
+	}
+
+	write("")
+	if _, err := s.ReloadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if level := s.logLevel.Level(); level != zap.InfoLevel {
+		t.Errorf("log level %v, want info restored", level)
+	}
+	if limit := s.tokenRateLimit(); limit != defaultTokenRateLimit {
+		t.Errorf("token rate limit %d, want the default", limit)
+	}
+	if origin := s.allowedOrigin("https://evil.example.com"); origin != "*" {
+		t.Errorf("allowed origin %q, want *", origin)
+	}
+	if _, err := s.namespaceQuota(ctx, "prod"); err != store.ErrNotFound {
+		t.Errorf("namespace quota error %v, want none", err)
+	}
+}
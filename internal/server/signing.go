@@ -0,0 +1,40 @@
+package server
+
+import (
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/pkg/signing"
+)
+
+// SetSigningKey makes the server sign every config it serves to readers and
+// watchers with HMAC-SHA256 under key. SDK clients configured with the same
+// key reject configs whose signature does not match.
+func (s *Server) SetSigningKey(key []byte) {
+	s.signingKey = key
+}
+
+// signed returns a copy of cfg carrying its signature, or cfg itself if no
+// signing key is set. The stored config is never modified.
+func (s *Server) signed(cfg *model.Config) *model.Config {
+	if len(s.signingKey) == 0 || cfg == nil {
+		return cfg
+	}
+	out := *cfg
+	out.Signature = signing.Payload{
+		Namespace:   cfg.Namespace,
+		Group:       cfg.Group,
+		Key:         cfg.Key,
+		Version:     cfg.Version,
+		Type:        cfg.Type,
+		ContentType: cfg.ContentType,
+		Value:       cfg.Value,
+	}.Sign(s.signingKey)
+	return &out
+}
+
+// signedEvent returns event with its config signed
+func (s *Server) signedEvent(event *model.ConfigEvent) *model.ConfigEvent {
+	if len(s.signingKey) == 0 {
+		return event
+	}
+	return &model.ConfigEvent{Type: event.Type, Config: s.signed(event.Config)}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// defaultStaleDays is how long a config must have gone unread or unchanged
+// to be reported as stale unless the request says otherwise
+const defaultStaleDays = 30
+
+// StaleConfig is a config that has not been accessed or not been modified
+// since the cutoff of a stale config report
+type StaleConfig struct {
+	Namespace    string     `json:"namespace"`
+	Group        string     `json:"group"`
+	Key          string     `json:"key"`
+	Version      int64      `json:"version"`
+	UpdatedBy    string     `json:"updated_by"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	AccessCount  int64      `json:"access_count"`
+	LastAccessed *time.Time `json:"last_accessed"`
+	NotAccessed  bool       `json:"not_accessed"` // 截止时间后未被获取或监听
+	NotModified  bool       `json:"not_modified"` // 截止时间后未被修改
+}
+
+// staleConfigsHandler reports the configs not accessed or not modified in
+// the last days (30 by default), in one namespace or all of them, oldest
+// change first. by=access or by=update reports only configs not accessed or
+// only those not modified. Configs created after the cutoff are never
+// reported as not accessed, nor are accesses from before tracking began
+// known.
+func (s *Server) staleConfigsHandler(c *gin.Context) {
+	days := defaultStaleDays
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive number"})
+			return
+		}
+		days = n
+	}
+	by := c.Query("by")
+	if by != "" && by != "access" && by != "update" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be access or update"})
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	ctx := c.Request.Context()
+	namespaces := []string{c.Query("namespace")}
+	if namespaces[0] == "" {
+		var err error
+		if namespaces, err = s.store.ListNamespaces(ctx); err != nil {
+			s.logger.Error("Failed to list namespaces", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	stale := []*StaleConfig{}
+	for _, namespace := range namespaces {
+		configs, err := s.sortedConfigs(ctx, namespace, "")
+		if err == nil {
+			var stored map[watchKey]*model.ConfigAccess
+			if stored, err = s.storedAccess(ctx, namespace, ""); err == nil {
+				stale = append(stale, s.staleConfigs(ctx, configs, stored, cutoff, by)...)
+			}
+		}
+		if err != nil {
+			s.logger.Error("Failed to report stale configs", zap.String("namespace", namespace), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool { return stale[i].UpdatedAt.Before(stale[j].UpdatedAt) })
+
+	c.JSON(http.StatusOK, gin.H{"days": days, "cutoff": cutoff, "configs": stale})
+}
+
+// staleConfigs returns those of configs not accessed or not modified since
+// cutoff, or only one of the two if by is access or update
+func (s *Server) staleConfigs(ctx context.Context, configs []*model.Config, stored map[watchKey]*model.ConfigAccess, cutoff time.Time, by string) []*StaleConfig {
+	var stale []*StaleConfig
+	for _, cfg := range configs {
+		access := s.accessOf(ctx, stored, cfg)
+		notAccessed := cfg.CreatedAt.Before(cutoff) && (access.LastAccessed == nil || access.LastAccessed.Before(cutoff))
+		notModified := cfg.UpdatedAt.Before(cutoff)
+		if !(notAccessed && by != "update" || notModified && by != "access") {
+			continue
+		}
+		stale = append(stale, &StaleConfig{
+			Namespace:    cfg.Namespace,
+			Group:        cfg.Group,
+			Key:          cfg.Key,
+			Version:      cfg.Version,
+			UpdatedBy:    cfg.UpdatedBy,
+			UpdatedAt:    cfg.UpdatedAt,
+			AccessCount:  access.AccessCount,
+			LastAccessed: access.LastAccessed,
+			NotAccessed:  notAccessed,
+			NotModified:  notModified,
+		})
+	}
+	return stale
+}
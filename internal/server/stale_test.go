@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestStaleConfigs tests that configs are reported as not accessed or not
+// modified since the cutoff, and that new configs are not reported
+func TestStaleConfigs(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore()), access: NewAccessTracker()}
+	ctx := context.Background()
+	old := time.Now().AddDate(0, 0, -60)
+	for _, cfg := range []*model.Config{
+		{Key: "dead", CreatedAt: old, UpdatedAt: old},
+		{Key: "read", CreatedAt: old, UpdatedAt: old},
+		{Key: "edited", CreatedAt: old, UpdatedAt: time.Now()},
+		{Key: "new", CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	} {
+		cfg.Namespace, cfg.Group, cfg.Value, cfg.Type = "public", "app", "1", "text"
+		if err := s.store.Put(ctx, cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.access.Record("public", "app", "read", SubscriberInfo{ClientID: "pod-1"})
+
+	configs, err := s.sortedConfigs(ctx, "public", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.storedAccess(ctx, "public", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cutoff := time.Now().AddDate(0, 0, -30)
+
+	tests := []struct {
+		by   string
+		want map[string][2]bool // key: not accessed, not modified
+	}{
+		{"", map[string][2]bool{"dead": {true, true}, "edited": {true, false}, "read": {false, true}}},
+		{"access", map[string][2]bool{"dead": {true, true}, "edited": {true, false}}},
+		{"update", map[string][2]bool{"dead": {true, true}, "read": {false, true}}},
+	}
+	for _, tt := range tests {
+		got := make(map[string][2]bool)
+		for _, c := range s.staleConfigs(ctx, configs, stored, cutoff, tt.by) {
+			got[c.Key] = [2]bool{c.NotAccessed, c.NotModified}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("by %q reported %v, want %v", tt.by, got, tt.want)
+			continue
+		}
+		for key, flags := range tt.want {
+			if got[key] != flags {
+				t.Errorf("by %q reported %s as %v, want %v", tt.by, key, got[key], flags)
+			}
+		}
+	}
+}
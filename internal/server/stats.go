@@ -0,0 +1,193 @@
+package server
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// holdDurationKey is set by long-poll handlers to the time spent waiting for a
+// change, so the stats middleware can exclude it from request latency
+const holdDurationKey = "stats.hold_duration"
+
+// latencyBuckets are the upper bounds of the latency histogram buckets,
+// growing exponentially from 100µs to roughly 100s
+var latencyBuckets = func() []time.Duration {
+	bounds := make([]time.Duration, 21)
+	for i := range bounds {
+		bounds[i] = 100 * time.Microsecond << i
+	}
+	return bounds
+}()
+
+// RouteStats contains request statistics for a single route and method
+type RouteStats struct {
+	Method          string        `json:"method"`
+	Route           string        `json:"route"`
+	TotalRequests   int64         `json:"total_requests"`
+	Errors          int64         `json:"errors"`
+	ErrorRate       float64       `json:"error_rate"`
+	AverageDuration time.Duration `json:"average_duration"`
+	P50             time.Duration `json:"p50"`
+	P95             time.Duration `json:"p95"`
+	P99             time.Duration `json:"p99"`
+}
+
+// latencyHistogram accumulates request latencies into fixed buckets. It is
+// updated with atomics only, so concurrent requests do not contend on a
+// lock, and as few as possible: the request count is the sum of the
+// buckets, taken on read. A snapshot taken meanwhile may miss part of a
+// request in flight.
+type latencyHistogram struct {
+	counts []atomic.Int64 // one more than latencyBuckets for the overflow bucket
+	errors atomic.Int64   // requests answered with a 5xx status
+	total  atomic.Int64   // nanoseconds
+	max    atomic.Int64   // nanoseconds
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]atomic.Int64, len(latencyBuckets)+1)}
+}
+
+func (h *latencyHistogram) observe(d time.Duration, failed bool) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })
+	h.counts[i].Add(1)
+	if failed {
+		h.errors.Add(1)
+	}
+	h.total.Add(int64(d))
+	for {
+		max := h.max.Load()
+		if int64(d) <= max || h.max.CompareAndSwap(max, int64(d)) {
+			break
+		}
+	}
+}
+
+// snapshot returns the current values of the histogram
+func (h *latencyHistogram) snapshot() histogramSnapshot {
+	snap := histogramSnapshot{
+		counts: make([]int64, len(h.counts)),
+		errors: h.errors.Load(),
+		total:  time.Duration(h.total.Load()),
+		max:    time.Duration(h.max.Load()),
+	}
+	for i := range h.counts {
+		snap.counts[i] = h.counts[i].Load()
+		snap.count += snap.counts[i]
+	}
+	return snap
+}
+
+func (h *latencyHistogram) routeStats(method, route string) RouteStats {
+	return h.snapshot().routeStats(method, route)
+}
+
+// histogramSnapshot holds the values of a latencyHistogram at one moment
+type histogramSnapshot struct {
+	counts []int64
+	count  int64
+	errors int64
+	total  time.Duration
+	max    time.Duration
+}
+
+// percentile estimates the q-th quantile (0 < q <= 1) by linear interpolation within the matching bucket
+func (h histogramSnapshot) percentile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.count)))
+	var cumulative int64
+	for i, n := range h.counts {
+		if n == 0 {
+			continue
+		}
+		if cumulative+n >= rank {
+			lower := time.Duration(0)
+			if i > 0 {
+				lower = latencyBuckets[i-1]
+			}
+			upper := h.max
+			if i < len(latencyBuckets) && latencyBuckets[i] < upper {
+				upper = latencyBuckets[i]
+			}
+			if upper < lower {
+				return upper
+			}
+			fraction := float64(rank-cumulative) / float64(n)
+			return lower + time.Duration(fraction*float64(upper-lower))
+		}
+		cumulative += n
+	}
+	return h.max
+}
+
+func (h histogramSnapshot) routeStats(method, route string) RouteStats {
+	rs := RouteStats{
+		Method:        method,
+		Route:         route,
+		TotalRequests: h.count,
+		Errors:        h.errors,
+		P50:           h.percentile(0.50),
+		P95:           h.percentile(0.95),
+		P99:           h.percentile(0.99),
+	}
+	if h.count > 0 {
+		rs.AverageDuration = h.total / time.Duration(h.count)
+		rs.ErrorRate = float64(h.errors) / float64(h.count) * 100
+	}
+	return rs
+}
+
+type routeKey struct {
+	method, route string
+}
+
+// rateWindow is the number of seconds a rateMeter averages over
+const rateWindow = 60
+
+// rateMeter counts events in one-second buckets covering the last
+// rateWindow seconds. It is safe for concurrent use; a bucket being reused
+// for a new second may lose a few concurrent counts, which is acceptable for
+// a rate estimate.
+type rateMeter struct {
+	buckets [rateWindow]struct {
+		second atomic.Int64
+		count  atomic.Int64
+	}
+}
+
+// add counts n events at now
+func (m *rateMeter) add(now time.Time, n int64) {
+	if n == 0 {
+		return
+	}
+	sec := now.Unix()
+	b := &m.buckets[sec%rateWindow]
+	if old := b.second.Load(); old != sec && b.second.CompareAndSwap(old, sec) {
+		b.count.Store(0)
+	}
+	b.count.Add(n)
+}
+
+// rate returns the events per second averaged over the last rateWindow seconds
+func (m *rateMeter) rate(now time.Time) float64 {
+	sec := now.Unix()
+	var total int64
+	for i := range m.buckets {
+		b := &m.buckets[i]
+		if age := sec - b.second.Load(); age >= 0 && age < rateWindow {
+			total += b.count.Load()
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// holdLongPoll counts the caller as a long-poll holder until the returned
+// func is called
+func (s *Server) holdLongPoll() func() {
+	s.longPollHolders.Add(1)
+	return func() { s.longPollHolders.Add(-1) }
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestRouteStats tests that a route reports its own error rate and that
+// latency percentiles fall within the observed range
+func TestRouteStats(t *testing.T) {
+	h := newLatencyHistogram()
+	for i := 0; i < 90; i++ {
+		h.observe(800*time.Microsecond, false)
+	}
+	for i := 0; i < 10; i++ {
+		h.observe(50*time.Millisecond, true)
+	}
+
+	rs := h.routeStats("GET", "/api/v1/configs/:namespace/:group/:key")
+	if rs.TotalRequests != 100 || rs.Errors != 10 || rs.ErrorRate != 10 {
+		t.Errorf("got %d requests, %d errors, %.1f%% error rate, want 100, 10 and 10%%", rs.TotalRequests, rs.Errors, rs.ErrorRate)
+	}
+	if rs.P50 <= 0 || rs.P50 > 800*time.Microsecond {
+		t.Errorf("p50 = %v, want up to 800µs", rs.P50)
+	}
+	if rs.P99 <= 800*time.Microsecond || rs.P99 > 50*time.Millisecond {
+		t.Errorf("p99 = %v, want between 800µs and 50ms", rs.P99)
+	}
+}
+
+// BenchmarkStatsMiddleware measures the stats middleware under requests in
+// parallel, as many as GOMAXPROCS, spread over a few routes
+func BenchmarkStatsMiddleware(b *testing.B) {
+	s := NewServer(store.NewInMemoryStore(), "secret", zap.NewNop())
+	engine := gin.New()
+	engine.Use(s.statsMiddleware())
+	paths := []string{"/a", "/b", "/c", "/d"}
+	for _, path := range paths {
+		engine.GET(path, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		reqs := make([]*http.Request, len(paths))
+		for i, path := range paths {
+			reqs[i] = httptest.NewRequest(http.MethodGet, path, nil)
+		}
+		w := httptest.NewRecorder()
+		for i := 0; pb.Next(); i++ {
+			engine.ServeHTTP(w, reqs[i%len(reqs)])
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+	"github.com/sotowang/otter/internal/util"
+)
+
+// tenantNamePattern restricts tenant names to lowercase DNS labels
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// tenantNamespace returns the name a namespace of the request's tenant is
+// tracked under, for state kept outside the store
+func tenantNamespace(c *gin.Context, namespace string) string {
+	return store.TenantName(store.TenantFrom(c.Request.Context()), namespace)
+}
+
+// validUsername reports whether a new user may be named username. Slashes
+// are reserved for qualifying the names of tenants' users.
+func validUsername(username string) bool {
+	return !strings.Contains(username, "/")
+}
+
+// platformMiddleware rejects requests from users and tokens of a tenant,
+// for routes that act on the whole deployment. It must run after
+// ginAuthMiddleware.
+func (s *Server) platformMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store.TenantFrom(c.Request.Context()) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not available to tenants"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// listTenantsHandler lists all tenants
+func (s *Server) listTenantsHandler(c *gin.Context) {
+	tenants, err := s.store.ListTenants(c.Request.Context())
+	if err != nil {
+		s.logger.Error("Failed to list tenants", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tenants == nil {
+		tenants = []*model.Tenant{}
+	}
+	c.JSON(http.StatusOK, tenants)
+}
+
+// createTenantHandler creates a tenant, optionally with its first admin,
+// who can then create the tenant's namespaces and users
+func (s *Server) createTenantHandler(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name" binding:"required"`
+		Admin *struct {
+			Username string `json:"username" binding:"required"`
+			Password string `json:"password" binding:"required"`
+		} `json:"admin"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if !tenantNamePattern.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tenant name must be lowercase letters, digits and dashes"})
+		return
+	}
+	if req.Admin != nil && !validUsername(req.Admin.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must not contain /"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := s.store.GetTenant(ctx, req.Name); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tenant already exists"})
+		return
+	} else if err != store.ErrNotFound {
+		s.logger.Error("Failed to get tenant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	username := c.GetString("username")
+	tenant := &model.Tenant{Name: req.Name, CreatedBy: username, CreatedAt: time.Now()}
+	if err := s.store.CreateTenant(ctx, tenant); err != nil {
+		s.logger.Error("Failed to create tenant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Admin != nil {
+		admin := &model.User{
+			Username:  req.Admin.Username,
+			Password:  util.MD5Encrypt(req.Admin.Password),
+			Role:      "admin",
+			Status:    "active",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := s.store.CreateUser(store.WithTenant(ctx, req.Name), admin); err != nil {
+			s.logger.Error("Failed to create tenant admin", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	s.logger.Info("Created tenant", zap.String("tenant", req.Name), zap.String("operator", username))
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// deleteTenantHandler deletes a tenant with all its namespaces and users,
+// revoking the sessions of its users
+func (s *Server) deleteTenantHandler(c *gin.Context) {
+	name := c.Param("tenant")
+	if _, err := s.store.GetTenant(c.Request.Context(), name); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+			return
+		}
+		s.logger.Error("Failed to get tenant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Configs are deleted without notifying watchers, so the lists of the
+	// tenant are dropped here
+	defer s.listCache.invalidateTenant(name)
+	if err := s.deleteTenantData(store.WithTenant(c.Request.Context(), name)); err != nil {
+		s.logger.Error("Failed to delete tenant data", zap.String("tenant", name), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.store.DeleteTenant(c.Request.Context(), name); err != nil {
+		s.logger.Error("Failed to delete tenant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Deleted tenant", zap.String("tenant", name), zap.String("operator", c.GetString("username")))
+	c.Status(http.StatusNoContent)
+}
+
+// deleteTenantData deletes the namespaces with their configs, the teams and
+// the users with their notifications of the tenant of ctx
+func (s *Server) deleteTenantData(ctx context.Context) error {
+	namespaces, err := s.store.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range namespaces {
+		configs, err := s.store.ListNamespaceConfigs(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		for _, cfg := range configs {
+			if err := s.store.Delete(ctx, namespace, cfg.Group, cfg.Key); err != nil {
+				return err
+			}
+		}
+		if err := s.store.DeleteNamespace(ctx, namespace); err != nil {
+			return err
+		}
+	}
+
+	users, err := s.store.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		sessions, err := s.store.ListSessions(ctx, user.Username)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if err := s.revokeSession(ctx, session); err != nil {
+				return err
+			}
+		}
+		if err := s.store.DeleteNotifications(ctx, user.Username); err != nil {
+			return err
+		}
+		if err := s.store.DeleteUser(ctx, user.Username); err != nil {
+			return err
+		}
+	}
+
+	teams, err := s.store.ListTeams(ctx)
+	if err != nil {
+		return err
+	}
+	for _, team := range teams {
+		if err := s.store.DeleteTeam(ctx, team.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
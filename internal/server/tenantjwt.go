@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// tokenIssuer is the issuer of every token the server signs
+	tokenIssuer = "otter"
+
+	// minTenantSecretLen is the shortest secret accepted for a tenant
+	minTenantSecretLen = 16
+)
+
+// errTokenAudience rejects a token whose audience is not its tenant's
+var errTokenAudience = errors.New("token audience does not match its tenant")
+
+// tenantAudience returns the audience of tokens issued to users and
+// applications of tenant, empty for the platform
+func tenantAudience(tenant string) string {
+	if tenant == "" {
+		return tokenIssuer
+	}
+	return tokenIssuer + ":tenant:" + tenant
+}
+
+// SetTenantSecrets makes the server sign and verify the tokens of the given
+// tenants with their own secret. Tokens of other tenants are signed with a
+// key derived from the JWT secret and the tenant name, so that every tenant
+// has a key of its own either way.
+func (s *Server) SetTenantSecrets(secrets map[string]string) {
+	s.tenantSecrets = secrets
+}
+
+// LoadTenantSecrets reads a JSON object mapping tenant names to their JWT
+// secrets, for SetTenantSecrets
+func LoadTenantSecrets(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for tenant, secret := range secrets {
+		if !tenantNamePattern.MatchString(tenant) {
+			return nil, fmt.Errorf("invalid tenant name %q", tenant)
+		}
+		if len(secret) < minTenantSecretLen {
+			return nil, fmt.Errorf("secret of tenant %s must be at least %d bytes", tenant, minTenantSecretLen)
+		}
+	}
+	return secrets, nil
+}
+
+// tokenKey returns the key signing the tokens of tenant
+func (s *Server) tokenKey(tenant string) []byte {
+	if tenant == "" {
+		return []byte(s.jwtSecret)
+	}
+	if secret, ok := s.tenantSecrets[tenant]; ok {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(s.jwtSecret))
+	mac.Write([]byte("tenant:" + tenant))
+	return mac.Sum(nil)
+}
+
+// signToken signs claims with the key of their tenant, issued for that
+// tenant's audience
+func (s *Server) signToken(claims *Claims) (string, error) {
+	claims.Issuer = tokenIssuer
+	claims.Audience = jwt.ClaimStrings{tenantAudience(claims.Tenant)}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.tokenKey(claims.Tenant))
+}
+
+// parseToken verifies tokenStr into claims. The signature must be made with
+// the key of the tenant the token claims, and the token must be issued for
+// that tenant's audience, so a token of one tenant is never accepted as
+// another's. Platform tokens issued before audiences were set carry none.
+func (s *Server) parseToken(tokenStr string, claims *Claims) error {
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.tokenKey(token.Claims.(*Claims).Tenant), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrTokenSignatureInvalid
+	}
+	if len(claims.Audience) == 0 && claims.Tenant == "" {
+		return nil
+	}
+	if !slices.Equal(claims.Audience, jwt.ClaimStrings{tenantAudience(claims.Tenant)}) {
+		return errTokenAudience
+	}
+	return nil
+}
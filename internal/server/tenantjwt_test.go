@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestTenantTokens tests that a token is only accepted under the tenant it
+// was issued for, whether tenants have their own secret or a derived key
+func TestTenantTokens(t *testing.T) {
+	s := &Server{jwtSecret: "platform-secret"}
+	s.SetTenantSecrets(map[string]string{"globex": "globex-secret-0123456789"})
+	expires := jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	for _, tenant := range []string{"", "acme", "globex"} {
+		token, err := s.signToken(&Claims{Username: "alice", TokenType: "access", Tenant: tenant,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: expires}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := &Claims{}
+		if err := s.parseToken(token, claims); err != nil || claims.Tenant != tenant {
+			t.Errorf("token of tenant %q: parsed tenant %q, error %v", tenant, claims.Tenant, err)
+		}
+	}
+
+	// A token claiming another tenant fails whichever key signed it
+	forge := func(key []byte, claims *Claims) string {
+		claims.ExpiresAt = expires
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	forged := map[string]string{
+		"acme key, globex claim": forge(s.tokenKey("acme"), &Claims{Tenant: "globex",
+			RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{tenantAudience("globex")}}}),
+		"platform key, acme claim": forge(s.tokenKey(""), &Claims{Tenant: "acme",
+			RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{tenantAudience("acme")}}}),
+		"acme key, globex audience": forge(s.tokenKey("acme"), &Claims{Tenant: "acme",
+			RegisteredClaims: jwt.RegisteredClaims{Audience: jwt.ClaimStrings{tenantAudience("globex")}}}),
+		"acme key, no audience": forge(s.tokenKey("acme"), &Claims{Tenant: "acme"}),
+	}
+	for name, token := range forged {
+		if err := s.parseToken(token, &Claims{}); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	// Platform tokens issued before audiences stay valid
+	if err := s.parseToken(forge(s.tokenKey(""), &Claims{Username: "admin"}), &Claims{}); err != nil {
+		t.Errorf("platform token without audience: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// listenFDsEnv lists the addresses of the listeners a new process
+	// inherits, one per line, starting at file descriptor 3
+	listenFDsEnv = "OTTER_LISTEN_FDS"
+	// readyFDEnv is the descriptor a new process reports readiness on
+	readyFDEnv = "OTTER_READY_FD"
+
+	// upgradeTimeout bounds how long a new process may take to get ready
+	upgradeTimeout = time.Minute
+	// drainGrace is how long connections may outlast the drain window
+	drainGrace = 5 * time.Second
+)
+
+var (
+	inheritOnce sync.Once
+	inherited   map[string]net.Listener
+)
+
+// inheritedListener returns the listener on addr passed down by the process
+// that started this one with Upgrade, if any. Each is handed out once.
+func inheritedListener(addr string) (net.Listener, bool) {
+	inheritOnce.Do(func() {
+		inherited = make(map[string]net.Listener)
+		addrs := os.Getenv(listenFDsEnv)
+		if addrs == "" {
+			return
+		}
+		os.Unsetenv(listenFDsEnv)
+		for i, addr := range strings.Split(addrs, "\n") {
+			f := os.NewFile(uintptr(3+i), addr)
+			l, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				continue
+			}
+			if strings.HasPrefix(addr, unixPrefix) {
+				l = unixListener{l}
+			}
+			inherited[addr] = l
+		}
+	})
+	l, ok := inherited[addr]
+	delete(inherited, addr)
+	return l, ok
+}
+
+// Upgrade starts a new process from the current executable with the same
+// arguments, handing it the listeners opened for addrs so no connection is
+// refused in between. It returns once the new process has called
+// NotifyReady, after which this process should Drain. If the new process
+// fails to start or get ready, it is stopped and this one keeps serving.
+func Upgrade(addrs []string, listeners []net.Listener) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		if u, ok := l.(unixListener); ok {
+			l = u.Listener
+			// The socket file now belongs to the new process
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+		}
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("cannot pass on listener %s", l.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer ready.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		listenFDsEnv+"="+strings.Join(addrs, "\n"),
+		readyFDEnv+"="+strconv.Itoa(3+len(listeners)))
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Only the new process holds the write end now, so the read below
+	// fails if it exits without reporting readiness
+	readyW.Close()
+
+	result := make(chan error, 1)
+	go func() {
+		if _, err := ready.Read(make([]byte, 1)); err != nil {
+			result <- errors.New("new process exited before getting ready")
+			return
+		}
+		result <- nil
+	}()
+	select {
+	case err = <-result:
+	case <-time.After(upgradeTimeout):
+		err = errors.New("new process did not get ready in time")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	cmd.Process.Release()
+	return nil
+}
+
+// NotifyReady tells the process that started this one with Upgrade that it
+// is serving, so that process can drain. It does nothing otherwise.
+func NotifyReady() {
+	fd, err := strconv.Atoi(os.Getenv(readyFDEnv))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(readyFDEnv)
+	f := os.NewFile(uintptr(fd), "ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// Drain stops accepting connections and ends the watches held open within
+// window, each at a random moment so watchers reconnect to the next process
+// spread out instead of in a thundering herd. Other requests in flight are
+// completed. It returns once every connection has closed, or window plus a
+// grace period has passed.
+func (s *Server) Drain(window time.Duration) {
+	s.logger.Info("Draining", zap.Duration("window", window),
+		zap.Int64("subscriptions", s.watcher.Stats().Subscriptions))
+	s.watcher.Drain(window)
+
+	ctx, cancel := context.WithTimeout(context.Background(), window+drainGrace)
+	defer cancel()
+
+	s.httpMu.Lock()
+	servers := s.httpServers
+	s.httpMu.Unlock()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				s.logger.Warn("Requests still in flight after draining", zap.Error(err))
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	// WebSocket watches are not tracked by the HTTP servers; they close
+	// once the drain ends their subscriptions
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for s.watcher.Stats().Subscriptions > 0 {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			s.logger.Warn("Watches still open after draining", zap.Int64("subscriptions", s.watcher.Stats().Subscriptions))
+			return
+		}
+	}
+	s.logger.Info("Drained")
+}
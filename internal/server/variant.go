@@ -0,0 +1,309 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// labelPattern is what label names and values, and variant names, may
+// consist of
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// Labels and variant names starting with reservedPrefix belong to the
+// server. The labels below identify a client instance, so that an override
+// variant can select it alone; clients cannot set them themselves.
+const (
+	reservedPrefix = "otter."
+	clientIDLabel  = reservedPrefix + "client_id"
+	clientIPLabel  = reservedPrefix + "ip"
+)
+
+// selectorLabels returns the labels variant selectors are matched against
+// for a client: its own labels and the labels identifying its instance
+func (info SubscriberInfo) selectorLabels() map[string]string {
+	labels := make(map[string]string, len(info.Labels)+2)
+	for name, value := range info.Labels {
+		labels[name] = value
+	}
+	if info.ClientID != "" {
+		labels[clientIDLabel] = info.ClientID
+	}
+	if info.IP != "" {
+		labels[clientIPLabel] = info.IP
+	}
+	return labels
+}
+
+// isOverride reports whether a variant is an override targeted at a single
+// client instance
+func isOverride(v *model.ConfigVariant) bool {
+	return strings.HasPrefix(v.Name, reservedPrefix)
+}
+
+// parseLabels reads the labels of a client from its labels header, a list
+// of name=value pairs separated by commas. Malformed pairs are ignored.
+func parseLabels(header string) map[string]string {
+	if header == "" {
+		return nil
+	}
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && labelPattern.MatchString(name) && labelPattern.MatchString(value) && !strings.HasPrefix(name, reservedPrefix) {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+// selectVariant returns the variant served to a client with labels: an
+// override targeted at the client, else the matching variant with the most
+// specific selector, the first by name on a tie, or nil if none matches
+func selectVariant(variants []*model.ConfigVariant, labels map[string]string) *model.ConfigVariant {
+	var selected *model.ConfigVariant
+	for _, v := range variants {
+		if !v.Matches(labels) {
+			continue
+		}
+		if selected == nil || (isOverride(v) && !isOverride(selected)) ||
+			(isOverride(v) == isOverride(selected) && len(v.Selector) > len(selected.Selector)) {
+			selected = v
+		}
+	}
+	return selected
+}
+
+// variantConfig returns cfg as served to a client with labels: a copy
+// carrying the value of the variant they select, or cfg itself if they
+// select none. Clients without labels always get cfg.
+func (s *Server) variantConfig(ctx context.Context, labels map[string]string, cfg *model.Config) (*model.Config, error) {
+	if len(labels) == 0 || cfg.Version < 0 {
+		return cfg, nil
+	}
+	variants, err := s.store.ListVariants(ctx, cfg.Namespace, cfg.Group, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	variant := selectVariant(variants, labels)
+	if variant == nil {
+		return cfg, nil
+	}
+	out := *cfg
+	out.Value = variant.Value
+	out.Variant = variant.Name
+	store.SetChecksums(&out)
+	return &out, nil
+}
+
+// variantEvents returns watch events as served to a client with labels,
+// each change carrying the variant the client selects, signed again
+func (s *Server) variantEvents(ctx context.Context, labels map[string]string, events []*model.ConfigEvent) ([]*model.ConfigEvent, error) {
+	if len(labels) == 0 {
+		return events, nil
+	}
+	out := make([]*model.ConfigEvent, len(events))
+	for i, event := range events {
+		out[i] = event
+		if event == nil || event.Type == model.EventDelete {
+			continue
+		}
+		cfg, err := s.variantConfig(ctx, labels, event.Config)
+		if err != nil {
+			return nil, err
+		}
+		if cfg != event.Config {
+			out[i] = &model.ConfigEvent{Type: event.Type, Config: s.signed(cfg)}
+		}
+	}
+	return out, nil
+}
+
+// listVariantsHandler returns the variants of a config ordered by name
+func (s *Server) listVariantsHandler(c *gin.Context) {
+	variants, err := s.store.ListVariants(c.Request.Context(), c.Param("namespace"), c.Param("group"), c.Param("key"))
+	if err != nil {
+		s.logger.Error("Failed to list variants", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, variants)
+}
+
+// setVariantHandler creates or replaces a variant of a config. The variant
+// takes the config's type, and the config moves to a new version so
+// watchers pick up what they are now served.
+func (s *Server) setVariantHandler(c *gin.Context) {
+	namespace, group, key, name := c.Param("namespace"), c.Param("group"), c.Param("key"), c.Param("variant")
+	var req struct {
+		Selector map[string]string `json:"selector" binding:"required"`
+		Value    string            `json:"value" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Selector) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body, a value and a selector of at least one label are required"})
+		return
+	}
+	if !labelPattern.MatchString(name) || strings.HasPrefix(name, reservedPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant name"})
+		return
+	}
+	for label, value := range req.Selector {
+		if !labelPattern.MatchString(label) || !labelPattern.MatchString(value) || strings.HasPrefix(label, reservedPrefix) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid selector label " + label + "=" + value})
+			return
+		}
+	}
+
+	s.putVariant(c, &model.ConfigVariant{
+		Namespace: namespace,
+		Group:     group,
+		Key:       key,
+		Name:      name,
+		Selector:  req.Selector,
+		Value:     req.Value,
+		UpdatedBy: c.GetString("username"),
+		UpdatedAt: time.Now(),
+	})
+}
+
+// putVariant stores a variant of the config in the request's path and
+// responds with it, or with an error if the user may not change the config
+// or the value is unfit for it
+func (s *Server) putVariant(c *gin.Context, variant *model.ConfigVariant) {
+	config, ok := s.variantBase(c)
+	if !ok {
+		return
+	}
+	if err := validateConfigValue(config.Type, variant.Value); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sized := *config
+	sized.Value = variant.Value
+	if err := s.checkQuota(c.Request.Context(), &sized); err != nil {
+		s.respondQuotaError(c, err)
+		return
+	}
+
+	if err := s.store.SetVariant(c.Request.Context(), variant); err != nil {
+		s.logger.Error("Failed to set variant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.republishVariants(c, config) {
+		return
+	}
+	c.JSON(http.StatusOK, variant)
+}
+
+// deleteVariantHandler deletes a variant of a config, its clients being
+// served the config's own value again
+func (s *Server) deleteVariantHandler(c *gin.Context) {
+	s.removeVariant(c, c.Param("variant"), "Variant not found")
+}
+
+// removeVariant deletes the named variant of the config in the request's
+// path, responding with notFound if there is no such variant
+func (s *Server) removeVariant(c *gin.Context, name, notFound string) {
+	config, ok := s.variantBase(c)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteVariant(c.Request.Context(), config.Namespace, config.Group, config.Key, name); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+			return
+		}
+		s.logger.Error("Failed to delete variant", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.republishVariants(c, config) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// variantBase returns the config in the request's path after checking that
+// the user may change its variants, having responded with an error if not
+func (s *Server) variantBase(c *gin.Context) (*model.Config, bool) {
+	namespace, group, key := c.Param("namespace"), c.Param("group"), c.Param("key")
+	if err := s.checkLock(c.Request.Context(), namespace, group, key); err != nil {
+		s.respondLockError(c, err)
+		return nil, false
+	}
+	if err := s.checkOwner(c.Request.Context(), namespace, group, key, c.GetString("username")); err != nil {
+		s.respondOwnerError(c, err)
+		return nil, false
+	}
+	config, err := s.store.Get(c.Request.Context(), namespace, group, key)
+	if err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Config not found"})
+			return nil, false
+		}
+		s.logger.Error("Failed to get config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return config, true
+}
+
+// republishVariants writes config again as a new version after its
+// variants changed, recording a VARIANT history entry and notifying
+// watchers and owners, so clients holding the old version fetch their
+// variant again. It responds with an error and returns false on failure.
+func (s *Server) republishVariants(c *gin.Context, config *model.Config) bool {
+	ctx := c.Request.Context()
+	username := c.GetString("username")
+	config.UpdatedBy = username
+	config.UpdatedAt = time.Now()
+	if err := s.store.Put(ctx, config); err != nil {
+		s.logger.Error("Failed to put config", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+
+	history := &model.ConfigHistory{
+		Namespace:   config.Namespace,
+		Group:       config.Group,
+		Key:         config.Key,
+		Value:       config.Value,
+		Type:        config.Type,
+		ContentType: config.ContentType,
+		Version:     config.Version,
+		OpType:      "VARIANT",
+		CreatedBy:   username,
+		CreatedAt:   time.Now(),
+	}
+	_ = s.store.CreateHistory(ctx, history)
+
+	s.notify(ctx, model.EventPut, config)
+	s.notifyOwners(ctx, config.Namespace, config.Group, config.Key, config.Version, "VARIANT", username)
+	s.publishChange(ctx, config.Namespace, config.Group, config.Key, config.Version, "VARIANT", username)
+	return true
+}
+
+// deleteVariants removes the variants of a deleted config, so a config
+// created again under its key starts without any. Failures are logged, the
+// config having been deleted already.
+func (s *Server) deleteVariants(ctx context.Context, namespace, group, key string) {
+	variants, err := s.store.ListVariants(ctx, namespace, group, key)
+	if err != nil {
+		s.logger.Warn("Failed to list variants of deleted config", zap.Error(err))
+		return
+	}
+	for _, v := range variants {
+		if err := s.store.DeleteVariant(ctx, namespace, group, key, v.Name); err != nil && err != store.ErrNotFound {
+			s.logger.Warn("Failed to delete variant of deleted config", zap.String("variant", v.Name), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestVariantConfig tests that clients are served the matching variant
+// with the most specific selector, and the config's own value without
+// labels or a matching variant
+func TestVariantConfig(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), store: store.NewTenantStore(store.NewInMemoryStore())}
+	ctx := context.Background()
+	cfg := &model.Config{Namespace: "public", Group: "app", Key: "endpoint", Value: "https://api.example.com", Type: "text"}
+	if err := s.store.Put(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []*model.ConfigVariant{
+		{Name: "eu", Selector: map[string]string{"region": "eu"}, Value: "https://eu.example.com"},
+		{Name: "eu-canary", Selector: map[string]string{"region": "eu", "tier": "canary"}, Value: "https://canary.eu.example.com"},
+	} {
+		v.Namespace, v.Group, v.Key = "public", "app", "endpoint"
+		if err := s.store.SetVariant(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		header  string
+		variant string
+		value   string
+	}{
+		{"", "", "https://api.example.com"},
+		{"region=us", "", "https://api.example.com"},
+		{"region=eu", "eu", "https://eu.example.com"},
+		{"tier=canary, region=eu", "eu-canary", "https://canary.eu.example.com"},
+		{"region=eu,tier=stable,bogus", "eu", "https://eu.example.com"},
+	}
+	for _, tt := range tests {
+		served, err := s.variantConfig(ctx, parseLabels(tt.header), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if served.Variant != tt.variant || served.Value != tt.value {
+			t.Errorf("labels %q served variant %q with %q, want %q with %q", tt.header, served.Variant, served.Value, tt.variant, tt.value)
+		}
+		if tt.variant != "" && (served.SHA256 == cfg.SHA256 || served.Size != int64(len(tt.value))) {
+			t.Errorf("labels %q served the checksums of the config's own value", tt.header)
+		}
+	}
+}
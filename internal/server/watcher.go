@@ -0,0 +1,443 @@
+package server
+
+import (
+	"hash/maphash"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// SubscriberInfo describes who is holding a watch subscription
+type SubscriberInfo struct {
+	// Tenant is the tenant whose namespaces the subscription watches
+	Tenant         string            `json:"tenant,omitempty"`
+	ClientID       string            `json:"client_id,omitempty"`
+	Username       string            `json:"username,omitempty"`
+	IP             string            `json:"ip"`
+	Labels         map[string]string `json:"labels,omitempty"` // select the config variants served
+	ConnectedSince time.Time         `json:"connected_since"`
+}
+
+// WatchedKey summarizes the subscriptions currently held on a single config key
+type WatchedKey struct {
+	Namespace   string           `json:"namespace"`
+	Group       string           `json:"group"`
+	Key         string           `json:"key"`
+	Count       int              `json:"count"`
+	Subscribers []SubscriberInfo `json:"subscribers"`
+}
+
+// WatcherStats summarizes the subscriptions held and the change events
+// fanned out to them
+type WatcherStats struct {
+	// Subscriptions is the number of registered subscriptions, across long
+	// polls and WebSocket connections
+	Subscriptions int64 `json:"subscriptions"`
+	// Notifications counts the change events notified to the watcher
+	Notifications int64 `json:"notifications"`
+	// Deliveries counts the events queued to subscriptions, and
+	// DeliveriesPerSecond averages them over the last minute
+	Deliveries          int64   `json:"deliveries"`
+	DeliveriesPerSecond float64 `json:"deliveries_per_second"`
+	// Coalesced counts queued events replaced by a newer change of the same
+	// key before the subscriber read them
+	Coalesced int64 `json:"coalesced"`
+	// Dropped counts events lost because a subscription's queue was full,
+	// each of which ended the subscription
+	Dropped int64 `json:"dropped"`
+}
+
+type watchKey struct {
+	namespace, group, key string
+}
+
+// subscriberQueueSize bounds the number of distinct config keys with a
+// change waiting for one subscription
+const subscriberQueueSize = 1024
+
+// Subscription is a persistent registration for changes to one or more
+// keys. It receives every change until it is unsubscribed or expired.
+//
+// Changes wait in a queue holding the latest event per config key, so a
+// slow reader never misses the final value of a key, only intermediate
+// ones. If more keys are waiting than the queue holds, the subscription is
+// ended so the client resynchronizes rather than silently losing changes.
+type Subscription struct {
+	keys []watchKey
+	info SubscriberInfo
+	done chan struct{}
+	once sync.Once
+
+	mu      sync.Mutex
+	pending []watchKey // config keys with a queued event, oldest first
+	events  map[watchKey]*model.ConfigEvent
+	ready   chan struct{}
+}
+
+// Ready returns a channel that receives a value when events are queued
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Next removes and returns the oldest queued event, or nil if none is queued
+func (s *Subscription) Next() *model.ConfigEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return nil
+	}
+	ck := s.pending[0]
+	s.pending = s.pending[1:]
+	event := s.events[ck]
+	delete(s.events, ck)
+	if len(s.pending) > 0 {
+		s.signal()
+	}
+	return event
+}
+
+// drain removes and returns every queued event, oldest first
+func (s *Subscription) drain() []*model.ConfigEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]*model.ConfigEvent, len(s.pending))
+	for i, ck := range s.pending {
+		events[i] = s.events[ck]
+		delete(s.events, ck)
+	}
+	s.pending = nil
+	return events
+}
+
+// push queues an event, replacing a queued event of the same config key.
+// It reports whether the event was queued, which fails if the queue is
+// full, and whether it replaced an event.
+func (s *Subscription) push(event *model.ConfigEvent) (queued, replaced bool) {
+	ck := watchKey{event.Config.Namespace, event.Config.Group, event.Config.Key}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, replaced = s.events[ck]; !replaced {
+		if len(s.pending) >= subscriberQueueSize {
+			return false, false
+		}
+		s.pending = append(s.pending, ck)
+	}
+	s.events[ck] = event
+	s.signal()
+	return true, replaced
+}
+
+// signal wakes a reader waiting on Ready without blocking
+func (s *Subscription) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Done returns a channel that is closed once the subscription has ended,
+// either through Unsubscribe, because an admin expired it or because its
+// queue overflowed
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// watcherShards is the number of independently locked parts of the
+// subscription registry, so subscribes and notifies on different keys do
+// not contend
+const watcherShards = 64
+
+// watcherShard holds the subscriptions of the keys hashing to it. Each key
+// maps to a set so a subscription is removed in constant time.
+type watcherShard struct {
+	mu          sync.Mutex
+	subscribers map[watchKey]map[*Subscription]struct{}
+}
+
+type Watcher struct {
+	seed   maphash.Seed
+	shards [watcherShards]watcherShard
+
+	subscriptions atomic.Int64
+	notifications atomic.Int64
+	deliveries    atomic.Int64
+	coalesced     atomic.Int64
+	dropped       atomic.Int64
+	deliveryRate  rateMeter
+
+	// drainWindow is the window subscriptions are ended within once the
+	// watcher drains, zero until then
+	drainWindow atomic.Int64
+
+	// listeners are called with every notified event, before it is delivered
+	listeners []func(tenant string, event *model.ConfigEvent)
+}
+
+func NewWatcher() *Watcher {
+	w := &Watcher{seed: maphash.MakeSeed()}
+	for i := range w.shards {
+		w.shards[i].subscribers = make(map[watchKey]map[*Subscription]struct{})
+	}
+	return w
+}
+
+// shard returns the part of the registry holding wk
+func (w *Watcher) shard(wk watchKey) *watcherShard {
+	var h maphash.Hash
+	h.SetSeed(w.seed)
+	h.WriteString(wk.namespace)
+	h.WriteByte(0)
+	h.WriteString(wk.group)
+	h.WriteByte(0)
+	h.WriteString(wk.key)
+	return &w.shards[h.Sum64()%watcherShards]
+}
+
+// Subscribe registers a subscription for a config key. An empty key
+// subscribes to every key in the group, and an empty group and key to every
+// key in the namespace. Callers must Unsubscribe once they stop reading
+// from it.
+func (w *Watcher) Subscribe(namespace, group, key string, info SubscriberInfo) *Subscription {
+	return w.subscribe([]watchKey{{namespace, group, key}}, info)
+}
+
+// subscribe registers one subscription for several keys, so a single
+// connection can wait on all of them. Keys are registered under the stored
+// names of the tenant's namespaces, so tenants only see their own changes.
+func (w *Watcher) subscribe(keys []watchKey, info SubscriberInfo) *Subscription {
+	if info.ConnectedSince.IsZero() {
+		info.ConnectedSince = time.Now()
+	}
+	if info.Tenant != "" {
+		stored := make([]watchKey, len(keys))
+		for i, wk := range keys {
+			stored[i] = watchKey{store.TenantName(info.Tenant, wk.namespace), wk.group, wk.key}
+		}
+		keys = stored
+	}
+	sub := &Subscription{
+		keys:   keys,
+		info:   info,
+		done:   make(chan struct{}),
+		events: make(map[watchKey]*model.ConfigEvent),
+		ready:  make(chan struct{}, 1),
+	}
+
+	for _, wk := range keys {
+		shard := w.shard(wk)
+		shard.mu.Lock()
+		subs := shard.subscribers[wk]
+		if subs == nil {
+			subs = make(map[*Subscription]struct{})
+			shard.subscribers[wk] = subs
+		}
+		subs[sub] = struct{}{}
+		shard.mu.Unlock()
+	}
+	w.subscriptions.Add(1)
+	if window := time.Duration(w.drainWindow.Load()); window > 0 {
+		w.expireWithin(sub, window)
+	}
+	return sub
+}
+
+// Unsubscribe ends a subscription. It is safe to call more than once.
+func (w *Watcher) Unsubscribe(sub *Subscription) {
+	w.remove(sub)
+	sub.once.Do(func() {
+		close(sub.done)
+		w.subscriptions.Add(-1)
+	})
+}
+
+// remove drops sub from the registry
+func (w *Watcher) remove(sub *Subscription) {
+	for _, wk := range sub.keys {
+		shard := w.shard(wk)
+		shard.mu.Lock()
+		subs := shard.subscribers[wk]
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(shard.subscribers, wk)
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// OnNotify registers fn to be called with every change event notified to
+// the watcher. It must be called before the watcher is notified.
+func (w *Watcher) OnNotify(fn func(tenant string, event *model.ConfigEvent)) {
+	w.listeners = append(w.listeners, fn)
+}
+
+// Notify delivers a change event of a tenant's config, or one outside any
+// tenant if tenant is empty, to every subscription of its key, group or
+// namespace. Subscriptions stay registered for further changes.
+func (w *Watcher) Notify(tenant string, event *model.ConfigEvent) {
+	for _, fn := range w.listeners {
+		fn(tenant, event)
+	}
+	config := event.Config
+	namespace := store.TenantName(tenant, config.Namespace)
+	matching := [...]watchKey{
+		{namespace, config.Group, config.Key},
+		{namespace, config.Group, ""},
+		{namespace, "", ""},
+	}
+
+	var subs []*Subscription
+	multiKey := false
+	for _, wk := range matching {
+		shard := w.shard(wk)
+		shard.mu.Lock()
+		for sub := range shard.subscribers[wk] {
+			subs = append(subs, sub)
+			multiKey = multiKey || len(sub.keys) > 1
+		}
+		shard.mu.Unlock()
+	}
+	if multiKey {
+		// A subscription spanning several of the matching keys is notified once
+		subs = uniqueSubscriptions(subs)
+	}
+
+	var delivered, coalesced int64
+	for _, sub := range subs {
+		queued, replaced := sub.push(event)
+		if !queued {
+			w.dropped.Add(1)
+			w.Unsubscribe(sub)
+			continue
+		}
+		delivered++
+		if replaced {
+			coalesced++
+		}
+	}
+	w.notifications.Add(1)
+	w.deliveries.Add(delivered)
+	w.coalesced.Add(coalesced)
+	w.deliveryRate.add(time.Now(), delivered)
+}
+
+// Stats returns the current subscription count and fan-out counters
+func (w *Watcher) Stats() WatcherStats {
+	return WatcherStats{
+		Subscriptions:       w.subscriptions.Load(),
+		Notifications:       w.notifications.Load(),
+		Deliveries:          w.deliveries.Load(),
+		DeliveriesPerSecond: w.deliveryRate.rate(time.Now()),
+		Coalesced:           w.coalesced.Load(),
+		Dropped:             w.dropped.Load(),
+	}
+}
+
+// uniqueSubscriptions removes repeated subscriptions from subs in place
+func uniqueSubscriptions(subs []*Subscription) []*Subscription {
+	seen := make(map[*Subscription]struct{}, len(subs))
+	unique := subs[:0]
+	for _, sub := range subs {
+		if _, dup := seen[sub]; !dup {
+			seen[sub] = struct{}{}
+			unique = append(unique, sub)
+		}
+	}
+	return unique
+}
+
+// Snapshot returns the current subscriptions grouped by key, optionally filtered by namespace
+func (w *Watcher) Snapshot(namespace string) []WatchedKey {
+	result := []WatchedKey{}
+	for i := range w.shards {
+		shard := &w.shards[i]
+		shard.mu.Lock()
+		for wk, subs := range shard.subscribers {
+			if namespace != "" && wk.namespace != namespace {
+				continue
+			}
+			item := WatchedKey{Namespace: wk.namespace, Group: wk.group, Key: wk.key, Count: len(subs)}
+			for sub := range subs {
+				item.Subscribers = append(item.Subscribers, sub.info)
+			}
+			result = append(result, item)
+		}
+		shard.mu.Unlock()
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		if result[i].Group != result[j].Group {
+			return result[i].Group < result[j].Group
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// Drain ends every subscription, including those made from now on, at a
+// random moment within window, so the clients they hold reconnect elsewhere
+// spread out rather than all at once. Long polls return 304 as if expired.
+func (w *Watcher) Drain(window time.Duration) {
+	if window <= 0 {
+		window = time.Nanosecond
+	}
+	w.drainWindow.Store(int64(window))
+
+	subs := make(map[*Subscription]struct{})
+	for i := range w.shards {
+		shard := &w.shards[i]
+		shard.mu.Lock()
+		for _, keySubs := range shard.subscribers {
+			for sub := range keySubs {
+				subs[sub] = struct{}{}
+			}
+		}
+		shard.mu.Unlock()
+	}
+	for sub := range subs {
+		w.expireWithin(sub, window)
+	}
+}
+
+// Draining reports whether Drain has been called
+func (w *Watcher) Draining() bool {
+	return w.drainWindow.Load() > 0
+}
+
+// expireWithin ends sub at a random moment within window
+func (w *Watcher) expireWithin(sub *Subscription, window time.Duration) {
+	time.AfterFunc(rand.N(window), func() { w.Unsubscribe(sub) })
+}
+
+// Expire ends the matching subscriptions without a change, so their long
+// polls return 304 immediately. Empty group or key match everything within
+// the namespace. It returns the number of subscriptions expired.
+func (w *Watcher) Expire(namespace, group, key string) int {
+	expired := make(map[*Subscription]struct{})
+	for i := range w.shards {
+		shard := &w.shards[i]
+		shard.mu.Lock()
+		for wk, subs := range shard.subscribers {
+			if wk.namespace != namespace || (group != "" && wk.group != group) || (key != "" && wk.key != key) {
+				continue
+			}
+			for sub := range subs {
+				expired[sub] = struct{}{}
+			}
+		}
+		shard.mu.Unlock()
+	}
+
+	// Shards are unlocked first, since a subscription may span several
+	for sub := range expired {
+		w.Unsubscribe(sub)
+	}
+	return len(expired)
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// benchmarkSubscriptions is the number of concurrent subscriptions the
+// watcher benchmarks register
+const benchmarkSubscriptions = 100000
+
+func putEvent(namespace, group, key string) *model.ConfigEvent {
+	return &model.ConfigEvent{Type: model.EventPut, Config: &model.Config{Namespace: namespace, Group: group, Key: key, Version: 1}}
+}
+
+// TestWatcherNotify tests that a change reaches the subscriptions of its
+// key, group and namespace exactly once, and no others
+func TestWatcherNotify(t *testing.T) {
+	w := NewWatcher()
+	key := w.Subscribe("prod", "billing", "rate", SubscriberInfo{})
+	group := w.Subscribe("prod", "billing", "", SubscriberInfo{})
+	namespace := w.Subscribe("prod", "", "", SubscriberInfo{})
+	other := w.Subscribe("prod", "billing", "currency", SubscriberInfo{})
+	both := w.subscribe([]watchKey{{"prod", "billing", "rate"}, {"prod", "", ""}}, SubscriberInfo{})
+
+	w.Notify("", putEvent("prod", "billing", "rate"))
+
+	for name, sub := range map[string]*Subscription{"key": key, "group": group, "namespace": namespace, "key and namespace": both} {
+		if events := sub.drain(); len(events) != 1 {
+			t.Errorf("%s subscription received %d events, want 1", name, len(events))
+		}
+	}
+	if event := other.Next(); event != nil {
+		t.Errorf("subscription of another key received %+v", event.Config)
+	}
+
+	if n := w.Expire("prod", "", ""); n != 5 {
+		t.Errorf("expired %d subscriptions, want 5", n)
+	}
+	if snapshot := w.Snapshot(""); len(snapshot) != 0 {
+		t.Errorf("registry not empty after expiring: %+v", snapshot)
+	}
+}
+
+// TestWatcherDrain tests that draining ends existing subscriptions and
+// those made afterwards within the window
+func TestWatcherDrain(t *testing.T) {
+	w := NewWatcher()
+	before := w.Subscribe("prod", "billing", "rate", SubscriberInfo{})
+	w.Drain(50 * time.Millisecond)
+	after := w.Subscribe("prod", "billing", "", SubscriberInfo{})
+
+	for name, sub := range map[string]*Subscription{"existing": before, "new": after} {
+		select {
+		case <-sub.Done():
+		case <-time.After(time.Second):
+			t.Errorf("%s subscription not ended by the drain", name)
+		}
+	}
+	if !w.Draining() || w.Stats().Subscriptions != 0 {
+		t.Errorf("draining %v with %d subscriptions left", w.Draining(), w.Stats().Subscriptions)
+	}
+}
+
+// TestWatcherTenants tests that changes only reach subscriptions of the
+// tenant whose config changed
+func TestWatcherTenants(t *testing.T) {
+	w := NewWatcher()
+	acme := w.Subscribe("prod", "billing", "rate", SubscriberInfo{Tenant: "acme"})
+	globex := w.Subscribe("prod", "billing", "rate", SubscriberInfo{Tenant: "globex"})
+	untenanted := w.Subscribe("prod", "billing", "rate", SubscriberInfo{})
+
+	w.Notify("acme", putEvent("prod", "billing", "rate"))
+
+	if event := acme.Next(); event == nil || event.Config.Namespace != "prod" {
+		t.Errorf("tenant subscription received %+v, want a change of prod", event)
+	}
+	for name, sub := range map[string]*Subscription{"other tenant": globex, "untenanted": untenanted} {
+		if event := sub.Next(); event != nil {
+			t.Errorf("%s subscription received %+v", name, event.Config)
+		}
+	}
+}
+
+// TestWatcherCoalesce tests that a slow subscription receives the latest
+// version of every changed key, in the order the keys first changed
+func TestWatcherCoalesce(t *testing.T) {
+	w := NewWatcher()
+	sub := w.Subscribe("prod", "billing", "", SubscriberInfo{})
+	defer w.Unsubscribe(sub)
+
+	for i, key := range []string{"rate", "currency", "rate", "rate", "limit"} {
+		event := putEvent("prod", "billing", key)
+		event.Config.Version = int64(i + 1)
+		w.Notify("", event)
+	}
+
+	select {
+	case <-sub.Ready():
+	default:
+		t.Fatal("subscription not ready after changes")
+	}
+	var got []string
+	for event := sub.Next(); event != nil; event = sub.Next() {
+		got = append(got, fmt.Sprintf("%s@%d", event.Config.Key, event.Config.Version))
+	}
+	if want := "[rate@4 currency@2 limit@5]"; fmt.Sprint(got) != want {
+		t.Errorf("received %v, want %s", got, want)
+	}
+}
+
+// TestWatcherOverflow tests that a subscription with more changed keys
+// waiting than its queue holds is ended instead of losing changes
+func TestWatcherOverflow(t *testing.T) {
+	w := NewWatcher()
+	sub := w.Subscribe("prod", "", "", SubscriberInfo{})
+	for i := 0; i <= subscriberQueueSize; i++ {
+		w.Notify("", putEvent("prod", "billing", fmt.Sprintf("k%d", i)))
+	}
+
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("subscription not ended after its queue overflowed")
+	}
+	if snapshot := w.Snapshot(""); len(snapshot) != 0 {
+		t.Errorf("overflowed subscription still registered: %+v", snapshot)
+	}
+}
+
+// TestWatcherConcurrent tests that concurrent subscribes, notifies and
+// unsubscribes leave an empty registry behind
+func TestWatcherConcurrent(t *testing.T) {
+	w := NewWatcher()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("k%d", j%10)
+				sub := w.Subscribe("prod", "billing", key, SubscriberInfo{})
+				w.Notify("", putEvent("prod", "billing", key))
+				if j%2 == 0 {
+					w.Unsubscribe(sub)
+				}
+				w.Unsubscribe(sub)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if snapshot := w.Snapshot(""); len(snapshot) != 0 {
+		t.Errorf("registry not empty: %+v", snapshot)
+	}
+}
+
+// TestWatcherStats tests that the watcher counts live subscriptions once
+// each and tells delivered, coalesced and dropped events apart
+func TestWatcherStats(t *testing.T) {
+	w := NewWatcher()
+	slow := w.Subscribe("prod", "billing", "", SubscriberInfo{})
+	defer w.Unsubscribe(slow)
+	full := w.Subscribe("prod", "", "", SubscriberInfo{})
+	gone := w.Subscribe("prod", "billing", "rate", SubscriberInfo{})
+	w.Unsubscribe(gone)
+	w.Unsubscribe(gone)
+
+	w.Notify("", putEvent("prod", "billing", "rate"))
+	w.Notify("", putEvent("prod", "billing", "rate"))
+	for i := 0; i < subscriberQueueSize; i++ {
+		w.Notify("", putEvent("prod", "other", fmt.Sprintf("k%d", i)))
+	}
+
+	select {
+	case <-full.Done():
+	default:
+		t.Fatal("subscription not ended after its queue overflowed")
+	}
+	stats := w.Stats()
+	want := WatcherStats{
+		Subscriptions: 1,
+		Notifications: 2 + subscriberQueueSize,
+		Deliveries:    4 + subscriberQueueSize - 1,
+		Coalesced:     2,
+		Dropped:       1,
+	}
+	if stats.DeliveriesPerSecond <= 0 {
+		t.Errorf("deliveries per second = %v, want positive", stats.DeliveriesPerSecond)
+	}
+	stats.DeliveriesPerSecond = 0
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+// BenchmarkWatcherSubscribe measures subscribing and unsubscribing while
+// benchmarkSubscriptions other subscriptions are registered
+func BenchmarkWatcherSubscribe(b *testing.B) {
+	w := NewWatcher()
+	for i := 0; i < benchmarkSubscriptions; i++ {
+		w.Subscribe("prod", "billing", fmt.Sprintf("k%d", i%1000), SubscriberInfo{})
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			sub := w.Subscribe("prod", "billing", fmt.Sprintf("k%d", i%1000), SubscriberInfo{})
+			w.Unsubscribe(sub)
+			i++
+		}
+	})
+}
+
+// BenchmarkWatcherNotify measures notifying one of 1000 keys sharing
+// benchmarkSubscriptions subscriptions, about 100 per key
+func BenchmarkWatcherNotify(b *testing.B) {
+	w := NewWatcher()
+	for i := 0; i < benchmarkSubscriptions; i++ {
+		w.Subscribe("prod", "billing", fmt.Sprintf("k%d", i%1000), SubscriberInfo{})
+	}
+	events := make([]*model.ConfigEvent, 1000)
+	for i := range events {
+		events[i] = putEvent("prod", "billing", fmt.Sprintf("k%d", i))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			w.Notify("", events[i%len(events)])
+			i++
+		}
+	})
+}
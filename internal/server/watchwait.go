@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultWatchWait is how long a long poll is held for a change when the
+	// client does not ask for a wait
+	defaultWatchWait = 30 * time.Second
+	// DefaultMaxWatchWait bounds the wait a client may ask for unless
+	// SetMaxWatchWait sets another bound
+	DefaultMaxWatchWait = 60 * time.Second
+)
+
+// SetMaxWatchWait bounds how long a long poll is held for a change, whatever
+// wait the client asks for. A bound below the default wait of 30s also
+// shortens the polls of clients that do not ask.
+func (s *Server) SetMaxWatchWait(d time.Duration) {
+	s.maxWatchWait = d
+}
+
+// watchWait returns how long to hold a long poll given its wait query
+// parameter, a duration such as 55s or a number of seconds, bounded by the
+// maximum wait
+func (s *Server) watchWait(param string) (time.Duration, error) {
+	max := s.maxWatchWait
+	if max <= 0 {
+		max = DefaultMaxWatchWait
+	}
+	wait := defaultWatchWait
+	if param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil {
+			seconds, serr := strconv.ParseFloat(param, 64)
+			if serr != nil {
+				return 0, fmt.Errorf("invalid wait %q", param)
+			}
+			d = time.Duration(seconds * float64(time.Second))
+		}
+		if d <= 0 {
+			return 0, fmt.Errorf("wait must be positive")
+		}
+		wait = d
+	}
+	return min(wait, max), nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestWatchWait tests that the wait a long poll asks for is parsed as a
+// duration or seconds and bounded by the maximum, which also bounds the
+// default wait
+func TestWatchWait(t *testing.T) {
+	s := &Server{}
+	for param, want := range map[string]time.Duration{
+		"":      defaultWatchWait,
+		"55s":   55 * time.Second,
+		"5":     5 * time.Second,
+		"500ms": 500 * time.Millisecond,
+		"10m":   DefaultMaxWatchWait,
+	} {
+		if got, err := s.watchWait(param); err != nil || got != want {
+			t.Errorf("watchWait(%q) = %v, %v, want %v", param, got, err, want)
+		}
+	}
+	for _, param := range []string{"0", "-5s", "soon"} {
+		if _, err := s.watchWait(param); err == nil {
+			t.Errorf("watchWait(%q) succeeded", param)
+		}
+	}
+
+	s.SetMaxWatchWait(20 * time.Second)
+	if got, _ := s.watchWait(""); got != 20*time.Second {
+		t.Errorf("default wait under a 20s maximum = %v, want 20s", got)
+	}
+}
+
+// TestLongPollWait tests that a long poll without changes is answered with
+// 304 once the wait it asked for has passed
+func TestLongPollWait(t *testing.T) {
+	s := &Server{
+		logger:  zap.NewNop(),
+		store:   store.NewTenantStore(store.NewInMemoryStore()),
+		watcher: NewWatcher(),
+	}
+	engine := gin.New()
+	engine.GET("/namespaces/:namespace/groups/:group/watch", s.watchGroupHandler)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/namespaces/public/groups/app/watch?wait=100ms", nil))
+	if w.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want 304", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("poll held %v, want about 100ms", elapsed)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/namespaces/public/groups/app/watch?wait=never", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status with an invalid wait = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,429 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// DefaultWebhookInterval is how often the server looks for webhook
+// deliveries that have fallen due
+const DefaultWebhookInterval = time.Second
+
+const (
+	// webhookTimeout bounds each delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookMaxAttempts is how many times a delivery is attempted before it
+	// is dead
+	webhookMaxAttempts = 10
+	// webhookBackoff is the wait after the first failed attempt, doubling
+	// after every further one up to webhookMaxBackoff
+	webhookBackoff    = 10 * time.Second
+	webhookMaxBackoff = time.Hour
+	// webhookBatch is how many due deliveries are attempted per round
+	webhookBatch = 100
+	// webhookRetention is how long delivered deliveries are kept
+	webhookRetention = 7 * 24 * time.Hour
+)
+
+// Headers of webhook requests. The signature is the hex HMAC-SHA256, under
+// the webhook's secret, of the timestamp, a dot and the request body.
+const (
+	webhookSignatureHeader = "X-Otter-Signature"
+	webhookTimestampHeader = "X-Otter-Timestamp"
+	webhookDeliveryHeader  = "X-Otter-Delivery"
+	webhookEventHeader     = "X-Otter-Event"
+)
+
+// StartWebhookDelivery posts queued webhook deliveries to their receivers,
+// looking for due ones every interval, and as soon as new ones are queued,
+// until ctx is cancelled. A failed delivery is retried with exponential
+// backoff until it has been attempted webhookMaxAttempts times, when it is
+// dead until an admin redelivers it. Several servers may share a store:
+// each delivery is claimed before it is attempted.
+func (s *Server) StartWebhookDelivery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWebhookInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var pruned time.Time
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.webhookWake:
+			case <-ctx.Done():
+				return
+			}
+			s.deliverDueWebhooks(ctx)
+			if time.Since(pruned) >= time.Hour {
+				pruned = time.Now()
+				if err := s.store.PruneWebhookDeliveries(ctx, pruned.Add(-webhookRetention)); err != nil {
+					s.logger.Error("Failed to prune webhook deliveries", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// queueWebhookDeliveries queues event for every webhook of its namespace
+func (s *Server) queueWebhookDeliveries(ctx context.Context, event *ChangeEvent) {
+	hooks, err := s.store.ListWebhooks(ctx, event.Namespace)
+	if err != nil {
+		s.logger.Error("Failed to list webhooks", zap.String("namespace", event.Namespace), zap.Error(err))
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Error("Failed to encode webhook payload", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	for _, hook := range hooks {
+		delivery := &model.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Namespace:     event.Namespace,
+			Event:         event.Op,
+			Payload:       string(payload),
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		if err := s.store.CreateWebhookDelivery(ctx, delivery); err != nil {
+			s.logger.Error("Failed to queue webhook delivery", zap.Int64("webhook", hook.ID), zap.Error(err))
+		}
+	}
+	s.wakeWebhooks()
+}
+
+// wakeWebhooks tells the delivery loop that deliveries are due
+func (s *Server) wakeWebhooks() {
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverDueWebhooks attempts every delivery due now, in the tenant that
+// queued it. Once an attempt to a webhook fails, its other deliveries wait
+// for the next round, so a receiver that is down holds up no other.
+func (s *Server) deliverDueWebhooks(ctx context.Context) {
+	deliveries, err := s.store.ListDueWebhookDeliveries(ctx, time.Now(), webhookBatch)
+	if err != nil {
+		s.logger.Error("Failed to list due webhook deliveries", zap.Error(err))
+		return
+	}
+	failed := make(map[int64]bool)
+	for _, delivery := range deliveries {
+		if failed[delivery.WebhookID] {
+			continue
+		}
+		tenant, namespace := store.SplitTenant(delivery.Namespace)
+		tctx := store.WithTenant(ctx, tenant)
+		delivery.Namespace = namespace
+
+		// Claim it for long enough to attempt it; should this server stop
+		// midway, the delivery falls due again afterwards
+		now := time.Now()
+		if err := s.store.ClaimWebhookDelivery(tctx, delivery.ID, now, now.Add(2*webhookTimeout)); err != nil {
+			if err != store.ErrNotFound {
+				s.logger.Error("Failed to claim webhook delivery", zap.Int64("id", delivery.ID), zap.Error(err))
+			}
+			continue
+		}
+		if !s.attemptDelivery(tctx, delivery) {
+			failed[delivery.WebhookID] = true
+		}
+	}
+}
+
+// attemptDelivery posts a claimed delivery to its webhook and records the
+// outcome, reporting whether the receiver accepted it
+func (s *Server) attemptDelivery(ctx context.Context, delivery *model.WebhookDelivery) bool {
+	fields := []zap.Field{
+		zap.Int64("id", delivery.ID),
+		zap.Int64("webhook", delivery.WebhookID),
+		zap.String("namespace", delivery.Namespace),
+	}
+	hook, err := s.store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil && err != store.ErrNotFound {
+		// Left claimed, to be attempted once the claim runs out
+		s.logger.Error("Failed to get webhook", append(fields, zap.Error(err))...)
+		return false
+	}
+	deleted := err == store.ErrNotFound // queued as its webhook was being deleted
+	if deleted {
+		err = fmt.Errorf("webhook deleted")
+	} else {
+		delivery.ResponseStatus, err = s.postDelivery(ctx, hook, delivery)
+	}
+
+	delivery.Attempts++
+	delivery.UpdatedAt = time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = model.DeliveryStatusDelivered
+		delivery.LastError = ""
+	case deleted || delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = model.DeliveryStatusDead
+		delivery.LastError = err.Error()
+		s.logger.Warn("Webhook delivery failed for the last time", append(fields, zap.Int("attempts", delivery.Attempts), zap.Error(err))...)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = delivery.UpdatedAt.Add(webhookRetryDelay(delivery.Attempts))
+		s.logger.Warn("Webhook delivery failed, will retry", append(fields, zap.Int("attempts", delivery.Attempts), zap.Time("next_attempt_at", delivery.NextAttemptAt), zap.Error(err))...)
+	}
+	if err := s.store.UpdateWebhookDelivery(ctx, delivery); err != nil && err != store.ErrNotFound {
+		s.logger.Error("Failed to update webhook delivery", append(fields, zap.Error(err))...)
+	}
+	return err == nil
+}
+
+// postDelivery posts a delivery's payload, signed, to hook, returning the
+// receiver's status; any status but 2xx is an error
+func (s *Server) postDelivery(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "otter-webhook")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of a webhook request
+func signWebhook(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns how long to wait before retrying a delivery
+// attempted attempts times
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookBackoff
+	for i := 1; i < attempts && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxBackoff)
+}
+
+// newWebhookSecret returns a random secret for signing webhook requests
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// webhook returns the webhook in the request's path, having responded with
+// an error if there is none in its namespace
+func (s *Server) webhook(c *gin.Context) (*model.Webhook, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return nil, false
+	}
+	hook, err := s.store.GetWebhook(c.Request.Context(), id)
+	if err == store.ErrNotFound || (err == nil && hook.Namespace != c.Param("namespace")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	if err != nil {
+		s.logger.Error("Failed to get webhook", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return hook, true
+}
+
+// listWebhooksHandler returns the webhooks of a namespace, without their
+// secrets
+func (s *Server) listWebhooksHandler(c *gin.Context) {
+	hooks, err := s.store.ListWebhooks(c.Request.Context(), c.Param("namespace"))
+	if err != nil {
+		s.logger.Error("Failed to list webhooks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// createWebhookHandler adds a webhook receiving the changes of a namespace.
+// Its secret is generated unless one is given, and only returned here.
+func (s *Server) createWebhookHandler(c *gin.Context) {
+	var req struct {
+		URL    string `json:"url" binding:"required"`
+		Secret string `json:"secret"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must be an absolute http or https URL"})
+		return
+	}
+	if req.Secret == "" {
+		req.Secret = newWebhookSecret()
+	}
+
+	hook := &model.Webhook{
+		Namespace: c.Param("namespace"),
+		URL:       req.URL,
+		Secret:    req.Secret,
+		CreatedBy: c.GetString("username"),
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.CreateWebhook(c.Request.Context(), hook); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Namespace not found"})
+			return
+		}
+		s.logger.Error("Failed to create webhook", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Created webhook",
+		zap.Int64("id", hook.ID),
+		zap.String("namespace", hook.Namespace),
+		zap.String("url", hook.URL),
+		zap.String("operator", hook.CreatedBy))
+	c.JSON(http.StatusCreated, hook)
+}
+
+// deleteWebhookHandler deletes a webhook along with its queued deliveries
+func (s *Server) deleteWebhookHandler(c *gin.Context) {
+	hook, ok := s.webhook(c)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteWebhook(c.Request.Context(), hook.ID); err != nil && err != store.ErrNotFound {
+		s.logger.Error("Failed to delete webhook", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.logger.Info("Deleted webhook",
+		zap.Int64("id", hook.ID),
+		zap.String("namespace", hook.Namespace),
+		zap.String("operator", c.GetString("username")))
+	c.Status(http.StatusNoContent)
+}
+
+// listWebhookDeliveriesHandler returns the latest deliveries of a webhook,
+// newest first, those with ?status= only if given
+func (s *Server) listWebhookDeliveriesHandler(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", model.DeliveryStatusPending, model.DeliveryStatusDelivered, model.DeliveryStatusDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or dead"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+	hook, ok := s.webhook(c)
+	if !ok {
+		return
+	}
+	deliveries, err := s.store.ListWebhookDeliveries(c.Request.Context(), hook.ID, status, limit)
+	if err != nil {
+		s.logger.Error("Failed to list webhook deliveries", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// redeliverWebhookHandler queues a delivery to be attempted again at once,
+// with a fresh set of attempts. Dead deliveries are redelivered after the
+// receiver is fixed; delivered ones may be too, for a receiver that lost
+// them.
+func (s *Server) redeliverWebhookHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("delivery"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+	hook, ok := s.webhook(c)
+	if !ok {
+		return
+	}
+	delivery, err := s.store.GetWebhookDelivery(c.Request.Context(), id)
+	if err == store.ErrNotFound || (err == nil && delivery.WebhookID != hook.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get webhook delivery", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	delivery.Status = model.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.LastError = ""
+	delivery.ResponseStatus = 0
+	delivery.UpdatedAt = time.Now()
+	if err := s.store.UpdateWebhookDelivery(c.Request.Context(), delivery); err != nil {
+		if err == store.ErrNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		s.logger.Error("Failed to redeliver webhook delivery", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.wakeWebhooks()
+
+	s.logger.Info("Redelivering webhook delivery",
+		zap.Int64("id", delivery.ID),
+		zap.Int64("webhook", hook.ID),
+		zap.String("operator", c.GetString("username")))
+	c.JSON(http.StatusAccepted, delivery)
+}
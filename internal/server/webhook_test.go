@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// TestWebhookDelivery tests that a change is posted, signed, to the
+// webhooks of its namespace, retried with backoff while the receiver fails,
+// and dead once every attempt has failed
+func TestWebhookDelivery(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusInternalServerError
+	var received []*http.Request
+	var bodies []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	setStatus := func(code int) {
+		mu.Lock()
+		status = code
+		mu.Unlock()
+	}
+	requests := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received)
+	}
+
+	s := &Server{
+		logger:        zap.NewNop(),
+		store:         store.NewTenantStore(store.NewInMemoryStore()),
+		webhookClient: receiver.Client(),
+		webhookWake:   make(chan struct{}, 1),
+	}
+	ctx := context.Background()
+	acme := store.WithTenant(ctx, "acme")
+	if err := s.store.CreateNamespace(acme, "public"); err != nil {
+		t.Fatal(err)
+	}
+	hook := &model.Webhook{Namespace: "public", URL: receiver.URL, Secret: "s3cret", CreatedBy: "alice", CreatedAt: time.Now()}
+	if err := s.store.CreateWebhook(acme, hook); err != nil {
+		t.Fatal(err)
+	}
+	// makeDue lets a delivery's retry fall due now
+	makeDue := func(id int64) {
+		delivery, err := s.store.GetWebhookDelivery(acme, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivery.NextAttemptAt = time.Now().Add(-time.Second)
+		if err := s.store.UpdateWebhookDelivery(acme, delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.publishChange(acme, "public", "app", "timeout", 3, "UPDATE", "alice")
+	s.publishChange(ctx, "public", "app", "timeout", 1, "UPDATE", "bob") // another tenant's namespace
+	s.deliverDueWebhooks(ctx)
+
+	deliveries, err := s.store.ListWebhookDeliveries(acme, hook.ID, "", 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, %v, want 1", len(deliveries), err)
+	}
+	delivery := deliveries[0]
+	if delivery.Status != model.DeliveryStatusPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusInternalServerError || delivery.LastError == "" {
+		t.Errorf("failed delivery %+v, want it pending after 1 attempt with the error", delivery)
+	}
+	if wait := time.Until(delivery.NextAttemptAt); wait < webhookBackoff-time.Second || wait > webhookBackoff {
+		t.Errorf("retry in %v, want %v", wait, webhookBackoff)
+	}
+
+	// Not retried before it falls due
+	s.deliverDueWebhooks(ctx)
+	if n := requests(); n != 1 {
+		t.Fatalf("receiver got %d requests before the retry was due, want 1", n)
+	}
+
+	setStatus(http.StatusNoContent)
+	makeDue(delivery.ID)
+	s.deliverDueWebhooks(ctx)
+	delivery, err = s.store.GetWebhookDelivery(acme, delivery.ID)
+	if err != nil || delivery.Status != model.DeliveryStatusDelivered || delivery.Attempts != 2 {
+		t.Fatalf("retried delivery %+v, %v, want it delivered after 2 attempts", delivery, err)
+	}
+
+	mu.Lock()
+	r, body := received[1], bodies[1]
+	mu.Unlock()
+	if r.Header.Get(webhookEventHeader) != "UPDATE" || r.Header.Get(webhookDeliveryHeader) == "" {
+		t.Errorf("request headers %v lack the event and delivery", r.Header)
+	}
+	if want := "sha256=" + signWebhook("s3cret", r.Header.Get(webhookTimestampHeader), body); r.Header.Get(webhookSignatureHeader) != want {
+		t.Errorf("signature %q, want %q", r.Header.Get(webhookSignatureHeader), want)
+	}
+	var event ChangeEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil || event.Tenant != "acme" || event.Key != "timeout" || event.Version != 3 || event.Operator != "alice" {
+		t.Errorf("payload %s, %v, want alice's change of acme's timeout", body, err)
+	}
+
+	// A delivery failing every attempt is dead
+	setStatus(http.StatusBadGateway)
+	s.publishChange(acme, "public", "app", "retries", 1, "DELETE", "alice")
+	deliveries, _ = s.store.ListWebhookDeliveries(acme, hook.ID, model.DeliveryStatusPending, 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d pending deliveries, want 1", len(deliveries))
+	}
+	for i := 0; i < webhookMaxAttempts; i++ {
+		makeDue(deliveries[0].ID)
+		s.deliverDueWebhooks(ctx)
+	}
+	delivery, err = s.store.GetWebhookDelivery(acme, deliveries[0].ID)
+	if err != nil || delivery.Status != model.DeliveryStatusDead || delivery.Attempts != webhookMaxAttempts {
+		t.Errorf("failing delivery %+v, %v, want it dead after %d attempts", delivery, err, webhookMaxAttempts)
+	}
+}
+
+// TestWebhookRetryDelay tests that the retry delay doubles up to its cap
+func TestWebhookRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  webhookBackoff,
+		2:  2 * webhookBackoff,
+		4:  8 * webhookBackoff,
+		20: webhookMaxBackoff,
+	} {
+		if got := webhookRetryDelay(attempts); got != want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
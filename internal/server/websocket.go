@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// wsPingInterval is how often an idle watch connection is pinged to keep
+// proxies from closing it
+const wsPingInterval = 30 * time.Second
+
+// wsMessage is exchanged over a watch WebSocket. Clients send subscribe and
+// unsubscribe messages carrying keys; the server sends change messages
+// carrying the event type and changed config (version -1 on delete) and
+// periodic pings.
+type wsMessage struct {
+	Type   string        `json:"type"`
+	Keys   []watchTarget `json:"keys,omitempty"`
+	Event  string        `json:"event,omitempty"`
+	Config *model.Config `json:"config,omitempty"`
+}
+
+// changeMessage wraps a change event for a watch WebSocket
+func changeMessage(event *model.ConfigEvent) wsMessage {
+	return wsMessage{Type: "change", Event: event.Type, Config: event.Config}
+}
+
+// watchWebSocketHandler upgrades the request to a WebSocket over which the
+// client watches any number of keys on one persistent connection
+func (s *Server) watchWebSocketHandler(c *gin.Context) {
+	info := s.subscriberInfo(c)
+	scopes := tokenScopes(c)
+	ws := websocket.Server{Handler: func(conn *websocket.Conn) {
+		s.serveWatchConn(conn, info, scopes)
+	}}
+	ws.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveWatchConn pushes changes to the keys a WebSocket client subscribed
+// to until the connection closes. Like the batch long poll, keys whose
+// version differs from the last one the client holds are sent straight away,
+// so nothing is lost while the watcher re-subscribes. Keys outside scopes
+// are ignored.
+func (s *Server) serveWatchConn(conn *websocket.Conn, info SubscriberInfo, scopes TokenScopes) {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(store.WithTenant(context.Background(), info.Tenant))
+	defer cancel()
+
+	incoming := make(chan wsMessage)
+	go func() {
+		defer cancel()
+		for {
+			var msg wsMessage
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				return
+			}
+			select {
+			case incoming <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	targets := make(map[watchKey]int64)
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	// The subscription persists across changes and is only replaced when
+	// the client changes its key set or an admin expires it
+	var sub *Subscription
+	defer func() {
+		if sub != nil {
+			s.watcher.Unsubscribe(sub)
+		}
+	}()
+
+	for {
+		if sub == nil {
+			keys := make([]watchKey, 0, len(targets))
+			list := make([]watchTarget, 0, len(targets))
+			for wk, version := range targets {
+				keys = append(keys, wk)
+				if wk.key != "" {
+					s.access.Record(wk.namespace, wk.group, wk.key, info)
+				}
+				list = append(list, watchTarget{Namespace: wk.namespace, Group: wk.group, Key: wk.key, Version: version})
+			}
+			// Subscribe before comparing versions so no change slips in between
+			sub = s.watcher.subscribe(keys, info)
+
+			changes, err := s.staleTargets(ctx, list, info.selectorLabels())
+			if err != nil {
+				s.logger.Error("Failed to get config", zap.Error(err))
+				return
+			}
+			for _, event := range changes {
+				if err := websocket.JSON.Send(conn, changeMessage(event)); err != nil {
+					return
+				}
+				trackVersion(targets, event.Config)
+			}
+		}
+
+		select {
+		case <-sub.Ready():
+			events, err := s.variantEvents(ctx, info.selectorLabels(), sub.drain())
+			if err != nil {
+				s.logger.Error("Failed to select config variant", zap.Error(err))
+				return
+			}
+			for _, event := range events {
+				if err := websocket.JSON.Send(conn, changeMessage(event)); err != nil {
+					return
+				}
+				trackVersion(targets, event.Config)
+			}
+		case <-sub.Done():
+			// Ended by a drain; the client reconnects to the next process
+			if s.watcher.Draining() {
+				return
+			}
+			// Force-expired by an admin or overflowed; re-subscribe, which
+			// sends whatever changed since the versions the client holds
+			sub = nil
+		case msg := <-incoming:
+			for _, t := range msg.Keys {
+				if t.Namespace == "" || t.Group == "" || t.Key == "" {
+					continue
+				}
+				wk, ok := t.watchKey()
+				if !ok || !scopes.allowsTarget(t) {
+					continue
+				}
+				switch msg.Type {
+				case "subscribe":
+					if wk.key == "" {
+						// Wildcards have no version to compare
+						targets[wk] = 0
+						break
+					}
+					targets[wk] = t.Version
+					s.propagation.Seen(store.TenantName(info.Tenant, t.Namespace), t.Group, t.Key, info)
+				case "unsubscribe":
+					delete(targets, wk)
+				}
+			}
+			s.watcher.Unsubscribe(sub)
+			sub = nil
+		case <-ping.C:
+			if err := websocket.JSON.Send(conn, wsMessage{Type: "ping"}); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// trackVersion records the version of a changed config the client now
+// holds, if it subscribed to that key itself rather than through a wildcard
+func trackVersion(targets map[watchKey]int64, cfg *model.Config) {
+	wk := watchKey{cfg.Namespace, cfg.Group, cfg.Key}
+	if _, ok := targets[wk]; ok {
+		targets[wk] = cfg.Version
+	}
+}
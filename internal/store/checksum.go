@@ -0,0 +1,35 @@
+package store
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// SetChecksums records the size and hashes of a config's content, which is
+// the decoded bytes for binary configs and the value otherwise, so they match
+// what a raw download returns
+func SetChecksums(cfg *model.Config) {
+	content := []byte(cfg.Value)
+	if cfg.Type == "binary" {
+		if data, err := base64.StdEncoding.DecodeString(cfg.Value); err == nil {
+			content = data
+		}
+	}
+	md5Sum := md5.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	cfg.MD5 = hex.EncodeToString(md5Sum[:])
+	cfg.SHA256 = hex.EncodeToString(sha256Sum[:])
+	cfg.Size = int64(len(content))
+}
+
+// fillChecksums computes the checksums of a config stored before they were
+// recorded on write
+func fillChecksums(cfg *model.Config) {
+	if cfg.SHA256 == "" {
+		SetChecksums(cfg)
+	}
+}
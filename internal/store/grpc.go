@@ -0,0 +1,518 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sotowang/otter/internal/grpc"
+	"github.com/sotowang/otter/internal/model"
+)
+
+// GRPCService is the gRPC service of store plugins. It has a unary method
+// for every method of Store, named alike, like /otter.store.v1.Store/Get.
+// The request of a method is a JSON array of its arguments after the
+// context, and the response a JSON array of its results before the error,
+// followed by every pointer argument as the plugin left it, so the IDs and
+// versions a method assigns reach the caller. ErrNotFound is sent as
+// NotFound and ErrRateLimited as ResourceExhausted; other errors as
+// Unknown with their text.
+const GRPCService = "otter.store.v1.Store"
+
+func init() {
+	Register("grpc", func(dsn string) (Store, error) {
+		s, err := NewGRPCStore(strings.TrimPrefix(dsn, "grpc://"))
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+// GRPCStore is a Store proxying every call to a store plugin, an external
+// process serving GRPCService, so storage integrations can live outside
+// this codebase
+type GRPCStore struct {
+	client *grpc.Client
+}
+
+// NewGRPCStore returns a store proxying to the plugin at addr, a host:port
+func NewGRPCStore(addr string) (*GRPCStore, error) {
+	if _, _, ok := strings.Cut(addr, ":"); !ok || strings.Contains(addr, "/") {
+		return nil, fmt.Errorf("invalid store plugin address %q, expected host:port", addr)
+	}
+	return &GRPCStore{client: grpc.NewClient(addr)}, nil
+}
+
+// call calls method with args, decoding the results and pointer arguments
+// of the response into out
+func (s *GRPCStore) call(ctx context.Context, method string, args []any, out ...any) error {
+	if args == nil {
+		args = []any{}
+	}
+	var resp []json.RawMessage
+	if err := s.client.Invoke(ctx, "/"+GRPCService+"/"+method, args, &resp); err != nil {
+		var callErr *grpc.Error
+		if !errors.As(err, &callErr) {
+			return err
+		}
+		switch callErr.Code {
+		case grpc.NotFound:
+			return ErrNotFound
+		case grpc.ResourceExhausted:
+			return ErrRateLimited
+		case grpc.Unknown:
+			return errors.New(callErr.Message)
+		}
+		return fmt.Errorf("store plugin: %w", err)
+	}
+	if len(resp) != len(out) {
+		return fmt.Errorf("store plugin: %s returned %d values, want %d", method, len(resp), len(out))
+	}
+	for i, raw := range resp {
+		if err := json.Unmarshal(raw, out[i]); err != nil {
+			return fmt.Errorf("store plugin: decode %s response: %w", method, err)
+		}
+	}
+	return nil
+}
+
+func (s *GRPCStore) Get(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	var config *model.Config
+	err := s.call(ctx, "Get", []any{namespace, group, key}, &config)
+	return config, err
+}
+
+func (s *GRPCStore) Put(ctx context.Context, config *model.Config) error {
+	return s.call(ctx, "Put", []any{config}, config)
+}
+
+func (s *GRPCStore) Delete(ctx context.Context, namespace, group, key string) error {
+	return s.call(ctx, "Delete", []any{namespace, group, key})
+}
+
+func (s *GRPCStore) List(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	var configs []*model.Config
+	err := s.call(ctx, "List", []any{namespace, group}, &configs)
+	return configs, err
+}
+
+func (s *GRPCStore) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	var configs []*model.Config
+	err := s.call(ctx, "ListNamespaceConfigs", []any{namespace}, &configs)
+	return configs, err
+}
+
+func (s *GRPCStore) NextVersion(ctx context.Context, namespace, group, key string) (int64, error) {
+	var n int64
+	err := s.call(ctx, "NextVersion", []any{namespace, group, key}, &n)
+	return n, err
+}
+
+func (s *GRPCStore) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	err := s.call(ctx, "ListNamespaces", nil, &namespaces)
+	return namespaces, err
+}
+
+func (s *GRPCStore) CreateNamespace(ctx context.Context, namespace string) error {
+	return s.call(ctx, "CreateNamespace", []any{namespace})
+}
+
+func (s *GRPCStore) DeleteNamespace(ctx context.Context, namespace string) error {
+	return s.call(ctx, "DeleteNamespace", []any{namespace})
+}
+
+func (s *GRPCStore) GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	var quota *model.NamespaceQuota
+	err := s.call(ctx, "GetNamespaceQuota", []any{namespace}, &quota)
+	return quota, err
+}
+
+func (s *GRPCStore) SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error {
+	return s.call(ctx, "SetNamespaceQuota", []any{quota}, quota)
+}
+
+func (s *GRPCStore) NamespaceUsage(ctx context.Context, namespace string) (configs int64, totalBytes int64, err error) {
+	err = s.call(ctx, "NamespaceUsage", []any{namespace}, &configs, &totalBytes)
+	return configs, totalBytes, err
+}
+
+func (s *GRPCStore) SetLock(ctx context.Context, lock *model.ConfigLock) error {
+	return s.call(ctx, "SetLock", []any{lock}, lock)
+}
+
+func (s *GRPCStore) GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error) {
+	var lock *model.ConfigLock
+	err := s.call(ctx, "GetLock", []any{namespace, group, key}, &lock)
+	return lock, err
+}
+
+func (s *GRPCStore) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	var locks []*model.ConfigLock
+	err := s.call(ctx, "ListLocks", []any{namespace}, &locks)
+	return locks, err
+}
+
+func (s *GRPCStore) DeleteLock(ctx context.Context, namespace, group, key string) error {
+	return s.call(ctx, "DeleteLock", []any{namespace, group, key})
+}
+
+func (s *GRPCStore) SetOwner(ctx context.Context, owner *model.ConfigOwner) error {
+	return s.call(ctx, "SetOwner", []any{owner}, owner)
+}
+
+func (s *GRPCStore) GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	var owner *model.ConfigOwner
+	err := s.call(ctx, "GetOwner", []any{namespace, group, key}, &owner)
+	return owner, err
+}
+
+func (s *GRPCStore) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	var owners []*model.ConfigOwner
+	err := s.call(ctx, "ListOwners", []any{namespace}, &owners)
+	return owners, err
+}
+
+func (s *GRPCStore) DeleteOwner(ctx context.Context, namespace, group, key string) error {
+	return s.call(ctx, "DeleteOwner", []any{namespace, group, key})
+}
+
+func (s *GRPCStore) SetVariant(ctx context.Context, variant *model.ConfigVariant) error {
+	return s.call(ctx, "SetVariant", []any{variant}, variant)
+}
+
+func (s *GRPCStore) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	var variants []*model.ConfigVariant
+	err := s.call(ctx, "ListVariants", []any{namespace, group, key}, &variants)
+	return variants, err
+}
+
+func (s *GRPCStore) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	return s.call(ctx, "DeleteVariant", []any{namespace, group, key, name})
+}
+
+func (s *GRPCStore) RecordAccess(ctx context.Context, access *model.ConfigAccess) error {
+	return s.call(ctx, "RecordAccess", []any{access}, access)
+}
+
+func (s *GRPCStore) ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error) {
+	var access []*model.ConfigAccess
+	err := s.call(ctx, "ListAccess", []any{namespace, group, key}, &access)
+	return access, err
+}
+
+func (s *GRPCStore) CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	return s.call(ctx, "CreateScheduledChange", []any{change}, change)
+}
+
+func (s *GRPCStore) GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	var change *model.ScheduledChange
+	err := s.call(ctx, "GetScheduledChange", []any{id}, &change)
+	return change, err
+}
+
+func (s *GRPCStore) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	var changes []*model.ScheduledChange
+	err := s.call(ctx, "ListScheduledChanges", []any{namespace}, &changes)
+	return changes, err
+}
+
+func (s *GRPCStore) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error) {
+	var changes []*model.ScheduledChange
+	err := s.call(ctx, "ListDueScheduledChanges", []any{now}, &changes)
+	return changes, err
+}
+
+func (s *GRPCStore) UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	return s.call(ctx, "UpdateScheduledChange", []any{change}, change)
+}
+
+func (s *GRPCStore) ClaimScheduledChange(ctx context.Context, id int64) error {
+	return s.call(ctx, "ClaimScheduledChange", []any{id})
+}
+
+func (s *GRPCStore) DeleteScheduledChange(ctx context.Context, id int64) error {
+	return s.call(ctx, "DeleteScheduledChange", []any{id})
+}
+
+func (s *GRPCStore) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	return s.call(ctx, "CreateWebhook", []any{hook}, hook)
+}
+
+func (s *GRPCStore) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	var hook *model.Webhook
+	err := s.call(ctx, "GetWebhook", []any{id}, &hook)
+	return hook, err
+}
+
+func (s *GRPCStore) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	var hooks []*model.Webhook
+	err := s.call(ctx, "ListWebhooks", []any{namespace}, &hooks)
+	return hooks, err
+}
+
+func (s *GRPCStore) DeleteWebhook(ctx context.Context, id int64) error {
+	return s.call(ctx, "DeleteWebhook", []any{id})
+}
+
+func (s *GRPCStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.call(ctx, "CreateWebhookDelivery", []any{delivery}, delivery)
+}
+
+func (s *GRPCStore) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	var delivery *model.WebhookDelivery
+	err := s.call(ctx, "GetWebhookDelivery", []any{id}, &delivery)
+	return delivery, err
+}
+
+func (s *GRPCStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := s.call(ctx, "ListWebhookDeliveries", []any{webhookID, status, limit}, &deliveries)
+	return deliveries, err
+}
+
+func (s *GRPCStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := s.call(ctx, "ListDueWebhookDeliveries", []any{now, limit}, &deliveries)
+	return deliveries, err
+}
+
+func (s *GRPCStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.call(ctx, "UpdateWebhookDelivery", []any{delivery}, delivery)
+}
+
+func (s *GRPCStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	return s.call(ctx, "ClaimWebhookDelivery", []any{id, now, until})
+}
+
+func (s *GRPCStore) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	return s.call(ctx, "PruneWebhookDeliveries", []any{before})
+}
+
+func (s *GRPCStore) CreateHistory(ctx context.Context, history *model.ConfigHistory) error {
+	return s.call(ctx, "CreateHistory", []any{history}, history)
+}
+
+func (s *GRPCStore) ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error) {
+	var history []*model.ConfigHistory
+	err := s.call(ctx, "ListHistory", []any{namespace, group, key}, &history)
+	return history, err
+}
+
+func (s *GRPCStore) ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	var history []*model.ConfigHistory
+	err := s.call(ctx, "ListNamespaceHistory", []any{namespace, since, limit}, &history)
+	return history, err
+}
+
+func (s *GRPCStore) ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error) {
+	var history []*model.ConfigHistory
+	err := s.call(ctx, "ListNamespaceEvents", []any{namespace, afterID, limit}, &history)
+	return history, err
+}
+
+func (s *GRPCStore) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
+	return s.call(ctx, "CreateTenant", []any{tenant}, tenant)
+}
+
+func (s *GRPCStore) GetTenant(ctx context.Context, name string) (*model.Tenant, error) {
+	var tenant *model.Tenant
+	err := s.call(ctx, "GetTenant", []any{name}, &tenant)
+	return tenant, err
+}
+
+func (s *GRPCStore) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	var tenants []*model.Tenant
+	err := s.call(ctx, "ListTenants", nil, &tenants)
+	return tenants, err
+}
+
+func (s *GRPCStore) DeleteTenant(ctx context.Context, name string) error {
+	return s.call(ctx, "DeleteTenant", []any{name})
+}
+
+func (s *GRPCStore) CreateUser(ctx context.Context, user *model.User) error {
+	return s.call(ctx, "CreateUser", []any{user}, user)
+}
+
+func (s *GRPCStore) GetUser(ctx context.Context, username string) (*model.User, error) {
+	var user *model.User
+	err := s.call(ctx, "GetUser", []any{username}, &user)
+	return user, err
+}
+
+func (s *GRPCStore) ListUsers(ctx context.Context) ([]*model.User, error) {
+	var users []*model.User
+	err := s.call(ctx, "ListUsers", nil, &users)
+	return users, err
+}
+
+func (s *GRPCStore) UpdateUser(ctx context.Context, user *model.User) error {
+	return s.call(ctx, "UpdateUser", []any{user}, user)
+}
+
+func (s *GRPCStore) DeleteUser(ctx context.Context, username string) error {
+	return s.call(ctx, "DeleteUser", []any{username})
+}
+
+func (s *GRPCStore) CreateSession(ctx context.Context, session *model.Session) error {
+	return s.call(ctx, "CreateSession", []any{session}, session)
+}
+
+func (s *GRPCStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	var session *model.Session
+	err := s.call(ctx, "GetSession", []any{id}, &session)
+	return session, err
+}
+
+func (s *GRPCStore) ListSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	var sessions []*model.Session
+	err := s.call(ctx, "ListSessions", []any{username}, &sessions)
+	return sessions, err
+}
+
+func (s *GRPCStore) UpdateSession(ctx context.Context, session *model.Session) error {
+	return s.call(ctx, "UpdateSession", []any{session}, session)
+}
+
+func (s *GRPCStore) DeleteSession(ctx context.Context, id string) error {
+	return s.call(ctx, "DeleteSession", []any{id})
+}
+
+func (s *GRPCStore) SetTeam(ctx context.Context, team *model.Team) error {
+	return s.call(ctx, "SetTeam", []any{team}, team)
+}
+
+func (s *GRPCStore) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	var team *model.Team
+	err := s.call(ctx, "GetTeam", []any{name}, &team)
+	return team, err
+}
+
+func (s *GRPCStore) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	var teams []*model.Team
+	err := s.call(ctx, "ListTeams", nil, &teams)
+	return teams, err
+}
+
+func (s *GRPCStore) DeleteTeam(ctx context.Context, name string) error {
+	return s.call(ctx, "DeleteTeam", []any{name})
+}
+
+func (s *GRPCStore) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	return s.call(ctx, "CreateNotification", []any{notification}, notification)
+}
+
+func (s *GRPCStore) ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	var notifications []*model.Notification
+	err := s.call(ctx, "ListNotifications", []any{username, unreadOnly, limit}, &notifications)
+	return notifications, err
+}
+
+func (s *GRPCStore) MarkNotificationsRead(ctx context.Context, username string, upToID int64) error {
+	return s.call(ctx, "MarkNotificationsRead", []any{username, upToID})
+}
+
+func (s *GRPCStore) DeleteNotifications(ctx context.Context, username string) error {
+	return s.call(ctx, "DeleteNotifications", []any{username})
+}
+
+func (s *GRPCStore) AddTokenToBlacklist(ctx context.Context, token string, expiresAt time.Time) error {
+	return s.call(ctx, "AddTokenToBlacklist", []any{token, expiresAt})
+}
+
+func (s *GRPCStore) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	var ok bool
+	err := s.call(ctx, "IsTokenBlacklisted", []any{token}, &ok)
+	return ok, err
+}
+
+func (s *GRPCStore) CleanupExpiredTokens(ctx context.Context) error {
+	return s.call(ctx, "CleanupExpiredTokens", nil)
+}
+
+func (s *GRPCStore) IncrementTokenUsage(ctx context.Context, token string) (int64, error) {
+	var n int64
+	err := s.call(ctx, "IncrementTokenUsage", []any{token}, &n)
+	return n, err
+}
+
+func (s *GRPCStore) CheckTokenRateLimit(ctx context.Context, token string, limit int64, duration time.Duration) (bool, error) {
+	var ok bool
+	err := s.call(ctx, "CheckTokenRateLimit", []any{token, limit, duration}, &ok)
+	return ok, err
+}
+
+func (s *GRPCStore) ResetTokenUsage(ctx context.Context, token string) error {
+	return s.call(ctx, "ResetTokenUsage", []any{token})
+}
+
+// NewGRPCHandler returns the handler of a store plugin serving GRPCService
+// with impl. impl has the methods of Store it supports, with the same
+// signatures; calls of the others fail with Unimplemented. It panics if a
+// method of impl named like one of Store has another signature.
+func NewGRPCHandler(impl any) http.Handler {
+	v := reflect.ValueOf(impl)
+	methods := make(map[string]grpc.Method)
+	for i := 0; i < storeType.NumMethod(); i++ {
+		want := storeType.Method(i)
+		fn := v.MethodByName(want.Name)
+		if !fn.IsValid() {
+			continue
+		}
+		if fn.Type() != want.Type {
+			panic(fmt.Sprintf("store: %T.%s is %s, want %s", impl, want.Name, fn.Type(), want.Type))
+		}
+		methods["/"+GRPCService+"/"+want.Name] = grpcMethod(fn)
+	}
+	return grpc.NewHandler(methods)
+}
+
+// grpcMethod adapts a Store method to a gRPC method
+func grpcMethod(fn reflect.Value) grpc.Method {
+	t := fn.Type()
+	return func(ctx context.Context, decode func(any) error) (any, error) {
+		var raw []json.RawMessage
+		if err := decode(&raw); err != nil {
+			return nil, err
+		}
+		if len(raw) != t.NumIn()-1 {
+			return nil, grpc.Errorf(grpc.InvalidArgument, "got %d arguments, want %d", len(raw), t.NumIn()-1)
+		}
+		args := []reflect.Value{reflect.ValueOf(ctx)}
+		for i, arg := range raw {
+			v := reflect.New(t.In(i + 1))
+			if err := json.Unmarshal(arg, v.Interface()); err != nil {
+				return nil, grpc.Errorf(grpc.InvalidArgument, "argument %d: %v", i+1, err)
+			}
+			args = append(args, v.Elem())
+		}
+
+		results := fn.Call(args)
+		if err, _ := results[len(results)-1].Interface().(error); err != nil {
+			switch {
+			case errors.Is(err, ErrNotFound):
+				return nil, grpc.Errorf(grpc.NotFound, "%v", err)
+			case errors.Is(err, ErrRateLimited):
+				return nil, grpc.Errorf(grpc.ResourceExhausted, "%v", err)
+			}
+			return nil, err
+		}
+		resp := make([]any, 0, len(results))
+		for _, result := range results[:len(results)-1] {
+			resp = append(resp, result.Interface())
+		}
+		for _, arg := range args[1:] {
+			if arg.Kind() == reflect.Pointer {
+				resp = append(resp, arg.Interface())
+			}
+		}
+		return resp, nil
+	}
+}
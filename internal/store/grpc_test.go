@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// newGRPCTestStore returns a GRPCStore proxying to a plugin serving impl
+func newGRPCTestStore(t *testing.T, impl any) *GRPCStore {
+	srv := httptest.NewServer(NewGRPCHandler(impl))
+	t.Cleanup(srv.Close)
+	s, err := NewGRPCStore(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("NewGRPCStore failed: %v", err)
+	}
+	return s
+}
+
+// partialPlugin overrides a method of the store it embeds
+type partialPlugin struct {
+	*InMemoryStore
+}
+
+func (partialPlugin) GetUser(ctx context.Context, username string) (*model.User, error) {
+	return nil, ErrRateLimited
+}
+
+// TestGRPCStore tests that calls through a plugin return what the plugin's
+// store returns, including what it assigns to arguments and its errors
+func TestGRPCStore(t *testing.T) {
+	ctx := context.Background()
+	s := newGRPCTestStore(t, NewInMemoryStore())
+
+	cfg := &model.Config{Namespace: "public", Group: "billing", Key: "rate", Value: "0.2", Type: "text", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := s.Put(ctx, cfg); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if cfg.Version != 1 {
+		t.Errorf("Put assigned version %d, want 1", cfg.Version)
+	}
+	got, err := s.Get(ctx, "public", "billing", "rate")
+	if err != nil || got.Value != "0.2" || !got.CreatedAt.Equal(cfg.CreatedAt) {
+		t.Errorf("Get = %+v, %v, want the config put", got, err)
+	}
+	if _, err := s.Get(ctx, "public", "billing", "missing"); err != ErrNotFound {
+		t.Errorf("Get of a missing config = %v, want ErrNotFound", err)
+	}
+	if configs, totalBytes, err := s.NamespaceUsage(ctx, "public"); err != nil || configs != 1 || totalBytes != 3 {
+		t.Errorf("NamespaceUsage = %d, %d, %v, want 1, 3", configs, totalBytes, err)
+	}
+	if err := s.CreateUser(ctx, &model.User{Username: "alice"}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if err := s.CreateUser(ctx, &model.User{Username: "alice"}); err == nil || err.Error() != "user already exists" {
+		t.Errorf("CreateUser of an existing user = %v, want the plugin's error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	if _, err := s.ListNamespaces(ctx); err == nil {
+		t.Error("call with an expired context succeeded")
+	}
+
+	partial := newGRPCTestStore(t, partialPlugin{NewInMemoryStore()})
+	if _, err := partial.GetUser(context.Background(), "alice"); err != ErrRateLimited {
+		t.Errorf("GetUser = %v, want ErrRateLimited", err)
+	}
+	if _, err := partial.ListNamespaces(context.Background()); err != nil {
+		t.Errorf("ListNamespaces of an embedded store failed: %v", err)
+	}
+	type usersOnly struct{ UserStore }
+	users := newGRPCTestStore(t, usersOnly{NewInMemoryStore()})
+	if _, err := users.Get(context.Background(), "public", "billing", "rate"); err == nil || !strings.Contains(err.Error(), "Unimplemented") {
+		t.Errorf("call of a method the plugin lacks = %v, want Unimplemented", err)
+	}
+}
@@ -0,0 +1,636 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// OperationBuckets are the upper bounds of the latency histogram buckets of
+// store operations, growing exponentially from 50µs to roughly 26s
+var OperationBuckets = func() []time.Duration {
+	bounds := make([]time.Duration, 20)
+	for i := range bounds {
+		bounds[i] = 50 * time.Microsecond << i
+	}
+	return bounds
+}()
+
+// OperationStats are the calls made of one Store method
+type OperationStats struct {
+	Method string
+	Calls  int64
+	// Errors counts the calls failing with an error other than ErrNotFound,
+	// which only reports a missing record
+	Errors   int64
+	InFlight int64
+	Total    time.Duration
+	// Buckets counts the calls by latency, one per bucket of
+	// OperationBuckets and the last for slower calls
+	Buckets []int64
+}
+
+// operationStats accumulates the calls of one method. It is updated with
+// atomics only, so calls of the same method do not contend on a lock.
+type operationStats struct {
+	calls    atomic.Int64
+	errors   atomic.Int64
+	inFlight atomic.Int64
+	total    atomic.Int64 // nanoseconds
+	buckets  []atomic.Int64
+}
+
+// InstrumentedStore records the latency, errors and calls in flight of
+// every Store method it passes on, so slow API requests can be traced to
+// the store operations they wait for
+type InstrumentedStore struct {
+	next Store
+	// ops has an entry for every Store method and is never changed after
+	// NewInstrumentedStore, so it is read without locking
+	ops map[string]*operationStats
+}
+
+// NewInstrumentedStore wraps st to record its operations
+func NewInstrumentedStore(st Store) *InstrumentedStore {
+	s := &InstrumentedStore{next: st, ops: make(map[string]*operationStats, storeType.NumMethod())}
+	for i := 0; i < storeType.NumMethod(); i++ {
+		s.ops[storeType.Method(i).Name] = &operationStats{buckets: make([]atomic.Int64, len(OperationBuckets)+1)}
+	}
+	return s
+}
+
+// Stats returns the stats of the methods called so far, ordered by name
+func (s *InstrumentedStore) Stats() []OperationStats {
+	var stats []OperationStats
+	for method, op := range s.ops {
+		st := OperationStats{
+			Method:   method,
+			Calls:    op.calls.Load(),
+			Errors:   op.errors.Load(),
+			InFlight: op.inFlight.Load(),
+			Total:    time.Duration(op.total.Load()),
+			Buckets:  make([]int64, len(op.buckets)),
+		}
+		if st.Calls == 0 && st.InFlight == 0 {
+			continue
+		}
+		for i := range op.buckets {
+			st.Buckets[i] = op.buckets[i].Load()
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Method < stats[j].Method })
+	return stats
+}
+
+// call is a call in progress
+type call struct {
+	op    *operationStats
+	start time.Time
+}
+
+func (s *InstrumentedStore) begin(method string) call {
+	op := s.ops[method]
+	op.inFlight.Add(1)
+	return call{op: op, start: time.Now()}
+}
+
+func (c call) end(err error) {
+	d := time.Since(c.start)
+	i := sort.Search(len(OperationBuckets), func(i int) bool { return d <= OperationBuckets[i] })
+	c.op.buckets[i].Add(1)
+	c.op.total.Add(int64(d))
+	c.op.calls.Add(1)
+	if err != nil && err != ErrNotFound {
+		c.op.errors.Add(1)
+	}
+	c.op.inFlight.Add(-1)
+}
+
+func (s *InstrumentedStore) Get(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	call := s.begin("Get")
+	config, err := s.next.Get(ctx, namespace, group, key)
+	call.end(err)
+	return config, err
+}
+
+func (s *InstrumentedStore) Put(ctx context.Context, config *model.Config) error {
+	call := s.begin("Put")
+	err := s.next.Put(ctx, config)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) Delete(ctx context.Context, namespace, group, key string) error {
+	call := s.begin("Delete")
+	err := s.next.Delete(ctx, namespace, group, key)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) List(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	call := s.begin("List")
+	configs, err := s.next.List(ctx, namespace, group)
+	call.end(err)
+	return configs, err
+}
+
+func (s *InstrumentedStore) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	call := s.begin("ListNamespaceConfigs")
+	configs, err := s.next.ListNamespaceConfigs(ctx, namespace)
+	call.end(err)
+	return configs, err
+}
+
+func (s *InstrumentedStore) NextVersion(ctx context.Context, namespace, group, key string) (int64, error) {
+	call := s.begin("NextVersion")
+	n, err := s.next.NextVersion(ctx, namespace, group, key)
+	call.end(err)
+	return n, err
+}
+
+func (s *InstrumentedStore) ListNamespaces(ctx context.Context) ([]string, error) {
+	call := s.begin("ListNamespaces")
+	namespaces, err := s.next.ListNamespaces(ctx)
+	call.end(err)
+	return namespaces, err
+}
+
+func (s *InstrumentedStore) CreateNamespace(ctx context.Context, namespace string) error {
+	call := s.begin("CreateNamespace")
+	err := s.next.CreateNamespace(ctx, namespace)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteNamespace(ctx context.Context, namespace string) error {
+	call := s.begin("DeleteNamespace")
+	err := s.next.DeleteNamespace(ctx, namespace)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	call := s.begin("GetNamespaceQuota")
+	quota, err := s.next.GetNamespaceQuota(ctx, namespace)
+	call.end(err)
+	return quota, err
+}
+
+func (s *InstrumentedStore) SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error {
+	call := s.begin("SetNamespaceQuota")
+	err := s.next.SetNamespaceQuota(ctx, quota)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) NamespaceUsage(ctx context.Context, namespace string) (configs int64, totalBytes int64, err error) {
+	call := s.begin("NamespaceUsage")
+	configs, totalBytes, err = s.next.NamespaceUsage(ctx, namespace)
+	call.end(err)
+	return configs, totalBytes, err
+}
+
+func (s *InstrumentedStore) SetLock(ctx context.Context, lock *model.ConfigLock) error {
+	call := s.begin("SetLock")
+	err := s.next.SetLock(ctx, lock)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error) {
+	call := s.begin("GetLock")
+	lock, err := s.next.GetLock(ctx, namespace, group, key)
+	call.end(err)
+	return lock, err
+}
+
+func (s *InstrumentedStore) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	call := s.begin("ListLocks")
+	locks, err := s.next.ListLocks(ctx, namespace)
+	call.end(err)
+	return locks, err
+}
+
+func (s *InstrumentedStore) DeleteLock(ctx context.Context, namespace, group, key string) error {
+	call := s.begin("DeleteLock")
+	err := s.next.DeleteLock(ctx, namespace, group, key)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) SetOwner(ctx context.Context, owner *model.ConfigOwner) error {
+	call := s.begin("SetOwner")
+	err := s.next.SetOwner(ctx, owner)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	call := s.begin("GetOwner")
+	owner, err := s.next.GetOwner(ctx, namespace, group, key)
+	call.end(err)
+	return owner, err
+}
+
+func (s *InstrumentedStore) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	call := s.begin("ListOwners")
+	owners, err := s.next.ListOwners(ctx, namespace)
+	call.end(err)
+	return owners, err
+}
+
+func (s *InstrumentedStore) DeleteOwner(ctx context.Context, namespace, group, key string) error {
+	call := s.begin("DeleteOwner")
+	err := s.next.DeleteOwner(ctx, namespace, group, key)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) SetVariant(ctx context.Context, variant *model.ConfigVariant) error {
+	call := s.begin("SetVariant")
+	err := s.next.SetVariant(ctx, variant)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	call := s.begin("ListVariants")
+	variants, err := s.next.ListVariants(ctx, namespace, group, key)
+	call.end(err)
+	return variants, err
+}
+
+func (s *InstrumentedStore) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	call := s.begin("DeleteVariant")
+	err := s.next.DeleteVariant(ctx, namespace, group, key, name)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) RecordAccess(ctx context.Context, access *model.ConfigAccess) error {
+	call := s.begin("RecordAccess")
+	err := s.next.RecordAccess(ctx, access)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error) {
+	call := s.begin("ListAccess")
+	access, err := s.next.ListAccess(ctx, namespace, group, key)
+	call.end(err)
+	return access, err
+}
+
+func (s *InstrumentedStore) CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	call := s.begin("CreateScheduledChange")
+	err := s.next.CreateScheduledChange(ctx, change)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	call := s.begin("GetScheduledChange")
+	change, err := s.next.GetScheduledChange(ctx, id)
+	call.end(err)
+	return change, err
+}
+
+func (s *InstrumentedStore) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	call := s.begin("ListScheduledChanges")
+	changes, err := s.next.ListScheduledChanges(ctx, namespace)
+	call.end(err)
+	return changes, err
+}
+
+func (s *InstrumentedStore) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error) {
+	call := s.begin("ListDueScheduledChanges")
+	changes, err := s.next.ListDueScheduledChanges(ctx, now)
+	call.end(err)
+	return changes, err
+}
+
+func (s *InstrumentedStore) UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	call := s.begin("UpdateScheduledChange")
+	err := s.next.UpdateScheduledChange(ctx, change)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) ClaimScheduledChange(ctx context.Context, id int64) error {
+	call := s.begin("ClaimScheduledChange")
+	err := s.next.ClaimScheduledChange(ctx, id)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteScheduledChange(ctx context.Context, id int64) error {
+	call := s.begin("DeleteScheduledChange")
+	err := s.next.DeleteScheduledChange(ctx, id)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	call := s.begin("CreateWebhook")
+	err := s.next.CreateWebhook(ctx, hook)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	call := s.begin("GetWebhook")
+	hook, err := s.next.GetWebhook(ctx, id)
+	call.end(err)
+	return hook, err
+}
+
+func (s *InstrumentedStore) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	call := s.begin("ListWebhooks")
+	hooks, err := s.next.ListWebhooks(ctx, namespace)
+	call.end(err)
+	return hooks, err
+}
+
+func (s *InstrumentedStore) DeleteWebhook(ctx context.Context, id int64) error {
+	call := s.begin("DeleteWebhook")
+	err := s.next.DeleteWebhook(ctx, id)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	call := s.begin("CreateWebhookDelivery")
+	err := s.next.CreateWebhookDelivery(ctx, delivery)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	call := s.begin("GetWebhookDelivery")
+	delivery, err := s.next.GetWebhookDelivery(ctx, id)
+	call.end(err)
+	return delivery, err
+}
+
+func (s *InstrumentedStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	call := s.begin("ListWebhookDeliveries")
+	deliveries, err := s.next.ListWebhookDeliveries(ctx, webhookID, status, limit)
+	call.end(err)
+	return deliveries, err
+}
+
+func (s *InstrumentedStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	call := s.begin("ListDueWebhookDeliveries")
+	deliveries, err := s.next.ListDueWebhookDeliveries(ctx, now, limit)
+	call.end(err)
+	return deliveries, err
+}
+
+func (s *InstrumentedStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	call := s.begin("UpdateWebhookDelivery")
+	err := s.next.UpdateWebhookDelivery(ctx, delivery)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	call := s.begin("ClaimWebhookDelivery")
+	err := s.next.ClaimWebhookDelivery(ctx, id, now, until)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	call := s.begin("PruneWebhookDeliveries")
+	err := s.next.PruneWebhookDeliveries(ctx, before)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateHistory(ctx context.Context, history *model.ConfigHistory) error {
+	call := s.begin("CreateHistory")
+	err := s.next.CreateHistory(ctx, history)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error) {
+	call := s.begin("ListHistory")
+	history, err := s.next.ListHistory(ctx, namespace, group, key)
+	call.end(err)
+	return history, err
+}
+
+func (s *InstrumentedStore) ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	call := s.begin("ListNamespaceHistory")
+	history, err := s.next.ListNamespaceHistory(ctx, namespace, since, limit)
+	call.end(err)
+	return history, err
+}
+
+func (s *InstrumentedStore) ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error) {
+	call := s.begin("ListNamespaceEvents")
+	history, err := s.next.ListNamespaceEvents(ctx, namespace, afterID, limit)
+	call.end(err)
+	return history, err
+}
+
+func (s *InstrumentedStore) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
+	call := s.begin("CreateTenant")
+	err := s.next.CreateTenant(ctx, tenant)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetTenant(ctx context.Context, name string) (*model.Tenant, error) {
+	call := s.begin("GetTenant")
+	tenant, err := s.next.GetTenant(ctx, name)
+	call.end(err)
+	return tenant, err
+}
+
+func (s *InstrumentedStore) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	call := s.begin("ListTenants")
+	tenants, err := s.next.ListTenants(ctx)
+	call.end(err)
+	return tenants, err
+}
+
+func (s *InstrumentedStore) DeleteTenant(ctx context.Context, name string) error {
+	call := s.begin("DeleteTenant")
+	err := s.next.DeleteTenant(ctx, name)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateUser(ctx context.Context, user *model.User) error {
+	call := s.begin("CreateUser")
+	err := s.next.CreateUser(ctx, user)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetUser(ctx context.Context, username string) (*model.User, error) {
+	call := s.begin("GetUser")
+	user, err := s.next.GetUser(ctx, username)
+	call.end(err)
+	return user, err
+}
+
+func (s *InstrumentedStore) ListUsers(ctx context.Context) ([]*model.User, error) {
+	call := s.begin("ListUsers")
+	users, err := s.next.ListUsers(ctx)
+	call.end(err)
+	return users, err
+}
+
+func (s *InstrumentedStore) UpdateUser(ctx context.Context, user *model.User) error {
+	call := s.begin("UpdateUser")
+	err := s.next.UpdateUser(ctx, user)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteUser(ctx context.Context, username string) error {
+	call := s.begin("DeleteUser")
+	err := s.next.DeleteUser(ctx, username)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateSession(ctx context.Context, session *model.Session) error {
+	call := s.begin("CreateSession")
+	err := s.next.CreateSession(ctx, session)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	call := s.begin("GetSession")
+	session, err := s.next.GetSession(ctx, id)
+	call.end(err)
+	return session, err
+}
+
+func (s *InstrumentedStore) ListSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	call := s.begin("ListSessions")
+	sessions, err := s.next.ListSessions(ctx, username)
+	call.end(err)
+	return sessions, err
+}
+
+func (s *InstrumentedStore) UpdateSession(ctx context.Context, session *model.Session) error {
+	call := s.begin("UpdateSession")
+	err := s.next.UpdateSession(ctx, session)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteSession(ctx context.Context, id string) error {
+	call := s.begin("DeleteSession")
+	err := s.next.DeleteSession(ctx, id)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) SetTeam(ctx context.Context, team *model.Team) error {
+	call := s.begin("SetTeam")
+	err := s.next.SetTeam(ctx, team)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	call := s.begin("GetTeam")
+	team, err := s.next.GetTeam(ctx, name)
+	call.end(err)
+	return team, err
+}
+
+func (s *InstrumentedStore) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	call := s.begin("ListTeams")
+	teams, err := s.next.ListTeams(ctx)
+	call.end(err)
+	return teams, err
+}
+
+func (s *InstrumentedStore) DeleteTeam(ctx context.Context, name string) error {
+	call := s.begin("DeleteTeam")
+	err := s.next.DeleteTeam(ctx, name)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	call := s.begin("CreateNotification")
+	err := s.next.CreateNotification(ctx, notification)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	call := s.begin("ListNotifications")
+	notifications, err := s.next.ListNotifications(ctx, username, unreadOnly, limit)
+	call.end(err)
+	return notifications, err
+}
+
+func (s *InstrumentedStore) MarkNotificationsRead(ctx context.Context, username string, upToID int64) error {
+	call := s.begin("MarkNotificationsRead")
+	err := s.next.MarkNotificationsRead(ctx, username, upToID)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteNotifications(ctx context.Context, username string) error {
+	call := s.begin("DeleteNotifications")
+	err := s.next.DeleteNotifications(ctx, username)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) AddTokenToBlacklist(ctx context.Context, token string, expiresAt time.Time) error {
+	call := s.begin("AddTokenToBlacklist")
+	err := s.next.AddTokenToBlacklist(ctx, token, expiresAt)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	call := s.begin("IsTokenBlacklisted")
+	ok, err := s.next.IsTokenBlacklisted(ctx, token)
+	call.end(err)
+	return ok, err
+}
+
+func (s *InstrumentedStore) CleanupExpiredTokens(ctx context.Context) error {
+	call := s.begin("CleanupExpiredTokens")
+	err := s.next.CleanupExpiredTokens(ctx)
+	call.end(err)
+	return err
+}
+
+func (s *InstrumentedStore) IncrementTokenUsage(ctx context.Context, token string) (int64, error) {
+	call := s.begin("IncrementTokenUsage")
+	n, err := s.next.IncrementTokenUsage(ctx, token)
+	call.end(err)
+	return n, err
+}
+
+func (s *InstrumentedStore) CheckTokenRateLimit(ctx context.Context, token string, limit int64, duration time.Duration) (bool, error) {
+	call := s.begin("CheckTokenRateLimit")
+	ok, err := s.next.CheckTokenRateLimit(ctx, token, limit, duration)
+	call.end(err)
+	return ok, err
+}
+
+func (s *InstrumentedStore) ResetTokenUsage(ctx context.Context, token string) error {
+	call := s.begin("ResetTokenUsage")
+	err := s.next.ResetTokenUsage(ctx, token)
+	call.end(err)
+	return err
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// TestInstrumentedStore tests that calls are counted by method, failures
+// other than missing records as errors, and only called methods reported
+func TestInstrumentedStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewInstrumentedStore(NewInMemoryStore())
+
+	if err := s.Put(ctx, &model.Config{Namespace: "public", Group: "app", Key: "timeout", Value: "30s"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	s.Get(ctx, "public", "app", "timeout")
+	if _, err := s.Get(ctx, "public", "app", "missing"); err != ErrNotFound {
+		t.Fatalf("Get of a missing config = %v, want ErrNotFound", err)
+	}
+	s.CreateUser(ctx, &model.User{Username: "alice"})
+	if err := s.CreateUser(ctx, &model.User{Username: "alice"}); err == nil {
+		t.Fatal("CreateUser of an existing user succeeded")
+	}
+
+	stats := s.Stats()
+	want := []struct {
+		method        string
+		calls, errors int64
+	}{{"CreateUser", 2, 1}, {"Get", 2, 0}, {"Put", 1, 0}}
+	if len(stats) != len(want) {
+		t.Fatalf("got stats of %d methods, want %d", len(stats), len(want))
+	}
+	for i, w := range want {
+		st := stats[i]
+		var bucketed int64
+		for _, n := range st.Buckets {
+			bucketed += n
+		}
+		if st.Method != w.method || st.Calls != w.calls || st.Errors != w.errors || st.InFlight != 0 || bucketed != w.calls {
+			t.Errorf("stats[%d] = %s with %d calls, %d errors, %d in flight and %d bucketed, want %s with %d calls and %d errors",
+				i, st.Method, st.Calls, st.Errors, st.InFlight, bucketed, w.method, w.calls, w.errors)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package store
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sotowang/otter/internal/model"
@@ -21,10 +23,42 @@ type InMemoryStore struct {
 	users          sync.Map // map[string]*model.User (key: username)
 	namespaces     sync.Map // map[string]bool (key: namespace)
 	tokenBlacklist sync.Map // map[string]*TokenBlacklistEntry (key: token)
+	quotas         sync.Map // map[string]*model.NamespaceQuota (key: namespace)
+	sessions       sync.Map // map[string]*model.Session (key: session ID)
+	tenants        sync.Map // map[string]*model.Tenant (key: tenant name)
+	locks          sync.Map // map[string]*model.ConfigLock (key: namespace/group/key)
+	owners         sync.Map // map[string]*model.ConfigOwner (key: namespace/group/key)
+	teams          sync.Map // map[string]*model.Team (key: team name)
+	variants       sync.Map // map[string]*model.ConfigVariant (key: namespace/group/key/name)
+	historyID      atomic.Int64
+
+	versionMu sync.Mutex       // serializes Put so stored versions only increase
+	versions  map[string]int64 // last version allocated per key, kept after deletes
+
+	notificationMu sync.Mutex                       // guards notifications and notificationID
+	notifications  map[string][]*model.Notification // per username, oldest first
+	notificationID int64
+
+	scheduleMu sync.Mutex                       // guards schedules and scheduleID
+	schedules  map[int64]*model.ScheduledChange // key: scheduled change ID
+	scheduleID int64
+
+	accessMu sync.Mutex                     // guards access
+	access   map[string]*model.ConfigAccess // key: namespace/group/key
+
+	webhookMu  sync.Mutex                       // guards webhooks, deliveries and their IDs
+	webhooks   map[int64]*model.Webhook         // key: webhook ID
+	deliveries map[int64]*model.WebhookDelivery // key: delivery ID
+	webhookID  int64
+	deliveryID int64
+}
+
+func init() {
+	Register("memory", func(string) (Store, error) { return NewInMemoryStore(), nil })
 }
 
 func NewInMemoryStore() *InMemoryStore {
-	store := &InMemoryStore{}
+	store := &InMemoryStore{versions: make(map[string]int64), notifications: make(map[string][]*model.Notification), schedules: make(map[int64]*model.ScheduledChange), access: make(map[string]*model.ConfigAccess), webhooks: make(map[int64]*model.Webhook), deliveries: make(map[int64]*model.WebhookDelivery)}
 	// Add default public namespace
 	store.namespaces.Store("public", true)
 	// Start background cleanup for expired tokens
@@ -96,10 +130,26 @@ func (s *InMemoryStore) Put(ctx context.Context, config *model.Config) error {
 	if config.Type == "" {
 		config.Type = "text"
 	}
-	s.data.Store(config.Namespace+"/"+config.Group+"/"+config.Key, config)
+	SetChecksums(config)
+
+	k := config.Namespace + "/" + config.Group + "/" + config.Key
+	s.versionMu.Lock()
+	defer s.versionMu.Unlock()
+	s.versions[k]++
+	config.Version = s.versions[k]
+	s.data.Store(k, config)
 	return nil
 }
 
+// NextVersion allocates the next version of a key
+func (s *InMemoryStore) NextVersion(ctx context.Context, namespace, group, key string) (int64, error) {
+	k := namespace + "/" + group + "/" + key
+	s.versionMu.Lock()
+	defer s.versionMu.Unlock()
+	s.versions[k]++
+	return s.versions[k], nil
+}
+
 func (s *InMemoryStore) Delete(ctx context.Context, namespace, group, key string) error {
 	s.data.Delete(namespace + "/" + group + "/" + key)
 	return nil
@@ -117,8 +167,21 @@ func (s *InMemoryStore) List(ctx context.Context, namespace, group string) ([]*m
 	return configs, nil
 }
 
+func (s *InMemoryStore) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	var configs []*model.Config
+	s.data.Range(func(key, value any) bool {
+		cfg := value.(*model.Config)
+		if cfg.Namespace == namespace {
+			configs = append(configs, cfg)
+		}
+		return true
+	})
+	return configs, nil
+}
+
 func (s *InMemoryStore) CreateHistory(ctx context.Context, history *model.ConfigHistory) error {
 	key := history.Namespace + "/" + history.Group + "/" + history.Key
+	history.ID = s.historyID.Add(1)
 	val, _ := s.history.LoadOrStore(key, []*model.ConfigHistory{})
 	histories := val.([]*model.ConfigHistory)
 	histories = append(histories, history)
@@ -134,6 +197,47 @@ func (s *InMemoryStore) ListHistory(ctx context.Context, namespace, group, key s
 	return val.([]*model.ConfigHistory), nil
 }
 
+func (s *InMemoryStore) ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	histories := []*model.ConfigHistory{}
+	s.history.Range(func(key, value any) bool {
+		for _, h := range value.([]*model.ConfigHistory) {
+			if h.Namespace == namespace && !h.CreatedAt.Before(since) {
+				histories = append(histories, h)
+			}
+		}
+		return true
+	})
+
+	sort.Slice(histories, func(i, j int) bool {
+		if !histories[i].CreatedAt.Equal(histories[j].CreatedAt) {
+			return histories[i].CreatedAt.After(histories[j].CreatedAt)
+		}
+		return histories[i].ID > histories[j].ID
+	})
+	if limit > 0 && len(histories) > limit {
+		histories = histories[:limit]
+	}
+	return histories, nil
+}
+
+func (s *InMemoryStore) ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error) {
+	histories := []*model.ConfigHistory{}
+	s.history.Range(func(key, value any) bool {
+		for _, h := range value.([]*model.ConfigHistory) {
+			if h.Namespace == namespace && h.ID > afterID {
+				histories = append(histories, h)
+			}
+		}
+		return true
+	})
+
+	sort.Slice(histories, func(i, j int) bool { return histories[i].ID < histories[j].ID })
+	if limit > 0 && len(histories) > limit {
+		histories = histories[:limit]
+	}
+	return histories, nil
+}
+
 func (s *InMemoryStore) ListNamespaces(ctx context.Context) ([]string, error) {
 	var namespaces []string
 	s.namespaces.Range(func(key, value any) bool {
@@ -178,6 +282,661 @@ func (s *InMemoryStore) DeleteNamespace(ctx context.Context, namespace string) e
 	}
 
 	s.namespaces.Delete(namespace)
+	s.quotas.Delete(namespace)
+	s.locks.Range(func(key, value any) bool {
+		if value.(*model.ConfigLock).Namespace == namespace {
+			s.locks.Delete(key)
+		}
+		return true
+	})
+	s.owners.Range(func(key, value any) bool {
+		if value.(*model.ConfigOwner).Namespace == namespace {
+			s.owners.Delete(key)
+		}
+		return true
+	})
+	s.variants.Range(func(key, value any) bool {
+		if value.(*model.ConfigVariant).Namespace == namespace {
+			s.variants.Delete(key)
+		}
+		return true
+	})
+	s.scheduleMu.Lock()
+	for id, change := range s.schedules {
+		if change.Namespace == namespace {
+			delete(s.schedules, id)
+		}
+	}
+	s.scheduleMu.Unlock()
+	s.accessMu.Lock()
+	for id, access := range s.access {
+		if access.Namespace == namespace {
+			delete(s.access, id)
+		}
+	}
+	s.accessMu.Unlock()
+	s.webhookMu.Lock()
+	for id, hook := range s.webhooks {
+		if hook.Namespace == namespace {
+			delete(s.webhooks, id)
+		}
+	}
+	for id, delivery := range s.deliveries {
+		if delivery.Namespace == namespace {
+			delete(s.deliveries, id)
+		}
+	}
+	s.webhookMu.Unlock()
+	return nil
+}
+
+// GetNamespaceQuota returns the quota configured for a namespace
+func (s *InMemoryStore) GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	val, ok := s.quotas.Load(namespace)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return val.(*model.NamespaceQuota), nil
+}
+
+// SetNamespaceQuota creates or replaces the quota of a namespace
+func (s *InMemoryStore) SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error {
+	if _, ok := s.namespaces.Load(quota.Namespace); !ok {
+		return ErrNotFound
+	}
+	s.quotas.Store(quota.Namespace, quota)
+	return nil
+}
+
+// SetLock creates or replaces a lock
+func (s *InMemoryStore) SetLock(ctx context.Context, lock *model.ConfigLock) error {
+	if _, ok := s.namespaces.Load(lock.Namespace); !ok {
+		return ErrNotFound
+	}
+	stored := *lock
+	s.locks.Store(lock.Namespace+"/"+lock.Group+"/"+lock.Key, &stored)
+	return nil
+}
+
+func (s *InMemoryStore) GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error) {
+	val, ok := s.locks.Load(namespace + "/" + group + "/" + key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	lock := *val.(*model.ConfigLock)
+	return &lock, nil
+}
+
+// ListLocks returns the locks in a namespace, the namespace lock first
+func (s *InMemoryStore) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	locks := []*model.ConfigLock{}
+	s.locks.Range(func(key, value any) bool {
+		lock := *value.(*model.ConfigLock)
+		if lock.Namespace == namespace {
+			locks = append(locks, &lock)
+		}
+		return true
+	})
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].Group != locks[j].Group {
+			return locks[i].Group < locks[j].Group
+		}
+		return locks[i].Key < locks[j].Key
+	})
+	return locks, nil
+}
+
+func (s *InMemoryStore) DeleteLock(ctx context.Context, namespace, group, key string) error {
+	if _, ok := s.locks.LoadAndDelete(namespace + "/" + group + "/" + key); !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetOwner creates or replaces an owner
+func (s *InMemoryStore) SetOwner(ctx context.Context, owner *model.ConfigOwner) error {
+	if _, ok := s.namespaces.Load(owner.Namespace); !ok {
+		return ErrNotFound
+	}
+	stored := *owner
+	s.owners.Store(owner.Namespace+"/"+owner.Group+"/"+owner.Key, &stored)
+	return nil
+}
+
+func (s *InMemoryStore) GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	val, ok := s.owners.Load(namespace + "/" + group + "/" + key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	owner := *val.(*model.ConfigOwner)
+	return &owner, nil
+}
+
+// ListOwners returns the owners in a namespace ordered by group and key
+func (s *InMemoryStore) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	owners := []*model.ConfigOwner{}
+	s.owners.Range(func(key, value any) bool {
+		owner := *value.(*model.ConfigOwner)
+		if owner.Namespace == namespace {
+			owners = append(owners, &owner)
+		}
+		return true
+	})
+	sort.Slice(owners, func(i, j int) bool {
+		if owners[i].Group != owners[j].Group {
+			return owners[i].Group < owners[j].Group
+		}
+		return owners[i].Key < owners[j].Key
+	})
+	return owners, nil
+}
+
+func (s *InMemoryStore) DeleteOwner(ctx context.Context, namespace, group, key string) error {
+	if _, ok := s.owners.LoadAndDelete(namespace + "/" + group + "/" + key); !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetTeam creates or replaces a team
+func (s *InMemoryStore) SetTeam(ctx context.Context, team *model.Team) error {
+	stored := *team
+	stored.Members = append([]string(nil), team.Members...)
+	s.teams.Store(team.Name, &stored)
+	return nil
+}
+
+func (s *InMemoryStore) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	val, ok := s.teams.Load(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	team := *val.(*model.Team)
+	return &team, nil
+}
+
+func (s *InMemoryStore) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	teams := []*model.Team{}
+	s.teams.Range(func(key, value any) bool {
+		team := *value.(*model.Team)
+		teams = append(teams, &team)
+		return true
+	})
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].Name < teams[j].Name
+	})
+	return teams, nil
+}
+
+func (s *InMemoryStore) DeleteTeam(ctx context.Context, name string) error {
+	if _, ok := s.teams.LoadAndDelete(name); !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateNotification stores a notification, assigning it the next ID
+func (s *InMemoryStore) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	s.notificationMu.Lock()
+	defer s.notificationMu.Unlock()
+	s.notificationID++
+	notification.ID = s.notificationID
+	stored := *notification
+	s.notifications[notification.Username] = append(s.notifications[notification.Username], &stored)
+	return nil
+}
+
+// ListNotifications returns up to limit notifications of a user, newest first
+func (s *InMemoryStore) ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	s.notificationMu.Lock()
+	defer s.notificationMu.Unlock()
+	notifications := []*model.Notification{}
+	stored := s.notifications[username]
+	for i := len(stored) - 1; i >= 0 && (limit <= 0 || len(notifications) < limit); i-- {
+		if !unreadOnly || !stored[i].Read {
+			n := *stored[i]
+			notifications = append(notifications, &n)
+		}
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead marks the notifications of a user up to and including upToID as read
+func (s *InMemoryStore) MarkNotificationsRead(ctx context.Context, username string, upToID int64) error {
+	s.notificationMu.Lock()
+	defer s.notificationMu.Unlock()
+	for _, n := range s.notifications[username] {
+		if n.ID <= upToID {
+			n.Read = true
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryStore) DeleteNotifications(ctx context.Context, username string) error {
+	s.notificationMu.Lock()
+	defer s.notificationMu.Unlock()
+	delete(s.notifications, username)
+	return nil
+}
+
+// SetVariant creates or replaces a variant
+func (s *InMemoryStore) SetVariant(ctx context.Context, variant *model.ConfigVariant) error {
+	stored := *variant
+	s.variants.Store(variant.Namespace+"/"+variant.Group+"/"+variant.Key+"/"+variant.Name, &stored)
+	return nil
+}
+
+// ListVariants returns the variants of a key, group or namespace ordered by
+// group, key and name
+func (s *InMemoryStore) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	variants := []*model.ConfigVariant{}
+	s.variants.Range(func(_, value any) bool {
+		v := value.(*model.ConfigVariant)
+		if v.Namespace == namespace && (group == "" || v.Group == group) && (key == "" || v.Key == key) {
+			copied := *v
+			variants = append(variants, &copied)
+		}
+		return true
+	})
+	sort.Slice(variants, func(i, j int) bool {
+		a, b := variants[i], variants[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Name < b.Name
+	})
+	return variants, nil
+}
+
+func (s *InMemoryStore) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	if _, ok := s.variants.LoadAndDelete(namespace + "/" + group + "/" + key + "/" + name); !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordAccess adds the access count of access to the key's and replaces
+// its last access
+func (s *InMemoryStore) RecordAccess(ctx context.Context, access *model.ConfigAccess) error {
+	s.accessMu.Lock()
+	defer s.accessMu.Unlock()
+	id := access.Namespace + "/" + access.Group + "/" + access.Key
+	stored, ok := s.access[id]
+	if !ok {
+		stored = &model.ConfigAccess{Namespace: access.Namespace, Group: access.Group, Key: access.Key}
+		s.access[id] = stored
+	}
+	count := stored.AccessCount + access.AccessCount
+	*stored = *access
+	stored.AccessCount = count
+	return nil
+}
+
+// ListAccess returns the access records of a key, group or namespace
+// ordered by group and key
+func (s *InMemoryStore) ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error) {
+	s.accessMu.Lock()
+	defer s.accessMu.Unlock()
+	records := []*model.ConfigAccess{}
+	for _, a := range s.access {
+		if a.Namespace == namespace && (group == "" || a.Group == group) && (key == "" || a.Key == key) {
+			copied := *a
+			records = append(records, &copied)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Group != records[j].Group {
+			return records[i].Group < records[j].Group
+		}
+		return records[i].Key < records[j].Key
+	})
+	return records, nil
+}
+
+// CreateScheduledChange stores a scheduled change, assigning it the next ID
+func (s *InMemoryStore) CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	s.scheduleID++
+	change.ID = s.scheduleID
+	stored := *change
+	s.schedules[change.ID] = &stored
+	return nil
+}
+
+func (s *InMemoryStore) GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	stored, ok := s.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	change := *stored
+	return &change, nil
+}
+
+// ListScheduledChanges returns the scheduled changes in a namespace, soonest first
+func (s *InMemoryStore) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	return s.listScheduledChanges(func(change *model.ScheduledChange) bool {
+		return change.Namespace == namespace
+	}), nil
+}
+
+// ListDueScheduledChanges returns the pending changes due at now, soonest first
+func (s *InMemoryStore) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error) {
+	return s.listScheduledChanges(func(change *model.ScheduledChange) bool {
+		return change.Status == model.ScheduleStatusPending && !change.PublishAt.After(now)
+	}), nil
+}
+
+// listScheduledChanges returns copies of the scheduled changes matching keep, soonest first
+func (s *InMemoryStore) listScheduledChanges(keep func(*model.ScheduledChange) bool) []*model.ScheduledChange {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	changes := []*model.ScheduledChange{}
+	for _, stored := range s.schedules {
+		if keep(stored) {
+			change := *stored
+			changes = append(changes, &change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].PublishAt.Equal(changes[j].PublishAt) {
+			return changes[i].PublishAt.Before(changes[j].PublishAt)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	return changes
+}
+
+func (s *InMemoryStore) UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	if _, ok := s.schedules[change.ID]; !ok {
+		return ErrNotFound
+	}
+	stored := *change
+	s.schedules[change.ID] = &stored
+	return nil
+}
+
+// ClaimScheduledChange moves a pending change to publishing
+func (s *InMemoryStore) ClaimScheduledChange(ctx context.Context, id int64) error {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	change, ok := s.schedules[id]
+	if !ok || change.Status != model.ScheduleStatusPending {
+		return ErrNotFound
+	}
+	change.Status = model.ScheduleStatusPublishing
+	change.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *InMemoryStore) DeleteScheduledChange(ctx context.Context, id int64) error {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	if _, ok := s.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.schedules, id)
+	return nil
+}
+
+// CreateWebhook stores a webhook, assigning it the next ID
+func (s *InMemoryStore) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	if _, ok := s.namespaces.Load(hook.Namespace); !ok {
+		return ErrNotFound
+	}
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	s.webhookID++
+	hook.ID = s.webhookID
+	stored := *hook
+	s.webhooks[hook.ID] = &stored
+	return nil
+}
+
+func (s *InMemoryStore) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	stored, ok := s.webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	hook := *stored
+	return &hook, nil
+}
+
+// ListWebhooks returns the webhooks of a namespace ordered by ID
+func (s *InMemoryStore) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	hooks := []*model.Webhook{}
+	for _, stored := range s.webhooks {
+		if stored.Namespace == namespace {
+			hook := *stored
+			hooks = append(hooks, &hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+
+// DeleteWebhook deletes a webhook and its deliveries
+func (s *InMemoryStore) DeleteWebhook(ctx context.Context, id int64) error {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.webhooks, id)
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// CreateWebhookDelivery stores a delivery, assigning it the next ID
+func (s *InMemoryStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	s.deliveryID++
+	delivery.ID = s.deliveryID
+	stored := *delivery
+	s.deliveries[delivery.ID] = &stored
+	return nil
+}
+
+func (s *InMemoryStore) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	stored, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delivery := *stored
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of a webhook with
+// status, or with any status if it is empty, newest first
+func (s *InMemoryStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries := s.listWebhookDeliveries(func(delivery *model.WebhookDelivery) bool {
+		return delivery.WebhookID == webhookID && (status == "" || delivery.Status == status)
+	}, func(a, b *model.WebhookDelivery) bool { return a.ID > b.ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries due at
+// now, oldest first
+func (s *InMemoryStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries := s.listWebhookDeliveries(func(delivery *model.WebhookDelivery) bool {
+		return delivery.Status == model.DeliveryStatusPending && !delivery.NextAttemptAt.After(now)
+	}, func(a, b *model.WebhookDelivery) bool {
+		if !a.NextAttemptAt.Equal(b.NextAttemptAt) {
+			return a.NextAttemptAt.Before(b.NextAttemptAt)
+		}
+		return a.ID < b.ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// listWebhookDeliveries returns copies of the deliveries matching keep, sorted by less
+func (s *InMemoryStore) listWebhookDeliveries(keep func(*model.WebhookDelivery) bool, less func(a, b *model.WebhookDelivery) bool) []*model.WebhookDelivery {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	deliveries := []*model.WebhookDelivery{}
+	for _, stored := range s.deliveries {
+		if keep(stored) {
+			delivery := *stored
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return less(deliveries[i], deliveries[j]) })
+	return deliveries
+}
+
+func (s *InMemoryStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return ErrNotFound
+	}
+	stored := *delivery
+	s.deliveries[delivery.ID] = &stored
+	return nil
+}
+
+// ClaimWebhookDelivery postpones a pending delivery due at now to until
+func (s *InMemoryStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	delivery, ok := s.deliveries[id]
+	if !ok || delivery.Status != model.DeliveryStatusPending || delivery.NextAttemptAt.After(now) {
+		return ErrNotFound
+	}
+	delivery.NextAttemptAt = until
+	return nil
+}
+
+// PruneWebhookDeliveries deletes the deliveries delivered before
+func (s *InMemoryStore) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	for id, delivery := range s.deliveries {
+		if delivery.Status == model.DeliveryStatusDelivered && delivery.UpdatedAt.Before(before) {
+			delete(s.deliveries, id)
+		}
+	}
+	return nil
+}
+
+// NamespaceUsage returns the number of configs and total value bytes stored in a namespace
+func (s *InMemoryStore) NamespaceUsage(ctx context.Context, namespace string) (int64, int64, error) {
+	var configs, totalBytes int64
+	s.data.Range(func(key, value any) bool {
+		cfg := value.(*model.Config)
+		if cfg.Namespace == namespace {
+			configs++
+			totalBytes += int64(len(cfg.Value))
+		}
+		return true
+	})
+	return configs, totalBytes, nil
+}
+
+// CreateSession records a new login session
+func (s *InMemoryStore) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
+	if _, loaded := s.tenants.LoadOrStore(tenant.Name, tenant); loaded {
+		return fmt.Errorf("tenant already exists")
+	}
+	return nil
+}
+
+func (s *InMemoryStore) GetTenant(ctx context.Context, name string) (*model.Tenant, error) {
+	val, ok := s.tenants.Load(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return val.(*model.Tenant), nil
+}
+
+func (s *InMemoryStore) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	var tenants []*model.Tenant
+	s.tenants.Range(func(key, value any) bool {
+		tenants = append(tenants, value.(*model.Tenant))
+		return true
+	})
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Name < tenants[j].Name })
+	return tenants, nil
+}
+
+func (s *InMemoryStore) DeleteTenant(ctx context.Context, name string) error {
+	if _, ok := s.tenants.LoadAndDelete(name); !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *InMemoryStore) CreateSession(ctx context.Context, session *model.Session) error {
+	stored := *session
+	s.sessions.Store(session.ID, &stored)
+	return nil
+}
+
+// GetSession returns a session by ID
+func (s *InMemoryStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	val, ok := s.sessions.Load(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session := *val.(*model.Session)
+	return &session, nil
+}
+
+// ListSessions returns the unexpired sessions of a user, newest first
+func (s *InMemoryStore) ListSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	now := time.Now()
+	var sessions []*model.Session
+	s.sessions.Range(func(key, value any) bool {
+		session := *value.(*model.Session)
+		if session.Username == username && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, &session)
+		}
+		return true
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+// UpdateSession replaces a stored session
+func (s *InMemoryStore) UpdateSession(ctx context.Context, session *model.Session) error {
+	if _, ok := s.sessions.Load(session.ID); !ok {
+		return ErrNotFound
+	}
+	stored := *session
+	s.sessions.Store(session.ID, &stored)
+	return nil
+}
+
+// DeleteSession removes a session
+func (s *InMemoryStore) DeleteSession(ctx context.Context, id string) error {
+	s.sessions.Delete(id)
 	return nil
 }
 
@@ -219,6 +978,12 @@ func (s *InMemoryStore) CleanupExpiredTokens(ctx context.Context) error {
 		}
 		return true
 	})
+	s.sessions.Range(func(key, value any) bool {
+		if now.After(value.(*model.Session).ExpiresAt) {
+			s.sessions.Delete(key)
+		}
+		return true
+	})
 	return nil
 }
 
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -15,6 +16,18 @@ type PostgresStore struct {
 	db *sql.DB
 }
 
+func init() {
+	open := func(dsn string) (Store, error) {
+		s, err := NewPostgresStore(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	Register("postgres", open)
+	Register("postgresql", open)
+}
+
 func NewPostgresStore(dsn string) (*PostgresStore, error) {
 	db, err := sql.Open("pgx", dsn)
 	if err != nil {
@@ -71,6 +84,132 @@ func NewPostgresStore(dsn string) (*PostgresStore, error) {
 			ALTER TABLE otter.config_history ADD COLUMN type TEXT DEFAULT 'text'; 
 		END IF; 
 	END $$;
+	ALTER TABLE otter.config_history ADD COLUMN IF NOT EXISTS created_by TEXT DEFAULT 'system';
+	ALTER TABLE otter.configs ADD COLUMN IF NOT EXISTS content_type TEXT DEFAULT '';
+	ALTER TABLE otter.configs ADD COLUMN IF NOT EXISTS md5 TEXT DEFAULT '';
+	ALTER TABLE otter.configs ADD COLUMN IF NOT EXISTS sha256 TEXT DEFAULT '';
+	ALTER TABLE otter.configs ADD COLUMN IF NOT EXISTS size BIGINT DEFAULT 0;
+	ALTER TABLE otter.config_history ADD COLUMN IF NOT EXISTS content_type TEXT DEFAULT '';
+	CREATE TABLE IF NOT EXISTS otter.config_versions (
+		namespace TEXT,
+		"group" TEXT,
+		key TEXT,
+		version BIGINT,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS otter.namespace_quotas (
+		namespace TEXT PRIMARY KEY REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		max_configs BIGINT DEFAULT 0,
+		max_total_bytes BIGINT DEFAULT 0,
+		max_value_bytes BIGINT DEFAULT 0,
+		updated_by TEXT DEFAULT 'system',
+		updated_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE TABLE IF NOT EXISTS otter.config_locks (
+		namespace TEXT REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		owner TEXT,
+		reason TEXT,
+		created_at TIMESTAMP WITH TIME ZONE,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS otter.config_owners (
+		namespace TEXT REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		owner_user TEXT,
+		owner_team TEXT,
+		assigned_by TEXT,
+		assigned_at TIMESTAMP WITH TIME ZONE,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS otter.teams (
+		name TEXT PRIMARY KEY,
+		members TEXT,
+		updated_by TEXT,
+		updated_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE TABLE IF NOT EXISTS otter.notifications (
+		id BIGSERIAL PRIMARY KEY,
+		username TEXT,
+		namespace TEXT,
+		"group" TEXT,
+		key TEXT,
+		version BIGINT,
+		op_type TEXT,
+		actor TEXT,
+		created_at TIMESTAMP WITH TIME ZONE,
+		is_read BOOLEAN DEFAULT FALSE
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_username ON otter.notifications (username, id);
+	CREATE TABLE IF NOT EXISTS otter.config_variants (
+		namespace TEXT REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		name TEXT,
+		selector TEXT,
+		value TEXT,
+		updated_by TEXT,
+		updated_at TIMESTAMP WITH TIME ZONE,
+		PRIMARY KEY (namespace, "group", key, name)
+	);
+	CREATE TABLE IF NOT EXISTS otter.scheduled_changes (
+		id BIGSERIAL PRIMARY KEY,
+		namespace TEXT REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		value TEXT,
+		type TEXT,
+		content_type TEXT,
+		publish_at TIMESTAMP WITH TIME ZONE,
+		status TEXT,
+		error TEXT,
+		created_by TEXT,
+		created_at TIMESTAMP WITH TIME ZONE,
+		updated_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_changes_due ON otter.scheduled_changes (status, publish_at);
+	CREATE TABLE IF NOT EXISTS otter.webhooks (
+		id BIGSERIAL PRIMARY KEY,
+		namespace TEXT REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		url TEXT,
+		secret TEXT,
+		created_by TEXT,
+		created_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE TABLE IF NOT EXISTS otter.webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id BIGINT REFERENCES otter.webhooks(id) ON DELETE CASCADE,
+		namespace TEXT,
+		event TEXT,
+		payload TEXT,
+		status TEXT,
+		attempts INTEGER,
+		next_attempt_at TIMESTAMP WITH TIME ZONE,
+		last_error TEXT,
+		response_status INTEGER,
+		created_at TIMESTAMP WITH TIME ZONE,
+		updated_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON otter.webhook_deliveries (status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON otter.webhook_deliveries (webhook_id, id);
+	CREATE TABLE IF NOT EXISTS otter.config_access (
+		namespace TEXT REFERENCES otter.namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		access_count BIGINT,
+		last_accessed TIMESTAMP WITH TIME ZONE,
+		last_client_id TEXT,
+		last_username TEXT,
+		last_ip TEXT,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS otter.tenants (
+		name TEXT PRIMARY KEY,
+		created_by TEXT DEFAULT 'system',
+		created_at TIMESTAMP WITH TIME ZONE
+	);
 	CREATE TABLE IF NOT EXISTS otter.users (
 		id SERIAL PRIMARY KEY,
 		username TEXT UNIQUE,
@@ -80,6 +219,20 @@ func NewPostgresStore(dsn string) (*PostgresStore, error) {
 		created_at TIMESTAMP WITH TIME ZONE,
 		updated_at TIMESTAMP WITH TIME ZONE
 	);
+	CREATE TABLE IF NOT EXISTS otter.sessions (
+		id TEXT PRIMARY KEY,
+		username TEXT,
+		ip TEXT,
+		user_agent TEXT,
+		created_at TIMESTAMP WITH TIME ZONE,
+		refreshed_at TIMESTAMP WITH TIME ZONE,
+		expires_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS idx_sessions_username ON otter.sessions (username);
+	CREATE TABLE IF NOT EXISTS otter.token_blacklist (
+		token TEXT PRIMARY KEY,
+		expires_at TIMESTAMP WITH TIME ZONE
+	);
 	-- Insert default public namespace if not exists
 	INSERT INTO otter.namespaces (name) VALUES ('public') ON CONFLICT DO NOTHING;
 	`
@@ -143,32 +296,77 @@ func (s *PostgresStore) DeleteUser(ctx context.Context, username string) error {
 }
 
 func (s *PostgresStore) Get(ctx context.Context, namespace, group, key string) (*model.Config, error) {
-	query := `SELECT namespace, "group", key, value, type, version, created_by, updated_by, created_at, updated_at FROM otter.configs WHERE namespace = $1 AND "group" = $2 AND key = $3`
+	query := `SELECT namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at FROM otter.configs WHERE namespace = $1 AND "group" = $2 AND key = $3`
 	row := s.db.QueryRowContext(ctx, query, namespace, group, key)
 
 	var cfg model.Config
-	if err := row.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
+	if err := row.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.ContentType, &cfg.MD5, &cfg.SHA256, &cfg.Size, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+	fillChecksums(&cfg)
 	return &cfg, nil
 }
 
 func (s *PostgresStore) Put(ctx context.Context, config *model.Config) error {
+	SetChecksums(config)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if config.Version, err = s.nextVersion(ctx, tx, config.Namespace, config.Group, config.Key); err != nil {
+		return err
+	}
+
 	query := `
-	INSERT INTO otter.configs (namespace, "group", key, value, type, version, created_by, updated_by, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	INSERT INTO otter.configs (namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	ON CONFLICT(namespace, "group", key) DO UPDATE SET
 		value = excluded.value,
 		type = excluded.type,
+		content_type = excluded.content_type,
+		md5 = excluded.md5,
+		sha256 = excluded.sha256,
+		size = excluded.size,
 		version = excluded.version,
 		updated_by = excluded.updated_by,
 		updated_at = excluded.updated_at;
 	`
-	_, err := s.db.ExecContext(ctx, query, config.Namespace, config.Group, config.Key, config.Value, config.Type, config.Version, config.CreatedBy, config.UpdatedBy, config.CreatedAt, config.UpdatedAt)
-	return err
+	if _, err := tx.ExecContext(ctx, query, config.Namespace, config.Group, config.Key, config.Value, config.Type, config.ContentType, config.MD5, config.SHA256, config.Size, config.Version, config.CreatedBy, config.UpdatedBy, config.CreatedAt, config.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NextVersion allocates the next version of a key
+func (s *PostgresStore) NextVersion(ctx context.Context, namespace, group, key string) (int64, error) {
+	return s.nextVersion(ctx, s.db, namespace, group, key)
+}
+
+// nextVersion increments the version sequence of a key. A key's sequence
+// starts after the highest version it already has in configs or history,
+// so versions stay increasing across upgrades and deletes.
+func (s *PostgresStore) nextVersion(ctx context.Context, q rowQuerier, namespace, group, key string) (int64, error) {
+	query := `
+	INSERT INTO otter.config_versions (namespace, "group", key, version)
+	VALUES ($1, $2, $3, (
+		SELECT COALESCE(MAX(version), 0) + 1 FROM (
+			SELECT version FROM otter.configs WHERE namespace = $1 AND "group" = $2 AND key = $3
+			UNION ALL
+			SELECT version FROM otter.config_history WHERE namespace = $1 AND "group" = $2 AND key = $3
+		) AS versions
+	))
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET version = config_versions.version + 1
+	RETURNING version
+	`
+	var version int64
+	err := q.QueryRowContext(ctx, query, namespace, group, key).Scan(&version)
+	return version, err
 }
 
 func (s *PostgresStore) Delete(ctx context.Context, namespace, group, key string) error {
@@ -178,7 +376,7 @@ func (s *PostgresStore) Delete(ctx context.Context, namespace, group, key string
 }
 
 func (s *PostgresStore) List(ctx context.Context, namespace, group string) ([]*model.Config, error) {
-	query := `SELECT namespace, "group", key, value, type, version, created_by, updated_by, created_at, updated_at FROM otter.configs WHERE namespace = $1 AND "group" = $2`
+	query := `SELECT namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at FROM otter.configs WHERE namespace = $1 AND "group" = $2`
 	rows, err := s.db.QueryContext(ctx, query, namespace, group)
 	if err != nil {
 		return nil, err
@@ -188,9 +386,30 @@ func (s *PostgresStore) List(ctx context.Context, namespace, group string) ([]*m
 	var configs []*model.Config
 	for rows.Next() {
 		var cfg model.Config
-		if err := rows.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
+		if err := rows.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.ContentType, &cfg.MD5, &cfg.SHA256, &cfg.Size, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
+			return nil, err
+		}
+		fillChecksums(&cfg)
+		configs = append(configs, &cfg)
+	}
+	return configs, nil
+}
+
+func (s *PostgresStore) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	query := `SELECT namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at FROM otter.configs WHERE namespace = $1 ORDER BY "group", key`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []*model.Config
+	for rows.Next() {
+		var cfg model.Config
+		if err := rows.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.ContentType, &cfg.MD5, &cfg.SHA256, &cfg.Size, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
 			return nil, err
 		}
+		fillChecksums(&cfg)
 		configs = append(configs, &cfg)
 	}
 	return configs, nil
@@ -198,15 +417,15 @@ func (s *PostgresStore) List(ctx context.Context, namespace, group string) ([]*m
 
 func (s *PostgresStore) CreateHistory(ctx context.Context, history *model.ConfigHistory) error {
 	query := `
-	INSERT INTO otter.config_history (namespace, "group", key, value, type, version, op_type, created_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	INSERT INTO otter.config_history (namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
-	_, err := s.db.ExecContext(ctx, query, history.Namespace, history.Group, history.Key, history.Value, history.Type, history.Version, history.OpType, history.CreatedAt)
+	_, err := s.db.ExecContext(ctx, query, history.Namespace, history.Group, history.Key, history.Value, history.Type, history.ContentType, history.Version, history.OpType, history.CreatedBy, history.CreatedAt)
 	return err
 }
 
 func (s *PostgresStore) ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error) {
-	query := `SELECT id, namespace, "group", key, value, type, version, op_type, created_at FROM otter.config_history WHERE namespace = $1 AND "group" = $2 AND key = $3 ORDER BY version DESC`
+	query := `SELECT id, namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at FROM otter.config_history WHERE namespace = $1 AND "group" = $2 AND key = $3 ORDER BY version DESC`
 	rows, err := s.db.QueryContext(ctx, query, namespace, group, key)
 	if err != nil {
 		return nil, err
@@ -216,7 +435,45 @@ func (s *PostgresStore) ListHistory(ctx context.Context, namespace, group, key s
 	var histories []*model.ConfigHistory
 	for rows.Next() {
 		var h model.ConfigHistory
-		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.Version, &h.OpType, &h.CreatedAt); err != nil {
+		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.ContentType, &h.Version, &h.OpType, &h.CreatedBy, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		histories = append(histories, &h)
+	}
+	return histories, nil
+}
+
+func (s *PostgresStore) ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at FROM otter.config_history WHERE namespace = $1 AND created_at >= $2 ORDER BY created_at DESC, id DESC LIMIT $3`
+	rows, err := s.db.QueryContext(ctx, query, namespace, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := []*model.ConfigHistory{}
+	for rows.Next() {
+		var h model.ConfigHistory
+		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.ContentType, &h.Version, &h.OpType, &h.CreatedBy, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		histories = append(histories, &h)
+	}
+	return histories, nil
+}
+
+func (s *PostgresStore) ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at FROM otter.config_history WHERE namespace = $1 AND id > $2 ORDER BY id LIMIT $3`
+	rows, err := s.db.QueryContext(ctx, query, namespace, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := []*model.ConfigHistory{}
+	for rows.Next() {
+		var h model.ConfigHistory
+		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.ContentType, &h.Version, &h.OpType, &h.CreatedBy, &h.CreatedAt); err != nil {
 			return nil, err
 		}
 		histories = append(histories, &h)
@@ -259,25 +516,734 @@ func (s *PostgresStore) DeleteNamespace(ctx context.Context, namespace string) e
 	return err
 }
 
+// CreateTenant registers a tenant
+func (s *PostgresStore) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
+	query := `INSERT INTO otter.tenants (name, created_by, created_at) VALUES ($1, $2, $3)`
+	_, err := s.db.ExecContext(ctx, query, tenant.Name, tenant.CreatedBy, tenant.CreatedAt)
+	return err
+}
+
+func (s *PostgresStore) GetTenant(ctx context.Context, name string) (*model.Tenant, error) {
+	query := `SELECT name, created_by, created_at FROM otter.tenants WHERE name = $1`
+	var t model.Tenant
+	if err := s.db.QueryRowContext(ctx, query, name).Scan(&t.Name, &t.CreatedBy, &t.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *PostgresStore) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	query := `SELECT name, created_by, created_at FROM otter.tenants ORDER BY name`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []*model.Tenant
+	for rows.Next() {
+		var t model.Tenant
+		if err := rows.Scan(&t.Name, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, &t)
+	}
+	return tenants, rows.Err()
+}
+
+func (s *PostgresStore) DeleteTenant(ctx context.Context, name string) error {
+	query := `DELETE FROM otter.tenants WHERE name = $1`
+	res, err := s.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetNamespaceQuota returns the quota configured for a namespace
+func (s *PostgresStore) GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	query := `SELECT namespace, max_configs, max_total_bytes, max_value_bytes, updated_by, updated_at FROM otter.namespace_quotas WHERE namespace = $1`
+	row := s.db.QueryRowContext(ctx, query, namespace)
+
+	var q model.NamespaceQuota
+	if err := row.Scan(&q.Namespace, &q.MaxConfigs, &q.MaxTotalBytes, &q.MaxValueBytes, &q.UpdatedBy, &q.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &q, nil
+}
+
+// SetNamespaceQuota creates or replaces the quota of a namespace
+func (s *PostgresStore) SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx, `SELECT 1 FROM otter.namespaces WHERE name = $1`, quota.Namespace).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	}
+	query := `
+	INSERT INTO otter.namespace_quotas (namespace, max_configs, max_total_bytes, max_value_bytes, updated_by, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT(namespace) DO UPDATE SET
+		max_configs = excluded.max_configs,
+		max_total_bytes = excluded.max_total_bytes,
+		max_value_bytes = excluded.max_value_bytes,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, quota.Namespace, quota.MaxConfigs, quota.MaxTotalBytes, quota.MaxValueBytes, quota.UpdatedBy, quota.UpdatedAt)
+	return err
+}
+
+// SetLock creates or replaces a lock
+func (s *PostgresStore) SetLock(ctx context.Context, lock *model.ConfigLock) error {
+	query := `
+	INSERT INTO otter.config_locks (namespace, "group", key, owner, reason, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET
+		owner = excluded.owner,
+		reason = excluded.reason,
+		created_at = excluded.created_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, lock.Namespace, lock.Group, lock.Key, lock.Owner, lock.Reason, lock.CreatedAt)
+	return err
+}
+
+func (s *PostgresStore) GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error) {
+	query := `SELECT namespace, "group", key, owner, reason, created_at FROM otter.config_locks WHERE namespace = $1 AND "group" = $2 AND key = $3`
+	var l model.ConfigLock
+	if err := s.db.QueryRowContext(ctx, query, namespace, group, key).Scan(&l.Namespace, &l.Group, &l.Key, &l.Owner, &l.Reason, &l.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListLocks returns the locks in a namespace, the namespace lock first
+func (s *PostgresStore) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	query := `SELECT namespace, "group", key, owner, reason, created_at FROM otter.config_locks WHERE namespace = $1 ORDER BY "group", key`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := []*model.ConfigLock{}
+	for rows.Next() {
+		var l model.ConfigLock
+		if err := rows.Scan(&l.Namespace, &l.Group, &l.Key, &l.Owner, &l.Reason, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		locks = append(locks, &l)
+	}
+	return locks, rows.Err()
+}
+
+func (s *PostgresStore) DeleteLock(ctx context.Context, namespace, group, key string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM otter.config_locks WHERE namespace = $1 AND "group" = $2 AND key = $3`, namespace, group, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetOwner creates or replaces an owner
+func (s *PostgresStore) SetOwner(ctx context.Context, owner *model.ConfigOwner) error {
+	query := `
+	INSERT INTO otter.config_owners (namespace, "group", key, owner_user, owner_team, assigned_by, assigned_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET
+		owner_user = excluded.owner_user,
+		owner_team = excluded.owner_team,
+		assigned_by = excluded.assigned_by,
+		assigned_at = excluded.assigned_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, owner.Namespace, owner.Group, owner.Key, owner.User, owner.Team, owner.AssignedBy, owner.AssignedAt)
+	return err
+}
+
+func (s *PostgresStore) GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	query := `SELECT namespace, "group", key, owner_user, owner_team, assigned_by, assigned_at FROM otter.config_owners WHERE namespace = $1 AND "group" = $2 AND key = $3`
+	var o model.ConfigOwner
+	if err := s.db.QueryRowContext(ctx, query, namespace, group, key).Scan(&o.Namespace, &o.Group, &o.Key, &o.User, &o.Team, &o.AssignedBy, &o.AssignedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &o, nil
+}
+
+// ListOwners returns the owners in a namespace ordered by group and key
+func (s *PostgresStore) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	query := `SELECT namespace, "group", key, owner_user, owner_team, assigned_by, assigned_at FROM otter.config_owners WHERE namespace = $1 ORDER BY "group", key`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := []*model.ConfigOwner{}
+	for rows.Next() {
+		var o model.ConfigOwner
+		if err := rows.Scan(&o.Namespace, &o.Group, &o.Key, &o.User, &o.Team, &o.AssignedBy, &o.AssignedAt); err != nil {
+			return nil, err
+		}
+		owners = append(owners, &o)
+	}
+	return owners, rows.Err()
+}
+
+func (s *PostgresStore) DeleteOwner(ctx context.Context, namespace, group, key string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM otter.config_owners WHERE namespace = $1 AND "group" = $2 AND key = $3`, namespace, group, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetTeam creates or replaces a team. Members are stored as a JSON array.
+func (s *PostgresStore) SetTeam(ctx context.Context, team *model.Team) error {
+	members, err := json.Marshal(team.Members)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO otter.teams (name, members, updated_by, updated_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT(name) DO UPDATE SET
+		members = excluded.members,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at;
+	`
+	_, err = s.db.ExecContext(ctx, query, team.Name, string(members), team.UpdatedBy, team.UpdatedAt)
+	return err
+}
+
+func (s *PostgresStore) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	query := `SELECT name, members, updated_by, updated_at FROM otter.teams WHERE name = $1`
+	team, err := scanTeam(s.db.QueryRowContext(ctx, query, name))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return team, err
+}
+
+func (s *PostgresStore) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, members, updated_by, updated_at FROM otter.teams ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*model.Team{}
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+func (s *PostgresStore) DeleteTeam(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM otter.teams WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateNotification stores a notification, assigning it the next ID
+func (s *PostgresStore) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	query := `
+	INSERT INTO otter.notifications (username, namespace, "group", key, version, op_type, actor, created_at, is_read)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id
+	`
+	n := notification
+	return s.db.QueryRowContext(ctx, query, n.Username, n.Namespace, n.Group, n.Key, n.Version, n.OpType, n.Actor, n.CreatedAt, n.Read).Scan(&n.ID)
+}
+
+// ListNotifications returns up to limit notifications of a user, newest first
+func (s *PostgresStore) ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	query := `SELECT id, username, namespace, "group", key, version, op_type, actor, created_at, is_read FROM otter.notifications WHERE username = $1 AND (NOT $2 OR NOT is_read) ORDER BY id DESC`
+	args := []any{username, unreadOnly}
+	if limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(&n.ID, &n.Username, &n.Namespace, &n.Group, &n.Key, &n.Version, &n.OpType, &n.Actor, &n.CreatedAt, &n.Read); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marks the notifications of a user up to and including upToID as read
+func (s *PostgresStore) MarkNotificationsRead(ctx context.Context, username string, upToID int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE otter.notifications SET is_read = TRUE WHERE username = $1 AND id <= $2`, username, upToID)
+	return err
+}
+
+func (s *PostgresStore) DeleteNotifications(ctx context.Context, username string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM otter.notifications WHERE username = $1`, username)
+	return err
+}
+
+// SetVariant creates or replaces a variant. Its selector is stored as a
+// JSON object.
+func (s *PostgresStore) SetVariant(ctx context.Context, variant *model.ConfigVariant) error {
+	selector, err := json.Marshal(variant.Selector)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO otter.config_variants (namespace, "group", key, name, selector, value, updated_by, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT(namespace, "group", key, name) DO UPDATE SET
+		selector = excluded.selector,
+		value = excluded.value,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at;
+	`
+	v := variant
+	_, err = s.db.ExecContext(ctx, query, v.Namespace, v.Group, v.Key, v.Name, string(selector), v.Value, v.UpdatedBy, v.UpdatedAt)
+	return err
+}
+
+// ListVariants returns the variants of a key, group or namespace ordered by
+// group, key and name
+func (s *PostgresStore) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	query := `
+	SELECT namespace, "group", key, name, selector, value, updated_by, updated_at FROM otter.config_variants
+	WHERE namespace = $1 AND ($2 = '' OR "group" = $2) AND ($3 = '' OR key = $3)
+	ORDER BY "group", key, name
+	`
+	rows, err := s.db.QueryContext(ctx, query, namespace, group, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []*model.ConfigVariant{}
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, rows.Err()
+}
+
+func (s *PostgresStore) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM otter.config_variants WHERE namespace = $1 AND "group" = $2 AND key = $3 AND name = $4`, namespace, group, key, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordAccess adds the access count of access to the key's and replaces
+// its last access
+func (s *PostgresStore) RecordAccess(ctx context.Context, access *model.ConfigAccess) error {
+	query := `
+	INSERT INTO otter.config_access (namespace, "group", key, access_count, last_accessed, last_client_id, last_username, last_ip)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET
+		access_count = otter.config_access.access_count + excluded.access_count,
+		last_accessed = excluded.last_accessed,
+		last_client_id = excluded.last_client_id,
+		last_username = excluded.last_username,
+		last_ip = excluded.last_ip;
+	`
+	a := access
+	_, err := s.db.ExecContext(ctx, query, a.Namespace, a.Group, a.Key, a.AccessCount, *a.LastAccessed, a.LastClientID, a.LastUsername, a.LastIP)
+	return err
+}
+
+// ListAccess returns the access records of a key, group or namespace
+// ordered by group and key
+func (s *PostgresStore) ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error) {
+	query := `
+	SELECT namespace, "group", key, access_count, last_accessed, last_client_id, last_username, last_ip FROM otter.config_access
+	WHERE namespace = $1 AND ($2 = '' OR "group" = $2) AND ($3 = '' OR key = $3)
+	ORDER BY "group", key
+	`
+	rows, err := s.db.QueryContext(ctx, query, namespace, group, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*model.ConfigAccess{}
+	for rows.Next() {
+		access, err := scanAccess(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, access)
+	}
+	return records, rows.Err()
+}
+
+// CreateScheduledChange stores a scheduled change, assigning it the next ID
+func (s *PostgresStore) CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	query := `
+	INSERT INTO otter.scheduled_changes (namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	RETURNING id
+	`
+	c := change
+	return s.db.QueryRowContext(ctx, query, c.Namespace, c.Group, c.Key, c.Value, c.Type, c.ContentType, c.PublishAt, c.Status, c.Error, c.CreatedBy, c.CreatedAt, c.UpdatedAt).Scan(&c.ID)
+}
+
+func (s *PostgresStore) GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at FROM otter.scheduled_changes WHERE id = $1`
+	change, err := scanScheduledChange(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return change, err
+}
+
+// ListScheduledChanges returns the scheduled changes in a namespace, soonest first
+func (s *PostgresStore) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at FROM otter.scheduled_changes WHERE namespace = $1 ORDER BY publish_at, id`
+	return s.queryScheduledChanges(ctx, query, namespace)
+}
+
+// ListDueScheduledChanges returns the pending changes due at now, soonest first
+func (s *PostgresStore) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at FROM otter.scheduled_changes WHERE status = $1 AND publish_at <= $2 ORDER BY publish_at, id`
+	return s.queryScheduledChanges(ctx, query, model.ScheduleStatusPending, now)
+}
+
+func (s *PostgresStore) queryScheduledChanges(ctx context.Context, query string, args ...any) ([]*model.ScheduledChange, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*model.ScheduledChange{}
+	for rows.Next() {
+		change, err := scanScheduledChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (s *PostgresStore) UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	query := `
+	UPDATE otter.scheduled_changes
+	SET value = $1, type = $2, content_type = $3, publish_at = $4, status = $5, error = $6, updated_at = $7
+	WHERE id = $8
+	`
+	c := change
+	res, err := s.db.ExecContext(ctx, query, c.Value, c.Type, c.ContentType, c.PublishAt, c.Status, c.Error, c.UpdatedAt, c.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimScheduledChange moves a pending change to publishing
+func (s *PostgresStore) ClaimScheduledChange(ctx context.Context, id int64) error {
+	query := `UPDATE otter.scheduled_changes SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4`
+	res, err := s.db.ExecContext(ctx, query, model.ScheduleStatusPublishing, time.Now(), id, model.ScheduleStatusPending)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteScheduledChange(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM otter.scheduled_changes WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateWebhook stores a webhook, assigning it the next ID
+func (s *PostgresStore) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	query := `INSERT INTO otter.webhooks (namespace, url, secret, created_by, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	return s.db.QueryRowContext(ctx, query, hook.Namespace, hook.URL, hook.Secret, hook.CreatedBy, hook.CreatedAt).Scan(&hook.ID)
+}
+
+func (s *PostgresStore) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	query := `SELECT id, namespace, url, secret, created_by, created_at FROM otter.webhooks WHERE id = $1`
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return hook, err
+}
+
+// ListWebhooks returns the webhooks of a namespace ordered by ID
+func (s *PostgresStore) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	query := `SELECT id, namespace, url, secret, created_by, created_at FROM otter.webhooks WHERE namespace = $1 ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*model.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook deletes a webhook, its deliveries cascading
+func (s *PostgresStore) DeleteWebhook(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM otter.webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateWebhookDelivery stores a delivery, assigning it the next ID
+func (s *PostgresStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+	INSERT INTO otter.webhook_deliveries (webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING id
+	`
+	d := delivery
+	return s.db.QueryRowContext(ctx, query, d.WebhookID, d.Namespace, d.Event, d.Payload, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.ResponseStatus, d.CreatedAt, d.UpdatedAt).Scan(&d.ID)
+}
+
+func (s *PostgresStore) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at FROM otter.webhook_deliveries WHERE id = $1`
+	delivery, err := scanWebhookDelivery(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return delivery, err
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of a webhook with
+// status, or with any status if it is empty, newest first
+func (s *PostgresStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at FROM otter.webhook_deliveries WHERE webhook_id = $1 AND ($2 = '' OR status = $2) ORDER BY id DESC LIMIT $3`
+	return s.queryWebhookDeliveries(ctx, query, webhookID, status, limit)
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries due at
+// now, oldest first.
+func (s *PostgresStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at FROM otter.webhook_deliveries WHERE status = $1 AND next_attempt_at <= $2 ORDER BY next_attempt_at, id LIMIT $3`
+	return s.queryWebhookDeliveries(ctx, query, model.DeliveryStatusPending, now, limit)
+}
+
+func (s *PostgresStore) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]*model.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+	UPDATE otter.webhook_deliveries
+	SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, response_status = $5, updated_at = $6
+	WHERE id = $7
+	`
+	d := delivery
+	res, err := s.db.ExecContext(ctx, query, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.ResponseStatus, d.UpdatedAt, d.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimWebhookDelivery postpones a pending delivery due at now to until
+func (s *PostgresStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	query := `UPDATE otter.webhook_deliveries SET next_attempt_at = $1 WHERE id = $2 AND status = $3 AND next_attempt_at <= $4`
+	res, err := s.db.ExecContext(ctx, query, until, id, model.DeliveryStatusPending, now)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries deletes the deliveries delivered before
+func (s *PostgresStore) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	query := `DELETE FROM otter.webhook_deliveries WHERE status = $1 AND updated_at < $2`
+	_, err := s.db.ExecContext(ctx, query, model.DeliveryStatusDelivered, before)
+	return err
+}
+
+// NamespaceUsage returns the number of configs and total value bytes stored in a namespace
+func (s *PostgresStore) NamespaceUsage(ctx context.Context, namespace string) (int64, int64, error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(OCTET_LENGTH(value)), 0) FROM otter.configs WHERE namespace = $1`
+	var configs, totalBytes int64
+	if err := s.db.QueryRowContext(ctx, query, namespace).Scan(&configs, &totalBytes); err != nil {
+		return 0, 0, err
+	}
+	return configs, totalBytes, nil
+}
+
+// CreateSession records a new login session
+func (s *PostgresStore) CreateSession(ctx context.Context, session *model.Session) error {
+	query := `INSERT INTO otter.sessions (id, username, ip, user_agent, created_at, refreshed_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.ExecContext(ctx, query, session.ID, session.Username, session.IP, session.UserAgent, session.CreatedAt, session.RefreshedAt, session.ExpiresAt)
+	return err
+}
+
+// GetSession returns a session by ID
+func (s *PostgresStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	query := `SELECT id, username, ip, user_agent, created_at, refreshed_at, expires_at FROM otter.sessions WHERE id = $1`
+	var session model.Session
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&session.ID, &session.Username, &session.IP, &session.UserAgent, &session.CreatedAt, &session.RefreshedAt, &session.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListSessions returns the unexpired sessions of a user, newest first
+func (s *PostgresStore) ListSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	query := `SELECT id, username, ip, user_agent, created_at, refreshed_at, expires_at FROM otter.sessions WHERE username = $1 AND expires_at > $2 ORDER BY created_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, username, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		var session model.Session
+		if err := rows.Scan(&session.ID, &session.Username, &session.IP, &session.UserAgent, &session.CreatedAt, &session.RefreshedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+// UpdateSession replaces a stored session
+func (s *PostgresStore) UpdateSession(ctx context.Context, session *model.Session) error {
+	query := `UPDATE otter.sessions SET ip = $1, user_agent = $2, refreshed_at = $3, expires_at = $4 WHERE id = $5`
+	res, err := s.db.ExecContext(ctx, query, session.IP, session.UserAgent, session.RefreshedAt, session.ExpiresAt, session.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteSession removes a session
+func (s *PostgresStore) DeleteSession(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM otter.sessions WHERE id = $1`, id)
+	return err
+}
+
 // AddTokenToBlacklist adds a token to the blacklist
 func (s *PostgresStore) AddTokenToBlacklist(ctx context.Context, token string, expiresAt time.Time) error {
-	// For simplicity, we'll use a simple implementation that returns nil
-	// In a real implementation, you would store this in a database table
-	return nil
+	query := `INSERT INTO otter.token_blacklist (token, expires_at) VALUES ($1, $2) ON CONFLICT (token) DO UPDATE SET expires_at = EXCLUDED.expires_at`
+	_, err := s.db.ExecContext(ctx, query, token, expiresAt)
+	return err
 }
 
 // IsTokenBlacklisted checks if a token is blacklisted
 func (s *PostgresStore) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	// For simplicity, we'll use a simple implementation that returns false
-	// In a real implementation, you would check this against a database table
-	return false, nil
+	query := `SELECT COUNT(*) FROM otter.token_blacklist WHERE token = $1 AND expires_at > $2`
+	var n int
+	if err := s.db.QueryRowContext(ctx, query, token, time.Now()).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
 }
 
-// CleanupExpiredTokens removes expired tokens from the blacklist
+// CleanupExpiredTokens removes expired tokens from the blacklist and expired sessions
 func (s *PostgresStore) CleanupExpiredTokens(ctx context.Context) error {
-	// For simplicity, we'll use a simple implementation that returns nil
-	// In a real implementation, you would clean up expired tokens from a database table
-	return nil
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM otter.token_blacklist WHERE expires_at <= $1`, now); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM otter.sessions WHERE expires_at <= $1`, now)
+	return err
 }
 
 // IncrementTokenUsage increments the token usage count
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory opens a store from a DSN with the scheme it is registered under
+type Factory func(dsn string) (Store, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a store backend available to Open for DSNs with scheme,
+// such as "postgres" for postgres://... DSNs. Backends register themselves
+// from an init function; adding one takes no change to the code opening
+// the store. Like database/sql.Register, it panics if factory is nil or
+// scheme is already registered.
+func Register(scheme string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("store: Register factory is nil")
+	}
+	if _, dup := factories[scheme]; dup {
+		panic("store: Register called twice for scheme " + scheme)
+	}
+	factories[scheme] = factory
+}
+
+// Schemes returns the registered DSN schemes, sorted
+func Schemes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	schemes := make([]string, 0, len(factories))
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Scheme returns the scheme selecting the backend of dsn. An empty DSN is
+// memory, and one without a scheme, like "host=db user=otter", postgres,
+// as DSNs were PostgreSQL connection strings before other backends could
+// be chosen.
+func Scheme(dsn string) string {
+	if dsn == "" {
+		return "memory"
+	}
+	scheme, _, ok := strings.Cut(dsn, "://")
+	if !ok {
+		return "postgres"
+	}
+	return scheme
+}
+
+// Open opens the store for dsn with the backend registered for its scheme
+func Open(dsn string) (Store, error) {
+	scheme := Scheme(dsn)
+	factoriesMu.RLock()
+	factory, ok := factories[scheme]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown store scheme %q, expected one of %s", scheme, strings.Join(Schemes(), ", "))
+	}
+	return factory(dsn)
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestOpen tests that DSNs open the backend registered for their scheme
+func TestOpen(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"":                                  "memory",
+		"memory://":                         "memory",
+		"postgres://otter@db:5432/otter":    "postgres",
+		"host=db user=otter dbname=otter":   "postgres",
+		"sqlite:///var/lib/otter/otter.db":  "sqlite",
+		"etcd://etcd-0:2379,etcd-1:2379/ot": "etcd",
+	} {
+		if got := Scheme(dsn); got != want {
+			t.Errorf("Scheme(%q) = %q, want %q", dsn, got, want)
+		}
+	}
+
+	if s, err := Open("memory://"); err != nil {
+		t.Errorf("Open(memory://) failed: %v", err)
+	} else if _, ok := s.(*InMemoryStore); !ok {
+		t.Errorf("Open(memory://) returned %T, want *InMemoryStore", s)
+	}
+	if s, err := Open("sqlite://" + filepath.Join(t.TempDir(), "otter.db")); err != nil {
+		t.Errorf("Open(sqlite://) failed: %v", err)
+	} else if _, ok := s.(*SQLiteStore); !ok {
+		t.Errorf("Open(sqlite://) returned %T, want *SQLiteStore", s)
+	}
+	if _, err := Open("etcd://etcd-0:2379"); err == nil {
+		t.Error("Open of an unregistered scheme succeeded")
+	}
+
+	Register("test", func(dsn string) (Store, error) { return NewInMemoryStore(), nil })
+	if _, err := Open("test://anything"); err != nil {
+		t.Errorf("Open of a registered scheme failed: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a scheme twice did not panic")
+		}
+	}()
+	Register("test", func(dsn string) (Store, error) { return nil, nil })
+}
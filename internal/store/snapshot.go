@@ -0,0 +1,191 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// snapshotVersion is the format version of memory snapshots
+const snapshotVersion = 1
+
+// memorySnapshot is everything an InMemoryStore holds but rate limit usage
+type memorySnapshot struct {
+	Version        int                               `json:"version"`
+	Configs        map[string]*model.Config          `json:"configs"`
+	Versions       map[string]int64                  `json:"versions"`
+	History        map[string][]*model.ConfigHistory `json:"history"`
+	HistoryID      int64                             `json:"history_id"`
+	Namespaces     map[string]bool                   `json:"namespaces"`
+	Quotas         map[string]*model.NamespaceQuota  `json:"quotas"`
+	Locks          map[string]*model.ConfigLock      `json:"locks"`
+	Owners         map[string]*model.ConfigOwner     `json:"owners"`
+	Variants       map[string]*model.ConfigVariant   `json:"variants"`
+	Access         map[string]*model.ConfigAccess    `json:"access"`
+	Schedules      map[int64]*model.ScheduledChange  `json:"schedules"`
+	ScheduleID     int64                             `json:"schedule_id"`
+	Webhooks       map[int64]*model.Webhook          `json:"webhooks"`
+	Deliveries     map[int64]*model.WebhookDelivery  `json:"deliveries"`
+	WebhookID      int64                             `json:"webhook_id"`
+	DeliveryID     int64                             `json:"delivery_id"`
+	Tenants        map[string]*model.Tenant          `json:"tenants"`
+	Users          map[string]*model.User            `json:"users"`
+	Sessions       map[string]*model.Session         `json:"sessions"`
+	Teams          map[string]*model.Team            `json:"teams"`
+	Notifications  map[string][]*model.Notification  `json:"notifications"`
+	NotificationID int64                             `json:"notification_id"`
+	TokenBlacklist map[string]*TokenBlacklistEntry   `json:"token_blacklist"`
+}
+
+// WriteSnapshot writes everything in the store but rate limit usage to w
+// as JSON. Writes made while it runs may be partly included.
+func (s *InMemoryStore) WriteSnapshot(w io.Writer) error {
+	snap := memorySnapshot{
+		Version:        snapshotVersion,
+		Configs:        dumpMap[*model.Config](&s.data),
+		History:        dumpMap[[]*model.ConfigHistory](&s.history),
+		HistoryID:      s.historyID.Load(),
+		Namespaces:     dumpMap[bool](&s.namespaces),
+		Quotas:         dumpMap[*model.NamespaceQuota](&s.quotas),
+		Locks:          dumpMap[*model.ConfigLock](&s.locks),
+		Owners:         dumpMap[*model.ConfigOwner](&s.owners),
+		Variants:       dumpMap[*model.ConfigVariant](&s.variants),
+		Tenants:        dumpMap[*model.Tenant](&s.tenants),
+		Users:          dumpMap[*model.User](&s.users),
+		Sessions:       dumpMap[*model.Session](&s.sessions),
+		Teams:          dumpMap[*model.Team](&s.teams),
+		TokenBlacklist: dumpMap[*TokenBlacklistEntry](&s.tokenBlacklist),
+	}
+
+	// Encode under the locks, as the maps they guard are changed in place
+	s.versionMu.Lock()
+	defer s.versionMu.Unlock()
+	s.notificationMu.Lock()
+	defer s.notificationMu.Unlock()
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	s.accessMu.Lock()
+	defer s.accessMu.Unlock()
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	snap.Versions = s.versions
+	snap.Notifications, snap.NotificationID = s.notifications, s.notificationID
+	snap.Schedules, snap.ScheduleID = s.schedules, s.scheduleID
+	snap.Access = s.access
+	snap.Webhooks, snap.Deliveries = s.webhooks, s.deliveries
+	snap.WebhookID, snap.DeliveryID = s.webhookID, s.deliveryID
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// ReadSnapshot adds what a snapshot written by WriteSnapshot holds to the
+// store, replacing records with the same keys. It is meant to fill a new
+// store before it is used.
+func (s *InMemoryStore) ReadSnapshot(r io.Reader) error {
+	var snap memorySnapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	loadMap(&s.data, snap.Configs)
+	loadMap(&s.history, snap.History)
+	s.historyID.Store(snap.HistoryID)
+	loadMap(&s.namespaces, snap.Namespaces)
+	loadMap(&s.quotas, snap.Quotas)
+	loadMap(&s.locks, snap.Locks)
+	loadMap(&s.owners, snap.Owners)
+	loadMap(&s.variants, snap.Variants)
+	loadMap(&s.tenants, snap.Tenants)
+	loadMap(&s.users, snap.Users)
+	loadMap(&s.sessions, snap.Sessions)
+	loadMap(&s.teams, snap.Teams)
+	loadMap(&s.tokenBlacklist, snap.TokenBlacklist)
+
+	s.versionMu.Lock()
+	for k, v := range snap.Versions {
+		s.versions[k] = v
+	}
+	s.versionMu.Unlock()
+	s.notificationMu.Lock()
+	for k, v := range snap.Notifications {
+		s.notifications[k] = v
+	}
+	s.notificationID = snap.NotificationID
+	s.notificationMu.Unlock()
+	s.scheduleMu.Lock()
+	for k, v := range snap.Schedules {
+		s.schedules[k] = v
+	}
+	s.scheduleID = snap.ScheduleID
+	s.scheduleMu.Unlock()
+	s.accessMu.Lock()
+	for k, v := range snap.Access {
+		s.access[k] = v
+	}
+	s.accessMu.Unlock()
+	s.webhookMu.Lock()
+	for k, v := range snap.Webhooks {
+		s.webhooks[k] = v
+	}
+	for k, v := range snap.Deliveries {
+		s.deliveries[k] = v
+	}
+	s.webhookID, s.deliveryID = snap.WebhookID, snap.DeliveryID
+	s.webhookMu.Unlock()
+	return nil
+}
+
+// SaveSnapshot writes a snapshot to path, replacing the file at once so
+// a crash midway leaves the previous snapshot intact
+func (s *InMemoryStore) SaveSnapshot(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := s.WriteSnapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadSnapshot reads the snapshot saved to path. It returns an error
+// satisfying errors.Is(err, fs.ErrNotExist) if there is none yet.
+func (s *InMemoryStore) LoadSnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.ReadSnapshot(f)
+}
+
+func dumpMap[V any](m *sync.Map) map[string]V {
+	out := make(map[string]V)
+	m.Range(func(key, value any) bool {
+		out[key.(string)] = value.(V)
+		return true
+	})
+	return out
+}
+
+func loadMap[V any](m *sync.Map, in map[string]V) {
+	for key, value := range in {
+		m.Store(key, value)
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -16,6 +17,18 @@ type SQLiteStore struct {
 	db *sql.DB
 }
 
+// init registers sqlite://path DSNs, such as sqlite:///var/lib/otter.db
+// or sqlite://otter.db relative to the working directory
+func init() {
+	Register("sqlite", func(dsn string) (Store, error) {
+		s, err := NewSQLiteStore(strings.TrimPrefix(dsn, "sqlite://"))
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
 func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -38,6 +51,10 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		key TEXT,
 		value TEXT,
 		type TEXT DEFAULT 'text',
+		content_type TEXT DEFAULT '',
+		md5 TEXT DEFAULT '',
+		sha256 TEXT DEFAULT '',
+		size INTEGER DEFAULT 0,
 		version INTEGER,
 		created_by TEXT DEFAULT 'system',
 		updated_by TEXT DEFAULT 'system',
@@ -52,8 +69,130 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		key TEXT,
 		value TEXT,
 		type TEXT DEFAULT 'text',
+		content_type TEXT DEFAULT '',
+		version INTEGER,
+		op_type TEXT,
+		created_by TEXT DEFAULT 'system',
+		created_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS config_versions (
+		namespace TEXT,
+		"group" TEXT,
+		key TEXT,
 		version INTEGER,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS namespace_quotas (
+		namespace TEXT PRIMARY KEY REFERENCES namespaces(name) ON DELETE CASCADE,
+		max_configs INTEGER DEFAULT 0,
+		max_total_bytes INTEGER DEFAULT 0,
+		max_value_bytes INTEGER DEFAULT 0,
+		updated_by TEXT DEFAULT 'system',
+		updated_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS config_locks (
+		namespace TEXT REFERENCES namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		owner TEXT,
+		reason TEXT,
+		created_at DATETIME,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS config_owners (
+		namespace TEXT REFERENCES namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		owner_user TEXT,
+		owner_team TEXT,
+		assigned_by TEXT,
+		assigned_at DATETIME,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS teams (
+		name TEXT PRIMARY KEY,
+		members TEXT,
+		updated_by TEXT,
+		updated_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT,
+		namespace TEXT,
+		"group" TEXT,
+		key TEXT,
+		version BIGINT,
 		op_type TEXT,
+		actor TEXT,
+		created_at DATETIME,
+		is_read BOOLEAN DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_username ON notifications (username, id);
+	CREATE TABLE IF NOT EXISTS config_variants (
+		namespace TEXT REFERENCES namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		name TEXT,
+		selector TEXT,
+		value TEXT,
+		updated_by TEXT,
+		updated_at DATETIME,
+		PRIMARY KEY (namespace, "group", key, name)
+	);
+	CREATE TABLE IF NOT EXISTS scheduled_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		namespace TEXT REFERENCES namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		value TEXT,
+		type TEXT,
+		content_type TEXT,
+		publish_at DATETIME,
+		status TEXT,
+		error TEXT,
+		created_by TEXT,
+		created_at DATETIME,
+		updated_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_changes_due ON scheduled_changes (status, publish_at);
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		namespace TEXT REFERENCES namespaces(name) ON DELETE CASCADE,
+		url TEXT,
+		secret TEXT,
+		created_by TEXT,
+		created_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER REFERENCES webhooks(id) ON DELETE CASCADE,
+		namespace TEXT,
+		event TEXT,
+		payload TEXT,
+		status TEXT,
+		attempts INTEGER,
+		next_attempt_at DATETIME,
+		last_error TEXT,
+		response_status INTEGER,
+		created_at DATETIME,
+		updated_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
+	CREATE TABLE IF NOT EXISTS config_access (
+		namespace TEXT REFERENCES namespaces(name) ON DELETE CASCADE,
+		"group" TEXT,
+		key TEXT,
+		access_count BIGINT,
+		last_accessed DATETIME,
+		last_client_id TEXT,
+		last_username TEXT,
+		last_ip TEXT,
+		PRIMARY KEY (namespace, "group", key)
+	);
+	CREATE TABLE IF NOT EXISTS tenants (
+		name TEXT PRIMARY KEY,
+		created_by TEXT DEFAULT 'system',
 		created_at DATETIME
 	);
 	CREATE TABLE IF NOT EXISTS users (
@@ -65,6 +204,20 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		created_at DATETIME,
 		updated_at DATETIME
 	);
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		username TEXT,
+		ip TEXT,
+		user_agent TEXT,
+		created_at DATETIME,
+		refreshed_at DATETIME,
+		expires_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_sessions_username ON sessions (username);
+	CREATE TABLE IF NOT EXISTS token_blacklist (
+		token TEXT PRIMARY KEY,
+		expires_at DATETIME
+	);
 	-- Insert default public namespace if not exists
 	INSERT OR IGNORE INTO namespaces (name) VALUES ('public');
 	`
@@ -72,13 +225,23 @@ func NewSQLiteStore(dbPath string) (*SQLiteStore, error) {
 		return nil, err
 	}
 
-	// Add type column to config_history if it doesn't exist
+	// Add columns added after the first release if they don't exist
 	// SQLite doesn't support IF NOT EXISTS for ALTER TABLE, so we use a try-catch approach
-	alterQuery := `ALTER TABLE config_history ADD COLUMN type TEXT DEFAULT 'text'`
-	if _, err := db.Exec(alterQuery); err != nil {
-		// Ignore error if column already exists
-		if !strings.Contains(err.Error(), "duplicate column name") {
-			return nil, err
+	alterQueries := []string{
+		`ALTER TABLE config_history ADD COLUMN type TEXT DEFAULT 'text'`,
+		`ALTER TABLE config_history ADD COLUMN created_by TEXT DEFAULT 'system'`,
+		`ALTER TABLE configs ADD COLUMN content_type TEXT DEFAULT ''`,
+		`ALTER TABLE configs ADD COLUMN md5 TEXT DEFAULT ''`,
+		`ALTER TABLE configs ADD COLUMN sha256 TEXT DEFAULT ''`,
+		`ALTER TABLE configs ADD COLUMN size INTEGER DEFAULT 0`,
+		`ALTER TABLE config_history ADD COLUMN content_type TEXT DEFAULT ''`,
+	}
+	for _, alterQuery := range alterQueries {
+		if _, err := db.Exec(alterQuery); err != nil {
+			// Ignore error if column already exists
+			if !strings.Contains(err.Error(), "duplicate column name") {
+				return nil, err
+			}
 		}
 	}
 
@@ -138,32 +301,77 @@ func (s *SQLiteStore) DeleteUser(ctx context.Context, username string) error {
 }
 
 func (s *SQLiteStore) Get(ctx context.Context, namespace, group, key string) (*model.Config, error) {
-	query := `SELECT namespace, "group", key, value, type, version, created_by, updated_by, created_at, updated_at FROM configs WHERE namespace = ? AND "group" = ? AND key = ?`
+	query := `SELECT namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at FROM configs WHERE namespace = ? AND "group" = ? AND key = ?`
 	row := s.db.QueryRowContext(ctx, query, namespace, group, key)
 
 	var cfg model.Config
-	if err := row.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
+	if err := row.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.ContentType, &cfg.MD5, &cfg.SHA256, &cfg.Size, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
+	fillChecksums(&cfg)
 	return &cfg, nil
 }
 
 func (s *SQLiteStore) Put(ctx context.Context, config *model.Config) error {
+	SetChecksums(config)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if config.Version, err = s.nextVersion(ctx, tx, config.Namespace, config.Group, config.Key); err != nil {
+		return err
+	}
+
 	query := `
-	INSERT INTO configs (namespace, "group", key, value, type, version, created_by, updated_by, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO configs (namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(namespace, "group", key) DO UPDATE SET
 		value = excluded.value,
 		type = excluded.type,
+		content_type = excluded.content_type,
+		md5 = excluded.md5,
+		sha256 = excluded.sha256,
+		size = excluded.size,
 		version = excluded.version,
 		updated_by = excluded.updated_by,
 		updated_at = excluded.updated_at;
 	`
-	_, err := s.db.ExecContext(ctx, query, config.Namespace, config.Group, config.Key, config.Value, config.Type, config.Version, config.CreatedBy, config.UpdatedBy, config.CreatedAt, config.UpdatedAt)
-	return err
+	if _, err := tx.ExecContext(ctx, query, config.Namespace, config.Group, config.Key, config.Value, config.Type, config.ContentType, config.MD5, config.SHA256, config.Size, config.Version, config.CreatedBy, config.UpdatedBy, config.CreatedAt, config.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NextVersion allocates the next version of a key
+func (s *SQLiteStore) NextVersion(ctx context.Context, namespace, group, key string) (int64, error) {
+	return s.nextVersion(ctx, s.db, namespace, group, key)
+}
+
+// nextVersion increments the version sequence of a key. A key's sequence
+// starts after the highest version it already has in configs or history,
+// so versions stay increasing across upgrades and deletes.
+func (s *SQLiteStore) nextVersion(ctx context.Context, q rowQuerier, namespace, group, key string) (int64, error) {
+	query := `
+	INSERT INTO config_versions (namespace, "group", key, version)
+	VALUES (?1, ?2, ?3, (
+		SELECT COALESCE(MAX(version), 0) + 1 FROM (
+			SELECT version FROM configs WHERE namespace = ?1 AND "group" = ?2 AND key = ?3
+			UNION ALL
+			SELECT version FROM config_history WHERE namespace = ?1 AND "group" = ?2 AND key = ?3
+		) AS versions
+	))
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET version = config_versions.version + 1
+	RETURNING version
+	`
+	var version int64
+	err := q.QueryRowContext(ctx, query, namespace, group, key).Scan(&version)
+	return version, err
 }
 
 func (s *SQLiteStore) Delete(ctx context.Context, namespace, group, key string) error {
@@ -173,7 +381,7 @@ func (s *SQLiteStore) Delete(ctx context.Context, namespace, group, key string)
 }
 
 func (s *SQLiteStore) List(ctx context.Context, namespace, group string) ([]*model.Config, error) {
-	query := `SELECT namespace, "group", key, value, type, version, created_by, updated_by, created_at, updated_at FROM configs WHERE namespace = ? AND "group" = ?`
+	query := `SELECT namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at FROM configs WHERE namespace = ? AND "group" = ?`
 	rows, err := s.db.QueryContext(ctx, query, namespace, group)
 	if err != nil {
 		return nil, err
@@ -183,9 +391,30 @@ func (s *SQLiteStore) List(ctx context.Context, namespace, group string) ([]*mod
 	var configs []*model.Config
 	for rows.Next() {
 		var cfg model.Config
-		if err := rows.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
+		if err := rows.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.ContentType, &cfg.MD5, &cfg.SHA256, &cfg.Size, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
+			return nil, err
+		}
+		fillChecksums(&cfg)
+		configs = append(configs, &cfg)
+	}
+	return configs, nil
+}
+
+func (s *SQLiteStore) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	query := `SELECT namespace, "group", key, value, type, content_type, md5, sha256, size, version, created_by, updated_by, created_at, updated_at FROM configs WHERE namespace = ? ORDER BY "group", key`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var configs []*model.Config
+	for rows.Next() {
+		var cfg model.Config
+		if err := rows.Scan(&cfg.Namespace, &cfg.Group, &cfg.Key, &cfg.Value, &cfg.Type, &cfg.ContentType, &cfg.MD5, &cfg.SHA256, &cfg.Size, &cfg.Version, &cfg.CreatedBy, &cfg.UpdatedBy, &cfg.CreatedAt, &cfg.UpdatedAt); err != nil {
 			return nil, err
 		}
+		fillChecksums(&cfg)
 		configs = append(configs, &cfg)
 	}
 	return configs, nil
@@ -193,15 +422,15 @@ func (s *SQLiteStore) List(ctx context.Context, namespace, group string) ([]*mod
 
 func (s *SQLiteStore) CreateHistory(ctx context.Context, history *model.ConfigHistory) error {
 	query := `
-	INSERT INTO config_history (namespace, "group", key, value, version, op_type, created_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO config_history (namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := s.db.ExecContext(ctx, query, history.Namespace, history.Group, history.Key, history.Value, history.Version, history.OpType, history.CreatedAt)
+	_, err := s.db.ExecContext(ctx, query, history.Namespace, history.Group, history.Key, history.Value, history.Type, history.ContentType, history.Version, history.OpType, history.CreatedBy, history.CreatedAt)
 	return err
 }
 
 func (s *SQLiteStore) ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error) {
-	query := `SELECT id, namespace, "group", key, value, version, op_type, created_at FROM config_history WHERE namespace = ? AND "group" = ? AND key = ? ORDER BY version DESC`
+	query := `SELECT id, namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at FROM config_history WHERE namespace = ? AND "group" = ? AND key = ? ORDER BY version DESC`
 	rows, err := s.db.QueryContext(ctx, query, namespace, group, key)
 	if err != nil {
 		return nil, err
@@ -211,7 +440,45 @@ func (s *SQLiteStore) ListHistory(ctx context.Context, namespace, group, key str
 	var histories []*model.ConfigHistory
 	for rows.Next() {
 		var h model.ConfigHistory
-		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Version, &h.OpType, &h.CreatedAt); err != nil {
+		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.ContentType, &h.Version, &h.OpType, &h.CreatedBy, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		histories = append(histories, &h)
+	}
+	return histories, nil
+}
+
+func (s *SQLiteStore) ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at FROM config_history WHERE namespace = ? AND created_at >= ? ORDER BY created_at DESC, id DESC LIMIT ?`
+	rows, err := s.db.QueryContext(ctx, query, namespace, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := []*model.ConfigHistory{}
+	for rows.Next() {
+		var h model.ConfigHistory
+		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.ContentType, &h.Version, &h.OpType, &h.CreatedBy, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		histories = append(histories, &h)
+	}
+	return histories, nil
+}
+
+func (s *SQLiteStore) ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, version, op_type, created_by, created_at FROM config_history WHERE namespace = ? AND id > ? ORDER BY id LIMIT ?`
+	rows, err := s.db.QueryContext(ctx, query, namespace, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histories := []*model.ConfigHistory{}
+	for rows.Next() {
+		var h model.ConfigHistory
+		if err := rows.Scan(&h.ID, &h.Namespace, &h.Group, &h.Key, &h.Value, &h.Type, &h.ContentType, &h.Version, &h.OpType, &h.CreatedBy, &h.CreatedAt); err != nil {
 			return nil, err
 		}
 		histories = append(histories, &h)
@@ -250,48 +517,869 @@ func (s *SQLiteStore) DeleteNamespace(ctx context.Context, namespace string) err
 	}
 
 	query := `DELETE FROM namespaces WHERE name = ?`
-	_, err := s.db.ExecContext(ctx, query, namespace)
+	if _, err := s.db.ExecContext(ctx, query, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM namespace_quotas WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM config_locks WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM config_owners WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM config_variants WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM scheduled_changes WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE namespace = ?`, namespace); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM config_access WHERE namespace = ?`, namespace)
 	return err
 }
 
-// AddTokenToBlacklist adds a token to the blacklist
-func (s *SQLiteStore) AddTokenToBlacklist(ctx context.Context, token string, expiresAt time.Time) error {
-	// For simplicity, we'll use a simple implementation that returns nil
-	// In a real implementation, you would store this in a database table
+// CreateTenant registers a tenant
+func (s *SQLiteStore) CreateTenant(ctx context.Context, tenant *model.Tenant) error {
+	query := `INSERT INTO tenants (name, created_by, created_at) VALUES (?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, query, tenant.Name, tenant.CreatedBy, tenant.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStore) GetTenant(ctx context.Context, name string) (*model.Tenant, error) {
+	query := `SELECT name, created_by, created_at FROM tenants WHERE name = ?`
+	var t model.Tenant
+	if err := s.db.QueryRowContext(ctx, query, name).Scan(&t.Name, &t.CreatedBy, &t.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *SQLiteStore) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	query := `SELECT name, created_by, created_at FROM tenants ORDER BY name`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []*model.Tenant
+	for rows.Next() {
+		var t model.Tenant
+		if err := rows.Scan(&t.Name, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, &t)
+	}
+	return tenants, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteTenant(ctx context.Context, name string) error {
+	query := `DELETE FROM tenants WHERE name = ?`
+	res, err := s.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-// IsTokenBlacklisted checks if a token is blacklisted
-func (s *SQLiteStore) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	// For simplicity, we'll use a simple implementation that returns false
-	// In a real implementation, you would check this against a database table
-	return false, nil
+// GetNamespaceQuota returns the quota configured for a namespace
+func (s *SQLiteStore) GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	query := `SELECT namespace, max_configs, max_total_bytes, max_value_bytes, updated_by, updated_at FROM namespace_quotas WHERE namespace = ?`
+	row := s.db.QueryRowContext(ctx, query, namespace)
+
+	var q model.NamespaceQuota
+	if err := row.Scan(&q.Namespace, &q.MaxConfigs, &q.MaxTotalBytes, &q.MaxValueBytes, &q.UpdatedBy, &q.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &q, nil
 }
 
-// CleanupExpiredTokens removes expired tokens from the blacklist
-func (s *SQLiteStore) CleanupExpiredTokens(ctx context.Context) error {
-	// For simplicity, we'll use a simple implementation that returns nil
-	// In a real implementation, you would clean up expired tokens from a database table
+// SetNamespaceQuota creates or replaces the quota of a namespace
+func (s *SQLiteStore) SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx, `SELECT 1 FROM namespaces WHERE name = ?`, quota.Namespace).Scan(&exists); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	}
+	query := `
+	INSERT INTO namespace_quotas (namespace, max_configs, max_total_bytes, max_value_bytes, updated_by, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(namespace) DO UPDATE SET
+		max_configs = excluded.max_configs,
+		max_total_bytes = excluded.max_total_bytes,
+		max_value_bytes = excluded.max_value_bytes,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, quota.Namespace, quota.MaxConfigs, quota.MaxTotalBytes, quota.MaxValueBytes, quota.UpdatedBy, quota.UpdatedAt)
+	return err
+}
+
+// SetLock creates or replaces a lock
+func (s *SQLiteStore) SetLock(ctx context.Context, lock *model.ConfigLock) error {
+	query := `
+	INSERT INTO config_locks (namespace, "group", key, owner, reason, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET
+		owner = excluded.owner,
+		reason = excluded.reason,
+		created_at = excluded.created_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, lock.Namespace, lock.Group, lock.Key, lock.Owner, lock.Reason, lock.CreatedAt)
+	return err
+}
+
+func (s *SQLiteStore) GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error) {
+	query := `SELECT namespace, "group", key, owner, reason, created_at FROM config_locks WHERE namespace = ? AND "group" = ? AND key = ?`
+	var l model.ConfigLock
+	if err := s.db.QueryRowContext(ctx, query, namespace, group, key).Scan(&l.Namespace, &l.Group, &l.Key, &l.Owner, &l.Reason, &l.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListLocks returns the locks in a namespace, the namespace lock first
+func (s *SQLiteStore) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	query := `SELECT namespace, "group", key, owner, reason, created_at FROM config_locks WHERE namespace = ? ORDER BY "group", key`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := []*model.ConfigLock{}
+	for rows.Next() {
+		var l model.ConfigLock
+		if err := rows.Scan(&l.Namespace, &l.Group, &l.Key, &l.Owner, &l.Reason, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		locks = append(locks, &l)
+	}
+	return locks, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteLock(ctx context.Context, namespace, group, key string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM config_locks WHERE namespace = ? AND "group" = ? AND key = ?`, namespace, group, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-// IncrementTokenUsage increments the token usage count
-func (s *SQLiteStore) IncrementTokenUsage(ctx context.Context, token string) (int64, error) {
-	// For simplicity, we'll use a simple implementation that returns 1
-	// In a real implementation, you would track this in a database table
-	return 1, nil
+// SetOwner creates or replaces an owner
+func (s *SQLiteStore) SetOwner(ctx context.Context, owner *model.ConfigOwner) error {
+	query := `
+	INSERT INTO config_owners (namespace, "group", key, owner_user, owner_team, assigned_by, assigned_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET
+		owner_user = excluded.owner_user,
+		owner_team = excluded.owner_team,
+		assigned_by = excluded.assigned_by,
+		assigned_at = excluded.assigned_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, owner.Namespace, owner.Group, owner.Key, owner.User, owner.Team, owner.AssignedBy, owner.AssignedAt)
+	return err
 }
 
-// CheckTokenRateLimit checks if a token has exceeded the rate limit
-func (s *SQLiteStore) CheckTokenRateLimit(ctx context.Context, token string, limit int64, duration time.Duration) (bool, error) {
-	// For simplicity, we'll use a simple implementation that returns true
-	// In a real implementation, you would check this against a database table
-	return true, nil
+func (s *SQLiteStore) GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	query := `SELECT namespace, "group", key, owner_user, owner_team, assigned_by, assigned_at FROM config_owners WHERE namespace = ? AND "group" = ? AND key = ?`
+	var o model.ConfigOwner
+	if err := s.db.QueryRowContext(ctx, query, namespace, group, key).Scan(&o.Namespace, &o.Group, &o.Key, &o.User, &o.Team, &o.AssignedBy, &o.AssignedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &o, nil
 }
 
-// ResetTokenUsage resets the token usage count
-func (s *SQLiteStore) ResetTokenUsage(ctx context.Context, token string) error {
-	// For simplicity, we'll use a simple implementation that returns nil
-	// In a real implementation, you would reset this in a database table
+// ListOwners returns the owners in a namespace ordered by group and key
+func (s *SQLiteStore) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	query := `SELECT namespace, "group", key, owner_user, owner_team, assigned_by, assigned_at FROM config_owners WHERE namespace = ? ORDER BY "group", key`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := []*model.ConfigOwner{}
+	for rows.Next() {
+		var o model.ConfigOwner
+		if err := rows.Scan(&o.Namespace, &o.Group, &o.Key, &o.User, &o.Team, &o.AssignedBy, &o.AssignedAt); err != nil {
+			return nil, err
+		}
+		owners = append(owners, &o)
+	}
+	return owners, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteOwner(ctx context.Context, namespace, group, key string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM config_owners WHERE namespace = ? AND "group" = ? AND key = ?`, namespace, group, key)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
+
+// SetTeam creates or replaces a team. Members are stored as a JSON array.
+func (s *SQLiteStore) SetTeam(ctx context.Context, team *model.Team) error {
+	members, err := json.Marshal(team.Members)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO teams (name, members, updated_by, updated_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET
+		members = excluded.members,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at;
+	`
+	_, err = s.db.ExecContext(ctx, query, team.Name, string(members), team.UpdatedBy, team.UpdatedAt)
+	return err
+}
+
+func (s *SQLiteStore) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	query := `SELECT name, members, updated_by, updated_at FROM teams WHERE name = ?`
+	team, err := scanTeam(s.db.QueryRowContext(ctx, query, name))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return team, err
+}
+
+func (s *SQLiteStore) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, members, updated_by, updated_at FROM teams ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*model.Team{}
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	return teams, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteTeam(ctx context.Context, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM teams WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateNotification stores a notification, assigning it the next ID
+func (s *SQLiteStore) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	query := `
+	INSERT INTO notifications (username, namespace, "group", key, version, op_type, actor, created_at, is_read)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	n := notification
+	res, err := s.db.ExecContext(ctx, query, n.Username, n.Namespace, n.Group, n.Key, n.Version, n.OpType, n.Actor, n.CreatedAt, n.Read)
+	if err != nil {
+		return err
+	}
+	n.ID, err = res.LastInsertId()
+	return err
+}
+
+// ListNotifications returns up to limit notifications of a user, newest first
+func (s *SQLiteStore) ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	query := `SELECT id, username, namespace, "group", key, version, op_type, actor, created_at, is_read FROM notifications WHERE username = ? AND (NOT ? OR NOT is_read) ORDER BY id DESC`
+	args := []any{username, unreadOnly}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(&n.ID, &n.Username, &n.Namespace, &n.Group, &n.Key, &n.Version, &n.OpType, &n.Actor, &n.CreatedAt, &n.Read); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marks the notifications of a user up to and including upToID as read
+func (s *SQLiteStore) MarkNotificationsRead(ctx context.Context, username string, upToID int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE notifications SET is_read = TRUE WHERE username = ? AND id <= ?`, username, upToID)
+	return err
+}
+
+func (s *SQLiteStore) DeleteNotifications(ctx context.Context, username string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM notifications WHERE username = ?`, username)
+	return err
+}
+
+// SetVariant creates or replaces a variant. Its selector is stored as a
+// JSON object.
+func (s *SQLiteStore) SetVariant(ctx context.Context, variant *model.ConfigVariant) error {
+	selector, err := json.Marshal(variant.Selector)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO config_variants (namespace, "group", key, name, selector, value, updated_by, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(namespace, "group", key, name) DO UPDATE SET
+		selector = excluded.selector,
+		value = excluded.value,
+		updated_by = excluded.updated_by,
+		updated_at = excluded.updated_at;
+	`
+	v := variant
+	_, err = s.db.ExecContext(ctx, query, v.Namespace, v.Group, v.Key, v.Name, string(selector), v.Value, v.UpdatedBy, v.UpdatedAt)
+	return err
+}
+
+// ListVariants returns the variants of a key, group or namespace ordered by
+// group, key and name
+func (s *SQLiteStore) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	query := `
+	SELECT namespace, "group", key, name, selector, value, updated_by, updated_at FROM config_variants
+	WHERE namespace = ? AND (? = '' OR "group" = ?) AND (? = '' OR key = ?)
+	ORDER BY "group", key, name
+	`
+	rows, err := s.db.QueryContext(ctx, query, namespace, group, group, key, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []*model.ConfigVariant{}
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM config_variants WHERE namespace = ? AND "group" = ? AND key = ? AND name = ?`, namespace, group, key, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordAccess adds the access count of access to the key's and replaces
+// its last access
+func (s *SQLiteStore) RecordAccess(ctx context.Context, access *model.ConfigAccess) error {
+	query := `
+	INSERT INTO config_access (namespace, "group", key, access_count, last_accessed, last_client_id, last_username, last_ip)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(namespace, "group", key) DO UPDATE SET
+		access_count = config_access.access_count + excluded.access_count,
+		last_accessed = excluded.last_accessed,
+		last_client_id = excluded.last_client_id,
+		last_username = excluded.last_username,
+		last_ip = excluded.last_ip;
+	`
+	a := access
+	_, err := s.db.ExecContext(ctx, query, a.Namespace, a.Group, a.Key, a.AccessCount, a.LastAccessed.UTC(), a.LastClientID, a.LastUsername, a.LastIP)
+	return err
+}
+
+// ListAccess returns the access records of a key, group or namespace
+// ordered by group and key
+func (s *SQLiteStore) ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error) {
+	query := `
+	SELECT namespace, "group", key, access_count, last_accessed, last_client_id, last_username, last_ip FROM config_access
+	WHERE namespace = ? AND (? = '' OR "group" = ?) AND (? = '' OR key = ?)
+	ORDER BY "group", key
+	`
+	rows, err := s.db.QueryContext(ctx, query, namespace, group, group, key, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*model.ConfigAccess{}
+	for rows.Next() {
+		access, err := scanAccess(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, access)
+	}
+	return records, rows.Err()
+}
+
+// CreateScheduledChange stores a scheduled change, assigning it the next ID
+func (s *SQLiteStore) CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	query := `
+	INSERT INTO scheduled_changes (namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	c := change
+	res, err := s.db.ExecContext(ctx, query, c.Namespace, c.Group, c.Key, c.Value, c.Type, c.ContentType, c.PublishAt.UTC(), c.Status, c.Error, c.CreatedBy, c.CreatedAt, c.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	c.ID, err = res.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at FROM scheduled_changes WHERE id = ?`
+	change, err := scanScheduledChange(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return change, err
+}
+
+// ListScheduledChanges returns the scheduled changes in a namespace, soonest first
+func (s *SQLiteStore) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at FROM scheduled_changes WHERE namespace = ? ORDER BY publish_at, id`
+	return s.queryScheduledChanges(ctx, query, namespace)
+}
+
+// ListDueScheduledChanges returns the pending changes due at now, soonest
+// first. Publish times are stored in UTC so they compare as text.
+func (s *SQLiteStore) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error) {
+	query := `SELECT id, namespace, "group", key, value, type, content_type, publish_at, status, error, created_by, created_at, updated_at FROM scheduled_changes WHERE status = ? AND publish_at <= ? ORDER BY publish_at, id`
+	return s.queryScheduledChanges(ctx, query, model.ScheduleStatusPending, now.UTC())
+}
+
+func (s *SQLiteStore) queryScheduledChanges(ctx context.Context, query string, args ...any) ([]*model.ScheduledChange, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*model.ScheduledChange{}
+	for rows.Next() {
+		change, err := scanScheduledChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	query := `
+	UPDATE scheduled_changes
+	SET value = ?, type = ?, content_type = ?, publish_at = ?, status = ?, error = ?, updated_at = ?
+	WHERE id = ?
+	`
+	c := change
+	res, err := s.db.ExecContext(ctx, query, c.Value, c.Type, c.ContentType, c.PublishAt.UTC(), c.Status, c.Error, c.UpdatedAt, c.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimScheduledChange moves a pending change to publishing
+func (s *SQLiteStore) ClaimScheduledChange(ctx context.Context, id int64) error {
+	query := `UPDATE scheduled_changes SET status = ?, updated_at = ? WHERE id = ? AND status = ?`
+	res, err := s.db.ExecContext(ctx, query, model.ScheduleStatusPublishing, time.Now(), id, model.ScheduleStatusPending)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteScheduledChange(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM scheduled_changes WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateWebhook stores a webhook, assigning it the next ID
+func (s *SQLiteStore) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	query := `INSERT INTO webhooks (namespace, url, secret, created_by, created_at) VALUES (?, ?, ?, ?, ?)`
+	res, err := s.db.ExecContext(ctx, query, hook.Namespace, hook.URL, hook.Secret, hook.CreatedBy, hook.CreatedAt)
+	if err != nil {
+		return err
+	}
+	hook.ID, err = res.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	query := `SELECT id, namespace, url, secret, created_by, created_at FROM webhooks WHERE id = ?`
+	hook, err := scanWebhook(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return hook, err
+}
+
+// ListWebhooks returns the webhooks of a namespace ordered by ID
+func (s *SQLiteStore) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	query := `SELECT id, namespace, url, secret, created_by, created_at FROM webhooks WHERE namespace = ? ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*model.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook deletes a webhook and its deliveries
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id)
+	return err
+}
+
+// CreateWebhookDelivery stores a delivery, assigning it the next ID
+func (s *SQLiteStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	d := delivery
+	res, err := s.db.ExecContext(ctx, query, d.WebhookID, d.Namespace, d.Event, d.Payload, d.Status, d.Attempts, d.NextAttemptAt.UTC(), d.LastError, d.ResponseStatus, d.CreatedAt, d.UpdatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	d.ID, err = res.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at FROM webhook_deliveries WHERE id = ?`
+	delivery, err := scanWebhookDelivery(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return delivery, err
+}
+
+// ListWebhookDeliveries returns up to limit deliveries of a webhook with
+// status, or with any status if it is empty, newest first
+func (s *SQLiteStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at FROM webhook_deliveries WHERE webhook_id = ? AND (? = '' OR status = ?) ORDER BY id DESC LIMIT ?`
+	return s.queryWebhookDeliveries(ctx, query, webhookID, status, status, limit)
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries due at
+// now, oldest first. Attempt times are stored in UTC so they compare as text.
+func (s *SQLiteStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, namespace, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, id LIMIT ?`
+	return s.queryWebhookDeliveries(ctx, query, model.DeliveryStatusPending, now.UTC(), limit)
+}
+
+func (s *SQLiteStore) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]*model.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, response_status = ?, updated_at = ?
+	WHERE id = ?
+	`
+	d := delivery
+	res, err := s.db.ExecContext(ctx, query, d.Status, d.Attempts, d.NextAttemptAt.UTC(), d.LastError, d.ResponseStatus, d.UpdatedAt.UTC(), d.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimWebhookDelivery postpones a pending delivery due at now to until
+func (s *SQLiteStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	query := `UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?`
+	res, err := s.db.ExecContext(ctx, query, until.UTC(), id, model.DeliveryStatusPending, now.UTC())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries deletes the deliveries delivered before
+func (s *SQLiteStore) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	query := `DELETE FROM webhook_deliveries WHERE status = ? AND updated_at < ?`
+	_, err := s.db.ExecContext(ctx, query, model.DeliveryStatusDelivered, before.UTC())
+	return err
+}
+
+// NamespaceUsage returns the number of configs and total value bytes stored in a namespace
+func (s *SQLiteStore) NamespaceUsage(ctx context.Context, namespace string) (int64, int64, error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(LENGTH(CAST(value AS BLOB))), 0) FROM configs WHERE namespace = ?`
+	var configs, totalBytes int64
+	if err := s.db.QueryRowContext(ctx, query, namespace).Scan(&configs, &totalBytes); err != nil {
+		return 0, 0, err
+	}
+	return configs, totalBytes, nil
+}
+
+// CreateSession records a new login session
+func (s *SQLiteStore) CreateSession(ctx context.Context, session *model.Session) error {
+	query := `INSERT INTO sessions (id, username, ip, user_agent, created_at, refreshed_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.db.ExecContext(ctx, query, session.ID, session.Username, session.IP, session.UserAgent, session.CreatedAt, session.RefreshedAt, session.ExpiresAt)
+	return err
+}
+
+// GetSession returns a session by ID
+func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	query := `SELECT id, username, ip, user_agent, created_at, refreshed_at, expires_at FROM sessions WHERE id = ?`
+	var session model.Session
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&session.ID, &session.Username, &session.IP, &session.UserAgent, &session.CreatedAt, &session.RefreshedAt, &session.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListSessions returns the unexpired sessions of a user, newest first
+func (s *SQLiteStore) ListSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	query := `SELECT id, username, ip, user_agent, created_at, refreshed_at, expires_at FROM sessions WHERE username = ? AND expires_at > ? ORDER BY created_at DESC`
+	rows, err := s.db.QueryContext(ctx, query, username, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		var session model.Session
+		if err := rows.Scan(&session.ID, &session.Username, &session.IP, &session.UserAgent, &session.CreatedAt, &session.RefreshedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+// UpdateSession replaces a stored session
+func (s *SQLiteStore) UpdateSession(ctx context.Context, session *model.Session) error {
+	query := `UPDATE sessions SET ip = ?, user_agent = ?, refreshed_at = ?, expires_at = ? WHERE id = ?`
+	res, err := s.db.ExecContext(ctx, query, session.IP, session.UserAgent, session.RefreshedAt, session.ExpiresAt, session.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteSession removes a session
+func (s *SQLiteStore) DeleteSession(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+// AddTokenToBlacklist adds a token to the blacklist
+func (s *SQLiteStore) AddTokenToBlacklist(ctx context.Context, token string, expiresAt time.Time) error {
+	query := `INSERT INTO token_blacklist (token, expires_at) VALUES (?, ?) ON CONFLICT (token) DO UPDATE SET expires_at = excluded.expires_at`
+	_, err := s.db.ExecContext(ctx, query, token, expiresAt)
+	return err
+}
+
+// IsTokenBlacklisted checks if a token is blacklisted
+func (s *SQLiteStore) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
+	query := `SELECT COUNT(*) FROM token_blacklist WHERE token = ? AND expires_at > ?`
+	var n int
+	if err := s.db.QueryRowContext(ctx, query, token, time.Now()).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CleanupExpiredTokens removes expired tokens from the blacklist and expired sessions
+func (s *SQLiteStore) CleanupExpiredTokens(ctx context.Context) error {
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM token_blacklist WHERE expires_at <= ?`, now); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, now)
+	return err
+}
+
+// IncrementTokenUsage increments the token usage count
+func (s *SQLiteStore) IncrementTokenUsage(ctx context.Context, token string) (int64, error) {
+	// For simplicity, we'll use a simple implementation that returns 1
+	// In a real implementation, you would track this in a database table
+	return 1, nil
+}
+
+// CheckTokenRateLimit checks if a token has exceeded the rate limit
+func (s *SQLiteStore) CheckTokenRateLimit(ctx context.Context, token string, limit int64, duration time.Duration) (bool, error) {
+	// For simplicity, we'll use a simple implementation that returns true
+	// In a real implementation, you would check this against a database table
+	return true, nil
+}
+
+// ResetTokenUsage resets the token usage count
+func (s *SQLiteStore) ResetTokenUsage(ctx context.Context, token string) error {
+	// For simplicity, we'll use a simple implementation that returns nil
+	// In a real implementation, you would reset this in a database table
+	return nil
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTeam reads a team row of the SQL stores, decoding its members
+func scanTeam(row rowScanner) (*model.Team, error) {
+	var t model.Team
+	var members string
+	if err := row.Scan(&t.Name, &members, &t.UpdatedBy, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(members), &t.Members); err != nil {
+		return nil, fmt.Errorf("decode members of team %s: %w", t.Name, err)
+	}
+	return &t, nil
+}
+
+// scanVariant reads a variant row of the SQL stores, decoding its selector
+func scanVariant(row rowScanner) (*model.ConfigVariant, error) {
+	var v model.ConfigVariant
+	var selector string
+	if err := row.Scan(&v.Namespace, &v.Group, &v.Key, &v.Name, &selector, &v.Value, &v.UpdatedBy, &v.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(selector), &v.Selector); err != nil {
+		return nil, fmt.Errorf("decode selector of variant %s: %w", v.Name, err)
+	}
+	return &v, nil
+}
+
+// scanAccess reads an access record row of the SQL stores
+func scanAccess(row rowScanner) (*model.ConfigAccess, error) {
+	var a model.ConfigAccess
+	var lastAccessed time.Time
+	if err := row.Scan(&a.Namespace, &a.Group, &a.Key, &a.AccessCount, &lastAccessed, &a.LastClientID, &a.LastUsername, &a.LastIP); err != nil {
+		return nil, err
+	}
+	a.LastAccessed = &lastAccessed
+	return &a, nil
+}
+
+// scanWebhook reads a webhook row of the SQL stores
+func scanWebhook(row rowScanner) (*model.Webhook, error) {
+	var h model.Webhook
+	if err := row.Scan(&h.ID, &h.Namespace, &h.URL, &h.Secret, &h.CreatedBy, &h.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// scanWebhookDelivery reads a webhook delivery row of the SQL stores
+func scanWebhookDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Namespace, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.ResponseStatus, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// scanScheduledChange reads a scheduled change row of the SQL stores
+func scanScheduledChange(row rowScanner) (*model.ScheduledChange, error) {
+	var c model.ScheduledChange
+	if err := row.Scan(&c.ID, &c.Namespace, &c.Group, &c.Key, &c.Value, &c.Type, &c.ContentType, &c.PublishAt, &c.Status, &c.Error, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
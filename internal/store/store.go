@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"reflect"
 	"time"
 
 	"github.com/sotowang/otter/internal/model"
@@ -13,21 +15,126 @@ var (
 	ErrRateLimited = errors.New("rate limit exceeded")
 )
 
-// Store defines the interface for configuration storage.
+// Store defines the interface for configuration storage. Backends
+// implement all of it; code needing only part of it can take one of the
+// interfaces it is composed of.
 type Store interface {
+	ConfigStore
+	UserStore
+	TokenStore
+}
+
+// storeType is the Store interface, for code going through its methods
+var storeType = reflect.TypeOf((*Store)(nil)).Elem()
+
+// ConfigStore stores configs and everything attached to their namespaces,
+// groups and keys
+type ConfigStore interface {
 	Get(ctx context.Context, namespace, group, key string) (*model.Config, error)
+	// Put creates or updates a config, assigning it the next version of its key
 	Put(ctx context.Context, config *model.Config) error
 	Delete(ctx context.Context, namespace, group, key string) error
 	List(ctx context.Context, namespace, group string) ([]*model.Config, error)
+	// ListNamespaceConfigs returns every config in a namespace, across all groups
+	ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error)
+	// NextVersion allocates the next version of a key without writing a config,
+	// for history records of deletes. Versions of a key increase by one with
+	// every allocation and are never reused, even after the key is deleted.
+	NextVersion(ctx context.Context, namespace, group, key string) (int64, error)
 
 	// Namespace methods
 	ListNamespaces(ctx context.Context) ([]string, error)
 	CreateNamespace(ctx context.Context, namespace string) error
 	DeleteNamespace(ctx context.Context, namespace string) error
 
+	// Quota methods
+	GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error)
+	SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error
+	NamespaceUsage(ctx context.Context, namespace string) (configs int64, totalBytes int64, err error)
+
+	// Lock methods. A lock with an empty group and key freezes its namespace.
+	// SetLock creates or replaces a lock
+	SetLock(ctx context.Context, lock *model.ConfigLock) error
+	GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error)
+	// ListLocks returns the locks in a namespace, the namespace lock first
+	ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error)
+	DeleteLock(ctx context.Context, namespace, group, key string) error
+
+	// Ownership methods. An owner with an empty key owns its whole group.
+	// SetOwner creates or replaces an owner
+	SetOwner(ctx context.Context, owner *model.ConfigOwner) error
+	GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error)
+	// ListOwners returns the owners in a namespace ordered by group and key
+	ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error)
+	DeleteOwner(ctx context.Context, namespace, group, key string) error
+
+	// Variant methods. ListVariants returns the variants of a key ordered by
+	// name, of every key in a group if key is empty, or of every key in the
+	// namespace if group is empty too
+	SetVariant(ctx context.Context, variant *model.ConfigVariant) error
+	ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error)
+	DeleteVariant(ctx context.Context, namespace, group, key, name string) error
+
+	// Access methods. RecordAccess adds the access count of access to the
+	// key's and replaces its last access. ListAccess returns the access
+	// records of a key, group or namespace like ListVariants, ordered by
+	// group and key.
+	RecordAccess(ctx context.Context, access *model.ConfigAccess) error
+	ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error)
+
+	// Scheduled change methods. CreateScheduledChange assigns the change its ID
+	CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error
+	GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error)
+	// ListScheduledChanges returns the scheduled changes in a namespace, soonest first
+	ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error)
+	// ListDueScheduledChanges returns the pending changes of every namespace due at now, soonest first
+	ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error)
+	UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error
+	// ClaimScheduledChange moves a pending change to publishing, returning
+	// ErrNotFound if it is no longer pending, so only one server publishes it
+	ClaimScheduledChange(ctx context.Context, id int64) error
+	DeleteScheduledChange(ctx context.Context, id int64) error
+
+	// Webhook methods. CreateWebhook assigns the webhook its ID and
+	// DeleteWebhook deletes its deliveries too
+	CreateWebhook(ctx context.Context, hook *model.Webhook) error
+	GetWebhook(ctx context.Context, id int64) (*model.Webhook, error)
+	// ListWebhooks returns the webhooks of a namespace ordered by ID
+	ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+
+	// Webhook delivery methods. CreateWebhookDelivery assigns the delivery its ID
+	CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error)
+	// ListWebhookDeliveries returns up to limit deliveries of a webhook with
+	// status, or with any status if it is empty, newest first
+	ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error)
+	// ListDueWebhookDeliveries returns up to limit pending deliveries of every namespace due at now, oldest first
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	// ClaimWebhookDelivery postpones a pending delivery due at now to until,
+	// returning ErrNotFound if it is no longer due, so only one server
+	// attempts it and another retries it if that server stops midway
+	ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error
+	// PruneWebhookDeliveries deletes the deliveries delivered before
+	PruneWebhookDeliveries(ctx context.Context, before time.Time) error
+
 	// History methods
 	CreateHistory(ctx context.Context, history *model.ConfigHistory) error
 	ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error)
+	// ListNamespaceHistory returns up to limit changes in a namespace made at or after since, newest first
+	ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error)
+	// ListNamespaceEvents returns up to limit changes in a namespace with an ID greater than afterID, oldest first
+	ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error)
+}
+
+// UserStore stores tenants, users and what belongs to them
+type UserStore interface {
+	// Tenant methods
+	CreateTenant(ctx context.Context, tenant *model.Tenant) error
+	GetTenant(ctx context.Context, name string) (*model.Tenant, error)
+	ListTenants(ctx context.Context) ([]*model.Tenant, error)
+	DeleteTenant(ctx context.Context, name string) error
 
 	// User methods
 	CreateUser(ctx context.Context, user *model.User) error
@@ -36,9 +143,36 @@ type Store interface {
 	UpdateUser(ctx context.Context, user *model.User) error
 	DeleteUser(ctx context.Context, username string) error
 
+	// Session methods
+	CreateSession(ctx context.Context, session *model.Session) error
+	GetSession(ctx context.Context, id string) (*model.Session, error)
+	// ListSessions returns the unexpired sessions of a user, newest first
+	ListSessions(ctx context.Context, username string) ([]*model.Session, error)
+	UpdateSession(ctx context.Context, session *model.Session) error
+	DeleteSession(ctx context.Context, id string) error
+
+	// Team methods. SetTeam creates or replaces a team
+	SetTeam(ctx context.Context, team *model.Team) error
+	GetTeam(ctx context.Context, name string) (*model.Team, error)
+	ListTeams(ctx context.Context) ([]*model.Team, error)
+	DeleteTeam(ctx context.Context, name string) error
+
+	// Notification methods. CreateNotification assigns the notification its ID
+	CreateNotification(ctx context.Context, notification *model.Notification) error
+	// ListNotifications returns up to limit notifications of a user, newest first
+	ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error)
+	// MarkNotificationsRead marks the notifications of a user up to and including upToID as read
+	MarkNotificationsRead(ctx context.Context, username string, upToID int64) error
+	DeleteNotifications(ctx context.Context, username string) error
+}
+
+// TokenStore stores the blacklist of revoked tokens and the usage counted
+// for rate limiting
+type TokenStore interface {
 	// Token methods for security
 	AddTokenToBlacklist(ctx context.Context, token string, expiresAt time.Time) error
 	IsTokenBlacklisted(ctx context.Context, token string) (bool, error)
+	// CleanupExpiredTokens removes expired blacklist entries and sessions
 	CleanupExpiredTokens(ctx context.Context) error
 
 	// Rate limiting methods
@@ -46,3 +180,8 @@ type Store interface {
 	CheckTokenRateLimit(ctx context.Context, token string, limit int64, duration time.Duration) (bool, error)
 	ResetTokenUsage(ctx context.Context, token string) error
 }
+
+// rowQuerier is implemented by *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
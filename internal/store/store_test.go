@@ -0,0 +1,282 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// TestVersionSequence tests that every backend numbers the writes of a key
+// 1, 2, 3... without reusing versions after a delete or across concurrent writes
+func TestVersionSequence(t *testing.T) {
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "otter.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	for name, s := range map[string]Store{"memory": NewInMemoryStore(), "sqlite": sqlite, "grpc": newGRPCTestStore(t, NewInMemoryStore())} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			put := func(key string) int64 {
+				cfg := &model.Config{Namespace: "public", Group: "billing", Key: key, Value: "v", Type: "text", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+				if err := s.Put(ctx, cfg); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+				return cfg.Version
+			}
+
+			if v1, v2 := put("rate"), put("rate"); v1 != 1 || v2 != 2 {
+				t.Errorf("versions %d and %d, want 1 and 2", v1, v2)
+			}
+			if v := put("currency"); v != 1 {
+				t.Errorf("first version of another key is %d, want 1", v)
+			}
+
+			if err := s.Delete(ctx, "public", "billing", "rate"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if v, err := s.NextVersion(ctx, "public", "billing", "rate"); err != nil || v != 3 {
+				t.Errorf("NextVersion after delete = %d, %v, want 3", v, err)
+			}
+			if v := put("rate"); v != 4 {
+				t.Errorf("version after recreating is %d, want 4", v)
+			}
+
+			var wg sync.WaitGroup
+			seen := make(chan int64, 20)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					seen <- put("limit")
+				}()
+			}
+			wg.Wait()
+			close(seen)
+			unique := make(map[int64]bool)
+			for v := range seen {
+				unique[v] = true
+			}
+			cfg, err := s.Get(ctx, "public", "billing", "limit")
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if len(unique) != 20 || cfg.Version != 20 {
+				t.Errorf("concurrent writes got %d distinct versions ending at %d, want 20 and 20", len(unique), cfg.Version)
+			}
+		})
+	}
+}
+
+// TestSessions tests that every backend lists a user's unexpired sessions
+// newest first and blacklists keys until they expire
+func TestSessions(t *testing.T) {
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "otter.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	for name, s := range map[string]Store{"memory": NewInMemoryStore(), "sqlite": sqlite, "grpc": newGRPCTestStore(t, NewInMemoryStore())} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			for _, session := range []*model.Session{
+				{ID: "old", Username: "alice", CreatedAt: now.Add(-2 * time.Hour), RefreshedAt: now, ExpiresAt: now.Add(time.Hour)},
+				{ID: "new", Username: "alice", CreatedAt: now.Add(-time.Hour), RefreshedAt: now, ExpiresAt: now.Add(time.Hour)},
+				{ID: "expired", Username: "alice", CreatedAt: now, RefreshedAt: now, ExpiresAt: now.Add(-time.Minute)},
+				{ID: "other", Username: "bob", CreatedAt: now, RefreshedAt: now, ExpiresAt: now.Add(time.Hour)},
+			} {
+				if err := s.CreateSession(ctx, session); err != nil {
+					t.Fatalf("CreateSession failed: %v", err)
+				}
+			}
+
+			sessions, err := s.ListSessions(ctx, "alice")
+			if err != nil {
+				t.Fatalf("ListSessions failed: %v", err)
+			}
+			if len(sessions) != 2 || sessions[0].ID != "new" || sessions[1].ID != "old" {
+				t.Errorf("got %d sessions, want new and old", len(sessions))
+			}
+
+			if err := s.DeleteSession(ctx, "old"); err != nil {
+				t.Fatalf("DeleteSession failed: %v", err)
+			}
+			if _, err := s.GetSession(ctx, "old"); err != ErrNotFound {
+				t.Errorf("GetSession after delete = %v, want ErrNotFound", err)
+			}
+
+			s.AddTokenToBlacklist(ctx, "session:new", now.Add(time.Hour))
+			s.AddTokenToBlacklist(ctx, "session:gone", now.Add(-time.Minute))
+			if ok, err := s.IsTokenBlacklisted(ctx, "session:new"); err != nil || !ok {
+				t.Errorf("IsTokenBlacklisted(session:new) = %v, %v, want true", ok, err)
+			}
+			if ok, err := s.IsTokenBlacklisted(ctx, "session:gone"); err != nil || ok {
+				t.Errorf("IsTokenBlacklisted(session:gone) = %v, %v, want false after expiry", ok, err)
+			}
+		})
+	}
+}
+
+// TestWebhookDeliveries tests that every backend lists due deliveries
+// oldest first, lets only one claim of a due delivery succeed, and deletes
+// deliveries with their webhook
+func TestWebhookDeliveries(t *testing.T) {
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "otter.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	for name, s := range map[string]Store{"memory": NewInMemoryStore(), "sqlite": sqlite, "grpc": newGRPCTestStore(t, NewInMemoryStore())} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			hook := &model.Webhook{Namespace: "public", URL: "http://example.com/hook", Secret: "s", CreatedBy: "admin", CreatedAt: now}
+			if err := s.CreateWebhook(ctx, hook); err != nil {
+				t.Fatalf("CreateWebhook failed: %v", err)
+			}
+			var ids []int64
+			for _, at := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(time.Minute)} {
+				delivery := &model.WebhookDelivery{WebhookID: hook.ID, Namespace: "public", Event: "UPDATE", Payload: "{}", Status: model.DeliveryStatusPending, NextAttemptAt: at, CreatedAt: now, UpdatedAt: now}
+				if err := s.CreateWebhookDelivery(ctx, delivery); err != nil {
+					t.Fatalf("CreateWebhookDelivery failed: %v", err)
+				}
+				ids = append(ids, delivery.ID)
+			}
+
+			due, err := s.ListDueWebhookDeliveries(ctx, now, 10)
+			if err != nil {
+				t.Fatalf("ListDueWebhookDeliveries failed: %v", err)
+			}
+			if len(due) != 2 || due[0].ID != ids[1] || due[1].ID != ids[0] {
+				t.Fatalf("got %d due deliveries, want the two due ones oldest first", len(due))
+			}
+
+			if err := s.ClaimWebhookDelivery(ctx, ids[0], now, now.Add(time.Minute)); err != nil {
+				t.Fatalf("ClaimWebhookDelivery failed: %v", err)
+			}
+			if err := s.ClaimWebhookDelivery(ctx, ids[0], now, now.Add(time.Minute)); err != ErrNotFound {
+				t.Errorf("second claim = %v, want ErrNotFound", err)
+			}
+			if due, _ := s.ListDueWebhookDeliveries(ctx, now, 10); len(due) != 1 {
+				t.Errorf("got %d due deliveries after a claim, want 1", len(due))
+			}
+
+			delivered := due[0]
+			delivered.Status = model.DeliveryStatusDelivered
+			delivered.Attempts = 1
+			delivered.UpdatedAt = now.Add(-time.Hour)
+			if err := s.UpdateWebhookDelivery(ctx, delivered); err != nil {
+				t.Fatalf("UpdateWebhookDelivery failed: %v", err)
+			}
+			if list, err := s.ListWebhookDeliveries(ctx, hook.ID, model.DeliveryStatusDelivered, 10); err != nil || len(list) != 1 || list[0].Attempts != 1 {
+				t.Errorf("ListWebhookDeliveries(delivered) = %d deliveries, %v, want the updated one", len(list), err)
+			}
+			if err := s.PruneWebhookDeliveries(ctx, now); err != nil {
+				t.Fatalf("PruneWebhookDeliveries failed: %v", err)
+			}
+			if list, _ := s.ListWebhookDeliveries(ctx, hook.ID, "", 10); len(list) != 2 || list[0].ID != ids[2] {
+				t.Errorf("got %d deliveries after pruning, want 2 newest first", len(list))
+			}
+
+			if err := s.DeleteWebhook(ctx, hook.ID); err != nil {
+				t.Fatalf("DeleteWebhook failed: %v", err)
+			}
+			if _, err := s.GetWebhookDelivery(ctx, ids[2]); err != ErrNotFound {
+				t.Errorf("GetWebhookDelivery after deleting the webhook = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+// TestNamespaceQuotas tests that every backend refuses a quota for a missing
+// namespace and drops the quota of a deleted one
+func TestNamespaceQuotas(t *testing.T) {
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "otter.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	for name, s := range map[string]Store{"memory": NewInMemoryStore(), "sqlite": sqlite, "grpc": newGRPCTestStore(t, NewInMemoryStore())} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			quota := &model.NamespaceQuota{Namespace: "billing", MaxConfigs: 10, UpdatedBy: "admin", UpdatedAt: time.Now()}
+			if err := s.SetNamespaceQuota(ctx, quota); err != ErrNotFound {
+				t.Errorf("SetNamespaceQuota of a missing namespace = %v, want ErrNotFound", err)
+			}
+
+			if err := s.CreateNamespace(ctx, "billing"); err != nil {
+				t.Fatalf("CreateNamespace failed: %v", err)
+			}
+			if err := s.SetNamespaceQuota(ctx, quota); err != nil {
+				t.Fatalf("SetNamespaceQuota failed: %v", err)
+			}
+			if q, err := s.GetNamespaceQuota(ctx, "billing"); err != nil || q.MaxConfigs != 10 {
+				t.Fatalf("GetNamespaceQuota = %+v, %v, want 10 configs", q, err)
+			}
+
+			if err := s.DeleteNamespace(ctx, "billing"); err != nil {
+				t.Fatalf("DeleteNamespace failed: %v", err)
+			}
+			if err := s.CreateNamespace(ctx, "billing"); err != nil {
+				t.Fatalf("CreateNamespace failed: %v", err)
+			}
+			if q, err := s.GetNamespaceQuota(ctx, "billing"); err != ErrNotFound {
+				t.Errorf("GetNamespaceQuota of a recreated namespace = %+v, %v, want ErrNotFound", q, err)
+			}
+		})
+	}
+}
+
+// BenchmarkStore measures getting, putting and listing configs of a group
+// of 100 on every local backend, as many calls in parallel as GOMAXPROCS
+func BenchmarkStore(b *testing.B) {
+	sqlite, err := NewSQLiteStore(filepath.Join(b.TempDir(), "otter.db"))
+	if err != nil {
+		b.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	ctx := context.Background()
+	for name, s := range map[string]Store{"memory": NewInMemoryStore(), "sqlite": sqlite} {
+		put := func(i int) error {
+			now := time.Now()
+			return s.Put(ctx, &model.Config{Namespace: "public", Group: "bench", Key: fmt.Sprintf("k%d", i%100), Value: "v", Type: "text", CreatedAt: now, UpdatedAt: now})
+		}
+		for i := 0; i < 100; i++ {
+			if err := put(i); err != nil {
+				b.Fatalf("Put failed: %v", err)
+			}
+		}
+
+		b.Run(name+"/get", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if _, err := s.Get(ctx, "public", "bench", fmt.Sprintf("k%d", i%100)); err != nil {
+						b.Errorf("Get failed: %v", err)
+						return
+					}
+				}
+			})
+		})
+		b.Run(name+"/put", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					if err := put(i); err != nil {
+						b.Errorf("Put failed: %v", err)
+						return
+					}
+				}
+			})
+		})
+		b.Run(name+"/list", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := s.List(ctx, "public", "bench"); err != nil {
+						b.Errorf("List failed: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,742 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// tenantSeparator joins a tenant to the names of its namespaces and users
+// in the underlying store. Namespaces are URL path segments, and usernames
+// of new users may not contain it, so tenant names never clash with
+// others.
+const tenantSeparator = "/"
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose store queries a TenantStore limits to
+// tenant. An empty tenant leaves queries unrestricted.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant of ctx, empty if it has none
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantName returns the name a tenant's namespace or user is stored under
+func TenantName(tenant, name string) string {
+	if tenant == "" {
+		return name
+	}
+	return tenant + tenantSeparator + name
+}
+
+// SplitTenant splits a stored namespace or username into its tenant, empty
+// if it has none, and the name seen by the tenant
+func SplitTenant(stored string) (tenant, name string) {
+	if tenant, name, ok := strings.Cut(stored, tenantSeparator); ok {
+		return tenant, name
+	}
+	return "", stored
+}
+
+// TenantStore isolates tenants from each other in every query. Queries
+// made with a WithTenant context only see the namespaces, configs, history,
+// quotas, locks, owners, teams, users, sessions and notifications of that
+// tenant, under the names the tenant gave them. Other queries see the
+// store as is.
+type TenantStore struct {
+	Store
+}
+
+// NewTenantStore wraps st so each context's tenant only sees its own data
+func NewTenantStore(st Store) *TenantStore {
+	return &TenantStore{Store: st}
+}
+
+// stored returns the stored name of a tenant's namespace or user
+func (s *TenantStore) stored(ctx context.Context, name string) string {
+	return TenantName(TenantFrom(ctx), name)
+}
+
+// visible returns the name the context's tenant sees for a stored
+// namespace or username, and whether the tenant may see it at all. Without
+// a tenant, names of tenants' data are hidden from lists but otherwise
+// usable as is.
+func visible(tenant, stored string) (string, bool) {
+	owner, name := SplitTenant(stored)
+	if owner != tenant {
+		return "", false
+	}
+	return name, true
+}
+
+func (s *TenantStore) configOut(ctx context.Context, cfg *model.Config) *model.Config {
+	if TenantFrom(ctx) == "" {
+		return cfg
+	}
+	out := *cfg
+	_, out.Namespace = SplitTenant(cfg.Namespace)
+	return &out
+}
+
+func (s *TenantStore) configsOut(ctx context.Context, configs []*model.Config) []*model.Config {
+	if TenantFrom(ctx) == "" {
+		return configs
+	}
+	out := make([]*model.Config, len(configs))
+	for i, cfg := range configs {
+		out[i] = s.configOut(ctx, cfg)
+	}
+	return out
+}
+
+func (s *TenantStore) historyOut(ctx context.Context, history []*model.ConfigHistory) []*model.ConfigHistory {
+	if TenantFrom(ctx) == "" {
+		return history
+	}
+	out := make([]*model.ConfigHistory, len(history))
+	for i, h := range history {
+		copied := *h
+		_, copied.Namespace = SplitTenant(h.Namespace)
+		out[i] = &copied
+	}
+	return out
+}
+
+func (s *TenantStore) userOut(ctx context.Context, user *model.User) *model.User {
+	if TenantFrom(ctx) == "" {
+		return user
+	}
+	out := *user
+	_, out.Username = SplitTenant(user.Username)
+	return &out
+}
+
+func (s *TenantStore) sessionOut(ctx context.Context, session *model.Session) (*model.Session, bool) {
+	tenant := TenantFrom(ctx)
+	if tenant == "" {
+		return session, true
+	}
+	name, ok := visible(tenant, session.Username)
+	if !ok {
+		return nil, false
+	}
+	out := *session
+	out.Username = name
+	return &out, true
+}
+
+func (s *TenantStore) scheduledOut(ctx context.Context, change *model.ScheduledChange) (*model.ScheduledChange, bool) {
+	tenant := TenantFrom(ctx)
+	if tenant == "" {
+		return change, true
+	}
+	name, ok := visible(tenant, change.Namespace)
+	if !ok {
+		return nil, false
+	}
+	out := *change
+	out.Namespace = name
+	return &out, true
+}
+
+func (s *TenantStore) scheduledListOut(ctx context.Context, changes []*model.ScheduledChange) []*model.ScheduledChange {
+	out := make([]*model.ScheduledChange, 0, len(changes))
+	for _, change := range changes {
+		if visible, ok := s.scheduledOut(ctx, change); ok {
+			out = append(out, visible)
+		}
+	}
+	return out
+}
+
+func (s *TenantStore) webhookOut(ctx context.Context, hook *model.Webhook) (*model.Webhook, bool) {
+	tenant := TenantFrom(ctx)
+	if tenant == "" {
+		return hook, true
+	}
+	name, ok := visible(tenant, hook.Namespace)
+	if !ok {
+		return nil, false
+	}
+	out := *hook
+	out.Namespace = name
+	return &out, true
+}
+
+func (s *TenantStore) deliveryOut(ctx context.Context, delivery *model.WebhookDelivery) (*model.WebhookDelivery, bool) {
+	tenant := TenantFrom(ctx)
+	if tenant == "" {
+		return delivery, true
+	}
+	name, ok := visible(tenant, delivery.Namespace)
+	if !ok {
+		return nil, false
+	}
+	out := *delivery
+	out.Namespace = name
+	return &out, true
+}
+
+func (s *TenantStore) deliveryListOut(ctx context.Context, deliveries []*model.WebhookDelivery) []*model.WebhookDelivery {
+	out := make([]*model.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		if visible, ok := s.deliveryOut(ctx, delivery); ok {
+			out = append(out, visible)
+		}
+	}
+	return out
+}
+
+func (s *TenantStore) Get(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	cfg, err := s.Store.Get(ctx, s.stored(ctx, namespace), group, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.configOut(ctx, cfg), nil
+}
+
+func (s *TenantStore) Put(ctx context.Context, config *model.Config) error {
+	stored := *config
+	stored.Namespace = s.stored(ctx, config.Namespace)
+	if err := s.Store.Put(ctx, &stored); err != nil {
+		return err
+	}
+	// Pass back what the store filled in, such as the version, without
+	// touching the copy the store may keep
+	out := stored
+	out.Namespace = config.Namespace
+	*config = out
+	return nil
+}
+
+func (s *TenantStore) Delete(ctx context.Context, namespace, group, key string) error {
+	return s.Store.Delete(ctx, s.stored(ctx, namespace), group, key)
+}
+
+func (s *TenantStore) List(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	configs, err := s.Store.List(ctx, s.stored(ctx, namespace), group)
+	if err != nil {
+		return nil, err
+	}
+	return s.configsOut(ctx, configs), nil
+}
+
+func (s *TenantStore) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	configs, err := s.Store.ListNamespaceConfigs(ctx, s.stored(ctx, namespace))
+	if err != nil {
+		return nil, err
+	}
+	return s.configsOut(ctx, configs), nil
+}
+
+func (s *TenantStore) NextVersion(ctx context.Context, namespace, group, key string) (int64, error) {
+	return s.Store.NextVersion(ctx, s.stored(ctx, namespace), group, key)
+}
+
+func (s *TenantStore) ListNamespaces(ctx context.Context) ([]string, error) {
+	namespaces, err := s.Store.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenant := TenantFrom(ctx)
+	out := make([]string, 0, len(namespaces))
+	for _, stored := range namespaces {
+		if name, ok := visible(tenant, stored); ok {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+func (s *TenantStore) CreateNamespace(ctx context.Context, namespace string) error {
+	return s.Store.CreateNamespace(ctx, s.stored(ctx, namespace))
+}
+
+func (s *TenantStore) DeleteNamespace(ctx context.Context, namespace string) error {
+	return s.Store.DeleteNamespace(ctx, s.stored(ctx, namespace))
+}
+
+func (s *TenantStore) GetNamespaceQuota(ctx context.Context, namespace string) (*model.NamespaceQuota, error) {
+	quota, err := s.Store.GetNamespaceQuota(ctx, s.stored(ctx, namespace))
+	if err != nil || TenantFrom(ctx) == "" {
+		return quota, err
+	}
+	out := *quota
+	out.Namespace = namespace
+	return &out, nil
+}
+
+func (s *TenantStore) SetNamespaceQuota(ctx context.Context, quota *model.NamespaceQuota) error {
+	stored := *quota
+	stored.Namespace = s.stored(ctx, quota.Namespace)
+	return s.Store.SetNamespaceQuota(ctx, &stored)
+}
+
+func (s *TenantStore) SetLock(ctx context.Context, lock *model.ConfigLock) error {
+	stored := *lock
+	stored.Namespace = s.stored(ctx, lock.Namespace)
+	return s.Store.SetLock(ctx, &stored)
+}
+
+func (s *TenantStore) GetLock(ctx context.Context, namespace, group, key string) (*model.ConfigLock, error) {
+	lock, err := s.Store.GetLock(ctx, s.stored(ctx, namespace), group, key)
+	if err != nil || TenantFrom(ctx) == "" {
+		return lock, err
+	}
+	lock.Namespace = namespace
+	return lock, nil
+}
+
+func (s *TenantStore) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	locks, err := s.Store.ListLocks(ctx, s.stored(ctx, namespace))
+	if err != nil || TenantFrom(ctx) == "" {
+		return locks, err
+	}
+	for _, lock := range locks {
+		lock.Namespace = namespace
+	}
+	return locks, nil
+}
+
+func (s *TenantStore) DeleteLock(ctx context.Context, namespace, group, key string) error {
+	return s.Store.DeleteLock(ctx, s.stored(ctx, namespace), group, key)
+}
+
+func (s *TenantStore) SetOwner(ctx context.Context, owner *model.ConfigOwner) error {
+	stored := *owner
+	stored.Namespace = s.stored(ctx, owner.Namespace)
+	return s.Store.SetOwner(ctx, &stored)
+}
+
+func (s *TenantStore) GetOwner(ctx context.Context, namespace, group, key string) (*model.ConfigOwner, error) {
+	owner, err := s.Store.GetOwner(ctx, s.stored(ctx, namespace), group, key)
+	if err != nil || TenantFrom(ctx) == "" {
+		return owner, err
+	}
+	owner.Namespace = namespace
+	return owner, nil
+}
+
+func (s *TenantStore) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	owners, err := s.Store.ListOwners(ctx, s.stored(ctx, namespace))
+	if err != nil || TenantFrom(ctx) == "" {
+		return owners, err
+	}
+	for _, owner := range owners {
+		owner.Namespace = namespace
+	}
+	return owners, nil
+}
+
+func (s *TenantStore) DeleteOwner(ctx context.Context, namespace, group, key string) error {
+	return s.Store.DeleteOwner(ctx, s.stored(ctx, namespace), group, key)
+}
+
+func (s *TenantStore) SetTeam(ctx context.Context, team *model.Team) error {
+	stored := *team
+	stored.Name = s.stored(ctx, team.Name)
+	return s.Store.SetTeam(ctx, &stored)
+}
+
+func (s *TenantStore) GetTeam(ctx context.Context, name string) (*model.Team, error) {
+	team, err := s.Store.GetTeam(ctx, s.stored(ctx, name))
+	if err != nil {
+		return nil, err
+	}
+	team.Name = name
+	return team, nil
+}
+
+func (s *TenantStore) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	teams, err := s.Store.ListTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenant := TenantFrom(ctx)
+	out := make([]*model.Team, 0, len(teams))
+	for _, team := range teams {
+		if name, ok := visible(tenant, team.Name); ok {
+			team.Name = name
+			out = append(out, team)
+		}
+	}
+	return out, nil
+}
+
+func (s *TenantStore) DeleteTeam(ctx context.Context, name string) error {
+	return s.Store.DeleteTeam(ctx, s.stored(ctx, name))
+}
+
+func (s *TenantStore) CreateNotification(ctx context.Context, notification *model.Notification) error {
+	stored := *notification
+	stored.Username = s.stored(ctx, notification.Username)
+	stored.Namespace = s.stored(ctx, notification.Namespace)
+	if err := s.Store.CreateNotification(ctx, &stored); err != nil {
+		return err
+	}
+	notification.ID = stored.ID
+	return nil
+}
+
+func (s *TenantStore) ListNotifications(ctx context.Context, username string, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	notifications, err := s.Store.ListNotifications(ctx, s.stored(ctx, username), unreadOnly, limit)
+	if err != nil || TenantFrom(ctx) == "" {
+		return notifications, err
+	}
+	for _, n := range notifications {
+		n.Username = username
+		_, n.Namespace = SplitTenant(n.Namespace)
+	}
+	return notifications, nil
+}
+
+func (s *TenantStore) MarkNotificationsRead(ctx context.Context, username string, upToID int64) error {
+	return s.Store.MarkNotificationsRead(ctx, s.stored(ctx, username), upToID)
+}
+
+func (s *TenantStore) DeleteNotifications(ctx context.Context, username string) error {
+	return s.Store.DeleteNotifications(ctx, s.stored(ctx, username))
+}
+
+func (s *TenantStore) SetVariant(ctx context.Context, variant *model.ConfigVariant) error {
+	stored := *variant
+	stored.Namespace = s.stored(ctx, variant.Namespace)
+	return s.Store.SetVariant(ctx, &stored)
+}
+
+func (s *TenantStore) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	variants, err := s.Store.ListVariants(ctx, s.stored(ctx, namespace), group, key)
+	if err != nil || TenantFrom(ctx) == "" {
+		return variants, err
+	}
+	for _, variant := range variants {
+		variant.Namespace = namespace
+	}
+	return variants, nil
+}
+
+func (s *TenantStore) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	return s.Store.DeleteVariant(ctx, s.stored(ctx, namespace), group, key, name)
+}
+
+func (s *TenantStore) RecordAccess(ctx context.Context, access *model.ConfigAccess) error {
+	stored := *access
+	stored.Namespace = s.stored(ctx, access.Namespace)
+	return s.Store.RecordAccess(ctx, &stored)
+}
+
+func (s *TenantStore) ListAccess(ctx context.Context, namespace, group, key string) ([]*model.ConfigAccess, error) {
+	records, err := s.Store.ListAccess(ctx, s.stored(ctx, namespace), group, key)
+	if err != nil || TenantFrom(ctx) == "" {
+		return records, err
+	}
+	for _, access := range records {
+		access.Namespace = namespace
+	}
+	return records, nil
+}
+
+func (s *TenantStore) CreateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	stored := *change
+	stored.Namespace = s.stored(ctx, change.Namespace)
+	if err := s.Store.CreateScheduledChange(ctx, &stored); err != nil {
+		return err
+	}
+	change.ID = stored.ID
+	return nil
+}
+
+func (s *TenantStore) GetScheduledChange(ctx context.Context, id int64) (*model.ScheduledChange, error) {
+	change, err := s.Store.GetScheduledChange(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out, ok := s.scheduledOut(ctx, change)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+func (s *TenantStore) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	changes, err := s.Store.ListScheduledChanges(ctx, s.stored(ctx, namespace))
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduledListOut(ctx, changes), nil
+}
+
+// ListDueScheduledChanges only returns the due changes of the context's
+// tenant, or those of every tenant without one
+func (s *TenantStore) ListDueScheduledChanges(ctx context.Context, now time.Time) ([]*model.ScheduledChange, error) {
+	changes, err := s.Store.ListDueScheduledChanges(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduledListOut(ctx, changes), nil
+}
+
+// UpdateScheduledChange only updates changes of the context's tenant
+func (s *TenantStore) UpdateScheduledChange(ctx context.Context, change *model.ScheduledChange) error {
+	if _, err := s.GetScheduledChange(ctx, change.ID); err != nil {
+		return err
+	}
+	stored := *change
+	stored.Namespace = s.stored(ctx, change.Namespace)
+	return s.Store.UpdateScheduledChange(ctx, &stored)
+}
+
+// ClaimScheduledChange only claims changes of the context's tenant
+func (s *TenantStore) ClaimScheduledChange(ctx context.Context, id int64) error {
+	if _, err := s.GetScheduledChange(ctx, id); err != nil {
+		return err
+	}
+	return s.Store.ClaimScheduledChange(ctx, id)
+}
+
+// DeleteScheduledChange only deletes changes of the context's tenant
+func (s *TenantStore) DeleteScheduledChange(ctx context.Context, id int64) error {
+	if _, err := s.GetScheduledChange(ctx, id); err != nil {
+		return err
+	}
+	return s.Store.DeleteScheduledChange(ctx, id)
+}
+
+func (s *TenantStore) CreateWebhook(ctx context.Context, hook *model.Webhook) error {
+	stored := *hook
+	stored.Namespace = s.stored(ctx, hook.Namespace)
+	if err := s.Store.CreateWebhook(ctx, &stored); err != nil {
+		return err
+	}
+	hook.ID = stored.ID
+	return nil
+}
+
+func (s *TenantStore) GetWebhook(ctx context.Context, id int64) (*model.Webhook, error) {
+	hook, err := s.Store.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out, ok := s.webhookOut(ctx, hook)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+func (s *TenantStore) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	hooks, err := s.Store.ListWebhooks(ctx, s.stored(ctx, namespace))
+	if err != nil || TenantFrom(ctx) == "" {
+		return hooks, err
+	}
+	for _, hook := range hooks {
+		hook.Namespace = namespace
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook only deletes webhooks of the context's tenant
+func (s *TenantStore) DeleteWebhook(ctx context.Context, id int64) error {
+	if _, err := s.GetWebhook(ctx, id); err != nil {
+		return err
+	}
+	return s.Store.DeleteWebhook(ctx, id)
+}
+
+func (s *TenantStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	stored := *delivery
+	stored.Namespace = s.stored(ctx, delivery.Namespace)
+	if err := s.Store.CreateWebhookDelivery(ctx, &stored); err != nil {
+		return err
+	}
+	delivery.ID = stored.ID
+	return nil
+}
+
+func (s *TenantStore) GetWebhookDelivery(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	delivery, err := s.Store.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out, ok := s.deliveryOut(ctx, delivery)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// ListWebhookDeliveries only returns deliveries of the context's tenant
+func (s *TenantStore) ListWebhookDeliveries(ctx context.Context, webhookID int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries, err := s.Store.ListWebhookDeliveries(ctx, webhookID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.deliveryListOut(ctx, deliveries), nil
+}
+
+// ListDueWebhookDeliveries only returns the due deliveries of the context's
+// tenant, or those of every tenant without one
+func (s *TenantStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries, err := s.Store.ListDueWebhookDeliveries(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.deliveryListOut(ctx, deliveries), nil
+}
+
+// UpdateWebhookDelivery only updates deliveries of the context's tenant
+func (s *TenantStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	if _, err := s.GetWebhookDelivery(ctx, delivery.ID); err != nil {
+		return err
+	}
+	stored := *delivery
+	stored.Namespace = s.stored(ctx, delivery.Namespace)
+	return s.Store.UpdateWebhookDelivery(ctx, &stored)
+}
+
+// ClaimWebhookDelivery only claims deliveries of the context's tenant
+func (s *TenantStore) ClaimWebhookDelivery(ctx context.Context, id int64, now, until time.Time) error {
+	if _, err := s.GetWebhookDelivery(ctx, id); err != nil {
+		return err
+	}
+	return s.Store.ClaimWebhookDelivery(ctx, id, now, until)
+}
+
+func (s *TenantStore) NamespaceUsage(ctx context.Context, namespace string) (int64, int64, error) {
+	return s.Store.NamespaceUsage(ctx, s.stored(ctx, namespace))
+}
+
+func (s *TenantStore) CreateHistory(ctx context.Context, history *model.ConfigHistory) error {
+	stored := *history
+	stored.Namespace = s.stored(ctx, history.Namespace)
+	if err := s.Store.CreateHistory(ctx, &stored); err != nil {
+		return err
+	}
+	out := stored
+	out.Namespace = history.Namespace
+	*history = out
+	return nil
+}
+
+func (s *TenantStore) ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error) {
+	history, err := s.Store.ListHistory(ctx, s.stored(ctx, namespace), group, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.historyOut(ctx, history), nil
+}
+
+func (s *TenantStore) ListNamespaceHistory(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	history, err := s.Store.ListNamespaceHistory(ctx, s.stored(ctx, namespace), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.historyOut(ctx, history), nil
+}
+
+func (s *TenantStore) ListNamespaceEvents(ctx context.Context, namespace string, afterID int64, limit int) ([]*model.ConfigHistory, error) {
+	history, err := s.Store.ListNamespaceEvents(ctx, s.stored(ctx, namespace), afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.historyOut(ctx, history), nil
+}
+
+func (s *TenantStore) CreateUser(ctx context.Context, user *model.User) error {
+	stored := *user
+	stored.Username = s.stored(ctx, user.Username)
+	if err := s.Store.CreateUser(ctx, &stored); err != nil {
+		return err
+	}
+	out := stored
+	out.Username = user.Username
+	*user = out
+	return nil
+}
+
+func (s *TenantStore) GetUser(ctx context.Context, username string) (*model.User, error) {
+	user, err := s.Store.GetUser(ctx, s.stored(ctx, username))
+	if err != nil {
+		return nil, err
+	}
+	return s.userOut(ctx, user), nil
+}
+
+func (s *TenantStore) ListUsers(ctx context.Context) ([]*model.User, error) {
+	users, err := s.Store.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenant := TenantFrom(ctx)
+	out := make([]*model.User, 0, len(users))
+	for _, user := range users {
+		if _, ok := visible(tenant, user.Username); ok {
+			out = append(out, s.userOut(ctx, user))
+		}
+	}
+	return out, nil
+}
+
+func (s *TenantStore) UpdateUser(ctx context.Context, user *model.User) error {
+	stored := *user
+	stored.Username = s.stored(ctx, user.Username)
+	return s.Store.UpdateUser(ctx, &stored)
+}
+
+func (s *TenantStore) DeleteUser(ctx context.Context, username string) error {
+	return s.Store.DeleteUser(ctx, s.stored(ctx, username))
+}
+
+func (s *TenantStore) CreateSession(ctx context.Context, session *model.Session) error {
+	stored := *session
+	stored.Username = s.stored(ctx, session.Username)
+	return s.Store.CreateSession(ctx, &stored)
+}
+
+// GetSession only finds sessions of the context's tenant
+func (s *TenantStore) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	session, err := s.Store.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out, ok := s.sessionOut(ctx, session)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+func (s *TenantStore) ListSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	sessions, err := s.Store.ListSessions(ctx, s.stored(ctx, username))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*model.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if visible, ok := s.sessionOut(ctx, session); ok {
+			out = append(out, visible)
+		}
+	}
+	return out, nil
+}
+
+func (s *TenantStore) UpdateSession(ctx context.Context, session *model.Session) error {
+	stored := *session
+	stored.Username = s.stored(ctx, session.Username)
+	return s.Store.UpdateSession(ctx, &stored)
+}
+
+// DeleteSession only deletes sessions of the context's tenant
+func (s *TenantStore) DeleteSession(ctx context.Context, id string) error {
+	if _, err := s.GetSession(ctx, id); err != nil {
+		return err
+	}
+	return s.Store.DeleteSession(ctx, id)
+}
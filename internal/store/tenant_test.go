@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/sotowang/otter/internal/model"
+)
+
+// TestTenantStore tests that tenants using the same names see only their
+// own namespaces, configs and users, and that they are hidden from
+// listings outside any tenant
+func TestTenantStore(t *testing.T) {
+	s := NewTenantStore(NewInMemoryStore())
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	for i, ctx := range []context.Context{acme, globex} {
+		if err := s.CreateNamespace(ctx, "prod"); err != nil {
+			t.Fatalf("CreateNamespace failed: %v", err)
+		}
+		cfg := &model.Config{Namespace: "prod", Group: "billing", Key: "rate", Value: fmt.Sprint(i), Type: "text"}
+		if err := s.Put(ctx, cfg); err != nil || cfg.Namespace != "prod" || cfg.Version != 1 {
+			t.Fatalf("Put gave %+v, %v, want version 1 of prod", cfg, err)
+		}
+		if err := s.CreateUser(ctx, &model.User{Username: "alice", Role: "admin"}); err != nil {
+			t.Fatalf("CreateUser failed: %v", err)
+		}
+	}
+
+	cfg, err := s.Get(globex, "prod", "billing", "rate")
+	if err != nil || cfg.Namespace != "prod" || cfg.Value != "1" {
+		t.Errorf("globex got %+v, %v, want its own value", cfg, err)
+	}
+	if namespaces, _ := s.ListNamespaces(acme); fmt.Sprint(namespaces) != "[prod]" {
+		t.Errorf("acme namespaces = %v, want [prod]", namespaces)
+	}
+	if users, _ := s.ListUsers(acme); len(users) != 1 || users[0].Username != "alice" {
+		t.Errorf("acme users = %+v, want alice", users)
+	}
+
+	root := context.Background()
+	if namespaces, _ := s.ListNamespaces(root); fmt.Sprint(namespaces) != "[public]" {
+		t.Errorf("namespaces outside tenants = %v, want [public]", namespaces)
+	}
+	if _, err := s.Get(root, "prod", "billing", "rate"); err != ErrNotFound {
+		t.Errorf("Get outside tenants gave %v, want ErrNotFound", err)
+	}
+	if cfg, err := s.Get(root, "acme/prod", "billing", "rate"); err != nil || cfg.Value != "0" {
+		t.Errorf("stored config of acme = %+v, %v", cfg, err)
+	}
+}
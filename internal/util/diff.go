@@ -0,0 +1,210 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffOp is the kind of change a DiffLine represents
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffDelete
+	DiffInsert
+)
+
+// DiffLine is a single line of a line-based diff
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// SplitLines splits text into lines without their trailing newlines
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// DiffLines computes a minimal line diff between a and b using Myers' algorithm
+func DiffLines(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k+offset] holds the furthest x reached on diagonal k
+	offset := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[k-1+offset] < v[k+1+offset]) {
+				x = v[k+1+offset]
+			} else {
+				x = v[k-1+offset] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[k+offset] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script
+	var lines []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		vd := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && vd[k-1+offset] < vd[k+1+offset]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := vd[prevK+offset]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: b[y]})
+		} else {
+			x--
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: a[x]})
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// UnifiedDiff renders a unified diff between two texts with the given number
+// of context lines. It returns an empty string when the texts are identical.
+func UnifiedDiff(fromName, toName, a, b string, contextLines int) string {
+	lines := DiffLines(SplitLines(a), SplitLines(b))
+
+	changed := false
+	for _, l := range lines {
+		if l.Op != DiffEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	// aLine/bLine are the 1-based line numbers at each position of lines
+	aLine := make([]int, len(lines)+1)
+	bLine := make([]int, len(lines)+1)
+	aLine[0], bLine[0] = 1, 1
+	for i, l := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.Op != DiffInsert {
+			aLine[i+1]++
+		}
+		if l.Op != DiffDelete {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are within 2*contextLines of each other
+		start := i - contextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != DiffEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == DiffEqual {
+				run++
+			}
+			if run == len(lines) || run-end > 2*contextLines {
+				end += contextLines
+				if end > len(lines) {
+					end = len(lines)
+				}
+				break
+			}
+			end = run
+		}
+
+		aCount, bCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.Op != DiffInsert {
+				aCount++
+			}
+			if l.Op != DiffDelete {
+				bCount++
+			}
+		}
+		aStart, bStart := aLine[start], bLine[start]
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, l := range lines[start:end] {
+			switch l.Op {
+			case DiffEqual:
+				sb.WriteString(" ")
+			case DiffDelete:
+				sb.WriteString("-")
+			case DiffInsert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(l.Text)
+			sb.WriteString("\n")
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
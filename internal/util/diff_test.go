@@ -0,0 +1,44 @@
+package util
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "x\ny\n",
+			b:    "x\ny\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			a:    "a\nb\nc",
+			b:    "a\nB\nc",
+			want: "--- from\n+++ to\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "created",
+			a:    "",
+			b:    "x\ny",
+			want: "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11",
+			b:    "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12",
+			want: "--- from\n+++ to\n@@ -1,5 +1,5 @@\n 1\n-2\n+TWO\n 3\n 4\n 5\n@@ -9,3 +9,4 @@\n 9\n 10\n 11\n+12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("from", "to", tt.a, tt.b, 3); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// GetConfigAccess reports how often a config has been fetched or watched,
+// and when and by which client it last was
+func (c *Client) GetConfigAccess(ctx context.Context, namespace, group, key string) (*model.ConfigAccess, error) {
+	var access model.ConfigAccess
+	if err := c.doJSON(ctx, http.MethodGet, configPath(namespace, group, key, "access"), nil, &access, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
+// ListConfigAccess reports the accesses of every config in a namespace, or
+// in a group if group is not empty, ordered by group and key. Configs never
+// accessed have a count of zero.
+func (c *Client) ListConfigAccess(ctx context.Context, namespace, group string) ([]*model.ConfigAccess, error) {
+	path := "/api/v1/namespaces/" + namespace + "/access"
+	if group != "" {
+		path += "?" + url.Values{"group": {group}}.Encode()
+	}
+	var records []*model.ConfigAccess
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &records, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// StaleConfig is a config reported by StaleConfigs
+type StaleConfig struct {
+	Namespace    string     `json:"namespace"`
+	Group        string     `json:"group"`
+	Key          string     `json:"key"`
+	Version      int64      `json:"version"`
+	UpdatedBy    string     `json:"updated_by"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	AccessCount  int64      `json:"access_count"`
+	LastAccessed *time.Time `json:"last_accessed"`
+	NotAccessed  bool       `json:"not_accessed"`
+	NotModified  bool       `json:"not_modified"`
+}
+
+// StaleConfigs reports the configs not accessed or not modified in the last
+// days, in namespace or all namespaces if it is empty, oldest change first.
+// by is access or update to report only configs not accessed or only those
+// not modified, or empty for both. A zero days uses the server default of
+// 30. Admin only.
+func (c *Client) StaleConfigs(ctx context.Context, days int, namespace, by string) ([]*StaleConfig, error) {
+	query := url.Values{}
+	if days > 0 {
+		query.Set("days", strconv.Itoa(days))
+	}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	if by != "" {
+		query.Set("by", by)
+	}
+	path := "/api/v1/admin/stale-configs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var resp struct {
+		Configs []*StaleConfig `json:"configs"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp.Configs, nil
+}
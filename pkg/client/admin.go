@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// UserRequest holds the fields used to create or update a user. Role must be
+// admin, user or viewer (read-only) and Status active or inactive. An empty
+// Password leaves the current password unchanged on update.
+type UserRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Role     string `json:"role"`
+	Status   string `json:"status"`
+}
+
+// ListNamespaces lists all namespaces
+func (c *Client) ListNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/namespaces", nil, &namespaces, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// CreateNamespace creates a namespace
+func (c *Client) CreateNamespace(ctx context.Context, name string) error {
+	req := map[string]string{"name": name}
+	return c.doJSON(ctx, http.MethodPost, "/api/v1/namespaces", req, nil, http.StatusCreated)
+}
+
+// DeleteNamespace deletes a namespace
+func (c *Client) DeleteNamespace(ctx context.Context, name string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/namespaces/"+name, nil, nil, http.StatusNoContent)
+}
+
+// ListUsers lists all users
+func (c *Client) ListUsers(ctx context.Context) ([]*model.User, error) {
+	var users []*model.User
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/users", nil, &users, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// CreateUser creates a user
+func (c *Client) CreateUser(ctx context.Context, req UserRequest) (*model.User, error) {
+	var user model.User
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/users", req, &user, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser updates the role, status and optionally the password of a user
+func (c *Client) UpdateUser(ctx context.Context, username string, req UserRequest) (*model.User, error) {
+	req.Username = ""
+	var user model.User
+	if err := c.doJSON(ctx, http.MethodPut, "/api/v1/users/"+username, req, &user, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUser deletes a user. The server refuses to delete the last admin.
+func (c *Client) DeleteUser(ctx context.Context, username string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/users/"+username, nil, nil, http.StatusNoContent)
+}
+
+// ListSessions lists the active sessions of the logged-in user
+func (c *Client) ListSessions(ctx context.Context) ([]*model.Session, error) {
+	var sessions []*model.Session
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/sessions", nil, &sessions, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the logged-in user's sessions, invalidating
+// every token issued for it
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/sessions/"+id, nil, nil, http.StatusNoContent)
+}
+
+// ListUserSessions lists the active sessions of any user. Admin only.
+func (c *Client) ListUserSessions(ctx context.Context, username string) ([]*model.Session, error) {
+	var sessions []*model.Session
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/admin/users/"+username+"/sessions", nil, &sessions, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeUserSession revokes one session of any user. Admin only.
+func (c *Client) RevokeUserSession(ctx context.Context, username, id string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/admin/users/"+username+"/sessions/"+id, nil, nil, http.StatusNoContent)
+}
+
+// RevokeUserSessions revokes every session of a user and returns how many
+// were revoked. Admin only.
+func (c *Client) RevokeUserSessions(ctx context.Context, username string) (int, error) {
+	var res struct {
+		Revoked int `json:"revoked"`
+	}
+	if err := c.doJSON(ctx, http.MethodDelete, "/api/v1/admin/users/"+username+"/sessions", nil, &res, http.StatusOK); err != nil {
+		return 0, err
+	}
+	return res.Revoked, nil
+}
+
+// TokenScope is a namespace and group a scoped token may read and watch.
+// Group "*" covers the whole namespace.
+type TokenScope struct {
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+}
+
+// ScopedToken is a minted read-only token
+type ScopedToken struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Scopes    []TokenScope `json:"scopes"`
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+// CreateScopedToken mints a token for an application that may only read and
+// watch configs within scopes. A zero ttl uses the server default of 90 days.
+// Admin only.
+func (c *Client) CreateScopedToken(ctx context.Context, name string, scopes []TokenScope, ttl time.Duration) (*ScopedToken, error) {
+	req := map[string]any{"name": name, "scopes": scopes}
+	if ttl > 0 {
+		req["ttl"] = ttl.String()
+	}
+	var token ScopedToken
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/admin/tokens", req, &token, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeScopedToken revokes a scoped token by its ID. Admin only.
+func (c *Client) RevokeScopedToken(ctx context.Context, id string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/admin/tokens/"+id, nil, nil, http.StatusNoContent)
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy controls how the client retries failed requests. Unary GET
+// calls, and PUT and DELETE calls if RetryWrites is set, are retried on
+// transport errors, 429 and 5xx responses; the watch loop backs off the
+// same way between failed polls.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt of a unary
+	// call. Zero uses the default of 2, a negative value disables retries.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry (default 200ms)
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts (default 30s)
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each failed attempt (default 2)
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction in either
+	// direction. Zero uses the default of 0.2, a negative value disables it.
+	Jitter float64
+	// RetryWrites also retries PUT and DELETE calls. A PUT whose response
+	// was lost may then be applied twice, writing another version of the
+	// config; a DELETE answered 404 on a retry is taken as done.
+	RetryWrites bool
+	// OnRetry, if set, is called before the client waits to retry
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a retry about to happen
+type RetryEvent struct {
+	// Method and API path of the failed request
+	Method string
+	Path   string
+	// Attempt is the number of the upcoming retry, starting at 1
+	Attempt int
+	// Delay is how long the client waits before retrying
+	Delay time.Duration
+	// Err is the transport error, or nil if the server answered StatusCode
+	Err        error
+	StatusCode int
+}
+
+// withDefaults fills in zero fields of the policy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = 2
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 200 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.2
+	}
+	return p
+}
+
+// backoff returns the delay before retry number attempt, starting at 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// retryable reports whether a request that got resp or err is worth retrying
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// errAlreadyDeleted is returned by do when a retried DELETE finds the
+// resource gone, most likely because an earlier attempt removed it
+var errAlreadyDeleted = errors.New("already deleted")
+
+// retriesMethod reports whether the policy retries requests with method
+func (p RetryPolicy) retriesMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPut, http.MethodDelete:
+		return p.RetryWrites
+	}
+	return false
+}
+
+// retryWait notifies OnRetry and waits before retry number attempt,
+// reporting false if ctx was cancelled first
+func (c *Client) retryWait(ctx context.Context, method, path string, attempt int, resp *http.Response, err error) bool {
+	delay := c.config.Retry.backoff(attempt)
+	if c.config.Retry.OnRetry != nil {
+		event := RetryEvent{Method: method, Path: path, Attempt: attempt, Delay: delay, Err: err}
+		if resp != nil {
+			event.StatusCode = resp.StatusCode
+		}
+		c.config.Retry.OnRetry(event)
+	}
+	return sleepContext(ctx, delay)
+}
+
+// do sends a unary request, retrying it according to the client's
+// RetryPolicy
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, c.client, method, path, body)
+		if method == http.MethodDelete && attempt > 1 && err == nil && resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, errAlreadyDeleted
+		}
+		if ctx.Err() != nil || !retryable(resp, err) || !c.config.Retry.retriesMethod(method) || attempt > c.config.Retry.MaxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if !c.retryWait(ctx, method, path, attempt, resp, err) {
+			return nil, ctx.Err()
+		}
+	}
+}
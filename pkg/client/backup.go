@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RestoreResult counts what a restore wrote
+type RestoreResult struct {
+	Namespaces int `json:"namespaces"`
+	Configs    int `json:"configs"`
+	History    int `json:"history"`
+	Users      int `json:"users"`
+}
+
+// Backup downloads a full dump of the server as gzipped JSON, which Restore
+// accepts as is. It requires an admin.
+func (c *Client) Backup(ctx context.Context) ([]byte, error) {
+	startTime := time.Now()
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/admin/backup", nil)
+	if err != nil {
+		c.updateStats(startTime, false)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.updateStats(startTime, false)
+		var res struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: res.Error}
+	}
+	data, err := io.ReadAll(resp.Body)
+	c.updateStats(startTime, err == nil)
+	return data, err
+}
+
+// SaveBackup makes the server write a backup to its configured backup
+// target and returns the name of the backup
+func (c *Client) SaveBackup(ctx context.Context) (string, error) {
+	var res struct {
+		Name string `json:"name"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/admin/backup?save=true", nil, &res, http.StatusCreated); err != nil {
+		return "", err
+	}
+	return res.Name, nil
+}
+
+// Restore uploads a backup taken by Backup or saved by the server and
+// writes it into the server, merging it with any data already there
+func (c *Client) Restore(ctx context.Context, backup []byte) (*RestoreResult, error) {
+	var result RestoreResult
+	if err := c.doBody(ctx, http.MethodPost, "/api/v1/admin/restore", backup, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
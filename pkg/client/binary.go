@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// PutBinaryConfig stores data as a binary config, such as a certificate or
+// keystore. An empty contentType defaults to application/octet-stream on the
+// server, which rejects values larger than 1 MiB.
+func (c *Client) PutBinaryConfig(ctx context.Context, namespace, group, key string, data []byte, contentType string) (*model.Config, error) {
+	return c.putConfig(ctx, namespace, group, key, map[string]string{
+		"value":        base64.StdEncoding.EncodeToString(data),
+		"type":         "binary",
+		"content_type": contentType,
+	})
+}
+
+// GetBinaryConfig retrieves a binary config and returns its decoded bytes and
+// content type
+func (c *Client) GetBinaryConfig(ctx context.Context, namespace, group, key string) ([]byte, string, error) {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := decodeBinary(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, cfg.ContentType, nil
+}
+
+// decodeBinary decodes the base64 value of a binary config
+func decodeBinary(cfg *model.Config) ([]byte, error) {
+	if cfg.Type != "binary" {
+		return nil, fmt.Errorf("config %s/%s/%s has type %q, not binary", cfg.Namespace, cfg.Group, cfg.Key, cfg.Type)
+	}
+	return base64.StdEncoding.DecodeString(cfg.Value)
+}
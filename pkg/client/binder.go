@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// FieldChange describes one struct field whose value changed between two
+// bound values. Path is the dotted Go field path, e.g. "DB.Port".
+type FieldChange struct {
+	Path string
+	Old  any
+	New  any
+}
+
+// BindOptions configures a Binder
+type BindOptions[T any] struct {
+	// Validate, if set, vets every decoded value. Values that fail
+	// validation are rejected and the current value stays in place.
+	Validate func(*T) error
+	// OnChange, if set, is called after a new value has been swapped in,
+	// with the fields that differ from the previous value
+	OnChange func(old, new *T, changes []FieldChange)
+	// OnError, if set, is called when an update cannot be decoded or fails
+	// validation, and with every *WatchError of the underlying watch
+	OnError func(error)
+}
+
+// Binder keeps a struct in sync with a config key. Every change is decoded
+// into a fresh value, validated and swapped in atomically, so readers always
+// see a complete, valid value through Load.
+type Binder[T any] struct {
+	ConfigValue[T]
+	watch *Watch
+}
+
+// Bind decodes a config into a new T and keeps it in sync until ctx is
+// cancelled or the Binder is stopped. The initial value must decode and pass
+// validation.
+func Bind[T any](ctx context.Context, c *Client, namespace, group, key string, opts BindOptions[T]) (*Binder[T], error) {
+	initial := new(T)
+	if err := c.GetConfigInto(ctx, namespace, group, key, initial); err != nil {
+		return nil, err
+	}
+	if opts.Validate != nil {
+		if err := opts.Validate(initial); err != nil {
+			return nil, fmt.Errorf("validate %s/%s/%s: %w", namespace, group, key, err)
+		}
+	}
+
+	b := &Binder[T]{}
+	b.value.Store(initial)
+
+	fail := func(err error) {
+		c.logger.Errorf("Failed to bind %s/%s/%s: %v", namespace, group, key, err)
+		if opts.OnError != nil {
+			opts.OnError(err)
+		}
+	}
+
+	b.watch = c.WatchConfig(ctx, namespace, group, key, func(cfg *model.Config) {
+		if cfg.Version == -1 {
+			// Deleted: keep serving the last value
+			return
+		}
+		next := new(T)
+		if err := Unmarshal(cfg, next); err != nil {
+			fail(err)
+			return
+		}
+		if opts.Validate != nil {
+			if err := opts.Validate(next); err != nil {
+				fail(fmt.Errorf("validate: %w", err))
+				return
+			}
+		}
+		old := b.value.Swap(next)
+		if opts.OnChange != nil {
+			if changes := DiffFields(old, next); len(changes) > 0 {
+				opts.OnChange(old, next, changes)
+			}
+		}
+	})
+
+	if opts.OnError != nil {
+		go func() {
+			for {
+				select {
+				case err := <-b.watch.Errors():
+					opts.OnError(err)
+				case <-b.watch.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	return b, nil
+}
+
+// Stop ends the binding. The last value remains available through Load.
+func (b *Binder[T]) Stop() {
+	b.watch.Stop()
+}
+
+// DiffFields lists the exported fields that differ between two values of
+// the same struct type, descending into nested structs. Other values, such
+// as slices and maps, are compared as a whole.
+func DiffFields[T any](old, new *T) []FieldChange {
+	var changes []FieldChange
+	diffValues(reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem(), "", &changes)
+	return changes
+}
+
+func diffValues(a, b reflect.Value, path string, changes *[]FieldChange) {
+	if a.Kind() == reflect.Struct {
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if path != "" {
+				name = path + "." + f.Name
+			}
+			diffValues(a.Field(i), b.Field(i), name, changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*changes = append(*changes, FieldChange{Path: path, Old: a.Interface(), New: b.Interface()})
+	}
+}
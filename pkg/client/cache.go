@@ -0,0 +1,73 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// configCache is the read-through cache behind GetConfig. Entries expire
+// after the TTL and are replaced as soon as a watch delivers a change, so a
+// watched key is never served stale. A nil cache is disabled.
+type configCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[watchTarget]cacheEntry
+}
+
+type cacheEntry struct {
+	cfg     *model.Config
+	expires time.Time
+}
+
+func newConfigCache(ttl time.Duration) *configCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &configCache{ttl: ttl, entries: make(map[watchTarget]cacheEntry)}
+}
+
+// get returns a copy of the cached config, if present and not expired
+func (cc *configCache) get(namespace, group, key string) (*model.Config, bool) {
+	if cc == nil {
+		return nil, false
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	target := watchTarget{Namespace: namespace, Group: group, Key: key}
+	entry, ok := cc.entries[target]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(cc.entries, target)
+		return nil, false
+	}
+	cfg := *entry.cfg
+	return &cfg, true
+}
+
+// put caches a copy of cfg for the TTL
+func (cc *configCache) put(cfg *model.Config) {
+	if cc == nil {
+		return
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	stored := *cfg
+	target := watchTarget{Namespace: cfg.Namespace, Group: cfg.Group, Key: cfg.Key}
+	cc.entries[target] = cacheEntry{cfg: &stored, expires: time.Now().Add(cc.ttl)}
+}
+
+// invalidate drops the cached value of a config
+func (cc *configCache) invalidate(namespace, group, key string) {
+	if cc == nil {
+		return
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	delete(cc.entries, watchTarget{Namespace: namespace, Group: group, Key: key})
+}
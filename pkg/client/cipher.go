@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// CipherPrefix marks an encrypted config value. The rest of the value is the
+// base64-encoded ciphertext, which the server stores and serves as is.
+const CipherPrefix = "cipher:"
+
+// Decryptor turns the ciphertext of an encrypted config value back into
+// plaintext. Implementations may use a local key, a KMS or any other key
+// source, and must be safe for concurrent use.
+type Decryptor interface {
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// AESGCM encrypts and decrypts config values with a local AES-GCM key. The
+// ciphertext is the random nonce followed by the sealed value.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM creates an AESGCM from a 16, 24 or 32 byte key
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// Encrypt returns plaintext as a config value in the cipher: format, ready
+// to be stored with PutConfig
+func (a *AESGCM) Encrypt(plaintext string) (string, error) {
+	sealed, err := a.seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return CipherPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// seal encrypts plaintext under a random nonce, which prefixes the result
+func (a *AESGCM) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt implements Decryptor
+func (a *AESGCM) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	n := a.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	return a.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+// decrypt returns a copy of cfg with an encrypted value replaced by its
+// plaintext. Configs without the cipher: prefix, or clients without a
+// Decryptor, are returned unchanged.
+func (c *Client) decrypt(ctx context.Context, cfg *model.Config) (*model.Config, error) {
+	if c.config.Decryptor == nil || !strings.HasPrefix(cfg.Value, CipherPrefix) {
+		return cfg, nil
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cfg.Value, CipherPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s/%s/%s: %w", cfg.Namespace, cfg.Group, cfg.Key, err)
+	}
+	plaintext, err := c.config.Decryptor.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s/%s/%s: %w", cfg.Namespace, cfg.Group, cfg.Key, err)
+	}
+	out := *cfg
+	out.Value = string(plaintext)
+	return &out, nil
+}
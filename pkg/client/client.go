@@ -2,33 +2,117 @@ package client
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sotowang/otter/pkg/model"
 )
 
+// clientIDHeader carries ClientConfig.ClientID on every request
+const clientIDHeader = "X-Otter-Client-Id"
+
+// clientLabelsHeader carries ClientConfig.Labels on every request
+const clientLabelsHeader = "X-Otter-Client-Labels"
+
 // ClientConfig contains configuration for the client
 
 type ClientConfig struct {
-	// Endpoint is the server endpoint URL
+	// Endpoint is the server endpoint URL, a comma-separated list of URLs
+	// to fail over between, or dns+srv://name to discover servers from DNS
+	// SRV records
 	Endpoint string
 	// Token is the authentication token
 	Token string
+	// Tenant is the tenant Login signs in to, empty for users outside any
+	// tenant
+	Tenant string
 	// ConnectionPoolSize is the maximum number of connections in the pool
 	ConnectionPoolSize int
 	// ConnectionIdleTimeout is the time after which idle connections are closed
 	ConnectionIdleTimeout time.Duration
 	// RequestTimeout is the timeout for each HTTP request
 	RequestTimeout time.Duration
-	// WatchTimeout is the timeout for watch requests
+	// WatchTimeout is the timeout for watch requests. It defaults to 40s, or
+	// 10s more than WatchWait.
 	WatchTimeout time.Duration
+	// WatchWait, if positive, asks the server to hold each long poll this
+	// long for a change instead of 30s, up to its -max-watch-wait. Set it
+	// below the idle timeout of proxies between client and server.
+	WatchWait time.Duration
+	// ClientID identifies this client instance to the server for propagation
+	// tracking. Defaults to hostname-pid.
+	ClientID string
+	// Labels describe this client instance, e.g. region=eu or tier=canary.
+	// Configs with variants are served the value of the variant whose
+	// selector matches them most specifically. Names and values may hold
+	// letters, digits, '.', '_', '-' and '/'.
+	Labels map[string]string
+	// SnapshotDir, if set, stores the last-known value of every fetched or
+	// watched config on disk. GetConfig serves from it when the server is
+	// unreachable, so dependent services can still start during an outage.
+	SnapshotDir string
+	// Offline serves configs only from SnapshotDir, e.g. one populated with
+	// ImportSnapshot, and never contacts the server. Watches stay idle.
+	Offline bool
+	// Retry controls retries of unary calls and the backoff between failed
+	// watch polls
+	Retry RetryPolicy
+	// Logger receives diagnostic messages. Defaults to the standard library
+	// logger; use NewZapLogger to route them through zap.
+	Logger Logger
+	// DisableWatchMultiplexing gives every WatchConfig its own long poll
+	// instead of sharing one connection per client
+	DisableWatchMultiplexing bool
+	// WatchTransport selects how multiplexed watches reach the server:
+	// WatchTransportLongPoll (default) or WatchTransportWebSocket
+	WatchTransport string
+	// Decryptor, if set, decrypts config values in the cipher: format before
+	// they are returned or delivered. Snapshots keep the encrypted value.
+	Decryptor Decryptor
+	// SecretResolver, if set, resolves secret references such as
+	// vault:secret/data/db#password in the client instead of the server.
+	// Snapshots keep the reference.
+	SecretResolver SecretResolver
+	// SigningKey, if set, must match the server's OTTER_SIGNING_KEY. Configs
+	// and change events without a valid signature are rejected with
+	// ErrInvalidSignature instead of being returned or delivered.
+	SigningKey []byte
+	// Metrics, if set, is notified of every request and watch event
+	Metrics MetricsHook
+	// HealthCheckInterval is how often endpoints that failed are probed to
+	// return them to rotation when several are configured
+	HealthCheckInterval time.Duration
+	// Resolver, if set, discovers endpoints instead of Endpoint. A dns+srv://
+	// Endpoint installs an SRVResolver.
+	Resolver Resolver
+	// ResolveInterval is how often Resolver is consulted again
+	ResolveInterval time.Duration
+	// Transport, if set, carries all requests instead of the built-in
+	// pooled transport; the pool, proxy and TLS options below are ignored
+	Transport http.RoundTripper
+	// ProxyURL routes requests through an HTTP proxy
+	ProxyURL string
+	// CAFile is a PEM bundle of CAs trusted to sign the server certificate,
+	// in place of the system roots
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented
+	// to servers that require mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables server certificate verification. Only
+	// use it for testing.
+	InsecureSkipVerify bool
+	// CacheTTL, if positive, serves repeated GetConfig calls for a key from
+	// memory for this long. Watch events refresh cached keys immediately.
+	CacheTTL time.Duration
 }
 
 // ConnectionStats contains connection statistics
@@ -45,11 +129,27 @@ type ConnectionStats struct {
 // Client represents a client for the Otter config center
 
 type Client struct {
-	endpoint     string
+	endpoints *endpointSet
+	client    *http.Client
+	config    ClientConfig
+	logger    Logger
+	stop      context.CancelFunc
+	mux       *watchMux
+	cache     *configCache
+
+	// Authentication state, guarded by authMu. refreshMu serializes token
+	// refreshes so concurrent requests trigger only one.
+	authMu       sync.RWMutex
+	refreshMu    sync.Mutex
 	token        string
 	refreshToken string
-	client       *http.Client
-	config       ClientConfig
+	// refreshAt is when the access token is refreshed proactively, zero
+	// if its lifetime is unknown
+	refreshAt time.Time
+	// Credentials from the last successful Login, used to log in again
+	// when the refresh token is no longer accepted
+	username string
+	password string
 
 	// Connection statistics
 	mu    sync.Mutex
@@ -83,16 +183,33 @@ func NewClientWithConfig(config ClientConfig) *Client {
 	}
 	if config.WatchTimeout <= 0 {
 		config.WatchTimeout = 40 * time.Second
+		if config.WatchWait > 0 {
+			config.WatchTimeout = config.WatchWait + 10*time.Second
+		}
+	}
+	if config.ClientID == "" {
+		config.ClientID = defaultClientID()
+	}
+	config.Retry = config.Retry.withDefaults()
+	if config.Logger == nil {
+		config.Logger = stdLogger{}
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = 10 * time.Second
+	}
+	if config.Resolver == nil {
+		config.Resolver = parseResolver(config.Endpoint)
+	}
+	if config.ResolveInterval <= 0 {
+		config.ResolveInterval = 30 * time.Second
 	}
 
 	// Create HTTP client with connection pool
-	transport := &http.Transport{
-		MaxIdleConns:          config.ConnectionPoolSize,
-		MaxIdleConnsPerHost:   config.ConnectionPoolSize,
-		IdleConnTimeout:       config.ConnectionIdleTimeout,
-		MaxConnsPerHost:       config.ConnectionPoolSize * 2, // Allow temporary burst
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+	transport, err := newTransport(config)
+	if err != nil {
+		// Requests will fail TLS verification or the proxy rather than
+		// silently bypassing them
+		config.Logger.Errorf("Invalid transport configuration: %v", err)
 	}
 
 	client := &http.Client{
@@ -100,21 +217,73 @@ func NewClientWithConfig(config ClientConfig) *Client {
 		Timeout:   config.RequestTimeout,
 	}
 
-	return &Client{
-		endpoint: config.Endpoint,
-		token:    config.Token,
-		client:   client,
-		config:   config,
+	endpoints := []string{""}
+	if config.Resolver == nil {
+		endpoints = parseEndpoints(config.Endpoint)
+	}
+
+	c := &Client{
+		endpoints: newEndpointSet(endpoints, config.Logger),
+		token:     config.Token,
+		client:    client,
+		config:    config,
+		logger:    config.Logger,
+		stop:      func() {},
+		cache:     newConfigCache(config.CacheTTL),
 		stats: ConnectionStats{
 			LastRequestTime: time.Now(),
 		},
 	}
+
+	if !config.DisableWatchMultiplexing && !config.Offline {
+		c.mux = newWatchMux(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.stop = cancel
+	if config.Resolver != nil && !config.Offline {
+		c.resolve(ctx)
+		go c.resolveLoop(ctx, config.ResolveInterval)
+	}
+	if config.Resolver != nil || len(c.endpoints.urls) > 1 {
+		go c.healthCheck(ctx, config.HealthCheckInterval)
+	}
+	return c
+}
+
+// Close stops background endpoint health checks and re-resolution. Watches
+// are stopped separately through their handles.
+func (c *Client) Close() {
+	c.stop()
+}
+
+// formatLabels encodes labels for the labels header as name=value pairs
+// separated by commas
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// defaultClientID derives a client identity from the host name and process ID
+func defaultClientID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
 
 // WithAuth sets the authentication token
 
 func (c *Client) WithAuth(token string) *Client {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
 	c.token = token
+	c.refreshAt = time.Time{}
 	return c
 }
 
@@ -161,18 +330,31 @@ type TokenResponse struct {
 }
 
 // RefreshToken refreshes the access token using the refresh token
-func (c *Client) RefreshToken() error {
-	if c.refreshToken == "" {
+func (c *Client) RefreshToken(ctx context.Context) error {
+	c.authMu.RLock()
+	refreshToken := c.refreshToken
+	c.authMu.RUnlock()
+	if refreshToken == "" {
 		return fmt.Errorf("no refresh token available")
 	}
 
 	startTime := time.Now()
-	url := fmt.Sprintf("%s/api/v1/refresh", c.endpoint)
+	base := c.endpoints.get()
+	url := base + "/api/v1/refresh"
 	reqBody, _ := json.Marshal(map[string]string{
-		"refresh_token": c.refreshToken,
+		"refresh_token": refreshToken,
 	})
 
-	resp, err := c.client.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		c.updateStats(startTime, false)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	c.observeRequest(http.MethodPost, base, "/api/v1/refresh", startTime, resp, err)
+	c.endpoints.report(base, resp, err)
 	if err != nil {
 		c.updateStats(startTime, false)
 		return err
@@ -190,80 +372,179 @@ func (c *Client) RefreshToken() error {
 		return err
 	}
 
-	c.token = res.AccessToken
-	c.refreshToken = res.RefreshToken
+	c.setTokens(res)
 	c.updateStats(startTime, true)
 	return nil
 }
 
 // Login authenticates with the server and gets a token
 
-func (c *Client) Login(username, password string) error {
+func (c *Client) Login(ctx context.Context, username, password string) error {
 	startTime := time.Now()
-	url := fmt.Sprintf("%s/api/v1/login", c.endpoint)
+	base := c.endpoints.get()
+	url := base + "/api/v1/login"
 
-	// Calculate password hash for logging
-	hash := sha256.Sum256([]byte(password))
-	passwordHash := hex.EncodeToString(hash[:])
-
-	reqBody, _ := json.Marshal(map[string]string{
+	login := map[string]string{
 		"username": username,
 		"password": password,
-	})
+	}
+	if c.config.Tenant != "" {
+		login["tenant"] = c.config.Tenant
+	}
+	reqBody, _ := json.Marshal(login)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		c.updateStats(startTime, false)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.client.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	resp, err := c.client.Do(req)
+	c.observeRequest(http.MethodPost, base, "/api/v1/login", startTime, resp, err)
+	c.endpoints.report(base, resp, err)
 	if err != nil {
 		c.updateStats(startTime, false)
-		log.Printf("Login failed for user %s: %v, password: %s, password_hash: %s", username, err, password, passwordHash)
+		c.logger.Errorf("Login failed for user %s: %v", username, err)
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		c.updateStats(startTime, false)
-		log.Printf("Login failed for user %s: status %d, password: %s, password_hash: %s", username, resp.StatusCode, password, passwordHash)
+		c.logger.Errorf("Login failed for user %s: status %d", username, resp.StatusCode)
 		return fmt.Errorf("login failed: status %d", resp.StatusCode)
 	}
 
 	var res TokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
 		c.updateStats(startTime, false)
-		log.Printf("Login failed for user %s: failed to decode response %v, password: %s, password_hash: %s", username, err, password, passwordHash)
+		c.logger.Errorf("Login failed for user %s: failed to decode response %v", username, err)
 		return err
 	}
 
-	c.token = res.AccessToken
-	c.refreshToken = res.RefreshToken
+	c.setTokens(res)
+	c.authMu.Lock()
+	c.username = username
+	c.password = password
+	c.authMu.Unlock()
 	c.updateStats(startTime, true)
-	log.Printf("Login successful for user %s, password_hash: %s", username, passwordHash)
+	c.logger.Infof("Login successful for user %s", username)
 	return nil
 }
 
-// GetConfig retrieves a configuration item
+// reauthenticate obtains a new access token, preferring the refresh token
+// and falling back to logging in again with the stored credentials
+func (c *Client) reauthenticate(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.reauthenticateLocked(ctx)
+}
 
-func (c *Client) GetConfig(namespace, group, key string) (*model.Config, error) {
-	startTime := time.Now()
-	url := fmt.Sprintf("%s/api/v1/namespaces/%s/groups/%s/configs/%s", c.endpoint, namespace, group, key)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// reauthenticateLocked implements reauthenticate. Callers must hold
+// c.refreshMu.
+func (c *Client) reauthenticateLocked(ctx context.Context) error {
+	err := c.RefreshToken(ctx)
+	c.authMu.RLock()
+	username, password := c.username, c.password
+	c.authMu.RUnlock()
+	if err == nil || username == "" {
+		if err != nil {
+			c.logger.Errorf("Token refresh failed: %v", err)
+		}
+		return err
+	}
+	c.logger.Warnf("Token refresh failed, logging in again: %v", err)
+	return c.Login(ctx, username, password)
+}
+
+// send sends an authenticated request for path to the current endpoint using
+// hc. If the server answers 401 the client re-authenticates and retries the
+// request once with the new token.
+func (c *Client) send(ctx context.Context, hc *http.Client, method, path string, body []byte) (*http.Response, error) {
+	c.ensureFreshToken(ctx)
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		base := c.endpoints.get()
+		req, err := http.NewRequestWithContext(ctx, method, base+path, reader)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		token := c.accessToken()
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		req.Header.Set(clientIDHeader, c.config.ClientID)
+		if len(c.config.Labels) > 0 {
+			req.Header.Set(clientLabelsHeader, formatLabels(c.config.Labels))
+		}
+
+		startTime := time.Now()
+		resp, err := hc.Do(req)
+		c.observeRequest(method, base, path, startTime, resp, err)
+		if ctx.Err() == nil {
+			c.endpoints.report(base, resp, err)
+		}
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, err
+		}
+		if c.reauthenticateFrom(ctx, token) != nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+}
+
+// GetConfig retrieves a configuration item, decrypting its value if it is
+// encrypted and a Decryptor is configured, and resolving a secret reference
+// if a SecretResolver is configured
+
+func (c *Client) GetConfig(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	cfg, err := c.getConfig(ctx, namespace, group, key)
 	if err != nil {
-		c.updateStats(startTime, false)
 		return nil, err
 	}
+	return c.reveal(ctx, cfg)
+}
 
-	if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+// getConfig retrieves a configuration item as stored on the server
+func (c *Client) getConfig(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	if c.config.Offline {
+		cfg, err := c.loadSnapshot(namespace, group, key)
+		if err != nil {
+			return nil, fmt.Errorf("offline snapshot for %s/%s/%s: %w", namespace, group, key, err)
+		}
+		return cfg, nil
+	}
+	if cfg, ok := c.cache.get(namespace, group, key); ok {
+		return cfg, nil
 	}
 
-	resp, err := c.client.Do(req)
+	startTime := time.Now()
+	resp, err := c.do(ctx, http.MethodGet, configPath(namespace, group, key)+c.readQuery(), nil)
 	if err != nil {
 		c.updateStats(startTime, false)
-		return nil, err
+		return c.fallbackToSnapshot(namespace, group, key, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		c.updateStats(startTime, false)
-		return nil, fmt.Errorf("failed to get config: status %d", resp.StatusCode)
+		var res struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		err := &APIError{StatusCode: resp.StatusCode, Message: res.Error}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return c.fallbackToSnapshot(namespace, group, key, err)
+		}
+		return nil, err
 	}
 
 	var cfg model.Config
@@ -272,69 +553,280 @@ func (c *Client) GetConfig(namespace, group, key string) (*model.Config, error)
 		return nil, err
 	}
 	c.updateStats(startTime, true)
+
+	if err := c.saveSnapshot(&cfg); err != nil {
+		c.logger.Warnf("Failed to save snapshot for %s/%s/%s: %v", namespace, group, key, err)
+	}
+	c.cache.put(&cfg)
 	return &cfg, nil
 }
 
-// WatchConfig watches for changes to a configuration item
+// fallbackToSnapshot serves the last-known value of a config when the server
+// is unreachable, returning the original error if no snapshot exists
+func (c *Client) fallbackToSnapshot(namespace, group, key string, cause error) (*model.Config, error) {
+	cfg, err := c.loadSnapshot(namespace, group, key)
+	if err != nil {
+		return nil, cause
+	}
+	c.logger.Warnf("Serving %s/%s/%s from local snapshot: %v", namespace, group, key, cause)
+	return cfg, nil
+}
+
+// ackConfig tells the server this client has applied a config version
+func (c *Client) ackConfig(ctx context.Context, namespace, group, key string, version int64) {
+	startTime := time.Now()
+	reqBody, _ := json.Marshal(map[string]int64{"version": version})
+
+	resp, err := c.do(ctx, http.MethodPost, configPath(namespace, group, key, "ack"), reqBody)
+	if err != nil {
+		c.logger.Debugf("Failed to ack %s/%s/%s version %d: %v", namespace, group, key, version, err)
+		c.updateStats(startTime, false)
+		return
+	}
+	resp.Body.Close()
+	c.updateStats(startTime, resp.StatusCode == http.StatusNoContent)
+}
+
+// watchErrorBuffer is how many unread errors a Watch holds before dropping
+// new ones
+const watchErrorBuffer = 16
+
+// Watch is a handle to a running config watch
+type Watch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	errs   chan error
+}
+
+// newWatch creates a watch handle reporting to errs, or to a channel of its
+// own if errs is nil
+func newWatch(cancel context.CancelFunc, errs chan error) *Watch {
+	if errs == nil {
+		errs = make(chan error, watchErrorBuffer)
+	}
+	return &Watch{cancel: cancel, done: make(chan struct{}), errs: errs}
+}
+
+// WatchError describes a failure of a running watch. The watch keeps
+// retrying; the error lets applications alert instead of silently running
+// on stale config.
+type WatchError struct {
+	Namespace string
+	// Group is empty for namespace watches
+	Group string
+	// Key is empty for group and namespace watches
+	Key string
+	// StatusCode is the HTTP status the server answered with, or 0 for
+	// transport, decode and decryption errors
+	StatusCode int
+	Err        error
+}
+
+func (e *WatchError) Error() string {
+	target := watchTarget{e.Namespace, e.Group, e.Key}
+	if e.Err == nil {
+		return fmt.Sprintf("watch %s: status %d", target, e.StatusCode)
+	}
+	return fmt.Sprintf("watch %s: %v", target, e.Err)
+}
+
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns a channel of *WatchError values describing failures of the
+// watch. It is never closed; use Done to detect the end of the watch.
+// Errors are dropped while the channel is full, so reading it is optional.
+func (w *Watch) Errors() <-chan error {
+	return w.errs
+}
+
+// report queues a watch failure without blocking
+func (w *Watch) report(t watchTarget, statusCode int, err error) {
+	select {
+	case w.errs <- &WatchError{Namespace: t.Namespace, Group: t.Group, Key: t.Key, StatusCode: statusCode, Err: err}:
+	default:
+	}
+}
+
+// Stop ends the watch, aborting any in-flight long poll, and waits for its
+// goroutine to exit. It is safe to call Stop more than once.
+func (w *Watch) Stop() {
+	w.cancel()
+	<-w.done
+}
+
+// Done returns a channel that is closed once the watch has stopped
+func (w *Watch) Done() <-chan struct{} {
+	return w.done
+}
+
+// sleepContext waits for d or until ctx is cancelled, reporting whether the full delay elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// WatchConfig watches for changes to a configuration item until ctx is
+// cancelled or the returned Watch is stopped. The callback is not invoked
+// again for a value identical to the one it last received; deleted configs
+// arrive with Version -1. Use WatchConfigEvents to receive the event type.
+func (c *Client) WatchConfig(ctx context.Context, namespace, group, key string, callback func(*model.Config)) *Watch {
+	return c.WatchConfigEvents(ctx, namespace, group, key, configCallback(callback))
+}
+
+// watchTarget watches a single config key with its own long poll, reporting
+// errors to errs if not nil
+func (c *Client) watchTarget(ctx context.Context, t watchTarget, callback func(*model.ConfigEvent), errs chan error) *Watch {
+	return c.watch(ctx, t, configPath(t.Namespace, t.Group, t.Key, "watch"), callback, errs)
+}
+
+// WatchGroup watches for changes to any configuration item in a group until
+// ctx is cancelled or the returned Watch is stopped. The callback receives
+// each changed config, skipping values identical to the last one for the
+// key; deleted configs arrive with Version -1.
+func (c *Client) WatchGroup(ctx context.Context, namespace, group string, callback func(*model.Config)) *Watch {
+	return c.WatchGroupEvents(ctx, namespace, group, configCallback(callback))
+}
+
+// WatchNamespace is like WatchGroup but watches every group of a namespace
+func (c *Client) WatchNamespace(ctx context.Context, namespace string, callback func(*model.Config)) *Watch {
+	return c.WatchNamespaceEvents(ctx, namespace, configCallback(callback))
+}
+
+// watch long-polls path until ctx is cancelled, delivering every change to
+// callback and reporting failures to errs if not nil. t identifies the
+// watch in log messages and errors.
+func (c *Client) watch(ctx context.Context, t watchTarget, path string, callback func(*model.ConfigEvent), errs chan error) *Watch {
+	ctx, cancel := context.WithCancel(ctx)
+	w := newWatch(cancel, errs)
+
+	if c.config.Offline {
+		// Nothing can change without a server
+		go func() {
+			<-ctx.Done()
+			close(w.done)
+		}()
+		return w
+	}
 
-func (c *Client) WatchConfig(namespace, group, key string, callback func(*model.Config)) {
 	go func() {
-		url := fmt.Sprintf("%s/api/v1/namespaces/%s/groups/%s/configs/%s/watch", c.endpoint, namespace, group, key)
+		defer close(w.done)
 
-		for {
-			startTime := time.Now()
+		// Create a custom client with watch timeout for watch requests only
+		watchClient := &http.Client{
+			Transport: c.client.Transport, // Reuse the same connection pool
+			Timeout:   c.config.WatchTimeout,
+		}
 
-			// Create a new request each time to ensure we use the latest token
-			req, err := http.NewRequest(http.MethodGet, url, nil)
-			if err != nil {
-				c.updateStats(startTime, false)
-				time.Sleep(2 * time.Second)
-				continue
-			}
+		// Consecutive failed polls, used to back off between retries
+		failures := 0
+		// Last version delivered for a key watch, sent so the server answers
+		// at once with a change made between polls
+		var version int64
 
-			if c.token != "" {
-				req.Header.Set("Authorization", "Bearer "+c.token)
-			}
+		for ctx.Err() == nil {
+			startTime := time.Now()
 
-			// Create a custom client with watch timeout for this request only
-			watchClient := &http.Client{
-				Transport: c.client.Transport, // Reuse the same connection pool
-				Timeout:   c.config.WatchTimeout,
+			url := path
+			if t.Key != "" && version != 0 {
+				url = fmt.Sprintf("%s?version=%d", path, version)
 			}
-
-			resp, err := watchClient.Do(req)
+			url = c.withWatchWait(url)
+			resp, err := c.send(ctx, watchClient, http.MethodGet, url, nil)
 			if err != nil {
-				// Log error and retry after delay
+				if ctx.Err() != nil {
+					// Stopped while waiting
+					return
+				}
+				// Retry after backing off
+				c.logger.Warnf("Watch %s failed: %v", t, err)
+				w.report(t, 0, err)
 				c.updateStats(startTime, false)
-				time.Sleep(2 * time.Second)
+				failures++
+				c.retryWait(ctx, http.MethodGet, path, failures, nil, err)
 				continue
 			}
 
 			if resp.StatusCode == http.StatusOK {
-				var cfg model.Config
-				if err := json.NewDecoder(resp.Body).Decode(&cfg); err == nil {
-					callback(&cfg)
+				if event, err := readEvent(resp.Body); err != nil {
+					c.logger.Errorf("Failed to decode watch response for %s: %v", t, err)
+					w.report(t, 0, err)
+				} else {
+					version = event.Config.Version
+					if err := c.deliver(ctx, event, callback); err != nil {
+						w.report(t, 0, err)
+					}
 				}
 				c.updateStats(startTime, true)
+				failures = 0
 			} else if resp.StatusCode == http.StatusNotModified {
 				// Timeout, just retry
 				c.updateStats(startTime, true) // Treat timeout as successful for stats
-			} else if resp.StatusCode == http.StatusUnauthorized {
-				// Token expired, try to refresh
-				c.updateStats(startTime, false)
-				if err := c.RefreshToken(); err == nil {
-					// Refresh successful, continue with next iteration
-					resp.Body.Close()
-					continue
-				}
-				// Refresh failed, retry after longer delay
-				time.Sleep(5 * time.Second)
+				failures = 0
 			} else {
-				// Other error, retry after delay
+				// Error, including a 401 that re-authentication could not
+				// fix, retry after backing off
+				c.logger.Warnf("Watch %s failed: status %d", t, resp.StatusCode)
+				w.report(t, resp.StatusCode, nil)
 				c.updateStats(startTime, false)
-				time.Sleep(2 * time.Second)
+				failures++
+				c.retryWait(ctx, http.MethodGet, path, failures, resp, nil)
 			}
 			resp.Body.Close()
 		}
 	}()
+
+	return w
+}
+
+// withWatchWait adds the hold time asked for by WatchWait to a long-poll
+// path
+func (c *Client) withWatchWait(path string) string {
+	if c.config.WatchWait <= 0 {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "wait=" + c.config.WatchWait.String()
+}
+
+// deliver updates the local snapshot for a changed config, hands it to
+// callback and acknowledges the new version to the server. It returns an
+// error if the change could not be decrypted.
+func (c *Client) deliver(ctx context.Context, event *model.ConfigEvent, callback func(*model.ConfigEvent)) error {
+	cfg := event.Config
+	// Never cache or snapshot a config that fails verification
+	if err := c.verify(cfg); err != nil {
+		c.logger.Errorf("Failed to deliver change: %v", err)
+		return err
+	}
+	var err error
+	if cfg.Version == -1 {
+		c.cache.invalidate(cfg.Namespace, cfg.Group, cfg.Key)
+		err = c.removeSnapshot(cfg.Namespace, cfg.Group, cfg.Key)
+	} else {
+		c.cache.put(cfg)
+		err = c.saveSnapshot(cfg)
+	}
+	if err != nil {
+		c.logger.Warnf("Failed to update snapshot for %s/%s/%s: %v", cfg.Namespace, cfg.Group, cfg.Key, err)
+	}
+	plain, err := c.reveal(ctx, cfg)
+	if err != nil {
+		c.logger.Errorf("Failed to deliver change: %v", err)
+		return err
+	}
+	callback(&model.ConfigEvent{Type: event.Type, Config: plain})
+	c.observeWatchEvent(cfg.Namespace, cfg.Group, cfg.Key, cfg.Version)
+	c.ackConfig(ctx, cfg.Namespace, cfg.Group, cfg.Key, cfg.Version)
+	return nil
 }
@@ -1,12 +1,26 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sotowang/otter/pkg/model"
+	"github.com/sotowang/otter/pkg/signing"
 )
 
 // TestConnectionPoolSingleInstance tests connection pool behavior with a single client instance
@@ -28,7 +42,7 @@ func TestConnectionPoolSingleInstance(t *testing.T) {
 			key := fmt.Sprintf("test_key_%d", id)
 			
 			// This will not block since we're not actually connecting to a server
-			c.WatchConfig("default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
+			c.WatchConfig(context.Background(), "default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
 				fmt.Printf("Watcher %d received update: %s\n", id, cfg.Key)
 			})
 			
@@ -69,7 +83,7 @@ func TestConnectionPoolMultipleInstances(t *testing.T) {
 				
 				key := fmt.Sprintf("test_key_%d_%d", clientID, watcherID)
 				
-				c.WatchConfig("default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
+				c.WatchConfig(context.Background(), "default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
 					fmt.Printf("Client %d, Watcher %d received update: %s\n", clientID, watcherID, cfg.Key)
 				})
 				
@@ -101,7 +115,7 @@ func TestConnectionLeak(t *testing.T) {
 			key := fmt.Sprintf("leak_test_key_%d", id)
 			
 			// Watch for a short time
-			c.WatchConfig("default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
+			c.WatchConfig(context.Background(), "default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
 				// Do nothing
 			})
 			
@@ -141,7 +155,7 @@ func TestConnectionPoolConfig(t *testing.T) {
 			
 			key := fmt.Sprintf("config_test_key_%d", id)
 			
-			c.WatchConfig("default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
+			c.WatchConfig(context.Background(), "default", "DEFAULT_GROUP", key, func(cfg *model.Config) {
 				// Do nothing
 			})
 			
@@ -152,4 +166,1170 @@ func TestConnectionPoolConfig(t *testing.T) {
 	wg.Wait()
 	
 	fmt.Printf("Custom pool config test completed successfully\n")
-}
\ No newline at end of file
+}
+// TestSnapshotFallback tests that GetConfig serves the last-known value when the server goes away
+func TestSnapshotFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: "postgres://db", Version: 7})
+	}))
+
+	c := NewClientWithConfig(ClientConfig{
+		Endpoint:    srv.URL,
+		SnapshotDir: t.TempDir(),
+	})
+
+	if _, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url"); err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+
+	// Simulate an outage
+	srv.Close()
+
+	cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url")
+	if err != nil {
+		t.Fatalf("GetConfig did not fall back to the snapshot: %v", err)
+	}
+	if cfg.Value != "postgres://db" || cfg.Version != 7 {
+		t.Errorf("unexpected snapshot config: %+v", cfg)
+	}
+
+	if _, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "missing"); err == nil {
+		t.Error("expected an error for a key without a snapshot")
+	}
+}
+
+// TestUnmarshal tests decoding config values of each supported type into structs
+func TestUnmarshal(t *testing.T) {
+	type dbConfig struct {
+		Host string `json:"host" yaml:"host" toml:"host" hcl:"host"`
+		Port int    `json:"port" yaml:"port" toml:"port" hcl:"port"`
+	}
+	type appConfig struct {
+		Name  string   `json:"name" yaml:"name" toml:"name" hcl:"name"`
+		Debug bool     `json:"debug" yaml:"debug" toml:"debug" hcl:"debug"`
+		DB    dbConfig `json:"db" yaml:"db" toml:"db" hcl:"db"`
+	}
+	want := appConfig{Name: "orders", Debug: true, DB: dbConfig{Host: "db.local", Port: 5432}}
+
+	tests := []struct {
+		typ   string
+		value string
+	}{
+		{"json", `{"name":"orders","debug":true,"db":{"host":"db.local","port":5432}}`},
+		{"yaml", "name: orders\ndebug: true\ndb:\n  host: db.local\n  port: 5432\n"},
+		{"properties", "# app\nname=orders\ndebug = true\ndb.host=db.local\ndb.port: 5432\n"},
+		{"ini", "; app\nname = orders\ndebug = true\n\n[db]\nhost = db.local\nport = 5432\n"},
+		{"toml", "name = \"orders\"\ndebug = true\n\n[db]\nhost = \"db.local\"\nport = 5432\n"},
+		{"hcl", "name = \"orders\"\ndebug = true\n\ndb {\n  host = \"db.local\"\n  port = 5432\n}\n"},
+	}
+
+	for _, tt := range tests {
+		var got appConfig
+		if err := Unmarshal(&model.Config{Type: tt.typ, Value: tt.value}, &got); err != nil {
+			t.Errorf("%s: Unmarshal failed: %v", tt.typ, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: got %+v, want %+v", tt.typ, got, want)
+		}
+	}
+}
+
+// TestWatchStop tests that stopping a watch aborts the in-flight long poll and ends its goroutine
+func TestWatchStop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hold the long poll until the client goes away. The body must be
+		// read for the server to notice the connection closing.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	w := c.WatchConfig(context.Background(), "public", "DEFAULT_GROUP", "stop_test_key", func(cfg *model.Config) {})
+
+	time.Sleep(100 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	select {
+	case <-w.Done():
+	default:
+		t.Error("Done channel not closed after Stop")
+	}
+	w.Stop()
+}
+
+// TestReauthOn401 tests that a rejected token is replaced by logging in again and the call retried once
+func TestReauthOn401(t *testing.T) {
+	var logins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/login":
+			logins++
+			json.NewEncoder(w).Encode(TokenResponse{AccessToken: fmt.Sprintf("token-%d", logins)})
+		case "/api/v1/refresh":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: "postgres://db"})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	if err := c.Login(context.Background(), "admin", "admin"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url")
+	if err != nil {
+		t.Fatalf("GetConfig did not re-authenticate: %v", err)
+	}
+	if cfg.Value != "postgres://db" || logins != 2 {
+		t.Errorf("unexpected result: config %+v after %d logins", cfg, logins)
+	}
+}
+
+// TestRetryBackoff tests that unary calls retry server errors with growing delays reported to OnRetry
+func TestRetryBackoff(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: "postgres://db"})
+	}))
+	defer srv.Close()
+
+	var events []RetryEvent
+	c := NewClientWithConfig(ClientConfig{
+		Endpoint: srv.URL,
+		Retry: RetryPolicy{
+			MaxRetries:     3,
+			InitialBackoff: 10 * time.Millisecond,
+			Jitter:         -1,
+			OnRetry:        func(e RetryEvent) { events = append(events, e) },
+		},
+	})
+
+	if _, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url"); err != nil {
+		t.Fatalf("GetConfig failed after retries: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 retries, got %d", len(events))
+	}
+	for i, e := range events {
+		want := 10 * time.Millisecond << i
+		if e.Attempt != i+1 || e.Delay != want || e.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("retry %d: unexpected event %+v", i+1, e)
+		}
+	}
+
+	// Non-idempotent calls are not retried
+	calls = 0
+	events = nil
+	if _, err := c.Rollback(context.Background(), "public", "DEFAULT_GROUP", "db_url", 1); err == nil {
+		t.Error("expected Rollback to fail")
+	}
+	if calls != 1 || len(events) != 0 {
+		t.Errorf("Rollback was retried: %d calls", calls)
+	}
+}
+
+// TestRetryWrites tests that PUT calls are retried only when the policy
+// opts in, and that a retried DELETE finding the config gone succeeds
+func TestRetryWrites(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch {
+		case r.Method == http.MethodDelete && calls > 1:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			// The write is applied but its response is lost
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, Retry: RetryPolicy{InitialBackoff: time.Millisecond}})
+	if _, err := c.PutConfig(ctx, "public", "DEFAULT_GROUP", "db_url", "postgres://db", ""); err == nil {
+		t.Error("expected PutConfig to fail")
+	}
+	if calls != 1 {
+		t.Errorf("PutConfig was sent %d times without RetryWrites, want 1", calls)
+	}
+
+	c = NewClientWithConfig(ClientConfig{Endpoint: srv.URL, Retry: RetryPolicy{InitialBackoff: time.Millisecond, RetryWrites: true}})
+	calls = 0
+	if _, err := c.PutConfig(ctx, "public", "DEFAULT_GROUP", "db_url", "postgres://db", ""); err == nil {
+		t.Error("expected PutConfig to fail")
+	}
+	if calls != 3 {
+		t.Errorf("PutConfig was sent %d times with RetryWrites, want 3", calls)
+	}
+
+	calls = 0
+	if err := c.DeleteConfig(ctx, "public", "DEFAULT_GROUP", "db_url"); err != nil {
+		t.Errorf("DeleteConfig retried after the config was deleted failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("DeleteConfig was sent %d times, want 2", calls)
+	}
+}
+
+// TestEndpointFailover tests that calls fail over from an unreachable endpoint to the next one
+func TestEndpointFailover(t *testing.T) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: "postgres://db"})
+	}))
+	defer live.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	c := NewClientWithConfig(ClientConfig{
+		Endpoint: dead.URL + ", " + live.URL,
+		Retry:    RetryPolicy{InitialBackoff: time.Millisecond},
+	})
+	defer c.Close()
+
+	cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url")
+	if err != nil {
+		t.Fatalf("GetConfig did not fail over: %v", err)
+	}
+	if cfg.Value != "postgres://db" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if got := c.endpoints.get(); got != live.URL {
+		t.Errorf("current endpoint is %s, want %s", got, live.URL)
+	}
+	if got := c.endpoints.unhealthy(); len(got) != 1 || got[0] != dead.URL {
+		t.Errorf("unexpected unhealthy endpoints: %v", got)
+	}
+}
+
+// TestWatchMuxFallback tests that watches fall back to per-key long polls when the server lacks batch and WebSocket watches
+func TestWatchMuxFallback(t *testing.T) {
+	for _, transport := range []string{WatchTransportLongPoll, WatchTransportWebSocket} {
+		var served sync.Once
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/watch", "/api/v1/watch/ws":
+				w.WriteHeader(http.StatusNotFound)
+			case "/api/v1/namespaces/public/groups/DEFAULT_GROUP/configs/db_url/watch":
+				sent := false
+				served.Do(func() {
+					json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: "postgres://db", Version: 2})
+					sent = true
+				})
+				if !sent {
+					io.Copy(io.Discard, r.Body)
+					<-r.Context().Done()
+				}
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}))
+
+		c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, WatchTransport: transport})
+		got := make(chan *model.Config, 1)
+		w := c.WatchConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url", func(cfg *model.Config) { got <- cfg })
+
+		select {
+		case cfg := <-got:
+			if cfg.Value != "postgres://db" {
+				t.Errorf("%s: unexpected config: %+v", transport, cfg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s: watch did not fall back to a per-key long poll", transport)
+		}
+		w.Stop()
+		srv.Close()
+	}
+}
+
+// TestDecryption tests that encrypted values are decrypted for the caller while snapshots keep the ciphertext
+func TestDecryption(t *testing.T) {
+	aes, err := NewAESGCM([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("NewAESGCM failed: %v", err)
+	}
+	encrypted, err := aes.Encrypt("s3cret")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_password", Value: encrypted, Version: 1})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, SnapshotDir: t.TempDir(), Decryptor: aes})
+	cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_password")
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if cfg.Value != "s3cret" {
+		t.Errorf("got value %q, want the plaintext", cfg.Value)
+	}
+
+	snap, err := c.loadSnapshot("public", "DEFAULT_GROUP", "db_password")
+	if err != nil || snap.Value != encrypted {
+		t.Errorf("snapshot does not hold the ciphertext: %+v, %v", snap, err)
+	}
+}
+
+// TestEnvelopeRotation tests that values wrapped by a retired local key stay
+// readable and can be rewrapped with the current key
+func TestEnvelopeRotation(t *testing.T) {
+	oldKey, newKey := []byte("0123456789abcdef0123456789abcdef"), []byte("fedcba9876543210fedcba9876543210")
+	before, err := NewLocalKeys("k1", map[string][]byte{"k1": oldKey})
+	if err != nil {
+		t.Fatalf("NewLocalKeys failed: %v", err)
+	}
+	encrypted, err := NewEnvelope(before).Encrypt(context.Background(), "s3cret")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	os.WriteFile(keyFile, []byte("# current key first\nk2 "+base64.StdEncoding.EncodeToString(newKey)+"\nk1 "+base64.StdEncoding.EncodeToString(oldKey)+"\n"), 0o600)
+	after, err := LoadLocalKeys(keyFile)
+	if err != nil {
+		t.Fatalf("LoadLocalKeys failed: %v", err)
+	}
+	e := NewEnvelope(after)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_password", Value: encrypted, Version: 1})
+	}))
+	defer srv.Close()
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, Decryptor: e})
+	if cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_password"); err != nil || cfg.Value != "s3cret" {
+		t.Fatalf("GetConfig = %+v, %v, want the plaintext", cfg, err)
+	}
+
+	rotated, err := e.Rotate(context.Background(), encrypted)
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	newOnly, _ := NewLocalKeys("k2", map[string][]byte{"k2": newKey})
+	if plaintext, err := decryptValue(NewEnvelope(newOnly), rotated); err != nil || plaintext != "s3cret" {
+		t.Errorf("rotated value decrypts to %q, %v without the old key", plaintext, err)
+	}
+	if again, _ := e.Rotate(context.Background(), rotated); again != rotated {
+		t.Error("rotating a value wrapped by the current key changed it")
+	}
+}
+
+// TestKeyProviders tests that the Vault, GCP and AWS providers round-trip
+// data keys through their key service APIs
+func TestKeyProviders(t *testing.T) {
+	// The fake key service "wraps" by reversing the key and checks auth
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		reverse := func(field string) string {
+			b, _ := base64.StdEncoding.DecodeString(req[field])
+			for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+				b[i], b[j] = b[j], b[i]
+			}
+			return base64.StdEncoding.EncodeToString(b)
+		}
+		switch {
+		case r.URL.Path == "/v1/transit/encrypt/orders" && r.Header.Get("X-Vault-Token") == "vt":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"ciphertext": "vault:v1:" + reverse("plaintext")}})
+		case r.URL.Path == "/v1/transit/decrypt/orders" && r.Header.Get("X-Vault-Token") == "vt":
+			req["ciphertext"] = strings.TrimPrefix(req["ciphertext"], "vault:v1:")
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": reverse("ciphertext")}})
+		case r.URL.Path == "/v1/projects/p/cryptoKeys/k:encrypt" && r.Header.Get("Authorization") == "Bearer gt":
+			json.NewEncoder(w).Encode(map[string]string{"ciphertext": reverse("plaintext")})
+		case r.URL.Path == "/v1/projects/p/cryptoKeys/k:decrypt" && r.Header.Get("Authorization") == "Bearer gt":
+			json.NewEncoder(w).Encode(map[string]string{"plaintext": reverse("ciphertext")})
+		case r.Header.Get("X-Amz-Target") == "TrentService.Encrypt" && strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/"):
+			json.NewEncoder(w).Encode(map[string]string{"CiphertextBlob": reverse("Plaintext")})
+		case r.Header.Get("X-Amz-Target") == "TrentService.Decrypt" && strings.Contains(r.Header.Get("Authorization"), "Credential=AKID/"):
+			json.NewEncoder(w).Encode(map[string]string{"Plaintext": reverse("CiphertextBlob")})
+		default:
+			http.Error(w, "denied", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	providers := []KeyProvider{
+		&VaultTransit{Address: srv.URL, Token: "vt", Key: "orders"},
+		&GCPKMS{KeyName: "projects/p/cryptoKeys/k", Endpoint: srv.URL, TokenSource: func(context.Context) (string, error) { return "gt", nil }},
+		&AWSKMS{Key: "alias/orders", Region: "us-east-1", Endpoint: srv.URL, Credentials: AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}},
+	}
+	for _, p := range providers {
+		e := NewEnvelope(p)
+		encrypted, err := e.Encrypt(context.Background(), "s3cret")
+		if err != nil {
+			t.Errorf("%s: Encrypt failed: %v", p.Name(), err)
+			continue
+		}
+		if plaintext, err := decryptValue(e, encrypted); err != nil || plaintext != "s3cret" {
+			t.Errorf("%s: decrypted %q, %v", p.Name(), plaintext, err)
+		}
+	}
+
+	if _, err := NewEnvelope(&VaultTransit{Address: srv.URL, Token: "wrong", Key: "orders"}).Encrypt(context.Background(), "s3cret"); err == nil {
+		t.Error("Encrypt succeeded with a rejected token")
+	}
+}
+
+// decryptValue decrypts a config value in the cipher: format
+func decryptValue(d Decryptor, value string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, CipherPrefix))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := d.Decrypt(context.Background(), ciphertext)
+	return string(plaintext), err
+}
+
+// TestDiffFields tests that changed fields are reported by their dotted path
+func TestDiffFields(t *testing.T) {
+	type db struct {
+		Host string
+		Port int
+	}
+	type app struct {
+		Name  string
+		Tags  []string
+		DB    db
+		debug bool
+	}
+	old := &app{Name: "orders", Tags: []string{"a"}, DB: db{Host: "db", Port: 5432}}
+	new := &app{Name: "orders", Tags: []string{"a", "b"}, DB: db{Host: "db", Port: 6432}, debug: true}
+
+	changes := DiffFields(old, new)
+	if len(changes) != 2 || changes[0].Path != "Tags" || changes[1].Path != "DB.Port" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if changes[1].Old != 5432 || changes[1].New != 6432 {
+		t.Errorf("unexpected DB.Port change: %+v", changes[1])
+	}
+}
+
+// TestSnapshotExportImport tests that an exported snapshot serves an offline client
+func TestSnapshotExportImport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/configs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode([]*model.Config{
+			{Namespace: "prod", Group: "orders", Key: "db_url", Value: "postgres://db", Version: 3},
+			{Namespace: "prod", Group: "payments", Key: "timeout", Value: "5s", Version: 1},
+		})
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	if err := NewClient(srv.URL).ExportSnapshot(context.Background(), &buf, "prod"); err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+
+	offline := NewClientWithConfig(ClientConfig{SnapshotDir: t.TempDir(), Offline: true})
+	if n, err := offline.ImportSnapshot(&buf); err != nil || n != 2 {
+		t.Fatalf("ImportSnapshot imported %d configs: %v", n, err)
+	}
+
+	cfg, err := offline.GetConfig(context.Background(), "prod", "orders", "db_url")
+	if err != nil || cfg.Value != "postgres://db" || cfg.Version != 3 {
+		t.Errorf("unexpected offline config: %+v, %v", cfg, err)
+	}
+	if _, err := offline.GetConfig(context.Background(), "prod", "orders", "missing"); err == nil {
+		t.Error("expected an error for a key missing from the snapshot")
+	}
+}
+
+type staticResolver struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (r *staticResolver) Resolve(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.urls, nil
+}
+
+func (r *staticResolver) setURLs(urls ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = urls
+}
+
+// TestResolverEndpoints tests that the client follows endpoints returned by a resolver
+func TestResolverEndpoints(t *testing.T) {
+	newServer := func(value string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: value})
+		}))
+	}
+	first, second := newServer("first"), newServer("second")
+	defer first.Close()
+	defer second.Close()
+
+	resolver := &staticResolver{urls: []string{first.URL}}
+	c := NewClientWithConfig(ClientConfig{Resolver: resolver, ResolveInterval: 10 * time.Millisecond})
+	defer c.Close()
+
+	cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url")
+	if err != nil || cfg.Value != "first" {
+		t.Fatalf("GetConfig = %+v, %v", cfg, err)
+	}
+
+	resolver.setURLs(second.URL)
+	deadline := time.Now().Add(2 * time.Second)
+	for c.endpoints.get() != second.URL {
+		if time.Now().After(deadline) {
+			t.Fatalf("client did not pick up re-resolved endpoint, still on %s", c.endpoints.get())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cfg, err = c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url")
+	if err != nil || cfg.Value != "second" {
+		t.Fatalf("GetConfig = %+v, %v", cfg, err)
+	}
+
+	if r, ok := parseResolver("dns+srv+https://otter.service.consul").(*SRVResolver); !ok || r.Name != "otter.service.consul" || r.Scheme != "https" {
+		t.Errorf("unexpected resolver for dns+srv+https endpoint: %+v", r)
+	}
+}
+
+// TestTLSOptions tests that a CA bundle lets the client verify a server with a private certificate
+func TestTLSOptions(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: "postgres://db"})
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	untrusted := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, Retry: RetryPolicy{MaxRetries: -1}})
+	if _, err := untrusted.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url"); err == nil {
+		t.Error("expected certificate verification to fail without the CA bundle")
+	}
+
+	for name, config := range map[string]ClientConfig{
+		"ca file":   {Endpoint: srv.URL, CAFile: caFile},
+		"transport": {Endpoint: srv.URL, Transport: srv.Client().Transport},
+	} {
+		c := NewClientWithConfig(config)
+		cfg, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url")
+		if err != nil || cfg.Value != "postgres://db" {
+			t.Errorf("%s: GetConfig = %+v, %v", name, cfg, err)
+		}
+	}
+}
+
+// TestConfigCache tests that cached reads skip the server until a watch event or the TTL refreshes them
+func TestConfigCache(t *testing.T) {
+	var gets int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			return
+		}
+		atomic.AddInt32(&gets, 1)
+		json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: "postgres://db", Version: 1})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, CacheTTL: 50 * time.Millisecond})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetConfig(ctx, "public", "DEFAULT_GROUP", "db_url"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&gets); n != 1 {
+		t.Errorf("expected 1 server request, got %d", n)
+	}
+
+	// A watch event replaces the cached value without a request
+	c.deliver(ctx, newEvent(model.EventPut, &model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: "postgres://new", Version: 2}), func(*model.ConfigEvent) {})
+	cfg, _ := c.GetConfig(ctx, "public", "DEFAULT_GROUP", "db_url")
+	if cfg.Value != "postgres://new" || atomic.LoadInt32(&gets) != 1 {
+		t.Errorf("cache not refreshed by watch event: %+v", cfg)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.GetConfig(ctx, "public", "DEFAULT_GROUP", "db_url"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&gets); n != 2 {
+		t.Errorf("expected expired entry to be fetched again, got %d requests", n)
+	}
+}
+
+// TestDedupeCallbacks tests that identical re-deliveries do not invoke the callback
+func TestDedupeCallbacks(t *testing.T) {
+	var got []string
+	callback := dedupe(configCallback(func(cfg *model.Config) { got = append(got, fmt.Sprintf("%s=%s", cfg.Key, cfg.Value)) }))
+
+	for _, cfg := range []*model.Config{
+		{Key: "a", Value: "1", Version: 1},
+		{Key: "a", Value: "1", Version: 1}, // re-notify after reconnect
+		{Key: "b", Value: "1", Version: 1},
+		{Key: "a", Value: "1", Version: 2}, // saved unchanged
+		{Key: "a", Value: "2", Version: 3},
+		{Key: "a", Value: "2", Version: -1},
+	} {
+		callback(newEvent("", cfg))
+	}
+
+	want := []string{"a=1", "b=1", "a=2", "a=2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+// TestWaitForConfig tests that WaitForConfig returns once a missing config is created
+func TestWaitForConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/watch") {
+			io.Copy(io.Discard, r.Body)
+			time.Sleep(50 * time.Millisecond)
+			json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: "postgres://db", Version: 1})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "config not found"})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, DisableWatchMultiplexing: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	cfg, err := c.WaitForConfig(ctx, "public", "DEFAULT_GROUP", "db_url")
+	if err != nil {
+		t.Fatalf("WaitForConfig failed: %v", err)
+	}
+	if cfg.Value != "postgres://db" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if _, err := c.GetConfig(ctx, "public", "DEFAULT_GROUP", "db_url"); !missing(err) {
+		t.Errorf("expected a not found APIError, got %v", err)
+	}
+}
+
+// TestWatchSendsVersion tests that a key watch passes the last delivered
+// version so the server can answer at once with a missed change
+func TestWatchSendsVersion(t *testing.T) {
+	versions := make(chan string, 10)
+	var polls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/watch") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		versions <- r.URL.Query().Get("version")
+		n := atomic.AddInt32(&polls, 1)
+		if n > 2 {
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: fmt.Sprint(n), Version: int64(n) * 10})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, DisableWatchMultiplexing: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := c.WatchConfig(ctx, "public", "DEFAULT_GROUP", "db_url", func(*model.Config) {})
+	defer w.Stop()
+
+	for _, want := range []string{"", "10", "20"} {
+		select {
+		case got := <-versions:
+			if got != want {
+				t.Errorf("watch sent version %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for watch with version %q", want)
+		}
+	}
+}
+
+// TestWatchWait tests that WatchWait is asked for on multiplexed and single
+// long polls, and lengthens the default watch timeout to outlast it
+func TestWatchWait(t *testing.T) {
+	waits := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		waits <- r.URL.Path + "?" + r.URL.RawQuery
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	for path, disableMux := range map[string]bool{
+		"/api/v1/watch?wait=55s": false,
+		"/api/v1/namespaces/public/groups/DEFAULT_GROUP/configs/db_url/watch?wait=55s": true,
+	} {
+		c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, WatchWait: 55 * time.Second, DisableWatchMultiplexing: disableMux})
+		if c.config.WatchTimeout != 65*time.Second {
+			t.Errorf("watch timeout = %v, want 65s", c.config.WatchTimeout)
+		}
+		w := c.WatchConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url", func(*model.Config) {})
+		select {
+		case got := <-waits:
+			if got != path {
+				t.Errorf("watch polled %s, want %s", got, path)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for poll of %s", path)
+		}
+		w.Stop()
+	}
+}
+
+// TestNamespaceEvents tests paging through a namespace change feed by cursor
+func TestNamespaceEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/events" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Query().Get("since") {
+		case "":
+			json.NewEncoder(w).Encode(EventPage{Events: []*model.ConfigHistory{{ID: 1, Key: "a"}, {ID: 2, Key: "b"}}, Cursor: "2", HasMore: true})
+		case "2":
+			json.NewEncoder(w).Encode(EventPage{Events: []*model.ConfigHistory{{ID: 5, Key: "c"}}, Cursor: "5"})
+		default:
+			json.NewEncoder(w).Encode(EventPage{Events: []*model.ConfigHistory{}, Cursor: r.URL.Query().Get("since")})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL})
+	var keys []string
+	cursor := ""
+	for {
+		page, err := c.NamespaceEvents(context.Background(), "prod", cursor, 2)
+		if err != nil {
+			t.Fatalf("NamespaceEvents failed: %v", err)
+		}
+		for _, e := range page.Events {
+			keys = append(keys, e.Key)
+		}
+		cursor = page.Cursor
+		if !page.HasMore {
+			break
+		}
+	}
+	if fmt.Sprint(keys) != "[a b c]" || cursor != "5" {
+		t.Errorf("got events %v and cursor %q, want [a b c] and 5", keys, cursor)
+	}
+}
+
+// TestWatchConfigEvents tests that watch callbacks receive the event type,
+// including from servers that send bare configs
+func TestWatchConfigEvents(t *testing.T) {
+	var polls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/watch") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		cfg := model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url"}
+		switch atomic.AddInt32(&polls, 1) {
+		case 1:
+			cfg.Value, cfg.Version = "postgres://new", 2
+			json.NewEncoder(w).Encode(cfg) // older server
+		case 2:
+			cfg.Value, cfg.Version = "postgres://old", 3
+			json.NewEncoder(w).Encode(model.ConfigEvent{Type: model.EventRollback, Config: &cfg})
+		case 3:
+			cfg.Version = -1
+			json.NewEncoder(w).Encode(model.ConfigEvent{Type: model.EventDelete, Config: &cfg})
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, DisableWatchMultiplexing: true})
+	events := make(chan string, 10)
+	w := c.WatchConfigEvents(context.Background(), "public", "DEFAULT_GROUP", "db_url", func(e *model.ConfigEvent) {
+		events <- fmt.Sprintf("%s %d", e.Type, e.Config.Version)
+	})
+	defer w.Stop()
+
+	for _, want := range []string{"PUT 2", "ROLLBACK 3", "DELETE -1"} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("got event %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+}
+
+// TestWatchNamespace tests watching every config of a namespace, against
+// a server and the mock
+func TestWatchNamespace(t *testing.T) {
+	var polls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/watch" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if atomic.AddInt32(&polls, 1) > 1 {
+			<-r.Context().Done()
+			return
+		}
+		cfg := model.Config{Namespace: "prod", Group: "billing", Key: "rate", Value: "5", Version: 1}
+		json.NewEncoder(w).Encode(model.ConfigEvent{Type: model.EventPut, Config: &cfg})
+	}))
+	defer srv.Close()
+
+	clients := []ConfigClient{NewClientWithConfig(ClientConfig{Endpoint: srv.URL}), NewMockClient()}
+	for _, c := range clients {
+		changes := make(chan string, 10)
+		w := c.WatchNamespace(context.Background(), "prod", func(cfg *model.Config) {
+			changes <- cfg.Group + "/" + cfg.Key
+		})
+		if m, ok := c.(*MockClient); ok {
+			m.TriggerChange(&model.Config{Namespace: "staging", Group: "billing", Key: "rate"})
+			m.TriggerChange(&model.Config{Namespace: "prod", Group: "billing", Key: "rate"})
+		}
+		select {
+		case got := <-changes:
+			if got != "billing/rate" {
+				t.Errorf("%T: got change to %s, want billing/rate", c, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("%T: timed out waiting for a change", c)
+		}
+		w.Stop()
+	}
+}
+
+// TestHistoryRollback tests listing history and previewing and applying a rollback
+func TestHistoryRollback(t *testing.T) {
+	var rolledBack bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/namespaces/public/groups/DEFAULT_GROUP/configs/db_url/history":
+			json.NewEncoder(w).Encode([]model.ConfigHistory{{ID: 1, Key: "db_url", Value: "postgres://old", Version: 1, OpType: "CREATE"}})
+		case "/api/v1/namespaces/public/groups/DEFAULT_GROUP/configs/db_url/rollback":
+			if r.URL.Query().Get("dryRun") == "true" {
+				json.NewEncoder(w).Encode(RollbackPreview{Key: "db_url", TargetVersion: 1, Value: "postgres://old", CurrentVersion: 2})
+				return
+			}
+			rolledBack = true
+			json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: "postgres://old", Version: 3})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+
+	histories, err := c.ListHistory(ctx, "public", "DEFAULT_GROUP", "db_url")
+	if err != nil || len(histories) != 1 || histories[0].Version != 1 {
+		t.Fatalf("ListHistory = %+v, %v", histories, err)
+	}
+
+	preview, err := c.PreviewRollback(ctx, "public", "DEFAULT_GROUP", "db_url", 1)
+	if err != nil || preview.CurrentVersion != 2 || rolledBack {
+		t.Fatalf("PreviewRollback = %+v, %v (rolled back: %v)", preview, err, rolledBack)
+	}
+
+	cfg, err := c.Rollback(ctx, "public", "DEFAULT_GROUP", "db_url", 1)
+	if err != nil || cfg.Version != 3 || !rolledBack {
+		t.Fatalf("Rollback = %+v, %v", cfg, err)
+	}
+}
+
+// TestBinaryConfig tests that binary values round-trip as base64 with their
+// content type
+func TestBinaryConfig(t *testing.T) {
+	var stored model.Config
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			json.NewDecoder(r.Body).Decode(&stored)
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(stored)
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL})
+	ctx := context.Background()
+	data := []byte{0x30, 0x82, 0x00, 0xff}
+	if _, err := c.PutBinaryConfig(ctx, "prod", "tls", "ca.der", data, "application/pkix-cert"); err != nil {
+		t.Fatalf("PutBinaryConfig failed: %v", err)
+	}
+	if stored.Type != "binary" || stored.Value != "MIIA/w==" {
+		t.Fatalf("sent type %q and value %q, want binary and MIIA/w==", stored.Type, stored.Value)
+	}
+
+	got, contentType, err := c.GetBinaryConfig(ctx, "prod", "tls", "ca.der")
+	if err != nil {
+		t.Fatalf("GetBinaryConfig failed: %v", err)
+	}
+	if !bytes.Equal(got, data) || contentType != "application/pkix-cert" {
+		t.Errorf("got %x with content type %q, want %x with application/pkix-cert", got, contentType, data)
+	}
+}
+
+// TestListConfigChecksums tests that checksum listings ask the server to
+// leave out values
+func TestListConfigChecksums(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("values") != "false" {
+			t.Errorf("request %s does not set values=false", r.URL)
+		}
+		json.NewEncoder(w).Encode([]*model.Config{{Key: "rate", SHA256: "abc", Size: 3}})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL})
+	configs, err := c.ListConfigChecksums(context.Background(), "prod", "billing")
+	if err != nil {
+		t.Fatalf("ListConfigChecksums failed: %v", err)
+	}
+	if len(configs) != 1 || configs[0].SHA256 != "abc" || configs[0].Size != 3 {
+		t.Errorf("got %+v", configs)
+	}
+}
+
+// secretStub is a SecretResolver serving secrets from a map
+type secretStub map[string]string
+
+func (s secretStub) Resolve(ctx context.Context, value string) (string, error) {
+	if secret, ok := s[value]; ok {
+		return secret, nil
+	}
+	return value, nil
+}
+
+// TestSecretResolver tests that a client with a SecretResolver asks the
+// server for the reference and resolves it itself
+func TestSecretResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("resolve") != "false" {
+			t.Errorf("request %s does not set resolve=false", r.URL)
+		}
+		json.NewEncoder(w).Encode(&model.Config{Namespace: "prod", Group: "db", Key: "password", Value: "vault:secret/data/db#password"})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{
+		Endpoint:       srv.URL,
+		SecretResolver: secretStub{"vault:secret/data/db#password": "s3cret"},
+	})
+	cfg, err := c.GetConfig(context.Background(), "prod", "db", "password")
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if cfg.Value != "s3cret" {
+		t.Errorf("got value %q, want s3cret", cfg.Value)
+	}
+}
+
+// TestSignedConfigs tests that a client with a SigningKey accepts configs
+// signed by the server and rejects tampered ones
+func TestSignedConfigs(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	cfg := &model.Config{Namespace: "prod", Group: "billing", Key: "rate", Value: "0.2", Type: "text", Version: 7}
+	cfg.Signature = signing.Payload{Namespace: "prod", Group: "billing", Key: "rate", Version: 7, Type: "text", Value: "0.2"}.Sign(key)
+	tampered := *cfg
+	tampered.Key, tampered.Value = "limit", "1000"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/limit") {
+			json.NewEncoder(w).Encode(&tampered)
+			return
+		}
+		json.NewEncoder(w).Encode(cfg)
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL, SigningKey: key})
+	ctx := context.Background()
+	if got, err := c.GetConfig(ctx, "prod", "billing", "rate"); err != nil || got.Value != "0.2" {
+		t.Errorf("GetConfig = %v, %v, want the signed value", got, err)
+	}
+	if _, err := c.GetConfig(ctx, "prod", "billing", "limit"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("GetConfig of a tampered config = %v, want ErrInvalidSignature", err)
+	}
+}
+
+// TestCreateScopedToken tests that scoped tokens are requested with their
+// scopes and lifetime
+func TestCreateScopedToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name   string       `json:"name"`
+			Scopes []TokenScope `json:"scopes"`
+			TTL    string       `json:"ttl"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/v1/admin/tokens" || req.TTL != "24h0m0s" || len(req.Scopes) != 1 || req.Scopes[0].Group != "billing" {
+			t.Errorf("got %s %+v", r.URL.Path, req)
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"id": "abc", "name": req.Name, "scopes": req.Scopes, "token": "jwt"})
+	}))
+	defer srv.Close()
+
+	c := NewClientWithConfig(ClientConfig{Endpoint: srv.URL})
+	token, err := c.CreateScopedToken(context.Background(), "billing-app", []TokenScope{{Namespace: "prod", Group: "billing"}}, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CreateScopedToken failed: %v", err)
+	}
+	if token.ID != "abc" || token.Token != "jwt" {
+		t.Errorf("got %+v", token)
+	}
+}
+
+// TestWatchErrors tests that watch failures are reported on the handle's error channel
+func TestWatchErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	for _, disable := range []bool{true, false} {
+		c := NewClientWithConfig(ClientConfig{
+			Endpoint:                 srv.URL,
+			DisableWatchMultiplexing: disable,
+			Retry:                    RetryPolicy{InitialBackoff: time.Millisecond},
+		})
+		w := c.WatchConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url", func(*model.Config) {})
+
+		select {
+		case err := <-w.Errors():
+			var watchErr *WatchError
+			if !errors.As(err, &watchErr) || watchErr.StatusCode != http.StatusForbidden || watchErr.Key != "db_url" {
+				t.Errorf("unexpected watch error: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Errorf("no error reported (multiplexing disabled: %v)", disable)
+		}
+		w.Stop()
+	}
+}
+
+// TestProactiveTokenRefresh tests that concurrent requests share one refresh of a token about to expire
+func TestProactiveTokenRefresh(t *testing.T) {
+	var refreshes, unauthorized int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/login":
+			json.NewEncoder(w).Encode(TokenResponse{AccessToken: "token-1", RefreshToken: "refresh", ExpiresIn: 1})
+		case "/api/v1/refresh":
+			atomic.AddInt32(&refreshes, 1)
+			time.Sleep(20 * time.Millisecond)
+			json.NewEncoder(w).Encode(TokenResponse{AccessToken: "token-2", RefreshToken: "refresh", ExpiresIn: 3600})
+		default:
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				atomic.AddInt32(&unauthorized, 1)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(model.Config{Key: "db_url", Value: "postgres://db"})
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	if err := c.Login(context.Background(), "admin", "admin"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	// Past the refresh point but before the token expires
+	time.Sleep(600 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetConfig(context.Background(), "public", "DEFAULT_GROUP", "db_url"); err != nil {
+				t.Errorf("GetConfig failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&refreshes); n != 1 {
+		t.Errorf("expected 1 refresh, got %d", n)
+	}
+	if n := atomic.LoadInt32(&unauthorized); n != 0 {
+		t.Errorf("expected no requests with the expiring token, got %d", n)
+	}
+}
+
+// TestMockClient tests that the mock delivers triggered changes to key and group watches
+func TestMockClient(t *testing.T) {
+	var c ConfigClient = NewMockClient()
+	mock := c.(*MockClient)
+	ctx := context.Background()
+
+	mock.Set("public", "DEFAULT_GROUP", "db_url", "postgres://db", "")
+	cfg, err := c.GetConfig(ctx, "public", "DEFAULT_GROUP", "db_url")
+	if err != nil || cfg.Value != "postgres://db" || cfg.Type != "text" {
+		t.Fatalf("GetConfig = %+v, %v", cfg, err)
+	}
+	if _, err := c.GetConfig(ctx, "public", "DEFAULT_GROUP", "missing"); !missing(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	var keyChanges, groupChanges []string
+	w := c.WatchConfig(ctx, "public", "DEFAULT_GROUP", "db_url", func(cfg *model.Config) { keyChanges = append(keyChanges, cfg.Value) })
+	c.WatchGroup(ctx, "public", "DEFAULT_GROUP", func(cfg *model.Config) { groupChanges = append(groupChanges, cfg.Key) })
+
+	mock.TriggerChange(&model.Config{Namespace: "public", Group: "DEFAULT_GROUP", Key: "db_url", Value: "postgres://new"})
+	c.PutConfig(ctx, "public", "DEFAULT_GROUP", "timeout", "5s", "")
+	c.DeleteConfig(ctx, "public", "DEFAULT_GROUP", "db_url")
+
+	if fmt.Sprint(keyChanges) != "[postgres://new ]" {
+		t.Errorf("key watch received %q", keyChanges)
+	}
+	if fmt.Sprint(groupChanges) != "[db_url timeout db_url]" {
+		t.Errorf("group watch received %q", groupChanges)
+	}
+
+	mock.TriggerError("public", "DEFAULT_GROUP", "db_url", errors.New("connection refused"))
+	select {
+	case err := <-w.Errors():
+		if !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	default:
+		t.Error("TriggerError did not reach the watch")
+	}
+	w.Stop()
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hashicorp/hcl"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// Unmarshal decodes the value of a config into target according to its Type.
+// JSON values use `json` struct tags; YAML and properties values use `yaml`
+// struct tags, with dotted property keys (db.host) mapped to nested fields.
+// INI values also use `yaml` tags, with each section ([db] or [db.pool])
+// mapped to a nested field. TOML and HCL values use `toml` and `hcl` tags.
+// Binary values can only be decoded into a *[]byte and text values only into
+// a *string.
+func Unmarshal(cfg *model.Config, target any) error {
+	switch cfg.Type {
+	case "json":
+		return json.Unmarshal([]byte(cfg.Value), target)
+	case "yaml", "yml":
+		return yaml.Unmarshal([]byte(cfg.Value), target)
+	case "properties":
+		node, err := propertiesToNode(cfg.Value)
+		if err != nil {
+			return err
+		}
+		return node.Decode(target)
+	case "ini":
+		node, err := iniToNode(cfg.Value)
+		if err != nil {
+			return err
+		}
+		return node.Decode(target)
+	case "toml":
+		return toml.Unmarshal([]byte(cfg.Value), target)
+	case "hcl":
+		return hcl.Unmarshal([]byte(cfg.Value), target)
+	case "binary":
+		b, ok := target.(*[]byte)
+		if !ok {
+			return fmt.Errorf("cannot unmarshal binary config into %T", target)
+		}
+		data, err := decodeBinary(cfg)
+		if err != nil {
+			return err
+		}
+		*b = data
+		return nil
+	default:
+		if s, ok := target.(*string); ok {
+			*s = cfg.Value
+			return nil
+		}
+		return fmt.Errorf("cannot unmarshal config of type %q into %T", cfg.Type, target)
+	}
+}
+
+// GetConfigInto retrieves a config and decodes its value into target
+func (c *Client) GetConfigInto(ctx context.Context, namespace, group, key string, target any) error {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(cfg, target)
+}
+
+// ConfigValue holds the latest decoded value of a watched config. It is safe for concurrent use.
+type ConfigValue[T any] struct {
+	value atomic.Pointer[T]
+}
+
+// Load returns the current decoded value. Callers must treat it as read-only.
+func (v *ConfigValue[T]) Load() *T {
+	return v.value.Load()
+}
+
+// WatchConfigInto decodes a config into a new T and keeps it up to date as the
+// config changes until ctx is cancelled, swapping in each newly decoded value
+// atomically. onChange, if not nil, is called with every new value. Updates
+// that fail to decode are logged and leave the current value in place.
+func WatchConfigInto[T any](ctx context.Context, c *Client, namespace, group, key string, onChange func(*T)) (*ConfigValue[T], error) {
+	initial := new(T)
+	if err := c.GetConfigInto(ctx, namespace, group, key, initial); err != nil {
+		return nil, err
+	}
+
+	holder := &ConfigValue[T]{}
+	holder.value.Store(initial)
+
+	c.WatchConfig(ctx, namespace, group, key, func(cfg *model.Config) {
+		if cfg.Version == -1 {
+			// Deleted: keep serving the last value
+			return
+		}
+		next := new(T)
+		if err := Unmarshal(cfg, next); err != nil {
+			c.logger.Errorf("Failed to decode %s/%s/%s: %v", namespace, group, key, err)
+			return
+		}
+		holder.value.Store(next)
+		if onChange != nil {
+			onChange(next)
+		}
+	})
+
+	return holder, nil
+}
+
+// propertiesToNode parses Java-style properties into a YAML mapping node so it
+// can be decoded into structs with the usual type conversions
+func propertiesToNode(text string) (*yaml.Node, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid properties line %q", line)
+		}
+		key := strings.TrimSpace(line[:sep])
+		value := strings.TrimSpace(line[sep+1:])
+
+		if err := setPropertyNode(root, strings.Split(key, "."), value); err != nil {
+			return nil, fmt.Errorf("property %s: %w", key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// iniToNode parses an INI document into a YAML mapping node. Keys of the
+// default section are top-level; dotted section names nest like dotted
+// property keys.
+func iniToNode(text string) (*yaml.Node, error) {
+	file, err := ini.Load([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, section := range file.Sections() {
+		var prefix []string
+		if section.Name() != ini.DefaultSection {
+			prefix = strings.Split(section.Name(), ".")
+		}
+		for _, key := range section.Keys() {
+			path := append(append([]string(nil), prefix...), key.Name())
+			if err := setPropertyNode(root, path, key.Value()); err != nil {
+				return nil, fmt.Errorf("%s: %w", strings.Join(path, "."), err)
+			}
+		}
+	}
+	return root, nil
+}
+
+// setPropertyNode stores value under the nested path of mapping nodes
+func setPropertyNode(mapping *yaml.Node, path []string, value string) error {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		child := mapping.Content[i+1]
+		if len(path) == 1 {
+			if child.Kind != yaml.ScalarNode {
+				return fmt.Errorf("conflicts with nested properties")
+			}
+			child.Value = value
+			return nil
+		}
+		if child.Kind != yaml.MappingNode {
+			return fmt.Errorf("conflicts with scalar property %s", path[0])
+		}
+		return setPropertyNode(child, path[1:], value)
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, keyNode, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+		return nil
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, keyNode, child)
+	return setPropertyNode(child, path[1:], value)
+}
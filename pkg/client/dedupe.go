@@ -0,0 +1,43 @@
+package client
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// dedupe wraps a watch callback so it is skipped when a config is identical
+// to the last one delivered for the same key, as happens after reconnects
+// and re-notifies. Version and event type are ignored: saving an unchanged
+// value or rolling back to it does not warrant a reload.
+func dedupe(callback func(*model.ConfigEvent)) func(*model.ConfigEvent) {
+	var mu sync.Mutex
+	last := make(map[watchTarget][sha256.Size]byte)
+
+	return func(event *model.ConfigEvent) {
+		cfg := event.Config
+		t := watchTarget{cfg.Namespace, cfg.Group, cfg.Key}
+		h := sha256.New()
+		if event.Type == model.EventDelete {
+			h.Write([]byte{0})
+		} else {
+			h.Write([]byte{1})
+			h.Write([]byte(cfg.Type))
+			h.Write([]byte{0})
+			h.Write([]byte(cfg.Value))
+		}
+		var sum [sha256.Size]byte
+		h.Sum(sum[:0])
+
+		mu.Lock()
+		prev, seen := last[t]
+		last[t] = sum
+		mu.Unlock()
+
+		if seen && prev == sum {
+			return
+		}
+		callback(event)
+	}
+}
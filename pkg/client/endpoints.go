@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointSet tracks the server URLs a client can talk to and which one is
+// in use. The client stays on the current endpoint until it fails, then
+// moves to the next endpoint that is believed healthy.
+type endpointSet struct {
+	mu      sync.Mutex
+	urls    []string
+	healthy []bool
+	current int
+	logger  Logger
+}
+
+// parseEndpoints splits a comma-separated list of server URLs
+func parseEndpoints(endpoint string) []string {
+	var urls []string
+	for _, u := range strings.Split(endpoint, ",") {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		urls = []string{""}
+	}
+	return urls
+}
+
+func newEndpointSet(urls []string, logger Logger) *endpointSet {
+	healthy := make([]bool, len(urls))
+	for i := range healthy {
+		healthy[i] = true
+	}
+	return &endpointSet{urls: urls, healthy: healthy, logger: logger}
+}
+
+// get returns the endpoint currently in use
+func (e *endpointSet) get() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.urls[e.current]
+}
+
+// report records the outcome of a request sent to url. Transport errors and
+// 5xx responses mark the endpoint unhealthy and, if it is the one in use,
+// fail over to the next healthy endpoint, or simply the next one in turn if
+// none are known to be healthy.
+func (e *endpointSet) report(url string, resp *http.Response, err error) {
+	if err == nil && resp.StatusCode < http.StatusInternalServerError {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.urls) < 2 {
+		return
+	}
+	for i, u := range e.urls {
+		if u == url {
+			e.healthy[i] = false
+		}
+	}
+	if e.urls[e.current] != url {
+		// Another request already failed over
+		return
+	}
+	next := (e.current + 1) % len(e.urls)
+	for i := 1; i < len(e.urls); i++ {
+		if j := (e.current + i) % len(e.urls); e.healthy[j] {
+			next = j
+			break
+		}
+	}
+	e.logger.Warnf("Endpoint %s failed, failing over to %s", url, e.urls[next])
+	e.current = next
+}
+
+// unhealthy returns the endpoints currently marked unhealthy
+func (e *endpointSet) unhealthy() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var urls []string
+	for i, u := range e.urls {
+		if !e.healthy[i] {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// list returns a copy of the known endpoints
+func (e *endpointSet) list() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.urls...)
+}
+
+// set replaces the known endpoints. Endpoints that remain keep their health,
+// and the one in use stays current if it is still present.
+func (e *endpointSet) set(urls []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if strings.Join(urls, ",") == strings.Join(e.urls, ",") {
+		return
+	}
+	previous := make(map[string]bool, len(e.urls))
+	for i, u := range e.urls {
+		previous[u] = e.healthy[i]
+	}
+	current := e.urls[e.current]
+
+	healthy := make([]bool, len(urls))
+	next := 0
+	for i, u := range urls {
+		if h, ok := previous[u]; ok {
+			healthy[i] = h
+		} else {
+			healthy[i] = true
+		}
+		if u == current {
+			next = i
+		}
+	}
+	e.logger.Infof("Endpoints changed from %v to %v", e.urls, urls)
+	e.urls, e.healthy, e.current = urls, healthy, next
+}
+
+// markHealthy puts url back into rotation
+func (e *endpointSet) markHealthy(url string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, u := range e.urls {
+		if u == url {
+			e.healthy[i] = true
+		}
+	}
+}
+
+// healthCheck probes unhealthy endpoints every interval until ctx is
+// cancelled, returning those that answer to rotation
+func (c *Client) healthCheck(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, url := range c.endpoints.unhealthy() {
+			if c.probe(ctx, url) {
+				c.logger.Infof("Endpoint %s is healthy again", url)
+				c.endpoints.markHealthy(url)
+			}
+		}
+	}
+}
+
+// probe reports whether the server at url answers its public stats endpoint
+func (c *Client) probe(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/api/v1/stats", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeyProvider wraps and unwraps the data keys of envelope-encrypted values
+// with a master key it holds or reaches remotely, such as a local key file,
+// a cloud KMS or Vault transit. Implementations must be safe for concurrent
+// use.
+type KeyProvider interface {
+	// Name identifies the provider in encrypted values, e.g. "awskms"
+	Name() string
+	// KeyID identifies the master key that new data keys are wrapped with
+	KeyID() string
+	// WrapKey encrypts a data key with the current master key
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped with the master key keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// envelope is the decoded form of an envelope-encrypted value. The value is
+// sealed with a random AES-256-GCM data key, which is stored wrapped by the
+// named provider's master key.
+type envelope struct {
+	Provider   string `json:"provider"`
+	KeyID      string `json:"key_id"`
+	DataKey    []byte `json:"data_key"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Envelope encrypts config values with a fresh data key per value, wrapped
+// by a KeyProvider. The provider name and key ID are stored with each value,
+// so values written under older keys or other providers stay readable after
+// a rotation. Envelope implements Decryptor for ClientConfig.
+type Envelope struct {
+	primary   KeyProvider
+	providers map[string]KeyProvider
+}
+
+// NewEnvelope creates an Envelope that encrypts with primary and can also
+// decrypt values wrapped by any of others, e.g. while migrating between
+// providers
+func NewEnvelope(primary KeyProvider, others ...KeyProvider) *Envelope {
+	e := &Envelope{primary: primary, providers: make(map[string]KeyProvider)}
+	for _, p := range others {
+		e.providers[p.Name()] = p
+	}
+	e.providers[primary.Name()] = primary
+	return e
+}
+
+// Encrypt returns plaintext as a config value in the cipher: format, ready
+// to be stored with PutConfig
+func (e *Envelope) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	a, err := NewAESGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := a.seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := e.primary.WrapKey(ctx, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("%s: wrap data key: %w", e.primary.Name(), err)
+	}
+	return encodeEnvelope(&envelope{Provider: e.primary.Name(), KeyID: e.primary.KeyID(), DataKey: wrapped, Ciphertext: sealed})
+}
+
+// Decrypt implements Decryptor
+func (e *Envelope) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(ciphertext, &env); err != nil {
+		return nil, fmt.Errorf("not an envelope-encrypted value: %w", err)
+	}
+	dataKey, err := e.unwrap(ctx, &env)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewAESGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return a.Decrypt(ctx, env.Ciphertext)
+}
+
+// Rotate rewraps the data key of an encrypted config value with the primary
+// provider's current key, leaving the sealed value itself untouched. Values
+// already wrapped by the current key are returned unchanged.
+func (e *Envelope) Rotate(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, CipherPrefix) {
+		return "", errors.New("value is not encrypted")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, CipherPrefix))
+	if err != nil {
+		return "", err
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return "", fmt.Errorf("not an envelope-encrypted value: %w", err)
+	}
+	if env.Provider == e.primary.Name() && env.KeyID == e.primary.KeyID() {
+		return value, nil
+	}
+	dataKey, err := e.unwrap(ctx, &env)
+	if err != nil {
+		return "", err
+	}
+	if env.DataKey, err = e.primary.WrapKey(ctx, dataKey); err != nil {
+		return "", fmt.Errorf("%s: wrap data key: %w", e.primary.Name(), err)
+	}
+	env.Provider, env.KeyID = e.primary.Name(), e.primary.KeyID()
+	return encodeEnvelope(&env)
+}
+
+// unwrap decrypts the data key of env with the provider that wrapped it
+func (e *Envelope) unwrap(ctx context.Context, env *envelope) ([]byte, error) {
+	p, ok := e.providers[env.Provider]
+	if !ok {
+		return nil, fmt.Errorf("no key provider %q configured", env.Provider)
+	}
+	dataKey, err := p.UnwrapKey(ctx, env.KeyID, env.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unwrap data key %s: %w", env.Provider, env.KeyID, err)
+	}
+	return dataKey, nil
+}
+
+func encodeEnvelope(env *envelope) (string, error) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return CipherPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// LocalKeys is a KeyProvider backed by AES keys held in memory, typically
+// loaded from a key file with LoadLocalKeys
+type LocalKeys struct {
+	current string
+	keys    map[string]*AESGCM
+}
+
+// NewLocalKeys creates a LocalKeys that wraps data keys with the key named
+// current. keys maps key IDs to 16, 24 or 32 byte AES keys; keep retired keys
+// in it so values wrapped by them can still be read.
+func NewLocalKeys(current string, keys map[string][]byte) (*LocalKeys, error) {
+	l := &LocalKeys{current: current, keys: make(map[string]*AESGCM, len(keys))}
+	for id, key := range keys {
+		a, err := NewAESGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		l.keys[id] = a
+	}
+	if _, ok := l.keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q not found", current)
+	}
+	return l, nil
+}
+
+// LoadLocalKeys reads a key file with one "<key-id> <base64 key>" pair per
+// line. The first key wraps new data keys; the others are kept to read
+// values written before a rotation. Blank lines and lines starting with #
+// are ignored.
+func LoadLocalKeys(path string) (*LocalKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var current string
+	keys := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: invalid key line, expected <key-id> <base64 key>", path)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %s: %w", path, fields[0], err)
+		}
+		if current == "" {
+			current = fields[0]
+		}
+		keys[fields[0]] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current == "" {
+		return nil, fmt.Errorf("%s: no keys", path)
+	}
+	return NewLocalKeys(current, keys)
+}
+
+// Name implements KeyProvider
+func (l *LocalKeys) Name() string { return "local" }
+
+// KeyID implements KeyProvider
+func (l *LocalKeys) KeyID() string { return l.current }
+
+// WrapKey implements KeyProvider
+func (l *LocalKeys) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	return l.keys[l.current].seal(dataKey)
+}
+
+// UnwrapKey implements KeyProvider
+func (l *LocalKeys) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	a, ok := l.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	return a.Decrypt(ctx, wrapped)
+}
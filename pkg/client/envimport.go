@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ImportFileOptions select how ImportFile reads a file
+type ImportFileOptions struct {
+	// Format is dotenv or properties
+	Format string
+	// SingleKey stores the whole file as one properties config of this key
+	// instead of one text config per entry
+	SingleKey string
+	// DryRun reports what would change without writing
+	DryRun bool
+}
+
+// ImportResult reports what importing one entry did
+type ImportResult struct {
+	Key    string `json:"key"`
+	Action string `json:"action"` // create, update or unchanged
+}
+
+// ImportFile imports a dotenv or properties file into a group, creating or
+// updating one config per entry, or a single properties config if
+// opts.SingleKey is set
+func (c *Client) ImportFile(ctx context.Context, namespace, group string, data []byte, opts ImportFileOptions) ([]ImportResult, error) {
+	query := url.Values{"format": {opts.Format}}
+	if opts.SingleKey != "" {
+		query.Set("mode", "single")
+		query.Set("key", opts.SingleKey)
+	}
+	if opts.DryRun {
+		query.Set("dryRun", "true")
+	}
+
+	var results []ImportResult
+	path := fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/import?%s", namespace, group, query.Encode())
+	if err := c.doBody(ctx, http.MethodPost, path, data, &results, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ExportGroup flattens all configs of a group into one file of the given
+// format, dotenv, properties, yaml or json, with secret references resolved
+func (c *Client) ExportGroup(ctx context.Context, namespace, group, format string) ([]byte, error) {
+	startTime := time.Now()
+	path := fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/export?%s", namespace, group, url.Values{"format": {format}}.Encode())
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		c.updateStats(startTime, false)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.updateStats(startTime, false)
+		var res struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: res.Error}
+	}
+	data, err := io.ReadAll(resp.Body)
+	c.updateStats(startTime, err == nil)
+	return data, err
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// WatchConfigEvents is like WatchConfig but hands the callback the full
+// change event, so it can tell a PUT from a DELETE or ROLLBACK
+func (c *Client) WatchConfigEvents(ctx context.Context, namespace, group, key string, callback func(*model.ConfigEvent)) *Watch {
+	target := watchTarget{namespace, group, key}
+	callback = dedupe(callback)
+	if c.mux != nil {
+		return c.mux.add(ctx, target, callback)
+	}
+	return c.watchTarget(ctx, target, callback, nil)
+}
+
+// WatchGroupEvents is like WatchGroup but hands the callback the full
+// change event of every changed config in the group
+func (c *Client) WatchGroupEvents(ctx context.Context, namespace, group string, callback func(*model.ConfigEvent)) *Watch {
+	t := watchTarget{Namespace: namespace, Group: group}
+	return c.watch(ctx, t, fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/watch", namespace, group), dedupe(callback), nil)
+}
+
+// WatchNamespaceEvents watches for changes to any configuration item in a
+// namespace until ctx is cancelled or the returned Watch is stopped, so an
+// agent can react to every change without enumerating groups and keys
+func (c *Client) WatchNamespaceEvents(ctx context.Context, namespace string, callback func(*model.ConfigEvent)) *Watch {
+	t := watchTarget{Namespace: namespace}
+	return c.watch(ctx, t, fmt.Sprintf("/api/v1/namespaces/%s/watch", namespace), dedupe(callback), nil)
+}
+
+// configCallback adapts a config callback to receive change events
+func configCallback(callback func(*model.Config)) func(*model.ConfigEvent) {
+	return func(event *model.ConfigEvent) {
+		callback(event.Config)
+	}
+}
+
+// newEvent wraps a config in a change event of the given type. Servers that
+// predate event types send bare configs, so an empty type is inferred from
+// the version.
+func newEvent(eventType string, cfg *model.Config) *model.ConfigEvent {
+	if eventType == "" {
+		eventType = model.EventPut
+		if cfg.Version == -1 {
+			eventType = model.EventDelete
+		}
+	}
+	return &model.ConfigEvent{Type: eventType, Config: cfg}
+}
+
+// readEvent reads a change event from a watch response body
+func readEvent(r io.Reader) (*model.ConfigEvent, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	return decodeEvent(raw)
+}
+
+// decodeEvent decodes a change event from a watch response, accepting the
+// bare config sent by older servers
+func decodeEvent(data []byte) (*model.ConfigEvent, error) {
+	var event model.ConfigEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	if event.Config != nil {
+		return newEvent(event.Type, event.Config), nil
+	}
+	var cfg model.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return newEvent("", &cfg), nil
+}
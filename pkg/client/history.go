@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// HistoryDiff is the change recorded by one history entry, as a unified diff
+// against the entry before it
+type HistoryDiff struct {
+	ID              int64  `json:"id"`
+	Version         int64  `json:"version"`
+	OpType          string `json:"op_type"`
+	PreviousVersion int64  `json:"previous_version"`
+	Diff            string `json:"diff"`
+}
+
+// RollbackPreview describes what a rollback would change without applying it
+type RollbackPreview struct {
+	Namespace      string `json:"namespace"`
+	Group          string `json:"group"`
+	Key            string `json:"key"`
+	TargetVersion  int64  `json:"target_version"`
+	Value          string `json:"value"`
+	Type           string `json:"type"`
+	CurrentVersion int64  `json:"current_version"`
+	CurrentType    string `json:"current_type"`
+	Diff           string `json:"diff"`
+}
+
+// ListHistory lists the change history of a configuration item
+func (c *Client) ListHistory(ctx context.Context, namespace, group, key string) ([]*model.ConfigHistory, error) {
+	var histories []*model.ConfigHistory
+	if err := c.doJSON(ctx, http.MethodGet, configPath(namespace, group, key, "history"), nil, &histories, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
+
+// GetHistoryDiff returns the change made by the history entry with the given ID
+func (c *Client) GetHistoryDiff(ctx context.Context, namespace, group, key string, id int64) (*HistoryDiff, error) {
+	var diff HistoryDiff
+	path := configPath(namespace, group, key, "history", fmt.Sprint(id), "diff")
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &diff, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// Rollback restores a configuration item to the value it had at version
+func (c *Client) Rollback(ctx context.Context, namespace, group, key string, version int64) (*model.Config, error) {
+	req := map[string]int64{"version": version}
+	var cfg model.Config
+	err := c.doJSON(ctx, http.MethodPost, configPath(namespace, group, key, "rollback"), req, &cfg, http.StatusOK)
+	c.cache.invalidate(namespace, group, key)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// PreviewRollback returns what Rollback to version would change, without
+// writing anything or notifying watchers
+func (c *Client) PreviewRollback(ctx context.Context, namespace, group, key string, version int64) (*RollbackPreview, error) {
+	req := map[string]int64{"version": version}
+	var preview RollbackPreview
+	path := configPath(namespace, group, key, "rollback") + "?dryRun=true"
+	if err := c.doJSON(ctx, http.MethodPost, path, req, &preview, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// NamespaceChanges lists the changes to any config of a namespace made
+// since the given time, newest first. A zero limit uses the server default.
+func (c *Client) NamespaceChanges(ctx context.Context, namespace string, since time.Time, limit int) ([]*model.ConfigHistory, error) {
+	query := url.Values{"since": {since.UTC().Format(time.RFC3339Nano)}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/api/v1/namespaces/" + namespace + "/changes?" + query.Encode()
+	var changes []*model.ConfigHistory
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &changes, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// EventPage is one page of a namespace change feed
+type EventPage struct {
+	Events []*model.ConfigHistory `json:"events"`
+	// Cursor is passed as since to fetch the events after this page
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// NamespaceEvents lists the changes to a namespace after cursor, oldest
+// first. An empty cursor starts from the first recorded change and a zero
+// limit uses the server default.
+func (c *Client) NamespaceEvents(ctx context.Context, namespace, cursor string, limit int) (*EventPage, error) {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set("since", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/api/v1/namespaces/" + namespace + "/events?" + query.Encode()
+	var page EventPage
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &page, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// VaultTransit is a KeyProvider that wraps data keys with a key of a
+// HashiCorp Vault transit secrets engine. Vault records the key version in
+// its ciphertext, so rotating the transit key needs no client changes.
+type VaultTransit struct {
+	Address    string       // Vault address, e.g. https://vault.example.com:8200
+	Token      string       // Vault token allowed to encrypt and decrypt with the key
+	Mount      string       // Mount path of the transit engine, "transit" if empty
+	Key        string       // Name of the transit key that wraps new data keys
+	HTTPClient *http.Client // HTTP client, http.DefaultClient if nil
+}
+
+// Name implements KeyProvider
+func (v *VaultTransit) Name() string { return "vault" }
+
+// KeyID implements KeyProvider
+func (v *VaultTransit) KeyID() string { return v.Key }
+
+// WrapKey implements KeyProvider
+func (v *VaultTransit) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var res struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := v.post(ctx, "encrypt/"+v.Key, map[string][]byte{"plaintext": dataKey}, &res); err != nil {
+		return nil, err
+	}
+	return []byte(res.Data.Ciphertext), nil
+}
+
+// UnwrapKey implements KeyProvider
+func (v *VaultTransit) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var res struct {
+		Data struct {
+			Plaintext []byte `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := v.post(ctx, "decrypt/"+keyID, map[string]string{"ciphertext": string(wrapped)}, &res); err != nil {
+		return nil, err
+	}
+	return res.Data.Plaintext, nil
+}
+
+func (v *VaultTransit) post(ctx context.Context, path string, body, out any) error {
+	mount := v.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+	url := strings.TrimRight(v.Address, "/") + "/v1/" + strings.Trim(mount, "/") + "/" + path
+	return postKeyService(ctx, v.HTTPClient, url, body, out, func(req *http.Request, _ []byte) error {
+		req.Header.Set("X-Vault-Token", v.Token)
+		return nil
+	})
+}
+
+// GCPKMS is a KeyProvider that wraps data keys with a Google Cloud KMS
+// symmetric key. Cloud KMS records the key version in its ciphertext, so
+// rotating the key needs no client changes.
+type GCPKMS struct {
+	// KeyName is the resource name of the key that wraps new data keys, e.g.
+	// projects/p/locations/global/keyRings/r/cryptoKeys/k
+	KeyName string
+	// TokenSource returns an OAuth2 access token for each request;
+	// GCPMetadataToken if nil
+	TokenSource func(ctx context.Context) (string, error)
+	Endpoint    string       // API endpoint, https://cloudkms.googleapis.com if empty
+	HTTPClient  *http.Client // HTTP client, http.DefaultClient if nil
+}
+
+// Name implements KeyProvider
+func (g *GCPKMS) Name() string { return "gcpkms" }
+
+// KeyID implements KeyProvider
+func (g *GCPKMS) KeyID() string { return g.KeyName }
+
+// WrapKey implements KeyProvider
+func (g *GCPKMS) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var res struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := g.post(ctx, g.KeyName+":encrypt", map[string][]byte{"plaintext": dataKey}, &res); err != nil {
+		return nil, err
+	}
+	return res.Ciphertext, nil
+}
+
+// UnwrapKey implements KeyProvider
+func (g *GCPKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var res struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := g.post(ctx, keyID+":decrypt", map[string][]byte{"ciphertext": wrapped}, &res); err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+func (g *GCPKMS) post(ctx context.Context, path string, body, out any) error {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+	tokenSource := g.TokenSource
+	if tokenSource == nil {
+		tokenSource = GCPMetadataToken
+	}
+	url := strings.TrimRight(endpoint, "/") + "/v1/" + path
+	return postKeyService(ctx, g.HTTPClient, url, body, out, func(req *http.Request, _ []byte) error {
+		token, err := tokenSource(ctx)
+		if err != nil {
+			return fmt.Errorf("access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// GCPMetadataToken fetches an access token for the default service account
+// from the GCE metadata server, as available on GCE, GKE and Cloud Run
+func GCPMetadataToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server: status %d", resp.StatusCode)
+	}
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+	return res.AccessToken, nil
+}
+
+// postKeyService POSTs body as JSON to a key service and decodes the JSON
+// response into out. prepare, if not nil, authenticates the request.
+func postKeyService(ctx context.Context, client *http.Client, url string, body, out any, prepare func(req *http.Request, body []byte) error) error {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		if err := prepare(req, reqBody); err != nil {
+			return err
+		}
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
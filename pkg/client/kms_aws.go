@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sotowang/otter/internal/awsv4"
+)
+
+// AWSCredentials are the access keys used to sign AWS requests
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Only for temporary credentials
+}
+
+// AWSKMS is a KeyProvider that wraps data keys with an AWS KMS symmetric
+// key. KMS identifies the key from the wrapped data key itself, so after
+// pointing Key at a new key or alias, values wrapped by the old key stay
+// readable as long as the caller may still decrypt with it.
+type AWSKMS struct {
+	Key    string // Key ID, ARN or alias that wraps new data keys
+	Region string // AWS region, AWS_REGION if empty
+	// Credentials sign requests; AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+	// and AWS_SESSION_TOKEN are used if empty
+	Credentials AWSCredentials
+	Endpoint    string       // API endpoint, https://kms.<region>.amazonaws.com if empty
+	HTTPClient  *http.Client // HTTP client, http.DefaultClient if nil
+}
+
+// Name implements KeyProvider
+func (a *AWSKMS) Name() string { return "awskms" }
+
+// KeyID implements KeyProvider
+func (a *AWSKMS) KeyID() string { return a.Key }
+
+// WrapKey implements KeyProvider
+func (a *AWSKMS) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var res struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	req := map[string]any{"KeyId": a.Key, "Plaintext": dataKey}
+	if err := a.call(ctx, "Encrypt", req, &res); err != nil {
+		return nil, err
+	}
+	return res.CiphertextBlob, nil
+}
+
+// UnwrapKey implements KeyProvider
+func (a *AWSKMS) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var res struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	req := map[string]any{"CiphertextBlob": wrapped}
+	if err := a.call(ctx, "Decrypt", req, &res); err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+// call invokes a KMS API action
+func (a *AWSKMS) call(ctx context.Context, action string, body, out any) error {
+	region := a.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		return fmt.Errorf("no AWS region configured")
+	}
+	creds := awsv4.Credentials(a.Credentials)
+	if creds.AccessKeyID == "" {
+		creds = awsv4.EnvCredentials()
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+
+	return postKeyService(ctx, a.HTTPClient, strings.TrimRight(endpoint, "/")+"/", body, out, func(req *http.Request, body []byte) error {
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService."+action)
+		awsv4.Sign(req, body, creds, region, "kms", time.Now())
+		return nil
+	})
+}
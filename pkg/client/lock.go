@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// LockConfig locks a config key against writes and deletions, recording
+// why. While it is locked, or its namespace frozen, PutConfig and
+// DeleteConfig fail with a 423 (Locked) *APIError. Locking a key already locked by someone else fails with a 423
+// *APIError; the owner of a lock may lock it again to change the reason.
+func (c *Client) LockConfig(ctx context.Context, namespace, group, key, reason string) (*model.ConfigLock, error) {
+	var lock model.ConfigLock
+	if err := c.doJSON(ctx, http.MethodPut, configPath(namespace, group, key, "lock"), map[string]string{"reason": reason}, &lock, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// UnlockConfig removes the lock of a config key. Only the lock owner or an
+// admin may unlock it.
+func (c *Client) UnlockConfig(ctx context.Context, namespace, group, key string) error {
+	return c.doJSON(ctx, http.MethodDelete, configPath(namespace, group, key, "lock"), nil, nil, http.StatusNoContent)
+}
+
+// FreezeNamespace locks every config of a namespace, for example during a
+// release freeze
+func (c *Client) FreezeNamespace(ctx context.Context, namespace, reason string) (*model.ConfigLock, error) {
+	var lock model.ConfigLock
+	if err := c.doJSON(ctx, http.MethodPut, "/api/v1/namespaces/"+namespace+"/lock", map[string]string{"reason": reason}, &lock, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// UnfreezeNamespace lifts the freeze of a namespace. Only the user who
+// froze it or an admin may lift it.
+func (c *Client) UnfreezeNamespace(ctx context.Context, namespace string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/namespaces/"+namespace+"/lock", nil, nil, http.StatusNoContent)
+}
+
+// ListLocks lists the locks in a namespace, its freeze first
+func (c *Client) ListLocks(ctx context.Context, namespace string) ([]*model.ConfigLock, error) {
+	var locks []*model.ConfigLock
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/namespaces/"+namespace+"/locks", nil, &locks, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"log"
+
+	"go.uber.org/zap"
+)
+
+// Logger receives the client's diagnostic messages, such as failed watch
+// polls, token refresh failures and decode errors. Implementations must be
+// safe for concurrent use.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// stdLogger writes to the standard library logger and is used when no
+// Logger is configured
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...any) {}
+
+func (stdLogger) Infof(format string, args ...any) {
+	log.Printf("INFO "+format, args...)
+}
+
+func (stdLogger) Warnf(format string, args ...any) {
+	log.Printf("WARN "+format, args...)
+}
+
+func (stdLogger) Errorf(format string, args ...any) {
+	log.Printf("ERROR "+format, args...)
+}
+
+// NopLogger discards all messages
+type NopLogger struct{}
+
+func (NopLogger) Debugf(format string, args ...any) {}
+func (NopLogger) Infof(format string, args ...any)  {}
+func (NopLogger) Warnf(format string, args ...any)  {}
+func (NopLogger) Errorf(format string, args ...any) {}
+
+// zapLogger adapts a zap logger to Logger
+type zapLogger struct {
+	sugar *zap.SugaredLogger
+}
+
+// NewZapLogger returns a Logger that writes to l
+func NewZapLogger(l *zap.Logger) Logger {
+	return zapLogger{sugar: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (z zapLogger) Debugf(format string, args ...any) { z.sugar.Debugf(format, args...) }
+func (z zapLogger) Infof(format string, args ...any)  { z.sugar.Infof(format, args...) }
+func (z zapLogger) Warnf(format string, args ...any)  { z.sugar.Warnf(format, args...) }
+func (z zapLogger) Errorf(format string, args ...any) { z.sugar.Errorf(format, args...) }
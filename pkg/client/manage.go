@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// APIError is returned when the server answers with an unexpected status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("otter: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("otter: status %d: %s", e.StatusCode, e.Message)
+}
+
+// doJSON sends an authenticated request to path, encoding body as JSON if not
+// nil and decoding the response into out if not nil. Any status other than
+// want is returned as an *APIError.
+func (c *Client) doJSON(ctx context.Context, method, path string, body, out any, want int) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return c.doBody(ctx, method, path, reqBody, out, want)
+}
+
+// doBody is doJSON for a request body that is already encoded, such as an
+// uploaded file
+func (c *Client) doBody(ctx context.Context, method, path string, reqBody []byte, out any, want int) error {
+	startTime := time.Now()
+
+	resp, err := c.do(ctx, method, path, reqBody)
+	if errors.Is(err, errAlreadyDeleted) {
+		c.updateStats(startTime, true)
+		return nil
+	}
+	if err != nil {
+		c.updateStats(startTime, false)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		c.updateStats(startTime, false)
+		var res struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&res)
+		return &APIError{StatusCode: resp.StatusCode, Message: res.Error}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.updateStats(startTime, false)
+			return err
+		}
+	}
+	c.updateStats(startTime, true)
+	return nil
+}
+
+// configPath builds the API path of a config, or of a sub-resource when
+// suffix is non-empty
+func configPath(namespace, group, key string, suffix ...string) string {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/configs/%s", namespace, group, key)
+	if len(suffix) > 0 {
+		path += "/" + strings.Join(suffix, "/")
+	}
+	return path
+}
+
+// PutConfig creates or updates a configuration item. An empty configType
+// defaults to text on the server.
+func (c *Client) PutConfig(ctx context.Context, namespace, group, key, value, configType string) (*model.Config, error) {
+	return c.putConfig(ctx, namespace, group, key, map[string]string{"value": value, "type": configType})
+}
+
+// putConfig sends a config write request and drops the cached copy
+func (c *Client) putConfig(ctx context.Context, namespace, group, key string, req map[string]string) (*model.Config, error) {
+	var cfg model.Config
+	err := c.doJSON(ctx, http.MethodPut, configPath(namespace, group, key), req, &cfg, http.StatusCreated)
+	c.cache.invalidate(namespace, group, key)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// DeleteConfig deletes a configuration item
+func (c *Client) DeleteConfig(ctx context.Context, namespace, group, key string) error {
+	err := c.doJSON(ctx, http.MethodDelete, configPath(namespace, group, key), nil, nil, http.StatusNoContent)
+	c.cache.invalidate(namespace, group, key)
+	return err
+}
+
+// ListConfigs lists the configuration items in a group
+func (c *Client) ListConfigs(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/configs", namespace, group)
+	var configs []*model.Config
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &configs, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// ListNamespaceConfigs lists the configuration items of every group in a namespace
+func (c *Client) ListNamespaceConfigs(ctx context.Context, namespace string) ([]*model.Config, error) {
+	var configs []*model.Config
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/namespaces/"+namespace+"/configs", nil, &configs, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// ListConfigChecksums lists the configuration items in a group without their
+// values, so callers can compare MD5, SHA256 and Size against what they hold
+// and fetch only the configs that changed
+func (c *Client) ListConfigChecksums(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/configs?values=false", namespace, group)
+	var configs []*model.Config
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &configs, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// GetConfigs retrieves several configuration items of a group in one round
+// trip, keyed by config key. Keys that do not exist are left out of the map.
+func (c *Client) GetConfigs(ctx context.Context, namespace, group string, keys []string) (map[string]*model.Config, error) {
+	if c.config.Offline {
+		configs := make(map[string]*model.Config, len(keys))
+		for _, key := range keys {
+			cfg, err := c.GetConfig(ctx, namespace, group, key)
+			if err == nil {
+				configs[key] = cfg
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+		return configs, nil
+	}
+
+	path := fmt.Sprintf("/api/v1/namespaces/%s/groups/%s/configs/batch", namespace, group) + c.readQuery()
+	req := map[string][]string{"keys": keys}
+	var res struct {
+		Configs []*model.Config `json:"configs"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, path, req, &res, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]*model.Config, len(res.Configs))
+	for _, cfg := range res.Configs {
+		if err := c.saveSnapshot(cfg); err != nil {
+			c.logger.Warnf("Failed to save snapshot for %s/%s/%s: %v", namespace, group, cfg.Key, err)
+		}
+		plain, err := c.reveal(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		configs[cfg.Key] = plain
+	}
+	return configs, nil
+}
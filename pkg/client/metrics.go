@@ -0,0 +1,71 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// MetricsHook receives an event for every HTTP request the client sends and
+// every change a watch delivers, so applications can export SDK metrics to
+// their own monitoring system. Implementations must be safe for concurrent
+// use and should return quickly.
+type MetricsHook interface {
+	// OnRequest is called after each HTTP request, including every retry
+	// and long poll
+	OnRequest(RequestMetric)
+	// OnWatchEvent is called when a watch delivers a change to its callback
+	OnWatchEvent(WatchMetric)
+}
+
+// RequestMetric describes a completed HTTP request
+type RequestMetric struct {
+	Method   string
+	Endpoint string
+	Path     string
+	// StatusCode is zero if the request failed with Err before a response
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// WatchMetric describes a change delivered by a watch
+type WatchMetric struct {
+	Namespace string
+	Group     string
+	Key       string
+	Version   int64
+	// Deleted is set when the config was deleted
+	Deleted bool
+}
+
+// observeRequest reports a request to the metrics hook, if any
+func (c *Client) observeRequest(method, endpoint, path string, startTime time.Time, resp *http.Response, err error) {
+	if c.config.Metrics == nil {
+		return
+	}
+	m := RequestMetric{
+		Method:   method,
+		Endpoint: endpoint,
+		Path:     path,
+		Duration: time.Since(startTime),
+		Err:      err,
+	}
+	if resp != nil {
+		m.StatusCode = resp.StatusCode
+	}
+	c.config.Metrics.OnRequest(m)
+}
+
+// observeWatchEvent reports a delivered change to the metrics hook, if any
+func (c *Client) observeWatchEvent(namespace, group, key string, version int64) {
+	if c.config.Metrics == nil {
+		return
+	}
+	c.config.Metrics.OnWatchEvent(WatchMetric{
+		Namespace: namespace,
+		Group:     group,
+		Key:       key,
+		Version:   version,
+		Deleted:   version == -1,
+	})
+}
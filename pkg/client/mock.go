@@ -0,0 +1,305 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// ConfigClient is the part of *Client applications use to read, watch and
+// write configs. Depending on it instead of *Client lets tests substitute a
+// MockClient for a running server.
+type ConfigClient interface {
+	GetConfig(ctx context.Context, namespace, group, key string) (*model.Config, error)
+	GetConfigs(ctx context.Context, namespace, group string, keys []string) (map[string]*model.Config, error)
+	ListConfigs(ctx context.Context, namespace, group string) ([]*model.Config, error)
+	WaitForConfig(ctx context.Context, namespace, group, key string) (*model.Config, error)
+	WatchConfig(ctx context.Context, namespace, group, key string, callback func(*model.Config)) *Watch
+	WatchGroup(ctx context.Context, namespace, group string, callback func(*model.Config)) *Watch
+	WatchConfigEvents(ctx context.Context, namespace, group, key string, callback func(*model.ConfigEvent)) *Watch
+	WatchGroupEvents(ctx context.Context, namespace, group string, callback func(*model.ConfigEvent)) *Watch
+	WatchNamespace(ctx context.Context, namespace string, callback func(*model.Config)) *Watch
+	WatchNamespaceEvents(ctx context.Context, namespace string, callback func(*model.ConfigEvent)) *Watch
+	PutConfig(ctx context.Context, namespace, group, key, value, configType string) (*model.Config, error)
+	DeleteConfig(ctx context.Context, namespace, group, key string) error
+}
+
+var (
+	_ ConfigClient = (*Client)(nil)
+	_ ConfigClient = (*MockClient)(nil)
+)
+
+// MockClient is an in-memory ConfigClient for unit tests. Configs are
+// seeded with Set, and TriggerChange simulates a change pushed by the
+// server, invoking matching watch callbacks synchronously.
+type MockClient struct {
+	mu      sync.Mutex
+	configs map[watchTarget]*model.Config
+	subs    map[*mockSub]struct{}
+	version int64
+}
+
+// mockSub is a watch registered with a MockClient. Group watches have an
+// empty key, namespace watches an empty group and key.
+type mockSub struct {
+	ctx      context.Context
+	target   watchTarget
+	callback func(*model.ConfigEvent)
+	w        *Watch
+}
+
+// NewMockClient creates an empty MockClient
+func NewMockClient() *MockClient {
+	return &MockClient{
+		configs: make(map[watchTarget]*model.Config),
+		subs:    make(map[*mockSub]struct{}),
+	}
+}
+
+// Set stores a config without notifying watches, e.g. to seed the initial
+// state. An empty configType defaults to text.
+func (m *MockClient) Set(namespace, group, key, value, configType string) *model.Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.store(namespace, group, key, value, configType)
+}
+
+// TriggerChange stores cfg, or removes it if cfg.Version is -1, and
+// delivers it to every watch of its key or group. A zero Version is
+// replaced with the next version number.
+func (m *MockClient) TriggerChange(cfg *model.Config) {
+	m.TriggerEvent(newEvent("", cfg))
+}
+
+// TriggerEvent is like TriggerChange but delivers an event of a given
+// type, e.g. to simulate a ROLLBACK. DELETE events remove the config.
+func (m *MockClient) TriggerEvent(event *model.ConfigEvent) {
+	m.mu.Lock()
+	cfg := event.Config
+	changed := *cfg
+	if event.Type == model.EventDelete {
+		changed.Version = -1
+	}
+	t := watchTarget{cfg.Namespace, cfg.Group, cfg.Key}
+	if changed.Version == -1 {
+		delete(m.configs, t)
+	} else {
+		if changed.Version == 0 {
+			m.version++
+			changed.Version = m.version
+		} else if changed.Version > m.version {
+			m.version = changed.Version
+		}
+		stored := changed
+		m.configs[t] = &stored
+	}
+	subs := m.watching(t)
+	m.mu.Unlock()
+
+	for _, sub := range subs {
+		delivered := changed
+		sub.callback(&model.ConfigEvent{Type: event.Type, Config: &delivered})
+	}
+}
+
+// TriggerError reports err on every watch of a key or group, as a failing
+// connection would
+func (m *MockClient) TriggerError(namespace, group, key string, err error) {
+	m.mu.Lock()
+	t := watchTarget{namespace, group, key}
+	subs := m.watching(t)
+	m.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.w.report(sub.target, 0, err)
+	}
+}
+
+// GetConfig implements ConfigClient, answering a not found *APIError for
+// missing configs
+func (m *MockClient) GetConfig(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg, ok := m.configs[watchTarget{namespace, group, key}]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Message: "config not found"}
+	}
+	out := *cfg
+	return &out, nil
+}
+
+// GetConfigs implements ConfigClient
+func (m *MockClient) GetConfigs(ctx context.Context, namespace, group string, keys []string) (map[string]*model.Config, error) {
+	configs := make(map[string]*model.Config, len(keys))
+	for _, key := range keys {
+		if cfg, err := m.GetConfig(ctx, namespace, group, key); err == nil {
+			configs[key] = cfg
+		}
+	}
+	return configs, nil
+}
+
+// ListConfigs implements ConfigClient, ordering configs by key
+func (m *MockClient) ListConfigs(ctx context.Context, namespace, group string) ([]*model.Config, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	configs := []*model.Config{}
+	for t, cfg := range m.configs {
+		if t.Namespace == namespace && t.Group == group {
+			out := *cfg
+			configs = append(configs, &out)
+		}
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Key < configs[j].Key })
+	return configs, nil
+}
+
+// WaitForConfig implements ConfigClient, returning once the config is Set,
+// put or triggered
+func (m *MockClient) WaitForConfig(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	created := make(chan *model.Config, 1)
+	w := m.WatchConfig(ctx, namespace, group, key, func(cfg *model.Config) {
+		if cfg.Version == -1 {
+			return
+		}
+		select {
+		case created <- cfg:
+		default:
+		}
+	})
+	defer w.Stop()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		// Set does not notify watches, so check the stored configs as well
+		if cfg, err := m.GetConfig(ctx, namespace, group, key); err == nil {
+			return cfg, nil
+		}
+		select {
+		case cfg := <-created:
+			return cfg, nil
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// WatchConfig implements ConfigClient
+func (m *MockClient) WatchConfig(ctx context.Context, namespace, group, key string, callback func(*model.Config)) *Watch {
+	return m.watch(ctx, watchTarget{namespace, group, key}, configCallback(callback))
+}
+
+// WatchGroup implements ConfigClient
+func (m *MockClient) WatchGroup(ctx context.Context, namespace, group string, callback func(*model.Config)) *Watch {
+	return m.watch(ctx, watchTarget{Namespace: namespace, Group: group}, configCallback(callback))
+}
+
+// WatchConfigEvents implements ConfigClient
+func (m *MockClient) WatchConfigEvents(ctx context.Context, namespace, group, key string, callback func(*model.ConfigEvent)) *Watch {
+	return m.watch(ctx, watchTarget{namespace, group, key}, callback)
+}
+
+// WatchGroupEvents implements ConfigClient
+func (m *MockClient) WatchGroupEvents(ctx context.Context, namespace, group string, callback func(*model.ConfigEvent)) *Watch {
+	return m.watch(ctx, watchTarget{Namespace: namespace, Group: group}, callback)
+}
+
+// WatchNamespace implements ConfigClient
+func (m *MockClient) WatchNamespace(ctx context.Context, namespace string, callback func(*model.Config)) *Watch {
+	return m.watch(ctx, watchTarget{Namespace: namespace}, configCallback(callback))
+}
+
+// WatchNamespaceEvents implements ConfigClient
+func (m *MockClient) WatchNamespaceEvents(ctx context.Context, namespace string, callback func(*model.ConfigEvent)) *Watch {
+	return m.watch(ctx, watchTarget{Namespace: namespace}, callback)
+}
+
+// PutConfig implements ConfigClient, notifying watches like the server would
+func (m *MockClient) PutConfig(ctx context.Context, namespace, group, key, value, configType string) (*model.Config, error) {
+	m.mu.Lock()
+	cfg := m.store(namespace, group, key, value, configType)
+	m.mu.Unlock()
+
+	m.TriggerChange(cfg)
+	return cfg, nil
+}
+
+// DeleteConfig implements ConfigClient, notifying watches like the server would
+func (m *MockClient) DeleteConfig(ctx context.Context, namespace, group, key string) error {
+	if _, err := m.GetConfig(ctx, namespace, group, key); err != nil {
+		return err
+	}
+	m.TriggerChange(&model.Config{Namespace: namespace, Group: group, Key: key, Version: -1})
+	return nil
+}
+
+// store saves a new version of a config. Callers must hold m.mu.
+func (m *MockClient) store(namespace, group, key, value, configType string) *model.Config {
+	if configType == "" {
+		configType = "text"
+	}
+	m.version++
+	now := time.Now()
+	cfg := &model.Config{
+		Namespace: namespace,
+		Group:     group,
+		Key:       key,
+		Value:     value,
+		Type:      configType,
+		Version:   m.version,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if prev, ok := m.configs[watchTarget{namespace, group, key}]; ok {
+		cfg.CreatedAt = prev.CreatedAt
+	}
+	stored := *cfg
+	m.configs[watchTarget{namespace, group, key}] = &stored
+	return cfg
+}
+
+// watch registers a callback until ctx is cancelled or the watch is stopped
+func (m *MockClient) watch(ctx context.Context, t watchTarget, callback func(*model.ConfigEvent)) *Watch {
+	ctx, cancel := context.WithCancel(ctx)
+	w := newWatch(cancel, nil)
+	sub := &mockSub{ctx: ctx, target: t, callback: callback, w: w}
+
+	m.mu.Lock()
+	m.subs[sub] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subs, sub)
+		m.mu.Unlock()
+		close(w.done)
+	}()
+	return w
+}
+
+// watching returns the active watches of a key, including watches of its
+// group and namespace. Callers must hold m.mu.
+func (m *MockClient) watching(t watchTarget) []*mockSub {
+	var subs []*mockSub
+	for sub := range m.subs {
+		if sub.ctx.Err() != nil {
+			continue
+		}
+		if sub.target.Namespace != t.Namespace {
+			continue
+		}
+		if sub.target == t || sub.target.Group == "" || (sub.target.Key == "" && sub.target.Group == t.Group) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
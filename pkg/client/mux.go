@@ -0,0 +1,293 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// watchTarget identifies a watched config key
+type watchTarget struct {
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+	Key       string `json:"key"`
+}
+
+func (t watchTarget) String() string {
+	if t.Group == "" {
+		return t.Namespace + "/*/*"
+	}
+	if t.Key == "" {
+		return t.Namespace + "/" + t.Group
+	}
+	return t.Namespace + "/" + t.Group + "/" + t.Key
+}
+
+// muxSub is one WatchConfig call served by the watch multiplexer
+type muxSub struct {
+	ctx      context.Context
+	target   watchTarget
+	callback func(*model.ConfigEvent)
+	// w is the handle returned to the caller, which receives its errors
+	w *Watch
+	// inner is the per-key watch serving this subscription after a fallback
+	inner *Watch
+}
+
+// watchMux multiplexes every WatchConfig of a client over a single batch
+// long poll and dispatches changes to the callbacks locally. If the server
+// does not support batch watches it falls back to one long poll per watch.
+type watchMux struct {
+	c *Client
+
+	mu       sync.Mutex
+	subs     map[watchTarget][]*muxSub
+	versions map[watchTarget]int64 // last version delivered, 0 if unknown
+	running  bool
+	// restart tells the running loop that the key set changed
+	restart     func()
+	unsupported bool
+	// useWebSocket serves watches over a WebSocket instead of long polls
+	useWebSocket bool
+}
+
+func newWatchMux(c *Client) *watchMux {
+	return &watchMux{
+		c:            c,
+		subs:         make(map[watchTarget][]*muxSub),
+		versions:     make(map[watchTarget]int64),
+		useWebSocket: c.config.WatchTransport == WatchTransportWebSocket,
+	}
+}
+
+// add registers a watch and returns its handle. The watch lasts until ctx
+// is cancelled or the handle is stopped.
+func (m *watchMux) add(ctx context.Context, target watchTarget, callback func(*model.ConfigEvent)) *Watch {
+	ctx, cancel := context.WithCancel(ctx)
+	w := newWatch(cancel, nil)
+	sub := &muxSub{ctx: ctx, target: target, callback: callback, w: w}
+
+	m.mu.Lock()
+	if m.unsupported {
+		sub.inner = m.c.watchTarget(ctx, target, callback, w.errs)
+	} else {
+		m.subs[target] = append(m.subs[target], sub)
+		m.changed()
+	}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.remove(sub)
+		m.mu.Lock()
+		inner := sub.inner
+		m.mu.Unlock()
+		if inner != nil {
+			<-inner.Done()
+		}
+		close(w.done)
+	}()
+	return w
+}
+
+// remove unregisters a watch
+func (m *watchMux) remove(sub *muxSub) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := m.subs[sub.target]
+	for i, s := range subs {
+		if s == sub {
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) == 0 {
+		delete(m.subs, sub.target)
+		delete(m.versions, sub.target)
+	} else {
+		m.subs[sub.target] = subs
+	}
+	m.changed()
+}
+
+// changed restarts the poll loop after the set of watched keys changed.
+// Callers must hold m.mu.
+func (m *watchMux) changed() {
+	if !m.running {
+		if len(m.subs) > 0 {
+			m.running = true
+			go m.run()
+		}
+		return
+	}
+	if m.restart != nil {
+		m.restart()
+	}
+}
+
+// run serves watches over the configured transport until none remain
+func (m *watchMux) run() {
+	m.mu.Lock()
+	useWebSocket := m.useWebSocket
+	m.mu.Unlock()
+
+	if useWebSocket {
+		if m.wsLoop() {
+			return
+		}
+		m.mu.Lock()
+		m.useWebSocket = false
+		m.mu.Unlock()
+	}
+	m.loop()
+}
+
+// loop long-polls the batch watch endpoint with the current key set until
+// no watches remain
+func (m *watchMux) loop() {
+	watchClient := &http.Client{
+		Transport: m.c.client.Transport, // Reuse the same connection pool
+		Timeout:   m.c.config.WatchTimeout,
+	}
+	path := m.c.withWatchWait("/api/v1/watch")
+
+	// Consecutive failed polls, used to back off between retries
+	failures := 0
+
+	for {
+		m.mu.Lock()
+		if len(m.subs) == 0 {
+			m.running = false
+			m.restart = nil
+			m.mu.Unlock()
+			return
+		}
+		type key struct {
+			watchTarget
+			Version int64 `json:"version"`
+		}
+		keys := make([]key, 0, len(m.subs))
+		for t := range m.subs {
+			keys = append(keys, key{t, m.versions[t]})
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.restart = cancel
+		m.mu.Unlock()
+
+		reqBody, _ := json.Marshal(map[string]any{"keys": keys})
+		startTime := time.Now()
+		resp, err := m.c.send(ctx, watchClient, http.MethodPost, path, reqBody)
+		if err != nil {
+			if ctx.Err() == nil {
+				m.c.logger.Warnf("Watch of %d keys failed: %v", len(keys), err)
+				m.reportAll(0, err)
+				m.c.updateStats(startTime, false)
+				failures++
+				// Waiting ends early if the key set changes
+				m.c.retryWait(ctx, http.MethodPost, path, failures, nil, err)
+			}
+			cancel()
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			var res struct {
+				Changes []json.RawMessage `json:"changes"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				m.c.logger.Errorf("Failed to decode watch response: %v", err)
+				m.reportAll(0, err)
+			} else {
+				for _, raw := range res.Changes {
+					event, err := decodeEvent(raw)
+					if err != nil {
+						m.c.logger.Errorf("Failed to decode watch response: %v", err)
+						m.reportAll(0, err)
+						continue
+					}
+					m.dispatch(event)
+				}
+			}
+			m.c.updateStats(startTime, true)
+			failures = 0
+		case resp.StatusCode == http.StatusNotModified:
+			m.c.updateStats(startTime, true)
+			failures = 0
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+			// Older server without batch watches
+			m.c.updateStats(startTime, false)
+			m.c.logger.Infof("Server does not support batch watches, falling back to one long poll per key")
+			m.disable()
+		default:
+			m.c.logger.Warnf("Watch of %d keys failed: status %d", len(keys), resp.StatusCode)
+			m.reportAll(resp.StatusCode, nil)
+			m.c.updateStats(startTime, false)
+			failures++
+			m.c.retryWait(ctx, http.MethodPost, path, failures, resp, nil)
+		}
+		resp.Body.Close()
+		cancel()
+	}
+}
+
+// dispatch records the new version of a changed config and delivers the
+// event to every watch of that key
+func (m *watchMux) dispatch(event *model.ConfigEvent) {
+	cfg := event.Config
+	t := watchTarget{cfg.Namespace, cfg.Group, cfg.Key}
+
+	m.mu.Lock()
+	subs := append([]*muxSub(nil), m.subs[t]...)
+	if len(subs) > 0 {
+		m.versions[t] = cfg.Version
+	}
+	m.mu.Unlock()
+
+	if len(subs) == 0 {
+		return
+	}
+	err := m.c.deliver(context.Background(), event, func(event *model.ConfigEvent) {
+		for _, sub := range subs {
+			if sub.ctx.Err() == nil {
+				sub.callback(event)
+			}
+		}
+	})
+	if err != nil {
+		for _, sub := range subs {
+			sub.w.report(t, 0, err)
+		}
+	}
+}
+
+// reportAll reports a failure of the shared connection to every watch
+func (m *watchMux) reportAll(statusCode int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for t, subs := range m.subs {
+		for _, sub := range subs {
+			sub.w.report(t, statusCode, err)
+		}
+	}
+}
+
+// disable switches every current and future watch to its own long poll
+func (m *watchMux) disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.unsupported = true
+	for _, subs := range m.subs {
+		for _, sub := range subs {
+			sub.inner = m.c.watchTarget(sub.ctx, sub.target, sub.callback, sub.w.errs)
+		}
+	}
+	m.subs = make(map[watchTarget][]*muxSub)
+	m.versions = make(map[watchTarget]int64)
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// Owner names the user or the team owning a group or key; set exactly one
+type Owner struct {
+	User string `json:"user,omitempty"`
+	Team string `json:"team,omitempty"`
+}
+
+// ListOwners lists the owners of groups and keys in a namespace. Only
+// owners and admins may change an owned config; PutConfig and DeleteConfig
+// by anyone else fail with a 403 *APIError.
+func (c *Client) ListOwners(ctx context.Context, namespace string) ([]*model.ConfigOwner, error) {
+	var owners []*model.ConfigOwner
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/namespaces/"+namespace+"/owners", nil, &owners, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// SetGroupOwner assigns every key of a group to owner, unless the key has
+// an owner of its own. Admin only.
+func (c *Client) SetGroupOwner(ctx context.Context, namespace, group string, owner Owner) (*model.ConfigOwner, error) {
+	var res model.ConfigOwner
+	path := fmt.Sprintf("/api/v1/admin/namespaces/%s/groups/%s/owner", namespace, group)
+	if err := c.doJSON(ctx, http.MethodPut, path, owner, &res, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteGroupOwner removes the owner of a group. Admin only.
+func (c *Client) DeleteGroupOwner(ctx context.Context, namespace, group string) error {
+	path := fmt.Sprintf("/api/v1/admin/namespaces/%s/groups/%s/owner", namespace, group)
+	return c.doJSON(ctx, http.MethodDelete, path, nil, nil, http.StatusNoContent)
+}
+
+// SetConfigOwner assigns a key to owner. Admin only.
+func (c *Client) SetConfigOwner(ctx context.Context, namespace, group, key string, owner Owner) (*model.ConfigOwner, error) {
+	var res model.ConfigOwner
+	path := fmt.Sprintf("/api/v1/admin/namespaces/%s/groups/%s/configs/%s/owner", namespace, group, key)
+	if err := c.doJSON(ctx, http.MethodPut, path, owner, &res, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteConfigOwner removes the owner of a key, leaving it to the owner of
+// its group if any. Admin only.
+func (c *Client) DeleteConfigOwner(ctx context.Context, namespace, group, key string) error {
+	path := fmt.Sprintf("/api/v1/admin/namespaces/%s/groups/%s/configs/%s/owner", namespace, group, key)
+	return c.doJSON(ctx, http.MethodDelete, path, nil, nil, http.StatusNoContent)
+}
+
+// ListTeams lists every team. Admin only.
+func (c *Client) ListTeams(ctx context.Context) ([]*model.Team, error) {
+	var teams []*model.Team
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/admin/teams", nil, &teams, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// SetTeam creates a team or replaces its members. Admin only.
+func (c *Client) SetTeam(ctx context.Context, name string, members []string) (*model.Team, error) {
+	var team model.Team
+	if err := c.doJSON(ctx, http.MethodPut, "/api/v1/admin/teams/"+name, map[string][]string{"members": members}, &team, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// DeleteTeam deletes a team. Admin only.
+func (c *Client) DeleteTeam(ctx context.Context, name string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/admin/teams/"+name, nil, nil, http.StatusNoContent)
+}
+
+// ListNotifications lists the logged-in user's notifications about changes
+// others made to configs they own, newest first. A limit of 0 uses the
+// server's default.
+func (c *Client) ListNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	query := url.Values{}
+	if unreadOnly {
+		query.Set("unread", "true")
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/api/v1/notifications"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var notifications []*model.Notification
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &notifications, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead marks the logged-in user's notifications up to and
+// including upToID as read, or all of them if upToID is 0
+func (c *Client) MarkNotificationsRead(ctx context.Context, upToID int64) error {
+	return c.doJSON(ctx, http.MethodPost, "/api/v1/notifications/read", map[string]int64{"up_to": upToID}, nil, http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// srvScheme prefixes endpoints resolved through DNS SRV records, e.g.
+// dns+srv://otter.service.consul. Use dns+srv+https:// for TLS servers.
+const srvScheme = "dns+srv://"
+
+const srvSchemeTLS = "dns+srv+https://"
+
+// Resolver discovers the server endpoints a client talks to. The client
+// calls it at start-up and every ResolveInterval, so it follows topology
+// changes without a redeploy.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// SRVResolver resolves endpoints from the DNS SRV records of Name, ordered
+// by priority and then weight
+type SRVResolver struct {
+	// Name is the domain queried directly, e.g. otter.service.consul
+	Name string
+	// Scheme is http (default) or https
+	Scheme string
+	// Resolver performs the lookups; defaults to net.DefaultResolver
+	Resolver *net.Resolver
+}
+
+// Resolve implements Resolver
+func (r *SRVResolver) Resolve(ctx context.Context) ([]string, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, "", "", r.Name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup SRV %s: %w", r.Name, err)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+
+	urls := make([]string, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		urls = append(urls, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("lookup SRV %s: no records", r.Name)
+	}
+	return urls, nil
+}
+
+// parseResolver returns an SRVResolver for a dns+srv:// endpoint, or nil
+// if endpoint is a plain URL list
+func parseResolver(endpoint string) Resolver {
+	endpoint = strings.TrimSpace(endpoint)
+	switch {
+	case strings.HasPrefix(endpoint, srvSchemeTLS):
+		return &SRVResolver{Name: strings.TrimRight(strings.TrimPrefix(endpoint, srvSchemeTLS), "/"), Scheme: "https"}
+	case strings.HasPrefix(endpoint, srvScheme):
+		return &SRVResolver{Name: strings.TrimRight(strings.TrimPrefix(endpoint, srvScheme), "/"), Scheme: "http"}
+	}
+	return nil
+}
+
+// resolve asks the configured resolver for endpoints and installs them,
+// keeping the current set if resolution fails
+func (c *Client) resolve(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.RequestTimeout)
+	defer cancel()
+
+	urls, err := c.config.Resolver.Resolve(ctx)
+	if err != nil {
+		c.logger.Warnf("Failed to resolve endpoints: %v", err)
+		return
+	}
+	var cleaned []string
+	for _, u := range urls {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			cleaned = append(cleaned, u)
+		}
+	}
+	if len(cleaned) == 0 {
+		c.logger.Warnf("Resolver returned no endpoints, keeping %v", c.endpoints.list())
+		return
+	}
+	c.endpoints.set(cleaned)
+}
+
+// resolveLoop re-resolves endpoints every interval until ctx is cancelled
+func (c *Client) resolveLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.resolve(ctx)
+	}
+}
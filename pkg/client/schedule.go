@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// ScheduleUpdate changes a scheduled change; nil fields are left as they are
+type ScheduleUpdate struct {
+	Value       *string    `json:"value,omitempty"`
+	Type        *string    `json:"type,omitempty"`
+	ContentType *string    `json:"content_type,omitempty"`
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+}
+
+// ScheduleConfig stores a config value for the server to write at
+// publishAt, which must be in the future. Locks and quotas are checked when
+// it is published; a change they reject is kept with the failed status.
+func (c *Client) ScheduleConfig(ctx context.Context, namespace, group, key, value, configType string, publishAt time.Time) (*model.ScheduledChange, error) {
+	req := map[string]any{"value": value, "type": configType, "publish_at": publishAt}
+	var change model.ScheduledChange
+	if err := c.doJSON(ctx, http.MethodPut, configPath(namespace, group, key), req, &change, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// ListScheduledChanges lists the scheduled changes in a namespace, soonest
+// first
+func (c *Client) ListScheduledChanges(ctx context.Context, namespace string) ([]*model.ScheduledChange, error) {
+	var changes []*model.ScheduledChange
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/namespaces/"+namespace+"/scheduled", nil, &changes, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetScheduledChange returns a scheduled change
+func (c *Client) GetScheduledChange(ctx context.Context, namespace string, id int64) (*model.ScheduledChange, error) {
+	var change model.ScheduledChange
+	if err := c.doJSON(ctx, http.MethodGet, scheduledPath(namespace, id), nil, &change, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// UpdateScheduledChange changes the value or publish time of a pending or
+// failed change, scheduling a failed one again
+func (c *Client) UpdateScheduledChange(ctx context.Context, namespace string, id int64, update ScheduleUpdate) (*model.ScheduledChange, error) {
+	var change model.ScheduledChange
+	if err := c.doJSON(ctx, http.MethodPut, scheduledPath(namespace, id), update, &change, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CancelScheduledChange cancels a scheduled change
+func (c *Client) CancelScheduledChange(ctx context.Context, namespace string, id int64) error {
+	return c.doJSON(ctx, http.MethodDelete, scheduledPath(namespace, id), nil, nil, http.StatusNoContent)
+}
+
+// scheduledPath returns the API path of a scheduled change
+func scheduledPath(namespace string, id int64) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/scheduled/%d", namespace, id)
+}
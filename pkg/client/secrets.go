@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// SecretResolver replaces a config value that references an external secret
+// store with the secret, and returns other values unchanged. vault.Resolver
+// implements it for vault:<path>#<field> references.
+type SecretResolver interface {
+	Resolve(ctx context.Context, value string) (string, error)
+}
+
+// reveal returns cfg as handed to callers: verified, decrypted, then with a secret
+// reference resolved if a SecretResolver is configured. Snapshots and the
+// cache keep the stored value, so secrets never reach the disk.
+func (c *Client) reveal(ctx context.Context, cfg *model.Config) (*model.Config, error) {
+	if err := c.verify(cfg); err != nil {
+		return nil, err
+	}
+	cfg, err := c.decrypt(ctx, cfg)
+	if err != nil || c.config.SecretResolver == nil {
+		return cfg, err
+	}
+	value, err := c.config.SecretResolver.Resolve(ctx, cfg.Value)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s/%s/%s: %w", cfg.Namespace, cfg.Group, cfg.Key, err)
+	}
+	if value == cfg.Value {
+		return cfg, nil
+	}
+	out := *cfg
+	out.Value = value
+	return &out, nil
+}
+
+// readQuery asks the server to leave secret references unresolved when the
+// client resolves them itself
+func (c *Client) readQuery() string {
+	if c.config.SecretResolver == nil {
+		return ""
+	}
+	return "?resolve=false"
+}
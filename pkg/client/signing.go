@@ -0,0 +1,33 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sotowang/otter/pkg/model"
+	"github.com/sotowang/otter/pkg/signing"
+)
+
+// ErrInvalidSignature is returned for a config whose signature is missing or
+// does not match ClientConfig.SigningKey
+var ErrInvalidSignature = errors.New("invalid config signature")
+
+// verify checks the server's signature of cfg when a SigningKey is configured
+func (c *Client) verify(cfg *model.Config) error {
+	if len(c.config.SigningKey) == 0 {
+		return nil
+	}
+	payload := signing.Payload{
+		Namespace:   cfg.Namespace,
+		Group:       cfg.Group,
+		Key:         cfg.Key,
+		Version:     cfg.Version,
+		Type:        cfg.Type,
+		ContentType: cfg.ContentType,
+		Value:       cfg.Value,
+	}
+	if !payload.Verify(c.config.SigningKey, cfg.Signature) {
+		return fmt.Errorf("%s/%s/%s version %d: %w", cfg.Namespace, cfg.Group, cfg.Key, cfg.Version, ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// snapshotPath returns the file holding the last-known value of a config
+func (c *Client) snapshotPath(namespace, group, key string) string {
+	return filepath.Join(c.config.SnapshotDir, url.PathEscape(namespace), url.PathEscape(group), url.PathEscape(key)+".json")
+}
+
+// saveSnapshot persists a config to the snapshot directory, if one is configured.
+// The file is written to a temporary name and renamed so readers never see a partial snapshot.
+func (c *Client) saveSnapshot(cfg *model.Config) error {
+	if c.config.SnapshotDir == "" {
+		return nil
+	}
+
+	path := c.snapshotPath(cfg.Namespace, cfg.Group, cfg.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadSnapshot reads the last-known value of a config from the snapshot directory
+func (c *Client) loadSnapshot(namespace, group, key string) (*model.Config, error) {
+	if c.config.SnapshotDir == "" {
+		return nil, os.ErrNotExist
+	}
+
+	data, err := os.ReadFile(c.snapshotPath(namespace, group, key))
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg model.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// removeSnapshot deletes the snapshot of a config that no longer exists
+func (c *Client) removeSnapshot(namespace, group, key string) error {
+	if c.config.SnapshotDir == "" {
+		return nil
+	}
+	err := os.Remove(c.snapshotPath(namespace, group, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// SnapshotFile is the format written by ExportSnapshot and read by
+// ImportSnapshot. Values are stored as served, so encrypted values stay
+// encrypted.
+type SnapshotFile struct {
+	ExportedAt time.Time       `json:"exported_at"`
+	Namespaces []string        `json:"namespaces"`
+	Configs    []*model.Config `json:"configs"`
+}
+
+// ExportSnapshot writes every config of the given namespaces to w, so a
+// connected environment can produce a frozen snapshot for CI jobs or
+// air-gapped deployments
+func (c *Client) ExportSnapshot(ctx context.Context, w io.Writer, namespaces ...string) error {
+	file := SnapshotFile{ExportedAt: time.Now().UTC(), Namespaces: namespaces, Configs: []*model.Config{}}
+	for _, namespace := range namespaces {
+		configs, err := c.ListNamespaceConfigs(ctx, namespace)
+		if err != nil {
+			return fmt.Errorf("export namespace %s: %w", namespace, err)
+		}
+		file.Configs = append(file.Configs, configs...)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(file)
+}
+
+// ImportSnapshot loads a file written by ExportSnapshot into SnapshotDir,
+// returning the number of configs imported. Combined with Offline the client
+// then runs entirely from the snapshot.
+func (c *Client) ImportSnapshot(r io.Reader) (int, error) {
+	if c.config.SnapshotDir == "" {
+		return 0, errors.New("import snapshot: no SnapshotDir configured")
+	}
+
+	var file SnapshotFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return 0, fmt.Errorf("import snapshot: %w", err)
+	}
+	for i, cfg := range file.Configs {
+		if err := c.saveSnapshot(cfg); err != nil {
+			return i, fmt.Errorf("import snapshot %s/%s/%s: %w", cfg.Namespace, cfg.Group, cfg.Key, err)
+		}
+	}
+	return len(file.Configs), nil
+}
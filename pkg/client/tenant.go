@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// TenantRequest holds the fields used to create a tenant. Admin, if set,
+// creates the tenant's first admin, who signs in with ClientConfig.Tenant
+// set to Name.
+type TenantRequest struct {
+	Name  string       `json:"name"`
+	Admin *UserRequest `json:"admin,omitempty"`
+}
+
+// ListTenants lists all tenants. It requires an admin outside any tenant.
+func (c *Client) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	var tenants []*model.Tenant
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/admin/tenants", nil, &tenants, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+// CreateTenant creates a tenant. It requires an admin outside any tenant.
+func (c *Client) CreateTenant(ctx context.Context, req TenantRequest) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/admin/tenants", req, &tenant, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// DeleteTenant deletes a tenant with all its namespaces, configs and users
+func (c *Client) DeleteTenant(ctx context.Context, name string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/api/v1/admin/tenants/"+name, nil, nil, http.StatusNoContent)
+}
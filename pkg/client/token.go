@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry an access token is refreshed.
+// Tokens with a shorter lifetime are refreshed halfway through it.
+const tokenRefreshMargin = 30 * time.Second
+
+// accessToken returns the current access token
+func (c *Client) accessToken() string {
+	c.authMu.RLock()
+	defer c.authMu.RUnlock()
+	return c.token
+}
+
+// Token returns the current access token, e.g. to share a login with other
+// clients through ClientConfig.Token
+func (c *Client) Token() string {
+	return c.accessToken()
+}
+
+// setTokens installs the tokens from a login or refresh response and
+// schedules the next proactive refresh from expires_in
+func (c *Client) setTokens(res TokenResponse) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	c.token = res.AccessToken
+	c.refreshToken = res.RefreshToken
+	c.refreshAt = time.Time{}
+	if res.ExpiresIn > 0 {
+		lifetime := time.Duration(res.ExpiresIn) * time.Second
+		margin := tokenRefreshMargin
+		if lifetime < 2*margin {
+			margin = lifetime / 2
+		}
+		c.refreshAt = time.Now().Add(lifetime - margin)
+	}
+}
+
+// ensureFreshToken refreshes the access token if it is about to expire, so
+// requests never go out with a token that lapses mid-flight. Failures are
+// logged and left to the 401 handling in send.
+func (c *Client) ensureFreshToken(ctx context.Context) {
+	c.authMu.RLock()
+	due := !c.refreshAt.IsZero() && time.Now().After(c.refreshAt)
+	stale := c.token
+	c.authMu.RUnlock()
+	if !due {
+		return
+	}
+	if err := c.reauthenticateFrom(ctx, stale); err != nil {
+		c.logger.Warnf("Proactive token refresh failed: %v", err)
+	}
+}
+
+// reauthenticateFrom re-authenticates unless another goroutine already
+// replaced the stale token while this one waited, so concurrent requests
+// that hit an expired token trigger a single refresh
+func (c *Client) reauthenticateFrom(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if c.accessToken() != stale {
+		return nil
+	}
+	return c.reauthenticateLocked(ctx)
+}
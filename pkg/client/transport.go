@@ -0,0 +1,79 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// newTransport builds the round tripper for config: config.Transport as is
+// if set, otherwise a pooled http.Transport with the configured proxy and
+// TLS options
+func newTransport(config ClientConfig) (http.RoundTripper, error) {
+	if config.Transport != nil {
+		return config.Transport, nil
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:          config.ConnectionPoolSize,
+		MaxIdleConnsPerHost:   config.ConnectionPoolSize,
+		IdleConnTimeout:       config.ConnectionIdleTimeout,
+		MaxConnsPerHost:       config.ConnectionPoolSize * 2, // Allow temporary burst
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if config.ProxyURL != "" {
+		proxy, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return transport, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	transport.TLSClientConfig = tlsConfig
+	return transport, err
+}
+
+// newTLSConfig builds the TLS settings from the CA bundle, client
+// certificate and verification options, or returns nil if none are set
+func newTLSConfig(config ClientConfig) (*tls.Config, error) {
+	if config.CAFile == "" && config.CertFile == "" && config.KeyFile == "" && !config.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return tlsConfig, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return tlsConfig, fmt.Errorf("no certificates found in CA bundle %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return tlsConfig, errors.New("client certificate requires both CertFile and KeyFile")
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return tlsConfig, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
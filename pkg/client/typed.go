@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetString returns the value of a config, or defaultValue if it cannot be retrieved
+func (c *Client) GetString(ctx context.Context, namespace, group, key, defaultValue string) string {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return defaultValue
+	}
+	return cfg.Value
+}
+
+// GetInt returns the value of a config parsed as an integer, or defaultValue
+// if it cannot be retrieved or parsed
+func (c *Client) GetInt(ctx context.Context, namespace, group, key string, defaultValue int) int {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return defaultValue
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(cfg.Value))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// GetBool returns the value of a config parsed with strconv.ParseBool, or
+// defaultValue if it cannot be retrieved or parsed
+func (c *Client) GetBool(ctx context.Context, namespace, group, key string, defaultValue bool) bool {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return defaultValue
+	}
+	v, err := strconv.ParseBool(strings.TrimSpace(cfg.Value))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// GetFloat returns the value of a config parsed as a float64, or defaultValue
+// if it cannot be retrieved or parsed
+func (c *Client) GetFloat(ctx context.Context, namespace, group, key string, defaultValue float64) float64 {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return defaultValue
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(cfg.Value), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// GetDuration returns the value of a config parsed with time.ParseDuration
+// (e.g. "1m30s"), or defaultValue if it cannot be retrieved or parsed
+func (c *Client) GetDuration(ctx context.Context, namespace, group, key string, defaultValue time.Duration) time.Duration {
+	cfg, err := c.GetConfig(ctx, namespace, group, key)
+	if err != nil {
+		return defaultValue
+	}
+	v, err := time.ParseDuration(strings.TrimSpace(cfg.Value))
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// ListVariants lists the variants of a config ordered by name
+func (c *Client) ListVariants(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	var variants []*model.ConfigVariant
+	if err := c.doJSON(ctx, http.MethodGet, configPath(namespace, group, key, "variants"), nil, &variants, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// SetVariant creates or replaces the variant name of a config, served
+// instead of its value to clients whose Labels include every label of
+// selector. The config moves to a new version so watchers pick it up.
+func (c *Client) SetVariant(ctx context.Context, namespace, group, key, name string, selector map[string]string, value string) (*model.ConfigVariant, error) {
+	req := map[string]any{"selector": selector, "value": value}
+	var variant model.ConfigVariant
+	err := c.doJSON(ctx, http.MethodPut, configPath(namespace, group, key, "variants", name), req, &variant, http.StatusOK)
+	c.cache.invalidate(namespace, group, key)
+	if err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+// DeleteVariant deletes a variant of a config
+func (c *Client) DeleteVariant(ctx context.Context, namespace, group, key, name string) error {
+	err := c.doJSON(ctx, http.MethodDelete, configPath(namespace, group, key, "variants", name), nil, nil, http.StatusNoContent)
+	c.cache.invalidate(namespace, group, key)
+	return err
+}
+
+// ListOverrides lists the overrides of a config targeted at single client
+// instances, ordered by name
+func (c *Client) ListOverrides(ctx context.Context, namespace, group, key string) ([]*model.ConfigVariant, error) {
+	var overrides []*model.ConfigVariant
+	if err := c.doJSON(ctx, http.MethodGet, configPath(namespace, group, key, "overrides"), nil, &overrides, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// SetOverride overrides the value of a config for the one client instance
+// with clientID, or with ip if clientID is empty, which must have fetched
+// or watched the config recently. It is served before any other variant.
+func (c *Client) SetOverride(ctx context.Context, namespace, group, key, clientID, ip, value string) (*model.ConfigVariant, error) {
+	req := map[string]any{"client_id": clientID, "ip": ip, "value": value}
+	var override model.ConfigVariant
+	err := c.doJSON(ctx, http.MethodPut, configPath(namespace, group, key, "overrides"), req, &override, http.StatusOK)
+	c.cache.invalidate(namespace, group, key)
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// DeleteOverride removes the override of a config for the client instance
+// with clientID, or with ip if clientID is empty
+func (c *Client) DeleteOverride(ctx context.Context, namespace, group, key, clientID, ip string) error {
+	query := url.Values{}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	} else {
+		query.Set("ip", ip)
+	}
+	err := c.doJSON(ctx, http.MethodDelete, configPath(namespace, group, key, "overrides")+"?"+query.Encode(), nil, nil, http.StatusNoContent)
+	c.cache.invalidate(namespace, group, key)
+	return err
+}
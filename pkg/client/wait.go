@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// waitForConfigInterval is how often WaitForConfig polls in case the watch
+// misses the creation, e.g. while reconnecting
+var waitForConfigInterval = 5 * time.Second
+
+// WaitForConfig blocks until a configuration item exists and returns it, for
+// services that start before their config has been provisioned. It watches
+// the key and polls as a fallback, riding out unreachable servers, until ctx
+// is done. Authorization and other client errors are returned immediately.
+func (c *Client) WaitForConfig(ctx context.Context, namespace, group, key string) (*model.Config, error) {
+	created := make(chan *model.Config, 1)
+	w := c.WatchConfig(ctx, namespace, group, key, func(cfg *model.Config) {
+		if cfg.Version == -1 {
+			return
+		}
+		select {
+		case created <- cfg:
+		default:
+		}
+	})
+	defer w.Stop()
+
+	ticker := time.NewTicker(waitForConfigInterval)
+	defer ticker.Stop()
+	for {
+		cfg, err := c.GetConfig(ctx, namespace, group, key)
+		if err == nil {
+			return cfg, nil
+		}
+		if !missing(err) {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError && apiErr.StatusCode != http.StatusTooManyRequests {
+				return nil, err
+			}
+			c.logger.Debugf("Waiting for %s/%s/%s: %v", namespace, group, key, err)
+		}
+
+		select {
+		case cfg := <-created:
+			return cfg, nil
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// missing reports whether err means the config does not exist yet
+func missing(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusNotFound
+	}
+	return errors.Is(err, os.ErrNotExist)
+}
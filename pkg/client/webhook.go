@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// ListWebhooks lists the webhooks of a namespace, without their secrets.
+// Admin only.
+func (c *Client) ListWebhooks(ctx context.Context, namespace string) ([]*model.Webhook, error) {
+	var hooks []*model.Webhook
+	if err := c.doJSON(ctx, http.MethodGet, "/api/v1/admin/namespaces/"+namespace+"/webhooks", nil, &hooks, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// CreateWebhook adds a webhook posting every config change in a namespace
+// to hookURL. Requests are signed with secret, or with a generated one if
+// it is empty; the returned webhook carries it, which is the only time it
+// is returned. Admin only.
+func (c *Client) CreateWebhook(ctx context.Context, namespace, hookURL, secret string) (*model.Webhook, error) {
+	req := map[string]string{"url": hookURL, "secret": secret}
+	var hook model.Webhook
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/admin/namespaces/"+namespace+"/webhooks", req, &hook, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// DeleteWebhook deletes a webhook and its queued deliveries. Admin only.
+func (c *Client) DeleteWebhook(ctx context.Context, namespace string, id int64) error {
+	return c.doJSON(ctx, http.MethodDelete, webhookPath(namespace, id), nil, nil, http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lists up to limit of the latest deliveries of a
+// webhook, newest first, only those with status (pending, delivered or
+// dead) unless it is empty. A zero limit uses the server default of 50.
+// Admin only.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, namespace string, id int64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if limit > 0 {
+		query.Set("limit", fmt.Sprint(limit))
+	}
+	path := webhookPath(namespace, id) + "/deliveries"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var deliveries []*model.WebhookDelivery
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, &deliveries, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhook queues a delivery of a webhook to be attempted again at
+// once, such as a dead one after its receiver is fixed. Admin only.
+func (c *Client) RedeliverWebhook(ctx context.Context, namespace string, id, deliveryID int64) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	path := fmt.Sprintf("%s/deliveries/%d/redeliver", webhookPath(namespace, id), deliveryID)
+	if err := c.doJSON(ctx, http.MethodPost, path, nil, &delivery, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// webhookPath returns the API path of a webhook
+func webhookPath(namespace string, id int64) string {
+	return fmt.Sprintf("/api/v1/admin/namespaces/%s/webhooks/%d", namespace, id)
+}
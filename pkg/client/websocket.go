@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// Watch transports selectable with ClientConfig.WatchTransport
+const (
+	// WatchTransportLongPoll multiplexes watches over a batch long poll
+	WatchTransportLongPoll = "longpoll"
+	// WatchTransportWebSocket keeps one persistent WebSocket per client,
+	// falling back to long polling if the server does not support it
+	WatchTransportWebSocket = "websocket"
+)
+
+const watchWebSocketPath = "/api/v1/watch/ws"
+
+// wsMessage is exchanged with the server over a watch WebSocket. Change
+// messages carry the event type in Event, which older servers leave empty.
+type wsMessage struct {
+	Type   string        `json:"type"`
+	Keys   []wsTarget    `json:"keys,omitempty"`
+	Event  string        `json:"event,omitempty"`
+	Config *model.Config `json:"config,omitempty"`
+}
+
+// wsTarget is a watched key together with the version the client holds
+type wsTarget struct {
+	watchTarget
+	Version int64 `json:"version"`
+}
+
+// errNoWatches ends a WebSocket connection once nothing is watched
+var errNoWatches = errors.New("no watches left")
+
+// dialWatch opens a watch WebSocket to the server at base
+func (c *Client) dialWatch(base string) (*websocket.Conn, error) {
+	c.ensureFreshToken(context.Background())
+	startTime := time.Now()
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(base, "http")+watchWebSocketPath, base)
+	if err != nil {
+		return nil, err
+	}
+	config.Header = http.Header{}
+	if token := c.accessToken(); token != "" {
+		config.Header.Set("Authorization", "Bearer "+token)
+	}
+	config.Header.Set(clientIDHeader, c.config.ClientID)
+	if len(c.config.Labels) > 0 {
+		config.Header.Set(clientLabelsHeader, formatLabels(c.config.Labels))
+	}
+	config.Dialer = &net.Dialer{Timeout: c.config.RequestTimeout}
+	if transport, ok := c.client.Transport.(*http.Transport); ok {
+		config.TlsConfig = transport.TLSClientConfig
+	}
+
+	conn, err := websocket.DialConfig(config)
+	c.observeRequest(http.MethodGet, base, watchWebSocketPath, startTime, nil, err)
+	c.updateStats(startTime, err == nil)
+	return conn, err
+}
+
+// badStatus reports whether a dial failed because the server refused the
+// upgrade, e.g. because it predates WebSocket watches or the token expired
+func badStatus(err error) bool {
+	var dialErr *websocket.DialError
+	return errors.As(err, &dialErr) && dialErr.Err == websocket.ErrBadStatus
+}
+
+// wsLoop serves all watches over a WebSocket, reconnecting and
+// re-subscribing whenever the connection drops. It returns true once no
+// watches remain, or false if the server does not accept WebSocket watches.
+func (m *watchMux) wsLoop() bool {
+	// Consecutive failed connection attempts, used to back off between retries
+	failures := 0
+	reauthenticated := false
+
+	for {
+		m.mu.Lock()
+		if len(m.subs) == 0 {
+			m.running = false
+			m.restart = nil
+			m.mu.Unlock()
+			return true
+		}
+		resync := make(chan struct{}, 1)
+		m.restart = func() {
+			select {
+			case resync <- struct{}{}:
+			default:
+			}
+		}
+		m.mu.Unlock()
+
+		base := m.c.endpoints.get()
+		conn, err := m.c.dialWatch(base)
+		if err != nil {
+			if badStatus(err) {
+				// Retry once with a fresh token before giving up on WebSockets
+				if !reauthenticated && m.c.reauthenticate(context.Background()) == nil {
+					reauthenticated = true
+					continue
+				}
+				m.c.logger.Infof("Server does not accept WebSocket watches, falling back to long polling")
+				return false
+			}
+			m.c.endpoints.report(base, nil, err)
+			m.c.logger.Warnf("WebSocket watch connection failed: %v", err)
+			m.reportAll(0, err)
+			failures++
+			m.c.retryWait(context.Background(), http.MethodGet, watchWebSocketPath, failures, nil, err)
+			continue
+		}
+
+		failures = 0
+		reauthenticated = false
+		if err := m.serveConn(conn, resync); err != nil && err != errNoWatches {
+			m.c.logger.Warnf("WebSocket watch connection lost: %v", err)
+			m.reportAll(0, err)
+		}
+		conn.Close()
+	}
+}
+
+// serveConn keeps the server's subscriptions on conn in line with the
+// watched keys and dispatches the changes it pushes, until the connection
+// fails or nothing is watched any more
+func (m *watchMux) serveConn(conn *websocket.Conn, resync <-chan struct{}) error {
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			var msg wsMessage
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				closed <- err
+				return
+			}
+			if msg.Type == "change" && msg.Config != nil {
+				m.dispatch(newEvent(msg.Event, msg.Config))
+			}
+		}
+	}()
+
+	// Subscribe to everything watched so far; after a reconnect the known
+	// versions let the server send whatever changed in the meantime
+	subscribed := make(map[watchTarget]bool)
+	if err := m.syncSubscriptions(conn, subscribed); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-resync:
+			if err := m.syncSubscriptions(conn, subscribed); err != nil {
+				return err
+			}
+		case err := <-closed:
+			return err
+		}
+	}
+}
+
+// syncSubscriptions subscribes to newly watched keys and unsubscribes from
+// keys no longer watched, recording the result in subscribed
+func (m *watchMux) syncSubscriptions(conn *websocket.Conn, subscribed map[watchTarget]bool) error {
+	var add, remove []wsTarget
+
+	m.mu.Lock()
+	if len(m.subs) == 0 {
+		m.mu.Unlock()
+		return errNoWatches
+	}
+	for t := range m.subs {
+		if !subscribed[t] {
+			add = append(add, wsTarget{t, m.versions[t]})
+		}
+	}
+	for t := range subscribed {
+		if _, ok := m.subs[t]; !ok {
+			remove = append(remove, wsTarget{watchTarget: t})
+		}
+	}
+	m.mu.Unlock()
+
+	if len(add) > 0 {
+		if err := websocket.JSON.Send(conn, wsMessage{Type: "subscribe", Keys: add}); err != nil {
+			return err
+		}
+		for _, t := range add {
+			subscribed[t.watchTarget] = true
+		}
+	}
+	if len(remove) > 0 {
+		if err := websocket.JSON.Send(conn, wsMessage{Type: "unsubscribe", Keys: remove}); err != nil {
+			return err
+		}
+		for _, t := range remove {
+			delete(subscribed, t.watchTarget)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package viperotter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// KoanfProvider is a koanf Provider for a single otter config, to be loaded
+// with the koanf parser matching the config's type:
+//
+//	p := viperotter.Provider(c, "public", "DEFAULT_GROUP", "app.yaml")
+//	k.Load(p, yaml.Parser())
+type KoanfProvider struct {
+	client    *client.Client
+	namespace string
+	group     string
+	key       string
+
+	mu    sync.Mutex
+	watch *client.Watch
+}
+
+// Provider returns a koanf Provider that reads namespace/group/key through c
+func Provider(c *client.Client, namespace, group, key string) *KoanfProvider {
+	return &KoanfProvider{client: c, namespace: namespace, group: group, key: key}
+}
+
+// ReadBytes returns the raw config value
+func (p *KoanfProvider) ReadBytes() ([]byte, error) {
+	cfg, err := p.client.GetConfig(context.Background(), p.namespace, p.group, p.key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(cfg.Value), nil
+}
+
+// Read is not supported; use ReadBytes with a parser
+func (p *KoanfProvider) Read() (map[string]interface{}, error) {
+	return nil, errors.New("viperotter: koanf provider does not support Read, use a parser")
+}
+
+// Watch calls cb whenever the config changes, after which the application
+// reloads it with koanf's Load. Deletions are not reported.
+func (p *KoanfProvider) Watch(cb func(event interface{}, err error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.watch != nil {
+		return errors.New("viperotter: provider is already watching")
+	}
+	p.watch = p.client.WatchConfig(context.Background(), p.namespace, p.group, p.key, func(cfg *model.Config) {
+		if cfg.Version != -1 {
+			cb(nil, nil)
+		}
+	})
+	return nil
+}
+
+// Unwatch stops watching the config
+func (p *KoanfProvider) Unwatch() error {
+	p.mu.Lock()
+	w := p.watch
+	p.watch = nil
+	p.mu.Unlock()
+	if w != nil {
+		w.Stop()
+	}
+	return nil
+}
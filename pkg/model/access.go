@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ConfigAccess records how often a config has been fetched or watched, and
+// when and by which client it last was. LastAccessed is nil if it never was.
+type ConfigAccess struct {
+	Namespace    string     `json:"namespace"`
+	Group        string     `json:"group"`
+	Key          string     `json:"key"`
+	AccessCount  int64      `json:"access_count"`
+	LastAccessed *time.Time `json:"last_accessed"`
+	LastClientID string     `json:"last_client_id,omitempty"`
+	LastUsername string     `json:"last_username,omitempty"`
+	LastIP       string     `json:"last_ip,omitempty"`
+}
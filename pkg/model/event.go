@@ -0,0 +1,15 @@
+package model
+
+// Event types of a ConfigEvent
+const (
+	EventPut      = "PUT"
+	EventDelete   = "DELETE"
+	EventRollback = "ROLLBACK"
+)
+
+// ConfigEvent is a change to a configuration as delivered to watchers.
+// Deleted configs still carry Version -1 for older clients.
+type ConfigEvent struct {
+	Type   string  `json:"type"` // PUT, DELETE or ROLLBACK
+	Config *Config `json:"config"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Statuses of a scheduled change. Published changes are removed, their
+// write being recorded in the config's history.
+const (
+	ScheduleStatusPending    = "pending"
+	ScheduleStatusPublishing = "publishing"
+	ScheduleStatusFailed     = "failed"
+)
+
+// ScheduledChange is a config value waiting to be written at PublishAt
+type ScheduledChange struct {
+	ID          int64     `json:"id"`
+	Namespace   string    `json:"namespace"`
+	Group       string    `json:"group"`
+	Key         string    `json:"key"`
+	Value       string    `json:"value"`
+	Type        string    `json:"type"`
+	ContentType string    `json:"content_type,omitempty"`
+	PublishAt   time.Time `json:"publish_at"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Session is one login of a user. Revoking it revokes every token issued
+// for it.
+type Session struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Current     bool      `json:"current"` // The session making the request
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// User represents a user in the system.
+type User struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`   // admin, user or viewer (read-only)
+	Status    string    `json:"status"` // active or inactive
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ConfigVariant is an alternative value of a config served to clients whose
+// labels match its selector, such as region=eu or tier=canary
+type ConfigVariant struct {
+	Namespace string            `json:"namespace"`
+	Group     string            `json:"group"`
+	Key       string            `json:"key"`
+	Name      string            `json:"name"`
+	Selector  map[string]string `json:"selector"`
+	Value     string            `json:"value"`
+	UpdatedBy string            `json:"updated_by"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Statuses of a webhook delivery. A delivery whose every attempt failed is
+// dead until it is redelivered.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// Webhook posts the config changes of a namespace to a URL
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Namespace string    `json:"namespace"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is a change event queued for a webhook, kept until it is
+// delivered or has failed every attempt
+type WebhookDelivery struct {
+	ID             int64     `json:"id"`
+	WebhookID      int64     `json:"webhook_id"`
+	Namespace      string    `json:"namespace"`
+	Event          string    `json:"event"`
+	Payload        string    `json:"payload"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
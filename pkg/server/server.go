@@ -0,0 +1,534 @@
+// Package server runs an otter config center inside another Go program,
+// such as an integration test or an all-in-one appliance, with the same
+// API, web console and options as the otter binary.
+//
+//	srv, err := server.New(server.Options{Listen: []string{"127.0.0.1:0"}})
+//	if err != nil {
+//		return err
+//	}
+//	if err := srv.Start(); err != nil {
+//		return err
+//	}
+//	defer srv.Shutdown(context.Background())
+//	endpoint := "http://" + srv.Addrs()[0].String()
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sotowang/otter/internal/backup"
+	"github.com/sotowang/otter/internal/demo"
+	core "github.com/sotowang/otter/internal/server"
+	"github.com/sotowang/otter/internal/store"
+	"github.com/sotowang/otter/pkg/vault"
+)
+
+const (
+	// DefaultListen is the address served on when Options.Listen is empty
+	DefaultListen = ":8086"
+	// DefaultJWTSecret signs tokens when Options.JWTSecret is empty. It is
+	// public, so production deployments must set their own.
+	DefaultJWTSecret = "default-secret-key"
+	// DefaultDrainTimeout is used when Options.DrainTimeout is zero
+	DefaultDrainTimeout = 30 * time.Second
+	// DefaultSnapshotInterval is used when Options.SnapshotInterval is zero
+	DefaultSnapshotInterval = time.Minute
+	// DefaultMaxWatchWait is used when Options.MaxWatchWait is zero
+	DefaultMaxWatchWait = core.DefaultMaxWatchWait
+)
+
+// Options configure an embedded server. The zero value serves an in-memory
+// store on DefaultListen.
+type Options struct {
+	// DSN selects the store backend by its scheme: postgres://...,
+	// sqlite:///path/to/otter.db, memory:// or that of a backend added with
+	// store.Register. Empty keeps data in memory; a DSN without a scheme is
+	// a PostgreSQL connection string.
+	DSN string
+	// SnapshotFile keeps in-memory data across restarts: it is loaded on
+	// start if it exists, and saved every SnapshotInterval and on Shutdown.
+	// It requires in-memory storage.
+	SnapshotFile     string
+	SnapshotInterval time.Duration
+	// Listen are the addresses to serve on, as host:port or
+	// unix:/path/to.sock. Use 127.0.0.1:0 for a free port and Addrs to find
+	// it.
+	Listen []string
+	// AdminListen are addresses serving /healthz, /metrics, pprof and the
+	// admin API instead of the public listeners
+	AdminListen []string
+	// DrainTimeout is the window over which open watches are ended on
+	// Shutdown or Upgrade
+	DrainTimeout time.Duration
+	// MaxWatchWait bounds how long a long poll is held for a change,
+	// whatever ?wait= the client asks for; polls that ask for none are held
+	// 30s or MaxWatchWait if shorter. Keep it below the idle timeout of
+	// proxies in front of the server.
+	MaxWatchWait time.Duration
+
+	// JWTSecret signs tokens, and TenantSecrets the tokens of tenants given
+	// a secret of their own
+	JWTSecret     string
+	TenantSecrets map[string]string
+	// LoginMaxFailures and LoginLockout configure login lockouts; zero
+	// values keep the defaults of 5 failures and a 1m first lockout
+	LoginMaxFailures int
+	LoginLockout     time.Duration
+
+	// IPAllow and IPDeny are IP rules as [METHOD ][/path/prefix=]cidr[,cidr...].
+	// TrustedProxies are the proxy networks whose X-Forwarded-For gives the
+	// client IP; nil trusts none, using the connection's address.
+	IPAllow        []string
+	IPDeny         []string
+	TrustedProxies []string
+
+	// SigningKey signs served configs, at least 16 bytes; nil disables it
+	SigningKey []byte
+	// NotifyBus shares change events with other instances, e.g.
+	// redis://localhost:6379/0
+	NotifyBus string
+	// ChangeSinks publish every config change to external systems, e.g.
+	// kafka://localhost:9092/otter.changes or
+	// nats://localhost:4222/otter.changes?jetstream=true
+	ChangeSinks []string
+	// Vault resolves vault:<path>#<field> config values, nil disables it
+	Vault *vault.Config
+
+	// BackupTarget is a directory or s3://bucket/prefix for saved backups,
+	// written every BackupInterval if positive and keeping the newest
+	// BackupKeep, or all if zero
+	BackupTarget   string
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// ScheduleInterval is how often scheduled config changes are looked
+	// for to publish; zero keeps the default of 1s
+	ScheduleInterval time.Duration
+
+	// AccessFlushInterval is how often the fetches and watches counted per
+	// config are written to the store; zero keeps the default of 10s
+	AccessFlushInterval time.Duration
+
+	// WebhookInterval is how often webhook deliveries falling due for a
+	// retry are looked for; zero keeps the default of 1s
+	WebhookInterval time.Duration
+
+	// SeedDemo populates example data and a read-only demo user
+	SeedDemo bool
+	// LogRequestBodies logs request bodies with sensitive fields redacted
+	LogRequestBodies bool
+	// Logger receives the server's logs; nil discards them
+	Logger *zap.Logger
+	// LogLevel is the level of Logger, adjusted by the settings file
+	LogLevel *zap.AtomicLevel
+	// SettingsFile is a YAML file of settings applied on Reload without a
+	// restart: log_level, token_rate_limit, cors_origins and default_quota
+	SettingsFile string
+}
+
+// Server is an otter config center embedded in the current process
+type Server struct {
+	opts   Options
+	core   *core.Server
+	logger *zap.Logger
+
+	// ctx ends the background work of the notify bus, change sinks, backup
+	// schedule and change scheduler
+	ctx    context.Context
+	cancel context.CancelFunc
+	bus    core.NotifyBus
+	sinks  []core.ChangeSink
+
+	// memory is the store saved to the snapshot file, if any. snapshotMu
+	// serializes saves, which stop once the snapshot is handed to the new
+	// process of an Upgrade.
+	memory           *store.InMemoryStore
+	snapshotMu       sync.Mutex
+	snapshotHandedOn bool
+
+	listeners      []net.Listener
+	adminListeners []net.Listener
+	errs           chan error
+	shutdown       sync.Once
+}
+
+// New builds a server from opts, opening its store, without serving yet
+func New(opts Options) (*Server, error) {
+	if len(opts.Listen) == 0 {
+		opts.Listen = []string{DefaultListen}
+	}
+	if opts.JWTSecret == "" {
+		opts.JWTSecret = DefaultJWTSecret
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	if opts.SigningKey != nil && len(opts.SigningKey) < 16 {
+		return nil, errors.New("signing key must be at least 16 bytes")
+	}
+	logger := opts.Logger
+
+	logger.Info("Opening store", zap.String("backend", store.Scheme(opts.DSN)))
+	st, err := store.Open(opts.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("initialize store: %w", err)
+	}
+
+	// Keep in-memory data across restarts
+	var memory *store.InMemoryStore
+	if opts.SnapshotFile != "" {
+		var ok bool
+		if memory, ok = st.(*store.InMemoryStore); !ok {
+			return nil, errors.New("snapshot file requires in-memory storage")
+		}
+		if opts.SnapshotInterval <= 0 {
+			opts.SnapshotInterval = DefaultSnapshotInterval
+		}
+		if err := memory.LoadSnapshot(opts.SnapshotFile); errors.Is(err, fs.ErrNotExist) {
+			logger.Info("No snapshot to load yet", zap.String("file", opts.SnapshotFile))
+		} else if err != nil {
+			return nil, fmt.Errorf("load snapshot: %w", err)
+		} else {
+			logger.Info("Loaded snapshot", zap.String("file", opts.SnapshotFile))
+		}
+	}
+
+	// Populate example data for evaluation and UI development
+	if opts.SeedDemo {
+		seeded, err := demo.Seed(context.Background(), st)
+		if err != nil {
+			return nil, fmt.Errorf("seed demo data: %w", err)
+		}
+		if seeded {
+			logger.Info("Seeded demo data", zap.String("username", demo.Username), zap.String("password", demo.Password))
+		} else {
+			logger.Info("Demo data already present, skipping seeding")
+		}
+	}
+
+	srv := core.NewServer(st, opts.JWTSecret, logger)
+	s := &Server{opts: opts, core: srv, logger: logger, memory: memory, errs: make(chan error, 1)}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	if err := s.configure(); err != nil {
+		s.cancel()
+		if s.bus != nil {
+			s.bus.Close()
+		}
+		s.closeSinks()
+		return nil, err
+	}
+	return s, nil
+}
+
+// configure applies the options beyond the store to the core server
+func (s *Server) configure() error {
+	opts, srv := s.opts, s.core
+
+	if opts.LogLevel != nil {
+		srv.SetLogLevel(*opts.LogLevel)
+	}
+	if opts.MaxWatchWait > 0 {
+		srv.SetMaxWatchWait(opts.MaxWatchWait)
+	}
+	if opts.SettingsFile != "" {
+		if err := srv.SetSettingsFile(opts.SettingsFile); err != nil {
+			return fmt.Errorf("invalid settings file: %w", err)
+		}
+	}
+
+	if len(opts.TenantSecrets) > 0 {
+		srv.SetTenantSecrets(opts.TenantSecrets)
+		s.logger.Info("Using tenant JWT secrets", zap.Int("tenants", len(opts.TenantSecrets)))
+	}
+
+	// Configure IP allow/deny rules
+	if len(opts.IPAllow) > 0 || len(opts.IPDeny) > 0 {
+		var rules []core.IPRule
+		for _, spec := range opts.IPAllow {
+			rule, err := core.ParseIPRule(spec, true)
+			if err != nil {
+				return fmt.Errorf("invalid allow rule: %w", err)
+			}
+			rules = append(rules, rule)
+		}
+		for _, spec := range opts.IPDeny {
+			rule, err := core.ParseIPRule(spec, false)
+			if err != nil {
+				return fmt.Errorf("invalid deny rule: %w", err)
+			}
+			rules = append(rules, rule)
+		}
+		srv.SetIPFilter(core.NewIPFilter(rules))
+	}
+
+	// Only trust forwarding headers from known proxies, otherwise clients could spoof their address
+	if err := srv.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	maxFailures, lockout := opts.LoginMaxFailures, opts.LoginLockout
+	if maxFailures <= 0 {
+		maxFailures = 5
+	}
+	if lockout <= 0 {
+		lockout = time.Minute
+	}
+	srv.SetLoginGuard(core.NewLoginGuard(maxFailures, lockout))
+
+	// Sign served configs so SDK clients can detect tampering in transit
+	if opts.SigningKey != nil {
+		srv.SetSigningKey(opts.SigningKey)
+		s.logger.Info("Signing served configs")
+	}
+
+	if opts.LogRequestBodies {
+		srv.SetLogRequestBodies(true)
+		s.logger.Warn("Logging request bodies")
+	}
+
+	// Share change events with the other instances
+	if opts.NotifyBus != "" {
+		bus, err := core.NewNotifyBus(opts.NotifyBus)
+		if err != nil {
+			return fmt.Errorf("invalid notify bus: %w", err)
+		}
+		s.bus = bus
+		srv.SetNotifyBus(s.ctx, bus)
+		s.logger.Info("Using notify bus", zap.String("bus", opts.NotifyBus))
+	}
+
+	// Publish changes to data pipelines and audit systems
+	for _, sinkURL := range opts.ChangeSinks {
+		sink, err := core.NewChangeSink(sinkURL)
+		if err != nil {
+			return fmt.Errorf("invalid change sink: %w", err)
+		}
+		s.sinks = append(s.sinks, sink)
+		srv.AddChangeSink(s.ctx, sink)
+		s.logger.Info("Publishing changes to change sink", zap.String("sink", sinkURL))
+	}
+
+	// Resolve Vault secret references when configs are read
+	if opts.Vault != nil {
+		srv.SetSecretResolver(vault.NewResolver(*opts.Vault))
+		s.logger.Info("Resolving Vault secret references", zap.String("vault", opts.Vault.Address))
+	}
+
+	// Publish scheduled config changes when they fall due
+	srv.StartScheduler(s.ctx, opts.ScheduleInterval)
+
+	// Record when and by whom each config is read
+	srv.StartAccessTracking(s.ctx, opts.AccessFlushInterval)
+
+	// Deliver config changes to webhooks, retrying failed deliveries
+	srv.StartWebhookDelivery(s.ctx, opts.WebhookInterval)
+
+	// Save backups for disaster recovery
+	if opts.BackupTarget != "" {
+		target, err := backup.Open(opts.BackupTarget)
+		if err != nil {
+			return fmt.Errorf("invalid backup target: %w", err)
+		}
+		srv.SetBackupSchedule(s.ctx, target, opts.BackupInterval, opts.BackupKeep)
+		s.logger.Info("Saving backups", zap.String("target", target.String()),
+			zap.Duration("interval", opts.BackupInterval), zap.Int("keep", opts.BackupKeep))
+	}
+
+	// Save in-memory data for the next start
+	if s.memory != nil {
+		go s.saveSnapshots(opts.SnapshotInterval)
+		s.logger.Info("Saving snapshots", zap.String("file", opts.SnapshotFile), zap.Duration("interval", opts.SnapshotInterval))
+	}
+	return nil
+}
+
+// saveSnapshots saves the snapshot file every interval until the server
+// shuts down
+func (s *Server) saveSnapshots(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.saveSnapshot()
+		}
+	}
+}
+
+// saveSnapshot saves the in-memory store to the snapshot file, unless it
+// was handed on to an upgraded process
+func (s *Server) saveSnapshot() error {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	if s.snapshotHandedOn {
+		return nil
+	}
+	if err := s.memory.SaveSnapshot(s.opts.SnapshotFile); err != nil {
+		s.logger.Error("Failed to save snapshot", zap.String("file", s.opts.SnapshotFile), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// LoadTenantSecrets reads a JSON object mapping tenant names to their JWT
+// secrets, for Options.TenantSecrets
+func LoadTenantSecrets(path string) (map[string]string, error) {
+	return core.LoadTenantSecrets(path)
+}
+
+// Start opens the listeners, taking over those handed down by a previous
+// process's Upgrade, and serves on them in the background. It returns once
+// the server accepts connections; failures after that are sent on Err.
+func (s *Server) Start() error {
+	for _, addr := range s.opts.Listen {
+		l, err := core.Listen(addr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		s.listeners = append(s.listeners, l)
+	}
+	// Move monitoring and admin routes off the public listeners
+	for _, addr := range s.opts.AdminListen {
+		l, err := core.Listen(addr)
+		if err != nil {
+			s.closeListeners()
+			return fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		s.adminListeners = append(s.adminListeners, l)
+	}
+	s.core.SetAdminListeners(s.adminListeners...)
+
+	s.logger.Info("Starting otter config center", zap.Strings("listen", s.opts.Listen), zap.Strings("admin_listen", s.opts.AdminListen))
+	go func() {
+		if err := s.core.Serve(s.listeners...); err != nil {
+			s.errs <- err
+		}
+	}()
+	core.NotifyReady()
+	return nil
+}
+
+func (s *Server) closeListeners() {
+	for _, l := range append(s.listeners, s.adminListeners...) {
+		l.Close()
+	}
+	s.listeners, s.adminListeners = nil, nil
+}
+
+// Addrs returns the addresses of the public listeners, once started
+func (s *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, l := range s.listeners {
+		addrs[i] = l.Addr()
+	}
+	return addrs
+}
+
+// AdminAddrs returns the addresses of the admin listeners, once started
+func (s *Server) AdminAddrs() []net.Addr {
+	addrs := make([]net.Addr, len(s.adminListeners))
+	for i, l := range s.adminListeners {
+		addrs[i] = l.Addr()
+	}
+	return addrs
+}
+
+// Err receives the error a listener failed with while serving
+func (s *Server) Err() <-chan error {
+	return s.errs
+}
+
+// Reload applies the settings file again, keeping the current settings if
+// it is invalid. Requests and watches carry on undisturbed.
+func (s *Server) Reload() error {
+	_, err := s.core.ReloadSettings()
+	return err
+}
+
+// Upgrade starts a new process of the current executable with the same
+// arguments, hands it the listeners and returns once it is serving. The
+// caller should then Shutdown this server. With in-memory storage the new
+// process starts empty, or from the snapshot file saved just before it
+// starts, after which this process stops saving it.
+func (s *Server) Upgrade() error {
+	if s.memory != nil {
+		if err := s.saveSnapshot(); err != nil {
+			return fmt.Errorf("save snapshot: %w", err)
+		}
+		s.snapshotMu.Lock()
+		s.snapshotHandedOn = true
+		s.snapshotMu.Unlock()
+		s.logger.Warn("Upgrading with in-memory storage, changes made from now on by this process are not carried over")
+	} else if store.Scheme(s.opts.DSN) == "memory" {
+		s.logger.Warn("Upgrading with in-memory storage, the new process starts empty")
+	}
+	addrs := append(append([]string(nil), s.opts.Listen...), s.opts.AdminListen...)
+	listeners := append(append([]net.Listener(nil), s.listeners...), s.adminListeners...)
+	if err := core.Upgrade(addrs, listeners); err != nil {
+		if s.memory != nil {
+			// This process carries on, so keep saving
+			s.snapshotMu.Lock()
+			s.snapshotHandedOn = false
+			s.snapshotMu.Unlock()
+		}
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections, ends open watches spread over the
+// drain timeout, or until ctx is done if that comes first, completes
+// requests in flight, stops background work and saves the snapshot file.
+// It returns ctx's error if ctx ended before connections were closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var err error
+	s.shutdown.Do(func() {
+		window := s.opts.DrainTimeout
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < window {
+			window = time.Until(deadline)
+		}
+		drained := make(chan struct{})
+		go func() {
+			s.core.Drain(window)
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		s.cancel()
+		s.core.FlushAccess(context.Background())
+		if s.memory != nil {
+			s.saveSnapshot()
+		}
+		if s.bus != nil {
+			s.bus.Close()
+		}
+		s.closeSinks()
+		s.closeListeners()
+	})
+	return err
+}
+
+// closeSinks closes the change sinks
+func (s *Server) closeSinks() {
+	for _, sink := range s.sinks {
+		sink.Close()
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sotowang/otter/pkg/client"
+	"github.com/sotowang/otter/pkg/model"
+)
+
+// TestEmbeddedServer tests that an embedded server serves the API on a free
+// port and the admin routes on its admin listener, and shuts down cleanly
+func TestEmbeddedServer(t *testing.T) {
+	srv, err := New(Options{
+		Listen:       []string{"127.0.0.1:0"},
+		AdminListen:  []string{"127.0.0.1:0"},
+		DrainTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	endpoint := "http://" + srv.Addrs()[0].String()
+
+	ctx := context.Background()
+	c := client.NewClientWithConfig(client.ClientConfig{Endpoint: endpoint})
+	if err := c.Login(ctx, "admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PutConfig(ctx, "public", "app", "timeout", "30s", "text"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := c.GetConfig(ctx, "public", "app", "timeout")
+	if err != nil || cfg.Value != "30s" {
+		t.Fatalf("GetConfig = %+v, %v, want 30s", cfg, err)
+	}
+
+	for base, want := range map[string]int{endpoint: http.StatusNotFound, "http://" + srv.AdminAddrs()[0].String(): http.StatusOK} {
+		resp, err := http.Get(base + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("GET %s/healthz = %d, want %d", base, resp.StatusCode, want)
+		}
+	}
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(endpoint + "/healthz"); err == nil {
+		t.Error("still serving after Shutdown")
+	}
+}
+
+// TestSnapshotFile tests that in-memory data saved to the snapshot file on
+// Shutdown is there again after a restart, versions carrying on
+func TestSnapshotFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "otter.snapshot")
+	ctx := context.Background()
+	start := func() (*Server, *client.Client) {
+		srv, err := New(Options{Listen: []string{"127.0.0.1:0"}, DrainTimeout: 100 * time.Millisecond, SnapshotFile: file})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+		c := client.NewClientWithConfig(client.ClientConfig{Endpoint: "http://" + srv.Addrs()[0].String()})
+		if err := c.Login(ctx, "admin", "admin"); err != nil {
+			t.Fatal(err)
+		}
+		return srv, c
+	}
+
+	srv, c := start()
+	if _, err := c.PutConfig(ctx, "public", "app", "timeout", "30s", "text"); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, c = start()
+	defer srv.Shutdown(ctx)
+	cfg, err := c.GetConfig(ctx, "public", "app", "timeout")
+	if err != nil || cfg.Value != "30s" {
+		t.Fatalf("GetConfig after restart = %+v, %v, want 30s", cfg, err)
+	}
+	if cfg, err := c.PutConfig(ctx, "public", "app", "timeout", "60s", "text"); err != nil || cfg.Version != 2 {
+		t.Errorf("PutConfig after restart = %+v, %v, want version 2", cfg, err)
+	}
+
+	if _, err := New(Options{DSN: "sqlite://" + filepath.Join(t.TempDir(), "otter.db"), SnapshotFile: file}); err == nil {
+		t.Error("New with a snapshot file and SQLite storage succeeded")
+	}
+}
+
+// benchmarkWatchers is the number of clients watching the key changed by
+// BenchmarkWatchFanOut
+const benchmarkWatchers = 100
+
+// startBenchmarkServer starts an embedded server without a token rate limit
+// and returns a logged-in client of it
+func startBenchmarkServer(b *testing.B) (*Server, *client.Client) {
+	settings := filepath.Join(b.TempDir(), "settings.yaml")
+	if err := os.WriteFile(settings, []byte("token_rate_limit: 0\n"), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	srv, err := New(Options{Listen: []string{"127.0.0.1:0"}, DrainTimeout: 100 * time.Millisecond, SettingsFile: settings})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := srv.Start(); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	c := client.NewClientWithConfig(client.ClientConfig{
+		Endpoint: "http://" + srv.Addrs()[0].String(),
+		Logger:   client.NopLogger{},
+	})
+	if err := c.Login(context.Background(), "admin", "admin"); err != nil {
+		b.Fatal(err)
+	}
+	return srv, c
+}
+
+// BenchmarkGetConfig measures getting configs over HTTP, as many requests in
+// parallel as GOMAXPROCS
+func BenchmarkGetConfig(b *testing.B) {
+	_, c := startBenchmarkServer(b)
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		if _, err := c.PutConfig(ctx, "public", "bench", fmt.Sprintf("k%d", i), "value", "text"); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := c.GetConfig(ctx, "public", "bench", fmt.Sprintf("k%d", i%100)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkPutConfig measures putting configs over HTTP, each a new version
+// with its history entry and change notification
+func BenchmarkPutConfig(b *testing.B) {
+	_, c := startBenchmarkServer(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.PutConfig(ctx, "public", "bench", fmt.Sprintf("k%d", i%100), strconv.Itoa(i), "text"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkWatchFanOut measures the time from putting a config to all of
+// benchmarkWatchers long-polling clients receiving the change
+func BenchmarkWatchFanOut(b *testing.B) {
+	srv, c := startBenchmarkServer(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := c.PutConfig(ctx, "public", "bench", "fanout", "-1", "text"); err != nil {
+		b.Fatal(err)
+	}
+
+	var target atomic.Int64
+	target.Store(-1)
+	received := make(chan struct{}, benchmarkWatchers)
+	for i := 0; i < benchmarkWatchers; i++ {
+		watcher := client.NewClientWithConfig(client.ClientConfig{
+			Endpoint: "http://" + srv.Addrs()[0].String(),
+			Token:    c.Token(),
+			Logger:   client.NopLogger{},
+		})
+		watcher.WatchConfig(ctx, "public", "bench", "fanout", func(cfg *model.Config) {
+			if n, err := strconv.ParseInt(cfg.Value, 10, 64); err == nil && n == target.Load() {
+				received <- struct{}{}
+			}
+		})
+	}
+	time.Sleep(time.Second)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		target.Store(int64(i))
+		if _, err := c.PutConfig(ctx, "public", "bench", "fanout", strconv.Itoa(i), "text"); err != nil {
+			b.Fatal(err)
+		}
+		for n := 0; n < benchmarkWatchers; n++ {
+			select {
+			case <-received:
+			case <-time.After(10 * time.Second):
+				b.Fatalf("change %d reached %d of %d watchers", i, n, benchmarkWatchers)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Package storeplugin serves store plugins, external processes an Otter
+// server started with -dsn grpc://host:port keeps its data in, so storage
+// integrations can live outside the Otter codebase.
+//
+// A plugin serves the gRPC service otter.store.v1.Store over HTTP/2
+// cleartext with the JSON codec (content type application/grpc+json). It
+// has a unary method for every method of Store, named alike. The request
+// of a method is a JSON array of its arguments after the context, and the
+// response a JSON array of its results before the error, followed by every
+// pointer argument as the plugin left it, so the IDs and versions it
+// assigns reach the server. For example, a call of Get is answered like
+//
+//	/otter.store.v1.Store/Get  ["public", "billing", "rate"]
+//	[{"namespace": "public", "group": "billing", "key": "rate", ...}]
+//
+// and a call of Put like
+//
+//	/otter.store.v1.Store/Put  [{"namespace": "public", ...}]
+//	[{"namespace": "public", ..., "version": 3}]
+//
+// ErrNotFound is sent as the status NotFound and ErrRateLimited as
+// ResourceExhausted; other errors as Unknown with their text. Plugins in
+// other languages can implement the service with any gRPC library
+// supporting a JSON codec.
+package storeplugin
+
+import (
+	"net/http"
+
+	"github.com/sotowang/otter/internal/model"
+	"github.com/sotowang/otter/internal/store"
+)
+
+// Service is the name of the gRPC service of store plugins
+const Service = store.GRPCService
+
+// Store is what a plugin stores, and its parts
+type (
+	Store       = store.Store
+	ConfigStore = store.ConfigStore
+	UserStore   = store.UserStore
+	TokenStore  = store.TokenStore
+)
+
+// The records passed to and returned by the methods of Store
+type (
+	Config          = model.Config
+	NamespaceQuota  = model.NamespaceQuota
+	ConfigLock      = model.ConfigLock
+	ConfigOwner     = model.ConfigOwner
+	ConfigVariant   = model.ConfigVariant
+	ConfigAccess    = model.ConfigAccess
+	ScheduledChange = model.ScheduledChange
+	Webhook         = model.Webhook
+	WebhookDelivery = model.WebhookDelivery
+	ConfigHistory   = model.ConfigHistory
+	Tenant          = model.Tenant
+	User            = model.User
+	Session         = model.Session
+	Team            = model.Team
+	Notification    = model.Notification
+)
+
+// Errors a plugin returns for the server to recognize them
+var (
+	ErrNotFound    = store.ErrNotFound
+	ErrRateLimited = store.ErrRateLimited
+)
+
+// Handler returns the handler of a plugin storing with impl. impl has the
+// methods of Store it supports, with the same signatures; calls of the
+// others fail with Unimplemented, so a plugin can leave out what its
+// servers never use, or embed a Store to override part of it. Handler
+// panics if a method of impl named like one of Store has another signature.
+func Handler(impl any) http.Handler {
+	return store.NewGRPCHandler(impl)
+}
+
+// ListenAndServe serves the plugin storing with impl on addr, a host:port
+func ListenAndServe(addr string, impl any) error {
+	return (&http.Server{Addr: addr, Handler: Handler(impl)}).ListenAndServe()
+}
@@ -0,0 +1,216 @@
+// Package vault resolves config values that reference HashiCorp Vault
+// secrets, such as vault:secret/data/db#password, so secrets can stay in
+// Vault while the rest of the config lives in Otter.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix marks a config value that references a Vault secret
+const Prefix = "vault:"
+
+// defaultCacheTTL is how long secrets without a lease are cached
+const defaultCacheTTL = 5 * time.Minute
+
+// ParseRef splits a vault:<path>#<field> reference. ok is false for values
+// that are not references, including values with the prefix but no field.
+func ParseRef(value string) (path, field string, ok bool) {
+	if !strings.HasPrefix(value, Prefix) {
+		return "", "", false
+	}
+	path, field, ok = strings.Cut(strings.TrimPrefix(value, Prefix), "#")
+	if !ok || path == "" || field == "" {
+		return "", "", false
+	}
+	return strings.Trim(path, "/"), field, true
+}
+
+// Config configures a Resolver
+type Config struct {
+	Address    string        // Vault address, e.g. https://vault.example.com:8200
+	Token      string        // Vault token allowed to read the referenced paths
+	Namespace  string        // Vault Enterprise namespace, if any
+	CacheTTL   time.Duration // How long to cache secrets without a lease, 5 minutes if zero
+	HTTPClient *http.Client  // HTTP client, http.DefaultClient if nil
+}
+
+// Resolver reads referenced secrets from Vault. Secrets are cached for their
+// lease duration, or Config.CacheTTL if they have none, and renewable leases
+// are renewed once two thirds of them have passed. It is safe for concurrent
+// use.
+type Resolver struct {
+	config Config
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*secret
+}
+
+// secret is a cached Vault read
+type secret struct {
+	data      map[string]any
+	leaseID   string
+	renewable bool
+	lease     time.Duration
+	renewAt   time.Time
+	expires   time.Time
+}
+
+// NewResolver creates a Resolver
+func NewResolver(config Config) *Resolver {
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = defaultCacheTTL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &Resolver{config: config, now: time.Now, cache: make(map[string]*secret)}
+}
+
+// Resolve returns the referenced secret field if value is a Vault
+// reference, and value unchanged otherwise
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	path, field, ok := ParseRef(value)
+	if !ok {
+		return value, nil
+	}
+	s, err := r.secret(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("vault %s: %w", path, err)
+	}
+	v, ok := s.data[field]
+	if !ok {
+		return "", fmt.Errorf("vault %s: no field %q", path, field)
+	}
+	if str, ok := v.(string); ok {
+		return str, nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// secret returns the secret at path from the cache, renewing its lease or
+// reading it again as needed
+func (r *Resolver) secret(ctx context.Context, path string) (*secret, error) {
+	now := r.now()
+	r.mu.Lock()
+	s := r.cache[path]
+	r.mu.Unlock()
+
+	if s != nil && now.Before(s.expires) {
+		if !s.renewable || now.Before(s.renewAt) {
+			return s, nil
+		}
+		if renewed, err := r.renew(ctx, s); err == nil {
+			r.store(path, renewed)
+			return renewed, nil
+		}
+		// Fall through to read a fresh secret if the lease cannot be renewed
+	}
+
+	s, err := r.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	r.store(path, s)
+	return s, nil
+}
+
+func (r *Resolver) store(path string, s *secret) {
+	r.mu.Lock()
+	r.cache[path] = s
+	r.mu.Unlock()
+}
+
+// leaseResponse holds the fields of a Vault response that describe a secret
+type leaseResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	Renewable     bool           `json:"renewable"`
+	LeaseDuration int64          `json:"lease_duration"`
+	Data          map[string]any `json:"data"`
+}
+
+// read fetches a secret. KV version 2 responses nest the secret under
+// data.data, which is unwrapped.
+func (r *Resolver) read(ctx context.Context, path string) (*secret, error) {
+	var res leaseResponse
+	if err := r.do(ctx, http.MethodGet, "/v1/"+path, nil, &res); err != nil {
+		return nil, err
+	}
+	data := res.Data
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, isKV2 := data["metadata"]; isKV2 {
+			data = inner
+		}
+	}
+	return r.newSecret(res, data), nil
+}
+
+// renew extends the lease of a dynamic secret
+func (r *Resolver) renew(ctx context.Context, s *secret) (*secret, error) {
+	var res leaseResponse
+	body := map[string]any{"lease_id": s.leaseID, "increment": int64(s.lease / time.Second)}
+	if err := r.do(ctx, http.MethodPut, "/v1/sys/leases/renew", body, &res); err != nil {
+		return nil, err
+	}
+	return r.newSecret(res, s.data), nil
+}
+
+func (r *Resolver) newSecret(res leaseResponse, data map[string]any) *secret {
+	now := r.now()
+	s := &secret{data: data, leaseID: res.LeaseID, renewable: res.Renewable && res.LeaseID != ""}
+	if res.LeaseDuration > 0 {
+		s.lease = time.Duration(res.LeaseDuration) * time.Second
+		s.expires = now.Add(s.lease)
+		s.renewAt = now.Add(s.lease * 2 / 3)
+	} else {
+		s.expires = now.Add(r.config.CacheTTL)
+	}
+	return s
+}
+
+// do sends a request to Vault and decodes the JSON response into out
+func (r *Resolver) do(ctx context.Context, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(r.config.Address, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", r.config.Token)
+	if r.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
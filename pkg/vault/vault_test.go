@@ -0,0 +1,93 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestResolve tests that references resolve against KV version 2 and are
+// served from the cache afterwards, while other values pass through
+func TestResolve(t *testing.T) {
+	var reads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/db" || r.Header.Get("X-Vault-Token") != "vt" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		reads.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"data":     map[string]any{"password": "s3cret", "port": 5432},
+			"metadata": map[string]any{"version": 3},
+		}})
+	}))
+	defer srv.Close()
+
+	r := NewResolver(Config{Address: srv.URL, Token: "vt"})
+	ctx := context.Background()
+	for value, want := range map[string]string{
+		"vault:secret/data/db#password": "s3cret",
+		"vault:secret/data/db#port":     "5432",
+		"vault:v1:not-a-reference":      "vault:v1:not-a-reference",
+		"plain":                         "plain",
+	} {
+		if got, err := r.Resolve(ctx, value); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+	if n := reads.Load(); n != 1 {
+		t.Errorf("read the secret %d times, want 1", n)
+	}
+
+	if _, err := r.Resolve(ctx, "vault:secret/data/db#user"); err == nil {
+		t.Error("resolving a missing field succeeded")
+	}
+	if _, err := r.Resolve(ctx, "vault:secret/data/other#password"); err == nil {
+		t.Error("resolving a denied path succeeded")
+	}
+}
+
+// TestLeaseRenewal tests that a dynamic secret's lease is renewed after two
+// thirds of it have passed and the secret is read again once it expires
+func TestLeaseRenewal(t *testing.T) {
+	var reads, renewals atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/database/creds/app":
+			n := reads.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id": "database/creds/app/1", "renewable": true, "lease_duration": 60,
+				"data": map[string]any{"username": "app-" + string(rune('0'+n))},
+			})
+		case "/v1/sys/leases/renew":
+			renewals.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{"lease_id": "database/creds/app/1", "renewable": false, "lease_duration": 60})
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	r := NewResolver(Config{Address: srv.URL})
+	r.now = func() time.Time { return now }
+	resolve := func() string {
+		v, err := r.Resolve(context.Background(), "vault:database/creds/app#username")
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		return v
+	}
+
+	resolve()
+	now = now.Add(50 * time.Second)
+	if v := resolve(); v != "app-1" || renewals.Load() != 1 {
+		t.Errorf("got %s after %d renewals, want app-1 after 1", v, renewals.Load())
+	}
+	now = now.Add(61 * time.Second)
+	if v := resolve(); v != "app-2" || reads.Load() != 2 {
+		t.Errorf("got %s after %d reads, want app-2 after 2", v, reads.Load())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals make the server hand its listeners to a new process
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// reloadSignals make the server reload its settings file
+var reloadSignals = []os.Signal{syscall.SIGHUP}
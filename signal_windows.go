@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// upgradeSignals is empty, as Windows has no signal to upgrade on
+var upgradeSignals []os.Signal
+
+// reloadSignals is empty; settings are reloaded through the admin API
+var reloadSignals []os.Signal
@@ -3,6 +3,7 @@ import type { Config } from '../../types';
 import CodeMirror from '@uiw/react-codemirror';
 import { StreamLanguage } from '@codemirror/language';
 import { properties } from '@codemirror/legacy-modes/mode/properties';
+import { toml } from '@codemirror/legacy-modes/mode/toml';
 import { json } from '@codemirror/lang-json';
 
 interface ConfigFormProps {
@@ -22,6 +23,9 @@ const ConfigForm: React.FC<ConfigFormProps> = React.memo(
       { value: 'properties', label: 'Properties' },
       { value: 'json', label: 'JSON' },
       { value: 'yaml', label: 'YAML' },
+      { value: 'toml', label: 'TOML' },
+      { value: 'ini', label: 'INI' },
+      { value: 'hcl', label: 'HCL' },
       { value: 'xml', label: 'XML' },
       { value: 'markdown', label: 'Markdown' },
     ];
@@ -195,9 +199,11 @@ const ConfigForm: React.FC<ConfigFormProps> = React.memo(
                 extensions={[
                   type === 'json'
                     ? json()
-                    : type === 'properties'
+                    : type === 'properties' || type === 'ini'
                       ? StreamLanguage.define(properties)
-                      : [],
+                      : type === 'toml'
+                        ? StreamLanguage.define(toml)
+                        : [],
                 ]}
                 onChange={(val) => setValue(val)}
               />
@@ -79,8 +79,9 @@ export const configAPI = {
     group: string,
     key: string
   ): Promise<Config> => {
+    // 编辑时保留 Vault 引用而不是解析后的密钥
     const response = await fetch(
-      `${API_BASE}/namespaces/${namespace}/groups/${group}/configs/${key}`,
+      `${API_BASE}/namespaces/${namespace}/groups/${group}/configs/${key}?resolve=false`,
       {
         headers: getHeaders(),
       }